@@ -0,0 +1,271 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The issues CheckAccessibility finds
+const (
+	AccessibilityMissingAlt   = "missing alt text"      // an image without alt, which alt="" is not
+	AccessibilityEmptyLink    = "empty link"            // a link with no text nor label to tell where it goes
+	AccessibilityEmptyButton  = "empty button"          // a button with no text nor label
+	AccessibilityHeadingOrder = "skipped heading level" // an h4 right after an h2, for instance
+	AccessibilityMissingLang  = "missing lang"          // an <html> without lang
+	AccessibilityMissingLabel = "missing label"         // a form field with no label
+)
+
+// AccessibilityIssue is an accessibility issue found on a page.
+type AccessibilityIssue struct {
+	URL  string `json:"url"`  // the page
+	Kind string `json:"kind"` // AccessibilityMissingAlt and the like, or the rule of axe-core
+
+	// Detail is what the issue is about: the src of the image, the href
+	//   of the link, the headings of the skip, the name of the field or,
+	//   from axe-core, the selector of the element
+	Detail string `json:"detail,omitempty"`
+}
+
+// CheckAccessibility runs the built-in accessibility checks on the HTML
+// document doc, found at base: the images without alt text, the links and
+// buttons with nothing to name them by, the heading levels skipped, the
+// page without a language and the form fields without a label. They are a
+// few of those of axe-core, which package browser runs in full.
+func CheckAccessibility(base *url.URL, doc *html.Node) []AccessibilityIssue {
+	page := base.String()
+	labelled := make(map[string]bool) // the ids of the fields a <label for> names
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Label {
+			if id, ok := attr(n, "for"); ok {
+				labelled[id] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(doc)
+
+	var issues []AccessibilityIssue
+	flag := func(kind, detail string) {
+		issues = append(issues, AccessibilityIssue{URL: page, Kind: kind, Detail: detail})
+	}
+	heading := 0 // the level of the last one
+	var walk func(n *html.Node, inLabel bool)
+	walk = func(n *html.Node, inLabel bool) {
+		if n.Type == html.ElementNode && n.Namespace == "" && !a11yHidden(n) {
+			switch n.DataAtom {
+			case atom.Html:
+				if lang, _ := attr(n, "lang"); strings.TrimSpace(lang) == "" {
+					flag(AccessibilityMissingLang, "")
+				}
+			case atom.Img:
+				if _, ok := attr(n, "alt"); !ok && !a11yNamed(n) {
+					src, _ := attr(n, "src")
+					flag(AccessibilityMissingAlt, src)
+				}
+			case atom.A:
+				if href, ok := attr(n, "href"); ok && anchorText(n) == "" && !a11yNamed(n) {
+					flag(AccessibilityEmptyLink, href)
+				}
+			case atom.Button:
+				if anchorText(n) == "" && !a11yNamed(n) {
+					name, _ := attr(n, "name")
+					flag(AccessibilityEmptyButton, name)
+				}
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				level := int(n.Data[1] - '0')
+				if heading > 0 && level > heading+1 {
+					flag(AccessibilityHeadingOrder, fmt.Sprintf("h%d to h%d: %s", heading, level, anchorText(n)))
+				}
+				heading = level
+			case atom.Label:
+				inLabel = true
+			case atom.Input, atom.Select, atom.Textarea:
+				typ, _ := attr(n, "type")
+				switch strings.ToLower(typ) {
+				case "hidden", "submit", "reset", "button":
+				case "image":
+					if _, ok := attr(n, "alt"); !ok && !a11yNamed(n) {
+						src, _ := attr(n, "src")
+						flag(AccessibilityMissingAlt, src)
+					}
+				default:
+					id, _ := attr(n, "id")
+					if !inLabel && !labelled[id] && !a11yNamed(n) {
+						name, _ := attr(n, "name")
+						if name == "" {
+							name = id
+						}
+						flag(AccessibilityMissingLabel, name)
+					}
+				}
+			}
+		}
+		if n.Type == html.ElementNode && a11yHidden(n) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inLabel)
+		}
+	}
+	walk(doc, false)
+	return issues
+}
+
+// a11yNamed reports whether the element n is given a name the assistive
+// technologies read out, other than its text
+func a11yNamed(n *html.Node) bool {
+	for _, name := range []string{"aria-label", "aria-labelledby", "title"} {
+		if v, _ := attr(n, name); strings.TrimSpace(v) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// a11yHidden reports whether the element n, and what it holds, are hidden
+// from the assistive technologies, or only there for show
+func a11yHidden(n *html.Node) bool {
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	role, _ := attr(n, "role")
+	return role == "presentation" || role == "none"
+}
+
+// AccessibilityKind sums up the issues of a kind.
+type AccessibilityKind struct {
+	Kind   string `json:"kind"`
+	Issues int    `json:"issues"` // how many were found
+	Pages  int    `json:"pages"`  // on how many pages
+}
+
+// AccessibilityReport audits the accessibility of the HTML pages of a
+// crawl, with CheckAccessibility or, for the pages that have them, with
+// the issues their Fetcher found, see CrawlResult.Accessibility.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. An
+// AccessibilityReport is safe for concurrent use, its zero value is ready
+// to use.
+type AccessibilityReport struct {
+	mu     sync.Mutex
+	pages  int
+	issues []AccessibilityIssue
+}
+
+// Add audits the page of a fetch that went fine, other results are
+// ignored.
+func (r *AccessibilityReport) Add(res CrawlResult) {
+	if res.Err != nil || res.External || res.Asset || res.Duplicate || res.Body == "" && res.Accessibility == nil {
+		return
+	}
+	issues := res.Accessibility
+	if issues == nil {
+		if !htmlResult(res) {
+			return
+		}
+		base, doc, err := pageDoc(res)
+		if err != nil {
+			return
+		}
+		issues = CheckAccessibility(base, doc)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pages++
+	r.issues = append(r.issues, issues...)
+}
+
+// Issues returns the issues found so far, sorted by page, kind and detail.
+func (r *AccessibilityReport) Issues() []AccessibilityIssue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	issues := append([]AccessibilityIssue(nil), r.issues...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Detail < b.Detail
+	})
+	return issues
+}
+
+// Kinds returns the summary of every kind of issue found so far, the most
+// found first.
+func (r *AccessibilityReport) Kinds() []AccessibilityKind {
+	byKind := make(map[string]*AccessibilityKind)
+	pages := make(map[[2]string]bool)
+	for _, i := range r.Issues() {
+		k := byKind[i.Kind]
+		if k == nil {
+			k = &AccessibilityKind{Kind: i.Kind}
+			byKind[i.Kind] = k
+		}
+		k.Issues++
+		if !pages[[2]string{i.Kind, i.URL}] {
+			pages[[2]string{i.Kind, i.URL}] = true
+			k.Pages++
+		}
+	}
+	kinds := make([]AccessibilityKind, 0, len(byKind))
+	for _, k := range byKind {
+		kinds = append(kinds, *k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if kinds[i].Issues != kinds[j].Issues {
+			return kinds[i].Issues > kinds[j].Issues
+		}
+		return kinds[i].Kind < kinds[j].Kind
+	})
+	return kinds
+}
+
+// WriteText writes how many pages were audited, then the summary of each
+// kind of issue followed by its issues:
+//
+//	12 pages audited, 3 with issues
+//	missing alt text	4 on 2 pages
+//		https://example.com/	/img/logo.png
+func (r *AccessibilityReport) WriteText(w io.Writer) error {
+	issues := r.Issues()
+	byKind := make(map[string][]AccessibilityIssue)
+	withIssues := make(map[string]bool)
+	for _, i := range issues {
+		byKind[i.Kind] = append(byKind[i.Kind], i)
+		withIssues[i.URL] = true
+	}
+	r.mu.Lock()
+	pages := r.pages
+	r.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "%d pages audited, %d with issues\n", pages, len(withIssues)); err != nil {
+		return err
+	}
+	for _, k := range r.Kinds() {
+		if _, err := fmt.Fprintf(w, "%s\t%d on %d pages\n", k.Kind, k.Issues, k.Pages); err != nil {
+			return err
+		}
+		for _, i := range byKind[k.Kind] {
+			if _, err := fmt.Fprintf(w, "\t%s\t%s\n", i.URL, i.Detail); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
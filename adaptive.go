@@ -0,0 +1,122 @@
+package webcrawl
+
+import "time"
+
+// The defaults of AdaptiveConcurrency
+const (
+	DefaultMaxErrorRate = 0.1
+	DefaultMaxPerHost   = 8
+)
+
+// AdaptiveConcurrency has the Crawler find for itself how many pages to
+// fetch at the same time, from each host and in all, much as TCP finds its
+// window: one fetch more at a time for every round of them that goes fine,
+// half as many once the responses slow down or fail. The hosts that keep
+// up get crawled fast, the ones that struggle are left alone, and the
+// crawl as a whole backs off when everything slows down at once, as when
+// the network of the crawler is the bottleneck.
+//
+// A host starts with one fetch at a time, the crawl with MaxWorkers of
+// them. The backoff of the hosts that answer 429 or 503 holds the same.
+type AdaptiveConcurrency struct {
+	// Latency is the response time over which a host is taken to
+	//   struggle, zero for twice the fastest one seen from it. Response
+	//   times, as error rates, are smoothed over the last fetches
+	Latency time.Duration
+
+	// MaxErrorRate is the share of fetches failing, of the server or of
+	//   the network, over which a host is taken to struggle,
+	//   DefaultMaxErrorRate when zero
+	MaxErrorRate float64
+
+	// MaxPerHost caps the fetches from a host at the same time,
+	//   DefaultMaxPerHost when zero
+	MaxPerHost int
+
+	// MinWorkers and MaxWorkers bound the fetches at the same time in
+	//   all: one and four times Crawler.MaxWorkers when zero
+	MinWorkers, MaxWorkers int
+}
+
+// adaptState is what AdaptiveConcurrency makes of the fetches of a host,
+// or of the whole crawl
+type adaptState struct {
+	best   time.Duration // the fastest response
+	slow   float64       // response times over their target, smoothed
+	errors float64       // failures, smoothed
+	acks   int           // fetches since the limit last changed
+}
+
+// adaptSmoothing is the weight of the latest fetch in adaptState, some
+// ten fetches count
+const adaptSmoothing = 0.2
+
+// record has s take a fetch into account, of the response time took over
+// its target, or that failed
+func (s *adaptState) record(slow float64, failed bool) {
+	e := 0.0
+	if failed {
+		e = 1
+	}
+	s.errors += adaptSmoothing * (e - s.errors)
+	if !failed && slow > 0 {
+		s.slow += adaptSmoothing * (slow - s.slow)
+	}
+	s.acks++
+}
+
+// next returns the limit that follows from s: halved once a round of
+// limit fetches in a row struggling, one more once a round of them goes
+// fine, between lo and hi
+func (s *adaptState) next(limit, lo, hi int, maxErrors float64) int {
+	if s.acks < limit {
+		return limit
+	}
+	s.acks = 0
+	if s.errors > maxErrors || s.slow > 1 {
+		return max(lo, limit/2)
+	}
+	return min(hi, limit+1)
+}
+
+// adapt adapts the concurrency of the host h and of the crawl to the fetch
+// f, which is over
+func (r *run) adapt(h *hostState, f fetched) {
+	a := r.Adaptive
+	failed := f.err != nil && hostFailure(f.err)
+	if f.took > 0 && !failed && (h.concurrency.best == 0 || f.took < h.concurrency.best) {
+		h.concurrency.best = f.took
+	}
+	target := a.Latency
+	if target <= 0 {
+		target = 2 * h.concurrency.best
+	}
+	slow := 0.0
+	if f.took > 0 && target > 0 {
+		slow = float64(f.took) / float64(target)
+	}
+	maxErrors := a.MaxErrorRate
+	if maxErrors <= 0 {
+		maxErrors = DefaultMaxErrorRate
+	}
+	perHost := a.MaxPerHost
+	if perHost <= 0 {
+		perHost = DefaultMaxPerHost
+	}
+	h.concurrency.record(slow, failed)
+	// Not while the backoff has the host, it sets limit too
+	if !h.until.After(time.Now()) {
+		h.limit = h.concurrency.next(max(1, h.limit), 1, perHost, maxErrors)
+	}
+
+	lo, hi := a.MinWorkers, a.MaxWorkers
+	if lo <= 0 {
+		lo = 1
+	}
+	if hi <= 0 {
+		hi = 4 * r.workers()
+	}
+	// The crawl struggles when its hosts do, as one
+	r.concurrency.record(slow, failed)
+	r.limit = r.concurrency.next(r.limit, lo, max(lo, hi), maxErrors)
+}
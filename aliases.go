@@ -0,0 +1,142 @@
+package webcrawl
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// HostAliases keep a crawl from fetching the same pages under the aliases
+// of their host: www.example.com and example.com, http://example.com and
+// https://example.com. Each host is crawled under one name, the first the
+// crawl came across, the seed's for its own host, unless a redirect from
+// one name to the other says which the server prefers. And an http URL is
+// upgraded to https once the crawl has fetched a page of its host over
+// https, the host serving both.
+//
+// A URL visited under another name is not fetched again, but those queued
+// before the crawl learned of an alias are fetched under the name they
+// had, the redirects of the server taking care of most of them.
+// HostAliases are safe for concurrent use, and to be used by one
+// crawl at a time.
+type HostAliases struct {
+	// MergeWWW makes www.example.com and example.com one host, within
+	//   the scope of the seed whichever it is
+	MergeWWW bool
+
+	// UpgradeHTTPS makes the http URLs of a host https ones, when the
+	//   host is known to serve https. URLs with a port are left alone
+	UpgradeHTTPS bool
+
+	mu    sync.Mutex
+	hosts map[string]*hostAlias // host, without www. with MergeWWW => what the crawl knows of it
+}
+
+// hostAlias is what the crawl knows of a host and its aliases
+type hostAlias struct {
+	host   string // the name it is crawled under
+	secure bool   // it served a page over https
+}
+
+// key returns the key of host in a.hosts
+func (a *HostAliases) key(host string) string {
+	if a.MergeWWW {
+		return strings.TrimPrefix(host, "www.")
+	}
+	return host
+}
+
+// alias returns what a knows of host, adding it under that name if need
+// be. The caller holds a.mu
+func (a *HostAliases) alias(host string) *hostAlias {
+	if a.hosts == nil {
+		a.hosts = make(map[string]*hostAlias)
+	}
+	k := a.key(host)
+	h := a.hosts[k]
+	if h == nil {
+		h = &hostAlias{host: host}
+		a.hosts[k] = h
+	}
+	return h
+}
+
+// Canonical returns rawURL, a normalized URL, under the name its host is
+// crawled under, upgraded to https if need be.
+func (a *HostAliases) Canonical(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+		return rawURL
+	}
+	a.mu.Lock()
+	h := a.alias(u.Host)
+	host, secure := h.host, h.secure
+	a.mu.Unlock()
+	if a.MergeWWW {
+		u.Host = host
+	}
+	if a.UpgradeHTTPS && secure && u.Scheme == "http" && u.Port() == "" {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// learn records that the fetch of requested went fine, ending at final
+// after redirects, "" without any
+func (a *HostAliases) learn(requested, final string) {
+	if final == "" {
+		final = requested
+	}
+	from, err1 := url.Parse(requested)
+	to, err2 := url.Parse(final)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	h := a.alias(to.Host)
+	if to.Scheme == "https" {
+		h.secure = true
+	}
+	if a.MergeWWW && from.Host != to.Host && a.key(from.Host) == a.key(to.Host) {
+		// The server prefers this name
+		h.host = to.Host
+	}
+}
+
+// aliases returns the other names of rawURL, a canonical URL, under
+// which the crawl may have visited it already
+func (a *HostAliases) aliases(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	hosts := []string{u.Host}
+	if a.MergeWWW {
+		if bare, ok := strings.CutPrefix(u.Host, "www."); ok {
+			hosts = append(hosts, bare)
+		} else {
+			hosts = append(hosts, "www."+u.Host)
+		}
+	}
+	schemes := []string{u.Scheme}
+	if a.UpgradeHTTPS && u.Scheme == "https" && u.Port() == "" {
+		schemes = append(schemes, "http")
+	}
+	var names []string
+	for _, s := range schemes {
+		for _, h := range hosts {
+			if s != u.Scheme || h != u.Host {
+				v := *u
+				v.Scheme, v.Host = s, h
+				names = append(names, v.String())
+			}
+		}
+	}
+	return names
+}
+
+// sameHost reports whether u is on an alias of the host of seed
+func (a *HostAliases) sameHost(seed, u *url.URL) bool {
+	return a != nil && a.MergeWWW && a.key(u.Host) == a.key(seed.Host)
+}
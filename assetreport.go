@@ -0,0 +1,176 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// PageWeight is what a page weighs with the assets it is made of.
+type PageWeight struct {
+	URL  string
+	Size int64 // of the page itself, as it came over the wire
+
+	// Assets is how many assets the page has, those of its stylesheets
+	//   included, and Weight the size of the page plus those of its assets
+	//   that were downloaded. Unknown is how many of them have no size:
+	//   only checked, out of scope or not fetched at all
+	Assets  int
+	Weight  int64
+	Unknown int
+
+	// Missing are the assets of the page that could not be fetched,
+	//   sorted by URL, each with the pages using it
+	Missing []*BrokenLink
+}
+
+// AssetReport weighs the pages of a crawl with their assets, and finds the
+// assets that are missing. Run the Crawler with FetchAssets for it to
+// have the assets, with CheckAssets too for them to be only checked: the
+// missing ones are found all the same, but the weights are those of the
+// pages alone.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. An
+// AssetReport is safe for concurrent use, its zero value is ready to use.
+type AssetReport struct {
+	// Normalizer must be the Crawler's, for the assets of the pages to be
+	//   matched with the results of their fetch. When nil a zero
+	//   Normalizer is used
+	Normalizer *Normalizer
+
+	mu      sync.Mutex
+	fetches map[string]*assetFetch // normalized URL => its fetch
+	pages   []string               // the normalized URLs of the pages, in order
+}
+
+// assetFetch is what an AssetReport knows of a fetch
+type assetFetch struct {
+	url    string
+	size   int64 // -1 when it isn't known
+	err    error
+	assets []string // normalized
+}
+
+// Add records the result of a fetch, of a page or of an asset.
+func (r *AssetReport) Add(res CrawlResult) {
+	norm := r.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	normalize := func(u string) string {
+		if n, err := norm.Normalize(u); err == nil {
+			return n
+		}
+		return u
+	}
+	f := &assetFetch{url: res.URL, size: resultSize(res), err: res.Err}
+	for _, a := range res.Assets {
+		f.assets = append(f.assets, normalize(a))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fetches == nil {
+		r.fetches = make(map[string]*assetFetch)
+	}
+	u := normalize(res.URL)
+	r.fetches[u] = f
+	if !res.Asset && !res.External && res.Err == nil && htmlResult(res) {
+		r.pages = append(r.pages, u)
+	}
+}
+
+// resultSize returns the size of the body of res as it came over the
+// wire, -1 when it wasn't downloaded
+func resultSize(res CrawlResult) int64 {
+	switch {
+	case res.Err != nil || res.External || res.Skipped != "" || res.NotModified:
+		return -1
+	case res.WireSize > 0:
+		return res.WireSize
+	case res.BodySize > 0:
+		return res.BodySize
+	case res.Body == "" && res.Asset:
+		// Only checked
+		return -1
+	}
+	return int64(len(res.Body))
+}
+
+// Pages returns the weights of the HTML pages recorded so far, the
+// heaviest first.
+func (r *AssetReport) Pages() []PageWeight {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assets := make([][]string, len(r.pages)) // of each page, normalized
+	users := make(map[string][]string)       // normalized asset => the pages using it
+	for i, u := range r.pages {
+		page := r.fetches[u]
+		r.walk(page, make(map[string]bool), func(a string) {
+			assets[i] = append(assets[i], a)
+			users[a] = append(users[a], page.url)
+		})
+	}
+	weights := make([]PageWeight, len(r.pages))
+	for i, u := range r.pages {
+		page := r.fetches[u]
+		w := PageWeight{URL: page.url, Size: max(page.size, 0), Assets: len(assets[i])}
+		w.Weight = w.Size
+		for _, a := range assets[i] {
+			f := r.fetches[a]
+			switch {
+			case f != nil && f.err == nil && f.size >= 0:
+				w.Weight += f.size
+				continue
+			case f != nil && f.err != nil && broken(f.err):
+				refs := append([]string(nil), users[a]...)
+				sort.Strings(refs)
+				w.Missing = append(w.Missing, &BrokenLink{URL: f.url, Err: f.err, Referrers: refs})
+			}
+			w.Unknown++
+		}
+		sort.Slice(w.Missing, func(i, j int) bool { return w.Missing[i].URL < w.Missing[j].URL })
+		weights[i] = w
+	}
+	sort.SliceStable(weights, func(i, j int) bool { return weights[i].Weight > weights[j].Weight })
+	return weights
+}
+
+// walk calls visit with every asset of f, and of its assets, once
+func (r *AssetReport) walk(f *assetFetch, seen map[string]bool, visit func(asset string)) {
+	for _, a := range f.assets {
+		if seen[a] {
+			continue
+		}
+		seen[a] = true
+		visit(a)
+		if sub := r.fetches[a]; sub != nil {
+			r.walk(sub, seen, visit)
+		}
+	}
+}
+
+// WriteText writes the pages to w, the heaviest first, each with its
+// number of assets, its weight in bytes and how many assets have no size,
+// followed by its missing assets:
+//
+//	https://example.com/	12 assets	348211 bytes
+//		404	https://example.com/img/gone.png
+func (r *AssetReport) WriteText(w io.Writer) error {
+	for _, p := range r.Pages() {
+		line := fmt.Sprintf("%s\t%d assets\t%d bytes", p.URL, p.Assets, p.Weight)
+		if p.Unknown > 0 {
+			line += fmt.Sprintf("\t%d unknown", p.Unknown)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for _, b := range p.Missing {
+			if _, err := fmt.Fprintf(w, "\t%s\t%s\n", b.Reason(), b.URL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
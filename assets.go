@@ -0,0 +1,205 @@
+package webcrawl
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// assetAttrs maps the elements a page is made of to the attributes of
+// their URLs. A <link> only counts for the rel of assetRels
+var assetAttrs = map[string][]string{
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"link":   {"href"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"embed":  {"src"},
+	"object": {"data"},
+	"input":  {"src"},
+}
+
+// assetRels are the link types of the <link> elements that are assets
+var assetRels = []string{"stylesheet", "icon", "apple-touch-icon", "mask-icon", "preload", "modulepreload"}
+
+// cssRefs matches the url() and @import references of a stylesheet, the
+// URL being in the first group that matched
+var cssRefs = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^"')\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// ExtractAssets returns the URLs of the resources the HTML document doc,
+// found at base, is made of, in document order and without duplicates:
+// its images, including those of srcset, stylesheets, scripts, icons,
+// media and what the CSS of its <style> elements and style attributes
+// refers to. Nothing is fetched: the images of a stylesheet, for one, are
+// for StylesheetAssets to find. Only the http, https and file URLs are
+// kept, which drops data: URLs.
+func ExtractAssets(base *url.URL, doc *html.Node) []string {
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+	var assets []string
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		if u := assetURL(base, ref); u != "" && !seen[u] {
+			seen[u] = true
+			assets = append(assets, u)
+		}
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.DataAtom == atom.Style {
+				for _, ref := range cssURLs(nodeText(n)) {
+					add(ref)
+				}
+			}
+			if style, ok := attr(n, "style"); ok {
+				for _, ref := range cssURLs(style) {
+					add(ref)
+				}
+			}
+			if n.DataAtom != atom.Link || assetLink(n) {
+				for _, name := range assetAttrs[n.Data] {
+					v, ok := attr(n, name)
+					switch {
+					case !ok:
+					case name == "srcset":
+						for _, ref := range srcsetURLs(v) {
+							add(ref)
+						}
+					case n.DataAtom == atom.Input:
+						if typ, _ := attr(n, "type"); strings.EqualFold(typ, "image") {
+							add(v)
+						}
+					default:
+						add(v)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return assets
+}
+
+// StylesheetAssets returns the URLs the stylesheet css, found at base,
+// refers to with url() and @import, as ExtractAssets does.
+func StylesheetAssets(base *url.URL, css string) []string {
+	var assets []string
+	seen := make(map[string]bool)
+	for _, ref := range cssURLs(css) {
+		if u := assetURL(base, ref); u != "" && !seen[u] {
+			seen[u] = true
+			assets = append(assets, u)
+		}
+	}
+	return assets
+}
+
+// assetLink reports whether the <link> n is an asset of its page
+func assetLink(n *html.Node) bool {
+	for _, rel := range assetRels {
+		if hasRel(n, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// assetURL returns ref resolved against base without its fragment, or ""
+// when it is not a URL that can be fetched
+func assetURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file" {
+		return ""
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+// cssURLs returns the references of the CSS css, as they are written
+func cssURLs(css string) []string {
+	var refs []string
+	for _, m := range cssRefs.FindAllStringSubmatch(css, -1) {
+		for _, ref := range m[1:] {
+			if ref != "" {
+				refs = append(refs, ref)
+				break
+			}
+		}
+	}
+	return refs
+}
+
+// rewriteCSS returns css with each of its references replaced by what
+// replace returns for it
+func rewriteCSS(css string, replace func(ref string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range cssRefs.FindAllStringSubmatchIndex(css, -1) {
+		for i := 2; i < len(m); i += 2 {
+			if m[i] < m[i+1] {
+				sb.WriteString(css[last:m[i]])
+				sb.WriteString(replace(css[m[i]:m[i+1]]))
+				last = m[i+1]
+				break
+			}
+		}
+	}
+	sb.WriteString(css[last:])
+	return sb.String()
+}
+
+// srcsetURLs returns the URLs of the candidates of the srcset value v
+func srcsetURLs(v string) []string {
+	var refs []string
+	for _, c := range strings.Split(v, ",") {
+		if f := strings.Fields(c); len(f) > 0 {
+			refs = append(refs, f[0])
+		}
+	}
+	return refs
+}
+
+// mediaType returns the media type of the body of res, such as
+// "text/html", by its Content-Type or else the extension of its URL, ""
+// when neither tells
+func mediaType(res CrawlResult) string {
+	if ct := res.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			return mt
+		}
+		return ct
+	}
+	u, err := url.Parse(pageURL(res))
+	if err != nil {
+		return ""
+	}
+	mt, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(u.Path)))
+	return mt
+}
+
+// pageURL returns the URL the body of res was found at, where its
+// redirects led
+func pageURL(res CrawlResult) string {
+	if n := len(res.Redirects); n > 0 {
+		return res.Redirects[n-1].To
+	}
+	return res.URL
+}
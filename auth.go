@@ -0,0 +1,179 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+)
+
+// Authenticator gets an HTTPFetcher into the authenticated areas of a
+// site. It is called on to log in before the first request to each host,
+// and again when a page answers 401 Unauthorized or 403 Forbidden, after
+// which the request is tried once more.
+type Authenticator interface {
+	// Authenticate logs in to the host of url, with client when it
+	//   takes requests: the cookies a login form sets land in its Jar.
+	//   It is never called for the same host from two goroutines at
+	//   once. An error fails the fetch that needed it
+	Authenticate(ctx context.Context, client *http.Client, url string) error
+
+	// Authorize adds the credentials to a request before it is sent,
+	//   such as an Authorization header
+	Authorize(req *http.Request)
+}
+
+// BasicAuth is an Authenticator sending HTTP basic credentials with every
+// request.
+type BasicAuth struct {
+	User, Password string
+}
+
+func (a *BasicAuth) Authenticate(context.Context, *http.Client, string) error { return nil }
+
+func (a *BasicAuth) Authorize(req *http.Request) { req.SetBasicAuth(a.User, a.Password) }
+
+// BearerToken is an Authenticator sending an OAuth 2 bearer token with
+// every request.
+type BearerToken string
+
+func (t BearerToken) Authenticate(context.Context, *http.Client, string) error { return nil }
+
+func (t BearerToken) Authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+}
+
+// HostAuth is an Authenticator handing each request to the Authenticator
+// of its host, by hostname, so that credentials are only sent where they
+// belong. The hosts that have none get no credentials.
+type HostAuth map[string]Authenticator
+
+func (h HostAuth) Authenticate(ctx context.Context, client *http.Client, url string) error {
+	if a := h[hostname(url)]; a != nil {
+		return a.Authenticate(ctx, client, url)
+	}
+	return nil
+}
+
+func (h HostAuth) Authorize(req *http.Request) {
+	if a := h[req.URL.Hostname()]; a != nil {
+		a.Authorize(req)
+	}
+}
+
+// FormLogin is an Authenticator posting a login form, URL-encoded as a
+// browser would. The session cookie the site answers with is kept by the
+// Jar of the HTTPFetcher's client, which must have one. With Header, the
+// value of that response header is sent with every later request too,
+// for the sites handing out a token rather than a cookie.
+type FormLogin struct {
+	URL    string        // where the form posts to
+	Fields neturl.Values // the fields of the form, such as user and password
+
+	// Header is the response header holding a token to send back, under
+	//   the same name, empty for none
+	Header string
+
+	mu    sync.Mutex
+	token string
+}
+
+func (f *FormLogin) Authenticate(ctx context.Context, client *http.Client, _ string) error {
+	if client.Jar == nil && f.Header == "" {
+		return errors.New("webcrawl: FormLogin needs a client with a cookie Jar")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.URL, strings.NewReader(f.Fields.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login %s: %w", f.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login: %w", &StatusError{URL: f.URL, StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+	if f.Header != "" {
+		token := resp.Header.Get(f.Header)
+		if token == "" {
+			return fmt.Errorf("login %s: no %s header in the answer", f.URL, f.Header)
+		}
+		f.mu.Lock()
+		f.token = token
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+func (f *FormLogin) Authorize(req *http.Request) {
+	if f.Header == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" {
+		req.Header.Set(f.Header, f.token)
+	}
+}
+
+// hostLogin is where an HTTPFetcher is with the Authenticator of a host
+type hostLogin struct {
+	mu  sync.Mutex
+	gen int // how many times it logged in
+	err error
+}
+
+// authState is the state of HTTPFetcher.Auth, by host
+type authState struct {
+	mu    sync.Mutex
+	hosts map[string]*hostLogin
+}
+
+// login makes sure Auth logged in to the host of url, again when it did
+// at most gen times already; gen -1 only logs in the first time. It
+// returns the login count of the host to tell the next call
+func (f *HTTPFetcher) login(ctx context.Context, url string, gen int) (int, error) {
+	if f.Auth == nil {
+		return 0, nil
+	}
+	f.auth.mu.Lock()
+	if f.auth.hosts == nil {
+		f.auth.hosts = make(map[string]*hostLogin)
+	}
+	h := f.auth.hosts[hostname(url)]
+	if h == nil {
+		h = &hostLogin{}
+		f.auth.hosts[hostname(url)] = h
+	}
+	f.auth.mu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.gen > 0 && h.gen > gen {
+		return h.gen, h.err
+	}
+	client := http.DefaultClient
+	if f.Client != nil {
+		client = f.Client
+	}
+	err := f.Auth.Authenticate(ctx, client, url)
+	if err != nil && ctx.Err() != nil {
+		// Called off, that's no answer
+		return h.gen, err
+	}
+	h.err = err
+	h.gen++
+	return h.gen, h.err
+}
+
+// denied reports whether the server wants credentials, or other ones
+func denied(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
@@ -0,0 +1,265 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BackoffPolicy says how the Crawler backs off a host that answers 429 Too
+// Many Requests or 503 Service Unavailable. The URLs of the host are held
+// back for as long as the Retry-After header of the answer says, or when
+// it has none for Delay, doubled for every such answer in a row. The host
+// then gets half as many fetches at the same time as it had, one more for
+// every page it serves fine afterwards. The URL that was turned away is
+// tried again, up to MaxRetries times, before it counts as failed.
+type BackoffPolicy struct {
+	// MaxRetries is how many times a URL turned away is tried again, a
+	//   negative value turns the backoff off: the answer is a failure
+	//   like any other
+	MaxRetries int
+
+	// Delay is the pause of a host when the answer has no Retry-After,
+	//   MaxDelay caps every pause, zero meaning no cap
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultBackoffPolicy is the BackoffPolicy of a Crawler without one: a
+// URL is tried again up to three times, a host pauses a second at first
+// and at most five minutes.
+var DefaultBackoffPolicy = BackoffPolicy{
+	MaxRetries: 3,
+	Delay:      time.Second,
+	MaxDelay:   5 * time.Minute,
+}
+
+// RetryAfter returns how long the server that failed a fetch with err
+// asked to be left alone for, as its Retry-After header said, and whether
+// it asked at all: err is a 429 or a 503 StatusError.
+func RetryAfter(err error) (time.Duration, bool) {
+	var se *StatusError
+	if !errors.As(err, &se) || !throttling(se.StatusCode) {
+		return 0, false
+	}
+	return se.RetryAfter, true
+}
+
+// throttling reports whether an HTTP status asks the client to slow down
+func throttling(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter returns the delay a Retry-After header value asks for,
+// in seconds or as an HTTP date, zero when there is none
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0
+		}
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// hostState is how the dispatcher gets along with a host
+type hostState struct {
+	inFlight int // fetches under way
+	limit    int // the most fetches at the same time, zero for no limit
+	cap      int // the most of them ever, MaxInFlightPerHost, zero for none
+
+	until  time.Time // paused until then
+	streak int       // throttling answers in a row
+
+	held []FrontierItem // the URLs waiting for the host to be available
+
+	breaker breakerState
+	down    bool // given up on by the breaker
+
+	concurrency adaptState // see adaptive.go
+}
+
+// available reports whether a fetch from the host may start at now
+func (h *hostState) available(now time.Time) bool {
+	return !now.Before(h.until) && (h.limit == 0 || h.inFlight < h.limit) && (h.cap == 0 || h.inFlight < h.cap)
+}
+
+// backoff returns the BackoffPolicy of the crawl
+func (r *run) backoff() *BackoffPolicy {
+	if r.Backoff == nil {
+		return &DefaultBackoffPolicy
+	}
+	return r.Backoff
+}
+
+// host returns the state of the host of rawURL
+func (r *run) host(rawURL string) *hostState {
+	return r.hostNamed(hostname(rawURL))
+}
+
+// hostNamed returns the state of the host name
+func (r *run) hostNamed(name string) *hostState {
+	h := r.hosts[name]
+	if h == nil {
+		h = &hostState{cap: r.MaxInFlightPerHost}
+		if c, ok := r.HostMaxInFlight[name]; ok {
+			h.cap = c
+		}
+		if r.Adaptive != nil {
+			h.limit = 1
+		}
+		r.hosts[name] = h
+	}
+	return h
+}
+
+// retryThrottled reports whether it, which was turned away by its host,
+// is to be tried again, and counts the try. Workers call it
+func (r *run) retryThrottled(it FrontierItem, err error) bool {
+	p := r.backoff()
+	if _, ok := RetryAfter(err); !ok || p.MaxRetries < 0 {
+		return false
+	}
+	r.hostsMu.Lock()
+	defer r.hostsMu.Unlock()
+	if r.throttled[it.URL] >= p.MaxRetries {
+		return false
+	}
+	r.throttled[it.URL]++
+	return true
+}
+
+// hostDone updates the state of the host of a fetch that is over, cut
+// short or not: it is paused when it turned the fetch away, its circuit
+// breaker trips when it keeps failing
+func (r *run) hostDone(f fetched, cut bool, now time.Time) {
+	h := r.host(f.URL)
+	inFlight := h.inFlight
+	h.inFlight--
+	if cut {
+		return
+	}
+	if r.Adaptive != nil {
+		r.adapt(h, f)
+	}
+	if f.err == nil {
+		h.streak = 0
+		if h.limit > 0 && r.Adaptive == nil {
+			h.limit++
+			if h.limit >= r.limit {
+				h.limit = 0
+			}
+		}
+		r.breakerSucceeded(h)
+		return
+	}
+	p := r.backoff()
+	if wait, ok := RetryAfter(f.err); ok && p.MaxRetries >= 0 {
+		h.streak++
+		if wait <= 0 {
+			wait = p.Delay
+			for i := 1; i < h.streak && (p.MaxDelay <= 0 || wait < p.MaxDelay); i++ {
+				wait *= 2
+			}
+		}
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+		if until := now.Add(wait); until.After(h.until) {
+			h.until = until
+		}
+		h.limit = max(1, inFlight/2)
+		r.log.Warn("host backing off", "host", hostname(f.URL), "wait", h.until.Sub(now), "concurrency", h.limit)
+	}
+	// A URL to be tried again has not failed yet
+	if !f.retry && hostFailure(f.err) {
+		r.breakerFailed(h, f.URL, now)
+	}
+}
+
+// hold keeps it aside until its host is available, or its Schedule lets
+// it be crawled, reporting whether it had to
+func (r *run) hold(it FrontierItem, now time.Time) bool {
+	name := hostname(it.URL)
+	h := r.hostNamed(name)
+	r.schedule(name, h, now)
+	if h.available(now) {
+		return false
+	}
+	h.held = append(h.held, it)
+	r.held++
+	if r.Progress != nil {
+		// Still queued for its host
+		r.Progress.queue(it.URL, 1)
+	}
+	return true
+}
+
+// waitHost waits for the host of it to be available, and its Schedule
+// to let it be crawled, for a Sequential crawl, and reports whether it is, rather than ctx done
+func (r *run) waitHost(ctx context.Context, it FrontierItem) bool {
+	name := hostname(it.URL)
+	h := r.hostNamed(name)
+	r.schedule(name, h, time.Now())
+	d := time.Until(h.until)
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release puts the items held for the hosts available again back in the
+// frontier, all of them once the crawl is called off
+func (r *run) release(now time.Time, all bool) {
+	if r.held == 0 {
+		return
+	}
+	for _, h := range r.hosts {
+		if len(h.held) == 0 || !all && !h.available(now) {
+			continue
+		}
+		for _, it := range h.held {
+			if r.Progress != nil {
+				r.Progress.queue(it.URL, -1)
+			}
+			r.frontier.Push(it)
+		}
+		r.held -= len(h.held)
+		h.held = nil
+	}
+}
+
+// due returns when the first host holding items is done backing off, or
+// the first retry is due, zero if none is backing off nor retried
+func (r *run) due(now time.Time) time.Time {
+	var next time.Time
+	for _, d := range r.delayed {
+		if next.IsZero() || d.at.Before(next) {
+			next = d.at
+		}
+	}
+	if r.held == 0 {
+		return next
+	}
+	for _, h := range r.hosts {
+		if len(h.held) > 0 && h.until.After(now) && (next.IsZero() || h.until.Before(next)) {
+			next = h.until
+		}
+	}
+	return next
+}
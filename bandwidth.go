@@ -0,0 +1,147 @@
+package webcrawl
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// bandwidthChunk is the most a throttled read reads at once, for the
+// bytes to trickle in rather than come in bursts of a whole buffer
+const bandwidthChunk = 16 << 10
+
+// BandwidthLimiter caps the bytes per second the bodies of the responses
+// come in at, those of all the hosts together and those of each host, see
+// HTTPFetcher.Bandwidth. It throttles the reads of the bodies: a server
+// sends no faster than they are read, bar the buffers of the network in
+// between, which RateLimit alone, spacing out the requests, doesn't see
+// to. A second's worth of bytes may come at once after a pause.
+//
+// A BandwidthLimiter is safe for concurrent use, its zero value does not
+// limit anything.
+type BandwidthLimiter struct {
+	// BytesPerSecond caps the bytes of all the hosts together, zero or
+	//   less means no cap
+	BytesPerSecond int64
+
+	// HostBytesPerSecond caps the bytes of each host, and Hosts the
+	//   bytes of the hosts that get their own cap, by hostname, in place
+	//   of it
+	HostBytesPerSecond int64
+	Hosts              map[string]int64
+
+	mu    sync.Mutex
+	all   *byteBucket
+	hosts map[string]*byteBucket // hostname => its bucket
+}
+
+// byteBucket is a token bucket of bytes, a second's worth deep
+type byteBucket struct {
+	rate   float64 // bytes per second
+	tokens float64 // may go negative, that's the backlog of bytes read
+	last   time.Time
+}
+
+// take takes n bytes out of b at now, and returns how long to wait for
+// them to have been due
+func (b *byteBucket) take(n int, now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	} else {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// hostRate returns the cap of host, zero or less for none
+func (l *BandwidthLimiter) hostRate(host string) int64 {
+	if rate, ok := l.Hosts[host]; ok {
+		return rate
+	}
+	return l.HostBytesPerSecond
+}
+
+// Reader returns r, a body coming from host, throttled to the caps of l,
+// its reads failing with ctx.Err() once ctx is done. It returns r itself
+// when nothing caps host, or l is nil.
+func (l *BandwidthLimiter) Reader(ctx context.Context, host string, r io.Reader) io.Reader {
+	if l == nil || l.BytesPerSecond <= 0 && l.hostRate(host) <= 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, l: l, host: host, r: r}
+}
+
+// take takes n bytes read from host out of the buckets, and returns how
+// long to wait for the slowest of them
+func (l *BandwidthLimiter) take(host string, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	if l.BytesPerSecond > 0 {
+		if l.all == nil {
+			l.all = &byteBucket{rate: float64(l.BytesPerSecond)}
+		}
+		wait = l.all.take(n, now)
+	}
+	if rate := l.hostRate(host); rate > 0 {
+		b := l.hosts[host]
+		if b == nil {
+			if l.hosts == nil {
+				l.hosts = make(map[string]*byteBucket)
+			}
+			b = &byteBucket{rate: float64(rate)}
+			l.hosts[host] = b
+		}
+		wait = max(wait, b.take(n, now))
+	}
+	return wait
+}
+
+// chunk returns the most a read of a body from host reads at once: a
+// tenth of a second's worth of its slowest cap, in bandwidthChunk at most
+func (l *BandwidthLimiter) chunk(host string) int {
+	n := int64(bandwidthChunk)
+	for _, rate := range []int64{l.BytesPerSecond, l.hostRate(host)} {
+		if rate > 0 {
+			n = min(n, max(rate/10, 1))
+		}
+	}
+	return int(n)
+}
+
+// throttledReader is a body read through a BandwidthLimiter
+type throttledReader struct {
+	ctx  context.Context
+	l    *BandwidthLimiter
+	host string
+	r    io.Reader
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if n := t.l.chunk(t.host); len(p) > n {
+		p = p[:n]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if wait := t.l.take(t.host, n); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-t.ctx.Done():
+				return n, t.ctx.Err()
+			}
+		}
+	}
+	return n, err
+}
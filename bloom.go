@@ -0,0 +1,109 @@
+package webcrawl
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// DefaultFalsePositiveRate is the false positive rate of a BloomVisitedSet
+// when NewBloomVisitedSet is given none.
+const DefaultFalsePositiveRate = 0.001
+
+// BloomVisitedSet is a VisitedSet kept in a bloom filter, for crawls of
+// more URLs than a MemoryVisitedSet holds in memory: sized for 10 million
+// URLs at a false positive rate of 0.1%, it takes 17 MiB whatever their
+// length. The price is that, once in a while, a URL not seen is taken for
+// one that was, and not crawled; the more so over the number of URLs it
+// was sized for. It is safe for concurrent use.
+//
+// A Crawler whose Visited is a BloomVisitedSet keeps no map of the URLs
+// either, bar the frontier of those queued: it tells the pages fetched,
+// for the URLs redirecting to one to have it crawled once, by a second
+// filter the size of this one, 34 MiB in all then, at the same false
+// positive rate; it keeps the state of the URLs that failed alone, for
+// Retry and StateOf; and it keeps no depths, a page found by a shorter
+// path after it was queued being fetched at the depth it was queued at.
+// The first-in first-out frontiers, the default one included, hand the
+// pages out level by level, and find each at its least depth first
+// anyway. FollowCanonical, DedupContent, a Journal and the reports still
+// keep what they need of each page.
+type BloomVisitedSet struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64 // bits
+	k    int    // hashes
+	n    int
+}
+
+// NewBloomVisitedSet returns an empty BloomVisitedSet sized for n URLs at
+// the false positive rate p, DefaultFalsePositiveRate when p is not
+// between 0 and 1.
+func NewBloomVisitedSet(n int, p float64) *BloomVisitedSet {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = DefaultFalsePositiveRate
+	}
+	// The optimal sizes: m = -n ln p / (ln 2)², k = m/n ln 2
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max(64, (m+63)/64*64)
+	k := max(1, int(math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomVisitedSet{bits: make([]uint64, m/64), m: m, k: k}
+}
+
+// hashes returns the two hashes of url the k of the filter are made of,
+// as Kirsch and Mitzenmacher have it
+func hashes(url string) (h1, h2 uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(url))
+	var sum [16]byte
+	b := h.Sum(sum[:0])
+	return binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:]) | 1
+}
+
+// Seen implements VisitedSet.
+func (s *BloomVisitedSet) Seen(url string) bool {
+	h1, h2 := hashes(url)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := 0; i < s.k; i++ {
+		bit := (h1 + uint64(i)*h2) % s.m
+		if s.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MarkSeen implements VisitedSet.
+func (s *BloomVisitedSet) MarkSeen(url string) {
+	h1, h2 := hashes(url)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < s.k; i++ {
+		bit := (h1 + uint64(i)*h2) % s.m
+		s.bits[bit/64] |= 1 << (bit % 64)
+	}
+	s.n++
+}
+
+// Len returns how many times MarkSeen was called, the URLs marked twice
+// counting twice.
+func (s *BloomVisitedSet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.n
+}
+
+// sibling returns an empty BloomVisitedSet of the size of s
+func (s *BloomVisitedSet) sibling() *BloomVisitedSet {
+	return &BloomVisitedSet{bits: make([]uint64, len(s.bits)), m: s.m, k: s.k}
+}
+
+// Size returns the size of the filter in bytes.
+func (s *BloomVisitedSet) Size() int {
+	return len(s.bits) * 8
+}
@@ -0,0 +1,96 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	const n = 100000
+	for _, p := range []float64{0.01, 0.001} {
+		s := NewBloomVisitedSet(n, p)
+		for i := range n {
+			s.MarkSeen("http://site.test/seen/" + strconv.Itoa(i))
+		}
+		for i := range n {
+			if u := "http://site.test/seen/" + strconv.Itoa(i); !s.Seen(u) {
+				t.Fatalf("p=%v: %s marked, not seen", p, u)
+			}
+		}
+		var fp int
+		const tries = 4 * n
+		for i := range tries {
+			if s.Seen("http://site.test/other/" + strconv.Itoa(i)) {
+				fp++
+			}
+		}
+		// The rate is p for n URLs marked, give or take the rounding of
+		//   the sizes and the luck of the draw
+		if rate := float64(fp) / tries; rate > 1.25*p || rate < p/4 {
+			t.Errorf("sized for %d URLs at %v: %d false positives in %d, a rate of %.5f", n, p, fp, tries, rate)
+		}
+	}
+}
+
+// treeFetcher serves pages numbered 0 to n-1, i linking to 2i+1, 2i+2
+// and back to i/2, the leaves divisible by 10 failing
+type treeFetcher struct {
+	n       int
+	mu      sync.Mutex
+	fetches map[string]int
+}
+
+func (f *treeFetcher) Fetch(_ context.Context, url string) (string, []string, error) {
+	f.mu.Lock()
+	f.fetches[url]++
+	f.mu.Unlock()
+	i, _ := strconv.Atoi(strings.TrimPrefix(url, "http://site.test/"))
+	if i >= f.n/2 && i%10 == 0 {
+		return "", nil, errors.New("failed")
+	}
+	var links []string
+	for _, l := range []int{2*i + 1, 2*i + 2, i / 2} {
+		if l < f.n {
+			links = append(links, "http://site.test/"+strconv.Itoa(l))
+		}
+	}
+	return "page " + strconv.Itoa(i), links, nil
+}
+
+func TestBloomCompactRun(t *testing.T) {
+	const pages = 2000
+	f := &treeFetcher{n: pages, fetches: make(map[string]int)}
+	c := NewCrawler(WithFetcher(f), WithoutRobots(), WithDepth(UnlimitedDepth))
+	c.Visited = NewBloomVisitedSet(10*pages, 0.001)
+	norm := &Normalizer{}
+	seed, _ := norm.Normalize("http://site.test/0")
+	r := c.newRun(context.Background(), norm, seed)
+	r.admit(FrontierItem{URL: seed})
+	var report *ErrorReport
+	if err := r.loop(context.Background()); !errors.As(err, &report) {
+		t.Fatalf("crawl: %v, want the failures reported", err)
+	}
+	if len(f.fetches) != pages {
+		t.Errorf("%d pages fetched, want %d", len(f.fetches), pages)
+	}
+	for u, n := range f.fetches {
+		if n != 1 {
+			t.Errorf("%s fetched %d times", u, n)
+		}
+	}
+	if len(r.depths) != 0 || len(r.pages) != 0 {
+		t.Errorf("%d depths and %d pages kept", len(r.depths), len(r.pages))
+	}
+	if len(r.states) != len(report.Errors) {
+		t.Errorf("%d states kept for %d URLs failed", len(r.states), len(report.Errors))
+	}
+	for u, st := range r.states {
+		if st.state != URLFailed {
+			t.Errorf("%s kept at state %v", u, st.state)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package webcrawl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// body returns the reader of the body of resp, the response to url,
+// throttled to Bandwidth, decompressed, cut at MaxBodyBytes, transcoded to
+// UTF-8 and handed to OnBody as it is read. done is to be called once the fetch is through
+// with it, with the error it ran into if any: it reads what is left, sets
+// the body, its sizes and whether it was cut in out, and returns the
+// first error
+func (f *HTTPFetcher) body(url string, resp *http.Response, out *Response) (_ io.Reader, done func(error) error) {
+	wire := &countingReader{r: resp.Body}
+	if f.Bandwidth != nil {
+		ctx, host := context.Background(), hostname(url)
+		if resp.Request != nil {
+			// Of the last hop, and the deadline of the fetch
+			ctx, host = resp.Request.Context(), resp.Request.URL.Hostname()
+		}
+		wire.r = f.Bandwidth.Reader(ctx, host, resp.Body)
+	}
+	rc := decompress(wire, resp.Header.Get("Content-Encoding"))
+	r := &countingReader{r: rc}
+	if f.MaxBodyBytes > 0 {
+		r.r = io.LimitReader(rc, f.MaxBodyBytes)
+	}
+	text, cs := toUTF8(r, resp.Header.Get("Content-Type"))
+	out.Charset = cs
+	sizes := func() {
+		out.BodySize, out.Truncated = r.n, f.cut(rc, r.n)
+		out.WireSize = wire.n
+	}
+	var buf strings.Builder
+	if f.OnBody == nil {
+		body := io.TeeReader(text, &buf)
+		return body, func(err error) error {
+			if err == nil {
+				_, err = io.Copy(io.Discard, body)
+			}
+			out.Body = buf.String()
+			sizes()
+			return err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	streamed := make(chan error, 1)
+	go func() {
+		err := f.OnBody(url, pr)
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			// What OnBody left unread, for the download to go on
+			_, err = io.Copy(io.Discard, pr)
+		}
+		streamed <- err
+	}()
+	body := io.TeeReader(text, pw)
+	return body, func(err error) error {
+		if err == nil {
+			_, err = io.Copy(io.Discard, body)
+		}
+		pw.CloseWithError(err)
+		if serr := <-streamed; serr != nil && serr != err {
+			// Rather the error of OnBody than the one it caused
+			err = serr
+		}
+		sizes()
+		return err
+	}
+}
+
+// cut reports whether the body rc, of which n bytes were read, goes on
+// past MaxBodyBytes
+func (f *HTTPFetcher) cut(rc io.Reader, n int64) bool {
+	if f.MaxBodyBytes <= 0 || n < f.MaxBodyBytes {
+		return false
+	}
+	var b [1]byte
+	m, _ := io.ReadFull(rc, b[:])
+	return m > 0
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
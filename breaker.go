@@ -0,0 +1,115 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// CircuitBreaker says when the Crawler stops fetching from a host that
+// keeps failing, so that a dead host doesn't tie up the workers, or the
+// retries of a RetryFetcher, while the other hosts have pages to serve.
+//
+// After Failures fetches from a host failed in a row, because it couldn't
+// be reached or answered with a 5xx status, the breaker trips: the URLs of
+// the host are held back for Cooldown. Then a single URL is fetched to
+// probe the host, when it succeeds the host is back to normal, when it
+// fails the breaker trips again, for twice as long. Once it tripped
+// MaxTrips times in a row the host is given up, its URLs fail with
+// ErrHostDown without being fetched.
+type CircuitBreaker struct {
+	// Failures is how many fetches in a row fail before the breaker trips
+	Failures int
+
+	// Cooldown is how long the URLs of the host are held back the first
+	//   time the breaker trips
+	Cooldown time.Duration
+
+	// MaxTrips is how many times in a row the breaker trips before the
+	//   host is given up, zero meaning it never is
+	MaxTrips int
+}
+
+// DefaultCircuitBreaker trips after 5 failures in a row, holds the host
+// back for 30 seconds, then a minute, and gives up on it after the third
+// time.
+var DefaultCircuitBreaker = CircuitBreaker{
+	Failures: 5,
+	Cooldown: 30 * time.Second,
+	MaxTrips: 3,
+}
+
+// breakerState is where the circuit breaker of a host is at
+type breakerState struct {
+	failures int  // fetches failed in a row
+	trips    int  // times tripped in a row
+	tripped  bool // until the next fetch succeeds
+}
+
+// hostFailure reports whether a fetch failing with err tells the host is
+// in trouble, rather than the page
+func hostFailure(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 && se.StatusCode != 501
+	}
+	var dns *net.DNSError
+	if errors.As(err, &dns) {
+		return true
+	}
+	return !errors.Is(err, context.Canceled) && Retryable(err)
+}
+
+// breakerSucceeded closes the breaker of h after a fetch from the host
+// went fine
+func (r *run) breakerSucceeded(h *hostState) {
+	h.breaker = breakerState{}
+}
+
+// breakerFailed counts a failure of the host of url against its breaker,
+// tripping it when it is one too many
+func (r *run) breakerFailed(h *hostState, url string, now time.Time) {
+	b := r.Breaker
+	if b == nil || h.down || now.Before(h.until) && h.breaker.tripped {
+		// No breaker, or a fetch that was under way when it tripped
+		return
+	}
+	h.breaker.failures++
+	if !h.breaker.tripped && h.breaker.failures < b.Failures {
+		return
+	}
+	host := hostname(url)
+	h.breaker.trips++
+	if b.MaxTrips > 0 && h.breaker.trips >= b.MaxTrips {
+		h.down, h.until, h.limit = true, time.Time{}, 0
+		r.hostsMu.Lock()
+		r.down[host] = true
+		r.hostsMu.Unlock()
+		r.log.Warn("host down", "host", host, "trips", h.breaker.trips)
+		return
+	}
+	cooldown := b.Cooldown
+	for i := 1; i < h.breaker.trips; i++ {
+		cooldown *= 2
+	}
+	h.breaker.tripped, h.breaker.failures = true, 0
+	if until := now.Add(cooldown); until.After(h.until) {
+		h.until = until
+	}
+	// One probe once the cooldown is over
+	h.limit = 1
+	r.log.Warn("circuit breaker tripped", "host", host, "cooldown", cooldown, "trips", h.breaker.trips)
+}
+
+// hostDown returns the error of a URL whose host the circuit breaker gave
+// up on, nil if it didn't. Workers call it
+func (r *run) hostDown(url string) error {
+	r.hostsMu.Lock()
+	defer r.hostsMu.Unlock()
+	if !r.down[hostname(url)] {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrHostDown, url)
+}
@@ -0,0 +1,145 @@
+package webcrawl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// BrokenLink is a link target that could not be fetched.
+type BrokenLink struct {
+	URL       string
+	Err       error    // what fetching it returned
+	Referrers []string // the pages linking to it, sorted
+}
+
+// Reason says briefly why the link is broken: the HTTP status code, or
+// the cause the error is classified under, such as "timed out".
+func (b *BrokenLink) Reason() string {
+	var se *StatusError
+	if errors.As(b.Err, &se) {
+		return strconv.Itoa(se.StatusCode)
+	}
+	return Classify(b.Err).Error()
+}
+
+// BrokenLinkReport finds the broken links of a crawl: the targets that
+// answered with an error status, timed out or could not be reached at
+// all. URLs robots.txt kept the crawler from are not broken. Run the
+// Crawler with CheckExternal for the links leaving the site to be checked
+// too.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. A
+// BrokenLinkReport is safe for concurrent use, its zero value is ready to
+// use.
+type BrokenLinkReport struct {
+	// Normalizer must be the Crawler's, for links to be matched with the
+	//   results of their fetch. When nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	mu        sync.Mutex
+	referrers map[string][]string // normalized target => pages linking to it
+	failed    map[string]error    // normalized URL => why its fetch failed
+	urls      map[string]string   // normalized URL => as it came in a result
+}
+
+// Add records the result of a fetch: whether it failed and which pages
+// the page links to, its assets counting as links.
+func (r *BrokenLinkReport) Add(res CrawlResult) {
+	norm := r.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.referrers == nil {
+		r.referrers = make(map[string][]string)
+		r.failed = make(map[string]error)
+		r.urls = make(map[string]string)
+	}
+	u := res.URL
+	if n, err := norm.Normalize(u); err == nil {
+		u = n
+	}
+	r.urls[u] = res.URL
+	if res.Err != nil && broken(res.Err) {
+		r.failed[u] = res.Err
+	}
+	for _, l := range append(res.Links[:len(res.Links):len(res.Links)], res.Assets...) {
+		if n, err := norm.Normalize(l); err == nil {
+			r.referrers[n] = append(r.referrers[n], res.URL)
+		}
+	}
+}
+
+// broken reports whether a fetch failing with err makes for a broken link
+func broken(err error) bool {
+	switch Classify(err) {
+	case ErrRobotsBlocked, ErrTooDeep:
+		return false
+	}
+	return true
+}
+
+// Broken returns the broken links recorded so far, sorted by URL. The
+// seed, which no page links to, is only there if it failed.
+func (r *BrokenLinkReport) Broken() []*BrokenLink {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var links []*BrokenLink
+	for u, err := range r.failed {
+		refs := append([]string(nil), r.referrers[u]...)
+		sort.Strings(refs)
+		links = append(links, &BrokenLink{URL: r.urls[u], Err: err, Referrers: refs})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+	return links
+}
+
+// WriteText writes the broken links to w grouped by the pages they are
+// on, each page followed by the links it has that are broken:
+//
+//	https://example.com/
+//		404	https://example.com/gone
+//		timed out	https://slow.example.org/
+//
+// A broken seed comes first, on its own.
+func (r *BrokenLinkReport) WriteText(w io.Writer) error {
+	byPage := make(map[string][]*BrokenLink)
+	var pages []string
+	for _, b := range r.Broken() {
+		refs := b.Referrers
+		if len(refs) == 0 {
+			refs = []string{""}
+		}
+		for _, p := range refs {
+			if byPage[p] == nil {
+				pages = append(pages, p)
+			}
+			byPage[p] = append(byPage[p], b)
+		}
+	}
+	sort.Strings(pages)
+	for _, p := range pages {
+		if p == "" {
+			for _, b := range byPage[p] {
+				if _, err := fmt.Fprintf(w, "%s\t%s\n", b.Reason(), b.URL); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, err := fmt.Fprintln(w, p); err != nil {
+			return err
+		}
+		for _, b := range byPage[p] {
+			if _, err := fmt.Fprintf(w, "\t%s\t%s\n", b.Reason(), b.URL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
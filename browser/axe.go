@@ -0,0 +1,59 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// axeRun runs axe-core on the page and boils its violations down to the
+// rule and the selectors of the elements in breach
+const axeRun = `axe.run(document).then(r => r.violations.map(v => ({
+	id: v.id,
+	impact: v.impact || "",
+	nodes: v.nodes.map(n => [].concat(n.target).join(" "))
+})))`
+
+// axeViolation is a violation as axeRun returns it
+type axeViolation struct {
+	ID     string   `json:"id"`
+	Impact string   `json:"impact"`
+	Nodes  []string `json:"nodes"`
+}
+
+// audit runs axe-core on the page of url rendered in tab, and returns its
+// violations as axeIssues does
+func (f *Fetcher) audit(tab context.Context, url string) ([]webcrawl.AccessibilityIssue, error) {
+	var violations []axeViolation
+	await := func(p *runtime.EvaluateParams) *runtime.EvaluateParams { return p.WithAwaitPromise(true) }
+	err := chromedp.Run(tab,
+		chromedp.Evaluate(f.Axe, nil),
+		chromedp.Evaluate(axeRun, &violations, await),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("axe-core: %w", err)
+	}
+	return axeIssues(url, violations), nil
+}
+
+// axeIssues returns the violations of the page of url, one issue per
+// element. Their kind is the axe rule, such as image-alt, with its
+// impact: "image-alt (critical)"
+func axeIssues(url string, violations []axeViolation) []webcrawl.AccessibilityIssue {
+	issues := []webcrawl.AccessibilityIssue{}
+	for _, v := range violations {
+		kind := v.ID
+		if v.Impact != "" {
+			kind += " (" + v.Impact + ")"
+		}
+		for _, n := range v.Nodes {
+			issues = append(issues, webcrawl.AccessibilityIssue{URL: url, Kind: kind, Detail: strings.TrimSpace(n)})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,62 @@
+package browser
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestAxeIssues(t *testing.T) {
+	const url = "https://site.test/"
+	got := axeIssues(url, []axeViolation{
+		{ID: "image-alt", Impact: "critical", Nodes: []string{"img.logo", " #hero > img "}},
+		{ID: "region", Nodes: []string{"body > div"}},
+		{ID: "list", Impact: "serious"},
+	})
+	want := []webcrawl.AccessibilityIssue{
+		{URL: url, Kind: "image-alt (critical)", Detail: "img.logo"},
+		{URL: url, Kind: "image-alt (critical)", Detail: "#hero > img"},
+		{URL: url, Kind: "region", Detail: "body > div"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("axeIssues = %+v, want %+v", got, want)
+	}
+	// Audited, a page without violations has none rather than nil
+	if got := axeIssues(url, nil); got == nil || len(got) != 0 {
+		t.Errorf("axeIssues of no violations = %#v, want an empty slice", got)
+	}
+}
+
+// stubAxe stands for axe-core, finding an image without alt text on every
+// page, in the shape axe.run resolves to
+const stubAxe = `window.axe = {run: () => Promise.resolve({violations: [
+	{id: "image-alt", impact: "critical", nodes: [{target: ["img"]}, {target: ["#app", "img.logo"]}]},
+	{id: "region", impact: null, nodes: [{target: ["body > div"]}]}
+]})};`
+
+func TestAudit(t *testing.T) {
+	f := startTest(t)
+	srv := app()
+	defer srv.Close()
+	f.Axe = stubAxe
+
+	resp, err := f.FetchResponse(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []webcrawl.AccessibilityIssue{
+		{URL: resp.URL, Kind: "image-alt (critical)", Detail: "img"},
+		{URL: resp.URL, Kind: "image-alt (critical)", Detail: "#app img.logo"},
+		{URL: resp.URL, Kind: "region", Detail: "body > div"},
+	}
+	if !reflect.DeepEqual(resp.Accessibility, want) {
+		t.Errorf("Accessibility = %+v, want %+v", resp.Accessibility, want)
+	}
+
+	f.Axe = "throw new Error('no axe')"
+	if _, err := f.FetchResponse(context.Background(), srv.URL+"/"); err == nil {
+		t.Error("FetchResponse with a broken axe-core: no error")
+	}
+}
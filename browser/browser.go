@@ -0,0 +1,275 @@
+// Package browser provides a webcrawl Fetcher that renders pages in
+// headless Chrome, for the sites that build their links with JavaScript.
+//
+// It is a package of its own so that the crawler doesn't depend on
+// chromedp unless it is needed. Chrome, or Chromium, must be installed:
+//
+//	f := browser.New()
+//	defer f.Close()
+//	f.Pages = []*regexp.Regexp{webcrawl.Glob("/app/**")}
+//	f.Fallback = webcrawl.NewHTTPFetcher(webcrawl.DefaultTimeout)
+//	c := webcrawl.NewCrawler(webcrawl.WithFetcher(f))
+package browser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// DefaultIdleWait is how long a Fetcher waits for the network to be idle
+// when its IdleWait is not set.
+const DefaultIdleWait = 5 * time.Second
+
+// Fetcher loads every page in a tab of a headless Chrome it starts on the
+// first fetch, waits for it to be rendered, and returns the rendered DOM
+// as the body along with its links. Close it once the crawl is over. A
+// Fetcher is safe for concurrent use, each fetch having a tab of its own.
+type Fetcher struct {
+	// Pages are the URL paths rendered in the browser, see
+	//   webcrawl.Glob, every page when empty. The others go to Fallback
+	Pages    []*regexp.Regexp
+	Fallback webcrawl.Fetcher
+
+	// WaitFor is a CSS selector the page is rendered once it matches,
+	//   when empty the page is rendered once the network is idle
+	WaitFor string
+
+	// IdleWait caps the wait for the network to be idle, DefaultIdleWait
+	//   when zero. A page still busy after that is taken as it is then
+	IdleWait time.Duration
+
+	// Timeout is the per-page timeout, webcrawl.DefaultTimeout when zero
+	//   and none when negative
+	Timeout time.Duration
+
+	// Links finds the links of the rendered pages, when nil the <a href>
+	//   links are
+	Links *webcrawl.LinkExtractor
+
+	// Screenshots, when set, has a full-page screenshot taken of every
+	//   page rendered, see Response.Screenshot
+	Screenshots *Screenshots
+
+	// Axe is the source of axe-core, axe.min.js, to run on every page
+	//   rendered for its accessibility issues, the Response.Accessibility
+	//   of the page. It is not bundled with this package, get it from
+	//   https://github.com/dequelabs/axe-core
+	Axe string
+
+	opts    []chromedp.ExecAllocatorOption
+	mu      sync.Mutex
+	browser context.Context // nil until the first fetch
+	cancel  context.CancelFunc
+}
+
+// anchorExtractor is what Fetcher uses when it has no Links
+var anchorExtractor = &webcrawl.LinkExtractor{Tags: webcrawl.AnchorTags}
+
+// New returns a Fetcher starting Chrome with opts, or with
+// chromedp.DefaultExecAllocatorOptions when there are none.
+func New(opts ...chromedp.ExecAllocatorOption) *Fetcher {
+	if len(opts) == 0 {
+		opts = chromedp.DefaultExecAllocatorOptions[:]
+	}
+	return &Fetcher{opts: opts}
+}
+
+// Fetch implements webcrawl.Fetcher.
+func (f *Fetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements webcrawl.ResponseFetcher. An error status
+// fails with a *webcrawl.StatusError, as over plain HTTP.
+func (f *Fetcher) FetchResponse(ctx context.Context, url string) (*webcrawl.Response, error) {
+	if !f.renders(url) {
+		return webcrawl.FetchResponse(ctx, f.Fallback, url)
+	}
+	browser, err := f.start()
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+
+	tab, cancel := chromedp.NewContext(browser)
+	defer cancel()
+	// The tab hangs off the browser, it still has to go when ctx does
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = webcrawl.DefaultTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		tab, cancel = context.WithTimeout(tab, timeout)
+		defer cancel()
+	}
+
+	// The tab's target only exists once it ran something
+	if err := chromedp.Run(tab, page.SetLifecycleEventsEnabled(true)); err != nil {
+		return nil, f.fail(ctx, url, err)
+	}
+	idle := make(chan struct{})
+	var once sync.Once
+	frame := cdp.FrameID(chromedp.FromContext(tab).Target.TargetID)
+	chromedp.ListenTarget(tab, func(ev interface{}) {
+		if e, ok := ev.(*page.EventLifecycleEvent); ok && e.FrameID == frame && e.Name == "networkIdle" {
+			once.Do(func() { close(idle) })
+		}
+	})
+
+	resp, err := chromedp.RunResponse(tab, chromedp.Navigate(url))
+	if err != nil {
+		return nil, f.fail(ctx, url, err)
+	}
+	out := &webcrawl.Response{URL: url, StatusCode: int(resp.Status)}
+	if resp.URL != "" {
+		out.URL = resp.URL
+	}
+	if out.StatusCode < 200 || out.StatusCode > 299 {
+		return out, &webcrawl.StatusError{URL: url, StatusCode: out.StatusCode, Status: fmt.Sprintf("%d %s", resp.Status, http.StatusText(out.StatusCode))}
+	}
+
+	if f.WaitFor != "" {
+		if err := chromedp.Run(tab, chromedp.WaitReady(f.WaitFor, chromedp.ByQuery)); err != nil {
+			return out, f.fail(ctx, url, err)
+		}
+	} else {
+		wait := f.IdleWait
+		if wait <= 0 {
+			wait = DefaultIdleWait
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-idle:
+		case <-t.C:
+		case <-tab.Done():
+		}
+		t.Stop()
+	}
+	var body string
+	if err := chromedp.Run(tab, chromedp.OuterHTML("html", &body, chromedp.ByQuery)); err != nil {
+		return out, f.fail(ctx, url, err)
+	}
+
+	base, err := neturl.Parse(out.URL)
+	if err != nil {
+		return out, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	links := f.Links
+	if links == nil {
+		links = anchorExtractor
+	}
+	urls, err := links.URLs(base, strings.NewReader(body))
+	if err != nil {
+		return out, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	out.Body, out.Links = body, urls
+	if f.Axe != "" {
+		if out.Accessibility, err = f.audit(tab, out.URL); err != nil {
+			return out, f.fail(ctx, url, err)
+		}
+	}
+	if f.Screenshots != nil {
+		if out.Screenshot, err = f.Screenshots.take(tab, url); err != nil {
+			return out, f.fail(ctx, url, err)
+		}
+	}
+	return out, nil
+}
+
+// Check implements webcrawl.Checker with Fallback, there is no cheaper way
+// for a browser to tell than to load the page.
+func (f *Fetcher) Check(ctx context.Context, url string) error {
+	if f.Fallback != nil {
+		return webcrawl.Check(ctx, f.Fallback, url)
+	}
+	_, _, err := f.Fetch(ctx, url)
+	return err
+}
+
+// renders reports whether url is one of Pages
+func (f *Fetcher) renders(url string) bool {
+	if len(f.Pages) == 0 || f.Fallback == nil {
+		return true
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, re := range f.Pages {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// fail returns the error of a fetch that failed with err, ctx.Err() when
+// it is why
+func (f *Fetcher) fail(ctx context.Context, url string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("fetch %s: %w", url, err)
+}
+
+// start starts the browser unless it runs already, and returns its
+// context
+func (f *Fetcher) start() (context.Context, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.browser != nil {
+		return f.browser, nil
+	}
+	opts := f.opts
+	if len(opts) == 0 {
+		opts = chromedp.DefaultExecAllocatorOptions[:]
+	}
+	alloc, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	browser, cancel := chromedp.NewContext(alloc)
+	if err := chromedp.Run(browser); err != nil {
+		cancel()
+		cancelAlloc()
+		return nil, err
+	}
+	f.browser = browser
+	f.cancel = func() {
+		cancel()
+		cancelAlloc()
+	}
+	return browser, nil
+}
+
+// Close shuts the browser down, a later fetch starts it again.
+func (f *Fetcher) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.browser == nil {
+		return nil
+	}
+	err := chromedp.Cancel(f.browser)
+	f.cancel()
+	f.browser, f.cancel = nil, nil
+	return err
+}
@@ -0,0 +1,147 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// fallback is a Fetcher of every URL, telling which one it fetched
+type fallback struct {
+	fetched []string
+}
+
+func (f *fallback) Fetch(ctx context.Context, url string) (string, []string, error) {
+	f.fetched = append(f.fetched, url)
+	if strings.HasSuffix(url, "/missing") {
+		return "", nil, &webcrawl.StatusError{URL: url, StatusCode: 404, Status: "404 Not Found"}
+	}
+	return "plain " + url, []string{url + "/next"}, nil
+}
+
+func TestRenders(t *testing.T) {
+	f := New()
+	if !f.renders("https://site.test/blog/") {
+		t.Error("a page not rendered with no Pages")
+	}
+	f.Pages = []*regexp.Regexp{webcrawl.Glob("/app/**")}
+	// Without a Fallback, every page has to be
+	if !f.renders("https://site.test/blog/") {
+		t.Error("a page not rendered with no Fallback")
+	}
+	f.Fallback = &fallback{}
+	for url, want := range map[string]bool{
+		"https://site.test/app/cart": true,
+		"https://site.test/app/":     true,
+		"https://site.test/blog/":    false,
+		"https://site.test":          false,
+		"://bad":                     true,
+	} {
+		if got := f.renders(url); got != want {
+			t.Errorf("renders(%s) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	fb := &fallback{}
+	f := New()
+	f.Pages, f.Fallback = []*regexp.Regexp{webcrawl.Glob("/app/**")}, fb
+	defer f.Close()
+
+	body, links, err := f.Fetch(context.Background(), "https://site.test/blog/")
+	if err != nil || body != "plain https://site.test/blog/" || !reflect.DeepEqual(links, []string{"https://site.test/blog//next"}) {
+		t.Errorf("Fetch = %q, %q, %v, want the Fallback's", body, links, err)
+	}
+	var se *webcrawl.StatusError
+	if err := f.Check(context.Background(), "https://site.test/app/missing"); !errors.As(err, &se) || se.StatusCode != 404 {
+		t.Errorf("Check = %v, want the 404 of the Fallback", err)
+	}
+	if want := []string{"https://site.test/blog/", "https://site.test/app/missing"}; !reflect.DeepEqual(fb.fetched, want) {
+		t.Errorf("Fallback fetched %q, want %q", fb.fetched, want)
+	}
+	if f.browser != nil {
+		t.Error("Chrome started for the Fallback's pages")
+	}
+}
+
+// startTest returns a Fetcher with Chrome started, skipping the test when
+// there is no Chrome to start
+func startTest(t *testing.T) *Fetcher {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("starts Chrome")
+	}
+	f := New()
+	if _, err := f.start(); err != nil {
+		t.Skip("no Chrome:", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// app serves a page whose links are built by JavaScript, one that takes a
+// second to, and a 404
+func app() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><div id="app"></div><script>
+document.getElementById("app").innerHTML = '<a href="/rendered">Rendered</a>';
+</script></body></html>`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><script>
+setTimeout(() => document.body.innerHTML += '<p id="late"><a href="/late">Late</a></p>', 1000);
+</script></body></html>`)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	return httptest.NewServer(mux)
+}
+
+func TestFetch(t *testing.T) {
+	f := startTest(t)
+	srv := app()
+	defer srv.Close()
+
+	resp, err := f.FetchResponse(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(resp.Body, "Rendered</a>") {
+		t.Errorf("FetchResponse = %d, %s, want the rendered DOM", resp.StatusCode, resp.Body)
+	}
+	if want := []string{srv.URL + "/rendered"}; !reflect.DeepEqual(resp.Links, want) {
+		t.Errorf("links %q, want %q", resp.Links, want)
+	}
+
+	f.WaitFor = "#late"
+	if _, links, err := f.Fetch(context.Background(), srv.URL+"/slow"); err != nil || len(links) != 1 {
+		t.Errorf("Fetch of /slow with WaitFor = %q, %v, want the link added late", links, err)
+	}
+	f.WaitFor = ""
+
+	var se *webcrawl.StatusError
+	if _, err := f.FetchResponse(context.Background(), srv.URL+"/missing"); !errors.As(err, &se) || se.StatusCode != 404 {
+		t.Errorf("FetchResponse of /missing: %v, want a 404 StatusError", err)
+	}
+
+	f.Timeout = 200 * time.Millisecond
+	f.WaitFor = "#never"
+	if _, _, err := f.Fetch(context.Background(), srv.URL+"/"); err == nil {
+		t.Error("Fetch waiting for #never: no error")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := f.Fetch(ctx, srv.URL+"/"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch with ctx done: %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,77 @@
+package browser
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultScreenshotQuality is the quality of the WebP screenshots when
+// Screenshots.Quality is not set
+const DefaultScreenshotQuality = 80
+
+// Screenshots are the full-page screenshots a Fetcher takes of the pages it
+// renders, once they are, for visual regression checks or an archive of
+// the site. Each goes to a file of Dir named after the SHA-1 of the URL,
+// such as 3f786850e387550fdab836ed7e6dc881de23001b.png, the same URL being
+// the same file from crawl to crawl, which the crawl results name in their
+// Screenshot.
+type Screenshots struct {
+	Dir string
+
+	// Format is "png", the default, or "webp", lossy at Quality, from 1
+	//   to 100, DefaultScreenshotQuality when zero
+	Format  string
+	Quality int
+}
+
+// take takes the screenshot of the page of url rendered in tab, and
+// returns the file it went to
+func (s *Screenshots) take(tab context.Context, url string) (string, error) {
+	format, ext := page.CaptureScreenshotFormatPng, ".png"
+	quality := 0
+	switch s.Format {
+	case "", "png":
+	case "webp":
+		format, ext = page.CaptureScreenshotFormatWebp, ".webp"
+		quality = s.Quality
+		if quality <= 0 {
+			quality = DefaultScreenshotQuality
+		}
+	default:
+		return "", fmt.Errorf("screenshot: unknown format %q, want png or webp", s.Format)
+	}
+	var img []byte
+	err := chromedp.Run(tab, chromedp.ActionFunc(func(ctx context.Context) error {
+		// The whole page, not only the window onto it
+		_, _, _, _, _, size, err := page.GetLayoutMetrics().Do(ctx)
+		if err != nil {
+			return err
+		}
+		capture := page.CaptureScreenshot().WithCaptureBeyondViewport(true).WithFromSurface(true).WithFormat(format).
+			WithClip(&page.Viewport{Width: size.Width, Height: size.Height, Scale: 1})
+		if quality > 0 {
+			capture = capture.WithQuality(int64(quality))
+		}
+		img, err = capture.Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return "", fmt.Errorf("screenshot: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(url))
+	name := filepath.Join(s.Dir, hex.EncodeToString(sum[:])+ext)
+	if err := os.WriteFile(name, img, 0o644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
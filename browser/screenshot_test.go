@@ -0,0 +1,51 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScreenshotsFormat(t *testing.T) {
+	s := &Screenshots{Dir: t.TempDir(), Format: "gif"}
+	// Turned down before the tab is used at all
+	if _, err := s.take(context.Background(), "https://site.test/"); err == nil || !strings.Contains(err.Error(), `"gif"`) {
+		t.Errorf("take as gif: %v, want an unknown format error", err)
+	}
+}
+
+func TestScreenshots(t *testing.T) {
+	f := startTest(t)
+	srv := app()
+	defer srv.Close()
+
+	for format, magic := range map[string][]byte{"": []byte("\x89PNG"), "webp": []byte("RIFF")} {
+		dir := t.TempDir()
+		f.Screenshots = &Screenshots{Dir: filepath.Join(dir, "shots"), Format: format}
+		url := srv.URL + "/"
+		resp, err := f.FetchResponse(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ext := ".png"
+		if format == "webp" {
+			ext = ".webp"
+		}
+		sum := sha1.Sum([]byte(url))
+		if want := filepath.Join(dir, "shots", hex.EncodeToString(sum[:])+ext); resp.Screenshot != want {
+			t.Errorf("%q: Screenshot %s, want %s", format, resp.Screenshot, want)
+		}
+		img, err := os.ReadFile(resp.Screenshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(img, magic) {
+			t.Errorf("%q: screenshot starts with %q, want %q", format, img[:min(8, len(img))], magic)
+		}
+	}
+}
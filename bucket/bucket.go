@@ -0,0 +1,165 @@
+// Package bucket saves the bodies of the pages of a crawl to an object
+// storage bucket, Amazon S3 or one that speaks its API: Google Cloud
+// Storage through its XML API, MinIO, Cloudflare R2 and the like. Large
+// crawls can keep their content there rather than on the local disk.
+//
+// Every body is an object of its own, named after the SHA-256 of its URL,
+// and a manifest lists them once the crawl is over:
+//
+//	b, err := bucket.Parse("s3://my-bucket/crawls/2024-06-01")
+//	...
+//	sink := &bucket.Sink{Bucket: b}
+//	c := webcrawl.NewCrawler(webcrawl.WithOnResult(func(r webcrawl.CrawlResult) {
+//		if err := sink.Add(ctx, r); err != nil {
+//			log.Print(err)
+//		}
+//	}))
+//	err = c.Run(ctx, seed)
+//	err = sink.Close(ctx)
+//
+// Requests are signed with AWS Signature Version 4, by the signer of the
+// AWS SDK for Go, which GCS takes with HMAC keys.
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// Bucket is a bucket of an object storage, and the credentials to write
+// to it.
+type Bucket struct {
+	// Endpoint is the URL of the storage, such as
+	//   https://s3.eu-west-3.amazonaws.com or
+	//   https://storage.googleapis.com. The objects are at
+	//   Endpoint/Name/key
+	Endpoint string
+	Name     string
+
+	// Region is the one of the bucket, "auto" for GCS and R2
+	Region string
+
+	// AccessKey, SecretKey and SessionToken are the credentials, an HMAC
+	//   key for GCS. SessionToken is only there with temporary ones
+	AccessKey, SecretKey, SessionToken string
+
+	// Prefix goes in front of the keys of the objects, as a directory.
+	//   It ends with a / unless empty
+	Prefix string
+
+	// Client makes the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Parse returns the bucket of a s3://name/prefix or gs://name/prefix URL,
+// with the credentials of the environment: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION (us-east-1 when
+// unset) for S3, GCS_HMAC_ACCESS_KEY and GCS_HMAC_SECRET for GCS.
+// AWS_ENDPOINT_URL points s3:// URLs to another storage than Amazon's.
+func Parse(rawURL string) (*Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("bucket: %s: no bucket name", rawURL)
+	}
+	b := &Bucket{Name: u.Host, Prefix: strings.TrimPrefix(u.Path, "/")}
+	if b.Prefix != "" && !strings.HasSuffix(b.Prefix, "/") {
+		b.Prefix += "/"
+	}
+	switch u.Scheme {
+	case "s3":
+		b.Region = os.Getenv("AWS_REGION")
+		if b.Region == "" {
+			b.Region = "us-east-1"
+		}
+		b.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+		if b.Endpoint == "" {
+			b.Endpoint = "https://s3." + b.Region + ".amazonaws.com"
+		}
+		b.AccessKey, b.SecretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		b.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	case "gs":
+		b.Endpoint, b.Region = "https://storage.googleapis.com", "auto"
+		b.AccessKey, b.SecretKey = os.Getenv("GCS_HMAC_ACCESS_KEY"), os.Getenv("GCS_HMAC_SECRET")
+	default:
+		return nil, fmt.Errorf("bucket: %s: want an s3:// or gs:// URL", rawURL)
+	}
+	if b.AccessKey == "" || b.SecretKey == "" {
+		return nil, fmt.Errorf("bucket: %s: no credentials in the environment", rawURL)
+	}
+	return b, nil
+}
+
+// Put writes the object key, under Prefix, with body.
+func (b *Bucket) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	u := strings.TrimSuffix(b.Endpoint, "/") + "/" + escape(b.Name) + "/" + escape(b.Prefix+key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(body)
+	if err := b.sign(req, hex.EncodeToString(sum[:]), time.Now()); err != nil {
+		return fmt.Errorf("bucket: putting %s: %w", key, err)
+	}
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("bucket: putting %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("bucket: putting %s: %s: %s", key, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// signer signs the requests of every Bucket. Put escapes the paths as
+// Signature Version 4 wants them already, S3 not wanting them escaped
+// twice
+var signer = v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+
+// sign signs req, whose body hashes to payloadHash, with AWS Signature
+// Version 4 at the time now
+func (b *Bucket) sign(req *http.Request, payloadHash string, now time.Time) error {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	creds := aws.Credentials{AccessKeyID: b.AccessKey, SecretAccessKey: b.SecretKey, SessionToken: b.SessionToken}
+	return signer.SignHTTP(req.Context(), creds, req, payloadHash, "s3", b.Region, now.UTC())
+}
+
+// escape percent-encodes the path s as Signature Version 4 wants it:
+// everything but the unreserved characters and /
+func escape(s string) string {
+	var sb strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
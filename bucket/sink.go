@@ -0,0 +1,87 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// ManifestKey is the key of the manifest of a Sink, under the Prefix of
+// its Bucket.
+const ManifestKey = "manifest.jsonl"
+
+// Entry is a line of the manifest of a Sink, in JSON: a body and the page
+// it is the one of.
+type Entry struct {
+	URL         string    `json:"url"`
+	Key         string    `json:"key"` // under the Prefix of the Bucket
+	ContentType string    `json:"content_type,omitempty"`
+	ContentHash string    `json:"content_hash"`
+	Size        int       `json:"size"`
+	Status      int       `json:"status,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// Sink writes the bodies of the pages of a crawl to Bucket, as objects
+// named bodies/ followed by the SHA-256 of the URL in hex, and lists them
+// in a manifest, which Close writes. A page fetched again under the same
+// URL, in a later crawl, thus has the same key. A Sink is safe for
+// concurrent use.
+type Sink struct {
+	Bucket *Bucket
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// Key returns the key of the body of the page at url, under the Prefix of
+// the Bucket.
+func Key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "bodies/" + hex.EncodeToString(sum[:])
+}
+
+// Add writes the body of the page of a fetch that went fine, the other
+// results are ignored.
+func (s *Sink) Add(ctx context.Context, res webcrawl.CrawlResult) error {
+	if res.Err != nil || res.External || res.Body == "" {
+		return nil
+	}
+	e := Entry{URL: res.URL, Key: Key(res.URL), ContentType: res.Header.Get("Content-Type"),
+		ContentHash: res.ContentHash, Size: len(res.Body), Status: res.StatusCode, FetchedAt: res.FetchedAt}
+	if e.ContentHash == "" {
+		e.ContentHash = webcrawl.ContentHash(res.Body)
+	}
+	if err := s.Bucket.Put(ctx, e.Key, []byte(res.Body), e.ContentType); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Entries returns the bodies written so far, in the order they were.
+func (s *Sink) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
+
+// Close writes the manifest, ManifestKey, one Entry per line.
+func (s *Sink) Close(ctx context.Context) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range s.Entries() {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return s.Bucket.Put(ctx, ManifestKey, buf.Bytes(), "application/x-ndjson")
+}
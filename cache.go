@@ -0,0 +1,112 @@
+package webcrawl
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// CacheEntry is what a ConditionalCache remembers of a page.
+type CacheEntry struct {
+	ETag         string   `json:",omitempty"`
+	LastModified string   `json:",omitempty"`
+	Links        []string `json:",omitempty"` // the page's links, for when it is unchanged
+}
+
+// ConditionalCache remembers the validators, ETag and Last-Modified, of
+// the pages an HTTPFetcher fetched, so that fetching them again is a
+// conditional request: a server that answers 304 Not Modified doesn't
+// resend the page. It keeps the pages' links rather than their bodies, so
+// that a re-crawl still goes through the pages that didn't change.
+//
+// Save it after a crawl and load it before the next one for incremental
+// re-crawls. A ConditionalCache is safe for concurrent use, its zero value
+// is an empty cache.
+type ConditionalCache struct {
+	mu      sync.RWMutex
+	entries map[string]CacheEntry // URL => entry
+}
+
+// NewConditionalCache returns an empty ConditionalCache.
+func NewConditionalCache() *ConditionalCache {
+	return &ConditionalCache{entries: make(map[string]CacheEntry)}
+}
+
+// LoadConditionalCache reads a ConditionalCache saved at path, a missing
+// file is an empty cache.
+func LoadConditionalCache(path string) (*ConditionalCache, error) {
+	c := NewConditionalCache()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the cache to path, as JSON. It writes a temporary file first
+// so that a crash doesn't leave a truncated cache behind.
+func (c *ConditionalCache) Save(path string) error {
+	c.mu.RLock()
+	b, err := json.Marshal(c.entries)
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get returns the entry of url.
+func (c *ConditionalCache) Get(url string) (CacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[url]
+	return e, ok
+}
+
+// Put sets the entry of url, an entry without validators deletes it.
+func (c *ConditionalCache) Put(url string, e CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.ETag == "" && e.LastModified == "" {
+		delete(c.entries, url)
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]CacheEntry)
+	}
+	c.entries[url] = e
+}
+
+// Len returns the number of pages in the cache.
+func (c *ConditionalCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// get is Get on a cache that may be nil
+func (c *ConditionalCache) get(url string) (CacheEntry, bool) {
+	if c == nil {
+		return CacheEntry{}, false
+	}
+	return c.Get(url)
+}
+
+// put is Put on a cache that may be nil
+func (c *ConditionalCache) put(url string, e CacheEntry) {
+	if c != nil {
+		c.Put(url, e)
+	}
+}
@@ -0,0 +1,219 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// The inconsistencies a CanonicalReport flags
+const (
+	CanonicalBroken = "canonical not 200" // a canonical answering with a redirect or an error
+	CanonicalChain  = "canonical chain"   // a canonical that has another canonical
+	CanonicalLoop   = "canonical loop"    // canonicals leading back to the page
+)
+
+// CanonicalIssue is an inconsistency of the <link rel="canonical"> of a
+// page.
+type CanonicalIssue struct {
+	URL       string // the page
+	Kind      string // CanonicalBroken and the like
+	Canonical string // the canonical of the page
+
+	// Chain are the canonicals followed from the page, Canonical first,
+	//   for CanonicalChain and CanonicalLoop. Status is what Canonical
+	//   answered, for CanonicalBroken
+	Chain  []string
+	Status string
+}
+
+// CanonicalReport validates the <link rel="canonical"> of the pages of a
+// crawl: that the canonicals answer with a 200, and are canonical
+// themselves rather than the start of a chain or a loop. The canonicals the
+// crawl didn't get to are left alone unless Fetch gets them.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. A
+// CanonicalReport is safe for concurrent use, its zero value is ready to
+// use.
+type CanonicalReport struct {
+	// Normalizer must be the Crawler's, for the canonicals to be matched
+	//   with the pages. When nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	mu     sync.Mutex
+	pages  map[string]string // normalized page URL => its canonical, normalized
+	status map[string]string // normalized URL => see fetchStatus, a redirect its code
+}
+
+// Add records how a fetch went and, for an HTML page, its canonical.
+func (r *CanonicalReport) Add(res CrawlResult) {
+	if res.Asset {
+		return
+	}
+	canonical := res.Canonical
+	if canonical == "" && !res.External && res.Err == nil && res.Body != "" && htmlResult(res) {
+		if base, doc, err := pageDoc(res); err == nil {
+			canonical = ExtractSEO(base, doc).Canonical
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == nil {
+		r.status = make(map[string]string)
+		r.pages = make(map[string]string)
+	}
+	r.status[r.normalize(res.URL)] = fetchStatus(res)
+	for _, hop := range res.Redirects {
+		r.status[r.normalize(hop.From)] = strconv.Itoa(hop.StatusCode)
+	}
+	if canonical != "" {
+		final := r.normalize(pageURL(res))
+		r.status[final] = fetchStatus(res)
+		r.pages[final] = r.normalize(canonical)
+	}
+}
+
+// Fetch fetches with f the canonicals the results added left out, and
+// theirs in turn, for them to be validated too. It is to be called once the
+// crawl is over, and goes without robots.txt nor rate limits: there is one
+// request per canonical. A failed fetch is a canonical not 200, Fetch only
+// returns the error of ctx.
+func (r *CanonicalReport) Fetch(ctx context.Context, f Fetcher) error {
+	for {
+		var missing []string
+		r.mu.Lock()
+		for _, c := range r.pages {
+			if _, ok := r.status[c]; !ok {
+				missing = append(missing, c)
+			}
+		}
+		r.mu.Unlock()
+		if len(missing) == 0 {
+			return nil
+		}
+		sort.Strings(missing)
+		for _, u := range missing {
+			resp, err := FetchResponse(ctx, f, u)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.Add(CrawlResult{URL: u, StatusCode: resp.StatusCode, Redirects: resp.Redirects, Header: resp.Header, Body: resp.Body, Err: err})
+		}
+	}
+}
+
+func (r *CanonicalReport) normalize(u string) string {
+	norm := r.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	if n, err := norm.Normalize(u); err == nil {
+		return n
+	}
+	return u
+}
+
+// Issues returns the inconsistencies of the pages recorded so far, sorted
+// by page. A page has one at most, the first of a canonical not 200, a
+// loop and a chain.
+func (r *CanonicalReport) Issues() []CanonicalIssue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var issues []CanonicalIssue
+	for page, c := range r.pages {
+		if c == page {
+			continue
+		}
+		if st, ok := r.status[c]; ok && st != "200" {
+			issues = append(issues, CanonicalIssue{URL: page, Kind: CanonicalBroken, Canonical: c, Status: st})
+			continue
+		}
+		chain := []string{c}
+		seen := map[string]bool{page: true, c: true}
+		kind := ""
+		for {
+			next, ok := r.pages[chain[len(chain)-1]]
+			if !ok || next == chain[len(chain)-1] {
+				break
+			}
+			kind = CanonicalChain
+			chain = append(chain, next)
+			if seen[next] {
+				if next == page {
+					kind = CanonicalLoop
+				}
+				break
+			}
+			seen[next] = true
+		}
+		if kind != "" {
+			issues = append(issues, CanonicalIssue{URL: page, Kind: kind, Canonical: c, Chain: chain})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].URL < issues[j].URL })
+	return issues
+}
+
+// WriteText writes the inconsistencies to w, one per line with the page and
+// its canonical, then what the canonical answered or the canonicals it
+// leads to:
+//
+//	canonical not 200	https://example.com/a	https://example.com/b	404
+//	canonical loop	https://example.com/a	https://example.com/b -> https://example.com/a
+func (r *CanonicalReport) WriteText(w io.Writer) error {
+	for _, i := range r.Issues() {
+		line := i.Kind + "\t" + i.URL + "\t"
+		if i.Status != "" {
+			line += i.Canonical + "\t" + i.Status
+		} else {
+			line += strings.Join(i.Chain, " -> ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonical sets the Canonical of res, an HTML page parsed into base and
+// doc, and makes it Duplicate of that page if the crawl is to go there
+// instead: it is in the Scope of seed, and its own canonicals don't lead
+// back
+func (r *run) canonical(res *CrawlResult, base *url.URL, doc *html.Node, seed *url.URL) {
+	res.Canonical = ExtractSEO(base, doc).Canonical
+	if res.Canonical == "" {
+		return
+	}
+	page, err1 := r.norm.Normalize(pageURL(*res))
+	canon, err2 := r.norm.Normalize(res.Canonical)
+	if err1 != nil || err2 != nil || page == canon {
+		return
+	}
+	if u, err := url.Parse(canon); err != nil || r.Scope != nil && !r.Scope.InScope(seed, u) {
+		return
+	}
+	r.pagesMu.Lock()
+	defer r.pagesMu.Unlock()
+	r.canonicals[page] = canon
+	for c, n := canon, 0; n <= len(r.canonicals); n++ {
+		if c == page {
+			r.log.Debug("canonical loop", "url", page, "canonical", canon)
+			return
+		}
+		next, ok := r.canonicals[c]
+		if !ok {
+			break
+		}
+		c = next
+	}
+	res.Duplicate, res.DuplicateOf, res.Links, res.NoFollowLinks = true, res.Canonical, nil, nil
+	res.Assets = nil
+}
@@ -0,0 +1,260 @@
+package webcrawl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotRecorded is returned by a ReplayFetcher for the URLs its cassette
+// holds no answer for.
+var ErrNotRecorded = errors.New("webcrawl: not in the cassette")
+
+// cassetteEntry is a line of a cassette: an answer of the Fetcher recorded,
+// to a fetch of URL or a check of it
+type cassetteEntry struct {
+	URL   string    `json:"url"`
+	Check bool      `json:"check,omitempty"`
+	Resp  *Response `json:"response,omitempty"`
+
+	// Body64 is the body when it is not UTF-8, such as a PDF, which JSON
+	//   strings can't hold; Resp.Body is empty then
+	Body64 []byte `json:"body_base64,omitempty"`
+
+	// The error, as far as the Crawler tells them apart: its message,
+	//   what Classify made of it, and the status it was for
+	Err        string        `json:"error,omitempty"`
+	Cause      string        `json:"cause,omitempty"`
+	Status     string        `json:"status,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+}
+
+// RecordingFetcher is a Fetcher recording every answer of another one to a
+// cassette file, for a ReplayFetcher to serve them back: a crawl recorded
+// once, against the real sites, can then be run again and again offline,
+// as in the integration tests of crawl logic. The cassette is a JSON object
+// per line, written as the answers come in. Fetches cut short by their
+// context are not recorded.
+type RecordingFetcher struct {
+	Fetcher Fetcher
+
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	err error
+}
+
+// NewRecordingFetcher returns a RecordingFetcher recording the answers of
+// f to the cassette at path, which it creates or truncates. Close it once
+// the crawl is over.
+func NewRecordingFetcher(f Fetcher, path string) (*RecordingFetcher, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingFetcher{Fetcher: f, f: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Fetch implements Fetcher.
+func (f *RecordingFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements ResponseFetcher.
+func (f *RecordingFetcher) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	resp, err := FetchResponse(ctx, f.Fetcher, url)
+	if ctx.Err() == nil {
+		f.record(newCassetteEntry(url, false, resp, err))
+	}
+	return resp, err
+}
+
+// Check implements Checker.
+func (f *RecordingFetcher) Check(ctx context.Context, url string) error {
+	err := Check(ctx, f.Fetcher, url)
+	if ctx.Err() == nil {
+		f.record(newCassetteEntry(url, true, nil, err))
+	}
+	return err
+}
+
+func newCassetteEntry(url string, check bool, resp *Response, err error) *cassetteEntry {
+	e := &cassetteEntry{URL: url, Check: check, Resp: resp}
+	if resp != nil && !utf8.ValidString(resp.Body) {
+		body := *resp
+		e.Body64, body.Body = []byte(resp.Body), ""
+		e.Resp = &body
+	}
+	if err != nil {
+		e.Err, e.Cause = err.Error(), Classify(err).Error()
+		var se *StatusError
+		if errors.As(err, &se) {
+			e.Status, e.StatusCode, e.RetryAfter = se.Status, se.StatusCode, se.RetryAfter
+		}
+	}
+	return e
+}
+
+func (f *RecordingFetcher) record(e *cassetteEntry) {
+	b, err := json.Marshal(e)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil && f.err == nil {
+		b = append(b, '\n')
+		_, err = f.w.Write(b)
+	}
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("webcrawl: cassette: %w", err)
+	}
+}
+
+// Close writes out what is left of the cassette and closes it. It returns
+// the first failure to record an answer, if any.
+func (f *RecordingFetcher) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.w.Flush()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return err
+}
+
+// ReplayFetcher is a Fetcher serving back the answers a RecordingFetcher
+// recorded, errors included, without touching the network. A URL answered
+// several times, as when retried, is answered in the same order, the last
+// answer over and over once they are used up. A URL the cassette doesn't
+// hold fails with ErrNotRecorded. It is safe for concurrent use.
+type ReplayFetcher struct {
+	mu      sync.Mutex
+	entries map[cassetteKey][]*cassetteEntry
+	served  map[cassetteKey]int
+}
+
+type cassetteKey struct {
+	url   string
+	check bool
+}
+
+// NewReplayFetcher returns a ReplayFetcher serving the answers of the
+// cassette at path.
+func NewReplayFetcher(path string) (*ReplayFetcher, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	f := &ReplayFetcher{entries: make(map[cassetteKey][]*cassetteEntry), served: make(map[cassetteKey]int)}
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		e := &cassetteEntry{}
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("webcrawl: cassette %s:%d: %w", path, line, err)
+		}
+		k := cassetteKey{e.URL, e.Check}
+		f.entries[k] = append(f.entries[k], e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("webcrawl: cassette %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// next returns the answer to serve for url
+func (f *ReplayFetcher) next(url string, check bool) (*cassetteEntry, error) {
+	k := cassetteKey{url, check}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries := f.entries[k]
+	if len(entries) == 0 && check {
+		// Fetched when recorded, it was checked now
+		k.check = false
+		entries = f.entries[k]
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotRecorded, url)
+	}
+	i := min(f.served[k], len(entries)-1)
+	f.served[k]++
+	return entries[i], nil
+}
+
+// Fetch implements Fetcher.
+func (f *ReplayFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements ResponseFetcher.
+func (f *ReplayFetcher) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := f.next(url, false)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{URL: url}
+	if e.Resp != nil {
+		// A copy, for the Crawler to do as it likes with
+		r := *e.Resp
+		resp = &r
+	}
+	if e.Body64 != nil {
+		resp.Body = string(e.Body64)
+	}
+	return resp, e.error()
+}
+
+// Check implements Checker.
+func (f *ReplayFetcher) Check(ctx context.Context, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e, err := f.next(url, true)
+	if err != nil {
+		return err
+	}
+	return e.error()
+}
+
+// error returns the error recorded, which Classify makes the same of, and
+// which tells the same status
+func (e *cassetteEntry) error() error {
+	if e.Err == "" {
+		return nil
+	}
+	// As the results read back from JSONL
+	err := &jsonlError{msg: e.Err, cause: ErrFetchFailed}
+	if e.StatusCode != 0 {
+		err.cause = &StatusError{URL: e.URL, StatusCode: e.StatusCode, Status: e.Status, RetryAfter: e.RetryAfter}
+		return err
+	}
+	for cause := range errorClasses {
+		if cause.Error() == e.Cause {
+			err.cause = cause
+		}
+	}
+	return err
+}
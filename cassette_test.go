@@ -0,0 +1,145 @@
+package webcrawl_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// answer is what a crawl made of a URL, as far as a replay is to tell the
+// same
+type answer struct {
+	Depth      int
+	StatusCode int
+	Body       string
+	Links      []string
+	Redirects  int
+	Err        string
+	Cause      error
+}
+
+// crawlAnswers crawls from seed with f and returns the answers by URL
+func crawlAnswers(t *testing.T, f webcrawl.Fetcher, seed string) map[string]answer {
+	t.Helper()
+	var mu sync.Mutex
+	answers := make(map[string]answer)
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(f), webcrawl.WithoutRobots(), webcrawl.WithDepth(webcrawl.UnlimitedDepth),
+		webcrawl.WithOnResult(func(res webcrawl.CrawlResult) {
+			a := answer{Depth: res.Depth, StatusCode: res.StatusCode, Body: res.Body, Links: res.Links, Redirects: len(res.Redirects)}
+			if res.Err != nil {
+				a.Err, a.Cause = res.Err.Error(), webcrawl.Classify(res.Err)
+			}
+			mu.Lock()
+			answers[res.URL] = a
+			mu.Unlock()
+		}))
+	c.CheckExternal = true
+	err := c.Crawl(seed)
+	var report *webcrawl.ErrorReport
+	if err != nil && !errors.As(err, &report) {
+		t.Fatal(err)
+	}
+	return answers
+}
+
+func TestRecordReplay(t *testing.T) {
+	site := testsite.Generate("http://site.test", testsite.Options{Pages: 60, CrossLinks: 2, DeadLinks: 0.1, Redirects: 0.1, Errors: 0.1, Seed: 5})
+	// A body that is not UTF-8, kept apart in the cassette
+	site.Page("/").Links = append(site.Page("/").Links, "/doc.bin")
+	site.Add("/doc.bin", &testsite.Page{Body: "\xff\xfe\x00binary", ContentType: "application/octet-stream"})
+	srv := httptest.NewServer(site.Handler())
+	defer srv.Close()
+	cassette := filepath.Join(t.TempDir(), "crawl.jsonl")
+
+	rec, err := webcrawl.NewRecordingFetcher(webcrawl.NewHTTPFetcher(0), cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := crawlAnswers(t, rec, srv.URL+"/")
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) < 60 {
+		t.Fatalf("recorded %d answers, want 60 at least", len(recorded))
+	}
+	fetches, _ := site.TotalFetches()
+
+	// The server is gone, the cassette answers the same
+	srv.Close()
+	replay, err := webcrawl.NewReplayFetcher(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := crawlAnswers(t, replay, srv.URL+"/")
+	if got, _ := site.TotalFetches(); got != fetches {
+		t.Errorf("the replay went to the site, %d fetches", got-fetches)
+	}
+	for u, want := range recorded {
+		got, ok := replayed[u]
+		switch {
+		case !ok:
+			t.Errorf("%s not replayed", u)
+		case !reflect.DeepEqual(got, want):
+			t.Errorf("%s replayed as\n%+v\nrecorded as\n%+v", u, got, want)
+		}
+	}
+	if len(replayed) != len(recorded) {
+		t.Errorf("%d answers replayed, %d recorded", len(replayed), len(recorded))
+	}
+	var failures int
+	for _, a := range recorded {
+		if a.Err != "" {
+			failures++
+		}
+	}
+	if failures == 0 {
+		t.Error("no failure recorded, the replay of errors went untested")
+	}
+	if a := recorded[srv.URL+"/doc.bin"]; a.Body != "\xff\xfe\x00binary" {
+		t.Errorf("body of /doc.bin = %q", a.Body)
+	}
+}
+
+func TestReplayInOrder(t *testing.T) {
+	site := testsite.New("http://site.test")
+	site.Add("/", &testsite.Page{Status: http.StatusServiceUnavailable})
+	cassette := filepath.Join(t.TempDir(), "retries.jsonl")
+	rec, err := webcrawl.NewRecordingFetcher(site, cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	rec.FetchResponse(ctx, site.URL("/"))
+	site.Add("/", &testsite.Page{Body: "<p>back</p>"})
+	rec.FetchResponse(ctx, site.URL("/"))
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := webcrawl.NewReplayFetcher(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var se *webcrawl.StatusError
+	if _, err := replay.FetchResponse(ctx, site.URL("/")); !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("first answer: %v, want a 503 StatusError", err)
+	}
+	for range 2 {
+		// The last answer over and over once used up
+		if resp, err := replay.FetchResponse(ctx, site.URL("/")); err != nil || !strings.Contains(resp.Body, "back") {
+			t.Errorf("next answer: %v, want the page back", err)
+		}
+	}
+	if _, err := replay.FetchResponse(ctx, site.URL("/other")); !errors.Is(err, webcrawl.ErrNotRecorded) {
+		t.Errorf("URL not recorded: %v, want ErrNotRecorded", err)
+	}
+}
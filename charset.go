@@ -0,0 +1,59 @@
+package webcrawl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// sniffLen is how much of a body is looked at for its charset, the
+// <meta charset> of an HTML page must be within it as HTML5 says
+const sniffLen = 1024
+
+// toUTF8 returns a reader of body transcoded to UTF-8, and the name of
+// the charset it was in, such as "windows-1252", going by the charset of
+// contentType, else by a byte order mark or the <meta> tags of an HTML
+// body. Bodies that aren't text are left alone, with no name
+func toUTF8(body io.Reader, contentType string) (io.Reader, string) {
+	if !isText(contentType) {
+		return body, ""
+	}
+	br := bufio.NewReaderSize(body, sniffLen)
+	head, _ := br.Peek(sniffLen) // as much as there is
+	enc, name, certain := charset.DetermineEncoding(head, contentType)
+	if !certain && ascii(head) && !bytes.Contains(bytes.ToLower(head), []byte("charset")) {
+		// Nothing tells, windows-1252 is a guess that would garble the
+		//   UTF-8 further down
+		return br, "utf-8"
+	}
+	if name == "utf-8" {
+		return br, name
+	}
+	return transform.NewReader(br, enc.NewDecoder()), name
+}
+
+// isText reports whether a Content-Type header denotes text, which has a
+// charset
+func isText(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(t, "text/") || t == "application/xhtml+xml" ||
+		t == "application/xml" || t == "application/json" || strings.HasSuffix(t, "+xml")
+}
+
+// ascii reports whether b is all ASCII
+func ascii(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
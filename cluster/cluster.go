@@ -0,0 +1,605 @@
+// Package cluster shares a crawl between several processes through Redis:
+// the frontier and the visited set live there, and every process crawls
+// the hosts that consistent hashing assigns it, so that the politeness of
+// a host, its rate limit and backoff, is kept by a single process for the
+// whole cluster.
+//
+// Start every process with the same seed, much as one would a crawl of its
+// own:
+//
+//	cl, err := cluster.Open("redis://localhost:6379/0", cluster.Options{Name: "example"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer cl.Close()
+//	c := webcrawl.NewCrawler(webcrawl.WithFrontier(cl), webcrawl.WithRateLimit(limiter))
+//	c.Visited = cl
+//	err = c.Run(ctx, "https://example.com/")
+//
+// The seed is queued once for the cluster, and a process fetches the URLs
+// of the hosts it owns, pushing the links it finds to the queues of their
+// hosts for their owners to take. A process claims a URL by moving it to a
+// list of its own, a lease that it renews as long as it lives: when it
+// stops renewing it, being killed or gone silent, the others put the URLs
+// it claimed back in their queues. A URL may so be fetched twice, never
+// lost. Run returns once no URL is queued or claimed in the whole cluster.
+//
+// Since a host is crawled by one process at a time, a crawl of a single
+// host goes no faster for more processes. The budgets of the Crawler, such
+// as MaxPages, are per process.
+//
+// The keys of a crawl are under its Name:
+//
+//	name:hosts          set, the hosts with URLs queued
+//	name:queue:host     list, the URLs of host waiting, as JSON FrontierItems
+//	name:leased:id      list, the URLs process id claimed
+//	name:members        sorted set, the processes by when their lease ends
+//	name:visited        set, the URLs seen
+//
+// Redis 6.2 or later is needed, for LMOVE.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// DefaultLease is how long the URLs a process claimed stay its own once
+// it stops renewing its lease, when Options.Lease is not set
+const DefaultLease = 30 * time.Second
+
+// vnodes is how many points each process has on the hash ring, for the
+// hosts to spread evenly
+const vnodes = 64
+
+// pollInterval is how often a process with nothing to fetch looks again
+// for URLs queued by the others
+const pollInterval = 200 * time.Millisecond
+
+// Options set up a Cluster.
+type Options struct {
+	// Name is the crawl, the prefix of its keys, "webcrawl" when empty
+	Name string
+
+	// ID names this process in the cluster, it has to be unique to it.
+	//   The hostname and the process ID when empty
+	ID string
+
+	// Lease is how long the URLs a process claimed stay its own once it
+	//   goes silent, DefaultLease when zero. It renews them every third of
+	//   it
+	Lease time.Duration
+}
+
+// Cluster is this process in a crawl shared through Redis. It is both the
+// webcrawl.Frontier and the webcrawl.VisitedSet of its Crawler, which
+// makes it a webcrawl.LeasingFrontier too.
+//
+// The Frontier and VisitedSet methods have no way of telling of a failure
+// of Redis: the first one is kept for Err, and the crawl goes on as best
+// it can.
+type Cluster struct {
+	rdb    *redis.Client
+	name   string
+	id     string
+	lease  time.Duration
+	quit   chan struct{}
+	closed chan struct{}
+
+	mu       sync.Mutex
+	err      error
+	ring     []point           // of the live processes
+	members  []string          // the live processes
+	claimed  map[string]string // URL => the JSON it was claimed as
+	taken    map[string]bool   // URLs another process marked seen first
+	owned    []string          // the hosts of this process with URLs queued
+	next     int               // of owned, to take the hosts in turn
+	ownedAt  time.Time         // when owned was listed
+	queued   int               // in the queues of owned, see Len
+	queuedAt time.Time
+}
+
+// point is a point of a process on the hash ring
+type point struct {
+	hash   uint64
+	member string
+}
+
+// Open joins the crawl of opts.Name on the Redis server at rawURL,
+// redis://[[user]:password@]host[:port][/db], or rediss:// for TLS. Close
+// leaves it.
+func Open(rawURL string, opts Options) (*Cluster, error) {
+	ropts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: %w", err)
+	}
+	rdb := redis.NewClient(ropts)
+	cl := &Cluster{rdb: rdb, name: opts.Name, id: opts.ID, lease: opts.Lease, claimed: make(map[string]string),
+		taken: make(map[string]bool), quit: make(chan struct{}), closed: make(chan struct{})}
+	if cl.name == "" {
+		cl.name = "webcrawl"
+	}
+	if cl.id == "" {
+		host, _ := os.Hostname()
+		cl.id = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if cl.lease <= 0 {
+		cl.lease = DefaultLease
+	}
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("cluster: %w", err)
+	}
+	if err := cl.heartbeat(); err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	go cl.renew()
+	return cl, nil
+}
+
+func (cl *Cluster) key(parts ...string) string {
+	k := cl.name
+	for _, p := range parts {
+		k += ":" + p
+	}
+	return k
+}
+
+// ID returns the ID of this process in the cluster.
+func (cl *Cluster) ID() string { return cl.id }
+
+// Err returns the first failure of Redis the Frontier or VisitedSet
+// methods ran into.
+func (cl *Cluster) Err() error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.err
+}
+
+// fail records err, if it is the first
+func (cl *Cluster) fail(err error) {
+	cl.mu.Lock()
+	if cl.err == nil {
+		cl.err = err
+	}
+	cl.mu.Unlock()
+}
+
+// renew renews the lease of this process until Close
+func (cl *Cluster) renew() {
+	defer close(cl.closed)
+	t := time.NewTicker(cl.lease / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := cl.heartbeat(); err != nil {
+				cl.fail(err)
+			}
+		case <-cl.quit:
+			return
+		}
+	}
+}
+
+// heartbeat renews the lease of this process, puts the URLs of the
+// processes whose lease ended back in their queues, and sets the hash ring
+// up for the processes alive
+func (cl *Cluster) heartbeat() error {
+	ctx := context.Background()
+	now := time.Now()
+	lease := redis.Z{Score: float64(now.Add(cl.lease).UnixMilli()), Member: cl.id}
+	if err := cl.rdb.ZAdd(ctx, cl.key("members"), lease).Err(); err != nil {
+		return err
+	}
+	dead, err := cl.rdb.ZRangeByScore(ctx, cl.key("members"), &redis.ZRangeBy{Min: "-inf", Max: "(" + unixMilli(now)}).Result()
+	if err != nil {
+		return err
+	}
+	for _, m := range dead {
+		if err := cl.requeue(m); err != nil {
+			return err
+		}
+		if err := cl.rdb.ZRem(ctx, cl.key("members"), m).Err(); err != nil {
+			return err
+		}
+	}
+	return cl.refresh()
+}
+
+// refresh sets the hash ring up for the processes alive, those that joined
+// since included
+func (cl *Cluster) refresh() error {
+	members, err := cl.rdb.ZRangeByScore(context.Background(), cl.key("members"), &redis.ZRangeBy{Min: unixMilli(time.Now()), Max: "+inf"}).Result()
+	if err != nil {
+		return err
+	}
+	ring := make([]point, 0, len(members)*vnodes)
+	for _, m := range members {
+		for i := 0; i < vnodes; i++ {
+			ring = append(ring, point{hash: hash(m + "#" + strconv.Itoa(i)), member: m})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	cl.mu.Lock()
+	cl.ring, cl.members = ring, members
+	cl.mu.Unlock()
+	return nil
+}
+
+func unixMilli(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// hash is FNV-1a, mixed as splitmix64 does: hosts differing by their last
+// letter alone would hash close by on the ring, and to the same process
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Owner returns the ID of the process that crawls host, as far as this
+// process knows the cluster.
+func (cl *Cluster) Owner(host string) string {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.owner(host)
+}
+
+func (cl *Cluster) owner(host string) string {
+	if len(cl.ring) == 0 {
+		return cl.id
+	}
+	h := hash(host)
+	i := sort.Search(len(cl.ring), func(i int) bool { return cl.ring[i].hash >= h })
+	if i == len(cl.ring) {
+		i = 0
+	}
+	return cl.ring[i].member
+}
+
+// requeue puts the URLs member claimed back in the queues of their hosts.
+// Those of another process are claimed by this one first, so that two
+// processes don't both requeue them
+func (cl *Cluster) requeue(member string) error {
+	ctx := context.Background()
+	from, mine := cl.key("leased", member), cl.key("leased", cl.id)
+	for {
+		var v string
+		var err error
+		if member == cl.id {
+			v, err = cl.rdb.LIndex(ctx, mine, 0).Result()
+		} else {
+			v, err = cl.rdb.LMove(ctx, from, mine, "LEFT", "RIGHT").Result()
+		}
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := cl.enqueue(v); err != nil {
+			return err
+		}
+		if err := cl.rdb.LRem(ctx, mine, 1, v).Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// enqueue queues the FrontierItem v, in JSON, for its host
+func (cl *Cluster) enqueue(v string) error {
+	var it webcrawl.FrontierItem
+	if err := json.Unmarshal([]byte(v), &it); err != nil {
+		return fmt.Errorf("cluster: bad item %q: %w", v, err)
+	}
+	ctx := context.Background()
+	host := hostname(it.URL)
+	if err := cl.rdb.RPush(ctx, cl.key("queue", host), v).Err(); err != nil {
+		return err
+	}
+	// The host is added after its URL, for claim to never miss one
+	return cl.rdb.SAdd(ctx, cl.key("hosts"), host).Err()
+}
+
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Push queues it for its host. An item this process claimed is handed
+// back, for the Crawler to fetch again later.
+func (cl *Cluster) Push(it webcrawl.FrontierItem) {
+	cl.mu.Lock()
+	_, handback := cl.claimed[it.URL]
+	taken := cl.taken[it.URL] && !handback
+	delete(cl.taken, it.URL)
+	cl.mu.Unlock()
+	if taken {
+		// Queued by the process that saw it first
+		return
+	}
+	b, err := json.Marshal(it)
+	if err != nil {
+		cl.fail(err)
+		return
+	}
+	if err := cl.enqueue(string(b)); err != nil {
+		cl.fail(err)
+		return
+	}
+	cl.mu.Lock()
+	v, ok := cl.claimed[it.URL]
+	delete(cl.claimed, it.URL)
+	cl.mu.Unlock()
+	if ok {
+		if err := cl.rdb.LRem(context.Background(), cl.key("leased", cl.id), 1, v).Err(); err != nil {
+			cl.fail(err)
+		}
+	}
+}
+
+// Pop claims the next URL of the hosts of this process. When there is
+// none and the Crawler has nothing under way, it waits for the other
+// processes to queue some, and only returns false once nothing is queued
+// nor claimed in the whole cluster.
+func (cl *Cluster) Pop() (webcrawl.FrontierItem, bool) {
+	quiet := 0
+	for {
+		if it, ok := cl.claim(); ok {
+			return it, true
+		}
+		cl.mu.Lock()
+		busy := len(cl.claimed) > 0 || cl.err != nil
+		cl.mu.Unlock()
+		// The URLs under way here may well have links to queue still
+		if busy {
+			return webcrawl.FrontierItem{}, false
+		}
+		// A process marks a URL seen before it queues it: the crawl is over
+		//   once idle twice over, not at a glance between the two
+		if !cl.idle() {
+			quiet = 0
+		} else if quiet++; quiet == 2 {
+			return webcrawl.FrontierItem{}, false
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-cl.quit:
+			return webcrawl.FrontierItem{}, false
+		}
+	}
+}
+
+// claim claims a URL off the queue of one of the hosts of this process,
+// taking them in turn
+func (cl *Cluster) claim() (webcrawl.FrontierItem, bool) {
+	ctx := context.Background()
+	var it webcrawl.FrontierItem
+	if err := cl.listOwned(); err != nil {
+		cl.fail(err)
+		return it, false
+	}
+	for {
+		cl.mu.Lock()
+		if len(cl.owned) == 0 {
+			cl.mu.Unlock()
+			return it, false
+		}
+		cl.next %= len(cl.owned)
+		host := cl.owned[cl.next]
+		cl.mu.Unlock()
+
+		v, err := cl.rdb.LMove(ctx, cl.key("queue", host), cl.key("leased", cl.id), "LEFT", "RIGHT").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			cl.fail(err)
+			return it, false
+		}
+		if err == nil {
+			if err := json.Unmarshal([]byte(v), &it); err != nil {
+				cl.fail(fmt.Errorf("cluster: bad item %q: %w", v, err))
+				cl.rdb.LRem(ctx, cl.key("leased", cl.id), 1, v)
+				continue
+			}
+			cl.mu.Lock()
+			cl.claimed[it.URL] = v
+			cl.next++
+			cl.mu.Unlock()
+			return it, true
+		}
+		// Its queue ran dry, it is off the hosts unless a URL came in
+		//   meanwhile
+		if err := cl.dropHost(host); err != nil {
+			cl.fail(err)
+			return it, false
+		}
+		cl.mu.Lock()
+		for i, h := range cl.owned {
+			if h == host {
+				cl.owned = append(cl.owned[:i], cl.owned[i+1:]...)
+				break
+			}
+		}
+		cl.mu.Unlock()
+	}
+}
+
+// dropHost takes host off the hosts with URLs queued, unless it has some
+func (cl *Cluster) dropHost(host string) error {
+	ctx := context.Background()
+	if err := cl.rdb.SRem(ctx, cl.key("hosts"), host).Err(); err != nil {
+		return err
+	}
+	n, err := cl.rdb.LLen(ctx, cl.key("queue", host)).Result()
+	if err != nil || n == 0 {
+		return err
+	}
+	return cl.rdb.SAdd(ctx, cl.key("hosts"), host).Err()
+}
+
+// listOwned lists the hosts of this process with URLs queued, when it
+// didn't since pollInterval or it ran out of them. The processes that
+// joined in the meantime take theirs over
+func (cl *Cluster) listOwned() error {
+	cl.mu.Lock()
+	fresh := len(cl.owned) > 0 && time.Since(cl.ownedAt) < pollInterval
+	cl.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	if err := cl.refresh(); err != nil {
+		return err
+	}
+	hosts, err := cl.rdb.SMembers(context.Background(), cl.key("hosts")).Result()
+	if err != nil {
+		return err
+	}
+	sort.Strings(hosts)
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.owned = cl.owned[:0]
+	for _, h := range hosts {
+		if cl.owner(h) == cl.id {
+			cl.owned = append(cl.owned, h)
+		}
+	}
+	cl.ownedAt = time.Now()
+	return nil
+}
+
+// idle reports whether the crawl is over for the cluster: no URL queued,
+// none claimed by a process alive
+func (cl *Cluster) idle() bool {
+	if err := cl.heartbeat(); err != nil {
+		cl.fail(err)
+		return true
+	}
+	ctx := context.Background()
+	n, err := cl.rdb.SCard(ctx, cl.key("hosts")).Result()
+	if err != nil {
+		cl.fail(err)
+		return true
+	}
+	if n > 0 {
+		return false
+	}
+	cl.mu.Lock()
+	members := cl.members
+	cl.mu.Unlock()
+	for _, m := range members {
+		n, err := cl.rdb.LLen(ctx, cl.key("leased", m)).Result()
+		if err != nil {
+			cl.fail(err)
+			return true
+		}
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Done tells the cluster the Crawler is done with the URL of it, which it
+// claimed, its links queued.
+func (cl *Cluster) Done(it webcrawl.FrontierItem) {
+	cl.mu.Lock()
+	v, ok := cl.claimed[it.URL]
+	delete(cl.claimed, it.URL)
+	cl.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := cl.rdb.LRem(context.Background(), cl.key("leased", cl.id), 1, v).Err(); err != nil {
+		cl.fail(err)
+	}
+}
+
+// Len returns how many URLs are queued for the hosts of this process, as
+// of a second ago at most.
+func (cl *Cluster) Len() int {
+	cl.mu.Lock()
+	if time.Since(cl.queuedAt) < time.Second {
+		defer cl.mu.Unlock()
+		return cl.queued
+	}
+	hosts := append([]string(nil), cl.owned...)
+	cl.mu.Unlock()
+	n := 0
+	for _, h := range hosts {
+		l, err := cl.rdb.LLen(context.Background(), cl.key("queue", h)).Result()
+		if err != nil {
+			cl.fail(err)
+			break
+		}
+		n += int(l)
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.queued, cl.queuedAt = n, time.Now()
+	return n
+}
+
+// Seen reports whether url was seen by any process of the cluster.
+func (cl *Cluster) Seen(url string) bool {
+	seen, err := cl.rdb.SIsMember(context.Background(), cl.key("visited"), url).Result()
+	if err != nil {
+		cl.fail(err)
+		return false
+	}
+	return seen
+}
+
+// MarkSeen records url as seen for the cluster. When another process did
+// since Seen, it is that one's to queue: the Push of url that follows is
+// dropped.
+func (cl *Cluster) MarkSeen(url string) {
+	n, err := cl.rdb.SAdd(context.Background(), cl.key("visited"), url).Result()
+	if err != nil {
+		cl.fail(err)
+		return
+	}
+	if n == 0 {
+		cl.mu.Lock()
+		cl.taken[url] = true
+		cl.mu.Unlock()
+	}
+}
+
+// Close leaves the cluster: the URLs this process claimed and didn't fetch
+// go back in their queues for the others, and its lease ends.
+func (cl *Cluster) Close() error {
+	close(cl.quit)
+	<-cl.closed
+	err := cl.requeue(cl.id)
+	if zerr := cl.rdb.ZRem(context.Background(), cl.key("members"), cl.id).Err(); err == nil {
+		err = zerr
+	}
+	if cerr := cl.rdb.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
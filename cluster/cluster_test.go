@@ -0,0 +1,233 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// openTest joins the crawl "test" on s as id, leaving it at the end of the
+// test unless it left before
+func openTest(t *testing.T, s *miniredis.Miniredis, id string, lease time.Duration) *Cluster {
+	t.Helper()
+	cl, err := Open("redis://"+s.Addr()+"/0", Options{Name: "test", ID: id, Lease: lease})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		select {
+		case <-cl.quit:
+		default:
+			cl.Close()
+		}
+	})
+	return cl
+}
+
+// kill stops cl renewing its lease, as a process killed would, and leaves
+// its claims where they are
+func kill(cl *Cluster) {
+	close(cl.quit)
+	<-cl.closed
+	cl.rdb.Close()
+}
+
+// list returns the list at key of s, nil for none
+func list(t *testing.T, s *miniredis.Miniredis, key string) []string {
+	t.Helper()
+	if !s.Exists(key) {
+		return nil
+	}
+	l, err := s.List(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestPopClaimsAndDoneAcks(t *testing.T) {
+	s := miniredis.RunT(t)
+	cl := openTest(t, s, "a", 0)
+
+	cl.Push(webcrawl.FrontierItem{URL: "http://example.com/", Depth: 0})
+	it, ok := cl.Pop()
+	if !ok || it.URL != "http://example.com/" {
+		t.Fatalf("Pop = %+v, %v, want http://example.com/", it, ok)
+	}
+	if l := list(t, s, "test:queue:example.com"); len(l) != 0 {
+		t.Errorf("queue = %q after Pop, want it empty", l)
+	}
+	if l := list(t, s, "test:leased:a"); len(l) != 1 {
+		t.Errorf("leased = %q after Pop, want the URL claimed", l)
+	}
+
+	// Claimed here, the crawl is not over: Pop doesn't wait
+	if it, ok := cl.Pop(); ok {
+		t.Errorf("Pop = %+v with nothing queued", it)
+	}
+	cl.Done(it)
+	if l := list(t, s, "test:leased:a"); len(l) != 0 {
+		t.Errorf("leased = %q after Done, want it empty", l)
+	}
+	if it, ok := cl.Pop(); ok {
+		t.Errorf("Pop = %+v once the crawl is over", it)
+	}
+	if err := cl.Err(); err != nil {
+		t.Errorf("Err = %v", err)
+	}
+}
+
+func TestPushHandsBack(t *testing.T) {
+	s := miniredis.RunT(t)
+	cl := openTest(t, s, "a", 0)
+
+	cl.Push(webcrawl.FrontierItem{URL: "http://example.com/"})
+	it, ok := cl.Pop()
+	if !ok {
+		t.Fatal("Pop found nothing")
+	}
+	// An item claimed and pushed again goes back in the queue, its claim let go
+	cl.Push(it)
+	if l := list(t, s, "test:leased:a"); len(l) != 0 {
+		t.Errorf("leased = %q after Push, want it empty", l)
+	}
+	if l := list(t, s, "test:queue:example.com"); len(l) != 1 {
+		t.Errorf("queue = %q after Push, want the URL back", l)
+	}
+	if again, ok := cl.Pop(); !ok || again.URL != it.URL {
+		t.Errorf("Pop = %+v, %v, want %s again", again, ok, it.URL)
+	}
+}
+
+func TestLeaseEndsRequeues(t *testing.T) {
+	s := miniredis.RunT(t)
+	lease := 300 * time.Millisecond
+	a := openTest(t, s, "a", lease)
+	a.Push(webcrawl.FrontierItem{URL: "http://example.com/"})
+	it, ok := a.Pop()
+	if !ok {
+		t.Fatal("Pop found nothing")
+	}
+	kill(a)
+
+	// Alive, b leaves the claims of a be
+	b := openTest(t, s, "b", lease)
+	if l := list(t, s, "test:leased:a"); len(l) != 1 {
+		t.Fatalf("leased by a = %q before its lease ended, want its claim", l)
+	}
+
+	time.Sleep(lease + 50*time.Millisecond)
+	got, ok := b.Pop()
+	if !ok || got.URL != it.URL {
+		t.Fatalf("Pop of b = %+v, %v, want %s that a claimed", got, ok, it.URL)
+	}
+	if l := list(t, s, "test:leased:a"); len(l) != 0 {
+		t.Errorf("leased by a = %q after its lease ended, want it empty", l)
+	}
+	if l := list(t, s, "test:leased:b"); len(l) != 1 {
+		t.Errorf("leased by b = %q, want the URL it took over", l)
+	}
+	if members, _ := s.ZMembers("test:members"); len(members) != 1 || members[0] != "b" {
+		t.Errorf("members = %q, want a gone", members)
+	}
+}
+
+func TestCloseRequeues(t *testing.T) {
+	s := miniredis.RunT(t)
+	a := openTest(t, s, "a", 0)
+	a.Push(webcrawl.FrontierItem{URL: "http://example.com/"})
+	it, ok := a.Pop()
+	if !ok {
+		t.Fatal("Pop found nothing")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if l := list(t, s, "test:leased:a"); len(l) != 0 {
+		t.Errorf("leased by a = %q after Close, want it empty", l)
+	}
+
+	b := openTest(t, s, "b", 0)
+	if got, ok := b.Pop(); !ok || got.URL != it.URL {
+		t.Errorf("Pop of b = %+v, %v, want %s that a left", got, ok, it.URL)
+	}
+}
+
+func TestMarkSeen(t *testing.T) {
+	s := miniredis.RunT(t)
+	a := openTest(t, s, "a", 0)
+	b := openTest(t, s, "b", 0)
+
+	const u = "http://example.com/"
+	if a.Seen(u) || b.Seen(u) {
+		t.Fatal("seen before MarkSeen")
+	}
+	a.MarkSeen(u)
+	b.MarkSeen(u)
+	if !b.Seen(u) {
+		t.Error("not seen by b after MarkSeen of a")
+	}
+	// Marked seen by a first, it is a's to queue
+	b.Push(webcrawl.FrontierItem{URL: u})
+	a.Push(webcrawl.FrontierItem{URL: u})
+	if l := list(t, s, "test:queue:example.com"); len(l) != 1 {
+		t.Errorf("queue = %q, want the URL once", l)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	if _, err := Open("http://localhost:6379", Options{}); err == nil {
+		t.Error("Open of an http:// URL: no error")
+	}
+	s := miniredis.RunT(t)
+	addr := s.Addr()
+	s.Close()
+	if _, err := Open("redis://"+addr, Options{}); err == nil {
+		t.Error("Open with no server: no error")
+	}
+}
+
+func TestCrawlShared(t *testing.T) {
+	s := miniredis.RunT(t)
+	site := testsite.Generate("http://site.test", testsite.Options{Pages: 30, BackLinks: true, CrossLinks: 2, Seed: 1})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	for _, id := range []string{"a", "b"} {
+		cl := openTest(t, s, id, 0)
+		c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithFrontier(cl), webcrawl.WithVisited(cl),
+			webcrawl.WithoutRobots(), webcrawl.WithDepth(webcrawl.UnlimitedDepth),
+			webcrawl.WithOnResult(func(res webcrawl.CrawlResult) {
+				mu.Lock()
+				fetched[res.URL]++
+				mu.Unlock()
+			}))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := c.Run(ctx, site.URL("/")); err != nil {
+				t.Errorf("Run of %s: %v", cl.ID(), err)
+			}
+			if err := cl.Err(); err != nil {
+				t.Errorf("Err of %s: %v", cl.ID(), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if want := len(site.Reachable(-1)); len(fetched) != want {
+		t.Errorf("%d URLs fetched, want %d", len(fetched), want)
+	}
+	if _, twice := site.TotalFetches(); len(twice) > 0 {
+		t.Errorf("fetched twice: %q", twice)
+	}
+}
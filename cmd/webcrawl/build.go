@@ -0,0 +1,821 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/bucket"
+	"github.com/jackyugit/webcrawl/cluster"
+	"github.com/jackyugit/webcrawl/search"
+	"github.com/jackyugit/webcrawl/shard"
+)
+
+// badFlags is an error of buildCrawler due to the flags themselves, on
+// which crawl exits with status 2 rather than 1
+type badFlags struct{ error }
+
+func (e badFlags) Unwrap() error { return e.error }
+
+// crawlJob is the crawler of a crawlConfig, along with the reports it
+// feeds and what is to be closed once it is done
+type crawlJob struct {
+	cfg   *crawlConfig
+	c     *webcrawl.Crawler
+	seeds []string // the seeds to crawl, the first one with the fetchers
+	//   of a local directory set to file:///
+
+	recorder   *webcrawl.RecordingFetcher
+	steer      webcrawl.Script
+	outbound   *webcrawl.OutboundReport
+	links      *webcrawl.LinkReport
+	sitemap    *webcrawl.SitemapBuilder
+	graph      *webcrawl.LinkGraph
+	mirror     *webcrawl.Mirror
+	weights    *webcrawl.AssetReport
+	hreflang   *webcrawl.HreflangReport
+	canonicals *webcrawl.CanonicalReport
+	audit      *webcrawl.SecurityReport
+	a11y       *webcrawl.AccessibilityReport
+	broken     *webcrawl.BrokenLinkReport
+	dups       *webcrawl.DuplicateReport
+	sums       *webcrawl.SummaryReport
+	snap       *webcrawl.Snapshot
+	seo        *webcrawl.SEOReport
+	wh         *webcrawl.Webhook
+	har        *webcrawl.HARLog
+	sf         *webcrawl.SpillFrontier
+	cl         *cluster.Cluster
+	sw         *shard.Worker
+	tracer     *webcrawl.OTLPTracer
+	heat       struct {
+		sync.Mutex
+		results []webcrawl.CrawlResult
+	}
+
+	// closers are called by close, last first, whether the crawl went
+	// well or not
+	closers []func() error
+}
+
+// buildCrawler sets up the crawler of cfg, its output written to out, and
+// everything it feeds. The errors due to the flags are badFlags. The
+// crawlJob is to be closed once done with, whatever happened.
+func buildCrawler(cfg *crawlConfig, out io.Writer) (_ *crawlJob, err error) {
+	if len(cfg.seeds) == 0 && len(cfg.seedSitemaps) == 0 {
+		return nil, badFlags{errors.New("no url to crawl")}
+	}
+	if cfg.resume && cfg.state == "" {
+		return nil, badFlags{errors.New("resume needs -state")}
+	}
+	j := &crawlJob{cfg: cfg, seeds: cfg.seeds}
+	defer func() {
+		if err != nil {
+			j.close()
+		}
+	}()
+	c := &webcrawl.Crawler{
+		MaxDepth:   cfg.depth,
+		MaxWorkers: cfg.workers,
+		Sequential: cfg.sequential,
+
+		MaxPages:        cfg.maxPages,
+		MaxPagesPerHost: cfg.maxHostPages,
+		MaxBytes:        cfg.maxBytes,
+		MinBodyBytes:    cfg.minBody,
+
+		MaxInFlightPerHost: cfg.hostInFlight,
+
+		UserAgent:    cfg.userAgent,
+		IgnoreRobots: cfg.ignoreRobots,
+		DryRun:       cfg.dryRun,
+		UseSitemaps:  cfg.sitemaps,
+		FollowFeeds:  cfg.feeds,
+
+		CheckDocuments: cfg.documents,
+		GracePeriod:    cfg.grace,
+	}
+	j.c = c
+	subdomains, err := parseSubdomains(cfg.scope)
+	if err != nil {
+		return nil, badFlags{err}
+	}
+	if c.Normalizer, err = newNormalizer(cfg.queryRules); err != nil {
+		return nil, badFlags{err}
+	}
+	if c.Logger, err = newLogger(cfg.logLevel, cfg.logFormat); err != nil {
+		return nil, badFlags{err}
+	}
+	if c.OnResult, err = newOutput(out, cfg.format, cfg.fields); err != nil {
+		return nil, badFlags{err}
+	}
+	if cfg.progress && cfg.output == "" && cfg.format == "text" {
+		// The dashboard stands in for the pages found
+		c.OnResult = func(webcrawl.CrawlResult) {}
+	}
+	c.Scope = &webcrawl.ScopeRules{Subdomains: subdomains}
+	if cfg.languages != "" {
+		c.Scope.Languages = strings.Split(cfg.languages, ",")
+	}
+	c.DetectLanguage = cfg.detectLanguage
+	for _, g := range cfg.include {
+		c.Scope.Include = append(c.Scope.Include, webcrawl.Glob(g))
+	}
+	for _, g := range cfg.exclude {
+		c.Scope.Exclude = append(c.Scope.Exclude, webcrawl.Glob(g))
+	}
+	if c.Filter, err = newFilter(cfg.filters); err != nil {
+		return nil, badFlags{err}
+	}
+	if c.HostMaxInFlight, err = parseHostCaps(cfg.hostInFlightFor); err != nil {
+		return nil, badFlags{err}
+	}
+	if c.DepthRules, err = parseDepthRules(cfg.depthFor); err != nil {
+		return nil, badFlags{err}
+	}
+	if c.DepthOverrides, err = parseDepthCaps(cfg.depthCap); err != nil {
+		return nil, badFlags{err}
+	}
+	if cfg.requeue > 0 {
+		p := webcrawl.DefaultRetryPolicy
+		p.MaxAttempts = cfg.requeue + 1
+		c.Retry = &p
+	}
+	rates, err := parseHostRates(cfg.hostRates)
+	if err != nil {
+		return nil, badFlags{err}
+	}
+	if cfg.rps > 0 || cfg.delay > 0 || rates != nil {
+		c.RateLimit = webcrawl.NewHostLimiter(cfg.rps, 1, cfg.delay)
+		c.RateLimit.Hosts = rates
+	}
+	if c.Schedule, err = parseSchedule(cfg.windows); err != nil {
+		return nil, badFlags{err}
+	}
+	backoff := webcrawl.DefaultBackoffPolicy
+	backoff.MaxDelay = cfg.maxBackoff
+	if cfg.maxBackoff <= 0 {
+		backoff.MaxRetries = -1
+	}
+	c.Backoff = &backoff
+	if cfg.adaptive {
+		c.Adaptive = &webcrawl.AdaptiveConcurrency{Latency: cfg.adaptiveLatency}
+	}
+	if cfg.breaker > 0 {
+		c.Breaker = &webcrawl.CircuitBreaker{Failures: cfg.breaker, Cooldown: cfg.cooldown, MaxTrips: webcrawl.DefaultCircuitBreaker.MaxTrips}
+	}
+	if err := j.buildFetcher(); err != nil {
+		return nil, err
+	}
+	if cfg.maxPathDepth > 0 || cfg.maxRepeated > 0 || cfg.maxQueries > 0 || cfg.maxDirURLs > 0 {
+		c.Traps = &webcrawl.TrapRules{MaxPathDepth: cfg.maxPathDepth, MaxRepeatedSegments: cfg.maxRepeated,
+			MaxQueryVariants: cfg.maxQueries, MaxDirectoryURLs: cfg.maxDirURLs}
+	}
+	if cfg.documentCommand != "" {
+		c.DocumentExtractor = &webcrawl.CommandExtractor{Command: strings.Fields(cfg.documentCommand)}
+	}
+	if cfg.mergeWWW || cfg.upgradeHTTPS {
+		c.Aliases = &webcrawl.HostAliases{MergeWWW: cfg.mergeWWW, UpgradeHTTPS: cfg.upgradeHTTPS}
+	}
+	if cfg.maxURLLength > 0 || cfg.maxQueryParams > 0 {
+		c.Guards = &webcrawl.URLGuards{MaxLength: cfg.maxURLLength, MaxQueryParams: cfg.maxQueryParams}
+	}
+	c.StructuredData = cfg.structured
+	if cfg.soft404 {
+		c.Soft404 = &webcrawl.Soft404Detector{}
+	}
+	c.ReadableText = cfg.text
+	if cfg.sampleBodies > 1 || cfg.bodyBytes > 0 || cfg.metadataOnly {
+		c.Bodies = &webcrawl.BodySampling{Every: cfg.sampleBodies, MaxBytes: cfg.bodyBytes, MetadataOnly: cfg.metadataOnly}
+	}
+	if len(cfg.scrape) > 0 {
+		if c.Scraper, err = newScraper(cfg.scrape); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.match) > 0 {
+		if c.ContentRules, err = newContentRules(cfg.match); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.script != "" {
+		if j.steer, err = webcrawl.OpenScript(cfg.script); err != nil {
+			return nil, err
+		}
+		if s, ok := j.steer.(*webcrawl.StarlarkScript); ok {
+			s.MaxSteps, s.Timeout = cfg.scriptSteps, cfg.scriptTimeout
+		}
+		c.Hooks = webcrawl.ScriptHooks(j.steer, func(err error) { fmt.Fprintln(os.Stderr, "webcrawl:", err) })
+	}
+	j.addReports()
+	if err := j.addSinks(); err != nil {
+		return nil, err
+	}
+	if cfg.state != "" {
+		if c.Journal, err = webcrawl.OpenJournal(cfg.state); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.bloom > 0 {
+		c.Visited = webcrawl.NewBloomVisitedSet(cfg.bloom, cfg.bloomFP)
+	}
+	if cfg.spill != "" {
+		if j.sf, err = webcrawl.NewSpillFrontier(cfg.spill, cfg.spillMemory); err != nil {
+			return nil, err
+		}
+		c.Frontier = j.sf
+	}
+	if cfg.redisURL != "" {
+		if j.cl, err = cluster.Open(cfg.redisURL, cluster.Options{Name: cfg.redisName}); err != nil {
+			return nil, err
+		}
+		c.Frontier, c.Visited = j.cl, j.cl
+	}
+	if cfg.coordinator != "" {
+		if j.cl != nil {
+			return nil, badFlags{errors.New("-coordinator and -redis don't go together")}
+		}
+		j.sw = shard.NewWorker(cfg.coordinator, shard.WorkerOptions{})
+		c.Frontier = j.sw
+		j.chain(j.sw.Send)
+	}
+	if cfg.metrics != "" {
+		c.Metrics = &webcrawl.Metrics{}
+	}
+	if cfg.otlp != "" {
+		if j.tracer, err = webcrawl.NewOTLPTracer(cfg.otlp, "webcrawl"); err != nil {
+			return nil, err
+		}
+		c.Tracer = j.tracer
+	}
+	if cfg.progress {
+		c.Progress = &webcrawl.Progress{}
+	}
+	return j, nil
+}
+
+// buildFetcher sets up the fetcher of the crawl, and the seeds of
+// -seeds-sitemap
+func (j *crawlJob) buildFetcher() error {
+	cfg, c := j.cfg, j.c
+	timeoutRules, err := parseTimeoutRules(cfg.timeoutFor)
+	if err != nil {
+		return badFlags{err}
+	}
+	opts := webcrawl.HTTPOptions{
+		Timeout:            cfg.timeout,
+		TimeoutOverrides:   timeoutRules,
+		ConnectTimeout:     cfg.connectTimeout,
+		ReadTimeout:        cfg.readTimeout,
+		CAFile:             cfg.caFile,
+		CertFile:           cfg.certFile,
+		KeyFile:            cfg.keyFile,
+		InsecureSkipVerify: cfg.insecure,
+		Proxy:              cfg.proxy,
+		MaxConnsPerHost:    cfg.maxConns,
+		HTTP1:              cfg.http1,
+		HTTP1Hosts:         cfg.http1Hosts,
+		HTTP3:              cfg.http3,
+		UserAgent:          cfg.userAgent,
+	}
+	if opts.DNS, err = newDNSCache(cfg.dnsCache, cfg.dnsServers, cfg.resolve); err != nil {
+		return badFlags{err}
+	}
+	if opts.Header, opts.HostHeader, err = parseHeaders(cfg.headers); err != nil {
+		return badFlags{err}
+	}
+	if cfg.loginURL != "" && cfg.cookies == "" {
+		opts.Jar = webcrawl.NewCookieJar()
+	}
+	if cfg.cookies != "" {
+		jar, err := webcrawl.LoadCookieJar(cfg.cookies)
+		if err != nil {
+			return err
+		}
+		j.closers = append(j.closers, func() error { return jar.Save(cfg.cookies) })
+		opts.Jar = jar
+	}
+	if proxies := strings.Split(cfg.proxy, ","); len(proxies) > 1 {
+		if opts.Proxies, err = newProxyPool(cfg.rotation, proxies); err != nil {
+			return badFlags{err}
+		}
+		opts.Proxy = ""
+	}
+	hf, err := webcrawl.NewHTTPFetcherOptions(opts)
+	if err != nil {
+		return badFlags{err}
+	}
+	var pages *webcrawl.PageCache
+	switch {
+	case cfg.offline && cfg.pageCache == "":
+		return badFlags{errors.New("-offline needs a -page-cache")}
+	case cfg.offline && (cfg.record != "" || cfg.replay != ""):
+		return badFlags{errors.New("-offline doesn't go with -record and -replay")}
+	case cfg.record != "" && cfg.replay != "":
+		return badFlags{errors.New("-record and -replay don't go together")}
+	case cfg.pageCache != "":
+		if pages, err = webcrawl.OpenPageCache(cfg.pageCache); err != nil {
+			return err
+		}
+	}
+	if len(cfg.seedSitemaps) > 0 {
+		var sf webcrawl.Fetcher = hf
+		if cfg.offline {
+			sf = pages
+		}
+		urls, err := webcrawl.LoadSitemaps(context.Background(), sf, cfg.seedSitemaps, 0)
+		if err != nil {
+			return err
+		}
+		j.seeds = append([]string(nil), j.seeds...)
+		for _, u := range urls {
+			j.seeds = append(j.seeds, u.Loc)
+		}
+		if len(j.seeds) == 0 {
+			return errors.New("no URL in the -seeds-sitemap")
+		}
+	}
+	seed := j.seeds[0]
+	if hf.Auth, err = newAuth(seed, cfg.basicAuth, cfg.bearer, cfg.loginURL, cfg.loginFields); err != nil {
+		return badFlags{err}
+	}
+	if len(cfg.acceptTypes) > 0 || len(cfg.rejectTypes) > 0 || cfg.maxSize > 0 {
+		hf.Filter = &webcrawl.ContentFilter{Accept: cfg.acceptTypes, Reject: cfg.rejectTypes, MaxLength: cfg.maxSize}
+		hf.HeadFirst = cfg.headFirst
+	}
+	hf.MaxBodyBytes = cfg.maxBody
+	if cfg.bandwidth > 0 || cfg.hostBandwidth > 0 || len(cfg.hostBandwidthFor) > 0 {
+		hosts, err := parseHostBandwidth(cfg.hostBandwidthFor)
+		if err != nil {
+			return badFlags{err}
+		}
+		hf.Bandwidth = &webcrawl.BandwidthLimiter{BytesPerSecond: cfg.bandwidth, HostBytesPerSecond: cfg.hostBandwidth, Hosts: hosts}
+	}
+	hf.MaxRedirects = cfg.maxRedirects
+	if hf.MaxRedirects == 0 {
+		hf.MaxRedirects = -1
+	}
+	if cfg.cache != "" {
+		if hf.Cache, err = webcrawl.LoadConditionalCache(cfg.cache); err != nil {
+			return err
+		}
+		j.closers = append(j.closers, func() error { return hf.Cache.Save(cfg.cache) })
+	}
+	if cfg.warc != "" {
+		f, err := os.Create(cfg.warc)
+		if err != nil {
+			return err
+		}
+		j.closers = append(j.closers, f.Close)
+		w := webcrawl.NewWARCWriter(f, strings.HasSuffix(cfg.warc, ".gz"))
+		hf.Client.Transport = &webcrawl.WARCTransport{Transport: hf.Client.Transport, WARC: w}
+	}
+	c.Fetcher = hf
+	if cfg.retries > 0 {
+		p := webcrawl.DefaultRetryPolicy
+		p.MaxAttempts = cfg.retries + 1
+		c.Fetcher = webcrawl.WithRetry(c.Fetcher, p)
+	}
+	switch {
+	case cfg.record != "":
+		if j.recorder, err = webcrawl.NewRecordingFetcher(c.Fetcher, cfg.record); err != nil {
+			return err
+		}
+		c.Fetcher = j.recorder
+	case cfg.replay != "":
+		if c.Fetcher, err = webcrawl.NewReplayFetcher(cfg.replay); err != nil {
+			return err
+		}
+	}
+	switch {
+	case cfg.offline:
+		c.Fetcher = pages
+	case pages != nil:
+		c.Middleware = append(c.Middleware, webcrawl.PageCacheMiddleware(pages))
+	}
+	// The http links of a local site are there for -broken-links
+	if fi, err := os.Stat(seed); err == nil && fi.IsDir() {
+		c.Fetchers = map[string]webcrawl.Fetcher{"file": &webcrawl.FileFetcher{Root: seed}}
+		j.seeds = append([]string{"file:///"}, j.seeds[1:]...)
+	} else if strings.HasPrefix(seed, "file:") {
+		c.Fetchers = map[string]webcrawl.Fetcher{"file": &webcrawl.FileFetcher{}}
+	}
+	return nil
+}
+
+// chain has add called with every result, ahead of the OnResult of the
+// crawler so far
+func (j *crawlJob) chain(add func(webcrawl.CrawlResult)) {
+	next := j.c.OnResult
+	j.c.OnResult = func(r webcrawl.CrawlResult) {
+		add(r)
+		next(r)
+	}
+}
+
+// addReports sets up the reports of the flags, fed the results
+func (j *crawlJob) addReports() {
+	cfg, c := j.cfg, j.c
+	if cfg.outboundCSV != "" {
+		c.CheckExternal = c.CheckExternal || cfg.checkOutbound
+		j.outbound = &webcrawl.OutboundReport{Seed: j.seeds[0], Scope: c.Scope, Normalizer: c.Normalizer}
+		j.chain(j.outbound.Add)
+	}
+	if cfg.heatmapCSV != "" {
+		j.chain(func(r webcrawl.CrawlResult) {
+			j.heat.Lock()
+			j.heat.results = append(j.heat.results, webcrawl.CrawlResult{URL: r.URL, Err: r.Err, Duration: r.Duration})
+			j.heat.Unlock()
+		})
+	}
+	if cfg.linksCSV != "" {
+		j.links = &webcrawl.LinkReport{}
+		j.chain(j.links.Add)
+	}
+	if cfg.sitemapOut != "" {
+		j.sitemap = &webcrawl.SitemapBuilder{Normalizer: c.Normalizer}
+		j.chain(j.sitemap.Add)
+	}
+	if cfg.linksDOT != "" || cfg.linksGraphML != "" || cfg.ranks {
+		j.graph = &webcrawl.LinkGraph{Normalizer: c.Normalizer}
+		if cfg.linksDOT != "" || cfg.linksGraphML != "" {
+			// The files tell the anchor text and region of the links
+			j.graph.Links = &webcrawl.LinkExtractor{}
+		}
+		j.chain(j.graph.Add)
+	}
+	if cfg.mirrorDir != "" {
+		c.FetchAssets = true
+		j.mirror = &webcrawl.Mirror{Dir: cfg.mirrorDir, Normalizer: c.Normalizer}
+		j.chain(func(r webcrawl.CrawlResult) {
+			if err := j.mirror.Add(r); err != nil {
+				c.Logger.Error("mirroring failed", "url", r.URL, "err", err)
+			}
+		})
+	}
+	if cfg.assets {
+		c.FetchAssets, c.CheckAssets = true, cfg.checkAssets
+		j.weights = &webcrawl.AssetReport{Normalizer: c.Normalizer}
+		j.chain(j.weights.Add)
+	}
+	if cfg.hreflangReport {
+		j.hreflang = &webcrawl.HreflangReport{Normalizer: c.Normalizer}
+		j.chain(j.hreflang.Add)
+	}
+	c.FollowCanonical = cfg.followCanonical
+	if cfg.canonicalReport {
+		j.canonicals = &webcrawl.CanonicalReport{Normalizer: c.Normalizer}
+		j.chain(j.canonicals.Add)
+	}
+	if cfg.security {
+		j.audit = &webcrawl.SecurityReport{}
+		j.chain(j.audit.Add)
+	}
+	if cfg.accessibility {
+		j.a11y = &webcrawl.AccessibilityReport{}
+		j.chain(j.a11y.Add)
+	}
+	if cfg.brokenLinks {
+		c.CheckExternal = true
+		j.broken = &webcrawl.BrokenLinkReport{}
+		j.chain(j.broken.Add)
+	}
+	if cfg.duplicates {
+		c.DedupContent = true
+		j.dups = &webcrawl.DuplicateReport{Near: cfg.nearDuplicates >= 0}
+		j.chain(j.dups.Add)
+	}
+	if cfg.summary || cfg.summaryJSON != "" {
+		j.sums = &webcrawl.SummaryReport{}
+		j.chain(j.sums.Add)
+	}
+	if cfg.snapshot != "" {
+		j.snap = &webcrawl.Snapshot{Taken: time.Now()}
+		j.chain(j.snap.Add)
+	}
+	if cfg.seoFile != "" {
+		j.seo = &webcrawl.SEOReport{}
+		j.chain(j.seo.Add)
+	}
+}
+
+// addSinks sets up where the flags send the results to: the index, the
+// database, the bucket, the stream, the webhook and the HAR log
+func (j *crawlJob) addSinks() error {
+	cfg, c := j.cfg, j.c
+	if cfg.indexDir != "" {
+		idx, err := search.Open(cfg.indexDir)
+		if err != nil {
+			return err
+		}
+		j.closers = append(j.closers, idx.Close)
+		j.chain(func(r webcrawl.CrawlResult) {
+			if err := idx.Add(r); err != nil {
+				c.Logger.Error("indexing failed", "url", r.URL, "err", err)
+			}
+		})
+	}
+	if cfg.dbFlag != "" {
+		db, err := openStore(cfg.dbFlag)
+		if err != nil {
+			return err
+		}
+		j.closers = append(j.closers, db.Close)
+		run, err := db.StartRun(context.Background(), j.seeds[0])
+		if err != nil {
+			return err
+		}
+		j.closers = append(j.closers, run.Finish)
+		j.chain(func(r webcrawl.CrawlResult) {
+			if err := run.Add(r); err != nil {
+				c.Logger.Error("saving failed", "url", r.URL, "err", err)
+			}
+		})
+	}
+	if cfg.bucketURL != "" {
+		b, err := bucket.Parse(cfg.bucketURL)
+		if err != nil {
+			return err
+		}
+		sink := &bucket.Sink{Bucket: b}
+		j.closers = append(j.closers, func() error { return sink.Close(context.Background()) })
+		j.chain(func(r webcrawl.CrawlResult) {
+			if err := sink.Add(context.Background(), r); err != nil {
+				c.Logger.Error("upload failed", "url", r.URL, "err", err)
+			}
+		})
+	}
+	if cfg.publish != "" {
+		sink, err := newStreamSink(cfg.publish, cfg.publishFormat)
+		if err != nil {
+			return err
+		}
+		if cfg.fields != "" {
+			sink.Fields = strings.Split(cfg.fields, ",")
+		}
+		if _, err := webcrawl.MarshalResult(webcrawl.CrawlResult{}, sink.Fields...); err != nil {
+			sink.Close()
+			return badFlags{err}
+		}
+		j.closers = append(j.closers, sink.Close)
+		j.chain(func(r webcrawl.CrawlResult) {
+			if err := sink.Add(context.Background(), r); err != nil {
+				c.Logger.Error("publishing failed", "url", r.URL, "err", err)
+			}
+		})
+	}
+	if cfg.webhookURL != "" {
+		j.wh = &webcrawl.Webhook{
+			URL:        cfg.webhookURL,
+			Secret:     cfg.webhookSecret,
+			ErrorRate:  cfg.webhookErrorRate,
+			MinResults: 10,
+		}
+		if cfg.webhookBatch > 0 {
+			j.wh.Events = []string{webcrawl.EventStarted, webcrawl.EventFinished, webcrawl.EventErrorRate, webcrawl.EventResults}
+			j.wh.BatchSize = cfg.webhookBatch
+		}
+		j.chain(j.wh.Add)
+	}
+	if cfg.harFile != "" {
+		j.har = &webcrawl.HARLog{Bodies: cfg.harBodies}
+		j.chain(j.har.Add)
+	}
+	return nil
+}
+
+// listen serves the -metrics, -debug and -control endpoints, the errors
+// logged to the standard error
+func (j *crawlJob) listen() {
+	serve := func(name, addr string, h http.Handler) {
+		go func() {
+			if err := http.ListenAndServe(addr, h); err != nil {
+				fmt.Fprintf(os.Stderr, "webcrawl: %s: %v\n", name, err)
+			}
+		}()
+	}
+	if j.cfg.metrics != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", j.c.Metrics)
+		serve("metrics", j.cfg.metrics, mux)
+	}
+	if j.cfg.debugAddr != "" {
+		serve("debug", j.cfg.debugAddr, j.c.DebugHandler())
+	}
+	if j.cfg.controlAddr != "" {
+		serve("control", j.cfg.controlAddr, j.c.ControlHandler())
+	}
+}
+
+// run crawls the seeds, or resumes the crawl of the journal with resume,
+// telling the webhook and showing the dashboard of -progress meanwhile
+func (j *crawlJob) run(ctx context.Context) error {
+	c := j.c
+	if j.wh != nil {
+		if err := j.wh.Start(ctx, j.seeds[0]); err != nil {
+			c.Logger.Error("webhook failed", "event", webcrawl.EventStarted, "err", err)
+		}
+	}
+	var dash *dashboard
+	if c.Progress != nil {
+		dash = startDashboard(c.Progress, os.Stderr, 500*time.Millisecond)
+	}
+	var err error
+	if j.cfg.resume {
+		err = c.Resume(ctx)
+	} else {
+		err = c.RunSeeds(ctx, j.seeds...)
+	}
+	if dash != nil {
+		dash.stop()
+	}
+	if j.wh != nil {
+		if werr := j.wh.Finish(context.Background(), err); werr != nil {
+			c.Logger.Error("webhook failed", "err", werr)
+		}
+	}
+	return err
+}
+
+// finish writes the reports of a crawl that ended with err, those listed
+// at the end to out, and closes what has to be for the crawl to be
+// complete, stopping at the first failure
+func (j *crawlJob) finish(ctx context.Context, out io.Writer, err error) error {
+	cfg, c := j.cfg, j.c
+	interrupted := errors.Is(err, context.Canceled)
+	if j.broken != nil {
+		fmt.Fprintln(out, "broken links:")
+		j.broken.WriteText(out)
+	}
+	if j.weights != nil {
+		fmt.Fprintln(out, "page weights:")
+		j.weights.WriteText(out)
+	}
+	if c.Traps != nil && len(c.Traps.Traps()) > 0 {
+		fmt.Fprintln(out, "crawl traps:")
+		c.Traps.WriteText(out)
+	}
+	if c.Guards != nil && len(c.Guards.Counts()) > 0 {
+		fmt.Fprintln(out, "rejected urls:")
+		c.Guards.WriteText(out)
+	}
+	if j.hreflang != nil {
+		if !interrupted {
+			j.hreflang.Fetch(ctx, c.Fetcher)
+		}
+		fmt.Fprintln(out, "hreflang issues:")
+		j.hreflang.WriteText(out)
+	}
+	if j.canonicals != nil {
+		if !interrupted {
+			j.canonicals.Fetch(ctx, c.Fetcher)
+		}
+		fmt.Fprintln(out, "canonical issues:")
+		j.canonicals.WriteText(out)
+	}
+	if j.audit != nil {
+		fmt.Fprintln(out, "security issues:")
+		j.audit.WriteText(out)
+	}
+	if j.a11y != nil {
+		fmt.Fprintln(out, "accessibility issues:")
+		j.a11y.WriteText(out)
+	}
+	if j.dups != nil {
+		fmt.Fprintln(out, "duplicate pages:")
+		clusters := j.dups.Clusters()
+		if j.dups.Near {
+			clusters = j.dups.NearClusters(cfg.nearDuplicates)
+		}
+		webcrawl.WriteClusters(out, clusters)
+	}
+	if cfg.ranks {
+		fmt.Fprintln(out, "page ranks:")
+		j.graph.WriteRanks(out)
+	}
+	if cfg.summary {
+		fmt.Fprintln(out, "crawl summary:")
+		j.sums.WriteText(out)
+	}
+	if j.links != nil {
+		if err := writeFile(cfg.linksCSV, j.links.WriteCSV); err != nil {
+			return err
+		}
+	}
+	if j.outbound != nil {
+		if err := writeFile(cfg.outboundCSV, j.outbound.WriteCSV); err != nil {
+			return err
+		}
+	}
+	if cfg.heatmapCSV != "" {
+		heatmap := webcrawl.DomainHeatMap(j.heat.results)
+		if err := writeFile(cfg.heatmapCSV, func(w io.Writer) error { return webcrawl.WriteDomainHeatMap(heatmap, w) }); err != nil {
+			return err
+		}
+	}
+	if cfg.linksDOT != "" {
+		if err := writeFile(cfg.linksDOT, j.graph.WriteDOT); err != nil {
+			return err
+		}
+	}
+	if cfg.linksGraphML != "" {
+		if err := writeFile(cfg.linksGraphML, j.graph.WriteGraphML); err != nil {
+			return err
+		}
+	}
+	if j.sitemap != nil {
+		if _, err := j.sitemap.WriteFiles(cfg.sitemapOut, cfg.sitemapBase); err != nil {
+			return err
+		}
+	}
+	if cfg.summaryJSON != "" {
+		if err := writeFile(cfg.summaryJSON, j.sums.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if j.snap != nil {
+		if err := j.snap.Save(cfg.snapshot); err != nil {
+			return err
+		}
+	}
+	if j.seo != nil {
+		if err := writeFile(cfg.seoFile, j.seo.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if j.har != nil {
+		if err := writeFile(cfg.harFile, j.har.WriteHAR); err != nil {
+			return err
+		}
+	}
+	if j.mirror != nil {
+		if err := j.mirror.Close(); err != nil {
+			return err
+		}
+	}
+	if j.sf != nil {
+		serr := j.sf.Err()
+		if cerr := j.sf.Close(); serr == nil {
+			serr = cerr
+		}
+		if serr != nil {
+			return serr
+		}
+	}
+	if j.cl != nil {
+		cerr := j.cl.Err()
+		if werr := j.cl.Close(); cerr == nil {
+			cerr = werr
+		}
+		if cerr != nil {
+			return cerr
+		}
+	}
+	if j.sw != nil {
+		if err := j.sw.Close(); err != nil {
+			return err
+		}
+	}
+	if j.steer != nil {
+		if err := j.steer.Close(); err != nil {
+			return err
+		}
+	}
+	if j.recorder != nil {
+		if err := j.recorder.Close(); err != nil {
+			return err
+		}
+	}
+	if j.tracer != nil {
+		if err := j.tracer.Close(); err != nil {
+			return err
+		}
+	}
+	if c.Journal != nil {
+		if err := c.Journal.Close(); err != nil {
+			return err
+		}
+		if interrupted {
+			fmt.Fprintf(os.Stderr, "webcrawl: progress saved, resume with webcrawl resume -state %s\n", cfg.state)
+		}
+	}
+	return nil
+}
+
+// close calls the closers, last first, logging their errors to the
+// standard error
+func (j *crawlJob) close() {
+	for i := len(j.closers) - 1; i >= 0; i-- {
+		if err := j.closers[i](); err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		}
+	}
+	j.closers = nil
+}
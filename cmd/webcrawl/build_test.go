@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// parseCrawl returns the crawlConfig of the crawl command line args
+func parseCrawl(t *testing.T, args ...string) *crawlConfig {
+	t.Helper()
+	cfg := &crawlConfig{}
+	fs := crawlFlags("crawl", cfg)
+	fs.Init("webcrawl crawl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	cfg.seeds = fs.Args()
+	return cfg
+}
+
+func TestBuildCrawler(t *testing.T) {
+	cfg := parseCrawl(t, "-depth", "2", "-max-pages", "50", "-depth-cap", `/tags/=1`,
+		"-timeout-for", `/slow/=1m`, "-rps", "3", "-include", "/docs/*", "-ranks", "-broken-links",
+		"-summary", "https://site.test/")
+	j, err := buildCrawler(cfg, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	c := j.c
+	if c.MaxDepth != 2 || c.MaxPages != 50 || len(c.DepthOverrides) != 1 || c.DepthOverrides[0].MaxDepth != 1 {
+		t.Errorf("depth %d, max pages %d, depth overrides %+v", c.MaxDepth, c.MaxPages, c.DepthOverrides)
+	}
+	hf, ok := c.Fetcher.(*webcrawl.HTTPFetcher)
+	if !ok || len(hf.TimeoutOverrides) != 1 || hf.TimeoutOverrides[0].Timeout != time.Minute {
+		t.Errorf("fetcher %T, want an HTTPFetcher with the -timeout-for", c.Fetcher)
+	}
+	if c.RateLimit == nil || len(c.Scope.Include) != 1 || !c.CheckExternal {
+		t.Errorf("rate limit %v, include %v, check external %v", c.RateLimit, c.Scope.Include, c.CheckExternal)
+	}
+	if j.graph == nil || j.broken == nil || j.sums == nil || j.seo != nil {
+		t.Errorf("reports: graph %v, broken %v, summary %v, seo %v", j.graph, j.broken, j.sums, j.seo)
+	}
+	if got := strings.Join(j.seeds, " "); got != "https://site.test/" {
+		t.Errorf("seeds %s", got)
+	}
+
+	dir := t.TempDir()
+	j, err = buildCrawler(parseCrawl(t, dir), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	if j.seeds[0] != "file:///" || j.c.Fetchers["file"] == nil {
+		t.Errorf("crawl of a directory: seeds %v, fetchers %v", j.seeds, j.c.Fetchers)
+	}
+}
+
+func TestBuildCrawlerBadFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-depth-cap", "/a/", "https://site.test/"},
+		{"-timeout-for", "/a/=soon", "https://site.test/"},
+		{"-scope", "planet", "https://site.test/"},
+		{"-offline", "https://site.test/"},
+		{"-record", "a", "-replay", "b", "https://site.test/"},
+		{"-format", "xml", "https://site.test/"},
+	} {
+		_, err := buildCrawler(parseCrawl(t, args...), io.Discard)
+		if !errors.As(err, new(badFlags)) {
+			t.Errorf("%q: %v, want badFlags", args, err)
+		}
+	}
+	cfg := parseCrawl(t, "https://site.test/")
+	cfg.resume = true
+	if _, err := buildCrawler(cfg, io.Discard); !errors.As(err, new(badFlags)) {
+		t.Errorf("resume without -state: %v, want badFlags", err)
+	}
+	// Not the flags' fault
+	if _, err := buildCrawler(parseCrawl(t, "-scrape", "title=[[", "https://site.test/"), io.Discard); err == nil || errors.As(err, new(badFlags)) {
+		t.Errorf("-scrape of a bad selector: %v, want another error than badFlags", err)
+	}
+}
+
+func TestCrawlJob(t *testing.T) {
+	site := testsite.New("http://site.test")
+	site.Add("/", &testsite.Page{Title: "Home", Links: []string{"/a", "/b"}})
+	site.Add("/a", &testsite.Page{Title: "A", Links: []string{"/b", "/missing"}})
+	site.Add("/b", &testsite.Page{Title: "B", Links: []string{"/"}})
+	srv := httptest.NewServer(site.Handler())
+	defer srv.Close()
+
+	links := filepath.Join(t.TempDir(), "links.csv")
+	var out bytes.Buffer
+	j, err := buildCrawler(parseCrawl(t, "-log-level", "error", "-ignore-robots", "-ranks", "-links-csv", links, srv.URL+"/"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	err = j.run(context.Background())
+	if err := j.finish(context.Background(), &out, err); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{srv.URL + "/a", srv.URL + "/missing", "page ranks:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output without %q:\n%s", want, out.String())
+		}
+	}
+	csv, err := os.ReadFile(links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(csv), srv.URL+"/missing") {
+		t.Errorf("-links-csv without the link to /missing:\n%s", csv)
+	}
+}
+
+func TestBuildCrawlerScript(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "steer.star")
+	if err := os.WriteFile(script, []byte("def should_follow(url):\n    return not url.endswith(\".pdf\")\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "crawl.yaml")
+	if err := os.WriteFile(config, []byte("script: {file: "+script+", steps: 5000, timeout: 2s}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &crawlConfig{}
+	fs := crawlFlags("crawl", cfg)
+	fs.Parse(nil)
+	if _, err := applyConfig(fs, config); err != nil {
+		t.Fatal(err)
+	}
+	cfg.seeds = []string{"https://site.test/"}
+	j, err := buildCrawler(cfg, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	s, ok := j.steer.(*webcrawl.StarlarkScript)
+	if !ok {
+		t.Fatalf("script %T, want a *webcrawl.StarlarkScript", j.steer)
+	}
+	if s.MaxSteps != 5000 || s.Timeout != 2*time.Second || j.c.Hooks == nil {
+		t.Errorf("script steps %d, timeout %v, hooks %v", s.MaxSteps, s.Timeout, j.c.Hooks)
+	}
+	if follow, err := s.ShouldFollow(context.Background(), "https://site.test/a.pdf"); err != nil || follow {
+		t.Errorf("ShouldFollow of a PDF = %v, %v, want false", follow, err)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configSections map the keys of the sections of a -config file to the
+// flags they set. Any flag can be set at the top level too, by its name
+var configSections = map[string]map[string]string{
+	"scope": {
+		"hosts":         "scope",
+		"include":       "include",
+		"exclude":       "exclude",
+		"languages":     "languages",
+		"query":         "query",
+		"merge-www":     "merge-www",
+		"upgrade-https": "upgrade-https",
+		"max-depth":     "depth",
+		"depths":        "depth-for",
+		"max-pages":     "max-pages",
+	},
+	"rate": {
+		"rps":            "rps",
+		"delay":          "delay",
+		"hosts":          "host-rate",
+		"retries":        "retries",
+		"requeue":        "requeue",
+		"inflight":       "host-inflight",
+		"bandwidth":      "bandwidth",
+		"host-bandwidth": "host-bandwidth",
+		"windows":        "window",
+	},
+	"auth": {
+		"basic":   "basic-auth",
+		"bearer":  "bearer",
+		"login":   "login",
+		"fields":  "login-field",
+		"headers": "header",
+		"cookies": "cookies",
+	},
+	"output": {
+		"format":  "format",
+		"fields":  "fields",
+		"file":    "o",
+		"db":      "db",
+		"index":   "index",
+		"bucket":  "bucket",
+		"publish": "publish",
+		"warc":    "warc",
+		"har":     "har",
+		"webhook": "webhook",
+	},
+	"script": {
+		"file":    "script",
+		"steps":   "script-steps",
+		"timeout": "script-timeout",
+	},
+}
+
+// loadConfig reads the -config file at path, YAML or TOML by its extension
+func loadConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		_, err = toml.Decode(string(data), &tree)
+	default:
+		return nil, fmt.Errorf("%s: want a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tree, nil
+}
+
+// applyConfig sets the flags of fs to the values of the -config file at
+// path, but for those given on the command line, and returns its seeds.
+// The errors name the offending key
+func applyConfig(fs *flag.FlagSet, path string) ([]string, error) {
+	tree, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var seeds []string
+	set := func(key, name string, v any) error {
+		if name == "config" {
+			return fmt.Errorf("%s: %s: a config file can't load another", path, key)
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown key %s", path, key)
+		}
+		values, err := configValues(name, v, fs.Lookup(name).Value)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		if given[name] {
+			return nil
+		}
+		for _, s := range values {
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		}
+		return nil
+	}
+	for _, key := range sortedKeys(tree) {
+		v := tree[key]
+		name := strings.ReplaceAll(key, "_", "-")
+		section, isSection := configSections[name]
+		sub, isTable := v.(map[string]any)
+		switch {
+		case name == "seeds" || name == "seed":
+			if seeds, err = configStrings(v); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		case isSection && isTable:
+			for _, k := range sortedKeys(sub) {
+				flagName, ok := section[strings.ReplaceAll(k, "_", "-")]
+				if !ok {
+					return nil, fmt.Errorf("%s: unknown key %s.%s", path, key, k)
+				}
+				if err := set(key+"."+k, flagName, sub[k]); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if err := set(key, name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return seeds, nil
+}
+
+// configValues turns the value v of a config key into the values to set
+// the flag called name to, several for a flag that may be repeated. A
+// table is the name=value pairs of a repeated flag, Name: value for
+// -header, and the rps and delay of the hosts of -host-rate
+func configValues(name string, v any, fv flag.Value) ([]string, error) {
+	_, repeated := fv.(*stringList)
+	switch v := v.(type) {
+	case map[string]any:
+		if !repeated {
+			return nil, fmt.Errorf("want a value, not a table")
+		}
+		var values []string
+		for _, k := range sortedKeys(v) {
+			if name == "host-rate" {
+				rate, err := configHostRate(v[k])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+				values = append(values, k+"="+rate)
+				continue
+			}
+			s, err := configScalar(v[k])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			if name == "header" {
+				values = append(values, k+": "+s)
+			} else {
+				values = append(values, k+"="+s)
+			}
+		}
+		return values, nil
+	case []any:
+		values, err := configStrings(v)
+		if err != nil {
+			return nil, err
+		}
+		if !repeated {
+			// The comma-separated lists, such as -languages
+			return []string{strings.Join(values, ",")}, nil
+		}
+		return values, nil
+	}
+	s, err := configScalar(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// configHostRate turns the rate of a host of -host-rate, its requests per
+// second or a table of rps and delay, into rps[,delay]
+func configHostRate(v any) (string, error) {
+	t, ok := v.(map[string]any)
+	if !ok {
+		return configScalar(v)
+	}
+	var rate, delay string
+	for _, k := range sortedKeys(t) {
+		s, err := configScalar(t[k])
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", k, err)
+		}
+		switch k {
+		case "rps":
+			rate = s
+		case "delay":
+			delay = s
+		default:
+			return "", fmt.Errorf("unknown key %s, want rps or delay", k)
+		}
+	}
+	if rate == "" {
+		rate = "0"
+	}
+	if delay != "" {
+		rate += "," + delay
+	}
+	return rate, nil
+}
+
+// configStrings turns a string, or a list of values, into strings
+func configStrings(v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		s, err := configScalar(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	values := make([]string, 0, len(list))
+	for i, e := range list {
+		s, err := configScalar(e)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// configScalar turns a string, number or boolean into the text of a flag
+func configScalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case nil:
+		return "", fmt.Errorf("want a value")
+	case map[string]any:
+		return "", fmt.Errorf("want a value, not a table")
+	case []any:
+		return "", fmt.Errorf("want a value, not a list")
+	}
+	return fmt.Sprint(v), nil
+}
+
+// isSet reports whether the flag called name of fs was set, on the command
+// line or by the -config file
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
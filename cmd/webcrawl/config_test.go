@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyConfigTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.toml")
+	err := os.WriteFile(path, []byte(`# A crawl of the docs
+seeds = ["https://example.com/", "https://example.com/blog/"]
+depth = 3
+max_pages = 1_000
+sequential = true
+
+[scope]
+hosts = "domain"
+exclude = ["/search*", '/tags/*']
+
+[rate]
+rps = 2.5
+hosts = { "cdn.example.com" = { rps = 10, delay = "50ms" } }
+
+[auth.headers]
+X-Crawler = """webcrawl"""
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &crawlConfig{}
+	fs := crawlFlags("crawl", cfg)
+	// The command line wins over the file
+	fs.Parse([]string{"-depth", "5"})
+	seeds, err := applyConfig(fs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(seeds, " "); got != "https://example.com/ https://example.com/blog/" {
+		t.Errorf("seeds %s", got)
+	}
+	if cfg.depth != 5 || cfg.maxPages != 1000 || !cfg.sequential || cfg.scope != "domain" || cfg.rps != 2.5 {
+		t.Errorf("depth %d, max pages %d, sequential %v, scope %s, rps %v", cfg.depth, cfg.maxPages, cfg.sequential, cfg.scope, cfg.rps)
+	}
+	if got := strings.Join(cfg.exclude, " "); got != "/search* /tags/*" {
+		t.Errorf("exclude %s", got)
+	}
+	if got := strings.Join(cfg.hostRates, " "); got != "cdn.example.com=10,50ms" {
+		t.Errorf("host rates %s", got)
+	}
+	if got := strings.Join(cfg.headers, " "); got != "X-Crawler: webcrawl" {
+		t.Errorf("headers %s", got)
+	}
+}
+
+func TestApplyConfigTOMLErrors(t *testing.T) {
+	for doc, want := range map[string]string{
+		"depth = 3\ndepth = 4\n":     "line 2",
+		"depth = [\n":                "line 1",
+		"depht = 3\n":                "unknown key depht",
+		"[scope]\nhosts = {a = 1}\n": "not a table",
+		"config = \"other.toml\"":    "can't load another",
+	} {
+		path := filepath.Join(t.TempDir(), "crawl.toml")
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := applyConfig(crawlFlags("crawl", &crawlConfig{}), path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: %v, want an error with %q", doc, err, want)
+		}
+	}
+}
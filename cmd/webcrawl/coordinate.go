@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jackyugit/webcrawl/shard"
+)
+
+// coordinate is the coordinate command, coordinating the crawl of the
+// workers started with -coordinator and printing their results
+func coordinate(args []string) int {
+	fs := flag.NewFlagSet("webcrawl coordinate", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:9093", "serve the workers on `addr`")
+	lease := fs.Duration("lease", shard.DefaultLease, "hand the hosts and URLs of a worker to the others once it is silent for this `long`")
+	format := fs.String("format", "text", "output `format` of the results, text or jsonl")
+	fields := fs.String("fields", "", "comma-separated `list` of the fields of the jsonl format, all when empty")
+	output := fs.String("o", "", "write the results to `file` rather than the standard output")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: webcrawl coordinate [flags]\n\nCoordinates the crawl of the workers started with webcrawl crawl -coordinator, printing their results, until it is over.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	out := os.Stdout
+	var err error
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 1
+		}
+		defer out.Close()
+	}
+	co := &shard.Coordinator{Lease: *lease}
+	if co.OnResult, err = newOutput(out, *format, *fields); err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 2
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 1
+	}
+	srv := &http.Server{Handler: co.Handler()}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	<-co.Over()
+	// For the workers to hear of it, and hand their last results in
+	deadline := time.Now().Add(*lease)
+	for len(co.Status().Workers) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	st := co.Status()
+	fmt.Fprintf(os.Stderr, "webcrawl: crawl over, %d results from the workers\n", st.Results)
+	return 0
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/store"
+	"github.com/jackyugit/webcrawl/stream"
+)
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// newDNSCache sets up the DNS cache of the -dns and -resolve flags, nil
+// when there are none and -dns-cache is not set: -dns is a comma-separated
+// list of name servers, or auto for those of /etc/resolv.conf, every
+// -resolve a host=ip[,ip...]
+func newDNSCache(cache bool, servers string, resolve []string) (*webcrawl.DNSCache, error) {
+	if !cache && servers == "" && len(resolve) == 0 {
+		return nil, nil
+	}
+	c := &webcrawl.DNSCache{}
+	switch servers {
+	case "":
+	case "auto":
+		c.Resolver = &webcrawl.DNSResolver{}
+	default:
+		c.Resolver = &webcrawl.DNSResolver{Servers: strings.Split(servers, ",")}
+	}
+	for _, r := range resolve {
+		host, ips, ok := strings.Cut(r, "=")
+		if !ok || host == "" || ips == "" {
+			return nil, fmt.Errorf("bad -resolve %q, want host=ip", r)
+		}
+		if c.Overrides == nil {
+			c.Overrides = make(map[string][]string)
+		}
+		c.Overrides[host] = append(c.Overrides[host], strings.Split(ips, ",")...)
+	}
+	return c, nil
+}
+
+// parseSubdomains maps the -scope flag values to the policies
+func parseSubdomains(v string) (webcrawl.SubdomainPolicy, error) {
+	switch v {
+	case "host":
+		return webcrawl.SameHost, nil
+	case "domain":
+		return webcrawl.SameRegistrableDomain, nil
+	case "any":
+		return webcrawl.AnyHost, nil
+	}
+	return 0, fmt.Errorf("unknown scope %q, want host, domain or any", v)
+}
+
+// newNormalizer sets up the Normalizer of the -query flags, each a
+// comma-separated list of rules for every host or, as host=rules, for one
+// host: sort, drop, tracking, sessions, or the name of a parameter to strip
+func newNormalizer(flags []string) (*webcrawl.Normalizer, error) {
+	n := &webcrawl.Normalizer{}
+	for _, f := range flags {
+		rules := n
+		if host, r, ok := strings.Cut(f, "="); ok {
+			if n.Hosts == nil {
+				n.Hosts = make(map[string]*webcrawl.Normalizer)
+			}
+			if n.Hosts[host] == nil {
+				n.Hosts[host] = &webcrawl.Normalizer{}
+			}
+			rules, f = n.Hosts[host], r
+		}
+		for _, r := range strings.Split(f, ",") {
+			switch r = strings.TrimSpace(r); r {
+			case "":
+				return nil, fmt.Errorf("-query %q: empty rule", f)
+			case "sort":
+				rules.SortQuery = true
+			case "drop":
+				rules.StripQuery = true
+			case "tracking":
+				rules.StripParams = append(rules.StripParams, webcrawl.TrackingParams...)
+			case "sessions":
+				rules.SessionParams = append(rules.SessionParams, webcrawl.SessionIDParams...)
+			default:
+				rules.StripParams = append(rules.StripParams, r)
+			}
+		}
+	}
+	return n, nil
+}
+
+// newProxyPool sets up the pool of the -proxy list, rotating as
+// -proxy-rotation says
+func newProxyPool(rotation string, proxies []string) (*webcrawl.ProxyPool, error) {
+	r := map[string]webcrawl.ProxyRotation{
+		"roundrobin": webcrawl.RoundRobin,
+		"random":     webcrawl.RandomProxy,
+		"sticky":     webcrawl.StickyPerHost,
+	}
+	rr, ok := r[rotation]
+	if !ok {
+		return nil, fmt.Errorf("unknown proxy rotation %q, want roundrobin, random or sticky", rotation)
+	}
+	return webcrawl.NewProxyPool(rr, proxies...)
+}
+
+// parseHeaders parses the -header flags into the headers sent to every
+// host and to each host
+func parseHeaders(flags []string) (http.Header, map[string]http.Header, error) {
+	all := make(http.Header)
+	var byHost map[string]http.Header
+	for _, f := range flags {
+		name, value, ok := strings.Cut(f, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("-header %q: want Name: value", f)
+		}
+		h := all
+		if host, n, ok := strings.Cut(name, "="); ok {
+			if byHost == nil {
+				byHost = make(map[string]http.Header)
+			}
+			if byHost[host] == nil {
+				byHost[host] = make(http.Header)
+			}
+			h, name = byHost[host], n
+		}
+		h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return all, byHost, nil
+}
+
+// parseHostRates parses the -host-rate flags, host=rps or host=rps,delay,
+// into the rates of HostLimiter.Hosts
+func parseHostRates(flags []string) (map[string]webcrawl.HostRate, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	rates := make(map[string]webcrawl.HostRate)
+	for _, f := range flags {
+		host, v, ok := strings.Cut(f, "=")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("-host-rate %q: want host=rps or host=rps,delay", f)
+		}
+		rps, d, _ := strings.Cut(v, ",")
+		r := webcrawl.HostRate{Burst: 1}
+		var err error
+		if r.RequestsPerSecond, err = strconv.ParseFloat(strings.TrimSpace(rps), 64); err != nil {
+			return nil, fmt.Errorf("-host-rate %q: bad rate: %w", f, err)
+		}
+		if d != "" {
+			if r.MinDelay, err = time.ParseDuration(strings.TrimSpace(d)); err != nil {
+				return nil, fmt.Errorf("-host-rate %q: bad delay: %w", f, err)
+			}
+		}
+		rates[host] = r
+	}
+	return rates, nil
+}
+
+// parseSchedule parses the -window flags, [host=]hh:mm-hh:mm[ zone], into
+// a Schedule, nil when there are none
+func parseSchedule(flags []string) (*webcrawl.Schedule, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	s := &webcrawl.Schedule{}
+	for _, f := range flags {
+		host, v, ok := strings.Cut(f, "=")
+		if !ok {
+			host, v = "", f
+		}
+		w, err := webcrawl.ParseWindow(v)
+		if err != nil {
+			return nil, fmt.Errorf("-window %q: want [host=]hh:mm-hh:mm[ zone]", f)
+		}
+		if host == "" {
+			s.Windows = append(s.Windows, w)
+			continue
+		}
+		if s.Hosts == nil {
+			s.Hosts = make(map[string][]webcrawl.Window)
+		}
+		host = strings.ToLower(strings.TrimSpace(host))
+		s.Hosts[host] = append(s.Hosts[host], w)
+	}
+	return s, nil
+}
+
+// parseDepthRules parses the -depth-for flags, glob=levels, into
+// Crawler.DepthRules
+func parseDepthRules(flags []string) ([]webcrawl.DepthRule, error) {
+	var rules []webcrawl.DepthRule
+	for _, f := range flags {
+		glob, v, ok := strings.Cut(f, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if !ok || glob == "" || err != nil || n < webcrawl.UnlimitedDepth {
+			return nil, fmt.Errorf("-depth-for %q: want glob=levels, -1 for no limit", f)
+		}
+		rules = append(rules, webcrawl.DepthRule{Path: webcrawl.Glob(strings.TrimSpace(glob)), MaxDepth: n})
+	}
+	return rules, nil
+}
+
+// parseDepthCaps parses the -depth-cap flags, regexp=levels, the last =
+// ending the regexp, into Crawler.DepthOverrides
+func parseDepthCaps(flags []string) ([]webcrawl.DepthRule, error) {
+	var rules []webcrawl.DepthRule
+	for _, f := range flags {
+		i := strings.LastIndex(f, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("-depth-cap %q: want regexp=levels", f)
+		}
+		re, err := regexp.Compile(f[:i])
+		if err != nil {
+			return nil, fmt.Errorf("-depth-cap %q: %w", f, err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(f[i+1:]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("-depth-cap %q: want regexp=levels, 0 or more", f)
+		}
+		rules = append(rules, webcrawl.DepthRule{URLPattern: re, MaxDepth: n})
+	}
+	return rules, nil
+}
+
+// parseTimeoutRules parses the -timeout-for flags, regexp=timeout, the
+// last = ending the regexp, into HTTPFetcher.TimeoutOverrides
+func parseTimeoutRules(flags []string) ([]webcrawl.TimeoutRule, error) {
+	var rules []webcrawl.TimeoutRule
+	for _, f := range flags {
+		i := strings.LastIndex(f, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("-timeout-for %q: want regexp=timeout", f)
+		}
+		re, err := regexp.Compile(f[:i])
+		if err != nil {
+			return nil, fmt.Errorf("-timeout-for %q: %w", f, err)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(f[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("-timeout-for %q: %w", f, err)
+		}
+		rules = append(rules, webcrawl.TimeoutRule{URLPattern: re, Timeout: d})
+	}
+	return rules, nil
+}
+
+// parseHostCaps parses the -host-inflight-for flags, host=n, into
+// Crawler.HostMaxInFlight
+func parseHostCaps(flags []string) (map[string]int, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	caps := make(map[string]int)
+	for _, f := range flags {
+		host, v, ok := strings.Cut(f, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if !ok || host == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("-host-inflight-for %q: want host=n", f)
+		}
+		caps[strings.ToLower(host)] = n
+	}
+	return caps, nil
+}
+
+// parseHostBandwidth parses the -host-bandwidth-for flags, host=bytes,
+// into BandwidthLimiter.Hosts
+func parseHostBandwidth(flags []string) (map[string]int64, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	caps := make(map[string]int64)
+	for _, f := range flags {
+		host, v, ok := strings.Cut(f, "=")
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if !ok || host == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("-host-bandwidth-for %q: want host=bytes", f)
+		}
+		caps[strings.ToLower(host)] = n
+	}
+	return caps, nil
+}
+
+// newAuth sets up the credentials of the -basic-auth, -bearer and -login
+// flags, which only go to the host of seed
+func newAuth(seed, basic, bearer, login string, fields []string) (webcrawl.Authenticator, error) {
+	var a webcrawl.Authenticator
+	switch {
+	case basic != "":
+		user, password, ok := strings.Cut(basic, ":")
+		if !ok {
+			return nil, fmt.Errorf("-basic-auth %q: want user:password", basic)
+		}
+		a = &webcrawl.BasicAuth{User: user, Password: password}
+	case bearer != "":
+		a = webcrawl.BearerToken(bearer)
+	case login != "":
+		values := make(url.Values)
+		for _, f := range fields {
+			name, value, ok := strings.Cut(f, "=")
+			if !ok {
+				return nil, fmt.Errorf("-login-field %q: want name=value", f)
+			}
+			values.Add(name, value)
+		}
+		a = &webcrawl.FormLogin{URL: login, Fields: values}
+	default:
+		return nil, nil
+	}
+	u, err := url.Parse(seed)
+	if err != nil {
+		return nil, err
+	}
+	return webcrawl.HostAuth{u.Hostname(): a}, nil
+}
+
+// newLogger sets up the logger the -log-level and -log-format flags ask
+// for, writing to the standard error
+func newLogger(level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, want debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want text or json", format)
+}
+
+// newScraper sets up the rules of the -scrape flags: "[glob ]field=selector",
+// the selector being XPath when it starts with /, ( or xpath:, CSS
+// otherwise, and taking the attribute of a trailing " @attr". A field
+// ending in [] has the values of every match
+func newScraper(flags []string) (*webcrawl.Scraper, error) {
+	var rules []webcrawl.ScrapeRule
+	for _, f := range flags {
+		name, sel, ok := strings.Cut(f, "=")
+		if !ok || strings.TrimSpace(sel) == "" {
+			return nil, fmt.Errorf("-scrape %q: want field=selector", f)
+		}
+		var r webcrawl.ScrapeRule
+		words := strings.Fields(name)
+		switch len(words) {
+		case 2:
+			r.Path = webcrawl.Glob(words[0])
+			words = words[1:]
+		case 1:
+		default:
+			return nil, fmt.Errorf("-scrape %q: want field=selector", f)
+		}
+		r.Field, r.All = strings.CutSuffix(words[0], "[]")
+		sel = strings.TrimSpace(sel)
+		if i := strings.LastIndex(sel, " @"); i >= 0 {
+			sel, r.Attr = strings.TrimSpace(sel[:i]), sel[i+2:]
+		}
+		if x, ok := strings.CutPrefix(sel, "xpath:"); ok {
+			r.XPath = x
+		} else if strings.HasPrefix(sel, "/") || strings.HasPrefix(sel, "(") {
+			r.XPath = sel
+		} else {
+			r.CSS = sel
+		}
+		rules = append(rules, r)
+	}
+	return webcrawl.NewScraper(rules...)
+}
+
+// newContentRules sets up the rules of the -match flags: "[follow ]name=rule",
+// the rule being css:selector, xpath:expr, regex:re or schema:Type
+func newContentRules(flags []string) (*webcrawl.ContentRules, error) {
+	var rules []webcrawl.ContentRule
+	for _, f := range flags {
+		name, rule, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("-match %q: want name=rule", f)
+		}
+		var r webcrawl.ContentRule
+		words := strings.Fields(name)
+		if len(words) == 2 && words[0] == "follow" {
+			r.Follow, words = true, words[1:]
+		}
+		if len(words) != 1 {
+			return nil, fmt.Errorf("-match %q: want [follow ]name=rule", f)
+		}
+		r.Name = words[0]
+		kind, arg, _ := strings.Cut(strings.TrimSpace(rule), ":")
+		if arg == "" {
+			return nil, fmt.Errorf("-match %q: want css:, xpath:, regex: or schema: and what to match", f)
+		}
+		switch kind {
+		case "css":
+			r.CSS = arg
+		case "xpath":
+			r.XPath = arg
+		case "regex":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("-match %q: %w", f, err)
+			}
+			r.Regexp = re
+		case "schema":
+			r.Schema = arg
+		default:
+			return nil, fmt.Errorf("-match %q: unknown rule %s, want css, xpath, regex or schema", f, kind)
+		}
+		rules = append(rules, r)
+	}
+	return webcrawl.NewContentRules(rules...)
+}
+
+// newFilter sets up the filter of the -filter flags, every one of which a
+// URL is to pass: a rule is regex:re, glob:pattern, ext:html,php or
+// depth:from-to, its negation with a ! in front, and several rules joined
+// by " | " pass the URLs one of them does
+func newFilter(flags []string) (webcrawl.Filter, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	var all []webcrawl.Filter
+	for _, f := range flags {
+		var any []webcrawl.Filter
+		for _, rule := range strings.Split(f, " | ") {
+			filter, err := parseFilterRule(strings.TrimSpace(rule))
+			if err != nil {
+				return nil, fmt.Errorf("-filter %q: %w", f, err)
+			}
+			any = append(any, filter)
+		}
+		all = append(all, webcrawl.AnyOf(any...))
+	}
+	return webcrawl.AllOf(all...), nil
+}
+
+func parseFilterRule(rule string) (webcrawl.Filter, error) {
+	rule, not := strings.CutPrefix(rule, "!")
+	kind, v, ok := strings.Cut(rule, ":")
+	if !ok {
+		return nil, fmt.Errorf("%q: want kind:value", rule)
+	}
+	var f webcrawl.Filter
+	switch kind {
+	case "regex":
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, err
+		}
+		f = webcrawl.RegexFilter(re)
+	case "glob":
+		f = webcrawl.GlobFilter(v)
+	case "ext":
+		f = webcrawl.ExtensionFilter(strings.Split(v, ",")...)
+	case "depth":
+		lo, hi, ranged := strings.Cut(v, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("%q: want depth:from-to", rule)
+		}
+		to := from
+		if ranged {
+			to = -1
+			if hi != "" {
+				if to, err = strconv.Atoi(hi); err != nil {
+					return nil, fmt.Errorf("%q: want depth:from-to", rule)
+				}
+			}
+		}
+		f = webcrawl.DepthFilter(from, to)
+	default:
+		return nil, fmt.Errorf("%q: unknown kind %s, want regex, glob, ext or depth", rule, kind)
+	}
+	if not {
+		f = webcrawl.NoneOf(f)
+	}
+	return f, nil
+}
+
+// openStore opens the database of the -db flag: a postgres:// URL, or the
+// path of a SQLite file, with an optional sqlite: in front
+func openStore(db string) (*store.Store, error) {
+	if strings.HasPrefix(db, "postgres://") || strings.HasPrefix(db, "postgresql://") {
+		return store.Open("pgx", db)
+	}
+	return store.Open("sqlite", strings.TrimPrefix(db, "sqlite:"))
+}
+
+// newStreamSink sets up the sink of the -publish flag: a
+// kafka://broker[,broker...]/topic or nats://host:port/subject URL
+func newStreamSink(publish, format string) (*stream.Sink, error) {
+	f, err := stream.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(publish)
+	if err != nil {
+		return nil, err
+	}
+	topic := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || topic == "" {
+		return nil, fmt.Errorf("-publish %q: want kafka://broker/topic or nats://host/subject", publish)
+	}
+	s := &stream.Sink{Topic: topic, Format: f}
+	switch u.Scheme {
+	case "kafka":
+		s.Publisher = stream.NewKafka(strings.Split(u.Host, ",")...)
+	case "nats":
+		u.Path = ""
+		if s.Publisher, err = stream.NewNATS(u.String()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("-publish %q: want a kafka:// or nats:// URL", publish)
+	}
+	return s, nil
+}
+
+// readSeeds reads the seeds of the -seeds-from flag out of the file name, or the
+// standard input for -
+func readSeeds(name string) ([]string, error) {
+	if name == "-" {
+		return webcrawl.ReadSeeds(os.Stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seeds, err := webcrawl.ReadSeeds(f)
+	if err != nil {
+		return nil, fmt.Errorf("-seeds-from %s: %w", name, err)
+	}
+	return seeds, nil
+}
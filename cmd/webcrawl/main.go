@@ -0,0 +1,518 @@
+// Command webcrawl crawls web sites and reports on them.
+//
+// Usage:
+//
+//	webcrawl crawl [flags] url... | dir
+//	webcrawl resume -state file [flags] url... | dir
+//	webcrawl report [flags] file.jsonl
+//	webcrawl diff old new
+//	webcrawl serve [flags]
+//	webcrawl coordinate [flags]
+//	webcrawl selftest
+//
+// Run webcrawl crawl -h for the list of flags.
+//
+// crawl crawls the site at url, printing every page it finds. Given a
+// directory, or a file:// url, it crawls the files there as a web server
+// would serve them, for checking a statically generated site before
+// deploying it. The pages found are printed to the standard output, what
+// goes wrong is logged to the standard error, with -log-level info every
+// page fetched is too.
+//
+// On an interrupt (Ctrl-C) or SIGTERM, webcrawl stops fetching new pages,
+// gives the fetches under way -grace to finish and prints their results.
+// With -state, the progress of the crawl is saved to a file as it goes,
+// and webcrawl resume -state file, with the same flags and url otherwise,
+// picks an interrupted crawl up again. A second interrupt quits right
+// away.
+//
+// With -config, crawl and resume read their flags from a YAML or TOML
+// file, the flags given on the command line overriding it. Its keys are
+// the names of the flags, along with the seeds and sections of them:
+//
+//	seeds: [https://example.com/]
+//	depth: 3
+//	scope: {hosts: domain, exclude: [/search*]}
+//	rate:
+//	  rps: 2
+//	  hosts: {cdn.example.com: {rps: 10, delay: 50ms}}
+//	auth: {bearer: secret, headers: {X-Crawler: webcrawl}}
+//	output: {format: jsonl, file: crawl.jsonl}
+//	script: {file: steer.star, timeout: 2s}
+//
+// scope has hosts, include, exclude, languages, query, merge-www,
+// upgrade-https, max-depth and max-pages; rate has rps, delay, hosts and
+// retries; auth has basic, bearer, login, fields, headers and cookies;
+// output has format, fields, file, db, index, bucket, publish, warc, har
+// and webhook; script has file, steps and timeout.
+//
+// report sums up a crawl written with -format jsonl: statuses, errors,
+// latency and the pages that stand out, see -summary. diff lists the
+// pages added, removed and changed from a crawl saved with -snapshot to a
+// later one.
+//
+// serve serves the gRPC service of rpc/crawler.proto on -addr, for other
+// services to start and stop crawls, follow them and stream their results.
+//
+// coordinate spreads a crawl over the processes started with crawl
+// -coordinator http://addr and the same url, on any number of machines:
+// it hands the hosts out to them, one process a host, and prints what they
+// fetch, as crawl does, until nothing is left to crawl.
+//
+// selftest crawls a reference site of 20 pages served on a local port and
+// checks what the crawl found, the pages, links, titles and PageRank, to
+// see that webcrawl works on this machine. It prints a line per check and
+// exits with status 1 when one fails.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// usage is what webcrawl prints when it doesn't know what to do
+const usage = `usage: webcrawl crawl [flags] url | dir
+       webcrawl resume -state file [flags] url | dir
+       webcrawl report [flags] file.jsonl
+       webcrawl diff old new
+       webcrawl serve [flags]
+       webcrawl coordinate [flags]
+       webcrawl selftest
+Run webcrawl crawl -h for the list of flags.
+`
+
+// run is main, returning the exit status so that the deferred calls
+// happen
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "crawl":
+		return crawl(args[1:], false)
+	case "resume":
+		return crawl(args[1:], true)
+	case "report":
+		return report(args[1:])
+	case "diff":
+		return diff(args[1:])
+	case "serve":
+		return serve(args[1:])
+	case "coordinate":
+		return coordinate(args[1:])
+	case "selftest":
+		return selftest(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "webcrawl: unknown command %q\n%s", args[0], usage)
+	return 2
+}
+
+// crawlConfig is what crawl and resume are set up by, the field of each
+// flag called after it
+type crawlConfig struct {
+	resume bool
+	seeds  []string // the arguments, then the urls of -seeds-from
+
+	depth            int
+	depthFor         stringList
+	depthCap         stringList
+	maxPages         int
+	maxHostPages     int
+	hostInFlight     int
+	hostInFlightFor  stringList
+	maxBytes         int64
+	minBody          int
+	workers          int
+	sequential       bool
+	timeout          time.Duration
+	timeoutFor       stringList
+	connectTimeout   time.Duration
+	readTimeout      time.Duration
+	proxy            string
+	rotation         string
+	caFile           string
+	certFile         string
+	keyFile          string
+	insecure         bool
+	maxConns         int
+	http1            bool
+	http1Hosts       stringList
+	http3            bool
+	maxRedirects     int
+	acceptTypes      stringList
+	rejectTypes      stringList
+	maxSize          int64
+	maxBody          int64
+	sampleBodies     int
+	bodyBytes        int
+	metadataOnly     bool
+	bandwidth        int64
+	hostBandwidth    int64
+	hostBandwidthFor stringList
+	headFirst        bool
+	cache            string
+	cookies          string
+	userAgent        string
+	dnsCache         bool
+	dnsServers       string
+	resolve          stringList
+	headers          stringList
+	basicAuth        string
+	bearer           string
+	loginURL         string
+	loginFields      stringList
+	retries          int
+	requeue          int
+	rps              float64
+	delay            time.Duration
+	windows          stringList
+	hostRates        stringList
+	breaker          int
+	cooldown         time.Duration
+	adaptive         bool
+	adaptiveLatency  time.Duration
+	maxBackoff       time.Duration
+	sitemaps         bool
+	documents        bool
+	documentCommand  string
+	feeds            bool
+	scope            string
+	include          stringList
+	exclude          stringList
+	filters          stringList
+	languages        string
+	detectLanguage   bool
+	queryRules       stringList
+	mergeWWW         bool
+	upgradeHTTPS     bool
+	maxPathDepth     int
+	maxRepeated      int
+	maxQueries       int
+	maxDirURLs       int
+	maxURLLength     int
+	maxQueryParams   int
+	dryRun           bool
+	ignoreRobots     bool
+	grace            time.Duration
+	format           string
+	fields           string
+	scrape           stringList
+	match            stringList
+	script           string
+	scriptSteps      uint64
+	scriptTimeout    time.Duration
+	soft404          bool
+	structured       bool
+	text             bool
+	output           string
+	warc             string
+	record           string
+	replay           string
+	pageCache        string
+	offline          bool
+	mirrorDir        string
+	assets           bool
+	checkAssets      bool
+	hreflangReport   bool
+	followCanonical  bool
+	canonicalReport  bool
+	security         bool
+	accessibility    bool
+	brokenLinks      bool
+	duplicates       bool
+	nearDuplicates   int
+	summary          bool
+	summaryJSON      string
+	seoFile          string
+	indexDir         string
+	dbFlag           string
+	bucketURL        string
+	publish          string
+	publishFormat    string
+	webhookURL       string
+	webhookSecret    string
+	webhookErrorRate float64
+	webhookBatch     int
+	harFile          string
+	harBodies        bool
+	outboundCSV      string
+	heatmapCSV       string
+	checkOutbound    bool
+	linksCSV         string
+	linksDOT         string
+	linksGraphML     string
+	sitemapOut       string
+	sitemapBase      string
+	ranks            bool
+	logLevel         string
+	logFormat        string
+	metrics          string
+	otlp             string
+	controlAddr      string
+	debugAddr        string
+	progress         bool
+	bloom            int
+	bloomFP          float64
+	spill            string
+	spillMemory      int
+	redisURL         string
+	redisName        string
+	coordinator      string
+	state            string
+	config           string
+	seedsFile        string
+	seedSitemaps     stringList
+	snapshot         string
+}
+
+// crawlFlags returns the flags of the name command, crawl or resume,
+// setting cfg
+func crawlFlags(name string, cfg *crawlConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("webcrawl "+name, flag.ExitOnError)
+	fs.IntVar(&cfg.depth, "depth", 4, "number of `levels` of links to follow, the seed being the first, -1 for no limit but -max-pages and the scope")
+	fs.Var(&cfg.depthFor, "depth-for", "follow the links to the paths matching a glob by their own `glob=levels`, rather than -depth, the first matching counting; may be repeated")
+	fs.Var(&cfg.depthCap, "depth-cap", "follow the links to the URLs matching a regexp no deeper than `regexp=levels`, on top of -depth and -depth-for, the least of those matching counting, 0 to skip them; may be repeated")
+	fs.IntVar(&cfg.maxPages, "max-pages", 0, "stop after fetching this many `pages`, 0 for no limit")
+	fs.IntVar(&cfg.maxHostPages, "max-host-pages", 0, "fetch at most this many `pages` from each host, 0 for no limit")
+	fs.IntVar(&cfg.hostInFlight, "host-inflight", 0, "fetch at most this many `pages` from each host at the same time, the workers going to the other hosts meanwhile, 0 for no cap")
+	fs.Var(&cfg.hostInFlightFor, "host-inflight-for", "cap the pages fetched from a host at the same time by its own `host=n`, rather than -host-inflight; may be repeated")
+	fs.Int64Var(&cfg.maxBytes, "max-bytes", 0, "stop after downloading this many `bytes`, 0 for no limit")
+	fs.IntVar(&cfg.minBody, "min-body-bytes", 0, "report the pages with a body shorter than this many `bytes` as skipped, not following their links, 0 for no minimum")
+	fs.IntVar(&cfg.workers, "workers", webcrawl.DefaultMaxWorkers, "number of pages fetched in `parallel`")
+	fs.BoolVar(&cfg.sequential, "sequential", false, "fetch one page at a time, breadth first in the order of the links, for the same crawl to always go the same way")
+	fs.DurationVar(&cfg.timeout, "timeout", webcrawl.DefaultTimeout, "per-request `timeout`")
+	fs.Var(&cfg.timeoutFor, "timeout-for", "give the URLs matching a regexp a timeout of their own, `regexp=timeout`, rather than -timeout, the first matching counting; may be repeated")
+	fs.DurationVar(&cfg.connectTimeout, "connect-timeout", 0, "`timeout` to connect to a server, 0 for none but -timeout")
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", 0, "`timeout` for the response headers once a request is sent, 0 for none but -timeout")
+	fs.StringVar(&cfg.proxy, "proxy", "", "send every request through the proxy at `url`, http://, https:// or socks5://, or a comma-separated list of them to rotate through")
+	fs.StringVar(&cfg.rotation, "proxy-rotation", "roundrobin", "how to pick a proxy of the -proxy list: roundrobin, random or sticky (per host)")
+	fs.StringVar(&cfg.caFile, "ca-file", "", "trust the certificate authorities of this PEM `file` rather than the system's")
+	fs.StringVar(&cfg.certFile, "cert", "", "present the client certificate of this PEM `file`, with -key")
+	fs.StringVar(&cfg.keyFile, "key", "", "the PEM `file` of the key of -cert")
+	fs.BoolVar(&cfg.insecure, "insecure", false, "accept any server certificate")
+	fs.IntVar(&cfg.maxConns, "max-conns-per-host", 0, "open at most this many `connections` to each host, 0 for no limit")
+	fs.BoolVar(&cfg.http1, "http1", false, "speak HTTP/1.1 only, even to the servers that offer HTTP/2")
+	fs.Var(&cfg.http1Hosts, "http1-host", "speak HTTP/1.1 only to this `host`, as one that mishandles HTTP/2; may be repeated")
+	fs.BoolVar(&cfg.http3, "http3", false, "try HTTP/3 over QUIC first with the https:// sites, over TCP from then on with those it fails with")
+	fs.IntVar(&cfg.maxRedirects, "max-redirects", webcrawl.DefaultMaxRedirects, "follow at most this many `redirects` in a row, -1 for none")
+	fs.Var(&cfg.acceptTypes, "accept-type", "only download the responses of this media `type`, such as text/html or image/*; may be repeated")
+	fs.Var(&cfg.rejectTypes, "reject-type", "don't download the responses of this media `type`; may be repeated")
+	fs.Int64Var(&cfg.maxSize, "max-size", 0, "don't download the responses announcing more than this many `bytes`, 0 for no limit")
+	fs.Int64Var(&cfg.maxBody, "max-body", 0, "download at most this many `bytes` of each response, 0 for no limit")
+	fs.IntVar(&cfg.sampleBodies, "sample-bodies", 0, "output the body of one page in `n`, the others without: the pages are parsed all the same")
+	fs.IntVar(&cfg.bodyBytes, "body-bytes", 0, "output at most this many `bytes` of the body of each page, 0 for no limit")
+	fs.BoolVar(&cfg.metadataOnly, "metadata-only", false, "output the pages without their body and text, for a crawl to map a site")
+	fs.Int64Var(&cfg.bandwidth, "bandwidth", 0, "download the bodies at most at this many `bytes` per second, from all the hosts together, 0 for no cap")
+	fs.Int64Var(&cfg.hostBandwidth, "host-bandwidth", 0, "download the bodies at most at this many `bytes` per second from each host, 0 for no cap")
+	fs.Var(&cfg.hostBandwidthFor, "host-bandwidth-for", "cap the bytes per second of a host by its own `host=bytes`, rather than -host-bandwidth; may be repeated")
+	fs.BoolVar(&cfg.headFirst, "head-first", false, "check the type and size of a response with a HEAD request before downloading it")
+	fs.StringVar(&cfg.cache, "cache", "", "remember the ETag and Last-Modified of pages in `file`, and only fetch again the ones that changed")
+	fs.StringVar(&cfg.cookies, "cookies", "", "keep the cookies the sites set in `file`, from one crawl to the next")
+	fs.StringVar(&cfg.userAgent, "user-agent", webcrawl.DefaultUserAgent, "the `name` to send to servers and to go by in robots.txt")
+	fs.BoolVar(&cfg.dnsCache, "dns-cache", false, "look every host up once for a minute rather than for every connection")
+	fs.StringVar(&cfg.dnsServers, "dns", "", "look the hosts up at these name `servers`, comma-separated, or auto for those of /etc/resolv.conf, caching the answers for their TTL")
+	fs.Var(&cfg.resolve, "resolve", "connect to `host=ip` rather than where DNS says, as for a staging server; may be repeated")
+	fs.Var(&cfg.headers, "header", "send the `Name: value` header with every request, or only to a host as host=Name: value; may be repeated")
+	fs.StringVar(&cfg.basicAuth, "basic-auth", "", "send these `user:password` credentials to the seed's host")
+	fs.StringVar(&cfg.bearer, "bearer", "", "send this bearer `token` to the seed's host")
+	fs.StringVar(&cfg.loginURL, "login", "", "log in by posting the -login-field fields to this `url` first")
+	fs.Var(&cfg.loginFields, "login-field", "a `name=value` field of the -login form, may be repeated")
+	fs.IntVar(&cfg.retries, "retries", 0, "how many `times` to retry a fetch that failed transiently")
+	fs.IntVar(&cfg.requeue, "requeue", 0, "how many `times` to queue again a URL whose fetch failed transiently, when it is found again")
+	fs.Float64Var(&cfg.rps, "rps", 0, "maximum `requests` per second to each host, 0 for no limit")
+	fs.DurationVar(&cfg.delay, "delay", 0, "minimum `delay` between two requests to the same host")
+	fs.Var(&cfg.windows, "window", "only crawl in this time of the day, `[host=]hh:mm-hh:mm[ zone]`, in UTC unless a zone such as Europe/Paris follows, for every host or for one; may be repeated")
+	fs.Var(&cfg.hostRates, "host-rate", "space out the requests to a host by its own `host=rps[,delay]`, rather than -rps and -delay; may be repeated")
+	fs.IntVar(&cfg.breaker, "breaker", webcrawl.DefaultCircuitBreaker.Failures, "hold back a host for a while after this many `failures` in a row, 0 never to")
+	fs.DurationVar(&cfg.cooldown, "breaker-cooldown", webcrawl.DefaultCircuitBreaker.Cooldown, "how `long` to hold back a failing host at first")
+	fs.BoolVar(&cfg.adaptive, "adaptive", false, "find how many pages to fetch at the same time from each host and in all, -workers at first, by how fast and well they answer")
+	fs.DurationVar(&cfg.adaptiveLatency, "adaptive-latency", 0, "with -adaptive, fetch less at the same time from a host answering slower than this `long`, 0 for twice its fastest")
+	fs.DurationVar(&cfg.maxBackoff, "max-backoff", webcrawl.DefaultBackoffPolicy.MaxDelay, "back off a host answering 429 or 503 for at most this `long`, 0 not to back off")
+	fs.BoolVar(&cfg.sitemaps, "sitemaps", false, "also crawl the URLs listed in the site's sitemaps")
+	fs.BoolVar(&cfg.documents, "documents", false, "check the links to PDFs, Word files and other documents with a HEAD request, whatever their depth")
+	fs.StringVar(&cfg.documentCommand, "document-command", "", "download the PDFs and take their text and links out with this `command`, reading the document on its standard input, such as \"pdftotext - -\"")
+	fs.BoolVar(&cfg.feeds, "feeds", false, "also crawl the entries of the RSS and Atom feeds the pages announce")
+	fs.StringVar(&cfg.scope, "scope", "host", "hosts to crawl besides the seed's: `host` (none), domain (its subdomains) or any")
+	fs.Var(&cfg.include, "include", "only crawl paths matching this `glob`, may be repeated")
+	fs.Var(&cfg.exclude, "exclude", "don't crawl paths matching this `glob`, may be repeated")
+	fs.Var(&cfg.filters, "filter", "only crawl the URLs passing this `rule`: regex:re, glob:pattern (a path, or host and path), ext:html,php or depth:from-to, ! in front negating it, rules joined by \" | \" passing the URLs one of them does, may be repeated for all of them")
+	fs.StringVar(&cfg.languages, "languages", "", "only follow the links of the pages in these comma-separated `languages`, such as en,fr")
+	fs.BoolVar(&cfg.detectLanguage, "detect-language", false, "detect the language of the pages, for the language field of the jsonl format")
+	fs.Var(&cfg.queryRules, "query", "normalize the query of the URLs by these comma-separated `rules`, for every host or only one as host=rules: sort, drop, tracking (utm_*, fbclid...), sessions (jsessionid...) or a parameter name to strip, * matching any end; may be repeated")
+	fs.BoolVar(&cfg.mergeWWW, "merge-www", false, "crawl www.example.com and example.com as one host, under the name the server prefers")
+	fs.BoolVar(&cfg.upgradeHTTPS, "upgrade-https", false, "crawl the http URLs of a host over https once it served a page over https")
+	fs.IntVar(&cfg.maxPathDepth, "max-path-depth", 0, "skip the URLs whose path has more than this many `segments`, 0 for no limit")
+	fs.IntVar(&cfg.maxRepeated, "max-repeated-segments", 0, "skip the URLs whose path has a segment more than this many `times`, 0 for no limit")
+	fs.IntVar(&cfg.maxQueries, "max-query-variants", 0, "crawl at most this many `URLs` with a query per path, 0 for no limit")
+	fs.IntVar(&cfg.maxDirURLs, "max-dir-urls", 0, "crawl at most this many `URLs` per directory, 0 for no limit")
+	fs.IntVar(&cfg.maxURLLength, "max-url-length", 0, "skip the URLs longer than this many `characters`, 0 for no limit")
+	fs.IntVar(&cfg.maxQueryParams, "max-query-params", 0, "skip the URLs with more than this many query `parameters`, 0 for no limit")
+	fs.BoolVar(&cfg.dryRun, "dry-run", false, "only fetch the seed, and list the URLs in the scope it links to that would be crawled")
+	fs.BoolVar(&cfg.ignoreRobots, "ignore-robots", false, "fetch pages even when robots.txt disallows them")
+	fs.DurationVar(&cfg.grace, "grace", 10*time.Second, "how long the fetches under way get to finish once interrupted")
+	fs.StringVar(&cfg.format, "format", "text", "output `format`: text, or jsonl for one JSON object per page")
+	fs.StringVar(&cfg.fields, "fields", "", "comma-separated `list` of the fields of the jsonl format, all when empty")
+	fs.Var(&cfg.scrape, "scrape", "scrape a field off the pages, for the fields field of the jsonl format: `[glob ]field=selector`, a CSS selector or XPath, may be repeated")
+	fs.Var(&cfg.match, "match", "tag the pages matching a content rule with its name, for the matched field of the jsonl format: `[follow ]name=rule`, the rule being css:selector, xpath:expr, regex:re or schema:Type; once a rule has follow, only the links of the pages matching one of those are followed; may be repeated")
+	fs.StringVar(&cfg.script, "script", "", "steer the crawl with the Starlark script at `path`, a .star file, its should_follow, extract and transform functions run in a sandbox, see webcrawl.StarlarkScript")
+	fs.Uint64Var(&cfg.scriptSteps, "script-steps", webcrawl.DefaultStarlarkSteps, "cut a call of the -script off after this many `steps`")
+	fs.DurationVar(&cfg.scriptTimeout, "script-timeout", webcrawl.DefaultScriptTimeout, "cut a call of the -script off after this long")
+	fs.BoolVar(&cfg.soft404, "soft-404", false, "flag the pages that are the one their host answers for URLs that don't exist, in the soft_404 field of the jsonl format")
+	fs.BoolVar(&cfg.structured, "structured-data", false, "parse the JSON-LD, microdata and OpenGraph of the pages, for the structured_data field of the jsonl format")
+	fs.BoolVar(&cfg.text, "text", false, "extract the main content of the pages as plain text, for the text and word_count fields of the jsonl format")
+	fs.StringVar(&cfg.output, "o", "", "write the output to `file` rather than the standard output")
+	fs.StringVar(&cfg.warc, "warc", "", "archive every request and response to the WARC `file`, gzipped when it ends in .gz")
+	fs.StringVar(&cfg.record, "record", "", "record every answer the crawl gets to the cassette `file`, for -replay")
+	fs.StringVar(&cfg.replay, "replay", "", "crawl the answers recorded in the cassette `file` by -record rather than the network")
+	fs.StringVar(&cfg.pageCache, "page-cache", "", "keep the pages fetched in `dir`, and parse again from it the ones the servers say did not change")
+	fs.BoolVar(&cfg.offline, "offline", false, "crawl the pages kept in the -page-cache alone, with no request")
+	fs.StringVar(&cfg.mirrorDir, "mirror", "", "save the pages, with their images, stylesheets and scripts, to `dir`, their links rewritten for the copy to be browsed offline")
+	fs.BoolVar(&cfg.assets, "assets", false, "also fetch the images, stylesheets and scripts of the pages, and list the pages by weight with their missing assets at the end")
+	fs.BoolVar(&cfg.checkAssets, "check-assets", false, "with -assets, only check the assets rather than download them, which leaves their weight out")
+	fs.BoolVar(&cfg.hreflangReport, "hreflang", false, "validate the hreflang annotations of the pages, and list their inconsistencies at the end")
+	fs.BoolVar(&cfg.followCanonical, "follow-canonical", false, "crawl the canonical of the pages declaring another one in the scope instead of following their links, for the canonical field of the jsonl format")
+	fs.BoolVar(&cfg.canonicalReport, "canonicals", false, "validate the canonicals of the pages, and list those not answering 200, the chains and the loops at the end")
+	fs.BoolVar(&cfg.security, "security", false, "list the security issues of the pages by host at the end: mixed content, links and redirects to HTTP, missing HSTS, insecure cookies")
+	fs.BoolVar(&cfg.accessibility, "accessibility", false, "list the accessibility issues of the pages at the end: images without alt text, empty links and buttons, skipped heading levels, missing lang and form labels")
+	fs.BoolVar(&cfg.brokenLinks, "broken-links", false, "also check the links leaving the scope, and list the broken links by page at the end")
+	fs.BoolVar(&cfg.duplicates, "duplicates", false, "don't follow the links of pages already crawled under another URL, and list the duplicate pages at the end")
+	fs.IntVar(&cfg.nearDuplicates, "near-duplicates", -1, "with -duplicates, also list the pages whose text is at most this many `bits` of simhash apart")
+	fs.BoolVar(&cfg.summary, "summary", false, "sum the crawl up at the end: statuses, errors, latency percentiles, bytes, and the largest, slowest and deepest pages")
+	fs.StringVar(&cfg.summaryJSON, "summary-json", "", "write the summary of the crawl, as JSON, to `file` at the end")
+	fs.StringVar(&cfg.seoFile, "seo", "", "write the SEO tags of every page and their issues, as JSON, to `file` at the end")
+	fs.StringVar(&cfg.indexDir, "index", "", "index the URL, title and text of the pages in the Bleve full-text index at `dir`, creating it if need be")
+	fs.StringVar(&cfg.dbFlag, "db", "", "save the results to the SQLite file or the postgres:// `database`, see package store for the schema")
+	fs.StringVar(&cfg.bucketURL, "bucket", "", "write the bodies of the pages, and a manifest of them, to the s3:// or gs:// `url`, see package bucket for the credentials")
+	fs.StringVar(&cfg.publish, "publish", "", "publish every result to the kafka://broker/topic or nats://host/subject `url`")
+	fs.StringVar(&cfg.publishFormat, "publish-format", "json", "`format` of the -publish messages: json, with the -fields of the jsonl format, or protobuf")
+	fs.StringVar(&cfg.webhookURL, "webhook", "", "post the start and the end of the crawl, as JSON, to `url`")
+	fs.StringVar(&cfg.webhookSecret, "webhook-secret", "", "sign the -webhook posts with this `key`, in their X-Webcrawl-Signature header")
+	fs.Float64Var(&cfg.webhookErrorRate, "webhook-error-rate", 0, "also post to the -webhook once this `share` of the fetches failed, between 0 and 1, after 10 of them at least; 0 never to")
+	fs.IntVar(&cfg.webhookBatch, "webhook-batch", 0, "also post the results to the -webhook, by batches of this many `results`, 0 not to")
+	fs.StringVar(&cfg.harFile, "har", "", "write the requests of the crawl as an HTTP Archive to `file` at the end")
+	fs.BoolVar(&cfg.harBodies, "har-bodies", false, "have the bodies of the pages in the -har archive too")
+	fs.StringVar(&cfg.outboundCSV, "outbound-csv", "", "write a CSV inventory of the links leaving the scope, with their source page and anchor text, to `file` at the end")
+	fs.StringVar(&cfg.heatmapCSV, "heatmap-csv", "", "write the pages fetched, the average latency and the error rate of each domain as CSV to `file` at the end")
+	fs.BoolVar(&cfg.checkOutbound, "check-outbound", false, "check each link leaving the scope once, for the status column of -outbound-csv")
+	fs.StringVar(&cfg.linksCSV, "links-csv", "", "write a CSV report of every link found to `file` at the end")
+	fs.StringVar(&cfg.linksDOT, "links-dot", "", "write the graph of the links between pages to `file` at the end, for Graphviz")
+	fs.StringVar(&cfg.linksGraphML, "links-graphml", "", "write the graph of the links between pages to `file` at the end, as GraphML")
+	fs.StringVar(&cfg.sitemapOut, "sitemap-out", "", "write a sitemap of the pages to be indexed, answered 200, not noindex and their own canonical, to `dir` at the end, split with an index past 50,000 URLs")
+	fs.StringVar(&cfg.sitemapBase, "sitemap-base", "", "the `url` the -sitemap-out dir is served from, for its index, the root of the seed's host by default; with pages of several hosts, the path on each host its directory of -sitemap-out is served from")
+	fs.BoolVar(&cfg.ranks, "ranks", false, "list the pages by PageRank at the end, with their in-links and out-links, and flag the orphans")
+	fs.StringVar(&cfg.logLevel, "log-level", "warn", "log events from this `level` up: debug, info, warn or error")
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "log `format`, text or json")
+	fs.StringVar(&cfg.metrics, "metrics", "", "serve Prometheus metrics on `addr`/metrics, such as :9090")
+	fs.StringVar(&cfg.otlp, "otlp", "", "trace every page through the crawl to this OpenTelemetry collector `url`, OTLP over HTTP, such as http://localhost:4318")
+	fs.StringVar(&cfg.controlAddr, "control", "", "serve the control API on `addr`, such as localhost:9091, to see the status of the crawl, pause and resume it, change the -workers and add seeds as it goes, see Crawler.ControlHandler")
+	fs.StringVar(&cfg.debugAddr, "debug", "", "serve pprof, runtime stats and a goroutine leak check on `addr`, such as localhost:6060, see Crawler.DebugHandler")
+	fs.BoolVar(&cfg.progress, "progress", false, "show the pages per second, the queues of the hosts, the latest errors and the time left on the terminal as the crawl goes, rather than the pages found")
+	fs.IntVar(&cfg.bloom, "bloom", 0, "keep the URLs seen in a bloom filter sized for this many `urls` rather than in memory, for huge crawls: some URLs may be taken for seen and not crawled")
+	fs.Float64Var(&cfg.bloomFP, "bloom-fp", webcrawl.DefaultFalsePositiveRate, "the false positive `rate` of -bloom")
+	fs.StringVar(&cfg.spill, "spill", "", "keep at most -spill-memory URLs of the frontier in memory and spill the others to `dir`, for huge crawls")
+	fs.IntVar(&cfg.spillMemory, "spill-memory", webcrawl.DefaultInMemory, "how many `urls` of the frontier -spill keeps in memory")
+	fs.StringVar(&cfg.redisURL, "redis", "", "share the crawl with the other webcrawl processes given the same seed and `url`, redis://[:password@]host[:port][/db], each crawling the hosts it is assigned")
+	fs.StringVar(&cfg.redisName, "redis-name", "webcrawl", "the `name` of the shared crawl, the prefix of its keys in Redis")
+	fs.StringVar(&cfg.coordinator, "coordinator", "", "be a worker of the webcrawl coordinate at `url`, crawling the hosts it hands out and handing the results in")
+	fs.StringVar(&cfg.state, "state", "", "save the progress of the crawl to `file`")
+	fs.StringVar(&cfg.config, "config", "", "read the flags, and the seeds, from this YAML or TOML `file`, the flags given on the command line overriding it")
+	fs.StringVar(&cfg.seedsFile, "seeds-from", "", "crawl the URLs of this `file` as seeds too, one per line, - for the standard input")
+	fs.Var(&cfg.seedSitemaps, "seeds-sitemap", "crawl the URLs of the sitemap at this `url` as seeds too, can be repeated")
+	fs.StringVar(&cfg.snapshot, "snapshot", "", "save the status, title and content hash of every page to `file` at the end, for webcrawl diff")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: webcrawl %s [flags] url... | dir\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// crawl is the crawl command, and the resume one with resume
+func crawl(args []string, resume bool) int {
+	name := "crawl"
+	if resume {
+		name = "resume"
+	}
+	cfg := &crawlConfig{resume: resume}
+	fs := crawlFlags(name, cfg)
+	fs.Parse(args)
+	cfg.seeds = fs.Args()
+	if cfg.config != "" {
+		seeds, err := applyConfig(fs, cfg.config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 2
+		}
+		if len(cfg.seeds) == 0 {
+			cfg.seeds = seeds
+		}
+	}
+	if cfg.seedsFile != "" {
+		seeds, err := readSeeds(cfg.seedsFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 2
+		}
+		cfg.seeds = append(cfg.seeds, seeds...)
+	}
+	if len(cfg.seeds) == 0 && len(cfg.seedSitemaps) == 0 {
+		fs.Usage()
+		return 2
+	}
+	if cfg.progress && !isSet(fs, "log-level") {
+		// The warnings would scroll the dashboard away, it has the errors
+		cfg.logLevel = "error"
+	}
+
+	out := os.Stdout
+	if cfg.output != "" {
+		var err error
+		if out, err = os.Create(cfg.output); err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 1
+		}
+		defer out.Close()
+	}
+	j, err := buildCrawler(cfg, out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		if errors.As(err, new(badFlags)) {
+			return 2
+		}
+		return 1
+	}
+	defer j.close()
+	j.listen()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, func() {
+		// Back to the default handling, so another interrupt kills us
+		stop()
+		fmt.Fprintf(os.Stderr, "webcrawl: interrupted, waiting up to %v for the fetches under way\n", cfg.grace)
+	})
+	err = j.run(ctx)
+	if ferr := j.finish(ctx, out, err); ferr != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", ferr)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 1
+	}
+	return 0
+}
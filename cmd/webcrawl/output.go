@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// newOutput returns what to do with each result for the -format and
+// -fields flags, writing to w
+func newOutput(w io.Writer, format, fields string) (func(webcrawl.CrawlResult), error) {
+	switch format {
+	case "text":
+		return func(r webcrawl.CrawlResult) { printResult(w, r) }, nil
+	case "jsonl":
+		var names []string
+		if fields != "" {
+			names = strings.Split(fields, ",")
+		}
+		jw, err := webcrawl.NewJSONLWriter(w, names...)
+		if err != nil {
+			return nil, err
+		}
+		return func(r webcrawl.CrawlResult) {
+			if err := jw.WriteResult(r); err != nil {
+				fmt.Fprintln(os.Stderr, "webcrawl:", err)
+				os.Exit(1)
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, want text or jsonl", format)
+}
+
+// printResult prints the pages found, the failures are logged
+func printResult(w io.Writer, r webcrawl.CrawlResult) {
+	if r.Err != nil {
+		return
+	}
+	if r.Skipped == webcrawl.SkippedDryRun {
+		fmt.Fprintf(w, "would crawl: %s (depth %d)\n", r.URL, r.Depth)
+		return
+	}
+	if r.Soft404 {
+		fmt.Fprintf(w, "soft 404: %s (depth %d)\n", r.URL, r.Depth)
+		return
+	}
+	fmt.Fprintf(w, "found: %s %q (depth %d, %v)\n", r.URL, r.Body, r.Depth, r.Duration.Round(time.Millisecond))
+}
+
+// report is the report command, summing up the crawl of a jsonl file
+func report(args []string) int {
+	fs := flag.NewFlagSet("webcrawl report", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the summary as JSON")
+	top := fs.Int("top", webcrawl.DefaultSummaryTop, "list this many of the largest, slowest and deepest `pages`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: webcrawl report [flags] file.jsonl\n\nSums up a crawl written by webcrawl crawl -format jsonl, - for the standard input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	in := os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	sums := &webcrawl.SummaryReport{Top: *top}
+	jr := webcrawl.NewJSONLReader(in)
+	for {
+		res, err := jr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 1
+		}
+		sums.Add(res)
+	}
+	write := sums.WriteText
+	if *asJSON {
+		write = sums.WriteJSON
+	}
+	if err := write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 1
+	}
+	return 0
+}
+
+// diff is the diff command, listing what changed from one snapshot to
+// another
+func diff(args []string) int {
+	fs := flag.NewFlagSet("webcrawl diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: webcrawl diff old new\n\nLists the pages added, removed and changed from the crawl saved by webcrawl crawl -snapshot old to the one saved to new.")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	var snaps [2]*webcrawl.Snapshot
+	for i, path := range fs.Args() {
+		if _, err := os.Stat(path); err != nil {
+			// LoadSnapshot takes a missing file for an empty snapshot
+			fmt.Fprintln(os.Stderr, "webcrawl:", err)
+			return 1
+		}
+		s, err := webcrawl.LoadSnapshot(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "webcrawl: %s: %v\n", path, err)
+			return 1
+		}
+		snaps[i] = s
+	}
+	if err := webcrawl.WriteChanges(os.Stdout, webcrawl.DiffSnapshots(snaps[0], snaps[1])); err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 1
+	}
+	return 0
+}
+
+// selftest is the selftest command, crawling the reference site of
+// testsite.SelfTest and printing how it went
+func selftest(args []string) int {
+	fs := flag.NewFlagSet("webcrawl selftest", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: webcrawl selftest\n\nCrawls a reference site of 20 pages on a local port and checks the pages, links, titles and PageRank found.")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	r := testsite.RunSelfTest(context.Background())
+	fmt.Print(r.String())
+	if r.Failed() {
+		return 1
+	}
+	return 0
+}
+
+// writeFile creates the file called name and has write fill it
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// dashboardHosts is how many hosts the -progress dashboard lists
+const dashboardHosts = 5
+
+// dashboard draws the -progress view of a crawl on a terminal, in place,
+// every interval until stopped
+type dashboard struct {
+	p     *webcrawl.Progress
+	w     *os.File
+	tty   bool // redraws in place, else draws once when stopped
+	width int  // of the terminal, the longer lines are cut
+	lines int  // drawn last time, to go back up over
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// startDashboard starts drawing p on w
+func startDashboard(p *webcrawl.Progress, w *os.File, interval time.Duration) *dashboard {
+	d := &dashboard{p: p, w: w, tty: isTerminal(w), width: 80, quit: make(chan struct{}), done: make(chan struct{})}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		d.width = n
+	}
+	go func() {
+		defer close(d.done)
+		if !d.tty {
+			return
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			d.draw()
+			select {
+			case <-t.C:
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+	return d
+}
+
+// stop stops the redraws, and draws the final state of the crawl
+func (d *dashboard) stop() {
+	close(d.quit)
+	<-d.done
+	d.draw()
+}
+
+// draw draws the progress over the previous drawing
+func (d *dashboard) draw() {
+	var text bytes.Buffer
+	d.p.WriteText(&text, dashboardHosts)
+	var buf bytes.Buffer
+	if d.tty && d.lines > 0 {
+		// Back to the first line drawn, and clear down from there
+		fmt.Fprintf(&buf, "\x1b[%dF\x1b[J", d.lines)
+	}
+	d.lines = 0
+	for _, line := range strings.Split(strings.TrimSuffix(text.String(), "\n"), "\n") {
+		if d.tty && len(line) >= d.width {
+			// A line wrapping over two would throw the count off
+			line = line[:d.width-1]
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		d.lines++
+	}
+	d.w.Write(buf.Bytes())
+}
+
+// isTerminal reports whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/rpc"
+)
+
+// serve is the serve command, serving the gRPC service of package rpc for
+// other services to start crawls and take their results
+func serve(args []string) int {
+	fs := flag.NewFlagSet("webcrawl serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:9092", "serve gRPC on `addr`")
+	timeout := fs.Duration("timeout", webcrawl.DefaultTimeout, "per-request `timeout`")
+	userAgent := fs.String("user-agent", webcrawl.DefaultUserAgent, "the `name` to send to servers and to go by in robots.txt")
+	ignoreRobots := fs.Bool("ignore-robots", false, "fetch pages even when robots.txt disallows them")
+	logLevel := fs.String("log-level", "warn", "log events from this `level` up: debug, info, warn or error")
+	logFormat := fs.String("log-format", "text", "log `format`, text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: webcrawl serve [flags]\n\nServes the Crawler service of rpc/crawler.proto over gRPC, without TLS.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 2
+	}
+	s := &rpc.Server{Options: []webcrawl.Option{
+		webcrawl.WithFetcher(webcrawl.NewHTTPFetcher(*timeout)),
+		webcrawl.WithUserAgent(*userAgent),
+		webcrawl.WithLogger(logger),
+	}}
+	if *ignoreRobots {
+		s.Options = append(s.Options, webcrawl.WithoutRobots())
+	}
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 1
+	}
+	g := grpc.NewServer()
+	rpc.RegisterCrawlerServer(g, s)
+	if err := g.Serve(lis); err != nil {
+		fmt.Fprintln(os.Stderr, "webcrawl:", err)
+		return 1
+	}
+	return 0
+}
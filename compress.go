@@ -0,0 +1,68 @@
+package webcrawl
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// AcceptEncoding is the Accept-Encoding header an HTTPFetcher sends,
+// unless its Header has one: the encodings it decompresses. Set the
+// header to "identity" for the servers to send bodies as they are.
+const AcceptEncoding = "gzip, deflate, br"
+
+// decompress returns a reader of body decoded from the Content-Encoding
+// encoding, gzip, deflate, br or of several of them in a row. A body it
+// can't decode fails to read, with an error telling so
+func decompress(body io.Reader, encoding string) io.Reader {
+	codings := strings.Split(encoding, ",")
+	// Applied in order, so undone the other way round
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch c := strings.ToLower(strings.TrimSpace(codings[i])); c {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				return errReader{fmt.Errorf("gzip: %w", err)}
+			}
+			body = zr
+		case "deflate":
+			body = inflate(body)
+		case "br":
+			body = brotli.NewReader(body)
+		default:
+			return errReader{fmt.Errorf("unsupported Content-Encoding %q", c)}
+		}
+	}
+	return body
+}
+
+// inflate returns a reader of the deflate body, which is meant to be in
+// the zlib format but is raw deflate for some servers
+func inflate(body io.Reader) io.Reader {
+	br := bufio.NewReader(body)
+	head, err := br.Peek(2)
+	if err != nil {
+		return errReader{fmt.Errorf("deflate: %w", err)}
+	}
+	// A zlib header is a multiple of 31, with the deflate method
+	if head[0]&0x0f == 8 && (uint(head[0])<<8|uint(head[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return errReader{fmt.Errorf("deflate: %w", err)}
+		}
+		return zr
+	}
+	return flate.NewReader(br)
+}
+
+// errReader is a body that fails to read with err
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
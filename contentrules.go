@@ -0,0 +1,181 @@
+package webcrawl
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// ContentRule matches the HTML pages by what they hold: an element a CSS
+// selector or an XPath expression finds, a regexp the body matches, or an
+// item of a schema.org type in their structured data. The pages it
+// matches have its Name in their CrawlResult.Matched, see
+// Crawler.ContentRules, for a focused crawl to keep to the pages on topic.
+type ContentRule struct {
+	Name string
+
+	// CSS, XPath, Regexp and Schema are what the page must hold, one of
+	//   them is to be set. An XPath expression computing a value, as
+	//   count(//article) > 2, matches when it is true, or a number other
+	//   than 0, or a string other than "". Schema is a type such as
+	//   "Product", matched in the JSON-LD and microdata of the page
+	CSS    string
+	XPath  string
+	Regexp *regexp.Regexp
+	Schema string
+
+	// Follow has the links of the pages followed only when they match
+	//   one of the rules with Follow, see ContentRules
+	Follow bool
+}
+
+// ContentRules are the ContentRules of a crawl compiled, see
+// Crawler.ContentRules. They can be shared by any number of goroutines.
+type ContentRules struct {
+	rules  []contentRule
+	follow bool // one of them has Follow
+}
+
+type contentRule struct {
+	ContentRule
+	css   cascadia.Selector
+	xpath *xpath.Expr
+}
+
+// NewContentRules returns ContentRules with rules, failing on a rule
+// without a name or what to match, or with a selector or expression that
+// doesn't compile.
+func NewContentRules(rules ...ContentRule) (*ContentRules, error) {
+	cr := &ContentRules{}
+	for _, r := range rules {
+		c := contentRule{ContentRule: r}
+		set := 0
+		for _, on := range []bool{r.CSS != "", r.XPath != "", r.Regexp != nil, r.Schema != ""} {
+			if on {
+				set++
+			}
+		}
+		var err error
+		switch {
+		case r.Name == "":
+			return nil, fmt.Errorf("webcrawl: content rule without a name")
+		case set != 1:
+			return nil, fmt.Errorf("webcrawl: content rule %s: want one of CSS, XPath, Regexp and Schema", r.Name)
+		case r.CSS != "":
+			c.css, err = cascadia.Compile(r.CSS)
+		case r.XPath != "":
+			c.xpath, err = xpath.Compile(r.XPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("webcrawl: content rule %s: %w", r.Name, err)
+		}
+		cr.follow = cr.follow || r.Follow
+		cr.rules = append(cr.rules, c)
+	}
+	return cr, nil
+}
+
+// Match returns the names of the rules the HTML document doc matches,
+// found at base with body, in the order of the rules, nil when none does.
+func (cr *ContentRules) Match(base *url.URL, doc *html.Node, body string) []string {
+	var types map[string]bool // of the structured data, once needed
+	var matched []string
+	for _, r := range cr.rules {
+		ok := false
+		switch {
+		case r.css != nil:
+			ok = r.css.MatchFirst(doc) != nil
+		case r.xpath != nil:
+			switch v := r.xpath.Evaluate(htmlquery.CreateXPathNavigator(doc)).(type) {
+			case *xpath.NodeIterator:
+				ok = v.MoveNext()
+			case bool:
+				ok = v
+			case float64:
+				ok = v != 0
+			case string:
+				ok = v != ""
+			}
+		case r.Regexp != nil:
+			ok = r.Regexp.MatchString(body)
+		default:
+			if types == nil {
+				types = schemaTypes(ExtractStructuredData(base, doc))
+			}
+			ok = types[strings.ToLower(r.Schema)]
+		}
+		if ok {
+			matched = append(matched, r.Name)
+		}
+	}
+	return matched
+}
+
+// Follows reports whether the links of a page matching the rules of
+// matched are to be followed: always when no rule has Follow.
+func (cr *ContentRules) Follows(matched []string) bool {
+	if !cr.follow {
+		return true
+	}
+	for _, r := range cr.rules {
+		if r.Follow {
+			for _, name := range matched {
+				if name == r.Name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// schemaTypes returns the schema.org types of the items of sd, lowercase
+// and without their URL: "product" for https://schema.org/Product
+func schemaTypes(sd *StructuredData) map[string]bool {
+	types := make(map[string]bool)
+	if sd == nil {
+		return types
+	}
+	add := func(t string) {
+		if i := strings.LastIndexAny(t, "/#"); i >= 0 {
+			t = t[i+1:]
+		}
+		types[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]any:
+			switch t := v["@type"].(type) {
+			case string:
+				add(t)
+			case []any:
+				for _, e := range t {
+					if s, ok := e.(string); ok {
+						add(s)
+					}
+				}
+			}
+			walk(v["@graph"])
+		}
+	}
+	for _, o := range sd.JSONLD {
+		walk(o)
+	}
+	for _, it := range sd.Microdata {
+		for _, t := range it.Type {
+			add(t)
+		}
+	}
+	return types
+}
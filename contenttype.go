@@ -0,0 +1,68 @@
+package webcrawl
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentFilter tells an HTTPFetcher which responses not to download, by
+// what their headers announce, such as the videos and archives of a site
+// when only its pages matter:
+//
+//	f.Filter = &webcrawl.ContentFilter{
+//		Accept:    []string{"text/*", "application/xhtml+xml"},
+//		MaxLength: 10 << 20,
+//	}
+//
+// A response with no Content-Type or Content-Length header is downloaded,
+// there is no telling what it holds.
+type ContentFilter struct {
+	// Accept lists the media types downloaded, a "type/*" standing for
+	//   every subtype. When empty every type is, but those of Reject
+	Accept []string
+	Reject []string
+
+	// MaxLength is the largest Content-Length downloaded, zero means no
+	//   limit
+	MaxLength int64
+}
+
+// Skip returns why the response with the headers h is not to be
+// downloaded, such as "content type video/mp4", empty if it is.
+func (f *ContentFilter) Skip(h http.Header) string {
+	if f == nil {
+		return ""
+	}
+	if ct := h.Get("Content-Type"); ct != "" {
+		t, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			t = strings.ToLower(strings.TrimSpace(ct))
+		}
+		if len(f.Accept) > 0 && !matchType(f.Accept, t) || matchType(f.Reject, t) {
+			return "content type " + t
+		}
+	}
+	if f.MaxLength > 0 {
+		if n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil && n > f.MaxLength {
+			return fmt.Sprintf("content length %d", n)
+		}
+	}
+	return ""
+}
+
+// matchType reports whether the media type t is one of types
+func matchType(types []string, t string) bool {
+	for _, p := range types {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == t || p == "*/*" {
+			return true
+		}
+		if major, ok := strings.CutSuffix(p, "/*"); ok && strings.HasPrefix(t, major+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package webcrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCrawl is returned by the controls of a Crawler that need a Run or a
+// Resume under way when there is none.
+var ErrNoCrawl = errors.New("webcrawl: no crawl under way")
+
+// RunStatus is where a Run or Resume under way stands, see Crawler.Status.
+type RunStatus struct {
+	Seed     string `json:"seed"`
+	Paused   bool   `json:"paused"`
+	Workers  int    `json:"workers"`
+	Queued   int    `json:"queued"` // URLs waiting in the frontier, held for their host or for their retry
+	InFlight int    `json:"in_flight"`
+	Fetched  int    `json:"fetched"` // failed or not
+	Failed   int    `json:"failed"`
+	Bytes    int64  `json:"bytes"`
+
+	// Elapsed is how long the crawl has been going, in nanoseconds in
+	//   JSON, Rate the pages fetched per second over that time
+	Elapsed time.Duration `json:"elapsed"`
+	Rate    float64       `json:"pages_per_second"`
+}
+
+// control is a change to a run under way, which its dispatcher makes
+// between two pages lest it race with the crawl
+type control struct {
+	do    func(*run) error
+	reply chan error
+}
+
+// track registers r as under way, for the controls to reach it, until the
+// returned func is called
+func (c *Crawler) track(r *run) (untrack func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runs == nil {
+		c.runs = make(map[*run]bool)
+	}
+	c.runs[r] = true
+	return func() {
+		c.mu.Lock()
+		delete(c.runs, r)
+		c.mu.Unlock()
+	}
+}
+
+// control has the dispatchers of the runs under way do what do says, and
+// returns the first error. It returns ErrNoCrawl when no run is under way
+func (c *Crawler) control(do func(*run) error) error {
+	c.mu.Lock()
+	runs := make([]*run, 0, len(c.runs))
+	for r := range c.runs {
+		runs = append(runs, r)
+	}
+	c.mu.Unlock()
+	if len(runs) == 0 {
+		return ErrNoCrawl
+	}
+	var first error
+	for _, r := range runs {
+		ctl := control{do: do, reply: make(chan error, 1)}
+		var err error
+		select {
+		case r.controls <- ctl:
+			err = <-ctl.reply
+		case <-r.over:
+			// It returned in the meantime
+			continue
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Pause holds off the crawls under way, and those started later on, until
+// Unpause: no page is fetched but the ones under way. A paused crawl
+// doesn't return once its frontier is done, it waits for Unpause, or for
+// Stop.
+func (c *Crawler) Pause() {
+	c.setPaused(true)
+}
+
+// Unpause goes on with the crawls Pause held off.
+func (c *Crawler) Unpause() {
+	c.setPaused(false)
+}
+
+func (c *Crawler) setPaused(paused bool) {
+	c.mu.Lock()
+	c.paused = paused
+	c.mu.Unlock()
+	c.control(func(r *run) error {
+		r.paused = paused
+		return nil
+	})
+}
+
+// SetWorkers changes how many pages the crawls under way, and those
+// started later on, fetch at the same time, MaxWorkers being where they
+// start. The fetches under way beyond n still finish.
+func (c *Crawler) SetWorkers(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("webcrawl: want 1 worker or more, not %d", n)
+	}
+	c.mu.Lock()
+	c.limit = n
+	c.mu.Unlock()
+	err := c.control(func(r *run) error {
+		r.limit = n
+		return nil
+	})
+	if err == ErrNoCrawl {
+		return nil
+	}
+	return err
+}
+
+// AddSeeds adds urls to the crawls under way, at depth 0. They must be in
+// the Scope of the seed of the crawl, the URLs crawled already are not
+// fetched again. It returns ErrNoCrawl when no crawl is under way, and an
+// error for the first URL that can't be crawled, adding none of them.
+func (c *Crawler) AddSeeds(urls ...string) error {
+	return c.control(func(r *run) error {
+		seeds := make([]string, 0, len(urls))
+		for _, url := range urls {
+			u, err := r.norm.Normalize(url)
+			if err != nil {
+				return err
+			}
+			if r.fetcherFor(u) == nil {
+				return fmt.Errorf("webcrawl: no Fetcher for the scheme of %s", u)
+			}
+			if r.Scope != nil {
+				pu, _ := neturl.Parse(u)
+				if !r.Scope.InScope(r.seedURL, pu) {
+					return fmt.Errorf("webcrawl: %s is out of the scope of %s", u, r.seed)
+				}
+			}
+			seeds = append(seeds, u)
+		}
+		for _, u := range seeds {
+			r.admit(FrontierItem{URL: u})
+		}
+		return nil
+	})
+}
+
+// Status returns where the crawls under way stand, none when there are
+// none.
+func (c *Crawler) Status() []RunStatus {
+	var statuses []RunStatus
+	c.control(func(r *run) error {
+		st := RunStatus{Seed: r.seed, Paused: r.paused, Workers: r.limit,
+			Queued: r.frontier.Len() + r.held + len(r.delayed), InFlight: r.pending,
+			Fetched: r.fetched, Failed: len(r.report.Errors), Bytes: r.bytes,
+			Elapsed: time.Since(r.started)}
+		if s := st.Elapsed.Seconds(); s > 0 {
+			st.Rate = float64(r.fetched) / s
+		}
+		statuses = append(statuses, st)
+		return nil
+	})
+	return statuses
+}
+
+// ControlHandler returns an HTTP API for c, mount it on a server of its
+// own, it has no authentication:
+//
+//	go http.ListenAndServe("localhost:9091", c.ControlHandler())
+//
+// with the endpoints
+//
+//	GET  /status         the RunStatus of the crawls under way, as a JSON array
+//	POST /pause          Pause
+//	POST /pause?for=2h   PauseUntil, or until=2006-01-02T15:04:05Z, and with host=example.com PauseHost
+//	POST /resume         Unpause
+//	POST /workers?n=16   SetWorkers
+//	POST /seeds?url=...  AddSeeds, the url parameters or the lines of a text/plain body
+//
+// The POSTs answer 204 No Content, 400 for a bad request and 409 Conflict
+// when it needs a crawl under way and there is none.
+func (c *Crawler) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, req *http.Request) {
+		statuses := c.Status()
+		if statuses == nil {
+			statuses = []RunStatus{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(statuses)
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, req *http.Request) {
+		var until time.Time
+		if v := req.FormValue("for"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, "want a duration as for, such as 2h", http.StatusBadRequest)
+				return
+			}
+			until = time.Now().Add(d)
+		} else if v := req.FormValue("until"); v != "" {
+			var err error
+			if until, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "want an RFC 3339 time as until", http.StatusBadRequest)
+				return
+			}
+		}
+		host := req.FormValue("host")
+		switch {
+		case host != "" && until.IsZero():
+			http.Error(w, "want for or until to pause a host", http.StatusBadRequest)
+		case host != "":
+			controlReply(w, c.PauseHost(host, until))
+		case !until.IsZero():
+			controlReply(w, c.PauseUntil(until))
+		default:
+			c.Pause()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, req *http.Request) {
+		c.Unpause()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /workers", func(w http.ResponseWriter, req *http.Request) {
+		n, err := strconv.Atoi(req.FormValue("n"))
+		if err != nil {
+			http.Error(w, "want the number of workers as n", http.StatusBadRequest)
+			return
+		}
+		controlReply(w, c.SetWorkers(n))
+	})
+	mux.HandleFunc("POST /seeds", func(w http.ResponseWriter, req *http.Request) {
+		urls := req.URL.Query()["url"]
+		if mt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mt == "text/plain" {
+			sc := bufio.NewScanner(req.Body)
+			for sc.Scan() {
+				if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+					urls = append(urls, line)
+				}
+			}
+			if err := sc.Err(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if len(urls) == 0 {
+			http.Error(w, "want the urls as url parameters or a text/plain body", http.StatusBadRequest)
+			return
+		}
+		controlReply(w, c.AddSeeds(urls...))
+	})
+	return mux
+}
+
+// controlReply answers a POST of the control API that did what it was
+// asked to, or failed with err
+func controlReply(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case err == ErrNoCrawl:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
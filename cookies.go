@@ -0,0 +1,141 @@
+package webcrawl
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar is an http.CookieJar that can be saved to disk and loaded
+// back, so that the session and consent cookies a site sets during a
+// crawl are still there for the next one. Session cookies are kept too,
+// a crawl being one session. Set it as the Jar of HTTPOptions, or of the
+// http.Client of an HTTPFetcher.
+//
+// A CookieJar is safe for concurrent use, make one with NewCookieJar or
+// LoadCookieJar.
+type CookieJar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[cookieKey]savedCookie // as they were set, for Save
+}
+
+type cookieKey struct{ host, domain, path, name string }
+
+// savedCookie is a cookie as Save writes it, with the URL that set it
+type savedCookie struct {
+	URL      string
+	Name     string
+	Value    string
+	Domain   string        `json:",omitempty"`
+	Path     string        `json:",omitempty"`
+	Expires  *time.Time    `json:",omitempty"` // nil for a session cookie
+	Secure   bool          `json:",omitempty"`
+	HttpOnly bool          `json:",omitempty"`
+	SameSite http.SameSite `json:",omitempty"`
+}
+
+// NewCookieJar returns an empty CookieJar, which goes by the public
+// suffix list to keep sites from setting cookies for a whole TLD.
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List}) // never fails
+	return &CookieJar{jar: jar, cookies: make(map[cookieKey]savedCookie)}
+}
+
+// LoadCookieJar reads a CookieJar saved at path, a missing file is an
+// empty jar. The cookies that expired since are dropped.
+func LoadCookieJar(path string) (*CookieJar, error) {
+	j := NewCookieJar()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved []savedCookie
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, err
+	}
+	for _, sc := range saved {
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			continue
+		}
+		c := &http.Cookie{Name: sc.Name, Value: sc.Value, Domain: sc.Domain, Path: sc.Path,
+			Secure: sc.Secure, HttpOnly: sc.HttpOnly, SameSite: sc.SameSite}
+		if sc.Expires != nil {
+			if !sc.Expires.After(time.Now()) {
+				continue
+			}
+			c.Expires = *sc.Expires
+		}
+		j.SetCookies(u, []*http.Cookie{c})
+	}
+	return j, nil
+}
+
+// Save writes the cookies to path, as JSON, through a temporary file.
+func (j *CookieJar) Save(path string) error {
+	j.mu.Lock()
+	saved := make([]savedCookie, 0, len(j.cookies))
+	now := time.Now()
+	for _, sc := range j.cookies {
+		if sc.Expires == nil || sc.Expires.After(now) {
+			saved = append(saved, sc)
+		}
+	}
+	j.mu.Unlock()
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SetCookies implements http.CookieJar.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		k := cookieKey{u.Hostname(), c.Domain, c.Path, c.Name}
+		sc := savedCookie{URL: u.String(), Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+			Secure: c.Secure, HttpOnly: c.HttpOnly, SameSite: c.SameSite}
+		switch {
+		case c.MaxAge < 0:
+			delete(j.cookies, k)
+			continue
+		case c.MaxAge > 0:
+			t := now.Add(time.Duration(c.MaxAge) * time.Second)
+			sc.Expires = &t
+		case !c.Expires.IsZero():
+			if !c.Expires.After(now) {
+				delete(j.cookies, k)
+				continue
+			}
+			t := c.Expires
+			sc.Expires = &t
+		}
+		j.cookies[k] = sc
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
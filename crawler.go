@@ -0,0 +1,1647 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxWorkers is the number of pages fetched in parallel when
+// MaxWorkers is not set
+const DefaultMaxWorkers = 8
+
+// SkippedDryRun is the CrawlResult.Skipped of the URLs a DryRun crawl would
+// have fetched
+const SkippedDryRun = "dry run"
+
+// SkippedBodyTooShort starts the CrawlResult.Skipped of the pages whose body
+// is shorter than MinBodyBytes, "body-too-short:" and its length following
+const SkippedBodyTooShort = "body-too-short"
+
+// Crawler crawls the pages reachable from a seed URL using its Fetcher.
+// Set up its fields directly, or have NewCrawler do it from options.
+//
+// Every URL is fetched at most once per call to Crawl, no matter how many
+// pages link to it.
+type Crawler struct {
+	// Fetcher retrieves the pages
+	Fetcher Fetcher
+
+	// Fetchers maps URL schemes, such as "file" or "ftp", to the Fetcher
+	//   of their URLs, so that one crawl can go through several kinds of
+	//   sources. Fetcher remains the one of http and https unless
+	//   Fetchers has them, and of every URL when Fetchers is nil. URLs of
+	//   a scheme no Fetcher takes are skipped
+	Fetchers map[string]Fetcher
+
+	// Middleware wraps the Fetchers in layers, the first being the
+	//   outermost, see Chain. The fetches of robots.txt and the sitemaps go
+	//   through them too
+	Middleware []Middleware
+
+	// MaxDepth is how many levels of links are followed, the seed
+	//   itself being the first level. A page is crawled at the shortest
+	//   depth it is found at: with a first-in first-out Frontier, the
+	//   default, a level is only handed out once the pages of the ones
+	//   before are fetched, for none to be found by a shorter path after
+	//   it was. Found again by a shorter path all the same, with another
+	//   Frontier, or from a page held back for its host or retried, it
+	//   is queued again at that depth, and fetched and reported again if
+	//   it was already, for its links to be followed as far as they
+	//   should. UnlimitedDepth follows them however deep they go: bound the crawl
+	//   with the Scope and MaxPages then
+	MaxDepth int
+
+	// DepthRules give the URLs of some paths a limit of their own, the
+	//   first rule matching a URL in place of MaxDepth: 2 under /blog/,
+	//   UnlimitedDepth under /docs/. A page is what is limited, by the
+	//   depth it is found at, whatever the ones of the pages linking to it
+	DepthRules []DepthRule
+
+	// DepthOverrides cap the limit MaxDepth and DepthRules give the URLs
+	//   they match: every override matching a URL applies, the least of
+	//   them and of that limit counting. One of MaxDepth 0 keeps the URLs
+	//   it matches out of the crawl, 2 under /user-profiles/ has those
+	//   fetched when the seed links to them alone. Unlike DepthRules, they
+	//   only ever lower the limit
+	DepthOverrides []DepthRule
+
+	// MaxPages caps how many pages a Run fetches, failed fetches and
+	//   checks included, zero means no limit. Once it is reached the
+	//   crawl winds down like a cancelled one, but returns as if it ran to
+	//   completion, the URLs left in the Frontier for a later Resume
+	MaxPages int
+
+	// MaxPagesPerHost caps how many pages a Run fetches from each host,
+	//   unless HostMaxPages says otherwise for that host, zero means no
+	//   limit. The URLs of a host past its budget are dropped
+	MaxPagesPerHost int
+	HostMaxPages    map[string]int // hostname => its budget
+
+	// MaxInFlightPerHost caps how many pages are fetched from each host
+	//   at the same time, unless HostMaxInFlight says otherwise for that
+	//   host, zero means no cap but MaxWorkers. The URLs of a host at its
+	//   cap wait for one of its fetches to be over, the workers going to
+	//   the other hosts meanwhile: unlike HTTPOptions.MaxConnsPerHost,
+	//   which has them wait for a connection, a crawl of many hosts keeps
+	//   all of its workers busy
+	MaxInFlightPerHost int
+	HostMaxInFlight    map[string]int // hostname => its cap
+
+	// MaxBytes caps how many bytes of bodies a Run downloads, zero means
+	//   no limit. It is checked as each page comes in, the pages already
+	//   being fetched then still count
+	MaxBytes int64
+
+	// MinBodyBytes, when set, makes the pages of a shorter body thin: error
+	//   pages answered with a 2xx, traps and the like. They are reported
+	//   with their Body, Skipped saying SkippedBodyTooShort and the length;
+	//   their links are not followed, nor the rest of the result filled
+	//   in. Unlike HTTPFetcher.MaxBodyBytes, which cuts what is downloaded,
+	//   it takes a page to be downloaded. The assets are not checked
+	MinBodyBytes int
+
+	// MaxWorkers caps how many pages are fetched at the same time,
+	//   zero means DefaultMaxWorkers
+	MaxWorkers int
+
+	// Sequential fetches the pages one at a time, in the goroutine of
+	//   Run, each in turn as the frontier has them: breadth first in the
+	//   order of the links with the default one. A host backed off is
+	//   waited for rather than let the others go first, so that a crawl of
+	//   the same site always goes the same way and reports the same
+	//   results in the same order, for tests, golden files and bug
+	//   reports. MaxWorkers, SetWorkers and Adaptive are left out, and
+	//   OnResult is never called concurrently
+	Sequential bool
+
+	// OnResult is called with the result of every fetch. It is called
+	//   concurrently from the worker goroutines, so it must be safe for
+	//   concurrent use
+	OnResult func(CrawlResult)
+
+	// Bodies, when set, keeps only some of the bodies of the results
+	//   handed to OnResult and Results, or cuts them short, or leaves
+	//   them all out, see BodySampling
+	Bodies *BodySampling
+
+	// Hooks, when set, are called at every stage of the crawl of every
+	//   URL, and may drop it, change how it is fetched or take its result
+	//   over, see Hooks
+	Hooks *Hooks
+
+	// Robots decides which URLs robots.txt lets the crawler fetch, and
+	//   how long to wait between fetches from the same host. When nil, a
+	//   Robots using Fetcher and UserAgent is set up for each Run
+	Robots *Robots
+
+	// UserAgent is the name the crawler goes by in robots.txt,
+	//   DefaultUserAgent when empty. It should be the one the Fetcher
+	//   sends, see HTTPFetcher.UserAgent
+	UserAgent string
+
+	// IgnoreRobots disables robots.txt handling altogether, meant for
+	//   crawling your own test sites, and has the links robots are not to
+	//   follow followed too, see CrawlResult.NoFollowLinks
+	IgnoreRobots bool
+
+	// DryRun only fetches the seed, for its links, and robots.txt and
+	//   the sitemaps: the URLs the crawl would go on with are reported
+	//   without being fetched, Skipped being SkippedDryRun, once the
+	//   Scope, robots.txt and the rest have let them through. Meant for
+	//   tuning the Scope
+	DryRun bool
+
+	// RateLimit spaces out the requests to each host, nil means pages are
+	//   fetched as fast as the workers go
+	RateLimit *HostLimiter
+
+	// Schedule, when set, restricts the crawl of the hosts to the times
+	//   of the day of its windows, their URLs waiting for the next one to
+	//   open meanwhile, see also PauseHost
+	Schedule *Schedule
+
+	// Backoff says how a host answering 429 or 503 is backed off: its
+	//   URLs wait as long as its Retry-After says, with fewer of them
+	//   fetched at once afterwards. When nil DefaultBackoffPolicy is used
+	Backoff *BackoffPolicy
+
+	// Retry, when set, has the URLs whose fetch failed of an error its
+	//   RetryOn deems transient queued again when they are found again,
+	//   up to MaxAttempts fetches in all, and not before Delay since the
+	//   failure. Without it, a URL is fetched once however many pages link
+	//   to it, failed or not. Unlike a RetryFetcher, which tries again
+	//   right away, the worker goes on with the other URLs meanwhile. See
+	//   StateOf
+	Retry *RetryPolicy
+
+	// Adaptive, when set, has the Crawler find how many pages to fetch
+	//   at the same time from each host and in all, MaxWorkers being
+	//   where it starts, see AdaptiveConcurrency
+	Adaptive *AdaptiveConcurrency
+
+	// Breaker, when set, holds back the URLs of a host that keeps
+	//   failing, and gives up on it if it doesn't recover
+	Breaker *CircuitBreaker
+
+	// Normalizer canonicalizes every URL before it is checked against the
+	//   visited ones, when nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	// Visited remembers the URLs already crawled. When nil, each Run
+	//   starts with an empty ShardedVisitedSet, which the workers screen
+	//   the links they find against themselves; setting one that outlives
+	//   the Run lets later runs skip what earlier ones crawled, the
+	//   dispatcher screening every link then. With a BloomVisitedSet, the
+	//   Run keeps as little as it can of each URL too, see there
+	Visited VisitedSet
+
+	// UseSitemaps seeds the crawl with the URLs listed in the sitemaps of
+	//   the seed's host, as announced by its robots.txt or else found at
+	//   /sitemap.xml, on top of the links discovered along the way
+	UseSitemaps bool
+
+	// Frontier decides in which order the URLs found are fetched. When
+	//   nil, each Run starts with an empty QueueFrontier, crawling breadth
+	//   first. A Frontier that outlives a cancelled Run still holds the
+	//   URLs the Run did not get to
+	Frontier Frontier
+
+	// Scope keeps the crawl within bounds, URLs out of scope are not
+	//   followed. When nil, every link is
+	Scope *ScopeRules
+
+	// Traps, when set, keeps the crawl out of the URL spaces that never
+	//   end, such as calendars, see TrapRules
+	Traps *TrapRules
+
+	// Guards, when set, rejects the URLs too long or with too many query
+	//   parameters before they are queued, see URLGuards
+	Guards *URLGuards
+
+	// Filter, when set, decides which of the URLs found are queued, once
+	//   the Scope let them through, see Filter. The seed is exempt from it.
+	//   It is called from the worker goroutines, concurrently, so it must
+	//   be safe for concurrent use
+	Filter Filter
+
+	// Aliases, when set, crawls each host under one name, merging www
+	//   and the bare domain, http and https, see HostAliases
+	Aliases *HostAliases
+
+	// Redirects, when set, records every redirect the crawl follows, see
+	//   CircularRedirectReport and LongChainReport
+	Redirects *RedirectGraph
+
+	// CheckExternal makes the links leaving the Scope checked rather than
+	//   dropped: they are fetched once, with Check so a HEAD request does
+	//   when the Fetcher can, and their links are not followed. Together
+	//   with a BrokenLinkReport, that makes a broken link checker
+	CheckExternal bool
+
+	// FetchAssets makes the assets of the pages fetched too, whatever
+	//   their depth: the images, stylesheets, scripts and fonts found by
+	//   ExtractAssets, and StylesheetAssets for the stylesheets, into
+	//   CrawlResult.Assets. They are reported with CrawlResult.Asset set,
+	//   the links of an asset are not followed but its own assets are.
+	//   They are still subject to the Scope
+	FetchAssets bool
+
+	// CheckAssets makes the assets only checked, as CheckExternal does
+	//   for the links leaving the Scope: they are not downloaded, so that
+	//   neither their size nor their own assets are known
+	CheckAssets bool
+
+	// FindFeeds makes the RSS and Atom feeds the pages announce recorded,
+	//   see CrawlResult.Feeds. FollowFeeds makes them fetched too, each
+	//   once, and their entries followed as links of the page, which
+	//   finds the articles of blogs and news sites the pages no longer
+	//   link to
+	FindFeeds   bool
+	FollowFeeds bool
+
+	// DetectLanguage makes the language of the pages detected, into
+	//   CrawlResult.Language. The Scope having Languages implies it
+	DetectLanguage bool
+
+	// CheckDocuments makes the links to documents, PDFs, Word files,
+	//   spreadsheets and the like known by their extension, checked
+	//   whatever their depth, and only checked as CheckExternal does
+	//   unless there is a DocumentExtractor
+	CheckDocuments bool
+
+	// DocumentExtractor, when set, takes the text and links out of the
+	//   documents fetched, into CrawlResult.Text and Links, the links
+	//   being followed as those of the pages
+	DocumentExtractor DocumentExtractor
+
+	// FollowCanonical makes the pages whose <link rel="canonical"> is
+	//   another page in the Scope reported as Duplicate of it, their
+	//   links not followed, and the canonical page crawled instead. In a
+	//   loop of canonicals, the page closing it is crawled as any other
+	FollowCanonical bool
+
+	// DedupContent makes the pages whose content was crawled already,
+	//   under another URL, reported as Duplicate with their links not
+	//   followed again, see DuplicateReport
+	DedupContent bool
+
+	// Soft404, when set, flags the pages that are soft 404s, see
+	//   CrawlResult.Soft404
+	Soft404 *Soft404Detector
+
+	// StructuredData makes the JSON-LD, microdata and OpenGraph of the
+	//   HTML pages parsed into CrawlResult.StructuredData
+	StructuredData bool
+
+	// ReadableText makes the main content of the HTML pages extracted
+	//   as plain text into CrawlResult.Text, see ReadableText
+	ReadableText bool
+
+	// Scraper, when set, scrapes the fields of its rules off the HTML
+	//   pages into CrawlResult.Fields
+	Scraper *Scraper
+
+	// ContentRules, when set, tag the HTML pages with the names of the
+	//   rules they match, in CrawlResult.Matched. With rules that Follow,
+	//   the links of the pages matching none of those are not followed,
+	//   but for those of the seeds, for a crawl to focus on a topic
+	ContentRules *ContentRules
+
+	// Journal, when set, records the progress of the crawl on disk for
+	//   Resume to pick it up from there
+	Journal *Journal
+
+	// Metrics, when set, counts the fetches, errors and latencies of the
+	//   crawl for Prometheus to scrape
+	Metrics *Metrics
+
+	// Progress, when set, follows the crawl as it goes for a live view
+	//   of it, see Progress
+	Progress *Progress
+
+	// Tracer, when set, traces every page through the stages of the
+	//   crawl with OpenTelemetry: a span "crawl.page" from the time the
+	//   page comes off the frontier to the time its result is handed
+	//   over, the spans of the stages in it, "crawl.dequeue",
+	//   "crawl.robots", "crawl.fetch", "crawl.parse" and "crawl.sink".
+	//   The span of a page is a child of the span of the context of Run
+	//   if it has one. See OTLPTracer
+	Tracer trace.Tracer
+
+	// Logger receives the events of the crawl, see the list in log.go.
+	//   Wrap any slog.Handler with slog.New to send them where the rest of
+	//   your logs go. When nil, nothing is logged
+	Logger *slog.Logger
+
+	// GracePeriod is how long the fetches under way when the crawl is
+	//   called off get to finish, their results are then reported as
+	//   usual. Zero aborts them right away
+	GracePeriod time.Duration
+
+	mu      sync.Mutex
+	stops   map[int]context.CancelFunc // of the Runs under way, by id
+	lastID  int
+	results chan CrawlResult // for the next Run, see Results
+	runs    map[*run]bool    // under way, for the controls, see control.go
+	paused  bool             // by Pause
+	limit   int              // of SetWorkers, 0 for MaxWorkers
+}
+
+// run is the state of one call to Run or Resume. The workers share the
+// Crawler and robots, the rest is the dispatcher's own
+type run struct {
+	*Crawler
+	ctx     context.Context // of the Run, for the Hooks
+	robots  *Robots         // nil when robots.txt is ignored
+	fetcher Fetcher         // of every URL, by its scheme
+
+	norm     *Normalizer
+	seed     string
+	seedURL  *neturl.URL
+	seeds    map[string]*neturl.URL // the other seeds of RunSeeds, read by the workers too
+	visited  VisitedSet
+	shared   *ShardedVisitedSet // visited, when the workers screen the links themselves
+	frontier Frontier
+	leases   LeasingFrontier // the frontier, when it hands items out on lease
+	report   ErrorReport
+
+	journalErr error // the first write to the Journal that failed
+	reported   int   // the frontier size last added to Metrics
+
+	results chan<- CrawlResult // nil unless Results was called
+
+	// The controls of the Crawler reach the dispatcher on controls until
+	//   over is closed
+	controls    chan control
+	over        chan struct{}
+	paused      bool
+	pausedUntil time.Time // of every host, by PauseUntil
+	limit       int       // workers fetching at the same time
+	spawned     int       // worker goroutines started, the most limit ever was
+	pending     int       // items handed to a worker and not yet reported back
+
+	// levels counts the pages handed to a worker and not yet reported
+	//   back by depth, for a first-in first-out frontier, fifo, to be
+	//   crawled level by level, see waits
+	fifo   bool
+	levels map[int]int
+
+	concurrency adaptState // of the whole crawl, see adaptive.go
+
+	// The pages the workers fetched, by the URL they ended up at, so that
+	//   several URLs redirecting to one page have it crawled once
+	pagesMu    sync.Mutex
+	pages      map[string]bool
+	pageBloom  *BloomVisitedSet  // in place of pages when compact
+	refetch    map[string]int    // URL => the depth it is to be fetched again at, see admit
+	contents   map[string]string // content hash => the first URL with it
+	canonicals map[string]string // normalized URL => its canonical, normalized
+
+	log     *slog.Logger
+	started time.Time
+	fetched int          // pages fetched, failed or not
+	bodies  atomic.Int64 // results with a body handed over, for Bodies
+
+	// What the budgets are counted against
+	dispatched int
+	hostPages  map[string]int // hostname => pages handed out
+	bytes      int64
+
+	// depths are the shortest depths the pages were found at, for admit
+	//   to queue them again when found by a shorter path
+	depths map[string]int
+
+	// compact is set when the visited set is a BloomVisitedSet: depths
+	//   are not kept then, nor states but for the URLs that failed, and
+	//   pageBloom stands in for pages, see BloomVisitedSet
+	compact bool
+
+	// states are where the URLs stand, for admit to queue again the ones
+	//   that failed, see retry.go, delayed the ones waiting to be
+	states  map[string]urlState
+	delayed []delayed
+
+	// How the hosts are backed off, see backoff.go
+	hosts map[string]*hostState // hostname => its state
+	held  int                   // items held for their host, in hosts
+
+	// What the workers need to know of the hosts
+	hostsMu   sync.Mutex
+	throttled map[string]int  // URL => times it was turned away
+	down      map[string]bool // hostname => given up on, see breaker.go
+}
+
+// fetched is what a worker hands back to the dispatcher once it is done
+// with an item
+type fetched struct {
+	FrontierItem
+	final  string // where redirects led, if anywhere
+	links  []string
+	assets []string
+	feeds  []string
+	canon  string // the canonical page to crawl instead, see FollowCanonical
+	size   int    // of the body, kept or streamed
+	err    error
+	retry  bool          // turned away by its host, to be fetched again
+	took   time.Duration // the response, zero when nothing was fetched
+
+	// screened is set when the worker screened what the page leads to
+	//   already, see screen: admits are the survivors of its assets,
+	//   canonical, feeds and links, in the order finish admits those, and
+	//   seenFinal is final normalized, when the worker marked it visited
+	screened  bool
+	admits    []screened
+	seenFinal string
+}
+
+// Crawl fetches url and, recursively, the pages it links to, up to
+// MaxDepth levels deep. It returns once every reachable page was crawled,
+// with the same errors as Run.
+func (c *Crawler) Crawl(url string) error {
+	return c.Run(context.Background(), url)
+}
+
+// Run is like Crawl but stops early when ctx is cancelled or its deadline
+// passes: no new pages are fetched, in-flight fetches are aborted once the
+// GracePeriod is over, and Run returns ctx.Err() when every worker has
+// finished.
+//
+// When the crawl ran to completion, Run returns nil if every page could be
+// fetched, or an *ErrorReport listing the URLs that failed and why. It
+// returns an error right away when url can't be normalized, or ErrTooDeep
+// when MaxDepth, or the DepthRules and DepthOverrides of url, leave nothing
+// to crawl.
+//
+// The pages are fetched by a fixed pool of MaxWorkers goroutines, the URLs
+// waiting their turn are queued in the Frontier.
+//
+// With a Journal, Run starts it afresh and records the crawl in it as it
+// goes, so that Resume can finish the crawl should this Run not.
+func (c *Crawler) Run(ctx context.Context, url string) error {
+	return c.RunSeeds(ctx, url)
+}
+
+// RunSeeds is Run from several seeds at once, in a single crawl: a URL
+// found from more than one of them is fetched once, at its least depth.
+// Each URL is in the Scope or not by the seed it descends from, see
+// FrontierItem.Seed, so that seeds on different sites each keep to their
+// own. The first seed is the one of the Journal, Status and the logs, see
+// ReadSeeds for a list of them out of a file.
+func (c *Crawler) RunSeeds(ctx context.Context, urls ...string) error {
+	if len(urls) == 0 {
+		return errors.New("webcrawl: no seed to crawl")
+	}
+	ctx, results, detach := c.attach(ctx)
+	defer detach()
+
+	norm := c.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	seeds := make([]string, 0, len(urls))
+	for _, url := range urls {
+		seed, err := norm.Normalize(url)
+		if err != nil {
+			return err
+		}
+		if c.fetcherFor(seed) == nil {
+			return fmt.Errorf("webcrawl: no Fetcher for the scheme of %s", seed)
+		}
+		if !slices.Contains(seeds, seed) {
+			seeds = append(seeds, seed)
+		}
+	}
+	kept := seeds[:0]
+	for _, seed := range seeds {
+		if !c.tooDeep(seed, 0) {
+			kept = append(kept, seed)
+		}
+	}
+	if len(kept) == 0 {
+		return &FetchError{URL: seeds[0], Cause: ErrTooDeep, Err: ErrTooDeep}
+	}
+	seeds = kept
+	if c.Journal != nil {
+		if err := c.Journal.start(seeds[0]); err != nil {
+			return fmt.Errorf("journal: %w", err)
+		}
+	}
+
+	r := c.newRun(ctx, norm, seeds[0])
+	r.results = results
+	for _, seed := range seeds[1:] {
+		r.seeds[seed], _ = neturl.Parse(seed)
+	}
+	r.log.InfoContext(ctx, "crawl started", "seed", seeds[0], "seeds", len(seeds), "max_depth", c.MaxDepth, "workers", r.workers())
+	for _, seed := range seeds {
+		it := FrontierItem{URL: seed}
+		if seed != r.seed {
+			it.Seed = seed
+		}
+		r.admit(it)
+	}
+	if c.UseSitemaps {
+		for _, seed := range seeds {
+			for _, u := range r.sitemapURLs(ctx, seed) {
+				it := FrontierItem{URL: u.Loc, Sitemap: &u}
+				if seed != r.seed {
+					it.Seed = seed
+				}
+				r.admit(it)
+			}
+		}
+	}
+	return r.loop(ctx)
+}
+
+// Resume finishes the crawl recorded in the Journal, as Run left it when it
+// was cancelled or the process died: the URLs it visited are not fetched
+// again, the ones it queued are, and the failures it ran into end up in the
+// *ErrorReport along with the new ones. Resume can itself be cancelled and
+// resumed. It returns the same errors as Run.
+//
+// The Crawler should be set up the same as for the Run that it resumes.
+func (c *Crawler) Resume(ctx context.Context) error {
+	ctx, results, detach := c.attach(ctx)
+	defer detach()
+
+	if c.Journal == nil {
+		return errors.New("webcrawl: Resume needs a Journal")
+	}
+	state := c.Journal.State()
+	if state.Seed == "" {
+		return errors.New("webcrawl: the journal holds no crawl to resume")
+	}
+	norm := c.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+
+	r := c.newRun(ctx, norm, state.Seed)
+	r.results = results
+	for _, u := range state.Visited {
+		r.visited.MarkSeen(u)
+	}
+	for _, it := range state.Pending {
+		r.frontier.Push(it)
+		if byDepth(it) && !r.compact {
+			r.depths[it.URL] = it.Depth
+		}
+	}
+	for u, st := range state.Status {
+		if d, ok := r.depths[u]; !r.compact && (!ok || st.Depth < d) {
+			r.depths[u] = st.Depth
+		}
+		if err := st.err(); err != nil {
+			r.report.add(u, st.Depth, err)
+		}
+	}
+	r.log.InfoContext(ctx, "crawl resumed", "seed", state.Seed, "visited", len(state.Visited), "pending", len(state.Pending))
+	return r.loop(ctx)
+}
+
+// Stop calls off the Runs and Resumes under way, as if their context was
+// cancelled: they return context.Canceled once their workers are done.
+func (c *Crawler) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stop := range c.stops {
+		stop()
+	}
+}
+
+// Results returns a channel on which the next Run or Resume sends the
+// result of every fetch, on top of calling OnResult, and which it closes
+// when it returns. Call it before Run and drain the channel, the workers
+// wait for each result to be received:
+//
+//	results := c.Results()
+//	go func() {
+//		for r := range results {
+//			fmt.Println(r.URL)
+//		}
+//	}()
+//	err := c.Run(ctx, seed)
+func (c *Crawler) Results() <-chan CrawlResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		c.results = make(chan CrawlResult)
+	}
+	return c.results
+}
+
+// attach registers a Run that starts: it hands it the channel of Results
+// if there is one, and a context that Stop cancels. The Run calls detach
+// when it returns
+func (c *Crawler) attach(ctx context.Context) (_ context.Context, results chan CrawlResult, detach func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stops == nil {
+		c.stops = make(map[int]context.CancelFunc)
+	}
+	c.lastID++
+	id := c.lastID
+	c.stops[id] = cancel
+	results, c.results = c.results, nil
+	return ctx, results, func() {
+		c.mu.Lock()
+		delete(c.stops, id)
+		c.mu.Unlock()
+		cancel()
+		if results != nil {
+			close(results)
+		}
+	}
+}
+
+// newRun sets up the state of a crawl from seed, which is normalized
+// already
+func (c *Crawler) newRun(ctx context.Context, norm *Normalizer, seed string) *run {
+	r := &run{Crawler: c, ctx: ctx, fetcher: Chain(schemeFetcher{c}, c.Middleware...), norm: norm, seed: seed, log: c.logger(), started: time.Now(),
+		hostPages: make(map[string]int), pages: make(map[string]bool), contents: make(map[string]string), canonicals: make(map[string]string),
+		depths: make(map[string]int), refetch: make(map[string]int), states: make(map[string]urlState),
+		hosts: make(map[string]*hostState), throttled: make(map[string]int), down: make(map[string]bool),
+		controls: make(chan control), over: make(chan struct{}), limit: c.workers()}
+	c.mu.Lock()
+	r.paused = c.paused
+	if c.limit > 0 {
+		r.limit = c.limit
+	}
+	c.mu.Unlock()
+	if !c.IgnoreRobots {
+		r.robots = c.Robots
+		if r.robots == nil {
+			r.robots = NewRobots(r.fetcher, c.UserAgent)
+		}
+	}
+	r.seedURL, _ = neturl.Parse(seed) // it was normalized, it parses
+	r.seeds = make(map[string]*neturl.URL)
+	r.visited = c.Visited
+	if r.visited == nil {
+		r.shared = NewShardedVisitedSet()
+		r.visited = r.shared
+	}
+	if bloom, ok := r.visited.(*BloomVisitedSet); ok {
+		r.compact, r.pageBloom = true, bloom.sibling()
+	}
+	r.frontier = c.Frontier
+	if r.frontier == nil {
+		r.frontier = NewBFSFrontier()
+	}
+	r.leases, _ = r.frontier.(LeasingFrontier)
+	switch r.frontier.(type) {
+	case *QueueFrontier, *SpillFrontier:
+		r.fifo, r.levels = true, make(map[int]int)
+	}
+	if c.Progress != nil {
+		c.Progress.begin(r)
+		r.frontier = progressFrontier{r.frontier, c.Progress}
+	}
+	return r
+}
+
+// admit pushes it into the frontier, unless no Fetcher takes it, it is out
+// of scope, visited already, rejected by the guards, too deep to be
+// fetched or caught in a trap
+func (r *run) admit(it FrontierItem) {
+	if s, ok := r.screen(it); ok {
+		r.enqueue(s)
+	}
+}
+
+// screened is an item found that screen let through, to enqueue
+type screened struct {
+	FrontierItem
+	again bool // visited already
+}
+
+// screen does the part of admit that needs none of the state of the
+// dispatcher: it normalizes the URL of it, and checks it against the
+// scheme, the Scope, the Filter and the visited set, marking it there.
+// With the ShardedVisitedSet of a run, the workers call it themselves,
+// concurrently, for the links of the pages they fetched
+func (r *run) screen(it FrontierItem) (screened, bool) {
+	pu, u, err := r.norm.parse(it.URL)
+	if err != nil {
+		return screened{}, false
+	}
+	if r.Aliases != nil {
+		if c := r.Aliases.Canonical(u); c != u {
+			u, pu = c, nil
+		}
+	}
+	it.URL = u
+	if r.fetcherFor(u) == nil {
+		r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", "unsupported scheme")
+		return screened{}, false
+	}
+	if r.Scope != nil && !r.isSeed(u) {
+		if pu == nil {
+			pu, _ = neturl.Parse(u)
+		}
+		seedURL := r.scopeSeed(it.Seed)
+		if r.Aliases.sameHost(seedURL, pu) {
+			// In scope under any name of the seed's host
+			in := *pu
+			in.Host = seedURL.Host
+			pu = &in
+		}
+		if !r.Scope.InScope(seedURL, pu) {
+			if !r.CheckExternal || it.Sitemap != nil {
+				r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", "out of scope")
+				return screened{}, false
+			}
+			it.External = true
+		}
+	}
+	if r.Filter != nil && !r.isSeed(u) && !r.Filter.Allow(it) {
+		r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", "filtered")
+		return screened{}, false
+	}
+	if r.CheckDocuments && !it.External && documentURL(u) != "" {
+		it.Document = true
+	}
+	// Found again, the URL is only queued again by a shorter path, or to
+	//   be fetched again after it failed
+	if r.Aliases != nil && !r.visited.Seen(u) {
+		for _, alias := range r.Aliases.aliases(u) {
+			if r.visited.Seen(alias) {
+				return screened{}, false
+			}
+		}
+	}
+	if r.shared != nil {
+		depth := noDepth
+		if byDepth(it) {
+			depth = it.Depth
+		}
+		return screened{it, !r.shared.claim(u, depth)}, true
+	}
+	again := r.visited.Seen(u)
+	if !again {
+		r.visited.MarkSeen(u)
+	}
+	return screened{it, again}, true
+}
+
+// byDepth reports whether it is crawled by depth, unlike the links only
+// checked, the assets, documents and feeds
+func byDepth(it FrontierItem) bool {
+	return !it.External && !it.Asset && !it.Document && !it.Feed
+}
+
+// enqueue does the rest of admit, on the dispatcher
+func (r *run) enqueue(s screened) {
+	it, u, again := s.FrontierItem, s.URL, s.again
+	if again && r.retrying(u) {
+		if d, ok := r.depths[u]; ok && d < it.Depth {
+			it.Depth = d
+		}
+		r.retry(it, time.Now())
+		r.journal(func(j *Journal) error { return j.queued(it) })
+		return
+	}
+	if again && !r.shallower(it) {
+		return
+	}
+	if !again && r.shared != nil && byDepth(it) {
+		// Found by a shorter path on another worker since, which was
+		//   told it is visited already: the depth is that one's
+		if d, ok := r.shared.least(u); ok && d < it.Depth {
+			it.Depth = d
+		}
+	}
+	if r.Guards != nil && !r.isSeed(u) && !again {
+		if reason := r.Guards.Rejected(u); reason != "" {
+			r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", reason)
+			r.journal(func(j *Journal) error { return j.seen(it.URL) })
+			return
+		}
+	}
+	if byDepth(it) && !r.compact {
+		r.depths[it.URL] = it.Depth
+	}
+	// The links of the deepest pages are still checked, and their assets
+	//   and documents fetched
+	if r.tooDeep(u, it.Depth) && !it.External && !it.Asset && !it.Document {
+		r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", "too deep")
+		r.journal(func(j *Journal) error { return j.seen(it.URL) })
+		return
+	}
+	if r.Traps != nil && !r.isSeed(u) && !again {
+		pu, _ := neturl.Parse(u)
+		if rule := r.Traps.Trapped(pu); rule != "" {
+			r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", "trap: "+rule)
+			r.journal(func(j *Journal) error { return j.seen(it.URL) })
+			return
+		}
+	}
+	if !r.enqueueHook(it) {
+		r.log.Debug("url skipped", "url", u, "depth", it.Depth, "reason", "OnEnqueue")
+		r.journal(func(j *Journal) error { return j.seen(it.URL) })
+		return
+	}
+	if again {
+		// Fetched already, or on its way, at a greater depth: the copy
+		//   queued is dropped by pop, the page is fetched again at this
+		//   one
+		r.pagesMu.Lock()
+		r.refetch[it.URL] = it.Depth
+		r.pagesMu.Unlock()
+		r.log.Debug("url queued again", "url", u, "depth", it.Depth, "reason", "shorter path")
+	}
+	r.frontier.Push(it)
+	if r.states[u].state != URLInFlight {
+		r.setState(u, URLQueued)
+	}
+	r.log.Debug("url queued", "url", u, "depth", it.Depth)
+	r.journal(func(j *Journal) error { return j.queued(it) })
+}
+
+// shallower reports whether the page of it was found before at a greater
+// depth. What is not crawled by depth, assets and the rest, is not queued
+// again
+func (r *run) shallower(it FrontierItem) bool {
+	if !byDepth(it) {
+		return false
+	}
+	d, ok := r.depths[it.URL]
+	return ok && it.Depth < d
+}
+
+// markSeen marks a URL the crawl got to without queueing it as visited,
+// so that it is not queued later
+func (r *run) markSeen(u string) {
+	if u, ok := r.mark(u); ok {
+		r.journal(func(j *Journal) error { return j.seen(u) })
+	}
+}
+
+// mark marks u as visited, and returns it normalized, ok when it was not
+// visited already
+func (r *run) mark(u string) (_ string, ok bool) {
+	u, err := r.norm.Normalize(u)
+	if err != nil {
+		return "", false
+	}
+	if r.Aliases != nil {
+		u = r.Aliases.Canonical(u)
+	}
+	if r.shared != nil {
+		return u, r.shared.Add(u)
+	}
+	if r.visited.Seen(u) {
+		return "", false
+	}
+	r.visited.MarkSeen(u)
+	return u, true
+}
+
+// journal records an event in the Journal, if there is one. A failure
+// doesn't stop the crawl, but Run reports it in the end
+func (r *run) journal(write func(*Journal) error) {
+	if r.Journal == nil {
+		return
+	}
+	if err := write(r.Journal); err != nil && r.journalErr == nil {
+		r.journalErr = fmt.Errorf("journal: %w", err)
+	}
+}
+
+// loop starts the workers and dispatches the frontier to them until it runs
+// dry or ctx is done
+func (r *run) loop(ctx context.Context) error {
+	defer r.track(r)()
+	defer close(r.over)
+	// The fetches only see ctx done once the grace period is over
+	fetchCtx := ctx
+	if r.GracePeriod > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		defer cancel()
+		stopGrace := context.AfterFunc(ctx, func() {
+			time.AfterFunc(r.GracePeriod, cancel)
+		})
+		defer stopGrace()
+	}
+
+	tasks := make(chan task)
+	done := make(chan fetched)
+	var wg sync.WaitGroup
+	spawn := func() {
+		// SetWorkers may call for more of them, though never for fewer:
+		//   the ones beyond the limit are just not handed anything
+		for ; !r.Sequential && r.spawned < r.limit; r.spawned++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				r.work(fetchCtx, worker, tasks, done)
+			}(r.spawned)
+		}
+	}
+	spawn()
+
+	// This go routine is the dispatcher, it alone works the frontier and
+	//   the state of the URLs. With the ShardedVisitedSet of the run, the
+	//   workers screen the links they find against the visited set
+	//   themselves, see screen, and only the survivors come this way
+
+	// next is the item popped from the frontier, waiting for a worker to
+	//   be free since popped, and stopped is set once the crawl is called
+	//   off
+	var next FrontierItem
+	var popped time.Time
+	hasNext, stopped := false, false
+	stop := ctx.Done()
+	var timer *time.Timer // of the next host due back
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		if !hasNext && !stopped && r.spent() {
+			// What is left stays in the frontier
+			r.log.InfoContext(ctx, "crawl budget spent", "pages", r.dispatched, "bytes", r.bytes)
+			stopped = true
+		}
+		// The items held for a host that is backed off go back to the
+		//   frontier once it is available again, or the crawl is over
+		now := time.Now()
+		r.release(now, stopped)
+		r.releaseRetries(now, stopped)
+		if hasNext && !r.Sequential && r.hold(next, now) {
+			// Its host was backed off while it waited for a worker
+			hasNext = false
+		}
+		if !hasNext && !stopped && !r.paused {
+			if next, hasNext = r.pop(); hasNext {
+				popped = time.Now()
+			}
+		}
+		// A paused crawl waits to be unpaused, even with nothing left
+		if !hasNext && r.pending == 0 && r.held == 0 && len(r.delayed) == 0 && (!r.paused || stopped) {
+			break
+		}
+		r.reportFrontier(hasNext)
+		if r.Sequential && hasNext && !r.paused && r.pending == 0 && ctx.Err() == nil {
+			// Fetched right here once the controls are answered, the
+			//   select below only waits on them and on stop
+			select {
+			case ctl := <-r.controls:
+				ctl.reply <- ctl.do(r)
+				continue
+			default:
+			}
+			if !r.waitHost(ctx, next) {
+				continue
+			}
+			hasNext = false
+			r.dispatch(next)
+			r.finish(fetchCtx, r.process(fetchCtx, 0, task{next, popped}))
+			continue
+		}
+		// Only offer an item when there is one and a worker may take it, a
+		//   nil channel is never ready so the select just waits on the
+		//   workers
+		var out chan<- task
+		if hasNext && !r.paused && r.pending < r.limit && !r.Sequential && !r.waits(next) {
+			out = tasks
+		}
+		// Nor wake up unless a host is due back with nothing else to do
+		var wakeUp <-chan time.Time
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		if wake := r.due(now); !hasNext && !wake.IsZero() {
+			timer = time.NewTimer(wake.Sub(now))
+			wakeUp = timer.C
+		}
+		select {
+		case out <- task{next, popped}:
+			hasNext = false
+			r.dispatch(next)
+		case <-wakeUp:
+		case ctl := <-r.controls:
+			ctl.reply <- ctl.do(r)
+			spawn()
+		case f := <-done:
+			r.finish(fetchCtx, f)
+			// Adaptive may have called for more workers
+			spawn()
+		case <-stop:
+			// Called off, leave everything not yet fetched in the frontier
+			//   and just wait for the workers to come back
+			if hasNext {
+				r.frontier.Push(next)
+				hasNext = false
+			}
+			stop, stopped = nil, true
+		}
+	}
+	close(tasks)
+	wg.Wait()
+	if r.Metrics != nil {
+		// What is left in the frontier is not waiting any more
+		r.Metrics.addFrontier(-r.reported)
+	}
+	err := r.journalErr
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = r.report.err()
+	}
+	r.log.InfoContext(ctx, "crawl finished", "fetched", r.fetched, "failed", len(r.report.Errors),
+		"elapsed", time.Since(r.started), "err", err)
+	return err
+}
+
+// dispatch counts it handed to a worker
+func (r *run) dispatch(it FrontierItem) {
+	r.pending++
+	if r.fifo && byDepth(it) {
+		r.levels[it.Depth]++
+	}
+	r.dispatched++
+	r.host(it.URL).inFlight++
+	r.setState(it.URL, URLInFlight)
+	if r.MaxPagesPerHost > 0 || r.HostMaxPages != nil {
+		r.hostPages[hostname(it.URL)]++
+	}
+}
+
+// waits reports whether it is to wait for the pages of lesser depths being
+// fetched to be done before it is handed out. The first-in first-out
+// frontiers hand the pages out by depth, but the workers don't finish them
+// in that order: one of the next level handed out before, the links of a
+// slow page of this one may still lead to it, by a shorter path, and it
+// would be fetched and reported again
+func (r *run) waits(it FrontierItem) bool {
+	if !r.fifo || !byDepth(it) {
+		return false
+	}
+	for d := range r.levels {
+		if d < it.Depth {
+			return true
+		}
+	}
+	return false
+}
+
+// finish takes in what a worker made of an item: the links of its page go
+// to the frontier, or the item itself when it is to be fetched again
+func (r *run) finish(fetchCtx context.Context, f fetched) {
+	r.pending--
+	if r.fifo && byDepth(f.FrontierItem) {
+		if r.levels[f.Depth]--; r.levels[f.Depth] == 0 {
+			delete(r.levels, f.Depth)
+		}
+	}
+	cut := interrupted(fetchCtx, f.err)
+	r.hostDone(f, cut, time.Now())
+	if cut {
+		// Not the page's fault, it is still to be fetched
+		r.frontier.Push(f.FrontierItem)
+		r.setState(f.URL, URLQueued)
+		return
+	}
+	if f.retry {
+		// Turned away for now, it doesn't count against the budgets
+		r.frontier.Push(f.FrontierItem)
+		r.setState(f.URL, URLQueued)
+		r.dispatched--
+		if r.MaxPagesPerHost > 0 || r.HostMaxPages != nil {
+			r.hostPages[hostname(f.URL)]--
+		}
+		return
+	}
+	r.fetched++
+	r.bytes += int64(f.size)
+	r.journal(func(j *Journal) error { return j.done(f.URL, f.Depth, f.err) })
+	if f.err != nil {
+		if r.failed(f.URL, f.err, time.Now()) {
+			// Failed again, once
+			r.report.remove(f.URL)
+		}
+		r.report.add(f.URL, f.Depth, f.err)
+		r.done(f.FrontierItem)
+		return
+	}
+	if r.states[f.URL].attempts > 0 {
+		// Retried to some avail
+		r.report.remove(f.URL)
+	}
+	r.setState(f.URL, URLDone)
+	if r.Aliases != nil && !f.screened {
+		r.Aliases.learn(f.URL, f.final)
+	}
+	if f.External {
+		r.done(f.FrontierItem)
+		return
+	}
+	if f.screened {
+		if f.seenFinal != "" {
+			r.journal(func(j *Journal) error { return j.seen(f.seenFinal) })
+		}
+		for _, s := range f.admits {
+			r.enqueue(s)
+		}
+		r.done(f.FrontierItem)
+		return
+	}
+	if f.final != "" {
+		r.markSeen(f.final)
+	}
+	// Even once called off, so the links land in the frontier
+	//   for a later Resume to follow
+	for _, u := range f.assets {
+		r.admit(FrontierItem{URL: u, Depth: f.Depth + 1, Seed: f.Seed, Asset: true})
+	}
+	if f.canon != "" {
+		// The same page, at the same depth
+		r.admit(FrontierItem{URL: f.canon, Depth: f.Depth, Seed: f.Seed})
+	}
+	if f.Asset {
+		r.done(f.FrontierItem)
+		return
+	}
+	if r.FollowFeeds {
+		for _, u := range f.feeds {
+			r.admit(FrontierItem{URL: u, Depth: f.Depth, Seed: f.Seed, Feed: true})
+		}
+	}
+	for _, u := range f.links {
+		r.admit(FrontierItem{URL: u, Depth: f.Depth + 1, Seed: f.Seed})
+	}
+	r.done(f.FrontierItem)
+}
+
+// workers returns how many workers fetch the pages
+func (c *Crawler) workers() int {
+	if c.Sequential {
+		return 1
+	}
+	if c.MaxWorkers <= 0 {
+		return DefaultMaxWorkers
+	}
+	return c.MaxWorkers
+}
+
+// spent reports whether MaxPages or MaxBytes is reached
+func (r *run) spent() bool {
+	return r.MaxPages > 0 && r.dispatched >= r.MaxPages ||
+		r.MaxBytes > 0 && r.bytes >= r.MaxBytes
+}
+
+// pop pops the next item from the frontier, dropping the ones whose host
+// has spent its budget and holding the ones whose host is backed off
+func (r *run) pop() (FrontierItem, bool) {
+	now := time.Now()
+	for {
+		it, ok := r.frontier.Pop()
+		if !ok {
+			return it, false
+		}
+		if d, ok := r.depths[it.URL]; ok && it.Depth > d {
+			// Queued again by a shorter path since
+			r.log.Debug("url skipped", "url", it.URL, "depth", it.Depth, "reason", "queued again at depth "+strconv.Itoa(d))
+			r.done(it)
+			continue
+		}
+		if r.MaxPagesPerHost > 0 || r.HostMaxPages != nil {
+			host := hostname(it.URL)
+			budget, ok := r.HostMaxPages[host]
+			if !ok {
+				budget = r.MaxPagesPerHost
+			}
+			if budget > 0 && r.hostPages[host] >= budget {
+				r.log.Debug("url skipped", "url", it.URL, "depth", it.Depth, "reason", "host budget spent")
+				r.journal(func(j *Journal) error { return j.dropped(it.URL) })
+				r.done(it)
+				continue
+			}
+		}
+		if r.Sequential || !r.hold(it, now) {
+			return it, true
+		}
+	}
+}
+
+// done tells a LeasingFrontier the crawl is done with it
+func (r *run) done(it FrontierItem) {
+	if r.leases != nil {
+		r.leases.Done(it)
+	}
+}
+
+// hostname returns the host of rawURL without its port
+func hostname(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// reportFrontier brings the frontier size of Metrics up to date, counting
+// the item popped and not yet handed out if there is one, and the items
+// held for their host
+func (r *run) reportFrontier(hasNext bool) {
+	if r.Metrics == nil {
+		return
+	}
+	n := r.frontier.Len() + r.held
+	if hasNext {
+		n++
+	}
+	r.Metrics.addFrontier(n - r.reported)
+	r.reported = n
+}
+
+// interrupted reports whether a fetch failed because the crawl was called
+// off rather than because of the page
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil
+}
+
+// sitemapURLs returns what the sitemaps of seed's host list, sitemaps that
+// are missing or broken are no reason to fail the crawl
+func (r *run) sitemapURLs(ctx context.Context, seed string) []SitemapURL {
+	locs, err := DiscoverSitemaps(ctx, r.robots, seed)
+	if err != nil {
+		return nil
+	}
+	urls, _ := LoadSitemaps(ctx, r.fetcher, locs, 0)
+	return urls
+}
+
+// task is an item the dispatcher hands a worker, popped from the frontier
+// at popped
+type task struct {
+	FrontierItem
+	popped time.Time
+}
+
+// work fetches the items handed over by the dispatcher until tasks is
+// closed, reporting each one back on done
+func (r *run) work(ctx context.Context, worker int, tasks <-chan task, done chan<- fetched) {
+	for t := range tasks {
+		done <- r.process(ctx, worker, t)
+	}
+}
+
+// process fetches the page of t, hands the result over, and returns what
+// the dispatcher is to make of it
+func (r *run) process(ctx context.Context, worker int, t task) fetched {
+	it := t.FrontierItem
+	pageCtx, page := r.span(ctx, "crawl.page", t.popped, attribute.String("url.full", it.URL),
+		attribute.Int("webcrawl.depth", it.Depth), attribute.Int("webcrawl.worker", worker))
+	_, dequeue := r.span(pageCtx, "crawl.dequeue", t.popped)
+	dequeue.End()
+	if r.Metrics != nil {
+		r.Metrics.started()
+	}
+	if r.Progress != nil {
+		r.Progress.startedFetch(it)
+	}
+	res := r.fetch(pageCtx, it)
+	cut := interrupted(ctx, res.Err)
+	retry := !cut && r.retryThrottled(it, res.Err)
+	if r.Metrics != nil {
+		r.Metrics.finished(res, cut)
+	}
+	if r.Progress != nil {
+		r.Progress.finishedFetch(it, res, !cut && !retry)
+	}
+	if retry {
+		r.log.DebugContext(ctx, "fetch throttled", "url", res.URL, "depth", res.Depth, "worker", worker)
+	} else {
+		r.logResult(ctx, worker, res, cut)
+	}
+	// A fetch cut short because the crawl was called off is not a
+	//   result of the page, nor is one its host turned away for now
+	if !cut && !retry {
+		sinkCtx, sink := r.span(pageCtx, "crawl.sink", time.Time{})
+		if r.resultHook(sinkCtx, &res) {
+			out := r.sample(res)
+			if r.OnResult != nil {
+				r.OnResult(out)
+			}
+			if r.results != nil {
+				r.results <- out
+			}
+		}
+		sink.End()
+	}
+	if res.StatusCode != 0 {
+		page.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	}
+	if retry {
+		page.SetAttributes(attribute.Bool("webcrawl.retry", true))
+	}
+	endSpan(page, res.Err)
+	f := fetched{FrontierItem: it, links: res.Links, assets: res.Assets, feeds: res.Feeds, size: max(len(res.Body), int(res.BodySize)), err: res.Err, retry: retry, took: res.Duration}
+	if r.IgnoreRobots && len(res.NoFollowLinks) > 0 {
+		f.links = append(f.links[:len(f.links):len(f.links)], res.NoFollowLinks...)
+	}
+	if res.Canonical != "" && res.DuplicateOf == res.Canonical {
+		f.canon = res.Canonical
+	}
+	if r.Scope != nil && res.Err == nil && !r.Scope.LanguageInScope(res.Language) && !r.isSeed(it.URL) {
+		r.log.Debug("links not followed", "url", it.URL, "reason", "language "+res.Language)
+		f.links = nil
+	}
+	if n := len(res.Redirects); n > 0 {
+		f.final = res.Redirects[n-1].To
+	}
+	if r.shared != nil && res.Err == nil && !it.External {
+		r.screenAll(&f)
+	}
+	return f
+}
+
+// screenAll screens what the page of f leads to, on the worker, as finish
+// would admit it, for the dispatcher only to enqueue the survivors
+func (r *run) screenAll(f *fetched) {
+	f.screened = true
+	if r.Aliases != nil {
+		r.Aliases.learn(f.URL, f.final)
+	}
+	if f.final != "" {
+		if u, ok := r.mark(f.final); ok {
+			f.seenFinal = u
+		}
+	}
+	add := func(it FrontierItem) {
+		if s, ok := r.screen(it); ok {
+			f.admits = append(f.admits, s)
+		}
+	}
+	for _, u := range f.assets {
+		add(FrontierItem{URL: u, Depth: f.Depth + 1, Seed: f.Seed, Asset: true})
+	}
+	if f.canon != "" {
+		add(FrontierItem{URL: f.canon, Depth: f.Depth, Seed: f.Seed})
+	}
+	if f.Asset {
+		return
+	}
+	if r.FollowFeeds {
+		for _, u := range f.feeds {
+			add(FrontierItem{URL: u, Depth: f.Depth, Seed: f.Seed, Feed: true})
+		}
+	}
+	for _, u := range f.links {
+		add(FrontierItem{URL: u, Depth: f.Depth + 1, Seed: f.Seed})
+	}
+}
+
+// fetch retrieves the page of it, provided robots.txt allows it and the
+// circuit breaker didn't give up on the host, once the host's rate limit
+// lets it through
+func (r *run) fetch(ctx context.Context, it FrontierItem) CrawlResult {
+	res := CrawlResult{URL: it.URL, Depth: it.Depth, External: it.External, Asset: it.Asset, Feed: it.Feed, Document: it.Document}
+	if res.Err = r.hostDown(it.URL); res.Err != nil {
+		return res
+	}
+	dry := r.DryRun && !r.isSeed(it.URL)
+	if r.robots != nil && isHTTP(it.URL) {
+		robotsCtx, span := r.span(ctx, "crawl.robots", time.Time{})
+		ok, err := r.robots.Allowed(robotsCtx, it.URL)
+		if err == nil && !ok {
+			err = fmt.Errorf("%w: %s", ErrRobotsBlocked, it.URL)
+		}
+		if err == nil && !dry {
+			err = r.robots.Wait(robotsCtx, it.URL)
+		}
+		endSpan(span, err)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+	}
+	if dry {
+		res.Skipped = SkippedDryRun
+		return res
+	}
+	if r.RateLimit != nil {
+		if err := r.RateLimit.WaitURL(ctx, it.URL); err != nil {
+			res.Err = err
+			return res
+		}
+	}
+	ctx, err := r.fetchHook(ctx, it)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.FetchedAt = time.Now()
+	fetchCtx, span := r.span(ctx, "crawl.fetch", res.FetchedAt)
+	if it.External || it.Asset && r.CheckAssets || it.Document && r.DocumentExtractor == nil {
+		span.SetAttributes(attribute.String("http.request.method", http.MethodHead))
+		res.Err = Check(fetchCtx, r.fetcher, it.URL)
+		res.Duration = time.Since(res.FetchedAt)
+		endSpan(span, res.Err)
+		return res
+	}
+	resp, err := FetchResponse(fetchCtx, r.fetcher, it.URL)
+	res.Duration = time.Since(res.FetchedAt)
+	if resp.StatusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	if resp.Protocol != "" {
+		span.SetAttributes(attribute.String("network.protocol.version", strings.TrimPrefix(resp.Protocol, "HTTP/")))
+	}
+	span.SetAttributes(attribute.Int64("http.response.body.size", max(int64(len(resp.Body)), resp.BodySize)))
+	endSpan(span, err)
+	res.StatusCode, res.Redirects, res.NotModified, res.Err = resp.StatusCode, resp.Redirects, resp.NotModified, err
+	res.NoIndex, res.Skipped = resp.NoIndex, resp.Skipped
+	res.BodySize, res.WireSize, res.Truncated = resp.BodySize, resp.WireSize, resp.Truncated
+	res.Charset, res.Screenshot, res.Accessibility = resp.Charset, resp.Screenshot, resp.Accessibility
+	res.Header, res.ContentLength, res.RemoteAddr = resp.Header, resp.ContentLength, resp.RemoteAddr
+	res.TLSVersion, res.Protocol, res.Timings = resp.TLSVersion, resp.Protocol, resp.Timings
+	if r.Redirects != nil {
+		for _, hop := range resp.Redirects {
+			r.Redirects.AddRedirect(hop.From, hop.To, hop.StatusCode)
+		}
+	}
+	if err != nil {
+		return res
+	}
+	if !r.firstFetch(it, resp.URL) {
+		res.Duplicate = true
+		return res
+	}
+	if n := max(len(resp.Body), int(resp.BodySize)); r.MinBodyBytes > 0 && n < r.MinBodyBytes && !it.Asset && !resp.NotModified && resp.Skipped == "" {
+		res.Body, res.Skipped = resp.Body, SkippedBodyTooShort+":"+strconv.Itoa(n)
+		return res
+	}
+	res.Body, res.Links, res.NoFollowLinks = resp.Body, resp.Links, resp.NoFollowLinks
+	ctx, parse := r.span(ctx, "crawl.parse", time.Time{})
+	defer func() {
+		parse.SetAttributes(attribute.Int("webcrawl.links", len(res.Links)))
+		parse.End()
+	}()
+	findFeeds := r.FindFeeds || r.FollowFeeds
+	language := r.DetectLanguage || r.Scope != nil && len(r.Scope.Languages) > 0
+	if it.Feed {
+		res.Links, res.NoFollowLinks = nil, nil
+		if base, err := neturl.Parse(pageURL(res)); err == nil {
+			entries, err := ParseFeed(base, []byte(res.Body))
+			if err != nil {
+				r.log.Debug("feed not parsed", "url", it.URL, "err", err)
+			}
+			for _, e := range entries {
+				res.Links = append(res.Links, e.URL)
+			}
+		}
+	} else if res.Body != "" && (r.StructuredData || r.ReadableText || r.Scraper != nil || r.ContentRules != nil || r.FetchAssets || findFeeds || language || r.FollowCanonical) && htmlResult(res) {
+		if base, doc, err := pageDoc(res); err == nil {
+			if r.FetchAssets {
+				res.Assets = ExtractAssets(base, doc)
+			}
+			if findFeeds {
+				res.Feeds = ExtractFeeds(base, doc)
+			}
+			if r.FollowCanonical {
+				r.canonical(&res, base, doc, r.scopeSeed(it.Seed))
+			}
+			if r.ReadableText {
+				res.Text = ReadableText(doc)
+				res.WordCount = WordCount(res.Text)
+			}
+			if language {
+				// The text only tells when the page doesn't
+				if res.Language = DetectLanguage(res.Header, doc, res.Text); res.Language == "" && res.Text == "" {
+					res.Language = TextLanguage(ReadableText(doc))
+				}
+			}
+			if r.StructuredData {
+				res.StructuredData = ExtractStructuredData(base, doc)
+			}
+			if r.Scraper != nil {
+				res.Fields = r.Scraper.Scrape(base, doc)
+			}
+			if r.ContentRules != nil {
+				res.Matched = r.ContentRules.Match(base, doc, res.Body)
+			}
+		}
+	} else if res.Body != "" && r.FetchAssets && mediaType(res) == "text/css" {
+		if base, err := neturl.Parse(pageURL(res)); err == nil {
+			res.Assets = StylesheetAssets(base, res.Body)
+		}
+	} else if mt := documentType(res); mt != "" && (r.CheckDocuments || r.DocumentExtractor != nil) {
+		res.Document = true
+		if r.DocumentExtractor != nil && res.Body != "" {
+			r.extractDocument(ctx, &res, mt)
+		}
+	}
+	if r.ContentRules != nil && !it.Feed && !r.isSeed(it.URL) && !r.ContentRules.Follows(res.Matched) {
+		res.Links = nil
+	}
+	if res.Body != "" {
+		res.ContentHash = ContentHash(res.Body)
+		if r.DedupContent && !res.Duplicate {
+			if first := r.firstContent(it.URL, res.ContentHash); first != it.URL {
+				res.Duplicate, res.DuplicateOf, res.Links, res.NoFollowLinks = true, first, nil, nil
+				res.Assets = nil
+			}
+		}
+	}
+	if r.Soft404 != nil {
+		res.Soft404 = r.Soft404.Soft404(ctx, r.fetcher, res)
+	}
+	return res
+}
+
+// extractDocument has the DocumentExtractor take the text and links out of
+// the document of res, of the media type mt
+func (r *run) extractDocument(ctx context.Context, res *CrawlResult, mt string) {
+	text, links, err := r.DocumentExtractor.Extract(ctx, mt, []byte(res.Body))
+	if err != nil {
+		r.log.Warn("document not extracted", "url", res.URL, "err", err)
+		return
+	}
+	res.Text, res.WordCount = text, WordCount(text)
+	base, err := neturl.Parse(pageURL(*res))
+	if err != nil {
+		return
+	}
+	res.Links = nil
+	for _, l := range links {
+		if u := assetURL(base, l); u != "" {
+			res.Links = append(res.Links, u)
+		}
+	}
+}
+
+// firstFetch records that fetching it got to the page at final, and
+// reports whether no other fetch got there before, or admit queued it
+// again at its depth
+func (r *run) firstFetch(it FrontierItem, final string) bool {
+	url := it.URL
+	if f, err := r.norm.Normalize(final); err == nil {
+		final = f
+	}
+	r.pagesMu.Lock()
+	defer r.pagesMu.Unlock()
+	if r.compact {
+		// Never queued again by a shorter path, depths not being kept
+		if r.pageBloom.Seen(final) {
+			return false
+		}
+		r.pageBloom.MarkSeen(final)
+		if url != final {
+			r.pageBloom.MarkSeen(url)
+		}
+		return true
+	}
+	if d, ok := r.refetch[url]; ok && d == it.Depth {
+		// Queued again by a shorter path, see admit
+		delete(r.refetch, url)
+		r.pages[final] = true
+		return true
+	}
+	if r.pages[final] {
+		return false
+	}
+	r.pages[final] = true
+	r.pages[url] = true
+	return true
+}
+
+// firstContent records that the page at url has the content hash, and
+// returns the first URL that had it
+func (r *run) firstContent(url, hash string) string {
+	r.pagesMu.Lock()
+	defer r.pagesMu.Unlock()
+	if first, ok := r.contents[hash]; ok {
+		return first
+	}
+	r.contents[hash] = url
+	return url
+}
+
+// isHTTP reports whether rawURL is an http or https URL, only those have
+// a robots.txt
+func isHTTP(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://")
+}
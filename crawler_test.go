@@ -0,0 +1,286 @@
+package webcrawl_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// recorder keeps what a crawl reported, by the page the results are of
+type recorder struct {
+	mu      sync.Mutex
+	reports map[string]int // page => results
+	depths  map[string]int // page => least depth reported
+}
+
+func newRecorder() *recorder {
+	return &recorder{reports: make(map[string]int), depths: make(map[string]int)}
+}
+
+func (rec *recorder) add(res webcrawl.CrawlResult) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	// Where a redirect led is at the depth of the redirect
+	page := res.URL
+	for _, hop := range res.Redirects {
+		rec.depth(hop.From, res.Depth)
+		page = hop.To
+	}
+	rec.reports[page]++
+	rec.depth(page, res.Depth)
+}
+
+func (rec *recorder) depth(page string, d int) {
+	if old, ok := rec.depths[page]; !ok || d < old {
+		rec.depths[page] = d
+	}
+}
+
+// stackFrontier is a last-in first-out Frontier, for a crawl to go deep
+// first and find its pages by shorter paths after
+type stackFrontier struct{ items []webcrawl.FrontierItem }
+
+func (s *stackFrontier) Push(it webcrawl.FrontierItem) { s.items = append(s.items, it) }
+
+func (s *stackFrontier) Pop() (webcrawl.FrontierItem, bool) {
+	if len(s.items) == 0 {
+		return webcrawl.FrontierItem{}, false
+	}
+	it := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return it, true
+}
+
+func (s *stackFrontier) Len() int { return len(s.items) }
+
+// shortcutSite has /x two levels down by /a, three by /b and /c, /b being
+// crawled first depth first
+func shortcutSite() *testsite.Site {
+	site := testsite.New("http://site.test")
+	site.Add("/", &testsite.Page{Links: []string{"/a", "/b"}})
+	site.Add("/a", &testsite.Page{Links: []string{"/x"}})
+	site.Add("/b", &testsite.Page{Links: []string{"/c"}})
+	site.Add("/c", &testsite.Page{Links: []string{"/x"}})
+	site.Add("/x", &testsite.Page{Links: []string{"/y"}})
+	site.Add("/y", &testsite.Page{})
+	return site
+}
+
+func TestShorterPathQueuedAgain(t *testing.T) {
+	site := shortcutSite()
+	rec := newRecorder()
+	// Found by /c first, /x is too deep, then by /a it is not
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(), webcrawl.WithDepth(3),
+		webcrawl.WithFrontier(&stackFrontier{}), webcrawl.WithOnResult(rec.add))
+	c.Sequential = true
+	if err := c.Crawl(site.URL("/")); err != nil {
+		t.Fatal(err)
+	}
+	x := site.URL("/x")
+	if n := site.Fetches(x); n != 1 {
+		t.Errorf("%s fetched %d times, want 1", x, n)
+	}
+	if d, want := rec.depths[x], site.Reachable(3)[x]; d != want {
+		t.Errorf("%s reported at depth %d, want %d", x, d, want)
+	}
+	if n := site.Fetches(site.URL("/y")); n != 0 {
+		t.Errorf("/y fetched %d times, beyond MaxDepth", n)
+	}
+}
+
+func TestShorterPathFetchedAgain(t *testing.T) {
+	site := shortcutSite()
+	rec := newRecorder()
+	// Fetched at depth 3 by /c first, /x is found at depth 2 by /a after,
+	//   and /y is then one level short of MaxDepth
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(), webcrawl.WithDepth(4),
+		webcrawl.WithFrontier(&stackFrontier{}), webcrawl.WithOnResult(rec.add))
+	c.Sequential = true
+	if err := c.Crawl(site.URL("/")); err != nil {
+		t.Fatal(err)
+	}
+	want := site.Reachable(4)
+	x, y := site.URL("/x"), site.URL("/y")
+	if n := site.Fetches(x); n != 2 {
+		t.Errorf("%s fetched %d times, want 2", x, n)
+	}
+	if d := rec.depths[x]; d != want[x] {
+		t.Errorf("%s reported at depth %d at least, want %d", x, d, want[x])
+	}
+	if d := rec.depths[y]; d != want[y] {
+		t.Errorf("%s reported at depth %d at least, want %d", y, d, want[y])
+	}
+}
+
+func TestBreadthFirstDepths(t *testing.T) {
+	// Workers running side by side finish their pages out of order
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	site := testsite.Generate("http://site.test", testsite.Options{
+		Pages: 3000, Branching: 4, BackLinks: true, CrossLinks: 3,
+		SlowPages: 0.05, SlowDelay: 5 * time.Millisecond,
+		Redirects: 0.05, DeadLinks: 0.05, Errors: 0.02, Seed: 7,
+	})
+	const maxDepth = 6
+	sets := []struct {
+		name    string
+		visited func() webcrawl.VisitedSet
+	}{
+		{"sharded", func() webcrawl.VisitedSet { return nil }},
+		{"memory", func() webcrawl.VisitedSet { return webcrawl.NewMemoryVisitedSet() }},
+	}
+	for _, set := range sets {
+		t.Run(set.name, func(t *testing.T) {
+			site.Reset()
+			rec := newRecorder()
+			c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(), webcrawl.WithDepth(maxDepth),
+				webcrawl.WithConcurrency(8), webcrawl.WithOnResult(rec.add))
+			c.Visited = set.visited()
+			// The dead links and errors fail, the crawl goes on
+			c.Crawl(site.URL("/"))
+
+			want := site.Reachable(maxDepth)
+			for u, d := range want {
+				got, ok := rec.depths[u]
+				switch {
+				case !ok:
+					t.Errorf("%s not reported", u)
+				case got != d:
+					t.Errorf("%s reported at depth %d, want %d", u, got, d)
+				}
+			}
+			for u, n := range rec.reports {
+				if _, ok := want[u]; !ok {
+					t.Errorf("%s reported, not reachable", u)
+				}
+				if n != 1 {
+					t.Errorf("%s reported %d times", u, n)
+				}
+			}
+			if total, twice := site.TotalFetches(); len(twice) > 0 {
+				t.Errorf("%d fetches, %d URLs fetched more than once: %v", total, len(twice), twice[:min(5, len(twice))])
+			}
+		})
+	}
+}
+
+func TestAdmittedOncePerShorterPath(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	site := testsite.Generate("http://site.test", testsite.Options{Pages: 2000, CrossLinks: 4, Redirects: 0.05, Seed: 3})
+	sets := []struct {
+		name    string
+		visited func() webcrawl.VisitedSet
+	}{
+		{"sharded", func() webcrawl.VisitedSet { return nil }},
+		{"memory", func() webcrawl.VisitedSet { return webcrawl.NewMemoryVisitedSet() }},
+	}
+	for _, set := range sets {
+		t.Run(set.name, func(t *testing.T) {
+			site.Reset()
+			rec := newRecorder()
+			var mu sync.Mutex
+			queued := make(map[string][]int)
+			c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(), webcrawl.WithDepth(webcrawl.UnlimitedDepth),
+				webcrawl.WithConcurrency(64), webcrawl.WithFrontier(&stackFrontier{}), webcrawl.WithOnResult(rec.add),
+				webcrawl.WithHooks(&webcrawl.Hooks{OnEnqueue: func(_ context.Context, it webcrawl.FrontierItem) bool {
+					mu.Lock()
+					queued[it.URL] = append(queued[it.URL], it.Depth)
+					mu.Unlock()
+					return true
+				}}))
+			c.Visited = set.visited()
+			if err := c.Crawl(site.URL("/")); err != nil {
+				t.Fatal(err)
+			}
+			// Depth first, the pages are found by shorter paths after,
+			//   and queued again once for each
+			for u, depths := range queued {
+				for i := 1; i < len(depths); i++ {
+					if depths[i] >= depths[i-1] {
+						t.Errorf("%s queued at depths %v, not each shorter than the one before", u, depths)
+						break
+					}
+				}
+			}
+			for u, d := range site.Reachable(webcrawl.UnlimitedDepth) {
+				if got := rec.depths[u]; got != d {
+					t.Errorf("%s reported at depth %d at least, want %d", u, got, d)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAdmission crawls a site of many links per page with 1 to 256
+// workers, the links admitted going through the dispatcher alone with a
+// MemoryVisitedSet, and screened by the workers with the default
+// ShardedVisitedSet. It reports the links admitted per second, see the
+// package doc for the figures
+func BenchmarkAdmission(b *testing.B) {
+	site := testsite.Generate("http://site.test", testsite.Options{Pages: 20000, Branching: 4, CrossLinks: 96, Seed: 1})
+	var links int
+	for _, p := range site.Paths() {
+		links += len(site.Page(p).Links)
+	}
+	sets := []struct {
+		name    string
+		visited func() webcrawl.VisitedSet
+	}{
+		{"memory", func() webcrawl.VisitedSet { return webcrawl.NewMemoryVisitedSet() }},
+		{"sharded", func() webcrawl.VisitedSet { return nil }},
+	}
+	for _, set := range sets {
+		for _, workers := range []int{1, 8, 64, 256} {
+			b.Run(fmt.Sprintf("%s/workers=%d", set.name, workers), func(b *testing.B) {
+				for range b.N {
+					site.Reset()
+					c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(),
+						webcrawl.WithDepth(webcrawl.UnlimitedDepth), webcrawl.WithConcurrency(workers))
+					c.Visited = set.visited()
+					if err := c.Crawl(site.URL("/")); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(links)*float64(b.N)/b.Elapsed().Seconds(), "links/s")
+			})
+		}
+	}
+}
+
+func TestMinBodyBytes(t *testing.T) {
+	site := testsite.New("http://site.test")
+	site.Add("/", &testsite.Page{Links: []string{"/thin", "/full"},
+		Body: `<p>` + strings.Repeat("Some text. ", 10) + `</p><a href="/thin">thin</a> <a href="/full">full</a>`})
+	site.Add("/thin", &testsite.Page{Links: []string{"/trap"}, Body: `<a href="/trap">x</a>`})
+	site.Add("/full", &testsite.Page{Body: `<p>` + strings.Repeat("More text. ", 10) + `</p>`})
+	site.Add("/trap", &testsite.Page{})
+	results := make(map[string]webcrawl.CrawlResult)
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(),
+		webcrawl.WithOnResult(func(res webcrawl.CrawlResult) { results[res.URL] = res }))
+	c.Sequential = true
+	c.MinBodyBytes = 100
+	if err := c.Crawl(site.URL("/")); err != nil {
+		t.Fatal(err)
+	}
+	thin := results[site.URL("/thin")]
+	if want := webcrawl.SkippedBodyTooShort + ":21"; thin.Skipped != want {
+		t.Errorf("Skipped of /thin = %q, want %q", thin.Skipped, want)
+	}
+	if thin.Body != `<a href="/trap">x</a>` || len(thin.Links) > 0 {
+		t.Errorf("/thin reported with body %q and links %v, want its body alone", thin.Body, thin.Links)
+	}
+	if n := site.Fetches(site.URL("/trap")); n != 0 {
+		t.Errorf("the link of /thin followed, /trap fetched %d times", n)
+	}
+	for _, p := range []string{"/", "/full"} {
+		if res := results[site.URL(p)]; res.Skipped != "" || res.ContentHash == "" {
+			t.Errorf("%s: Skipped %q, content hash %q, want the page in full", p, res.Skipped, res.ContentHash)
+		}
+	}
+}
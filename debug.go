@@ -0,0 +1,284 @@
+package webcrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuntimeStatus is what DebugHandler serves at /debug/runtime.
+type RuntimeStatus struct {
+	Goroutines int    `json:"goroutines"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	HeapAlloc  uint64 `json:"heap_alloc"` // bytes
+	HeapSys    uint64 `json:"heap_sys"`
+	NumGC      uint32 `json:"num_gc"`
+
+	// Runs are the crawls under way
+	Runs []RunDebug `json:"runs"`
+}
+
+// RunDebug is where the state of a crawl under way stands.
+type RunDebug struct {
+	Seed     string `json:"seed"`
+	Frontier int    `json:"frontier"`
+	Held     int    `json:"held"`    // URLs held for a host backed off
+	Visited  int    `json:"visited"` // -1 when the VisitedSet doesn't tell
+	Hosts    int    `json:"hosts"`
+	InFlight int    `json:"in_flight"`
+	Workers  int    `json:"workers"` // fetching at the same time
+	Spawned  int    `json:"spawned"` // worker goroutines started
+
+	// Unresponsive is set when the dispatcher of the crawl didn't take
+	//   the question, busy or stuck: the rest is unknown then
+	Unresponsive bool `json:"unresponsive,omitempty"`
+}
+
+// LeakReport is what DebugHandler serves at /debug/leaks: the goroutines of
+// the process grouped by where they wait, and those of the crawler that
+// look leaked.
+type LeakReport struct {
+	Goroutines int `json:"goroutines"`
+
+	// Workers are the worker goroutines of the runs found, and
+	//   ExpectedWorkers those the runs under way started: more of the
+	//   first are leaked, as are dispatchers beyond the runs
+	Workers             int `json:"workers"`
+	ExpectedWorkers     int `json:"expected_workers"`
+	Dispatchers         int `json:"dispatchers"`
+	ExpectedDispatchers int `json:"expected_dispatchers"`
+
+	// Suspects are the goroutines of this module waiting on a channel or
+	//   a lock for a minute or more, and the workers and dispatchers of
+	//   no run, unless a run is Unresponsive
+	Suspects []GoroutineGroup `json:"suspects"`
+	Groups   []GoroutineGroup `json:"groups"`
+}
+
+// GoroutineGroup is the goroutines that wait in the same state at the same
+// place, as created by the same function.
+type GoroutineGroup struct {
+	Count     int    `json:"count"`
+	State     string `json:"state"`        // such as "chan receive"
+	Minutes   int    `json:"wait_minutes"` // the longest wait, under a minute being 0
+	Function  string `json:"function"`     // the innermost one of this module, or else of all
+	CreatedBy string `json:"created_by"`
+	Stack     string `json:"stack"` // of one of them
+}
+
+// modulePath is the prefix of the functions of this module in the stacks
+const modulePath = "github.com/jackyugit/webcrawl"
+
+// DebugHandler returns the diagnostics of c and of the process over HTTP,
+// to mount on a server of its own, it has no authentication and tells a lot
+// about the process:
+//
+//	go http.ListenAndServe("localhost:6060", c.DebugHandler())
+//
+// with the endpoints
+//
+//	GET /debug/pprof/   the profiles of net/http/pprof
+//	GET /debug/runtime  the goroutine count, the heap, and the sizes of the frontier and visited set of the crawls under way, see RuntimeStatus
+//	GET /debug/leaks    the goroutines by where they wait, and the ones of the crawler that look leaked, see LeakReport
+func (c *Crawler) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/runtime", func(w http.ResponseWriter, req *http.Request) {
+		debugReply(w, c.RuntimeStatus())
+	})
+	mux.HandleFunc("GET /debug/leaks", func(w http.ResponseWriter, req *http.Request) {
+		debugReply(w, c.LeakReport())
+	})
+	return mux
+}
+
+func debugReply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// debugWait is how long runsDebug waits for a dispatcher to answer
+const debugWait = time.Second
+
+// runsDebug returns where the runs under way stand, from their dispatchers.
+// Unlike control, it doesn't wait on a dispatcher that is stuck, which is
+// when it is needed most
+func (c *Crawler) runsDebug() []RunDebug {
+	c.mu.Lock()
+	runs := make([]*run, 0, len(c.runs))
+	for r := range c.runs {
+		runs = append(runs, r)
+	}
+	c.mu.Unlock()
+	out := []RunDebug{}
+	for _, r := range runs {
+		reply := make(chan RunDebug, 1)
+		ctl := control{do: func(r *run) error {
+			d := RunDebug{Seed: r.seed, Frontier: r.frontier.Len(), Held: r.held, Visited: -1, Hosts: len(r.hosts),
+				InFlight: r.pending, Workers: r.limit, Spawned: r.spawned}
+			if l, ok := r.visited.(interface{ Len() int }); ok {
+				d.Visited = l.Len()
+			}
+			reply <- d
+			return nil
+		}, reply: make(chan error, 1)}
+		timer := time.NewTimer(debugWait)
+		select {
+		case r.controls <- ctl:
+			out = append(out, <-reply)
+		case <-r.over:
+		case <-timer.C:
+			out = append(out, RunDebug{Seed: r.seed, Visited: -1, Unresponsive: true})
+		}
+		timer.Stop()
+	}
+	return out
+}
+
+// RuntimeStatus returns the state of the process and of the crawls of c
+// under way.
+func (c *Crawler) RuntimeStatus() RuntimeStatus {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return RuntimeStatus{Goroutines: runtime.NumGoroutine(), GOMAXPROCS: runtime.GOMAXPROCS(0),
+		HeapAlloc: ms.HeapAlloc, HeapSys: ms.HeapSys, NumGC: ms.NumGC, Runs: c.runsDebug()}
+}
+
+// LeakReport checks the goroutines of the process for the ones of c that
+// are leaked: workers and dispatchers beyond those of the crawls under way,
+// and goroutines of this module blocked for a minute or more.
+func (c *Crawler) LeakReport() LeakReport {
+	runs := c.runsDebug()
+	rep := LeakReport{ExpectedDispatchers: len(runs), Suspects: []GoroutineGroup{}}
+	// The workers of a run that didn't answer are unknown
+	counted := true
+	for _, r := range runs {
+		rep.ExpectedWorkers += r.Spawned
+		counted = counted && !r.Unresponsive
+	}
+	// Taken after the runs, a worker started in between is not counted
+	//   leaked
+	gs := goroutines()
+	rep.Goroutines = len(gs)
+
+	groups := make(map[string]*GoroutineGroup)
+	var keys []string
+	for _, g := range gs {
+		worker := strings.Contains(g.stack, modulePath+".(*run).work(")
+		dispatcher := strings.Contains(g.stack, modulePath+".(*run).loop(")
+		if worker {
+			rep.Workers++
+		}
+		if dispatcher {
+			rep.Dispatchers++
+		}
+		key := g.state + "\x00" + g.function + "\x00" + g.createdBy
+		grp := groups[key]
+		if grp == nil {
+			grp = &GoroutineGroup{State: g.state, Function: g.function, CreatedBy: g.createdBy, Stack: g.stack}
+			groups[key] = grp
+			keys = append(keys, key)
+		}
+		grp.Count++
+		grp.Minutes = max(grp.Minutes, g.minutes)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		gi, gj := groups[keys[i]], groups[keys[j]]
+		if gi.Count != gj.Count {
+			return gi.Count > gj.Count
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		g := groups[k]
+		rep.Groups = append(rep.Groups, *g)
+		ours := strings.HasPrefix(g.Function, modulePath)
+		stuck := g.Minutes > 0 && blocked(g.State)
+		orphan := counted && rep.Workers > rep.ExpectedWorkers && strings.Contains(g.Stack, modulePath+".(*run).work(") ||
+			counted && rep.Dispatchers > rep.ExpectedDispatchers && strings.Contains(g.Stack, modulePath+".(*run).loop(")
+		if ours && stuck || orphan {
+			rep.Suspects = append(rep.Suspects, *g)
+		}
+	}
+	return rep
+}
+
+// blocked reports whether a goroutine in state waits on another one
+func blocked(state string) bool {
+	switch state {
+	case "chan send", "chan receive", "chan send (nil chan)", "chan receive (nil chan)", "select", "select (no cases)",
+		"semacquire", "sync.Mutex.Lock", "sync.RWMutex.Lock", "sync.RWMutex.RLock", "sync.Cond.Wait", "sync.WaitGroup.Wait":
+		return true
+	}
+	return false
+}
+
+// goroutine is a goroutine of a stack dump
+type goroutine struct {
+	state     string
+	minutes   int
+	function  string
+	createdBy string
+	stack     string
+}
+
+// goroutineHeader is the first line of a goroutine of a stack dump, such
+// as "goroutine 7 [chan receive, 3 minutes]:"
+var goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[([^,\]]+)(?:, (\d+) minutes)?[^\]]*\]:$`)
+
+// goroutines returns the goroutines of the process, but the one calling
+func goroutines() []goroutine {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var gs []goroutine
+	// The first one is this one
+	for i, block := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(string(block)), "\n")
+		m := goroutineHeader.FindStringSubmatch(lines[0])
+		if m == nil {
+			continue
+		}
+		g := goroutine{state: m[1], stack: strings.Join(lines[1:], "\n")}
+		g.minutes, _ = strconv.Atoi(m[2])
+		// The lines alternate the functions and their files
+		for j := 1; j < len(lines); j += 2 {
+			fn := lines[j]
+			if rest, ok := strings.CutPrefix(fn, "created by "); ok {
+				rest, _, _ = strings.Cut(rest, " in goroutine ")
+				g.createdBy = rest
+				continue
+			}
+			if k := strings.LastIndexByte(fn, '('); k > 0 {
+				fn = fn[:k]
+			}
+			if g.function == "" || !strings.HasPrefix(g.function, modulePath) && strings.HasPrefix(fn, modulePath) {
+				g.function = fn
+			}
+		}
+		gs = append(gs, g)
+	}
+	return gs
+}
@@ -0,0 +1,63 @@
+package webcrawl
+
+import (
+	neturl "net/url"
+	"regexp"
+)
+
+// UnlimitedDepth is the MaxDepth of a crawl following links however deep
+// they go, within its Scope and up to its MaxPages
+const UnlimitedDepth = -1
+
+// DepthRule is a depth limit of its own for the URLs it matches, see
+// Crawler.DepthRules and Crawler.DepthOverrides. A rule with both Path and
+// URLPattern set matches the URLs matching both.
+type DepthRule struct {
+	// Path is matched against the URL path, see Glob
+	Path *regexp.Regexp
+
+	// URLPattern is matched against the whole URL
+	URLPattern *regexp.Regexp
+
+	// MaxDepth is as Crawler.MaxDepth, UnlimitedDepth for no limit
+	MaxDepth int
+}
+
+// matches reports whether the rule matches rawURL, of the path p
+func (rule DepthRule) matches(rawURL, p string) bool {
+	return (rule.Path != nil || rule.URLPattern != nil) &&
+		(rule.Path == nil || rule.Path.MatchString(p)) &&
+		(rule.URLPattern == nil || rule.URLPattern.MatchString(rawURL))
+}
+
+// maxDepth returns the depth limit of rawURL, which is normalized: the one
+// of the first of DepthRules matching it, or else MaxDepth, capped by the
+// DepthOverrides matching it
+func (c *Crawler) maxDepth(rawURL string) int {
+	if len(c.DepthRules) == 0 && len(c.DepthOverrides) == 0 {
+		return c.MaxDepth
+	}
+	p := "/"
+	if u, err := neturl.Parse(rawURL); err == nil && u.EscapedPath() != "" {
+		p = u.EscapedPath()
+	}
+	limit := c.MaxDepth
+	for _, rule := range c.DepthRules {
+		if rule.matches(rawURL, p) {
+			limit = rule.MaxDepth
+			break
+		}
+	}
+	for _, rule := range c.DepthOverrides {
+		if rule.matches(rawURL, p) && rule.MaxDepth >= 0 && (limit < 0 || rule.MaxDepth < limit) {
+			limit = rule.MaxDepth
+		}
+	}
+	return limit
+}
+
+// tooDeep reports whether depth is beyond the limit of rawURL
+func (c *Crawler) tooDeep(rawURL string, depth int) bool {
+	max := c.maxDepth(rawURL)
+	return max >= 0 && depth >= max
+}
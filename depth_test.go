@@ -0,0 +1,55 @@
+package webcrawl_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+func TestDepthOverrides(t *testing.T) {
+	site := testsite.New("http://site.test")
+	site.Add("/", &testsite.Page{Links: []string{"/blog/1", "/users/1", "/docs/1"}})
+	for i, p := range []string{"/blog/1", "/blog/2", "/blog/3", "/blog/4", "/blog/5", "/blog/6"} {
+		next := []string{"/blog/" + string(rune('2'+i))}
+		site.Add(p, &testsite.Page{Links: next})
+	}
+	site.Add("/blog/7", &testsite.Page{})
+	site.Add("/users/1", &testsite.Page{Links: []string{"/users/2"}})
+	site.Add("/users/2", &testsite.Page{})
+	site.Add("/docs/1", &testsite.Page{Links: []string{"/docs/2"}})
+	site.Add("/docs/2", &testsite.Page{Links: []string{"/docs/3"}})
+	site.Add("/docs/3", &testsite.Page{})
+
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(), webcrawl.WithDepth(3))
+	c.Sequential = true
+	c.DepthRules = []webcrawl.DepthRule{{Path: webcrawl.Glob("/blog/**"), MaxDepth: webcrawl.UnlimitedDepth}}
+	c.DepthOverrides = []webcrawl.DepthRule{
+		// Both match the blog, the least counts
+		{URLPattern: regexp.MustCompile(`/blog/`), MaxDepth: 6},
+		{URLPattern: regexp.MustCompile(`^http://site\.test/blog/`), MaxDepth: 5},
+		{URLPattern: regexp.MustCompile(`/users/`), MaxDepth: 0},
+		// Above -depth, it doesn't raise it
+		{URLPattern: regexp.MustCompile(`/docs/`), MaxDepth: 10},
+		{Path: webcrawl.Glob("/docs/**"), URLPattern: regexp.MustCompile(`^https:`), MaxDepth: 0},
+	}
+	if err := c.Crawl(site.URL("/")); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]int{
+		"/blog/4": 1, "/blog/5": 0,
+		"/users/1": 0,
+		"/docs/2":  1, "/docs/3": 0,
+	} {
+		if n := site.Fetches(site.URL(p)); n != want {
+			t.Errorf("%s fetched %d times, want %d", p, n, want)
+		}
+	}
+
+	c.DepthOverrides = []webcrawl.DepthRule{{URLPattern: regexp.MustCompile(`.`), MaxDepth: 0}}
+	if err := c.Crawl(site.URL("/")); !errors.Is(err, webcrawl.ErrTooDeep) {
+		t.Errorf("crawl with every URL overridden to depth 0: %v, want ErrTooDeep", err)
+	}
+}
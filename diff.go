@@ -0,0 +1,81 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// DiffSnapshots compares two snapshots of a site, the one of an earlier
+// crawl and the one of a later, and returns the pages added, removed, and
+// modified: whose content or status changed, such as a page gone from 200
+// to 404 after a deploy, with their titles before and after. A status
+// unknown to either snapshot, saved before they had one, is no change. The
+// changes are sorted by URL, a nil Snapshot is an empty one.
+func DiffSnapshots(old, new *Snapshot) []Change {
+	if old == nil {
+		old = &Snapshot{}
+	}
+	if new == nil {
+		new = &Snapshot{}
+	}
+	var changes []Change
+	for u, st := range new.Pages {
+		prev, ok := old.Pages[u]
+		ch := Change{URL: u, OldHash: prev.Hash, NewHash: st.Hash, OldStatus: prev.Status, NewStatus: st.Status,
+			OldTitle: prev.Title, NewTitle: st.Title}
+		switch {
+		case !ok:
+			ch.Kind = PageAdded
+		case prev.Hash != st.Hash || prev.Status != 0 && st.Status != 0 && prev.Status != st.Status:
+			ch.Kind = PageModified
+		default:
+			continue
+		}
+		changes = append(changes, ch)
+	}
+	for u, prev := range old.Pages {
+		if _, ok := new.Pages[u]; !ok {
+			changes = append(changes, Change{Kind: PageRemoved, URL: u, OldHash: prev.Hash, OldStatus: prev.Status, OldTitle: prev.Title})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].URL < changes[j].URL })
+	return changes
+}
+
+// WriteChanges writes changes to w, one per line with the kind and the URL,
+// then for a modified page what changed of it:
+//
+//	added	https://example.com/new
+//	modified	https://example.com/	status 200 -> 404	title "Home" -> "Not Found"	content
+//	removed	https://example.com/old
+func WriteChanges(w io.Writer, changes []Change) error {
+	for _, ch := range changes {
+		line := ch.Kind.String() + "\t" + ch.URL
+		if ch.Kind == PageModified {
+			if ch.OldStatus != ch.NewStatus {
+				line += fmt.Sprintf("\tstatus %s -> %s", statusText(ch.OldStatus), statusText(ch.NewStatus))
+			}
+			if ch.OldTitle != ch.NewTitle {
+				line += fmt.Sprintf("\ttitle %q -> %q", ch.OldTitle, ch.NewTitle)
+			}
+			if ch.OldHash != ch.NewHash {
+				line += "\tcontent"
+			}
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusText is a status of a Change as WriteChanges writes it, "-" for
+// none
+func statusText(code int) string {
+	if code == 0 {
+		return "-"
+	}
+	return strconv.Itoa(code)
+}
@@ -0,0 +1,136 @@
+package webcrawl
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Directives are what a page tells robots to do with it, in its
+// <meta name="robots"> tags or its X-Robots-Tag headers.
+type Directives struct {
+	NoIndex  bool // not to be indexed
+	NoFollow bool // its links are not to be followed
+}
+
+// Parse adds the directives of a comma-separated list such as
+// "noindex, nofollow" to d. "none" is both, the directives it doesn't
+// know are ignored.
+func (d *Directives) Parse(list string) {
+	for _, v := range strings.Split(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "noindex":
+			d.NoIndex = true
+		case "nofollow":
+			d.NoFollow = true
+		case "none":
+			d.NoIndex, d.NoFollow = true, true
+		}
+	}
+}
+
+// HeaderDirectives returns the directives of the X-Robots-Tag headers of
+// h that apply to userAgent: those without a user agent in front, and
+// those after "userAgent:", going by its product token as robots.txt
+// does.
+func HeaderDirectives(h http.Header, userAgent string) Directives {
+	agent := productToken(userAgent)
+	var d Directives
+	for _, v := range h.Values("X-Robots-Tag") {
+		applies := true
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if name, rest, ok := strings.Cut(part, ":"); ok && !valuedDirective(name) {
+				// A user agent in front, for the rest of the header
+				applies = strings.EqualFold(strings.TrimSpace(name), agent)
+				part = rest
+			}
+			if applies {
+				d.Parse(part)
+			}
+		}
+	}
+	return d
+}
+
+// valuedDirective reports whether name is a directive followed by a colon
+// and a value, rather than a user agent
+func valuedDirective(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+		return true
+	}
+	return false
+}
+
+// MetaDirectives returns the directives of the <meta name="robots"> tags
+// of doc, and of those named after the product token of userAgent.
+func MetaDirectives(doc *html.Node, userAgent string) Directives {
+	agent := productToken(userAgent)
+	var d Directives
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+			name, _ := attr(n, "name")
+			if strings.EqualFold(name, "robots") || strings.EqualFold(name, agent) {
+				content, _ := attr(n, "content")
+				d.Parse(content)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return d
+}
+
+// pageLinks parses the HTML page read from body, found at base, and sets
+// the links and the directives of out: the links a robot may follow go to
+// Links, the others, marked rel="nofollow" or all of them on a nofollow
+// page, to NoFollowLinks
+func pageLinks(out *Response, e *LinkExtractor, base *url.URL, body io.Reader, userAgent string) error {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return err
+	}
+	d := MetaDirectives(doc, userAgent)
+	out.NoIndex = out.NoIndex || d.NoIndex
+	out.NoFollow = out.NoFollow || d.NoFollow
+
+	var follow, nofollow []Link
+	for _, l := range e.ExtractNode(base, doc) {
+		if l.NoFollow || out.NoFollow {
+			nofollow = append(nofollow, l)
+		} else {
+			follow = append(follow, l)
+		}
+	}
+	out.Links = LinkURLs(follow)
+	// A URL linked to both ways can be followed
+	followed := make(map[string]bool, len(out.Links))
+	for _, u := range out.Links {
+		followed[u] = true
+	}
+	for _, u := range LinkURLs(nofollow) {
+		if !followed[u] {
+			out.NoFollowLinks = append(out.NoFollowLinks, u)
+		}
+	}
+	return nil
+}
+
+// hasRel reports whether the rel attribute of n has the link type t
+func hasRel(n *html.Node, t string) bool {
+	rel, _ := attr(n, "rel")
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,418 @@
+package webcrawl
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// The defaults of DNSCache
+const (
+	// DefaultDNSTTL is how long an answer is kept when its Resolver
+	//   doesn't tell
+	DefaultDNSTTL = time.Minute
+
+	// DefaultNegativeDNSTTL is how long a failed lookup is kept
+	DefaultNegativeDNSTTL = 10 * time.Second
+)
+
+// Resolver looks host names up for a DNSCache.
+type Resolver interface {
+	// LookupHost returns the IP addresses of host, and how long they may
+	//   be kept, zero when it doesn't know
+	LookupHost(ctx context.Context, host string) (addrs []string, ttl time.Duration, err error)
+}
+
+// SystemResolver is the Resolver of the system, net.DefaultResolver, with
+// /etc/hosts and all. It doesn't know the TTL of its answers.
+type SystemResolver struct{}
+
+// LookupHost implements Resolver.
+func (SystemResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	return addrs, 0, err
+}
+
+// DNSResolver is a Resolver querying name servers itself, over UDP and
+// over TCP for the answers too long for it, which gets it the TTL of the
+// answers. Names are looked up as they are, without the search domains of
+// /etc/resolv.conf, nor /etc/hosts.
+type DNSResolver struct {
+	// Servers are the name servers asked in turn, host or host:port, those
+	//   of /etc/resolv.conf when empty
+	Servers []string
+
+	// Timeout caps every query, 5 seconds when zero
+	Timeout time.Duration
+}
+
+// LookupHost implements Resolver, returning the IPv4 and IPv6 addresses of
+// host and the lowest TTL of the records that led to them.
+func (r *DNSResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	servers := r.Servers
+	if len(servers) == 0 {
+		var err error
+		if servers, err = resolvConf("/etc/resolv.conf"); err != nil {
+			return nil, 0, err
+		}
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	var lastErr error
+	for _, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		addrs, ttl, err := r.lookupAt(ctx, server, name)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			// The server knows there is none, the next won't either
+			return nil, 0, err
+		}
+		lastErr = err
+	}
+	return nil, 0, lastErr
+}
+
+// lookupAt asks server for the IPv4 and IPv6 addresses of name
+func (r *DNSResolver) lookupAt(ctx context.Context, server string, name dnsmessage.Name) ([]string, time.Duration, error) {
+	var addrs []string
+	var ttl uint32
+	for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		a, t, err := r.query(ctx, server, name, typ)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(a) > 0 && (len(addrs) == 0 || t < ttl) {
+			ttl = t
+		}
+		addrs = append(addrs, a...)
+	}
+	if len(addrs) == 0 {
+		return nil, 0, &net.DNSError{Err: "no address for host", Name: name.String(), Server: server, IsNotFound: true}
+	}
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+// query asks server for the records of name of type typ, returning the
+// addresses and the lowest TTL of the answers
+func (r *DNSResolver) query(ctx context.Context, server string, name dnsmessage.Name, typ dnsmessage.Type) ([]string, uint32, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: name, Type: typ, Class: dnsmessage.ClassINET})
+	q, err := b.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+	answer, err := exchange(ctx, "udp", server, q, id)
+	if err == nil {
+		var h dnsmessage.Header
+		var p dnsmessage.Parser
+		if h, err = p.Start(answer); err == nil && h.Truncated {
+			answer, err = exchange(ctx, "tcp", server, q, id)
+		}
+	}
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server, IsTimeout: ctx.Err() != nil}
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(answer)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server}
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: name.String(), Server: server, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: "server answered " + h.RCode.String(), Name: name.String(), Server: server}
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server}
+	}
+	var addrs []string
+	var ttl uint32
+	for first := true; ; first = false {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server}
+		}
+		// The CNAMEs on the way count for the TTL as well
+		if first || rh.TTL < ttl {
+			ttl = rh.TTL
+		}
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			rr, err := p.AResource()
+			if err != nil {
+				return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server}
+			}
+			addrs = append(addrs, netip.AddrFrom4(rr.A).String())
+		case dnsmessage.TypeAAAA:
+			rr, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server}
+			}
+			addrs = append(addrs, netip.AddrFrom16(rr.AAAA).String())
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: server}
+			}
+		}
+	}
+	return addrs, ttl, nil
+}
+
+// exchange sends the query q to server over network and returns the
+// answer with the same id
+func exchange(ctx context.Context, network, server string, q []byte, id uint16) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if network == "tcp" {
+		msg := make([]byte, 2, 2+len(q))
+		binary.BigEndian.PutUint16(msg, uint16(len(q)))
+		if _, err := conn.Write(append(msg, q...)); err != nil {
+			return nil, err
+		}
+		var n [2]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return nil, err
+		}
+		answer := make([]byte, binary.BigEndian.Uint16(n[:]))
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return nil, err
+		}
+		return answer, nil
+	}
+	if _, err := conn.Write(q); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Stray answers to other queries are skipped
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// resolvConf returns the name servers of the resolv.conf file name
+func resolvConf(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: no name servers: %w", err)
+	}
+	defer f.Close()
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("webcrawl: no name servers in %s", name)
+	}
+	return servers, sc.Err()
+}
+
+// DNSCache resolves the host names a crawl comes across once for as long
+// as their TTL, so that a large crawl doesn't hammer the resolver, and
+// resolves some of them as told instead, for a staging server to be
+// crawled under the names of production. Use its DialFunc as the
+// DialContext of an http.Transport, see HTTPOptions.DNS. It is safe for
+// concurrent use, and its zero value is ready to use.
+type DNSCache struct {
+	// Resolver looks the host names up, SystemResolver when nil
+	Resolver Resolver
+
+	// Overrides are the addresses of hosts, which are not looked up
+	Overrides map[string][]string
+
+	// MinTTL and MaxTTL bound how long an answer is kept, whatever its
+	//   TTL: zero means no bound. TTL is how long one is kept when the
+	//   Resolver doesn't tell, DefaultDNSTTL when zero
+	MinTTL, MaxTTL, TTL time.Duration
+
+	// NegativeTTL is how long a failed lookup is kept,
+	//   DefaultNegativeDNSTTL when zero, negative not to keep them
+	NegativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+// dnsEntry is a lookup of a DNSCache, under way until ready is closed
+type dnsEntry struct {
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+	next    int // of addrs, to dial them in turn
+}
+
+// LookupHost returns the addresses of host, as cached or looked up.
+func (c *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	e, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return e.addrs, nil
+}
+
+func (c *DNSCache) lookup(ctx context.Context, host string) (*dnsEntry, error) {
+	if addrs, ok := c.Overrides[host]; ok {
+		return &dnsEntry{addrs: addrs}, nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return &dnsEntry{addrs: []string{host}}, nil
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*dnsEntry)
+	}
+	e := c.entries[host]
+	if e != nil {
+		select {
+		case <-e.ready:
+			if time.Now().After(e.expires) {
+				e = nil
+			}
+		default:
+		}
+	}
+	if e == nil {
+		// The lookups of the same host at the same time wait for this one
+		e = &dnsEntry{ready: make(chan struct{})}
+		c.entries[host] = e
+		c.mu.Unlock()
+		c.resolve(ctx, host, e)
+		close(e.ready)
+	} else {
+		c.mu.Unlock()
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return e, e.err
+}
+
+// resolve looks host up into e
+func (c *DNSCache) resolve(ctx context.Context, host string, e *dnsEntry) {
+	r := c.Resolver
+	if r == nil {
+		r = SystemResolver{}
+	}
+	addrs, ttl, err := r.LookupHost(ctx, host)
+	now := time.Now()
+	if err != nil {
+		e.err = err
+		neg := c.NegativeTTL
+		if neg == 0 {
+			neg = DefaultNegativeDNSTTL
+		}
+		if ctx.Err() != nil {
+			// The lookup was called off, not failed
+			neg = -1
+		}
+		e.expires = now.Add(neg)
+		return
+	}
+	if ttl <= 0 {
+		ttl = c.TTL
+		if ttl <= 0 {
+			ttl = DefaultDNSTTL
+		}
+	}
+	if c.MinTTL > 0 {
+		ttl = max(ttl, c.MinTTL)
+	}
+	if c.MaxTTL > 0 {
+		ttl = min(ttl, c.MaxTTL)
+	}
+	e.addrs, e.expires = addrs, now.Add(ttl)
+}
+
+// DialFunc returns a DialContext for an http.Transport dialing with d, or
+// a net.Dialer of its own when nil, the addresses of the host as c has
+// them. They are tried in turn from one dial to the next, and the next one
+// when one fails.
+func (c *DNSCache) DialFunc(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d == nil {
+		d = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		e, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		first := e.next
+		e.next++
+		c.mu.Unlock()
+		var lastErr error
+		for i := range e.addrs {
+			ip := e.addrs[(first+i)%len(e.addrs)]
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if lastErr == nil {
+			lastErr = &net.DNSError{Err: "no address for host", Name: host, IsNotFound: true}
+		}
+		return nil, lastErr
+	}
+}
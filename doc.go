@@ -0,0 +1,56 @@
+// Package webcrawl is a small concurrent web crawler.
+//
+// It started as the last exercise of the Go Tour
+// (https://tour.golang.org/concurrency/9) and grew into a library: a
+// Crawler walks the pages reachable from a seed URL, up to a maximum depth,
+// fetching each page once through a pluggable Fetcher and reporting a
+// CrawlResult per page.
+//
+//	c := &webcrawl.Crawler{
+//		Fetcher:  webcrawl.NewHTTPFetcher(10 * time.Second),
+//		MaxDepth: 3,
+//		OnResult: func(r webcrawl.CrawlResult) { fmt.Println(r.URL) },
+//	}
+//	c.Crawl("https://example.com/")
+//
+// NewCrawler builds the same from options, with sensible defaults:
+//
+//	c := webcrawl.NewCrawler(webcrawl.WithDepth(3), webcrawl.WithConcurrency(4))
+//	results := c.Results()
+//	go func() {
+//		for r := range results {
+//			fmt.Println(r.URL)
+//		}
+//	}()
+//	err := c.Run(ctx, "https://example.com/")
+//
+// # Admission
+//
+// The links a page has are admitted on the worker that fetched it, against
+// a ShardedVisitedSet, the dispatcher goroutine only queueing those found
+// new, where it used to normalize, screen and check every one of them
+// itself with a MemoryVisitedSet, which it still does with any VisitedSet
+// but the default. BenchmarkAdmission crawls a site of 20,000 pages of 100
+// links each from memory both ways:
+//
+//	go test -run '^$' -bench Admission -benchtime 1x -count 5
+//
+// Links admitted per second, median of 5, on a machine of a single CPU,
+// and the share of the CPU the dispatcher took at 64 workers:
+//
+//	workers   MemoryVisitedSet   ShardedVisitedSet
+//	      1            500,170             360,252
+//	      8            431,106             341,097
+//	     64            395,844             334,197
+//	    256            348,402             352,655
+//
+//	dispatcher at 64 workers       59%          7%
+//
+// On a single CPU the sharded set is slower, by up to 28%: the
+// screening is the same work wherever it runs, and handing the links over
+// from the workers costs more. What it buys is the share of the work left
+// to the one dispatcher goroutine, which caps what more CPUs can bring: by
+// Amdahl's law at most 1.7 times the throughput of one CPU with the
+// MemoryVisitedSet, and 15 times with the ShardedVisitedSet. These caps
+// are not measured, a single CPU can't tell them.
+package webcrawl
@@ -0,0 +1,136 @@
+package webcrawl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// documentTypes maps the extensions of the documents to their media type,
+// mime.TypeByExtension not knowing them all everywhere
+var documentTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".rtf":  "application/rtf",
+	".epub": "application/epub+zip",
+}
+
+// documentMediaTypes is the set of the media types of documentTypes
+var documentMediaTypes = func() map[string]bool {
+	types := make(map[string]bool, len(documentTypes))
+	for _, t := range documentTypes {
+		types[t] = true
+	}
+	return types
+}()
+
+// documentURL returns the media type of the document rawURL is by its
+// extension, "" when it isn't one
+func documentURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return documentTypes[strings.ToLower(path.Ext(u.Path))]
+}
+
+// documentType returns the media type of the document res is, by its
+// Content-Type or else its extension, "" when it isn't one
+func documentType(res CrawlResult) string {
+	if mt := mediaType(res); documentMediaTypes[mt] {
+		return mt
+	}
+	if res.Header.Get("Content-Type") == "" || mediaType(res) == "application/octet-stream" {
+		return documentURL(pageURL(res))
+	}
+	return ""
+}
+
+// DocumentExtractor takes the text and links out of the documents a crawl
+// fetches, PDFs, Word files and the like, see Crawler.DocumentExtractor.
+type DocumentExtractor interface {
+	// Extract returns the text of the document in body, of the media type
+	//   mediaType, and the URLs it links to, relative ones being resolved
+	//   against the URL of the document. A document it can't read has no
+	//   text nor links, without an error
+	Extract(ctx context.Context, mediaType string, body []byte) (text string, links []string, err error)
+}
+
+// CommandExtractor is a DocumentExtractor running a command that writes
+// the text of the document it reads on its standard input, such as
+// pdftotext of Poppler:
+//
+//	&webcrawl.CommandExtractor{Command: []string{"pdftotext", "-layout", "-", "-"}}
+//
+// The links are the http and https URLs of the text, along with the URI
+// actions of the links of a PDF when they are not compressed.
+type CommandExtractor struct {
+	// Command is the program and its arguments
+	Command []string
+
+	// Types are the media types the command reads, when nil only
+	//   application/pdf
+	Types []string
+}
+
+// pdfURIs finds the URI actions of a PDF, /URI (https://example.com/)
+var pdfURIs = regexp.MustCompile(`/URI\s*\(((?:[^()\\]|\\.)*)\)`)
+
+// textURLs finds the URLs written out in a text
+var textURLs = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}\\]+[^\s<>"'()\[\]{}\\.,;:!?]`)
+
+// Extract implements DocumentExtractor.
+func (e *CommandExtractor) Extract(ctx context.Context, mediaType string, body []byte) (string, []string, error) {
+	types := e.Types
+	if types == nil {
+		types = []string{"application/pdf"}
+	}
+	ok := false
+	for _, t := range types {
+		ok = ok || t == mediaType
+	}
+	if !ok || len(e.Command) == 0 {
+		return "", nil, nil
+	}
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", nil, fmt.Errorf("webcrawl: %s: %w", e.Command[0], err)
+	}
+	text := stdout.String()
+	var links []string
+	seen := make(map[string]bool)
+	add := func(u string) {
+		if !seen[u] {
+			seen[u] = true
+			links = append(links, u)
+		}
+	}
+	if mediaType == "application/pdf" {
+		for _, m := range pdfURIs.FindAllSubmatch(body, -1) {
+			add(strings.NewReplacer(`\(`, "(", `\)`, ")", `\\`, `\`).Replace(string(m[1])))
+		}
+	}
+	for _, u := range textURLs.FindAllString(text, -1) {
+		add(u)
+	}
+	return text, links, nil
+}
@@ -0,0 +1,228 @@
+package webcrawl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// SimHash returns the simhash of the text of body, an HTML page or plain
+// text: a 64-bit fingerprint that differs in few bits between pages whose
+// text differs little. Two pages sharing a template but for a date or a
+// counter typically end up a handful of bits apart, see SimHashDistance.
+func SimHash(body string) uint64 {
+	words := strings.Fields(strings.ToLower(pageText(body)))
+	// Features are the runs of three words, or the words of short texts
+	const shingle = 3
+	n := len(words) - shingle + 1
+	if n < 1 {
+		n = len(words)
+	}
+	var weights [64]int
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		for _, w := range words[i:min(i+shingle, len(words))] {
+			h.Write([]byte(w))
+			h.Write([]byte{' '})
+		}
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+	var sim uint64
+	for b, w := range weights {
+		if w > 0 {
+			sim |= 1 << b
+		}
+	}
+	return sim
+}
+
+// SimHashDistance returns how many bits a and b differ in.
+func SimHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// pageText returns the text of body with the markup, scripts and styles
+// taken out, body as is when it has no markup
+func pageText(body string) string {
+	if !strings.Contains(body, "<") {
+		return body
+	}
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(body))
+	skip := 0 // inside script or style
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isHiddenTag(string(name)) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isHiddenTag(string(name)) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func isHiddenTag(name string) bool {
+	return name == "script" || name == "style" || name == "noscript" || name == "template"
+}
+
+// DuplicateReport finds the pages of a crawl that have the same content
+// under different URLs, such as a page reachable with and without a
+// tracking parameter, and, with Near, the ones that are almost the same.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. Run
+// the Crawler with DedupContent for the links of the duplicates not to be
+// followed again, they are reported all the same. A DuplicateReport is
+// safe for concurrent use, its zero value is ready to use.
+type DuplicateReport struct {
+	// Near makes Add compute the SimHash of every page, for NearClusters
+	Near bool
+
+	mu    sync.Mutex
+	pages map[string]pageHash // URL => the hashes of its content
+}
+
+type pageHash struct {
+	hash string
+	sim  uint64
+}
+
+// Add records the content of a page that was fetched fine, other results
+// are ignored.
+func (r *DuplicateReport) Add(res CrawlResult) {
+	if res.Err != nil || res.External || res.Body == "" {
+		return
+	}
+	p := pageHash{hash: res.ContentHash}
+	if p.hash == "" {
+		p.hash = ContentHash(res.Body)
+	}
+	if r.Near {
+		p.sim = SimHash(res.Body)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pages == nil {
+		r.pages = make(map[string]pageHash)
+	}
+	r.pages[res.URL] = p
+}
+
+// Clusters returns the groups of URLs whose content is exactly the same,
+// each sorted, in the order of their first URL. Pages without a duplicate
+// are left out.
+func (r *DuplicateReport) Clusters() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byHash := make(map[string][]string)
+	for u, p := range r.pages {
+		byHash[p.hash] = append(byHash[p.hash], u)
+	}
+	var clusters [][]string
+	for _, urls := range byHash {
+		if len(urls) > 1 {
+			clusters = append(clusters, urls)
+		}
+	}
+	return sortClusters(clusters)
+}
+
+// NearClusters returns the groups of URLs whose SimHash is at most
+// maxDistance bits away from another one of the group, as Clusters does.
+// Exact duplicates are near ones too. It needs Near to have been set as
+// the pages were added, and compares every pair of pages, which is fine
+// for a site of some thousand pages.
+func (r *DuplicateReport) NearClusters(maxDistance int) [][]string {
+	r.mu.Lock()
+	urls := make([]string, 0, len(r.pages))
+	for u := range r.pages {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	sims := make([]uint64, len(urls))
+	for i, u := range urls {
+		sims[i] = r.pages[u].sim
+	}
+	r.mu.Unlock()
+
+	// Union-find over the pairs close enough
+	parent := make([]int, len(urls))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range urls {
+		for j := i + 1; j < len(urls); j++ {
+			if SimHashDistance(sims[i], sims[j]) <= maxDistance {
+				parent[root(j)] = root(i)
+			}
+		}
+	}
+	groups := make(map[int][]string)
+	for i, u := range urls {
+		groups[root(i)] = append(groups[root(i)], u)
+	}
+	var clusters [][]string
+	for _, g := range groups {
+		if len(g) > 1 {
+			clusters = append(clusters, g)
+		}
+	}
+	return sortClusters(clusters)
+}
+
+func sortClusters(clusters [][]string) [][]string {
+	for _, c := range clusters {
+		sort.Strings(c)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i][0] < clusters[j][0] })
+	return clusters
+}
+
+// WriteClusters writes clusters, as returned by Clusters or NearClusters,
+// to w: the first URL of each, followed by the others:
+//
+//	https://example.com/
+//		https://example.com/?utm_source=feed
+//		https://example.com/index.html
+func WriteClusters(w io.Writer, clusters [][]string) error {
+	for _, c := range clusters {
+		if _, err := fmt.Fprintln(w, c[0]); err != nil {
+			return err
+		}
+		for _, u := range c[1:] {
+			if _, err := fmt.Fprintf(w, "\t%s\n", u); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
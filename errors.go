@@ -0,0 +1,183 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"sort"
+	"strings"
+)
+
+// The causes a failed URL is classified under, test for them with
+// errors.Is.
+var (
+	// ErrNotFound is the cause of pages that don't exist, such as a 404
+	//   or 410 answer or a missing file
+	ErrNotFound = errors.New("not found")
+
+	// ErrTimeout is the cause of fetches that took too long
+	ErrTimeout = errors.New("timed out")
+
+	// ErrRobotsBlocked is the cause of URLs that robots.txt disallows
+	ErrRobotsBlocked = errors.New("blocked by robots.txt")
+
+	// ErrTooDeep is the cause of URLs beyond the crawl's depth limit,
+	//   which Run returns for a seed when MaxDepth leaves nothing to crawl
+	ErrTooDeep = errors.New("too deep")
+
+	// ErrHTTPStatus is the cause of any other error status from the server
+	ErrHTTPStatus = errors.New("unexpected HTTP status")
+
+	// ErrRedirectLoop is the cause of redirects that come back to a URL
+	//   they went through already
+	ErrRedirectLoop = errors.New("redirect loop")
+
+	// ErrTooManyRedirects is the cause of redirect chains longer than the
+	//   fetcher follows
+	ErrTooManyRedirects = errors.New("too many redirects")
+
+	// ErrHostDown is the cause of the URLs of a host the circuit breaker
+	//   of the crawl gave up on, which are not fetched, see CircuitBreaker
+	ErrHostDown = errors.New("host down")
+
+	// ErrFetchFailed is the cause of every failure that fits nowhere else
+	ErrFetchFailed = errors.New("fetch failed")
+)
+
+// causes lists every cause Classify returns
+var causes = []error{ErrNotFound, ErrTimeout, ErrRobotsBlocked, ErrTooDeep, ErrHTTPStatus,
+	ErrRedirectLoop, ErrTooManyRedirects, ErrHostDown, ErrFetchFailed}
+
+// Is makes 404 and 410 errors match ErrNotFound and any other status
+// ErrHTTPStatus.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == 404 || e.StatusCode == 410
+	case ErrHTTPStatus:
+		return e.StatusCode != 404 && e.StatusCode != 410
+	}
+	return false
+}
+
+// Classify returns the cause err falls under, one of the Err values of
+// this package, or nil for a nil err.
+func Classify(err error) error {
+	var ne net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, ErrRobotsBlocked):
+		return ErrRobotsBlocked
+	case errors.Is(err, ErrTooDeep):
+		return ErrTooDeep
+	case errors.Is(err, ErrRedirectLoop):
+		return ErrRedirectLoop
+	case errors.Is(err, ErrTooManyRedirects):
+		return ErrTooManyRedirects
+	case errors.Is(err, ErrHostDown):
+		return ErrHostDown
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &ne) && ne.Timeout():
+		return ErrTimeout
+	case errors.Is(err, ErrHTTPStatus):
+		return ErrHTTPStatus
+	}
+	return ErrFetchFailed
+}
+
+// FetchError is a URL the crawler could not crawl, and why.
+type FetchError struct {
+	URL   string
+	Depth int
+	Cause error // what Classify made of Err
+	Err   error // as reported by the fetcher
+}
+
+func (e *FetchError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+// Unwrap makes errors.Is match both the cause and the original error.
+func (e *FetchError) Unwrap() []error {
+	return []error{e.Cause, e.Err}
+}
+
+// ErrorReport is the error Run returns when some URLs failed, it lists
+// every one of them.
+type ErrorReport struct {
+	Errors []*FetchError // sorted by URL
+}
+
+// add records a failure of url, classifying err
+func (r *ErrorReport) add(url string, depth int, err error) {
+	r.Errors = append(r.Errors, &FetchError{URL: url, Depth: depth, Cause: Classify(err), Err: err})
+}
+
+// remove forgets the failure of url, fetched again
+func (r *ErrorReport) remove(url string) {
+	for i, e := range r.Errors {
+		if e.URL == url {
+			r.Errors = append(r.Errors[:i], r.Errors[i+1:]...)
+			return
+		}
+	}
+}
+
+// ByCause groups the failed URLs by their cause.
+func (r *ErrorReport) ByCause() map[error][]*FetchError {
+	m := make(map[error][]*FetchError)
+	for _, e := range r.Errors {
+		m[e.Cause] = append(m[e.Cause], e)
+	}
+	return m
+}
+
+// Error summarizes the report, such as
+// "3 URLs failed: 2 not found, 1 timed out".
+func (r *ErrorReport) Error() string {
+	byCause := r.ByCause()
+	order := make([]error, 0, len(byCause))
+	for cause := range byCause {
+		order = append(order, cause)
+	}
+	// Most frequent cause first
+	sort.Slice(order, func(i, j int) bool {
+		ni, nj := len(byCause[order[i]]), len(byCause[order[j]])
+		if ni != nj {
+			return ni > nj
+		}
+		return order[i].Error() < order[j].Error()
+	})
+	causes := make([]string, len(order))
+	for i, cause := range order {
+		causes[i] = fmt.Sprintf("%d %v", len(byCause[cause]), cause)
+	}
+	noun := "URLs"
+	if len(r.Errors) == 1 {
+		noun = "URL"
+	}
+	return fmt.Sprintf("%d %s failed: %s", len(r.Errors), noun, strings.Join(causes, ", "))
+}
+
+// Unwrap makes errors.Is and errors.As look into each FetchError.
+func (r *ErrorReport) Unwrap() []error {
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+	return errs
+}
+
+// err returns r as an error, or nil when nothing failed
+func (r *ErrorReport) err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	sort.Slice(r.Errors, func(i, j int) bool { return r.Errors[i].URL < r.Errors[j].URL })
+	return r
+}
@@ -0,0 +1,144 @@
+package webcrawl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FeedEntry is an item of an RSS feed, or an entry of an Atom one.
+type FeedEntry struct {
+	URL       string // absolute
+	Title     string
+	Published time.Time // zero when the feed doesn't say
+}
+
+// feedTypes are the media types of the feeds a page can announce
+var feedTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+}
+
+// ExtractFeeds returns the URLs of the RSS and Atom feeds doc, found at
+// base, announces with <link rel="alternate">, in document order.
+func ExtractFeeds(base *url.URL, doc *html.Node) []string {
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+	var feeds []string
+	seen := make(map[string]bool)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Link && hasRel(n, "alternate") {
+			t, _ := attr(n, "type")
+			t, _, _ = strings.Cut(strings.ToLower(t), ";")
+			if href, ok := attr(n, "href"); ok && feedTypes[strings.TrimSpace(t)] {
+				if u := assetURL(base, href); u != "" && !seen[u] {
+					seen[u] = true
+					feeds = append(feeds, u)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return feeds
+}
+
+// The XML of the feeds, RSS 2.0 and 1.0 items alike, namespaces ignored as
+// for the sitemaps
+type xmlFeed struct {
+	Items   []xmlFeedItem `xml:"channel>item"`
+	RDF     []xmlFeedItem `xml:"item"` // RSS 1.0 has them next to the channel
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+type xmlFeedItem struct {
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+	GUID  struct {
+		Value     string `xml:",chardata"`
+		PermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"` // dc:date
+}
+
+// ParseFeed parses an RSS or Atom feed, found at base, and returns its
+// entries in order, their links resolved against base.
+func ParseFeed(base *url.URL, data []byte) ([]FeedEntry, error) {
+	root, err := xmlRoot(data)
+	if err != nil {
+		return nil, err
+	}
+	if root != "rss" && root != "RDF" && root != "feed" {
+		return nil, fmt.Errorf("feed: unexpected root element <%s>", root)
+	}
+	var f xmlFeed
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("feed: %w", err)
+	}
+	var entries []FeedEntry
+	add := func(link, title string, published time.Time) {
+		if u := assetURL(base, link); u != "" {
+			entries = append(entries, FeedEntry{URL: u, Title: strings.TrimSpace(title), Published: published})
+		}
+	}
+	for _, it := range append(f.Items, f.RDF...) {
+		link := strings.TrimSpace(it.Link)
+		if link == "" && it.GUID.PermaLink != "false" {
+			// The guid is the permalink unless it says otherwise
+			link = strings.TrimSpace(it.GUID.Value)
+		}
+		published := parseFeedDate(strings.TrimSpace(it.PubDate))
+		if published.IsZero() {
+			published = parseW3CDate(strings.TrimSpace(it.Date))
+		}
+		add(link, it.Title, published)
+	}
+	for _, e := range f.Entries {
+		link := ""
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		published := parseW3CDate(strings.TrimSpace(e.Published))
+		if published.IsZero() {
+			published = parseW3CDate(strings.TrimSpace(e.Updated))
+		}
+		add(link, e.Title, published)
+	}
+	return entries, nil
+}
+
+// parseFeedDate parses the RFC 822 dates of RSS, with the variations found
+// in the wild
+func parseFeedDate(s string) time.Time {
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST",
+		time.RFC822Z, time.RFC822, "2 Jan 2006 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,155 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Fetcher retrieves pages for the Crawler.
+type Fetcher interface {
+	// Fetch returns the body of URL and
+	// a slice of URLs found on that page.
+	// The fetch should be abandoned, returning ctx.Err(), as soon as ctx
+	// is done.
+	Fetch(ctx context.Context, url string) (body string, urls []string, err error)
+}
+
+// Response is what a ResponseFetcher tells of a page, more than Fetch
+// does.
+type Response struct {
+	URL        string // where the page was found in the end, after redirects
+	StatusCode int    // of the final response, zero when not over HTTP
+	Body       string
+	Links      []string
+
+	// Redirects are the hops followed to get to URL, in order
+	Redirects []Redirect
+
+	// Header is the header of the final response, and ContentLength
+	//   what its Content-Length said, -1 when it said nothing. Both are
+	//   only there over HTTP
+	Header        http.Header
+	ContentLength int64
+
+	// RemoteAddr is the address of the server, IP and port, TLSVersion
+	//   the version of TLS spoken, such as "TLS 1.3", empty over plain
+	//   HTTP, and Protocol the one of HTTP, such as "HTTP/2.0". Timings
+	//   break the fetch down
+	RemoteAddr string
+	TLSVersion string
+	Protocol   string
+	Timings    Timings
+
+	// NotModified is set when the server said the page did not change
+	//   since it was last fetched, Body is empty then but for the pages
+	//   a PageCacheMiddleware answers from its cache
+	NotModified bool
+
+	// NoIndex and NoFollow are the robots directives of the page, see
+	//   Directives. NoFollowLinks are the links robots are not to
+	//   follow, left out of Links: those marked rel="nofollow", or every
+	//   one of them on a nofollow page
+	NoIndex, NoFollow bool
+	NoFollowLinks     []string
+
+	// BodySize is the size of the body downloaded, in Body or not and
+	//   once decompressed, and Truncated is set when it was cut there for
+	//   being too long, see HTTPFetcher.MaxBodyBytes. WireSize is its
+	//   size as it came over the wire, compressed or not
+	BodySize  int64
+	WireSize  int64
+	Truncated bool
+
+	// Charset is the one the body was in, such as "shift_jis" or
+	//   "windows-1252", before it was transcoded to UTF-8. Empty when the
+	//   body isn't text or the Fetcher doesn't tell
+	Charset string
+
+	// Skipped tells why the page was not downloaded, such as "content
+	//   type video/mp4", empty when it was. See ContentFilter
+	Skipped string
+
+	// Screenshot is the file a screenshot of the page was saved to, by
+	//   the Fetchers rendering pages, as the one of package browser
+	Screenshot string
+
+	// Accessibility are the accessibility issues of the page, for the
+	//   Fetchers auditing the pages they render, as the one of package
+	//   browser with axe-core. Nil when it was not audited
+	Accessibility []AccessibilityIssue
+}
+
+// Redirect is one hop of a redirect chain.
+type Redirect struct {
+	From, To   string
+	StatusCode int // 301, 302, 307, 308...
+}
+
+// ResponseFetcher is implemented by Fetchers that can tell more of a page
+// than its body and links. The Crawler uses FetchResponse rather than
+// Fetch when it is there.
+type ResponseFetcher interface {
+	Fetcher
+
+	// FetchResponse is Fetch returning a Response. On error, it may
+	//   return a Response telling as much as is known, such as the
+	//   redirects that led to a 404, or nil
+	FetchResponse(ctx context.Context, url string) (*Response, error)
+}
+
+// FetchResponse fetches url with f, with its FetchResponse method when it
+// is a ResponseFetcher, else with Fetch. The Response is never nil.
+func FetchResponse(ctx context.Context, f Fetcher, url string) (*Response, error) {
+	if rf, ok := f.(ResponseFetcher); ok {
+		resp, err := rf.FetchResponse(ctx, url)
+		if resp == nil {
+			resp = &Response{URL: url}
+		}
+		return resp, err
+	}
+	body, links, err := f.Fetch(ctx, url)
+	return &Response{URL: url, Body: body, Links: links}, err
+}
+
+// Checker is implemented by Fetchers that can tell whether a URL works
+// more cheaply than by fetching it, such as with a HEAD request. The
+// Crawler checks the links leaving its scope this way when
+// Crawler.CheckExternal is set.
+type Checker interface {
+	// Check returns nil when url can be fetched, or the error Fetch
+	//   would return
+	Check(ctx context.Context, url string) error
+}
+
+// Check checks url with f, with its Check method when it is a Checker,
+// else by fetching it.
+func Check(ctx context.Context, f Fetcher, url string) error {
+	if c, ok := f.(Checker); ok {
+		return c.Check(ctx, url)
+	}
+	_, _, err := f.Fetch(ctx, url)
+	return err
+}
+
+// FakeFetcher is Fetcher that returns canned results, keyed by URL.
+// It is handy for trying out the crawler without touching the network.
+type FakeFetcher map[string]*FakeResult
+
+// FakeResult is the canned answer of a FakeFetcher for one URL
+type FakeResult struct {
+	Body string
+	URLs []string
+}
+
+// Fetch implements Fetcher, URLs missing from f are reported as
+// ErrNotFound.
+func (f FakeFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	if res, ok := f[url]; ok {
+		return res.Body, res.URLs, nil
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrNotFound, url)
+}
@@ -0,0 +1,183 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIndex is the file a FileFetcher serves for a directory when its
+// Index is not set.
+const DefaultIndex = "index.html"
+
+// FileFetcher fetches file:// URLs from the local file system, such as
+// the output of a static site generator, to check the site before it is
+// deployed without running a web server:
+//
+//	c := webcrawl.NewCrawler(
+//		webcrawl.WithSchemeFetcher("file", &webcrawl.FileFetcher{Root: "public"}),
+//	)
+//	err := c.Run(ctx, "file:///")
+//
+// The links of HTML files are extracted, those of other files are not. A
+// URL of a directory gets its Index file, or when it has none a page
+// linking to its entries. A missing file fails with an error classified
+// as ErrNotFound.
+type FileFetcher struct {
+	// Root is the directory the paths of the URLs start from, as for a
+	//   web server, so that the links relative to the root of the site,
+	//   such as /about/, work. When empty the paths are the file system's
+	Root string
+
+	// Index is the file of a directory, DefaultIndex when empty
+	Index string
+
+	// Links finds the links of HTML files, when nil the <a href> links
+	//   with the file, http or https scheme are
+	Links *LinkExtractor
+}
+
+// fileExtractor is what FileFetcher uses when it has no Links
+var fileExtractor = &LinkExtractor{Tags: AnchorTags, Schemes: []string{"file", "http", "https"}}
+
+// Fetch implements Fetcher.
+func (f *FileFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements ResponseFetcher. The URL of the Response ends
+// in a slash for a directory, the URL of which may not.
+func (f *FileFetcher) FetchResponse(ctx context.Context, rawURL string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("fetch %s: not a file URL", rawURL)
+	}
+	name := f.path(u.Path)
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+
+	out := &Response{URL: rawURL}
+	if fi.IsDir() {
+		// Like a web server, so that relative links resolve within the
+		//   directory
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+			out.URL = u.String()
+		}
+		index := f.Index
+		if index == "" {
+			index = DefaultIndex
+		}
+		if ifi, err := os.Stat(filepath.Join(name, index)); err == nil && !ifi.IsDir() {
+			name = filepath.Join(name, index)
+		} else {
+			body, err := dirListing(name)
+			if err != nil {
+				return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+			}
+			return f.withLinks(out, u, body)
+		}
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	defer file.Close()
+	// The charset is up to the file, not to its extension
+	ct := mime.TypeByExtension(filepath.Ext(name))
+	if t, _, err := mime.ParseMediaType(ct); err == nil {
+		ct = t
+	}
+	text, cs := toUTF8(file, ct)
+	b, err := io.ReadAll(text)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	out.Charset = cs
+	if !isHTML(ct) {
+		out.Body = string(b)
+		return out, nil
+	}
+	return f.withLinks(out, u, string(b))
+}
+
+// withLinks sets the body of out to the HTML page body, found at u, and
+// its links
+func (f *FileFetcher) withLinks(out *Response, u *url.URL, body string) (*Response, error) {
+	links := f.Links
+	if links == nil {
+		links = fileExtractor
+	}
+	if err := pageLinks(out, links, u, strings.NewReader(body), ""); err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", out.URL, err)
+	}
+	out.Body = body
+	return out, nil
+}
+
+// Check implements Checker, it only looks whether the file is there.
+func (f *FileFetcher) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(f.path(u.Path)); err != nil {
+		return fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// path returns the file name of the URL path p
+func (f *FileFetcher) path(p string) string {
+	if f.Root == "" {
+		return filepath.FromSlash(p)
+	}
+	// Cleaned as rooted first, so that .. can't get out of Root
+	return filepath.Join(f.Root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// dirListing returns an HTML page linking to the entries of the directory
+// name, the directories with a trailing slash
+func dirListing(name string) (string, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<ul>\n")
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || e.Type()&fs.ModeSymlink != 0 && isDir(filepath.Join(name, n)) {
+			n += "/"
+		}
+		ref := (&url.URL{Path: n}).String()
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(ref), html.EscapeString(n))
+	}
+	b.WriteString("</ul>\n")
+	return b.String(), nil
+}
+
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,119 @@
+package webcrawl
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Filter decides which of the URLs found are queued, see Crawler.Filter.
+// The built-in ones match the URL with a regexp, a glob, its extension or
+// its depth, and AllOf, AnyOf and NoneOf put them together:
+//
+//	webcrawl.AllOf(
+//		webcrawl.AnyOf(webcrawl.GlobFilter("/blog/**"), webcrawl.GlobFilter("/news/**")),
+//		webcrawl.NoneOf(webcrawl.ExtensionFilter("jpg", "png", "zip")),
+//	)
+//
+// The Crawler calls a Filter from its workers, for the links of the pages
+// they fetched, several at a time: it must be safe for concurrent use, as
+// the built-in ones are.
+type Filter interface {
+	// Allow reports whether the URL of it, which is normalized, may be
+	//   queued
+	Allow(it FrontierItem) bool
+}
+
+// FilterFunc is a Filter out of a function.
+type FilterFunc func(it FrontierItem) bool
+
+// Allow implements Filter.
+func (f FilterFunc) Allow(it FrontierItem) bool { return f(it) }
+
+// AllOf allows the URLs every one of filters allows, all of them when
+// there are none.
+func AllOf(filters ...Filter) Filter {
+	return FilterFunc(func(it FrontierItem) bool {
+		for _, f := range filters {
+			if !f.Allow(it) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// AnyOf allows the URLs one of filters allows, none when there are none.
+func AnyOf(filters ...Filter) Filter {
+	return FilterFunc(func(it FrontierItem) bool {
+		for _, f := range filters {
+			if f.Allow(it) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// NoneOf allows the URLs none of filters allows.
+func NoneOf(filters ...Filter) Filter {
+	return FilterFunc(func(it FrontierItem) bool {
+		return !AnyOf(filters...).Allow(it)
+	})
+}
+
+// RegexFilter allows the URLs re matches, anywhere in them unless it is
+// anchored.
+func RegexFilter(re *regexp.Regexp) Filter {
+	return FilterFunc(func(it FrontierItem) bool { return re.MatchString(it.URL) })
+}
+
+// GlobFilter allows the URLs pattern matches, see Glob: a pattern starting
+// with / matches their path, any other their host and path, as
+// example.com/blog/** or *.example.com/**.
+func GlobFilter(pattern string) Filter {
+	re := Glob(pattern)
+	withHost := !strings.HasPrefix(pattern, "/")
+	return FilterFunc(func(it FrontierItem) bool {
+		u, err := url.Parse(it.URL)
+		if err != nil {
+			return false
+		}
+		p := u.EscapedPath()
+		if p == "" {
+			p = "/"
+		}
+		if withHost {
+			p = u.Host + p
+		}
+		return re.MatchString(p)
+	})
+}
+
+// ExtensionFilter allows the URLs whose path ends in one of exts, such as
+// "html" or ".pdf", whatever the case. An empty one stands for the paths
+// with no extension, as / and /about.
+func ExtensionFilter(exts ...string) Filter {
+	set := make(map[string]bool, len(exts))
+	for _, e := range exts {
+		set[strings.ToLower(strings.TrimPrefix(e, "."))] = true
+	}
+	return FilterFunc(func(it FrontierItem) bool {
+		u, err := url.Parse(it.URL)
+		if err != nil {
+			return false
+		}
+		return set[strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))]
+	})
+}
+
+// DepthFilter allows the URLs found at depths from "from" to "to", a
+// negative "to" being no limit. Meant to be put together with the others,
+// as a glob only applying from some depth on, Crawler.MaxDepth being the
+// limit of the whole crawl.
+func DepthFilter(from, to int) Filter {
+	return FilterFunc(func(it FrontierItem) bool {
+		return it.Depth >= from && (to < 0 || it.Depth <= to)
+	})
+}
@@ -0,0 +1,174 @@
+package webcrawl
+
+import (
+	"container/heap"
+)
+
+// FrontierItem is a URL waiting in the frontier for its turn to be
+// fetched.
+type FrontierItem struct {
+	URL   string
+	Depth int // how many links away from the seed, the seed being 0
+
+	// Seed is the seed of RunSeeds the URL descends from, whose Scope it
+	//   is held to, empty for the first one
+	Seed string `json:",omitempty"`
+
+	// Sitemap is the sitemap entry the URL came from, nil when it was
+	//   found as a link or given as the seed
+	Sitemap *SitemapURL `json:",omitempty"`
+
+	// External is set on the links leaving the scope that are only
+	//   checked, see Crawler.CheckExternal
+	External bool `json:",omitempty"`
+
+	// Asset is set on the assets of the pages, see Crawler.FetchAssets
+	Asset bool `json:",omitempty"`
+
+	// Feed is set on the feeds the pages announce, see
+	//   Crawler.FollowFeeds
+	Feed bool `json:",omitempty"`
+
+	// Document is set on the links to documents, see
+	//   Crawler.CheckDocuments
+	Document bool `json:",omitempty"`
+}
+
+// Frontier holds the URLs the crawler is yet to fetch and decides in which
+// order they come out. The Crawler only calls it from a single goroutine.
+type Frontier interface {
+	// Push adds an item, an URL is only pushed once per crawl but when
+	//   found again by a shorter path, at a lower Depth. A Frontier may
+	//   drop those, the page is then crawled at the Depth it had
+	Push(FrontierItem)
+	// Pop removes and returns the next item to fetch, ok is false when
+	//   the frontier is empty
+	Pop() (item FrontierItem, ok bool)
+	// Len returns the number of items waiting
+	Len() int
+}
+
+// LeasingFrontier is a Frontier that hands its items out on lease, such as
+// one shared by several crawlers that has to know which items are being
+// fetched still. The Crawler calls Done once it is done with an item it
+// popped, the links of the page pushed. The items it pushes back instead,
+// to be fetched later, it doesn't.
+type LeasingFrontier interface {
+	Frontier
+	Done(FrontierItem)
+}
+
+// QueueFrontier is a first-in first-out Frontier, which makes for a
+// breadth-first crawl: every page of a level is fetched before the next
+// level is started, the Crawler waiting for the slowest pages of a level
+// to be done before handing out the next. Its zero value is an empty
+// queue.
+type QueueFrontier struct {
+	items []FrontierItem
+	head  int // items before head were popped already
+}
+
+// NewBFSFrontier returns an empty QueueFrontier.
+func NewBFSFrontier() *QueueFrontier {
+	return &QueueFrontier{}
+}
+
+// Push implements Frontier.
+func (q *QueueFrontier) Push(it FrontierItem) {
+	q.items = append(q.items, it)
+}
+
+// Pop implements Frontier.
+func (q *QueueFrontier) Pop() (FrontierItem, bool) {
+	if q.head == len(q.items) {
+		return FrontierItem{}, false
+	}
+	it := q.items[q.head]
+	q.items[q.head] = FrontierItem{}
+	q.head++
+	// Reclaim the popped part once it outweighs what is left
+	if q.head > 64 && q.head*2 >= len(q.items) {
+		q.items = append([]FrontierItem(nil), q.items[q.head:]...)
+		q.head = 0
+	}
+	return it, true
+}
+
+// Len implements Frontier.
+func (q *QueueFrontier) Len() int {
+	return len(q.items) - q.head
+}
+
+// PriorityFrontier is a Frontier that hands out the item with the highest
+// score first, items that score the same come out in the order they went
+// in.
+type PriorityFrontier struct {
+	score func(FrontierItem) float64
+	h     priorityHeap
+	seq   uint64
+}
+
+// NewPriorityFrontier returns an empty PriorityFrontier ranking items with
+// score, higher is sooner.
+func NewPriorityFrontier(score func(FrontierItem) float64) *PriorityFrontier {
+	return &PriorityFrontier{score: score}
+}
+
+// Push implements Frontier.
+func (p *PriorityFrontier) Push(it FrontierItem) {
+	p.seq++
+	heap.Push(&p.h, scoredItem{it, p.score(it), p.seq})
+}
+
+// Pop implements Frontier.
+func (p *PriorityFrontier) Pop() (FrontierItem, bool) {
+	if len(p.h) == 0 {
+		return FrontierItem{}, false
+	}
+	return heap.Pop(&p.h).(scoredItem).FrontierItem, true
+}
+
+// Len implements Frontier.
+func (p *PriorityFrontier) Len() int {
+	return len(p.h)
+}
+
+// ScoreByDepth is a PriorityFrontier score favouring the shallowest URLs.
+func ScoreByDepth(it FrontierItem) float64 {
+	return -float64(it.Depth)
+}
+
+// ScoreBySitemapPriority is a PriorityFrontier score favouring the URLs
+// their sitemap gives the highest priority. URLs that are not in a sitemap
+// get the protocol's default priority of 0.5.
+func ScoreBySitemapPriority(it FrontierItem) float64 {
+	if it.Sitemap == nil {
+		return 0.5
+	}
+	return it.Sitemap.Priority
+}
+
+type scoredItem struct {
+	FrontierItem
+	score float64
+	seq   uint64 // to keep equal scores first-in first-out
+}
+
+// priorityHeap implements heap.Interface, the best item on top
+type priorityHeap []scoredItem
+
+func (h priorityHeap) Len() int { return len(h) }
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	return h[i].seq < h[j].seq
+}
+func (h priorityHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *priorityHeap) Push(x any)   { *h = append(*h, x.(scoredItem)) }
+func (h *priorityHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,98 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// The reasons URLGuards reject a URL for
+const (
+	GuardURLLength   = "url too long"              // more characters than MaxLength
+	GuardQueryParams = "too many query parameters" // more parameters than MaxQueryParams
+)
+
+// URLGuards reject the URLs no crawl wants before they get to the
+// frontier: those too long to be anything but generated, and those with a
+// query string piling up parameters. A zero field turns its guard off.
+//
+// The Crawler hands every new URL to Rejected once it is normalized, the
+// seed excepted, and counts the URLs rejected there. URLGuards are safe
+// for concurrent use.
+type URLGuards struct {
+	// MaxLength is how many characters a URL can have, normalized
+	MaxLength int
+
+	// MaxQueryParams is how many parameters the query of a URL can have,
+	//   ?a=1&b=2&b=3 having 3
+	MaxQueryParams int
+
+	mu       sync.Mutex
+	rejected map[string]int // reason => URLs rejected for it
+}
+
+// Rejected reports why u, a normalized URL, is rejected, "" when it
+// isn't, and counts it. It is to be called once per URL.
+func (g *URLGuards) Rejected(u string) string {
+	reason := ""
+	switch {
+	case g.MaxLength > 0 && len(u) > g.MaxLength:
+		reason = GuardURLLength
+	case g.MaxQueryParams > 0 && queryParams(u) > g.MaxQueryParams:
+		reason = GuardQueryParams
+	default:
+		return ""
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.rejected == nil {
+		g.rejected = make(map[string]int)
+	}
+	g.rejected[reason]++
+	return reason
+}
+
+// queryParams returns how many parameters the query of rawURL has
+func queryParams(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, p := range strings.Split(u.RawQuery, "&") {
+		if p != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Counts returns how many URLs were rejected so far, by reason.
+func (g *URLGuards) Counts() map[string]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	counts := make(map[string]int, len(g.rejected))
+	for k, n := range g.rejected {
+		counts[k] = n
+	}
+	return counts
+}
+
+// WriteText writes how many URLs each guard rejected to w, one line per
+// guard that rejected any:
+//
+//	1284	too many query parameters
+func (g *URLGuards) WriteText(w io.Writer) error {
+	counts := g.Counts()
+	for _, reason := range []string{GuardURLLength, GuardQueryParams} {
+		if counts[reason] == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%d\t%s\n", counts[reason], reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package webcrawl
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HARLog collects the requests of a crawl and writes them out as an HTTP
+// Archive, HAR 1.2, which browser devtools and HAR viewers load. Every
+// result is an entry, the redirects that led to it one more each, and the
+// fetches that got no response at all an entry with status 0 and the
+// error in _error.
+//
+// The headers, timings and sizes are there as far as the Fetcher told
+// them, which HTTPFetcher does. The request headers are not.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance, and
+// write it with WriteHAR once the crawl is over. A HARLog is safe for
+// concurrent use, its zero value is ready to use.
+type HARLog struct {
+	// Bodies has the bodies of the pages in the archive too, which makes
+	//   it as large as the site
+	Bodies bool
+
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// The HAR 1.2 format, as far as a crawl fills it in
+type (
+	harEntry struct {
+		Started  time.Time   `json:"startedDateTime"`
+		Time     float64     `json:"time"`
+		Request  harRequest  `json:"request"`
+		Response harResponse `json:"response"`
+		Cache    struct{}    `json:"cache"`
+		Timings  harTimings  `json:"timings"`
+		ServerIP string      `json:"serverIPAddress,omitempty"`
+		Error    string      `json:"_error,omitempty"`
+	}
+	harRequest struct {
+		Method      string  `json:"method"`
+		URL         string  `json:"url"`
+		HTTPVersion string  `json:"httpVersion"`
+		Cookies     []harNV `json:"cookies"`
+		Headers     []harNV `json:"headers"`
+		QueryString []harNV `json:"queryString"`
+		HeadersSize int     `json:"headersSize"`
+		BodySize    int     `json:"bodySize"`
+	}
+	harResponse struct {
+		Status      int        `json:"status"`
+		StatusText  string     `json:"statusText"`
+		HTTPVersion string     `json:"httpVersion"`
+		Cookies     []harNV    `json:"cookies"`
+		Headers     []harNV    `json:"headers"`
+		Content     harContent `json:"content"`
+		RedirectURL string     `json:"redirectURL"`
+		HeadersSize int        `json:"headersSize"`
+		BodySize    int64      `json:"bodySize"`
+	}
+	harContent struct {
+		Size        int64  `json:"size"`
+		Compression int64  `json:"compression,omitempty"`
+		MimeType    string `json:"mimeType"`
+		Text        string `json:"text,omitempty"`
+	}
+	harTimings struct {
+		Blocked float64 `json:"blocked"`
+		DNS     float64 `json:"dns"`
+		Connect float64 `json:"connect"`
+		SSL     float64 `json:"ssl"`
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+	harNV struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+)
+
+// Add records the requests of a fetch.
+func (h *HARLog) Add(res CrawlResult) {
+	if res.FetchedAt.IsZero() {
+		// Never sent, such as a URL robots.txt disallows
+		return
+	}
+	var entries []harEntry
+	url := res.URL
+	for _, hop := range res.Redirects {
+		e := newHAREntry(res, hop.From)
+		e.Response.Status, e.Response.StatusText = hop.StatusCode, http.StatusText(hop.StatusCode)
+		e.Response.RedirectURL = hop.To
+		entries = append(entries, e)
+		url = hop.To
+	}
+
+	e := newHAREntry(res, url)
+	e.Response.Status = res.StatusCode
+	e.Response.StatusText = http.StatusText(res.StatusCode)
+	e.Response.Headers = harHeaders(res.Header)
+	e.Response.Content.MimeType = res.Header.Get("Content-Type")
+	e.Response.Content.Size = max(res.BodySize, int64(len(res.Body)))
+	e.Response.BodySize = -1
+	if res.WireSize > 0 {
+		e.Response.BodySize = res.WireSize
+		e.Response.Content.Compression = max(0, e.Response.Content.Size-res.WireSize)
+	}
+	if h.Bodies {
+		e.Response.Content.Text = res.Body
+	}
+	if host, _, err := net.SplitHostPort(res.RemoteAddr); err == nil {
+		e.ServerIP = host
+	}
+	if res.Err != nil && res.StatusCode == 0 {
+		e.Error = res.Err.Error()
+	}
+	t := res.Timings
+	if t.Total == 0 {
+		t.Total = res.Duration
+	}
+	e.Timings = harTimings{Blocked: -1, DNS: ms(t.DNS), Connect: ms(t.Connect + t.TLS), SSL: ms(t.TLS),
+		Wait: ms(t.TTFB), Receive: ms(max(0, t.Total-t.DNS-t.Connect-t.TLS-t.TTFB))}
+	if t.TTFB == 0 {
+		// Not traced, it was all waiting
+		e.Timings.Wait, e.Timings.Receive = ms(t.Total), 0
+	}
+	// The connect time includes the ssl time, time is the sum of the
+	//   others
+	e.Time = e.Timings.DNS + e.Timings.Connect + e.Timings.Send + e.Timings.Wait + e.Timings.Receive
+	entries = append(entries, e)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entries...)
+}
+
+// newHAREntry returns the entry of a request for url made by the fetch
+// res, with no response yet
+func newHAREntry(res CrawlResult, url string) harEntry {
+	method := http.MethodGet
+	if res.External {
+		// Checked, see Checker
+		method = http.MethodHead
+	}
+	e := harEntry{Started: res.FetchedAt, Timings: harTimings{Blocked: -1}}
+	e.Request = harRequest{Method: method, URL: url, Cookies: []harNV{}, Headers: []harNV{},
+		QueryString: []harNV{}, HeadersSize: -1}
+	if u, err := neturl.Parse(url); err == nil {
+		for name, values := range u.Query() {
+			for _, v := range values {
+				e.Request.QueryString = append(e.Request.QueryString, harNV{name, v})
+			}
+		}
+		sort.Slice(e.Request.QueryString, func(i, j int) bool {
+			return e.Request.QueryString[i].Name < e.Request.QueryString[j].Name
+		})
+	}
+	e.Response = harResponse{Cookies: []harNV{}, Headers: []harNV{}, HeadersSize: -1, BodySize: -1}
+	e.Request.HTTPVersion, e.Response.HTTPVersion = res.Protocol, res.Protocol
+	return e
+}
+
+// harHeaders returns h as HAR lists it, sorted by name
+func harHeaders(h http.Header) []harNV {
+	list := []harNV{}
+	for name, values := range h {
+		for _, v := range values {
+			list = append(list, harNV{name, v})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// WriteHAR writes the archive to w, the entries in the order their
+// requests started.
+func (h *HARLog) WriteHAR(w io.Writer) error {
+	h.mu.Lock()
+	entries := append([]harEntry(nil), h.entries...)
+	h.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Started.Before(entries[j].Started) })
+
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Creator struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"creator"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	har.Log.Version = "1.2"
+	har.Log.Creator.Name = DefaultUserAgent
+	har.Log.Entries = entries
+	if har.Log.Entries == nil {
+		har.Log.Entries = []harEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&har)
+}
@@ -0,0 +1,92 @@
+package webcrawl
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The severities of a HeaderAnomaly
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// MaxClockSkew is how far the Date header of a response may be off the
+// time it was fetched before ResponseHeaderAnomalyCheck flags it
+const MaxClockSkew = 60 * time.Second
+
+// HeaderAnomaly is a response header ResponseHeaderAnomalyCheck found
+// wrong, or missing, on a page.
+type HeaderAnomaly struct {
+	URL    string // the page
+	Header string // the name of the header, canonical
+	Value  string // its value, empty when it is missing
+	Reason string
+
+	Severity       string // SeverityHigh, SeverityMedium or SeverityLow
+	Recommendation string // what to do about it
+}
+
+// serverVersion matches a product with a version in a Server header, as
+// Apache/2.4.1 or nginx/1.25.3
+var serverVersion = regexp.MustCompile(`[A-Za-z][\w.-]*/\d+(\.\d+)*`)
+
+// ResponseHeaderAnomalyCheck looks for misconfigured servers in the
+// response headers of results: a Server header telling its version, an
+// X-Powered-By header, HTML pages without Content-Security-Policy or
+// X-Frame-Options, and a Date more than MaxClockSkew off FetchedAt. The
+// results without a response, those of the links only checked and of the
+// assets are left out. The anomalies come sorted by page and header.
+func ResponseHeaderAnomalyCheck(results []CrawlResult) []HeaderAnomaly {
+	var anomalies []HeaderAnomaly
+	for _, res := range results {
+		if res.Header == nil || res.External || res.Asset {
+			continue
+		}
+		page := pageURL(res)
+		flag := func(header, reason, severity, recommendation string) {
+			anomalies = append(anomalies, HeaderAnomaly{
+				URL: page, Header: header, Value: res.Header.Get(header), Reason: reason,
+				Severity: severity, Recommendation: recommendation,
+			})
+		}
+		if v := res.Header.Get("Server"); serverVersion.MatchString(v) {
+			flag("Server", "the server tells its version", SeverityLow,
+				"leave the version out of the Server header, as with ServerTokens Prod or server_tokens off")
+		}
+		if res.Header.Get("X-Powered-By") != "" {
+			flag("X-Powered-By", "the server tells what it runs on", SeverityLow,
+				"drop the X-Powered-By header")
+		}
+		if res.Err == nil && htmlResult(res) {
+			csp := res.Header.Get("Content-Security-Policy")
+			if csp == "" {
+				flag("Content-Security-Policy", "no Content-Security-Policy", SeverityMedium,
+					"set a Content-Security-Policy restricting where scripts and the rest load from")
+			}
+			if res.Header.Get("X-Frame-Options") == "" && !strings.Contains(csp, "frame-ancestors") {
+				flag("X-Frame-Options", "no X-Frame-Options, nor frame-ancestors", SeverityMedium,
+					"set X-Frame-Options: DENY or SAMEORIGIN, or a frame-ancestors directive, against clickjacking")
+			}
+		}
+		if v := res.Header.Get("Date"); v != "" && !res.FetchedAt.IsZero() {
+			if date, err := http.ParseTime(v); err == nil {
+				if skew := date.Sub(res.FetchedAt); skew > MaxClockSkew || skew < -MaxClockSkew {
+					flag("Date", "the clock of the server is "+skew.Round(time.Second).String()+" off", SeverityLow,
+						"keep the clock of the server in sync, with NTP, for caches to expire pages when they should")
+				}
+			}
+		}
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if anomalies[i].URL != anomalies[j].URL {
+			return anomalies[i].URL < anomalies[j].URL
+		}
+		return anomalies[i].Header < anomalies[j].Header
+	})
+	return anomalies
+}
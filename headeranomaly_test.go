@@ -0,0 +1,91 @@
+package webcrawl_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestResponseHeaderAnomalyCheck(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	html := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	header := func(kv ...string) http.Header {
+		h := html.Clone()
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+	tests := []struct {
+		name    string
+		res     webcrawl.CrawlResult
+		headers []string // the ones flagged, in order
+	}{
+		{"clean", webcrawl.CrawlResult{Header: header("Server", "nginx", "Content-Security-Policy", "default-src 'self'", "X-Frame-Options", "DENY")}, nil},
+		{"frame-ancestors", webcrawl.CrawlResult{Header: header("Content-Security-Policy", "frame-ancestors 'none'")}, nil},
+		{"bare", webcrawl.CrawlResult{Header: html}, []string{"Content-Security-Policy", "X-Frame-Options"}},
+		{"disclosing", webcrawl.CrawlResult{Header: header("Server", "Apache/2.4.1 (Unix)", "X-Powered-By", "PHP/8.1",
+			"Content-Security-Policy", "default-src 'self'", "X-Frame-Options", "DENY")}, []string{"Server", "X-Powered-By"}},
+		{"skewed", webcrawl.CrawlResult{FetchedAt: now, Header: header("Date", now.Add(-2*time.Minute).Format(http.TimeFormat),
+			"Content-Security-Policy", "frame-ancestors 'self'")}, []string{"Date"}},
+		{"in time", webcrawl.CrawlResult{FetchedAt: now, Header: header("Date", now.Add(30*time.Second).Format(http.TimeFormat),
+			"Content-Security-Policy", "frame-ancestors 'self'")}, nil},
+		// Not HTML, no policy is wanted
+		{"image", webcrawl.CrawlResult{Header: http.Header{"Content-Type": {"image/png"}}}, nil},
+		{"asset", webcrawl.CrawlResult{Asset: true, Header: header("X-Powered-By", "Express")}, nil},
+		{"no response", webcrawl.CrawlResult{}, nil},
+	}
+	for _, tt := range tests {
+		tt.res.URL = "https://site.test/" + tt.name
+		got := webcrawl.ResponseHeaderAnomalyCheck([]webcrawl.CrawlResult{tt.res})
+		var headers []string
+		for _, a := range got {
+			headers = append(headers, a.Header)
+			if a.URL != tt.res.URL || a.Reason == "" || a.Recommendation == "" || a.Severity == "" {
+				t.Errorf("%s: incomplete %+v", tt.name, a)
+			}
+			if a.Value != tt.res.Header.Get(a.Header) {
+				t.Errorf("%s: %s value %q, want %q", tt.name, a.Header, a.Value, tt.res.Header.Get(a.Header))
+			}
+		}
+		if len(headers) != len(tt.headers) {
+			t.Errorf("%s: flagged %v, want %v", tt.name, headers, tt.headers)
+			continue
+		}
+		for i := range headers {
+			if headers[i] != tt.headers[i] {
+				t.Errorf("%s: flagged %v, want %v", tt.name, headers, tt.headers)
+				break
+			}
+		}
+	}
+}
+
+func TestResponseHeaderAnomalyCrawl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Powered-By", "PHP/8.1")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>hello</p>"))
+	}))
+	defer srv.Close()
+	var results []webcrawl.CrawlResult
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(webcrawl.NewHTTPFetcher(time.Second)), webcrawl.WithoutRobots(),
+		webcrawl.WithOnResult(func(res webcrawl.CrawlResult) { results = append(results, res) }))
+	c.Sequential = true
+	if err := c.Crawl(srv.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	got := webcrawl.ResponseHeaderAnomalyCheck(results)
+	want := map[string]string{"X-Powered-By": webcrawl.SeverityLow, "Content-Security-Policy": webcrawl.SeverityMedium, "X-Frame-Options": webcrawl.SeverityMedium}
+	if len(got) != len(want) {
+		t.Fatalf("flagged %+v, want %v", got, want)
+	}
+	for _, a := range got {
+		if want[a.Header] != a.Severity {
+			t.Errorf("%s flagged %s, want %s", a.Header, a.Severity, want[a.Header])
+		}
+	}
+}
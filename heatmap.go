@@ -0,0 +1,79 @@
+package webcrawl
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DomainHeat is how a domain fared in a crawl, see DomainHeatMap.
+type DomainHeat struct {
+	Domain       string  // the hostname, lower case
+	FetchCount   int     // results of its URLs, failed or not
+	AvgLatencyMs float64 // the average Duration of those, in milliseconds
+	ErrorRate    float64 // the share of those that failed, 0 to 1
+}
+
+// DomainHeatMap breaks results down by domain, the ones fetched most
+// first, those fetched as much by name. Write it out with
+// WriteDomainHeatMap, for a spreadsheet to chart.
+func DomainHeatMap(results []CrawlResult) []DomainHeat {
+	type sums struct {
+		fetches, errors int
+		latency         float64
+	}
+	byDomain := make(map[string]*sums)
+	for _, res := range results {
+		domain := strings.ToLower(hostname(res.URL))
+		if domain == "" {
+			continue
+		}
+		s := byDomain[domain]
+		if s == nil {
+			s = &sums{}
+			byDomain[domain] = s
+		}
+		s.fetches++
+		s.latency += float64(res.Duration.Microseconds()) / 1000
+		if res.Err != nil {
+			s.errors++
+		}
+	}
+	heat := make([]DomainHeat, 0, len(byDomain))
+	for domain, s := range byDomain {
+		heat = append(heat, DomainHeat{
+			Domain:       domain,
+			FetchCount:   s.fetches,
+			AvgLatencyMs: s.latency / float64(s.fetches),
+			ErrorRate:    float64(s.errors) / float64(s.fetches),
+		})
+	}
+	sort.Slice(heat, func(i, j int) bool {
+		if heat[i].FetchCount != heat[j].FetchCount {
+			return heat[i].FetchCount > heat[j].FetchCount
+		}
+		return heat[i].Domain < heat[j].Domain
+	})
+	return heat
+}
+
+// WriteDomainHeatMap writes heatmap to w as CSV, with a header line:
+//
+//	domain,fetch_count,avg_latency_ms,error_rate
+//	example.com,120,84.512,0.025
+func WriteDomainHeatMap(heatmap []DomainHeat, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"domain", "fetch_count", "avg_latency_ms", "error_rate"})
+	for _, h := range heatmap {
+		cw.Write([]string{
+			h.Domain,
+			strconv.Itoa(h.FetchCount),
+			strconv.FormatFloat(h.AvgLatencyMs, 'f', 3, 64),
+			strconv.FormatFloat(h.ErrorRate, 'f', 3, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
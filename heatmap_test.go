@@ -0,0 +1,44 @@
+package webcrawl_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestDomainHeatMap(t *testing.T) {
+	fail := errors.New("failed")
+	results := []webcrawl.CrawlResult{
+		{URL: "https://b.test/", Duration: 10 * time.Millisecond},
+		{URL: "https://B.test:8443/x", Duration: 30 * time.Millisecond, Err: fail},
+		{URL: "https://a.test/", Duration: 5 * time.Millisecond},
+		{URL: "https://c.test/", Duration: 1 * time.Millisecond},
+		{URL: "https://c.test/y", Duration: 2 * time.Millisecond},
+		{URL: "https://c.test/z", Duration: 3 * time.Millisecond, Err: fail},
+	}
+	got := webcrawl.DomainHeatMap(results)
+	want := []webcrawl.DomainHeat{
+		{Domain: "c.test", FetchCount: 3, AvgLatencyMs: 2, ErrorRate: 1.0 / 3},
+		{Domain: "b.test", FetchCount: 2, AvgLatencyMs: 20, ErrorRate: 0.5},
+		{Domain: "a.test", FetchCount: 1, AvgLatencyMs: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DomainHeatMap = %+v, want %+v", got, want)
+	}
+
+	var b strings.Builder
+	if err := webcrawl.WriteDomainHeatMap(got, &b); err != nil {
+		t.Fatal(err)
+	}
+	csv := "domain,fetch_count,avg_latency_ms,error_rate\n" +
+		"c.test,3,2.000,0.333\n" +
+		"b.test,2,20.000,0.500\n" +
+		"a.test,1,5.000,0.000\n"
+	if b.String() != csv {
+		t.Errorf("WriteDomainHeatMap wrote\n%s\nwant\n%s", b.String(), csv)
+	}
+}
@@ -0,0 +1,72 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hooks are called at the stages of the crawl of every URL, for a program
+// to steer the crawl from the inside, see Crawler.Hooks. Any of them may be
+// nil. The context they get is the one of the Run, or of the page once it
+// is being fetched, carrying its span.
+type Hooks struct {
+	// OnEnqueue is called with every URL about to be queued, once the
+	//   Scope, the guards and the rest let it through, and returning false
+	//   drops it, for the whole Run. It is called from the dispatcher of
+	//   the crawl, which waits on it: it must be quick
+	OnEnqueue func(ctx context.Context, it FrontierItem) bool
+
+	// OnFetch is called in the worker right before the page of it is
+	//   fetched, robots.txt and the rate limits being through, and
+	//   returns the context to fetch it with: carrying headers, see
+	//   WithRequestHeader, or a deadline of its own. An error fails the
+	//   page with it without fetching it
+	OnFetch func(ctx context.Context, it FrontierItem) (context.Context, error)
+
+	// OnResult is called with the result of every page fetched, OnError
+	//   with those that failed, in the worker, before Crawler.OnResult and
+	//   Results. They may change it, the links followed being its Links
+	//   once they return, and returning false consumes it: Crawler.OnResult
+	//   and Results don't get it
+	OnResult func(ctx context.Context, res *CrawlResult) bool
+	OnError  func(ctx context.Context, res *CrawlResult) bool
+}
+
+// enqueueHook reports whether the OnEnqueue hook lets it be queued
+func (r *run) enqueueHook(it FrontierItem) bool {
+	if r.Hooks == nil || r.Hooks.OnEnqueue == nil {
+		return true
+	}
+	return r.Hooks.OnEnqueue(r.ctx, it)
+}
+
+// fetchHook returns the context to fetch it with, from the OnFetch hook
+func (r *run) fetchHook(ctx context.Context, it FrontierItem) (context.Context, error) {
+	if r.Hooks == nil || r.Hooks.OnFetch == nil {
+		return ctx, nil
+	}
+	hctx, err := r.Hooks.OnFetch(ctx, it)
+	if err != nil {
+		return ctx, fmt.Errorf("webcrawl: OnFetch: %w", err)
+	}
+	if hctx == nil {
+		hctx = ctx
+	}
+	return hctx, nil
+}
+
+// resultHook hands res to the OnResult or OnError hook, and reports whether
+// it is still to be handed over
+func (r *run) resultHook(ctx context.Context, res *CrawlResult) bool {
+	if r.Hooks == nil {
+		return true
+	}
+	hook := r.Hooks.OnResult
+	if res.Err != nil {
+		hook = r.Hooks.OnError
+	}
+	if hook == nil {
+		return true
+	}
+	return hook(ctx, res)
+}
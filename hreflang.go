@@ -0,0 +1,223 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// The inconsistencies a HreflangReport flags
+const (
+	HreflangInvalidCode     = "invalid language code" // a hreflang that is not a BCP 47 tag nor x-default
+	HreflangBrokenAlternate = "alternate not 200"     // an alternate answering with a redirect or an error
+	HreflangNoReturnLink    = "no return link"        // an alternate that doesn't link back to the page
+	HreflangNoSelf          = "no self reference"     // a page that isn't among its own alternates
+)
+
+// HreflangIssue is an inconsistency of the hreflang annotations of a
+// page.
+type HreflangIssue struct {
+	URL  string // the page
+	Kind string // HreflangInvalidCode and the like
+
+	// Lang and Alternate are the hreflang and URL of the alternate at
+	//   fault, both empty for HreflangNoSelf. Status is what the
+	//   alternate answered, for HreflangBrokenAlternate
+	Lang      string
+	Alternate string
+	Status    string
+}
+
+// HreflangReport validates the hreflang annotations of the pages of a
+// crawl, the <link rel="alternate" hreflang> of their <head>: that their
+// language codes are valid, that the alternates answer with a 200, and
+// that they annotate the page back, as search engines want. The alternates
+// the crawl didn't get to, out of its scope or only found in annotations,
+// are only checked for their code unless Fetch gets them.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. A
+// HreflangReport is safe for concurrent use, its zero value is ready to
+// use.
+type HreflangReport struct {
+	// Normalizer must be the Crawler's, for the alternates to be matched
+	//   with the pages. When nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	mu     sync.Mutex
+	pages  map[string]map[string]string // normalized page URL => hreflang => the alternate
+	status map[string]string            // normalized URL => see fetchStatus, a redirect its code
+}
+
+// Add records how a fetch went and, for an HTML page, its annotations.
+func (r *HreflangReport) Add(res CrawlResult) {
+	if res.External || res.Asset {
+		return
+	}
+	var alternates map[string]string
+	page := res.Err == nil && res.Body != "" && !res.Duplicate && htmlResult(res)
+	if page {
+		if base, doc, err := pageDoc(res); err == nil {
+			alternates = ExtractSEO(base, doc).Hreflang
+		} else {
+			page = false
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == nil {
+		r.status = make(map[string]string)
+		r.pages = make(map[string]map[string]string)
+	}
+	r.status[r.normalize(res.URL)] = fetchStatus(res)
+	for _, hop := range res.Redirects {
+		r.status[r.normalize(hop.From)] = strconv.Itoa(hop.StatusCode)
+	}
+	if page {
+		final := r.normalize(pageURL(res))
+		r.status[final] = fetchStatus(res)
+		r.pages[final] = alternates
+	}
+}
+
+// Fetch fetches with f the alternates the results added left out, and
+// theirs in turn, for them to be validated too. It is to be called once the
+// crawl is over, and goes without robots.txt nor rate limits: there is one
+// request per alternate. A failed fetch is an alternate not 200, Fetch only
+// returns the error of ctx.
+func (r *HreflangReport) Fetch(ctx context.Context, f Fetcher) error {
+	for {
+		var missing []string
+		r.mu.Lock()
+		for _, alternates := range r.pages {
+			for _, alt := range alternates {
+				if _, ok := r.status[r.normalize(alt)]; !ok {
+					missing = append(missing, alt)
+				}
+			}
+		}
+		r.mu.Unlock()
+		if len(missing) == 0 {
+			return nil
+		}
+		sort.Strings(missing)
+		for _, u := range missing {
+			if r.known(u) {
+				continue
+			}
+			resp, err := FetchResponse(ctx, f, u)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.Add(CrawlResult{URL: u, StatusCode: resp.StatusCode, Redirects: resp.Redirects, Header: resp.Header, Body: resp.Body, Err: err})
+			if !r.known(u) {
+				// Not even a status, don't go round in circles
+				r.mu.Lock()
+				r.status[r.normalize(u)] = fetchStatus(CrawlResult{Err: err})
+				r.mu.Unlock()
+			}
+		}
+	}
+}
+
+// known reports whether how fetching u went is recorded
+func (r *HreflangReport) known(u string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.status[r.normalize(u)]
+	return ok
+}
+
+func (r *HreflangReport) normalize(u string) string {
+	norm := r.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	if n, err := norm.Normalize(u); err == nil {
+		return n
+	}
+	return u
+}
+
+// Issues returns the inconsistencies of the pages recorded so far, sorted
+// by page, kind and hreflang.
+func (r *HreflangReport) Issues() []HreflangIssue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var issues []HreflangIssue
+	for page, alternates := range r.pages {
+		if len(alternates) == 0 {
+			continue
+		}
+		self := false
+		for lang, alt := range alternates {
+			flag := func(kind string) {
+				issues = append(issues, HreflangIssue{URL: page, Kind: kind, Lang: lang, Alternate: alt})
+			}
+			if _, err := language.Parse(lang); err != nil && lang != "x-default" {
+				flag(HreflangInvalidCode)
+			}
+			a := r.normalize(alt)
+			if a == page {
+				self = true
+				continue
+			}
+			if st, ok := r.status[a]; ok && st != "200" {
+				flag(HreflangBrokenAlternate)
+				issues[len(issues)-1].Status = st
+				continue
+			}
+			back, ok := r.pages[a]
+			if !ok {
+				continue
+			}
+			returned := false
+			for _, b := range back {
+				returned = returned || r.normalize(b) == page
+			}
+			if !returned {
+				flag(HreflangNoReturnLink)
+			}
+		}
+		if !self {
+			issues = append(issues, HreflangIssue{URL: page, Kind: HreflangNoSelf})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Lang < b.Lang
+	})
+	return issues
+}
+
+// WriteText writes the inconsistencies to w, one per line with the page,
+// the hreflang and the alternate, and what the alternate answered when
+// that is the issue:
+//
+//	alternate not 200	https://example.com/	fr	https://example.com/fr/	404
+func (r *HreflangReport) WriteText(w io.Writer) error {
+	for _, i := range r.Issues() {
+		line := i.Kind + "\t" + i.URL
+		if i.Alternate != "" {
+			line += "\t" + i.Lang + "\t" + i.Alternate
+		}
+		if i.Status != "" {
+			line += "\t" + i.Status
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
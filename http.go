@@ -0,0 +1,397 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DefaultTimeout is how long HTTPFetcher waits for a page when no
+// Timeout is configured
+const DefaultTimeout = 10 * time.Second
+
+// DefaultMaxRedirects is how many redirects in a row HTTPFetcher follows
+// when MaxRedirects is not set
+const DefaultMaxRedirects = 10
+
+// HTTPFetcher is a Fetcher that retrieves pages over HTTP(S) with
+// net/http, and returns the absolute URLs of the links on the page.
+type HTTPFetcher struct {
+	// Client is used to perform the requests, when nil
+	//   http.DefaultClient is used
+	Client *http.Client
+
+	// Timeout bounds each request, including reading the body.
+	//   Zero means DefaultTimeout, a negative value disables the timeout
+	//   (only the Client's own timeout, if any, applies then)
+	Timeout time.Duration
+
+	// TimeoutOverrides, when set, give the URLs they match a timeout of
+	//   their own in place of Timeout, the first matching one counting
+	TimeoutOverrides []TimeoutRule
+
+	// Links finds the links of HTML pages, when nil only <a href> links
+	//   are followed
+	Links *LinkExtractor
+
+	// MaxRedirects is how many redirects in a row are followed, past
+	//   that the fetch fails with ErrTooManyRedirects. Zero means
+	//   DefaultMaxRedirects, a negative value follows none: the redirect
+	//   response is then an error like any other 3xx. The CheckRedirect
+	//   of the Client is not used
+	MaxRedirects int
+
+	// Cache, when set, makes fetches of the pages it knows conditional.
+	//   A page that did not change comes back as a Response with
+	//   NotModified set, without a body but with the links it had
+	Cache *ConditionalCache
+
+	// Filter, when set, skips the responses it says not to download: the
+	//   fetch succeeds without a body, Response.Skipped telling why. With
+	//   HeadFirst a HEAD request gets the headers first, so that nothing
+	//   of those is downloaded, at the cost of one more request for the
+	//   others
+	Filter    *ContentFilter
+	HeadFirst bool
+
+	// MaxBodyBytes caps how much of a body is downloaded, zero means no
+	//   limit. A longer body is cut there, Response.Truncated set, the
+	//   links of an HTML page being those of the part downloaded
+	MaxBodyBytes int64
+
+	// Bandwidth, when set, caps the bytes per second the bodies are
+	//   downloaded at, see BandwidthLimiter
+	Bandwidth *BandwidthLimiter
+
+	// OnBody, when set, gets every body as it is downloaded, instead of
+	//   Response.Body which stays empty, so that large ones can go
+	//   straight to disk. It runs in a goroutine of its own while the
+	//   links are extracted, and needn't read the body to the end. An
+	//   error fails the fetch
+	OnBody func(url string, body io.Reader) error
+
+	// UserAgent is sent with every request, DefaultUserAgent when empty.
+	//   Set the Crawler's UserAgent too, for robots.txt
+	UserAgent string
+
+	// Header holds the headers sent with every request, such as
+	//   Accept-Language, and HostHeader the ones sent to each host, by
+	//   hostname, on top of or in place of those. A User-Agent there
+	//   wins over UserAgent, an Accept-Encoding over AcceptEncoding
+	Header     http.Header
+	HostHeader map[string]http.Header
+
+	// Auth, when set, logs in to the sites and adds credentials to the
+	//   requests, see Authenticator
+	Auth Authenticator
+	auth authState
+}
+
+// TimeoutRule is a timeout of its own for the URLs matching URLPattern,
+// see HTTPFetcher.TimeoutOverrides.
+type TimeoutRule struct {
+	// URLPattern is matched against the whole URL
+	URLPattern *regexp.Regexp
+
+	// Timeout is as HTTPFetcher.Timeout
+	Timeout time.Duration
+}
+
+// anchorExtractor is what HTTPFetcher uses when it has no Links
+var anchorExtractor = &LinkExtractor{Tags: AnchorTags}
+
+// NewHTTPFetcher returns an HTTPFetcher using its own http.Client with
+// the given per-request timeout.
+func NewHTTPFetcher(timeout time.Duration) *HTTPFetcher {
+	return &HTTPFetcher{Client: &http.Client{}, Timeout: timeout}
+}
+
+// Fetch implements Fetcher. Any response other than 2xx is reported as
+// an error, links are only extracted from HTML documents.
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements ResponseFetcher, it is Fetch also telling the
+// redirects followed and the status. Redirects that come back to a URL
+// they went through fail with ErrRedirectLoop.
+func (f *HTTPFetcher) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	if timeout := f.timeout(url); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	out := &Response{URL: url}
+	var header http.Header
+	cached, ok := f.Cache.get(url)
+	if ok {
+		header = make(http.Header)
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	gen, err := f.login(ctx, url, -1)
+	if err != nil {
+		return out, err
+	}
+	ctx, trace := traced(ctx)
+	defer trace.record(out)
+	if f.HeadFirst && f.Filter != nil {
+		if skip, err := f.head(ctx, url, out); skip || err != nil {
+			return out, err
+		}
+	}
+	resp, err := f.do(ctx, http.MethodGet, url, header, out)
+	if err == nil && f.Auth != nil && denied(resp) {
+		// Logged out, or never logged in the right way: once more
+		resp.Body.Close()
+		if _, err := f.login(ctx, url, gen); err != nil {
+			return out, err
+		}
+		out.Redirects = nil
+		resp, err = f.do(ctx, http.MethodGet, url, header, out)
+	}
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	responseMeta(out, resp)
+
+	if resp.StatusCode == http.StatusNotModified && ok {
+		out.NotModified, out.Links = true, cached.Links
+		return out, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return out, statusError(url, resp)
+	}
+	if out.Skipped = f.Filter.Skip(resp.Header); out.Skipped != "" {
+		return out, nil
+	}
+
+	d := HeaderDirectives(resp.Header, f.userAgent())
+	out.NoIndex, out.NoFollow = d.NoIndex, d.NoFollow
+	entry := CacheEntry{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	body, done := f.body(url, resp, out)
+	if isHTML(resp.Header.Get("Content-Type")) {
+		links := f.Links
+		if links == nil {
+			links = anchorExtractor
+		}
+		// Relative links are resolved against the URL we ended up at,
+		//   which differs from url when we followed redirects
+		err = pageLinks(out, links, resp.Request.URL, body, f.userAgent())
+	}
+	if err := done(err); err != nil {
+		return out, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	if !out.Truncated {
+		// Or the links of the part downloaded would stand for the page's
+		entry.Links = out.Links
+		f.Cache.put(url, entry)
+	}
+	return out, nil
+}
+
+// head asks for the headers of url and reports whether the Filter skips
+// the response, out then telling of it. Otherwise the GET is left to
+// tell, and follow the redirects again
+func (f *HTTPFetcher) head(ctx context.Context, url string, out *Response) (bool, error) {
+	resp, err := f.do(ctx, http.MethodHead, url, nil, out)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed, resp.StatusCode == http.StatusNotImplemented, denied(resp):
+		// No HEAD here, or logged out
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		responseMeta(out, resp)
+		return false, statusError(url, resp)
+	default:
+		if skipped := f.Filter.Skip(resp.Header); skipped != "" {
+			responseMeta(out, resp)
+			out.Skipped = skipped
+			return true, nil
+		}
+	}
+	out.Redirects = nil
+	return false, nil
+}
+
+// do sends a request for url with the headers of f and the extra header,
+// following redirects and recording them in out.Redirects
+func (f *HTTPFetcher) do(ctx context.Context, method, url string, header http.Header, out *Response) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent())
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
+	for k, v := range f.Header {
+		req.Header[k] = v
+	}
+	for k, v := range f.HostHeader[req.URL.Hostname()] {
+		req.Header[k] = v
+	}
+	for k, v := range RequestHeader(ctx) {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if f.Auth != nil {
+		f.Auth.Authorize(req)
+	}
+	// The traceparent of the span of the fetch, for the server to join
+	//   the trace
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	client := http.DefaultClient
+	if f.Client != nil {
+		client = f.Client
+	}
+	max := f.MaxRedirects
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+	// A copy of the client, for its CheckRedirect to see this fetch only
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if max < 0 {
+			return http.ErrUseLastResponse
+		}
+		to := req.URL.String()
+		out.Redirects = append(out.Redirects, Redirect{
+			From:       via[len(via)-1].URL.String(),
+			To:         to,
+			StatusCode: req.Response.StatusCode,
+		})
+		for _, r := range via {
+			if r.URL.String() == to {
+				return &RedirectError{URL: url, Redirects: out.Redirects, Err: ErrRedirectLoop}
+			}
+		}
+		if len(via) > max {
+			return &RedirectError{URL: url, Redirects: out.Redirects, Err: ErrTooManyRedirects}
+		}
+		return nil
+	}
+	return c.Do(req)
+}
+
+// timeout returns how long the fetch of url may take, by the first of
+// TimeoutOverrides it matches, or else Timeout, zero meaning no limit
+func (f *HTTPFetcher) timeout(url string) time.Duration {
+	timeout := f.Timeout
+	for _, rule := range f.TimeoutOverrides {
+		if rule.URLPattern.MatchString(url) {
+			timeout = rule.Timeout
+			break
+		}
+	}
+	if timeout == 0 {
+		return DefaultTimeout
+	}
+	return max(timeout, 0)
+}
+
+// userAgent returns the UserAgent sent
+func (f *HTTPFetcher) userAgent() string {
+	if f.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return f.UserAgent
+}
+
+// Check implements Checker with a HEAD request, falling back to Fetch
+// for servers that don't do HEAD.
+func (f *HTTPFetcher) Check(ctx context.Context, url string) error {
+	if timeout := f.timeout(url); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if _, err := f.login(ctx, url, -1); err != nil {
+		return err
+	}
+	resp, err := f.do(ctx, http.MethodHead, url, nil, &Response{})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed, resp.StatusCode == http.StatusNotImplemented:
+		_, _, err := f.Fetch(ctx, url)
+		return err
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return statusError(url, resp)
+	}
+	return nil
+}
+
+// RedirectError is returned by HTTPFetcher when it gives up on a chain of
+// redirects.
+type RedirectError struct {
+	URL       string
+	Redirects []Redirect // the hops up to the one given up on
+	Err       error      // ErrRedirectLoop or ErrTooManyRedirects
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("fetch %s: %v after %d redirects", e.URL, e.Err, len(e.Redirects))
+}
+
+func (e *RedirectError) Unwrap() error { return e.Err }
+
+// StatusError is returned by HTTPFetcher when the server answers with
+// anything but a 2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int    // e.g. 404
+	Status     string // e.g. "404 Not Found"
+
+	// RetryAfter is how long the server asked to wait before the next
+	//   request, by the Retry-After header of a 429 or 503, zero if it
+	//   didn't say
+	RetryAfter time.Duration
+}
+
+// statusError returns the StatusError of the answer to a request for url
+func statusError(url string, resp *http.Response) *StatusError {
+	err := &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	if throttling(resp.StatusCode) {
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return err
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetch %s: %s", e.URL, e.Status)
+}
+
+// isHTML reports whether a Content-Type header denotes an HTML document,
+// a missing header is given the benefit of the doubt
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
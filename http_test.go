@@ -0,0 +1,46 @@
+package webcrawl_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestTimeoutOverrides(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("<p>late</p>"))
+	}))
+	defer srv.Close()
+	f := webcrawl.NewHTTPFetcher(20 * time.Millisecond)
+	f.TimeoutOverrides = []webcrawl.TimeoutRule{
+		{URLPattern: regexp.MustCompile(`/report/`), Timeout: time.Second},
+		// Never reached for /report/, the rule above counting
+		{URLPattern: regexp.MustCompile(`/report/|/api/`), Timeout: 20 * time.Millisecond},
+		{URLPattern: regexp.MustCompile(`/unlimited/`), Timeout: -1},
+	}
+	ctx := context.Background()
+	for _, tt := range []struct {
+		path string
+		ok   bool
+	}{
+		{"/report/heavy", true},
+		{"/unlimited/page", true},
+		{"/api/heavy", false},
+		{"/page", false},
+	} {
+		_, err := f.FetchResponse(ctx, srv.URL+tt.path)
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("%s: %v, want the page", tt.path, err)
+		case !tt.ok && !errors.Is(webcrawl.Classify(err), webcrawl.ErrTimeout):
+			t.Errorf("%s: %v, want ErrTimeout", tt.path, err)
+		}
+	}
+}
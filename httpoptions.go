@@ -0,0 +1,198 @@
+package webcrawl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// HTTPOptions configure the http.Client of an HTTPFetcher made by
+// NewHTTPFetcherOptions. The zero value gives the same client as
+// NewHTTPFetcher.
+type HTTPOptions struct {
+	// Timeout caps every fetch as a whole, see HTTPFetcher.Timeout, and
+	//   TimeoutOverrides those of the URLs they match
+	Timeout          time.Duration
+	TimeoutOverrides []TimeoutRule
+
+	// ConnectTimeout caps the time to connect to the server, TLS
+	//   handshake included, and ReadTimeout the wait for the response
+	//   headers once the request is sent. Zero means no limit on top of
+	//   Timeout
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+
+	// CAFile is a PEM bundle of the certificate authorities trusted,
+	//   instead of the system's
+	CAFile string
+
+	// CertFile and KeyFile are the PEM certificate and key presented to
+	//   the servers that ask for a client certificate
+	CertFile, KeyFile string
+
+	// InsecureSkipVerify accepts any server certificate, meant for test
+	//   sites with a self-signed one
+	InsecureSkipVerify bool
+
+	// Proxy is the URL of the proxy every request goes through, http://,
+	//   https:// or socks5://. When empty the HTTP_PROXY, HTTPS_PROXY and
+	//   NO_PROXY environment variables are
+	Proxy string
+
+	// Proxies, when set, picks a proxy for every request in place of
+	//   Proxy, see ProxyPool
+	Proxies ProxyProvider
+
+	// UserAgent, Header and HostHeader are those of the HTTPFetcher
+	UserAgent  string
+	Header     http.Header
+	HostHeader map[string]http.Header
+
+	// Jar keeps the cookies the sites set and sends them back, nil means
+	//   cookies are ignored. See CookieJar to keep them between crawls
+	Jar http.CookieJar
+
+	// MaxConnsPerHost caps the connections open to each host, zero means
+	//   no limit
+	MaxConnsPerHost int
+
+	// DNS, when set, resolves the hosts instead of the system for every
+	//   connection, caching the answers, see DNSCache
+	DNS *DNSCache
+
+	// HTTP1 has the client speak HTTP/1.1 only, HTTP1Hosts with the hosts
+	//   listed, as the servers that mishandle HTTP/2. Otherwise it speaks
+	//   HTTP/2 with the servers that offer it over TLS
+	HTTP1      bool
+	HTTP1Hosts []string
+
+	// HTTP3 has the https:// requests go over HTTP/3 first, with the
+	//   http3 package of quic-go; a host it fails to fetch from is
+	//   fetched from over TCP from then on, as one that doesn't speak
+	//   HTTP/3 or behind a network that blocks UDP. It takes the TLS
+	//   options and ConnectTimeout; HTTP1, a Proxy or Proxies leave it out
+	HTTP3 bool
+}
+
+// NewHTTPFetcherOptions returns an HTTPFetcher with a client set up by o.
+// It fails when the proxy URL is invalid or a certificate file can't be
+// loaded.
+func NewHTTPFetcherOptions(o HTTPOptions) (*HTTPFetcher, error) {
+	t, err := o.Transport()
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = t
+	if o.Proxies != nil {
+		rt = NewProxyTransport(t, o.Proxies)
+	}
+	h3 := o.HTTP3 && !o.HTTP1 && o.Proxy == "" && o.Proxies == nil
+	if len(o.HTTP1Hosts) > 0 && !o.HTTP1 || h3 {
+		pt := &protocolTransport{tcp: rt, http1: rt}
+		if h3 {
+			pt.h3 = o.http3Transport(t)
+		}
+		if !o.HTTP1 && len(o.HTTP1Hosts) > 0 {
+			t1 := t.Clone()
+			disableHTTP2(t1)
+			pt.http1 = t1
+			if o.Proxies != nil {
+				pt.http1 = NewProxyTransport(t1, o.Proxies)
+			}
+		}
+		pt.http1Hosts = make(map[string]bool, len(o.HTTP1Hosts))
+		for _, h := range o.HTTP1Hosts {
+			pt.http1Hosts[h] = true
+		}
+		rt = pt
+	}
+	return &HTTPFetcher{
+		Client:           &http.Client{Transport: rt, Jar: o.Jar},
+		Timeout:          o.Timeout,
+		TimeoutOverrides: o.TimeoutOverrides,
+		UserAgent:        o.UserAgent,
+		Header:           o.Header,
+		HostHeader:       o.HostHeader,
+	}, nil
+}
+
+// http3Transport returns the HTTP/3 transport of o, with the TLS config of
+// t, its transport over TCP
+func (o HTTPOptions) http3Transport(t *http.Transport) *http3.Transport {
+	cfg := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		cfg = t.TLSClientConfig.Clone()
+		// http3 offers h3 alone
+		cfg.NextProtos = nil
+	}
+	qc := &quic.Config{}
+	if o.ConnectTimeout > 0 {
+		qc.HandshakeIdleTimeout = o.ConnectTimeout
+	}
+	return &http3.Transport{TLSClientConfig: cfg, QUICConfig: qc}
+}
+
+// Transport returns an http.Transport set up by o, for a client of your
+// own. It leaves Proxies, HTTP1Hosts and HTTP3 out, see NewProxyTransport.
+func (o HTTPOptions) Transport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if o.ConnectTimeout > 0 {
+		t.DialContext = (&net.Dialer{Timeout: o.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		t.TLSHandshakeTimeout = o.ConnectTimeout
+	}
+	if o.DNS != nil {
+		d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if o.ConnectTimeout > 0 {
+			d.Timeout = o.ConnectTimeout
+		}
+		t.DialContext = o.DNS.DialFunc(d)
+	}
+	t.ResponseHeaderTimeout = o.ReadTimeout
+	t.MaxConnsPerHost = o.MaxConnsPerHost
+	if o.HTTP1 {
+		disableHTTP2(t)
+	}
+
+	if o.Proxy != "" {
+		u, err := parseProxy(o.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if o.CAFile == "" && o.CertFile == "" && o.KeyFile == "" && !o.InsecureSkipVerify {
+		return t, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("webcrawl: CA bundle: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("webcrawl: CA bundle %s: no certificate found", o.CAFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("webcrawl: a client certificate needs both CertFile and KeyFile")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("webcrawl: client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = cfg
+	return t, nil
+}
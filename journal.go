@@ -0,0 +1,275 @@
+package webcrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Journal persists the state of a crawl to disk, so that a crawl that was
+// interrupted, by a crash or on purpose, can pick up where it left off with
+// Crawler.Resume.
+//
+// It is an append-only file of JSON lines, one per event: the crawl
+// starting, a URL entering the frontier, a URL being marked as visited
+// without being queued, a URL being done with, and a URL being dropped from
+// the frontier without being fetched. Replaying the events
+// rebuilds the visited set, the frontier and the status of every URL.
+// Each event is written out as it happens, Sync makes sure it reached the
+// disk.
+type Journal struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	state *JournalState // as of the last event
+}
+
+// JournalState is the state of a crawl as recorded by a Journal.
+type JournalState struct {
+	Seed    string    // the URL the crawl started from
+	Started time.Time // when it started
+
+	Visited []string       // every URL marked as visited, in order
+	Pending []FrontierItem // the URLs queued and not done with, in order
+
+	// Status tells how every URL done with went
+	Status map[string]URLStatus
+
+	pending map[string]int // URL => index in Pending
+}
+
+// URLStatus is how fetching a URL went.
+type URLStatus struct {
+	Depth int
+	Err   string `json:",omitempty"` // empty when the fetch succeeded
+	Cause string `json:",omitempty"` // what Classify made of the error
+}
+
+// journalEntry is one line of the journal file
+type journalEntry struct {
+	Op    string        `json:"op"`
+	URL   string        `json:"url,omitempty"`
+	Item  *FrontierItem `json:"item,omitempty"`
+	Depth int           `json:"depth,omitempty"`
+	Err   string        `json:"err,omitempty"`
+	Cause string        `json:"cause,omitempty"`
+	Time  *time.Time    `json:"time,omitempty"`
+}
+
+const (
+	opStart = "start"
+	opQueue = "queue"
+	opSeen  = "seen"
+	opDone  = "done"
+	opDrop  = "drop"
+)
+
+// OpenJournal opens the journal file at path, creating it when it does not
+// exist, and replays the events it already holds.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	state, err := replayJournal(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("journal %s: %w", path, err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Journal{f: f, w: bufio.NewWriter(f), state: state}, nil
+}
+
+// replayJournal reads every event of r, a torn last line (the process died
+// while writing it) is ignored
+func replayJournal(r io.Reader) (*JournalState, error) {
+	state := newJournalState()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var torn error
+	for sc.Scan() {
+		if torn != nil {
+			// Only the very last line may be torn
+			return nil, torn
+		}
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			torn = err
+			continue
+		}
+		state.apply(&e)
+	}
+	return state, sc.Err()
+}
+
+func newJournalState() *JournalState {
+	return &JournalState{Status: make(map[string]URLStatus), pending: make(map[string]int)}
+}
+
+// apply updates s with the event e
+func (s *JournalState) apply(e *journalEntry) {
+	switch e.Op {
+	case opStart:
+		*s = *newJournalState()
+		s.Seed = e.URL
+		if e.Time != nil {
+			s.Started = *e.Time
+		}
+	case opQueue:
+		// Queued again by a shorter path, see Crawler.MaxDepth
+		s.unqueue(e.Item.URL)
+		s.Visited = append(s.Visited, e.Item.URL)
+		s.pending[e.Item.URL] = len(s.Pending)
+		s.Pending = append(s.Pending, *e.Item)
+	case opSeen:
+		s.Visited = append(s.Visited, e.URL)
+	case opDone:
+		s.Status[e.URL] = URLStatus{Depth: e.Depth, Err: e.Err, Cause: e.Cause}
+		// Not when queued again at another depth since
+		if i, ok := s.pending[e.URL]; !ok || s.Pending[i].Depth == e.Depth {
+			s.unqueue(e.URL)
+		}
+	case opDrop:
+		s.unqueue(e.URL)
+	}
+}
+
+// unqueue removes url from Pending
+func (s *JournalState) unqueue(url string) {
+	if i, ok := s.pending[url]; ok {
+		// Leave a hole, compacted by pendingItems
+		s.Pending[i].URL = ""
+		delete(s.pending, url)
+	}
+}
+
+// pendingItems returns the queued URLs not done with, in order
+func (s *JournalState) pendingItems() []FrontierItem {
+	var items []FrontierItem
+	for _, it := range s.Pending {
+		if it.URL != "" {
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+// State returns a copy of the state recorded so far.
+func (j *Journal) State() *JournalState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := *j.state
+	s.Visited = append([]string(nil), s.Visited...)
+	s.Pending = s.pendingItems()
+	s.Status = make(map[string]URLStatus, len(j.state.Status))
+	for u, e := range j.state.Status {
+		s.Status[u] = e
+	}
+	s.pending = nil
+	return &s
+}
+
+// start empties the journal, recording a new crawl from seed
+func (j *Journal) start(seed string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	return j.write(&journalEntry{Op: opStart, URL: seed, Time: &now})
+}
+
+func (j *Journal) queued(it FrontierItem) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(&journalEntry{Op: opQueue, Item: &it})
+}
+
+func (j *Journal) seen(url string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(&journalEntry{Op: opSeen, URL: url})
+}
+
+func (j *Journal) done(url string, depth int, err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := &journalEntry{Op: opDone, URL: url, Depth: depth}
+	if err != nil {
+		e.Err, e.Cause = err.Error(), Classify(err).Error()
+	}
+	return j.write(e)
+}
+
+func (j *Journal) dropped(url string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(&journalEntry{Op: opDrop, URL: url})
+}
+
+// write appends e to the file and to the state, the caller holds j.mu
+func (j *Journal) write(e *journalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	j.state.apply(e)
+	b = append(b, '\n')
+	if _, err := j.w.Write(b); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+// Sync commits the journal to stable storage.
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Close syncs and closes the journal file.
+func (j *Journal) Close() error {
+	return errors.Join(j.Sync(), j.f.Close())
+}
+
+// recordedError is a failure read back from a journal, Classify files it
+// under the cause it had when it was recorded
+type recordedError struct {
+	msg   string
+	cause error
+}
+
+func (e *recordedError) Error() string { return e.msg }
+func (e *recordedError) Unwrap() error { return e.cause }
+
+// err rebuilds the error of a failed URL, nil when it succeeded
+func (st URLStatus) err() error {
+	if st.Err == "" {
+		return nil
+	}
+	for _, c := range causes {
+		if c.Error() == st.Cause {
+			return &recordedError{st.Err, c}
+		}
+	}
+	return &recordedError{st.Err, ErrFetchFailed}
+}
@@ -0,0 +1,289 @@
+package webcrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSONLFields are the fields a JSONLWriter knows, in the order it writes
+// them.
+var JSONLFields = []string{"url", "depth", "status", "headers", "content_length", "remote_addr",
+	"tls_version", "protocol", "timings", "not_modified", "fetched_at", "duration_ms", "error", "cause",
+	"content_hash", "duplicate_of", "canonical", "noindex", "soft_404", "skipped", "body_size", "wire_size",
+	"truncated", "body_cut", "asset", "feed", "document", "charset", "language", "screenshot", "structured_data", "fields", "matched", "word_count", "text", "links",
+	"nofollow_links", "assets", "feeds", "body"}
+
+// jsonlField returns the value of a field of r, ok is false when the field
+// is to be left out of the line
+var jsonlField = map[string]func(r *CrawlResult) (v any, ok bool){
+	"url":     func(r *CrawlResult) (any, bool) { return r.URL, true },
+	"depth":   func(r *CrawlResult) (any, bool) { return r.Depth, true },
+	"status":  func(r *CrawlResult) (any, bool) { return r.StatusCode, r.StatusCode != 0 },
+	"headers": func(r *CrawlResult) (any, bool) { return r.Header, r.Header != nil },
+	"content_length": func(r *CrawlResult) (any, bool) {
+		return r.ContentLength, r.Header != nil && r.ContentLength >= 0
+	},
+	"remote_addr": func(r *CrawlResult) (any, bool) { return r.RemoteAddr, r.RemoteAddr != "" },
+	"tls_version": func(r *CrawlResult) (any, bool) { return r.TLSVersion, r.TLSVersion != "" },
+	"protocol":    func(r *CrawlResult) (any, bool) { return r.Protocol, r.Protocol != "" },
+	"timings": func(r *CrawlResult) (any, bool) {
+		t := r.Timings
+		return map[string]float64{"dns_ms": ms(t.DNS), "connect_ms": ms(t.Connect), "tls_ms": ms(t.TLS),
+			"ttfb_ms": ms(t.TTFB), "total_ms": ms(t.Total)}, t.Total > 0
+	},
+	"not_modified": func(r *CrawlResult) (any, bool) { return true, r.NotModified },
+	"fetched_at": func(r *CrawlResult) (any, bool) {
+		return r.FetchedAt.UTC().Format(time.RFC3339Nano), !r.FetchedAt.IsZero()
+	},
+	"duration_ms": func(r *CrawlResult) (any, bool) {
+		return ms(r.Duration), !r.FetchedAt.IsZero()
+	},
+	"error": func(r *CrawlResult) (any, bool) {
+		if r.Err == nil {
+			return nil, false
+		}
+		return r.Err.Error(), true
+	},
+	"cause": func(r *CrawlResult) (any, bool) {
+		if r.Err == nil {
+			return nil, false
+		}
+		return Classify(r.Err).Error(), true
+	},
+	"content_hash": func(r *CrawlResult) (any, bool) { return r.ContentHash, r.ContentHash != "" },
+	"duplicate_of": func(r *CrawlResult) (any, bool) { return r.DuplicateOf, r.DuplicateOf != "" },
+	"canonical":    func(r *CrawlResult) (any, bool) { return r.Canonical, r.Canonical != "" },
+	"noindex":      func(r *CrawlResult) (any, bool) { return true, r.NoIndex },
+	"soft_404":     func(r *CrawlResult) (any, bool) { return true, r.Soft404 },
+	"skipped":      func(r *CrawlResult) (any, bool) { return r.Skipped, r.Skipped != "" },
+	"body_size":    func(r *CrawlResult) (any, bool) { return r.BodySize, r.BodySize > 0 },
+	"wire_size":    func(r *CrawlResult) (any, bool) { return r.WireSize, r.WireSize > 0 },
+	"truncated":    func(r *CrawlResult) (any, bool) { return true, r.Truncated },
+	"body_cut":     func(r *CrawlResult) (any, bool) { return true, r.BodyCut },
+	"asset":        func(r *CrawlResult) (any, bool) { return true, r.Asset },
+	"feed":         func(r *CrawlResult) (any, bool) { return true, r.Feed },
+	"document":     func(r *CrawlResult) (any, bool) { return true, r.Document },
+	"charset":      func(r *CrawlResult) (any, bool) { return r.Charset, r.Charset != "" },
+	"language":     func(r *CrawlResult) (any, bool) { return r.Language, r.Language != "" },
+	"screenshot":   func(r *CrawlResult) (any, bool) { return r.Screenshot, r.Screenshot != "" },
+	"structured_data": func(r *CrawlResult) (any, bool) {
+		return r.StructuredData, r.StructuredData != nil
+	},
+	"word_count": func(r *CrawlResult) (any, bool) { return r.WordCount, r.Text != "" },
+	"text":       func(r *CrawlResult) (any, bool) { return r.Text, r.Text != "" },
+	"fields":     func(r *CrawlResult) (any, bool) { return r.Fields, r.Fields != nil },
+	"matched":    func(r *CrawlResult) (any, bool) { return r.Matched, len(r.Matched) > 0 },
+	"links":      func(r *CrawlResult) (any, bool) { return r.Links, r.Err == nil },
+	"nofollow_links": func(r *CrawlResult) (any, bool) {
+		return r.NoFollowLinks, len(r.NoFollowLinks) > 0
+	},
+	"assets": func(r *CrawlResult) (any, bool) { return r.Assets, len(r.Assets) > 0 },
+	"feeds":  func(r *CrawlResult) (any, bool) { return r.Feeds, len(r.Feeds) > 0 },
+	"body":   func(r *CrawlResult) (any, bool) { return r.Body, r.Err == nil && (r.Body != "" || !r.BodyCut) },
+}
+
+// ms returns d in milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// JSONLWriter writes crawl results as JSON Lines, one object per result,
+// ready for jq or a bulk import:
+//
+//	{"url":"https://example.com/","depth":0,"fetched_at":"...","duration_ms":12.5,"links":[...],"body":"..."}
+//
+// Fields that don't apply to a result, such as error for a page that was
+// fetched fine, are left out. A JSONLWriter is safe for concurrent use, so
+// WriteResult can be called straight from Crawler.OnResult.
+type JSONLWriter struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	fields []string
+}
+
+// NewJSONLWriter returns a JSONLWriter writing the given fields of each
+// result to w, or all of JSONLFields when there are none. It fails on a
+// field it doesn't know.
+func NewJSONLWriter(w io.Writer, fields ...string) (*JSONLWriter, error) {
+	fields, err := jsonlFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLWriter{w: bufio.NewWriter(w), fields: fields}, nil
+}
+
+// jsonlFields returns fields, or JSONLFields when empty, failing on a
+// field it doesn't know
+func jsonlFields(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return JSONLFields, nil
+	}
+	for _, f := range fields {
+		if jsonlField[f] == nil {
+			return nil, fmt.Errorf("webcrawl: unknown JSONL field %q, want one of %s", f, strings.Join(JSONLFields, ", "))
+		}
+	}
+	return fields, nil
+}
+
+// MarshalResult returns r as a JSONLWriter writes it, without the
+// newline: an object of the given fields, or of all of JSONLFields when
+// there are none.
+func MarshalResult(r CrawlResult, fields ...string) ([]byte, error) {
+	fields, err := jsonlFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	return marshalResult(&r, fields)
+}
+
+func marshalResult(r *CrawlResult, fields []string) ([]byte, error) {
+	var line []byte
+	line = append(line, '{')
+	for _, f := range fields {
+		v, ok := jsonlField[f](r)
+		if !ok {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) > 1 {
+			line = append(line, ',')
+		}
+		line = append(line, '"')
+		line = append(line, f...)
+		line = append(line, '"', ':')
+		line = append(line, b...)
+	}
+	return append(line, '}'), nil
+}
+
+// WriteResult writes r as one line. Each line is flushed right away, so a
+// reader at the other end of a pipe gets it as soon as the page is done.
+func (w *JSONLWriter) WriteResult(r CrawlResult) error {
+	line, err := marshalResult(&r, w.fields)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.w.Write(line); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// JSONLReader reads back the results a JSONLWriter wrote, for reports to
+// be made of a crawl after the fact. The fields left out of the lines are
+// zero in the results, and so are timings, structured_data and fields; a
+// failed fetch has an Err with the message of the error field, that
+// Classify takes for its cause.
+type JSONLReader struct {
+	r *bufio.Reader
+}
+
+// NewJSONLReader returns a JSONLReader reading from r.
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	return &JSONLReader{r: bufio.NewReader(r)}
+}
+
+// jsonlLine is a line of a JSONLWriter, as far as JSONLReader reads it
+type jsonlLine struct {
+	URL           string      `json:"url"`
+	Depth         int         `json:"depth"`
+	Status        int         `json:"status"`
+	Headers       http.Header `json:"headers"`
+	ContentLength *int64      `json:"content_length"`
+	RemoteAddr    string      `json:"remote_addr"`
+	TLSVersion    string      `json:"tls_version"`
+	Protocol      string      `json:"protocol"`
+	NotModified   bool        `json:"not_modified"`
+	FetchedAt     time.Time   `json:"fetched_at"`
+	DurationMS    float64     `json:"duration_ms"`
+	Error         string      `json:"error"`
+	Cause         string      `json:"cause"`
+	ContentHash   string      `json:"content_hash"`
+	DuplicateOf   string      `json:"duplicate_of"`
+	Canonical     string      `json:"canonical"`
+	NoIndex       bool        `json:"noindex"`
+	Soft404       bool        `json:"soft_404"`
+	Skipped       string      `json:"skipped"`
+	BodySize      int64       `json:"body_size"`
+	WireSize      int64       `json:"wire_size"`
+	Truncated     bool        `json:"truncated"`
+	BodyCut       bool        `json:"body_cut"`
+	Asset         bool        `json:"asset"`
+	Feed          bool        `json:"feed"`
+	Document      bool        `json:"document"`
+	Charset       string      `json:"charset"`
+	Language      string      `json:"language"`
+	Screenshot    string      `json:"screenshot"`
+	WordCount     int         `json:"word_count"`
+	Text          string      `json:"text"`
+	Matched       []string    `json:"matched"`
+	Links         []string    `json:"links"`
+	NoFollowLinks []string    `json:"nofollow_links"`
+	Assets        []string    `json:"assets"`
+	Feeds         []string    `json:"feeds"`
+	Body          string      `json:"body"`
+}
+
+// jsonlError is the Err of a result read back: its message, wrapping the
+// cause it had
+type jsonlError struct {
+	msg   string
+	cause error
+}
+
+func (e *jsonlError) Error() string { return e.msg }
+func (e *jsonlError) Unwrap() error { return e.cause }
+
+// Next returns the next result, io.EOF once there are none. Blank lines
+// are skipped, a line that is not a JSON object is an error.
+func (r *JSONLReader) Next() (CrawlResult, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) == 0 {
+			if err != nil {
+				return CrawlResult{}, err
+			}
+			continue
+		}
+		var l jsonlLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return CrawlResult{}, fmt.Errorf("webcrawl: bad JSONL line: %w", err)
+		}
+		res := CrawlResult{URL: l.URL, Depth: l.Depth, StatusCode: l.Status, Header: l.Headers, ContentLength: -1,
+			RemoteAddr: l.RemoteAddr, TLSVersion: l.TLSVersion, Protocol: l.Protocol, NotModified: l.NotModified, FetchedAt: l.FetchedAt,
+			Duration:    time.Duration(l.DurationMS * float64(time.Millisecond)),
+			ContentHash: l.ContentHash, DuplicateOf: l.DuplicateOf, Canonical: l.Canonical, NoIndex: l.NoIndex,
+			Soft404: l.Soft404, Skipped: l.Skipped, BodySize: l.BodySize, WireSize: l.WireSize, Truncated: l.Truncated, BodyCut: l.BodyCut,
+			Asset: l.Asset, Feed: l.Feed, Document: l.Document, Charset: l.Charset, Language: l.Language, Screenshot: l.Screenshot,
+			WordCount: l.WordCount, Text: l.Text, Matched: l.Matched, Links: l.Links, NoFollowLinks: l.NoFollowLinks, Assets: l.Assets,
+			Feeds: l.Feeds, Body: l.Body}
+		if l.ContentLength != nil {
+			res.ContentLength = *l.ContentLength
+		}
+		res.Duplicate = l.DuplicateOf != ""
+		if l.Error != "" {
+			e := &jsonlError{msg: l.Error, cause: ErrFetchFailed}
+			for cause := range errorClasses {
+				if cause.Error() == l.Cause {
+					e.cause = cause
+				}
+			}
+			res.Err = e
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,220 @@
+package webcrawl
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/text/language"
+)
+
+// DetectLanguage returns the language of a page as a BCP 47 tag, such as
+// "en" or "pt-BR": the one its <html lang> says, or else its
+// Content-Language header or <meta http-equiv>, or else the one its text
+// looks like, "" when there is no telling. text is the text of the page,
+// see ReadableText, when empty header and doc are all there is.
+//
+// The text tells the language by its script, and for the languages
+// written with Latin letters by their commonest words: it knows English,
+// French, German, Spanish, Italian, Portuguese, Dutch, Swedish, Danish,
+// Norwegian, Finnish, Polish, Czech, Turkish and Indonesian.
+func DetectLanguage(header http.Header, doc *html.Node, text string) string {
+	if lang := declaredLanguage(doc); lang != "" {
+		return lang
+	}
+	if lang := languageTag(header.Get("Content-Language")); lang != "" {
+		return lang
+	}
+	if lang := languageTag(metaLanguage(doc)); lang != "" {
+		return lang
+	}
+	return TextLanguage(text)
+}
+
+// languageTag returns the canonical form of the first tag of v, a
+// Content-Language or lang attribute, "" when there is none
+func languageTag(v string) string {
+	v, _, _ = strings.Cut(v, ",")
+	t, err := language.Parse(strings.TrimSpace(v))
+	if err != nil || t == language.Und {
+		return ""
+	}
+	return t.String()
+}
+
+// declaredLanguage returns the lang, or xml:lang, of the root element of
+// doc
+func declaredLanguage(doc *html.Node) string {
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Html {
+			continue
+		}
+		for _, a := range n.Attr {
+			if a.Key == "lang" || a.Key == "xml:lang" {
+				if lang := languageTag(a.Val); lang != "" {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// metaLanguage returns the content of the <meta http-equiv=content-language>
+// of doc
+func metaLanguage(doc *html.Node) string {
+	var lang string
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+			if v, _ := attr(n, "http-equiv"); strings.EqualFold(v, "content-language") {
+				lang, _ = attr(n, "content")
+				return true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(doc)
+	return lang
+}
+
+// scriptLanguages tells the language of the texts written in a script
+// mostly used for one
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// stopWords are the commonest words of the languages written with Latin
+// letters, those that tell them apart the best
+var stopWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "with", "this", "are", "was", "you", "on", "be", "it"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "dans", "pour", "que", "qui", "sur", "pas", "du", "au", "avec"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "eine", "sich", "auf", "für", "auch", "dem", "zu"},
+	"es": {"el", "los", "las", "y", "que", "del", "en", "por", "una", "con", "para", "es", "se", "como", "más", "al"},
+	"it": {"il", "di", "che", "e", "della", "per", "non", "una", "sono", "gli", "con", "del", "nel", "alla", "anche", "è"},
+	"pt": {"o", "os", "que", "do", "da", "em", "um", "uma", "para", "com", "não", "dos", "das", "se", "ao", "é"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "ook", "maar", "er"},
+	"sv": {"och", "att", "det", "som", "en", "på", "är", "av", "för", "med", "till", "den", "inte", "har", "om", "ett"},
+	"da": {"og", "at", "det", "er", "en", "til", "på", "af", "med", "den", "for", "ikke", "som", "har", "de", "et"},
+	"no": {"og", "er", "det", "som", "på", "til", "en", "av", "med", "for", "ikke", "har", "de", "jeg", "et", "å"},
+	"fi": {"ja", "on", "ei", "se", "että", "hän", "oli", "ovat", "mutta", "kun", "myös", "tai", "jos", "sen", "ole", "vain"},
+	"pl": {"i", "w", "nie", "na", "się", "jest", "z", "do", "że", "to", "jak", "ale", "po", "tak", "czy", "od"},
+	"cs": {"a", "je", "se", "na", "v", "že", "to", "s", "z", "do", "jako", "ale", "by", "pro", "jsou", "není"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ile", "çok", "ne", "daha", "olarak", "gibi", "ama", "en", "değil", "kadar"},
+	"id": {"dan", "yang", "di", "ini", "itu", "dengan", "untuk", "dari", "tidak", "dalam", "akan", "pada", "juga", "ke", "ada", "adalah"},
+}
+
+// stopWordLanguages maps each stop word to its languages
+var stopWordLanguages = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopWords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// TextLanguage returns the language text looks like it is written in, as
+// a BCP 47 tag, "" when there is no telling: too short, or too many
+// languages alike. See DetectLanguage for those it knows.
+func TextLanguage(text string) string {
+	var letters int
+	scripts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if r < 0x80 {
+			continue
+		}
+		for i, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				scripts[i]++
+				break
+			}
+		}
+	}
+	if letters < 20 {
+		return ""
+	}
+	// Japanese mixes its kana with Han characters
+	kana := scripts[1] + scripts[2]
+	for i, s := range scriptLanguages {
+		n := scripts[i]
+		if s.lang == "ja" {
+			n = kana
+		}
+		if s.script == unicode.Han && kana > 0 {
+			continue
+		}
+		if n*3 > letters {
+			return s.lang
+		}
+	}
+
+	counts := make(map[string]int)
+	words := 0
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		words++
+		for _, lang := range stopWordLanguages[w] {
+			counts[lang]++
+		}
+	}
+	langs := make([]string, 0, len(counts))
+	for lang := range counts {
+		langs = append(langs, lang)
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sort.Slice(langs, func(i, j int) bool { return counts[langs[i]] > counts[langs[j]] })
+	best, second := counts[langs[0]], 0
+	if len(langs) > 1 {
+		second = counts[langs[1]]
+	}
+	// A text has plenty of stop words, one in twenty at the very least,
+	//   and its language has a fifth more of them than the next
+	if best < 3 || best*20 < words || best*5 < second*6 {
+		return ""
+	}
+	return langs[0]
+}
+
+// LanguageInScope reports whether a page in the language lang is in the
+// scope: Languages are empty, or have lang or its base language, "en"
+// taking "en-GB" in. A page whose language is unknown always is.
+func (s *ScopeRules) LanguageInScope(lang string) bool {
+	if len(s.Languages) == 0 || lang == "" {
+		return true
+	}
+	for _, l := range s.Languages {
+		if strings.EqualFold(l, lang) || len(lang) > len(l) && strings.EqualFold(lang[:len(l)], l) && lang[len(l)] == '-' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package webcrawl
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The kinds of the edges of a LinkGraph
+const (
+	EdgeLink     = "link"     // a link robots may follow
+	EdgeNoFollow = "nofollow" // a link robots are not to follow
+	EdgeRedirect = "redirect" // a redirect, the page being at its target
+)
+
+// LinkGraph records which page of a crawl links to which, as a directed
+// graph of URLs, and writes it out for Graphviz (WriteDOT) or for Gephi
+// and yEd (WriteGraphML) to draw the structure of the site. The URLs
+// linked to that the crawl didn't fetch, out of scope or too deep, are
+// nodes too, without a status. With Links set, the links of each page are
+// taken again for the edges to tell their anchor text, rel and region,
+// see Link.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance, and
+// write it once the crawl is over. A LinkGraph is safe for concurrent
+// use, its zero value is ready to use.
+type LinkGraph struct {
+	// Normalizer must be the Crawler's, for the URLs of the links to be
+	//   those of the pages. When nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	// Links, when set, takes the links from the HTML pages again, for the
+	//   context of each edge. Only those the Fetcher found are edges
+	Links *LinkExtractor
+
+	mu    sync.Mutex
+	nodes map[string]*graphNode // normalized URL => its node
+}
+
+// Edge is a link or redirect of a LinkGraph.
+type Edge struct {
+	Target string // normalized
+	Kind   string // EdgeLink, EdgeNoFollow or EdgeRedirect
+
+	// Text, Rel and Region are those of the first link of the page to
+	//   the target, see Link, when LinkGraph.Links is set
+	Text   string
+	Rel    string
+	Region string
+}
+
+// graphNode is a URL of the graph, with its edges
+type graphNode struct {
+	status string // see fetchStatus, the code of a redirect, empty when it wasn't fetched
+	depth  int
+	out    map[string]*Edge // target => the edge to it
+}
+
+// Add records the page of a fetch and its links. The assets of the pages,
+// see Crawler.FetchAssets, are left out.
+func (g *LinkGraph) Add(res CrawlResult) {
+	if res.Asset {
+		return
+	}
+	norm := g.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	normalize := func(u string) string {
+		if n, err := norm.Normalize(u); err == nil {
+			return n
+		}
+		return u
+	}
+	var found map[string]Link // normalized URL => the first link to it
+	if g.Links != nil && res.Err == nil && res.Body != "" && htmlResult(res) {
+		if base, doc, err := pageDoc(res); err == nil {
+			found = make(map[string]Link)
+			for _, l := range g.Links.ExtractNode(base, doc) {
+				if u := normalize(l.URL); found[u].URL == "" {
+					found[u] = l
+				}
+			}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	page := g.node(normalize(res.URL))
+	for _, hop := range res.Redirects {
+		from := g.node(normalize(hop.From))
+		from.status, from.depth = strconv.Itoa(hop.StatusCode), res.Depth
+		to := normalize(hop.To)
+		from.out[to] = &Edge{Target: to, Kind: EdgeRedirect}
+		page = g.node(to)
+	}
+	page.status, page.depth = fetchStatus(res), res.Depth
+	link := func(u, kind string) {
+		l := found[u]
+		page.out[u] = &Edge{Target: u, Kind: kind, Text: l.Text, Rel: l.Rel, Region: l.Region}
+	}
+	for _, u := range res.NoFollowLinks {
+		link(normalize(u), EdgeNoFollow)
+	}
+	// A URL linked to both ways can be followed
+	for _, u := range res.Links {
+		link(normalize(u), EdgeLink)
+	}
+	for u := range page.out {
+		g.node(u)
+	}
+}
+
+// node returns the node of url, adding it if need be. The caller holds
+// g.mu
+func (g *LinkGraph) node(url string) *graphNode {
+	if g.nodes == nil {
+		g.nodes = make(map[string]*graphNode)
+	}
+	n := g.nodes[url]
+	if n == nil {
+		n = &graphNode{depth: -1, out: make(map[string]*Edge)}
+		g.nodes[url] = n
+	}
+	return n
+}
+
+// Nodes returns the URLs of the graph, sorted.
+func (g *LinkGraph) Nodes() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sorted()
+}
+
+// sorted is Nodes without the locking
+func (g *LinkGraph) sorted() []string {
+	urls := make([]string, 0, len(g.nodes))
+	for u := range g.nodes {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Edges returns where url links or redirects to, sorted, with the kind of
+// each edge: EdgeLink, EdgeNoFollow or EdgeRedirect.
+func (g *LinkGraph) Edges(url string) (targets, kinds []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.edges(url)
+}
+
+// edges is Edges without the locking
+func (g *LinkGraph) edges(url string) (targets, kinds []string) {
+	n := g.nodes[url]
+	if n == nil {
+		return nil, nil
+	}
+	for u := range n.out {
+		targets = append(targets, u)
+	}
+	sort.Strings(targets)
+	kinds = make([]string, len(targets))
+	for i, u := range targets {
+		kinds[i] = n.out[u].Kind
+	}
+	return targets, kinds
+}
+
+// OutEdges returns the edges of url, sorted by target.
+func (g *LinkGraph) OutEdges(url string) []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.outEdges(url)
+}
+
+// outEdges is OutEdges without the locking
+func (g *LinkGraph) outEdges(url string) []Edge {
+	n := g.nodes[url]
+	if n == nil {
+		return nil
+	}
+	edges := make([]Edge, 0, len(n.out))
+	for _, e := range n.out {
+		edges = append(edges, *e)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Target < edges[j].Target })
+	return edges
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz, the nodes
+// labelled with their status, the nofollow links dotted and the
+// redirects dashed, the links with their anchor, rel and region if known:
+//
+//	webcrawl crawl -links-dot site.dot https://example.com/
+//	sfdp -Tsvg site.dot > site.svg
+func (g *LinkGraph) WriteDOT(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph links {")
+	urls := g.sorted()
+	for _, u := range urls {
+		n := g.nodes[u]
+		attrs := ""
+		if n.status != "" {
+			attrs = fmt.Sprintf(" [status=%s, depth=%d]", dotQuote(n.status), n.depth)
+		}
+		fmt.Fprintf(bw, "\t%s%s;\n", dotQuote(u), attrs)
+	}
+	for _, u := range urls {
+		for _, e := range g.outEdges(u) {
+			var attrs []string
+			switch e.Kind {
+			case EdgeNoFollow:
+				attrs = append(attrs, "style=dotted")
+			case EdgeRedirect:
+				attrs = append(attrs, "style=dashed")
+			}
+			for _, a := range [][2]string{{"anchor", e.Text}, {"rel", e.Rel}, {"region", e.Region}} {
+				if a[1] != "" {
+					attrs = append(attrs, a[0]+"="+dotQuote(a[1]))
+				}
+			}
+			style := ""
+			if len(attrs) > 0 {
+				style = " [" + strings.Join(attrs, ", ") + "]"
+			}
+			fmt.Fprintf(bw, "\t%s -> %s%s;\n", dotQuote(u), dotQuote(e.Target), style)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a quoted DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteGraphML writes the graph as GraphML, the nodes with their status
+// and depth as attributes, the edges with their kind, and their anchor,
+// rel and region if known.
+func (g *LinkGraph) WriteGraphML(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="status" for="node" attr.name="status" attr.type="string"/>
+  <key id="depth" for="node" attr.name="depth" attr.type="int"/>
+  <key id="kind" for="edge" attr.name="kind" attr.type="string"/>
+  <key id="anchor" for="edge" attr.name="anchor" attr.type="string"/>
+  <key id="rel" for="edge" attr.name="rel" attr.type="string"/>
+  <key id="region" for="edge" attr.name="region" attr.type="string"/>
+  <graph id="links" edgedefault="directed">
+`)
+	urls := g.sorted()
+	for _, u := range urls {
+		n := g.nodes[u]
+		if n.status == "" {
+			fmt.Fprintf(bw, "    <node id=\"%s\"/>\n", html.EscapeString(u))
+			continue
+		}
+		fmt.Fprintf(bw, "    <node id=\"%s\"><data key=\"status\">%s</data><data key=\"depth\">%d</data></node>\n",
+			html.EscapeString(u), html.EscapeString(n.status), n.depth)
+	}
+	for _, u := range urls {
+		for _, e := range g.outEdges(u) {
+			fmt.Fprintf(bw, "    <edge source=\"%s\" target=\"%s\"><data key=\"kind\">%s</data>",
+				html.EscapeString(u), html.EscapeString(e.Target), e.Kind)
+			for _, a := range [][2]string{{"anchor", e.Text}, {"rel", e.Rel}, {"region", e.Region}} {
+				if a[1] != "" {
+					fmt.Fprintf(bw, "<data key=\"%s\">%s</data>", a[0], html.EscapeString(a[1]))
+				}
+			}
+			fmt.Fprint(bw, "</edge>\n")
+		}
+	}
+	fmt.Fprint(bw, "  </graph>\n</graphml>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,159 @@
+package webcrawl
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LinkReport collects the links of the pages a crawl fetched and writes
+// them out as a CSV inventory, one row per link:
+//
+//	source,target,anchor_text,status,depth,nofollow
+//	https://example.com/,https://example.com/about,About us,200,0,
+//
+// status is how fetching the target went: its HTTP status code (200 when
+// it was fetched fine by a Fetcher that doesn't tell), the cause of any
+// other failure (such as "timed out"), or empty when the crawl didn't
+// fetch it, for instance because it was out of scope. depth is the
+// source's, nofollow is "true" for the links robots are not to follow,
+// see CrawlResult.NoFollowLinks.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance, and
+// write it with WriteCSV once the crawl is over. A LinkReport is safe for
+// concurrent use, its zero value is ready to use.
+type LinkReport struct {
+	// Normalizer must be the Crawler's, for targets to be matched with
+	//   the results of their fetch. When nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	// Links takes the links from the pages again, anchor text included.
+	//   Only the links the Fetcher found are kept, so when nil
+	//   DefaultLinkTags is used to catch whatever it looked at
+	Links *LinkExtractor
+
+	mu     sync.Mutex
+	rows   []linkRow
+	status map[string]string // normalized URL => how its fetch went
+}
+
+type linkRow struct {
+	source, target, text string
+	depth                int
+	nofollow             bool
+}
+
+// Add records the result of a fetch: how it went and, for an HTML page,
+// the links it has.
+func (r *LinkReport) Add(res CrawlResult) {
+	status := fetchStatus(res)
+	var rows []linkRow
+	if res.Err == nil && len(res.Links)+len(res.NoFollowLinks) > 0 {
+		rows = r.extract(res)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == nil {
+		r.status = make(map[string]string)
+	}
+	r.status[r.normalize(res.URL)] = status
+	r.rows = append(r.rows, rows...)
+}
+
+// statusCode returns the HTTP status of res, the one of its StatusError
+// when the Fetcher doesn't tell otherwise, zero when there is none
+func statusCode(res CrawlResult) int {
+	var se *StatusError
+	if res.StatusCode == 0 && errors.As(res.Err, &se) {
+		return se.StatusCode
+	}
+	return res.StatusCode
+}
+
+// fetchStatus is what the status column says of a fetch
+func fetchStatus(res CrawlResult) string {
+	var se *StatusError
+	switch err := res.Err; {
+	case err == nil && res.StatusCode != 0:
+		return strconv.Itoa(res.StatusCode)
+	case err == nil:
+		return "200"
+	case errors.As(err, &se):
+		return strconv.Itoa(se.StatusCode)
+	}
+	return Classify(res.Err).Error()
+}
+
+// extract finds the links of res again, with their anchor text
+func (r *LinkReport) extract(res CrawlResult) []linkRow {
+	base, err := url.Parse(res.URL)
+	if err != nil {
+		return nil
+	}
+	e := r.Links
+	if e == nil {
+		e = &LinkExtractor{}
+	}
+	links, err := e.Extract(base, strings.NewReader(res.Body))
+	if err != nil {
+		return nil
+	}
+	// found is whether the Fetcher found the URL, and as nofollow
+	found := make(map[string]bool, len(res.Links)+len(res.NoFollowLinks))
+	for _, u := range res.Links {
+		found[u] = false
+	}
+	for _, u := range res.NoFollowLinks {
+		found[u] = true
+	}
+	var rows []linkRow
+	listed := make(map[string]bool)
+	for _, l := range links {
+		if nofollow, ok := found[l.URL]; ok && !listed[l.URL] {
+			listed[l.URL] = true
+			rows = append(rows, linkRow{res.URL, l.URL, l.Text, res.Depth, nofollow})
+		}
+	}
+	// Links the Fetcher found that aren't in the HTML as we see it
+	for _, u := range append(append([]string(nil), res.Links...), res.NoFollowLinks...) {
+		if !listed[u] {
+			listed[u] = true
+			rows = append(rows, linkRow{res.URL, u, "", res.Depth, found[u]})
+		}
+	}
+	return rows
+}
+
+func (r *LinkReport) normalize(u string) string {
+	norm := r.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	if n, err := norm.Normalize(u); err == nil {
+		return n
+	}
+	return u
+}
+
+// WriteCSV writes the links recorded so far to w, with a header line, in
+// the order their pages were fetched.
+func (r *LinkReport) WriteCSV(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "target", "anchor_text", "status", "depth", "nofollow"})
+	for _, row := range r.rows {
+		nofollow := ""
+		if row.nofollow {
+			nofollow = "true"
+		}
+		cw.Write([]string{row.source, row.target, row.text, r.status[r.normalize(row.target)], strconv.Itoa(row.depth), nofollow})
+	}
+	cw.Flush()
+	return cw.Error()
+}
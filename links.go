@@ -0,0 +1,265 @@
+package webcrawl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Link is a URL found in a page, along with the element it came from.
+type Link struct {
+	URL  string // absolute, without fragment
+	Tag  string // element name, such as "a" or "img"
+	Attr string // attribute name, such as "href" or "src"
+	Text string // the text of an <a>, its spaces collapsed, or its image's alt
+
+	// NoFollow is set when the element has rel="nofollow"
+	NoFollow bool
+
+	// Rel is the rel attribute of the element, lowercased, its spaces
+	//   collapsed: "nofollow sponsored"
+	Rel string
+
+	// Region is the part of the page the link is in, RegionNav and the
+	//   like
+	Region string
+}
+
+// The regions of a page a Link can be in, after the nearest <nav>,
+// <header>, <footer> or <aside> around it, or its ARIA role
+const (
+	RegionNav     = "nav"     // navigation, role="navigation"
+	RegionHeader  = "header"  // the banner, role="banner"
+	RegionFooter  = "footer"  // role="contentinfo"
+	RegionAside   = "aside"   // a sidebar, role="complementary"
+	RegionContent = "content" // anywhere else, the content of the page
+)
+
+// regionRoles maps the ARIA landmark roles to their regions
+var regionRoles = map[string]string{
+	"navigation":    RegionNav,
+	"banner":        RegionHeader,
+	"contentinfo":   RegionFooter,
+	"complementary": RegionAside,
+}
+
+// region returns the region n is in, in being its parent's, unless n
+// starts one. The <header> and <footer> of an <article>, <section> or
+// <main>, sectioned, are those of a part of the content
+func region(n *html.Node, in string, sectioned bool) string {
+	if role, ok := attr(n, "role"); ok {
+		if r, ok := regionRoles[strings.ToLower(strings.TrimSpace(role))]; ok {
+			return r
+		}
+	}
+	switch {
+	case n.DataAtom == atom.Nav:
+		return RegionNav
+	case n.DataAtom == atom.Aside:
+		return RegionAside
+	case n.DataAtom == atom.Header && !sectioned:
+		return RegionHeader
+	case n.DataAtom == atom.Footer && !sectioned:
+		return RegionFooter
+	}
+	return in
+}
+
+// DefaultLinkTags lists the elements and attributes a zero LinkExtractor
+// takes links from.
+var DefaultLinkTags = map[string][]string{
+	"a":      {"href"},
+	"link":   {"href"},
+	"img":    {"src"},
+	"script": {"src"},
+	"iframe": {"src"},
+}
+
+// AnchorTags only takes links from <a href>, that is the links to other
+// pages rather than to the resources a page is made of.
+var AnchorTags = map[string][]string{
+	"a": {"href"},
+}
+
+// LinkExtractor finds the links of an HTML document by walking its parsed
+// tree, and resolves them to absolute URLs. It can be shared by any number
+// of Fetchers and goroutines.
+type LinkExtractor struct {
+	// Tags maps element names to the attributes holding their links,
+	//   when nil DefaultLinkTags is used
+	Tags map[string][]string
+
+	// Schemes lists the URL schemes kept, when nil only http and https
+	//   links are, which drops mailto:, javascript: and the like
+	Schemes []string
+}
+
+// Extract parses the HTML document read from body and returns its links in
+// document order, without duplicates: a URL found twice in the same
+// attribute of the same kind of element is only kept the first time.
+// Relative links are resolved against
+// base, or against the document's <base href> when it has one.
+func (e *LinkExtractor) Extract(base *url.URL, body io.Reader) ([]Link, error) {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return e.ExtractNode(base, doc), nil
+}
+
+// ExtractNode is Extract for a document that is already parsed.
+func (e *LinkExtractor) ExtractNode(base *url.URL, doc *html.Node) []Link {
+	tags := e.Tags
+	if tags == nil {
+		tags = DefaultLinkTags
+	}
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+
+	type key struct{ url, tag, attr string }
+	var links []Link
+	seen := make(map[key]bool)
+	var walk func(n *html.Node, in string, sectioned bool)
+	walk = func(n *html.Node, in string, sectioned bool) {
+		if n.Type == html.ElementNode {
+			in = region(n, in, sectioned)
+			sectioned = sectioned || n.DataAtom == atom.Article || n.DataAtom == atom.Section || n.DataAtom == atom.Main
+			for _, name := range tags[n.Data] {
+				v, ok := attr(n, name)
+				if !ok {
+					continue
+				}
+				u := e.resolve(base, v)
+				if u == "" {
+					continue
+				}
+				k := key{u, n.Data, name}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				l := Link{URL: u, Tag: n.Data, Attr: name, Region: in}
+				if n.DataAtom == atom.A {
+					l.Text = anchorText(n)
+				}
+				l.NoFollow = hasRel(n, "nofollow")
+				if rel, ok := attr(n, "rel"); ok {
+					l.Rel = strings.Join(strings.Fields(strings.ToLower(rel)), " ")
+				}
+				links = append(links, l)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, in, sectioned)
+		}
+	}
+	walk(doc, RegionContent, false)
+	return links
+}
+
+// URLs is Extract returning just the distinct URLs, which is what a
+// Fetcher hands to the Crawler.
+func (e *LinkExtractor) URLs(base *url.URL, body io.Reader) ([]string, error) {
+	links, err := e.Extract(base, body)
+	if err != nil {
+		return nil, err
+	}
+	return LinkURLs(links), nil
+}
+
+// LinkURLs returns the distinct URLs of links, in order.
+func LinkURLs(links []Link) []string {
+	var urls []string
+	seen := make(map[string]bool, len(links))
+	for _, l := range links {
+		if !seen[l.URL] {
+			seen[l.URL] = true
+			urls = append(urls, l.URL)
+		}
+	}
+	return urls
+}
+
+// resolve turns the raw attribute value ref into an absolute URL, or ""
+// when it is not a link we keep
+func (e *LinkExtractor) resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if !e.keepScheme(u.Scheme) {
+		return ""
+	}
+	// A fragment points into the same document
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+func (e *LinkExtractor) keepScheme(scheme string) bool {
+	if e.Schemes == nil {
+		return scheme == "http" || scheme == "https"
+	}
+	for _, s := range e.Schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// anchorText returns the text inside n, with its runs of spaces collapsed.
+// An image standing for the text counts with its alt
+func anchorText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			sb.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.DataAtom == atom.Img:
+			if alt, ok := attr(n, "alt"); ok {
+				sb.WriteString(" " + alt + " ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// findBase returns the href of the document's first <base> element
+func findBase(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+		if v, ok := attr(n, "href"); ok {
+			return v
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if b := findBase(c); b != "" {
+			return b
+		}
+	}
+	return ""
+}
+
+// attr returns the value of n's attribute called name
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
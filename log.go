@@ -0,0 +1,71 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// The Crawler logs these events to its Logger:
+//
+//	level  message                  attributes
+//	INFO   crawl started            seed, max_depth, workers
+//	INFO   crawl resumed            seed, visited, pending
+//	DEBUG  url queued               url, depth
+//	DEBUG  url skipped              url, depth, reason
+//	INFO   page fetched             url, depth, duration, links, worker
+//	INFO   page skipped             url, depth, duration, reason, worker
+//	WARN   fetch failed             url, depth, duration, worker, cause, status, err
+//	DEBUG  fetch cut short          url, depth, worker
+//	DEBUG  fetch throttled          url, depth, worker
+//	WARN   host backing off         host, wait, concurrency
+//	WARN   circuit breaker tripped  host, cooldown, trips
+//	WARN   host down                host, trips
+//	INFO   crawl finished           fetched, failed, elapsed, err
+//
+// status is only there when the server answered with an error status. A
+// fetch is throttled when its host answered 429 or 503, it is tried again
+// once the host is done backing off. The circuit breaker trips and gives
+// up on hosts as CircuitBreaker says.
+
+// discardHandler is the slog.Handler of a nil Logger, it drops everything
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logger returns the Logger of c, one that logs nothing when it is nil
+func (c *Crawler) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return c.Logger
+}
+
+// logResult logs how a fetch by worker went
+func (r *run) logResult(ctx context.Context, worker int, res CrawlResult, cut bool) {
+	switch {
+	case cut:
+		r.log.DebugContext(ctx, "fetch cut short",
+			"url", res.URL, "depth", res.Depth, "worker", worker)
+	case res.Err != nil:
+		attrs := []any{"url", res.URL, "depth", res.Depth, "duration", res.Duration,
+			"worker", worker, "cause", Classify(res.Err).Error()}
+		var se *StatusError
+		if errors.As(res.Err, &se) {
+			attrs = append(attrs, "status", se.StatusCode)
+		}
+		attrs = append(attrs, "err", res.Err)
+		r.log.WarnContext(ctx, "fetch failed", attrs...)
+	case res.Skipped != "":
+		r.log.InfoContext(ctx, "page skipped",
+			"url", res.URL, "depth", res.Depth, "duration", res.Duration,
+			"reason", res.Skipped, "worker", worker)
+	default:
+		r.log.InfoContext(ctx, "page fetched",
+			"url", res.URL, "depth", res.Depth, "duration", res.Duration,
+			"links", len(res.Links), "worker", worker)
+	}
+}
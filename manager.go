@@ -0,0 +1,432 @@
+package webcrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoJob is returned by a CrawlerManager for a job it doesn't know of.
+var ErrNoJob = errors.New("webcrawl: no such job")
+
+// JobState is where a job of a CrawlerManager stands.
+type JobState string
+
+// The states of a job
+const (
+	JobWaiting  JobState = "waiting"  // for one of the jobs running to be over, see CrawlerManager.MaxJobs
+	JobRunning  JobState = "running"  // crawling
+	JobFinished JobState = "finished" // done crawling, failed fetches or not
+	JobStopped  JobState = "stopped"  // cancelled
+	JobFailed   JobState = "failed"   // the crawl could not go on, Err says why
+)
+
+// CrawlerManager runs crawl jobs side by side in one process, for a service
+// to crawl for several tenants at once: each job has a Crawler of its own,
+// with its own scope, limits and sinks, and nothing of its crawl is shared
+// with the others but what the Options of the manager share on purpose, a
+// Fetcher and its connections for instance.
+//
+//	m := &webcrawl.CrawlerManager{Options: []webcrawl.Option{webcrawl.WithUserAgent("mybot")}, MaxJobs: 8}
+//	job, err := m.Start([]string{"https://example.com/"},
+//		webcrawl.WithMaxPages(1000),
+//		webcrawl.WithOnResult(func(res webcrawl.CrawlResult) { ... }),
+//	)
+//
+// It keeps the jobs it started, and what became of them, until Remove. A
+// CrawlerManager is safe for concurrent use, its zero value is ready to
+// use.
+type CrawlerManager struct {
+	// Options set up the Crawler of every job, before those given to
+	//   Start. They are not to hold what a crawl keeps to itself, such as
+	//   a Frontier, a VisitedSet or a Journal, lest the jobs mix up
+	Options []Option
+
+	// MaxJobs caps the jobs running at once, those started beyond it
+	//   waiting their turn. Zero or less means no cap
+	MaxJobs int
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	lastID  int
+	running int
+	waiting []*Job // in the order they were started
+}
+
+// Job is a crawl of a CrawlerManager. Its Crawler is there for the
+// controls of a crawl under way, Pause, SetWorkers, AddSeeds and the
+// others, and is not to be Run by anyone else.
+type Job struct {
+	ID      string
+	Seeds   []string
+	Crawler *Crawler
+
+	m       *CrawlerManager
+	ctx     context.Context
+	cancel  context.CancelFunc
+	start   chan struct{} // closed once the job may run
+	done    chan struct{} // closed once the job is over
+	created time.Time
+
+	mu      sync.Mutex
+	state   JobState
+	err     error
+	started time.Time // when it began to run
+	ended   time.Time
+	fetched int
+	failed  int
+	bytes   int64
+}
+
+// JobStatus is where a job stands, see Job.Status.
+type JobStatus struct {
+	ID    string   `json:"id"`
+	Seeds []string `json:"seeds"`
+	State JobState `json:"state"`
+	Error string   `json:"error,omitempty"`
+
+	// Created is when the job was started, Elapsed how long it has been
+	//   running, or ran, in nanoseconds in JSON
+	Created time.Time     `json:"created"`
+	Elapsed time.Duration `json:"elapsed"`
+
+	Fetched int   `json:"fetched"` // failed or not
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"`
+
+	// Run is where the crawl stands while it is running
+	Run *RunStatus `json:"run,omitempty"`
+}
+
+// Start starts a job crawling from seeds, see Crawler.RunSeeds, with a
+// Crawler set up by the Options of m then opts, and returns it running, or
+// waiting its turn under MaxJobs. The OnResult of opts, if any, is called
+// with every result of the job, and its Logger logs with the ID of the job.
+func (m *CrawlerManager) Start(seeds []string, opts ...Option) (*Job, error) {
+	if len(seeds) == 0 {
+		return nil, errors.New("webcrawl: no seed to crawl")
+	}
+	c := NewCrawler(append(append([]Option(nil), m.Options...), opts...)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{Seeds: append([]string(nil), seeds...), Crawler: c, m: m, ctx: ctx, cancel: cancel,
+		start: make(chan struct{}), done: make(chan struct{}), created: time.Now(), state: JobWaiting}
+	onResult := c.OnResult
+	c.OnResult = func(res CrawlResult) {
+		j.count(res)
+		if onResult != nil {
+			onResult(res)
+		}
+	}
+
+	m.mu.Lock()
+	if m.jobs == nil {
+		m.jobs = make(map[string]*Job)
+	}
+	m.lastID++
+	j.ID = strconv.Itoa(m.lastID)
+	m.jobs[j.ID] = j
+	if m.MaxJobs <= 0 || m.running < m.MaxJobs {
+		m.running++
+		close(j.start)
+	} else {
+		m.waiting = append(m.waiting, j)
+	}
+	m.mu.Unlock()
+	if c.Logger != nil {
+		c.Logger = c.Logger.With("job", j.ID)
+	}
+	go j.run()
+	return j, nil
+}
+
+// run crawls once j may run, and hands its turn over to the next job
+// waiting when it is over
+func (j *Job) run() {
+	defer close(j.done)
+	select {
+	case <-j.start:
+	case <-j.ctx.Done():
+		j.m.dequeue(j)
+		j.mu.Lock()
+		j.state, j.ended = JobStopped, time.Now()
+		j.mu.Unlock()
+		return
+	}
+	defer j.m.next()
+	j.mu.Lock()
+	j.state, j.started = JobRunning, time.Now()
+	j.mu.Unlock()
+
+	err := j.Crawler.RunSeeds(j.ctx, j.Seeds...)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.err, j.ended = err, time.Now()
+	var report *ErrorReport
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.state, j.err = JobStopped, nil
+	case err == nil || errors.As(err, &report):
+		j.state = JobFinished
+	default:
+		j.state = JobFailed
+	}
+	j.cancel()
+}
+
+// dequeue takes j, cancelled, out of the jobs waiting their turn. It may
+// have been given its turn in the meantime, which it hands over
+func (m *CrawlerManager) dequeue(j *Job) {
+	m.mu.Lock()
+	for i, w := range m.waiting {
+		if w == j {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			m.mu.Unlock()
+			return
+		}
+	}
+	m.mu.Unlock()
+	select {
+	case <-j.start:
+		m.next()
+	default:
+	}
+}
+
+// next gives the turn of a job that is over to the first one waiting
+func (m *CrawlerManager) next() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.waiting) == 0 {
+		m.running--
+		return
+	}
+	j := m.waiting[0]
+	m.waiting = m.waiting[1:]
+	close(j.start)
+}
+
+// count tallies res, the OnResult of the job
+func (j *Job) count(res CrawlResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.fetched++
+	if res.Err != nil {
+		j.failed++
+	}
+	j.bytes += int64(len(res.Body))
+}
+
+// Cancel calls off j, as if the context of its Run was cancelled: it is
+// over once its workers are done, see Done. A job waiting its turn never
+// runs.
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// Done returns a channel closed once j is over.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait waits for j to be over and returns its Err.
+func (j *Job) Wait() error {
+	<-j.done
+	return j.Err()
+}
+
+// Err returns what the crawl of j returned, nil while it is not over and
+// once it was cancelled. For a job that finished with fetches failed, it is
+// the *ErrorReport of those.
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Status returns where j stands.
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	st := JobStatus{ID: j.ID, Seeds: j.Seeds, State: j.state, Created: j.created,
+		Fetched: j.fetched, Failed: j.failed, Bytes: j.bytes}
+	if j.err != nil {
+		st.Error = j.err.Error()
+	}
+	switch {
+	case !j.ended.IsZero() && !j.started.IsZero():
+		st.Elapsed = j.ended.Sub(j.started)
+	case !j.started.IsZero():
+		st.Elapsed = time.Since(j.started)
+	}
+	j.mu.Unlock()
+	if st.State == JobRunning {
+		if runs := j.Crawler.Status(); len(runs) > 0 {
+			st.Run = &runs[0]
+		}
+	}
+	return st
+}
+
+// Job returns the job of m with the ID id.
+func (m *CrawlerManager) Job(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// Jobs returns the jobs of m, in the order they were started.
+func (m *CrawlerManager) Jobs() []*Job {
+	m.mu.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].created.Before(jobs[j].created) })
+	return jobs
+}
+
+// Cancel cancels the job of m with the ID id, see Job.Cancel. It returns
+// ErrNoJob when there is none.
+func (m *CrawlerManager) Cancel(id string) error {
+	j, ok := m.Job(id)
+	if !ok {
+		return ErrNoJob
+	}
+	j.Cancel()
+	return nil
+}
+
+// Remove forgets the job of m with the ID id, which must be over. It
+// returns ErrNoJob when there is none.
+func (m *CrawlerManager) Remove(id string) error {
+	j, ok := m.Job(id)
+	if !ok {
+		return ErrNoJob
+	}
+	select {
+	case <-j.done:
+	default:
+		return fmt.Errorf("webcrawl: job %s is not over", id)
+	}
+	m.mu.Lock()
+	delete(m.jobs, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// Shutdown cancels every job of m and waits for them to be over, or for
+// ctx to be done.
+func (m *CrawlerManager) Shutdown(ctx context.Context) error {
+	jobs := m.Jobs()
+	for _, j := range jobs {
+		j.Cancel()
+	}
+	for _, j := range jobs {
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Handler returns an HTTP API for m, mount it on a server of its own, it
+// has no authentication:
+//
+//	GET    /jobs               the JobStatus of every job, as a JSON array
+//	POST   /jobs?url=...       Start, from the url parameters, with depth, max_pages and workers
+//	GET    /jobs/{id}          the JobStatus of a job
+//	POST   /jobs/{id}/cancel   Cancel
+//	DELETE /jobs/{id}          Remove
+//	       /jobs/{id}/control/ the ControlHandler of the Crawler of a job
+//
+// POST /jobs answers 201 Created with the JobStatus of the job, the others
+// 204 No Content, 404 when there is no such job, 400 for a bad
+// request and 409 Conflict for a job not over to Remove.
+func (m *CrawlerManager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, req *http.Request) {
+		statuses := []JobStatus{}
+		for _, j := range m.Jobs() {
+			statuses = append(statuses, j.Status())
+		}
+		jobReply(w, http.StatusOK, statuses)
+	})
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, req *http.Request) {
+		seeds := req.URL.Query()["url"]
+		if len(seeds) == 0 {
+			http.Error(w, "want the seeds as url parameters", http.StatusBadRequest)
+			return
+		}
+		var opts []Option
+		for name, opt := range map[string]func(int) Option{"depth": WithDepth, "max_pages": WithMaxPages, "workers": WithConcurrency} {
+			if v := req.FormValue(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					http.Error(w, "want a number as "+name, http.StatusBadRequest)
+					return
+				}
+				opts = append(opts, opt(n))
+			}
+		}
+		j, err := m.Start(seeds, opts...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jobReply(w, http.StatusCreated, j.Status())
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, req *http.Request) {
+		j, ok := m.Job(req.PathValue("id"))
+		if !ok {
+			http.Error(w, ErrNoJob.Error(), http.StatusNotFound)
+			return
+		}
+		jobReply(w, http.StatusOK, j.Status())
+	})
+	mux.HandleFunc("POST /jobs/{id}/cancel", func(w http.ResponseWriter, req *http.Request) {
+		if err := m.Cancel(req.PathValue("id")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, req *http.Request) {
+		switch err := m.Remove(req.PathValue("id")); {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case err == ErrNoJob:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusConflict)
+		}
+	})
+	mux.HandleFunc("/jobs/{id}/control/", func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+		j, ok := m.Job(id)
+		if !ok {
+			http.Error(w, ErrNoJob.Error(), http.StatusNotFound)
+			return
+		}
+		http.StripPrefix("/jobs/"+id+"/control", j.Crawler.ControlHandler()).ServeHTTP(w, req)
+	})
+	return mux
+}
+
+// jobReply answers a request of the jobs API with v, as JSON
+func jobReply(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
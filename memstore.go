@@ -0,0 +1,84 @@
+package webcrawl
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryResultStore keeps the last result of every URL in memory, bodies
+// and all, and finds those fetched since a given time by an index of them
+// sorted by FetchedAt: an incremental pipeline polls QuerySinceTime with
+// the time it last processed up to, to get the newest results alone. See
+// the store package to keep them in a database instead.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. A
+// MemoryResultStore is safe for concurrent use, its zero value is ready
+// to use.
+type MemoryResultStore struct {
+	mu      sync.RWMutex
+	results map[string]*CrawlResult // URL => its last result
+	byTime  []*CrawlResult          // results, sorted by FetchedAt
+}
+
+// Add records res, in place of the result of its URL recorded before.
+func (s *MemoryResultStore) Add(res CrawlResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.results == nil {
+		s.results = make(map[string]*CrawlResult)
+	}
+	if old, ok := s.results[res.URL]; ok {
+		i := s.search(old.FetchedAt)
+		for s.byTime[i] != old {
+			i++
+		}
+		s.byTime = append(s.byTime[:i], s.byTime[i+1:]...)
+	}
+	r := &res
+	s.results[res.URL] = r
+	// The results mostly come in the order they were fetched, at the end
+	i := len(s.byTime)
+	if i > 0 && res.FetchedAt.Before(s.byTime[i-1].FetchedAt) {
+		i = s.search(res.FetchedAt)
+	}
+	s.byTime = append(s.byTime, nil)
+	copy(s.byTime[i+1:], s.byTime[i:])
+	s.byTime[i] = r
+}
+
+// search returns the index of the first result of byTime fetched at t or
+// after. The caller holds s.mu
+func (s *MemoryResultStore) search(t time.Time) int {
+	return sort.Search(len(s.byTime), func(i int) bool { return !s.byTime[i].FetchedAt.Before(t) })
+}
+
+// Get returns the result recorded for url.
+func (s *MemoryResultStore) Get(url string) (CrawlResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.results[url]; ok {
+		return *r, true
+	}
+	return CrawlResult{}, false
+}
+
+// Len returns how many URLs have a result recorded.
+func (s *MemoryResultStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.results)
+}
+
+// QuerySinceTime returns the results fetched at t or after, by FetchedAt.
+// The results of the URLs not fetched, without a FetchedAt, come first
+// for a zero t and not at all otherwise.
+func (s *MemoryResultStore) QuerySinceTime(t time.Time) []CrawlResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []CrawlResult
+	for _, r := range s.byTime[s.search(t):] {
+		out = append(out, *r)
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package webcrawl_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestMemoryResultStoreQuerySinceTime(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	var s webcrawl.MemoryResultStore
+	add := func(url string, at time.Duration) {
+		s.Add(webcrawl.CrawlResult{URL: url, FetchedAt: t0.Add(at)})
+	}
+	add("/a", 0)
+	add("/c", 3*time.Second)
+	// Out of order, as the workers finish
+	add("/b", time.Second)
+	add("/d", 2*time.Second)
+	s.Add(webcrawl.CrawlResult{URL: "/unfetched"})
+	since := func(at time.Duration) []string {
+		var urls []string
+		for _, r := range s.QuerySinceTime(t0.Add(at)) {
+			urls = append(urls, r.URL)
+		}
+		return urls
+	}
+	if got, want := since(time.Second), []string{"/b", "/d", "/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("since t0+1s: %v, want %v", got, want)
+	}
+	if got := since(time.Hour); got != nil {
+		t.Errorf("since t0+1h: %v, want none", got)
+	}
+	// Fetched again, /a moves to the end
+	add("/a", 4*time.Second)
+	if got, want := since(0), []string{"/b", "/d", "/c", "/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("since t0: %v, want %v", got, want)
+	}
+	if got := len(s.QuerySinceTime(time.Time{})); got != 5 || s.Len() != 5 {
+		t.Errorf("since the zero time: %d results of %d, want 5", got, s.Len())
+	}
+	if r, ok := s.Get("/a"); !ok || !r.FetchedAt.Equal(t0.Add(4*time.Second)) {
+		t.Errorf("Get(/a) = %v, %v, want the later fetch", r.FetchedAt, ok)
+	}
+}
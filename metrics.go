@@ -0,0 +1,201 @@
+package webcrawl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the fetch duration
+// histogram buckets when Metrics.Buckets is not set. They are the usual
+// Prometheus ones.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics counts what the Crawler does and serves it in the Prometheus
+// text format, mount it on /metrics:
+//
+//	m := &webcrawl.Metrics{}
+//	c := &webcrawl.Crawler{Fetcher: f, MaxDepth: 3, Metrics: m}
+//	http.Handle("/metrics", m)
+//
+// It exposes
+//
+//	webcrawl_fetches_total                  counter, every fetch done
+//	webcrawl_errors_total{class}            counter, failed fetches by cause
+//	webcrawl_frontier_size                  gauge, URLs waiting to be fetched
+//	webcrawl_fetches_in_flight              gauge, fetches under way
+//	webcrawl_fetch_duration_seconds{host}   histogram, fetch latency per host
+//
+// The error classes are the causes Classify returns, in snake case:
+// not_found, timed_out, robots_blocked, too_deep, http_status,
+// redirect_loop, too_many_redirects, host_down and fetch_failed.
+//
+// Pages per second is rate(webcrawl_fetches_total[1m]).
+//
+// A Metrics is safe for concurrent use and may be shared by several
+// Crawlers, its zero value is ready to use.
+type Metrics struct {
+	// Buckets are the upper bounds of the duration histogram, in seconds
+	//   and increasing, nil means DefaultBuckets. Set them before the
+	//   first fetch
+	Buckets []float64
+
+	mu        sync.Mutex
+	fetches   uint64
+	errors    map[string]uint64 // class => count
+	frontier  int               // summed over the crawls reporting to m
+	inFlight  int
+	durations map[string]*histogram // host => its latencies
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative, one more for +Inf
+	sum    float64
+	count  uint64
+}
+
+// errorClasses names the causes of Classify in metric labels
+var errorClasses = map[error]string{
+	ErrNotFound:      "not_found",
+	ErrTimeout:       "timed_out",
+	ErrRobotsBlocked: "robots_blocked",
+	ErrTooDeep:       "too_deep",
+	ErrHTTPStatus:    "http_status",
+
+	ErrRedirectLoop:     "redirect_loop",
+	ErrTooManyRedirects: "too_many_redirects",
+	ErrHostDown:         "host_down",
+	ErrFetchFailed:      "fetch_failed",
+}
+
+// addFrontier moves the frontier size gauge by n
+func (m *Metrics) addFrontier(n int) {
+	m.mu.Lock()
+	m.frontier += n
+	m.mu.Unlock()
+}
+
+// started counts a fetch under way
+func (m *Metrics) started() {
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+}
+
+// finished records the result of a fetch that started, one cut short by
+// the crawl being called off only leaves the in-flight ones
+func (m *Metrics) finished(res CrawlResult, interrupted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	if interrupted {
+		return
+	}
+	m.fetches++
+	if res.Err != nil {
+		if m.errors == nil {
+			m.errors = make(map[string]uint64)
+		}
+		m.errors[errorClasses[Classify(res.Err)]]++
+	}
+	if res.FetchedAt.IsZero() {
+		// Failed before reaching the server, there is no latency to speak of
+		return
+	}
+	host := ""
+	if u, err := url.Parse(res.URL); err == nil {
+		host = u.Host
+	}
+	if m.durations == nil {
+		m.durations = make(map[string]*histogram)
+	}
+	h, ok := m.durations[host]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets())+1)}
+		m.durations[host] = h
+	}
+	h.observe(m.buckets(), res.Duration)
+}
+
+func (m *Metrics) buckets() []float64 {
+	if m.Buckets == nil {
+		return DefaultBuckets
+	}
+	return m.Buckets
+}
+
+func (h *histogram) observe(buckets []float64, d time.Duration) {
+	s := d.Seconds()
+	i := sort.SearchFloat64s(buckets, s) // the first bucket s fits in
+	h.counts[i]++
+	h.sum += s
+	h.count++
+}
+
+// ServeHTTP writes the metrics out in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	bw.Flush()
+}
+
+// write writes the metrics to w in the Prometheus text format
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP webcrawl_fetches_total Fetches done, failed or not.\n")
+	fmt.Fprintf(w, "# TYPE webcrawl_fetches_total counter\n")
+	fmt.Fprintf(w, "webcrawl_fetches_total %d\n", m.fetches)
+
+	fmt.Fprintf(w, "# HELP webcrawl_errors_total Failed fetches by class of error.\n")
+	fmt.Fprintf(w, "# TYPE webcrawl_errors_total counter\n")
+	classes := make([]string, 0, len(errorClasses))
+	for _, class := range errorClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		fmt.Fprintf(w, "webcrawl_errors_total{class=%q} %d\n", class, m.errors[class])
+	}
+
+	fmt.Fprintf(w, "# HELP webcrawl_frontier_size URLs waiting to be fetched.\n")
+	fmt.Fprintf(w, "# TYPE webcrawl_frontier_size gauge\n")
+	fmt.Fprintf(w, "webcrawl_frontier_size %d\n", m.frontier)
+
+	fmt.Fprintf(w, "# HELP webcrawl_fetches_in_flight Fetches under way.\n")
+	fmt.Fprintf(w, "# TYPE webcrawl_fetches_in_flight gauge\n")
+	fmt.Fprintf(w, "webcrawl_fetches_in_flight %d\n", m.inFlight)
+
+	fmt.Fprintf(w, "# HELP webcrawl_fetch_duration_seconds How long fetches took, by host.\n")
+	fmt.Fprintf(w, "# TYPE webcrawl_fetch_duration_seconds histogram\n")
+	hosts := make([]string, 0, len(m.durations))
+	for host := range m.durations {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	buckets := m.buckets()
+	for _, host := range hosts {
+		h := m.durations[host]
+		label := labelEscaper.Replace(host)
+		var cum uint64
+		for i, le := range buckets {
+			cum += h.counts[i]
+			fmt.Fprintf(w, "webcrawl_fetch_duration_seconds_bucket{host=\"%s\",le=\"%g\"} %d\n", label, le, cum)
+		}
+		fmt.Fprintf(w, "webcrawl_fetch_duration_seconds_bucket{host=\"%s\",le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(w, "webcrawl_fetch_duration_seconds_sum{host=\"%s\"} %g\n", label, h.sum)
+		fmt.Fprintf(w, "webcrawl_fetch_duration_seconds_count{host=\"%s\"} %d\n", label, h.count)
+	}
+}
+
+// labelEscaper escapes label values the way the text format wants
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,63 @@
+package webcrawl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	m := &Metrics{Buckets: []float64{.1, 1}}
+	at := time.Now()
+	for _, res := range []CrawlResult{
+		{URL: "https://a.test/1", FetchedAt: at, Duration: 50 * time.Millisecond},
+		{URL: "https://a.test/2", FetchedAt: at, Duration: 500 * time.Millisecond},
+		{URL: "https://a.test/3", FetchedAt: at, Duration: 2 * time.Second, Err: fmt.Errorf("slow: %w", ErrTimeout)},
+		{URL: `https://we"ird.test/`, FetchedAt: at, Duration: time.Second},
+		// Failed before reaching a server, no latency
+		{URL: "https://down.test/", Err: fmt.Errorf("dial: %w", ErrHostDown)},
+		{URL: "https://a.test/4", Err: errors.New("refused")},
+	} {
+		m.started()
+		m.finished(res, false)
+	}
+	m.started()
+	m.started()
+	m.finished(CrawlResult{URL: "https://a.test/5"}, true)
+	m.addFrontier(3)
+
+	var b strings.Builder
+	m.write(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE webcrawl_fetches_total counter\nwebcrawl_fetches_total 6\n",
+		`webcrawl_errors_total{class="timed_out"} 1` + "\n",
+		`webcrawl_errors_total{class="host_down"} 1` + "\n",
+		`webcrawl_errors_total{class="fetch_failed"} 1` + "\n",
+		`webcrawl_errors_total{class="not_found"} 0` + "\n",
+		"# TYPE webcrawl_frontier_size gauge\nwebcrawl_frontier_size 3\n",
+		"# TYPE webcrawl_fetches_in_flight gauge\nwebcrawl_fetches_in_flight 1\n",
+		"# TYPE webcrawl_fetch_duration_seconds histogram\n" +
+			`webcrawl_fetch_duration_seconds_bucket{host="a.test",le="0.1"} 1` + "\n" +
+			`webcrawl_fetch_duration_seconds_bucket{host="a.test",le="1"} 2` + "\n" +
+			`webcrawl_fetch_duration_seconds_bucket{host="a.test",le="+Inf"} 3` + "\n" +
+			`webcrawl_fetch_duration_seconds_sum{host="a.test"} 2.55` + "\n" +
+			`webcrawl_fetch_duration_seconds_count{host="a.test"} 3` + "\n" +
+			// A second on the bound, in the bucket of 1
+			`webcrawl_fetch_duration_seconds_bucket{host="we\"ird.test",le="0.1"} 0` + "\n" +
+			`webcrawl_fetch_duration_seconds_bucket{host="we\"ird.test",le="1"} 1` + "\n" +
+			`webcrawl_fetch_duration_seconds_bucket{host="we\"ird.test",le="+Inf"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no\n%s\nin\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "down.test") {
+		t.Error("a histogram for a host never reached")
+	}
+	if got := labelEscaper.Replace("a\\b\"c\nd"); got != `a\\b\"c\nd` {
+		t.Errorf("label escaped %s", got)
+	}
+}
@@ -0,0 +1,188 @@
+package webcrawl
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a Fetcher in a layer of its own, such as logging,
+// headers, caching or rate limiting, see Crawler.Middleware. Around makes
+// one out of a function, for the Fetcher it returns to keep what next can
+// do: FetchResponse and Check.
+type Middleware func(next Fetcher) Fetcher
+
+// Chain returns f wrapped in mw, the first one being the outermost: the
+// first to see a fetch and the last to see its answer.
+func Chain(f Fetcher, mw ...Middleware) Fetcher {
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
+	}
+	return f
+}
+
+// FetchCall is a fetch, or a check, going through a Middleware made with
+// Around.
+type FetchCall struct {
+	URL   string
+	Check bool // only checked, see Checker: Next returns no Response then
+
+	next Fetcher
+}
+
+// Next goes on with the fetch through the layers below, with ctx.
+func (c FetchCall) Next(ctx context.Context) (*Response, error) {
+	if c.Check {
+		return nil, Check(ctx, c.next, c.URL)
+	}
+	return FetchResponse(ctx, c.next, c.URL)
+}
+
+// Around returns a Middleware calling fn for every fetch and check, which
+// returns what call.Next does, or anything else in its place: call.Next
+// may be called with another context, more than once, or not at all.
+func Around(fn func(ctx context.Context, call FetchCall) (*Response, error)) Middleware {
+	return func(next Fetcher) Fetcher {
+		return aroundFetcher{fn, next}
+	}
+}
+
+// aroundFetcher is a Fetcher of Around
+type aroundFetcher struct {
+	fn   func(ctx context.Context, call FetchCall) (*Response, error)
+	next Fetcher
+}
+
+func (f aroundFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+func (f aroundFetcher) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	resp, err := f.fn(ctx, FetchCall{URL: url, next: f.next})
+	if resp == nil {
+		resp = &Response{URL: url}
+	}
+	return resp, err
+}
+
+func (f aroundFetcher) Check(ctx context.Context, url string) error {
+	_, err := f.fn(ctx, FetchCall{URL: url, Check: true, next: f.next})
+	return err
+}
+
+// LogMiddleware logs every fetch and check to l, at the debug level, with
+// its duration and status, at the warn level when it fails.
+func LogMiddleware(l *slog.Logger) Middleware {
+	return Around(func(ctx context.Context, call FetchCall) (*Response, error) {
+		start := time.Now()
+		resp, err := call.Next(ctx)
+		attrs := []any{"url", call.URL, "check", call.Check, "duration", time.Since(start)}
+		if resp != nil && resp.StatusCode != 0 {
+			attrs = append(attrs, "status", resp.StatusCode)
+		}
+		if err != nil {
+			l.WarnContext(ctx, "request failed", append(attrs, "err", err)...)
+		} else {
+			l.DebugContext(ctx, "request done", attrs...)
+		}
+		return resp, err
+	})
+}
+
+// headerKey is the context key of the headers of WithRequestHeader
+type headerKey struct{}
+
+// WithRequestHeader returns ctx carrying h, for the HTTPFetcher to send
+// with the requests of the fetches of ctx, on top of its own Header and
+// HostHeader. A Fetcher of your own may do the same, see RequestHeader.
+func WithRequestHeader(ctx context.Context, h http.Header) context.Context {
+	if old := RequestHeader(ctx); old != nil {
+		merged := old.Clone()
+		for k, v := range h {
+			merged[k] = v
+		}
+		h = merged
+	}
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
+// RequestHeader returns the headers ctx carries, nil when none.
+func RequestHeader(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headerKey{}).(http.Header)
+	return h
+}
+
+// HeaderMiddleware has the HTTPFetcher below send h with every request,
+// in place of the headers of the same name it would have sent, see
+// WithRequestHeader.
+func HeaderMiddleware(h http.Header) Middleware {
+	return Around(func(ctx context.Context, call FetchCall) (*Response, error) {
+		return call.Next(WithRequestHeader(ctx, h))
+	})
+}
+
+// RateLimitMiddleware spaces out the fetches and checks with l, per host.
+// Crawler.RateLimit does the same for the pages the Crawler fetches, this
+// one has robots.txt and the sitemaps wait their turn as well.
+func RateLimitMiddleware(l *HostLimiter) Middleware {
+	return Around(func(ctx context.Context, call FetchCall) (*Response, error) {
+		if err := l.WaitURL(ctx, call.URL); err != nil {
+			return nil, err
+		}
+		return call.Next(ctx)
+	})
+}
+
+// ResponseCache keeps the Responses of the fetches that succeeded, by
+// URL, for CacheMiddleware to answer them again without fetching. It is
+// safe for concurrent use.
+type ResponseCache struct {
+	mu        sync.Mutex
+	responses map[string]*Response
+}
+
+// NewResponseCache returns an empty ResponseCache.
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{responses: make(map[string]*Response)}
+}
+
+// Len returns how many Responses c holds.
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.responses)
+}
+
+// CacheMiddleware answers the fetches and checks of the URLs fetched
+// before from c, so that the pages crawled again, by later runs or by
+// several Crawlers sharing c, are fetched once. Fetches that failed are not
+// kept, they are tried again.
+func CacheMiddleware(c *ResponseCache) Middleware {
+	return Around(func(ctx context.Context, call FetchCall) (*Response, error) {
+		c.mu.Lock()
+		cached := c.responses[call.URL]
+		c.mu.Unlock()
+		if cached != nil {
+			if call.Check {
+				return nil, nil
+			}
+			// A copy, for the Crawler to do as it likes with
+			resp := *cached
+			return &resp, nil
+		}
+		resp, err := call.Next(ctx)
+		if err == nil && !call.Check && resp != nil && !resp.NotModified {
+			kept := *resp
+			c.mu.Lock()
+			c.responses[call.URL] = &kept
+			c.mu.Unlock()
+		}
+		return resp, err
+	})
+}
@@ -0,0 +1,306 @@
+package webcrawl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// mirrorAttrs maps the elements whose URLs a Mirror rewrites to the
+// attributes holding them, on top of those of assetAttrs
+var mirrorAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"form":   {"action"},
+}
+
+// Mirror saves the pages of a crawl and their assets to a directory, for
+// the site to be browsed offline, as wget --mirror --convert-links does.
+// Set Crawler.FetchAssets for the images, stylesheets and scripts of the
+// pages to be there too.
+//
+// Each file goes to the path MirrorPath gives it under Dir, as it is
+// fetched. Close then rewrites the links of the HTML pages and stylesheets
+// saved: those to a file of the mirror become relative paths to it, the
+// others absolute URLs, so that they work wherever the directory is
+// copied. A Mirror is safe for concurrent use, so Add can be called
+// straight from Crawler.OnResult.
+type Mirror struct {
+	Dir string
+
+	// Normalizer is the one of the crawl, so that the links are found
+	//   among the URLs saved as the crawler visited them. When nil a zero
+	//   Normalizer is used
+	Normalizer *Normalizer
+
+	mu      sync.Mutex
+	files   map[string]string // URL => its path under Dir, with slashes
+	aliases map[string]string // URL => the URL it redirected to
+	convert []mirrored        // the files whose links Close rewrites
+}
+
+// mirrored is a file of a Mirror
+type mirrored struct {
+	url, path string
+	css       bool // a stylesheet, else an HTML page
+}
+
+// MirrorPath returns the path, with slashes, of the copy of the file at u
+// within a mirror: its host then its path, index.html standing for a
+// directory. The query, if any, is appended after an @, and the path of an
+// HTML page ends in .html, as for /news?page=2 that becomes
+// example.com/news@page=2.html. A file URL has no host.
+func MirrorPath(u *url.URL, isHTML bool) string {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index"
+		if isHTML {
+			p += ".html"
+		}
+	}
+	if u.RawQuery != "" {
+		p += "@" + strings.ReplaceAll(u.RawQuery, "/", "_")
+	}
+	if ext := strings.ToLower(path.Ext(p)); isHTML && ext != ".html" && ext != ".htm" {
+		p += ".html"
+	}
+	// Rooted first, so that .. can't get out of the mirror
+	p = path.Clean("/" + p)
+	if u.Host == "" {
+		return p[1:]
+	}
+	return strings.ReplaceAll(u.Host, ":", "_") + p
+}
+
+// Add saves the body of res, if it has one, failing when it can't be
+// written.
+func (m *Mirror) Add(res CrawlResult) error {
+	page := pageURL(res)
+	if res.Err != nil || res.Body == "" {
+		if res.Err == nil && page != res.URL {
+			// Several URLs redirecting to a page saved once
+			m.alias(res.URL, page)
+		}
+		return nil
+	}
+	u, err := url.Parse(page)
+	if err != nil {
+		return err
+	}
+	typ := mediaType(res)
+	p := MirrorPath(u, isHTML(typ))
+	name := filepath.Join(m.Dir, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, []byte(res.Body), 0o644); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	m.files[m.normalize(page)] = p
+	if isHTML(typ) || typ == "text/css" {
+		m.convert = append(m.convert, mirrored{url: page, path: p, css: typ == "text/css"})
+	}
+	if page != res.URL {
+		m.aliases[m.normalize(res.URL)] = m.normalize(page)
+	}
+	return nil
+}
+
+// alias records that from redirected to the page at to
+func (m *Mirror) alias(from, to string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	m.aliases[m.normalize(from)] = m.normalize(to)
+}
+
+// init makes the maps of m, the caller holds m.mu
+func (m *Mirror) init() {
+	if m.files == nil {
+		m.files = make(map[string]string)
+		m.aliases = make(map[string]string)
+	}
+}
+
+// normalize returns u as the crawler has it
+func (m *Mirror) normalize(u string) string {
+	norm := m.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	if n, err := norm.Normalize(u); err == nil {
+		return n
+	}
+	return u
+}
+
+// Close rewrites the links of the HTML pages and stylesheets saved, once
+// the crawl is over. It returns the errors of the files it couldn't
+// rewrite, joined.
+func (m *Mirror) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, f := range m.convert {
+		if err := m.rewrite(f); err != nil {
+			errs = append(errs, fmt.Errorf("webcrawl: mirror %s: %w", f.path, err))
+		}
+	}
+	m.convert = nil
+	return errors.Join(errs...)
+}
+
+// rewrite rewrites the links of the file f
+func (m *Mirror) rewrite(f mirrored) error {
+	name := filepath.Join(m.Dir, filepath.FromSlash(f.path))
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(f.url)
+	if err != nil {
+		return err
+	}
+	if f.css {
+		return os.WriteFile(name, []byte(rewriteCSS(string(b), m.local(base, f.path))), 0o644)
+	}
+	doc, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	m.rewriteHTML(base, f.path, doc)
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return err
+	}
+	return os.WriteFile(name, out.Bytes(), 0o644)
+}
+
+// rewriteHTML rewrites the links of doc, found at base and saved at from.
+// Its <base> goes, the links being relative to the file, and its charset
+// becomes UTF-8, as the Fetchers transcode the pages to it
+func (m *Mirror) rewriteHTML(base *url.URL, from string, doc *html.Node) {
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+	local := m.local(base, from)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode && c.DataAtom == atom.Base {
+				n.RemoveChild(c)
+			} else {
+				walk(c)
+			}
+			c = next
+		}
+		if n.Type != html.ElementNode {
+			return
+		}
+		if n.DataAtom == atom.Style {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					c.Data = rewriteCSS(c.Data, local)
+				}
+			}
+		}
+		for i := range n.Attr {
+			a := &n.Attr[i]
+			if a.Namespace != "" {
+				continue
+			}
+			switch {
+			case a.Key == "style":
+				a.Val = rewriteCSS(a.Val, local)
+			case a.Key == "srcset":
+				a.Val = rewriteSrcset(a.Val, local)
+			case n.DataAtom == atom.Meta && a.Key == "charset":
+				a.Val = "utf-8"
+			case n.DataAtom == atom.Meta && a.Key == "content":
+				if v, _ := attr(n, "http-equiv"); strings.EqualFold(v, "content-type") {
+					a.Val = "text/html; charset=utf-8"
+				}
+			case urlAttr(n.Data, a.Key):
+				a.Val = local(a.Val)
+			}
+		}
+	}
+	walk(doc)
+}
+
+// urlAttr reports whether the attribute key of the element tag holds a URL
+// a Mirror rewrites
+func urlAttr(tag, key string) bool {
+	for _, attrs := range [][]string{assetAttrs[tag], mirrorAttrs[tag]} {
+		for _, a := range attrs {
+			if a == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// local returns the function rewriting a reference of the file saved at
+// from, found at base: to the relative path of its copy when the mirror
+// has one, else to its absolute URL. The caller holds m.mu
+func (m *Mirror) local(base *url.URL, from string) func(ref string) string {
+	return func(ref string) string {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || strings.HasPrefix(ref, "#") {
+			return ref
+		}
+		u, err := base.Parse(ref)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file" {
+			return ref
+		}
+		fragment := u.Fragment
+		u.Fragment, u.RawFragment = "", ""
+		key := m.normalize(u.String())
+		if to, ok := m.aliases[key]; ok {
+			key = to
+		}
+		p, ok := m.files[key]
+		if !ok {
+			u.Fragment = fragment
+			return u.String()
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(p))
+		if err != nil {
+			return ref
+		}
+		// As a URL, whose first segment is not taken for a scheme
+		return (&url.URL{Path: filepath.ToSlash(rel), Fragment: fragment}).String()
+	}
+}
+
+// rewriteSrcset returns the srcset value v with the URL of each of its
+// candidates replaced by what replace returns for it
+func rewriteSrcset(v string, replace func(ref string) string) string {
+	candidates := strings.Split(v, ",")
+	for i, c := range candidates {
+		if f := strings.Fields(c); len(f) > 0 {
+			f[0] = replace(f[0])
+			candidates[i] = strings.Join(f, " ")
+		}
+	}
+	return strings.Join(candidates, ", ")
+}
@@ -0,0 +1,246 @@
+package webcrawl
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Normalizer rewrites URLs into a canonical form, so that different
+// spellings of the same page are only crawled once. It always
+//
+//   - lowercases the scheme and the host,
+//   - removes the port when it is the scheme's default one,
+//   - resolves "." and ".." path segments, giving an empty path a "/",
+//   - strips the fragment, and an empty "?".
+//
+// Query parameters are left alone unless asked otherwise, since servers
+// are free to give them meaning in any order. Hosts may ask otherwise
+// than the rest, the tracking parameters of a shop being stripped while
+// those of a search engine are kept, say:
+//
+//	n := &webcrawl.Normalizer{
+//		SortQuery:   true,
+//		StripParams: webcrawl.TrackingParams,
+//		Hosts: map[string]*webcrawl.Normalizer{
+//			"shop.example.com": {StripParams: append([]string{"ref"}, webcrawl.TrackingParams...), SessionParams: webcrawl.SessionIDParams},
+//		},
+//	}
+type Normalizer struct {
+	// SortQuery orders the query parameters by name, keeping the
+	//   relative order of repeated parameters
+	SortQuery bool
+
+	// StripQuery drops the whole query string
+	StripQuery bool
+
+	// StripParams lists query parameters to remove, by exact name, a
+	//   trailing "*" matching any end: utm_* strips utm_source and
+	//   utm_medium
+	StripParams []string
+
+	// SessionParams lists the parameters holding session IDs, whatever
+	//   their case, so that the URLs of every session are one: they are
+	//   removed from the query, and from the path where servers such as
+	//   Tomcat put them, as in /cart;jsessionid=1F3A
+	SessionParams []string
+
+	// Hosts maps hostnames to the rules for the query of their URLs,
+	//   which replace SortQuery, StripQuery, StripParams and SessionParams
+	//   for them. Their own Hosts are ignored
+	Hosts map[string]*Normalizer
+}
+
+// TrackingParams are the query parameters of the usual campaign and click
+// trackers, which say nothing of the page, for Normalizer.StripParams
+var TrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "gclsrc", "dclid", "gbraid", "wbraid", "msclkid",
+	"yclid", "twclid", "igshid", "mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi",
+}
+
+// SessionIDParams are the usual names of session ID parameters, for
+// Normalizer.SessionParams
+var SessionIDParams = []string{
+	"jsessionid", "phpsessid", "aspsessionid", "sessionid", "session_id", "sid", "cfid", "cftoken",
+}
+
+// defaultPorts maps the schemes we know to the port they use by default
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// Normalize returns the canonical form of rawURL, which must be absolute.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	_, s, err := n.parse(rawURL)
+	return s, err
+}
+
+// parse is Normalize, returning the canonical form parsed too, for the
+// callers not to parse it again
+func (n *Normalizer) parse(rawURL string) (*url.URL, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if !u.IsAbs() {
+		return nil, "", &url.Error{Op: "normalize", URL: rawURL, Err: errNotAbsolute}
+	}
+	n.normalize(u)
+	return u, u.String(), nil
+}
+
+// errNotAbsolute is why relative URLs can't be normalized, there is
+// nothing to resolve them against
+var errNotAbsolute = errors.New("not an absolute URL")
+
+// normalize rewrites u in place
+func (n *Normalizer) normalize(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := u.Hostname(), u.Port()
+	host = strings.ToLower(host)
+	if strings.Contains(host, ":") {
+		// IPv6 literal
+		host = "[" + host + "]"
+	}
+	if port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+
+	rules := n
+	if h := n.Hosts[u.Hostname()]; h != nil {
+		rules = h
+	}
+	if u.Opaque == "" {
+		p := removeDotSegments(u.EscapedPath())
+		if len(rules.SessionParams) > 0 {
+			p = rules.stripPathSessions(p)
+		}
+		if p == "" && u.Host != "" {
+			p = "/"
+		}
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = unescaped, p
+		}
+	}
+
+	u.Fragment, u.RawFragment = "", ""
+	u.ForceQuery = false
+	switch {
+	case rules.StripQuery:
+		u.RawQuery = ""
+	case rules.SortQuery || len(rules.StripParams) > 0 || len(rules.SessionParams) > 0:
+		u.RawQuery = rules.rewriteQuery(u.RawQuery)
+	}
+}
+
+// stripped reports whether the query parameter name is to be removed
+func (n *Normalizer) stripped(name string) bool {
+	for _, p := range n.StripParams {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(name, prefix) || p == name {
+			return true
+		}
+	}
+	return n.session(name)
+}
+
+// session reports whether name is one of SessionParams
+func (n *Normalizer) session(name string) bool {
+	for _, p := range n.SessionParams {
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPathSessions removes the session parameters of the segments of the
+// escaped path p, ;jsessionid=1F3A and the like
+func (n *Normalizer) stripPathSessions(p string) string {
+	if !strings.Contains(p, ";") {
+		return p
+	}
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		name, params, ok := strings.Cut(seg, ";")
+		if !ok {
+			continue
+		}
+		kept := []string{name}
+		for _, param := range strings.Split(params, ";") {
+			if k, _, _ := strings.Cut(param, "="); !n.session(k) {
+				kept = append(kept, param)
+			}
+		}
+		segs[i] = strings.Join(kept, ";")
+	}
+	return strings.Join(segs, "/")
+}
+
+// rewriteQuery applies StripParams, SessionParams and SortQuery to a raw
+// query string, working on the raw pairs so that the encoding of the kept
+// ones is not altered
+func (n *Normalizer) rewriteQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type pair struct{ name, raw string }
+	var pairs []pair
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.stripped(name) {
+			continue
+		}
+		pairs = append(pairs, pair{name, raw})
+	}
+	if n.SortQuery {
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].name < pairs[j].name })
+	}
+	raws := make([]string, len(pairs))
+	for i, p := range pairs {
+		raws[i] = p.raw
+	}
+	return strings.Join(raws, "&")
+}
+
+// removeDotSegments implements the algorithm of RFC 3986 section 5.2.4
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	var out []string
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// Never pop the empty segment that stands for the leading "/"
+			if len(out) > 1 || len(out) == 1 && out[0] != "" {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	res := strings.Join(out, "/")
+	if strings.HasPrefix(p, "/") && !strings.HasPrefix(res, "/") {
+		res = "/" + res
+	}
+	return res
+}
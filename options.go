@@ -0,0 +1,181 @@
+package webcrawl
+
+import (
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxDepth is the MaxDepth of a Crawler made by NewCrawler without
+// WithDepth.
+const DefaultMaxDepth = 3
+
+// Option configures a Crawler made by NewCrawler.
+type Option func(*Crawler)
+
+// NewCrawler returns a Crawler set up by opts. Without options, it crawls
+// over HTTP with an HTTPFetcher using DefaultTimeout, DefaultMaxDepth
+// levels deep, with DefaultMaxWorkers workers, staying on the seed's host
+// and obeying robots.txt:
+//
+//	c := webcrawl.NewCrawler(
+//		webcrawl.WithDepth(2),
+//		webcrawl.WithConcurrency(4),
+//		webcrawl.WithRateLimit(webcrawl.NewHostLimiter(2, 1, 0)),
+//	)
+//	err := c.Run(ctx, "https://example.com/")
+//
+// The options only set fields of the Crawler, which remain open to change
+// until the first Run.
+func NewCrawler(opts ...Option) *Crawler {
+	c := &Crawler{
+		Fetcher:  NewHTTPFetcher(DefaultTimeout),
+		MaxDepth: DefaultMaxDepth,
+		Scope:    &ScopeRules{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithFetcher makes the Crawler fetch pages with f.
+func WithFetcher(f Fetcher) Option {
+	return func(c *Crawler) { c.Fetcher = f }
+}
+
+// WithMiddleware adds mw to the Middleware of the Crawler, inside those
+// given before.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Crawler) { c.Middleware = append(c.Middleware, mw...) }
+}
+
+// WithSchemeFetcher makes the Crawler fetch the URLs of scheme with f, see
+// Crawler.Fetchers.
+func WithSchemeFetcher(scheme string, f Fetcher) Option {
+	return func(c *Crawler) {
+		if c.Fetchers == nil {
+			c.Fetchers = make(map[string]Fetcher)
+		}
+		c.Fetchers[strings.ToLower(scheme)] = f
+	}
+}
+
+// WithDepth sets the MaxDepth of the Crawler.
+func WithDepth(depth int) Option {
+	return func(c *Crawler) { c.MaxDepth = depth }
+}
+
+// WithConcurrency sets how many pages the Crawler fetches at the same time,
+// its MaxWorkers.
+func WithConcurrency(workers int) Option {
+	return func(c *Crawler) { c.MaxWorkers = workers }
+}
+
+// WithScope sets the ScopeRules of the Crawler, nil follows every link.
+func WithScope(s *ScopeRules) Option {
+	return func(c *Crawler) { c.Scope = s }
+}
+
+// WithFilter makes the Crawler queue only the URLs f allows, see
+// Crawler.Filter.
+func WithFilter(f Filter) Option {
+	return func(c *Crawler) { c.Filter = f }
+}
+
+// WithRateLimit spaces out the requests of the Crawler to each host with l.
+func WithRateLimit(l *HostLimiter) Option {
+	return func(c *Crawler) { c.RateLimit = l }
+}
+
+// WithBackoff sets how the Crawler backs off the hosts answering 429 or
+// 503.
+func WithBackoff(p BackoffPolicy) Option {
+	return func(c *Crawler) { c.Backoff = &p }
+}
+
+// WithCircuitBreaker has the Crawler hold back, and in the end give up
+// on, the hosts that keep failing as b says.
+func WithCircuitBreaker(b CircuitBreaker) Option {
+	return func(c *Crawler) { c.Breaker = &b }
+}
+
+// WithAdaptiveConcurrency has the Crawler find how many pages to fetch at
+// the same time as a says.
+func WithAdaptiveConcurrency(a AdaptiveConcurrency) Option {
+	return func(c *Crawler) { c.Adaptive = &a }
+}
+
+// WithMaxPages sets the MaxPages budget of the Crawler.
+func WithMaxPages(n int) Option {
+	return func(c *Crawler) { c.MaxPages = n }
+}
+
+// WithOnResult makes the Crawler call f with the result of every fetch.
+func WithOnResult(f func(CrawlResult)) Option {
+	return func(c *Crawler) { c.OnResult = f }
+}
+
+// WithHooks makes the Crawler call h at every stage of the crawl of every
+// URL, see Hooks.
+func WithHooks(h *Hooks) Option {
+	return func(c *Crawler) { c.Hooks = h }
+}
+
+// WithRobots makes the Crawler use r for robots.txt.
+func WithRobots(r *Robots) Option {
+	return func(c *Crawler) { c.Robots = r }
+}
+
+// WithUserAgent makes the Crawler go by ua in robots.txt, and its
+// Fetcher send it when it is an HTTPFetcher, as it is by default. Give it
+// after WithFetcher.
+func WithUserAgent(ua string) Option {
+	return func(c *Crawler) {
+		c.UserAgent = ua
+		if hf, ok := c.Fetcher.(*HTTPFetcher); ok {
+			hf.UserAgent = ua
+		}
+	}
+}
+
+// WithoutRobots makes the Crawler ignore robots.txt.
+func WithoutRobots() Option {
+	return func(c *Crawler) { c.IgnoreRobots = true }
+}
+
+// WithNormalizer sets the Normalizer of the Crawler.
+func WithNormalizer(n *Normalizer) Option {
+	return func(c *Crawler) { c.Normalizer = n }
+}
+
+// WithFrontier sets the Frontier of the Crawler.
+func WithFrontier(f Frontier) Option {
+	return func(c *Crawler) { c.Frontier = f }
+}
+
+// WithVisited sets the VisitedSet of the Crawler.
+func WithVisited(v VisitedSet) Option {
+	return func(c *Crawler) { c.Visited = v }
+}
+
+// WithJournal makes the Crawler record its progress in j.
+func WithJournal(j *Journal) Option {
+	return func(c *Crawler) { c.Journal = j }
+}
+
+// WithMetrics makes the Crawler count what it does in m.
+func WithMetrics(m *Metrics) Option {
+	return func(c *Crawler) { c.Metrics = m }
+}
+
+// WithTracer makes the Crawler trace every page with t.
+func WithTracer(t trace.Tracer) Option {
+	return func(c *Crawler) { c.Tracer = t }
+}
+
+// WithLogger makes the Crawler log its events to l.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Crawler) { c.Logger = l }
+}
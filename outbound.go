@@ -0,0 +1,110 @@
+package webcrawl
+
+import (
+	"encoding/csv"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// OutboundReport takes the inventory of the links leaving the scope of a
+// crawl, and writes it out as CSV, one row per link, sorted by target:
+//
+//	target,status,source,anchor_text,nofollow
+//	https://github.com/example,200,https://example.com/about,Our code,
+//
+// The crawl doesn't go to the targets: status is empty unless it checked
+// them, see Crawler.CheckExternal, which fetches each of them once. It is
+// then as in a LinkReport.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance, and
+// write it with WriteCSV once the crawl is over. An OutboundReport is safe
+// for concurrent use.
+type OutboundReport struct {
+	// Seed and Scope must be the Crawler's, for the report to tell the
+	//   links leaving the scope from the others. When Scope is nil, those
+	//   off the host of Seed leave it
+	Seed  string
+	Scope *ScopeRules
+
+	// Normalizer and Links are as in a LinkReport
+	Normalizer *Normalizer
+	Links      *LinkExtractor
+
+	mu     sync.Mutex
+	seed   *url.URL
+	rows   []linkRow
+	status map[string]string // normalized URL => how its check went
+}
+
+// Add records the links of a page that leave the scope, or how the check
+// of one went.
+func (r *OutboundReport) Add(res CrawlResult) {
+	links := &LinkReport{Normalizer: r.Normalizer, Links: r.Links}
+	var rows []linkRow
+	if !res.External && !res.Asset && res.Err == nil && len(res.Links)+len(res.NoFollowLinks) > 0 {
+		for _, row := range links.extract(res) {
+			if r.outbound(row.target) {
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if res.External {
+		if r.status == nil {
+			r.status = make(map[string]string)
+		}
+		r.status[links.normalize(res.URL)] = fetchStatus(res)
+	}
+	r.rows = append(r.rows, rows...)
+}
+
+// outbound reports whether the link to target leaves the scope
+func (r *OutboundReport) outbound(target string) bool {
+	r.mu.Lock()
+	if r.seed == nil {
+		r.seed, _ = url.Parse(r.Seed)
+		if r.seed == nil {
+			r.seed = &url.URL{}
+		}
+	}
+	seed := r.seed
+	r.mu.Unlock()
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if r.Scope == nil {
+		return u.Hostname() != seed.Hostname()
+	}
+	return !r.Scope.InScope(seed, u)
+}
+
+// WriteCSV writes the links recorded so far to w, with a header line, by
+// target and source.
+func (r *OutboundReport) WriteCSV(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	links := &LinkReport{Normalizer: r.Normalizer}
+	rows := append([]linkRow(nil), r.rows...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].target != rows[j].target {
+			return rows[i].target < rows[j].target
+		}
+		return rows[i].source < rows[j].source
+	})
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"target", "status", "source", "anchor_text", "nofollow"})
+	for _, row := range rows {
+		nofollow := ""
+		if row.nofollow {
+			nofollow = "true"
+		}
+		cw.Write([]string{row.target, r.status[links.normalize(row.target)], row.source, row.text, nofollow})
+	}
+	cw.Flush()
+	return cw.Error()
+}
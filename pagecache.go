@@ -0,0 +1,187 @@
+package webcrawl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotCached is returned by a PageCache fetching a URL it holds no page
+// of.
+var ErrNotCached = errors.New("webcrawl: not in the page cache")
+
+// PageCache keeps the pages fetched on disk, bodies and all, by URL: a
+// file per page in a directory, JSON, named after the SHA-256 of the URL.
+// PageCacheMiddleware fills it as a crawl goes and has the pages that
+// didn't change since answered from it, re-parsed rather than fetched
+// again, and a PageCache is a Fetcher itself, answering from the cache
+// alone for a crawl offline: the extraction and reports of a crawl run
+// again, with other settings, without a request.
+//
+// A PageCache is safe for concurrent use, by several processes too: a page
+// is written to a temporary file first, then renamed.
+type PageCache struct {
+	dir string
+}
+
+// pageCacheEntry is the file of a page
+type pageCacheEntry struct {
+	URL  string    `json:"url"`
+	Resp *Response `json:"response"`
+	At   time.Time `json:"cached_at"`
+
+	// Body64 is the body when it is not UTF-8, such as a PDF, which JSON
+	//   strings can't hold; Resp.Body is empty then
+	Body64 []byte `json:"body_base64,omitempty"`
+}
+
+// OpenPageCache returns the PageCache in dir, creating it if need be.
+func OpenPageCache(dir string) (*PageCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &PageCache{dir: dir}, nil
+}
+
+// path returns the file of the page of url
+func (c *PageCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name+".json")
+}
+
+// Get returns the page cached for url, a copy for the caller to do as it
+// likes with.
+func (c *PageCache) Get(url string) (*Response, bool) {
+	b, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil, false
+	}
+	var e pageCacheEntry
+	if err := json.Unmarshal(b, &e); err != nil || e.Resp == nil || e.URL != url {
+		return nil, false
+	}
+	if e.Body64 != nil {
+		e.Resp.Body = string(e.Body64)
+	}
+	return e.Resp, true
+}
+
+// Put caches resp as the page of url.
+func (c *PageCache) Put(url string, resp *Response) error {
+	e := pageCacheEntry{URL: url, Resp: resp, At: time.Now().UTC()}
+	if !utf8.ValidString(resp.Body) {
+		kept := *resp
+		kept.Body = ""
+		e.Resp, e.Body64 = &kept, []byte(resp.Body)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	path := c.path(url)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".page-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Len returns how many pages c holds.
+func (c *PageCache) Len() int {
+	n := 0
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".json") {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+// Fetch implements Fetcher, from the cache alone.
+func (c *PageCache) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := c.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements ResponseFetcher, from the cache alone: the
+// pages it doesn't hold fail with ErrNotCached.
+func (c *PageCache) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp, ok := c.Get(url)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, url)
+	}
+	return resp, nil
+}
+
+// Check implements Checker, from the cache alone.
+func (c *PageCache) Check(ctx context.Context, url string) error {
+	_, err := c.FetchResponse(ctx, url)
+	return err
+}
+
+// PageCacheMiddleware caches the pages fetched in c, and asks again for
+// those it holds with their ETag and Last-Modified, see
+// WithRequestHeader: a page the server says did not change is answered
+// from c, with its body and NotModified set, for the Crawler to parse it
+// again. The checks go through as they are.
+func PageCacheMiddleware(c *PageCache) Middleware {
+	return Around(func(ctx context.Context, call FetchCall) (*Response, error) {
+		if call.Check {
+			return call.Next(ctx)
+		}
+		cached, ok := c.Get(call.URL)
+		if ok {
+			h := make(http.Header)
+			if etag := cached.Header.Get("ETag"); etag != "" {
+				h.Set("If-None-Match", etag)
+			}
+			if lm := cached.Header.Get("Last-Modified"); lm != "" {
+				h.Set("If-Modified-Since", lm)
+			}
+			if len(h) > 0 {
+				ctx = WithRequestHeader(ctx, h)
+			}
+		}
+		resp, err := call.Next(ctx)
+		var se *StatusError
+		switch {
+		case ok && (resp != nil && resp.NotModified || errors.As(err, &se) && se.StatusCode == http.StatusNotModified):
+			cached.NotModified = true
+			return cached, nil
+		case err == nil && resp != nil && resp.Skipped == "":
+			if perr := c.Put(call.URL, resp); perr != nil {
+				return resp, perr
+			}
+		}
+		return resp, err
+	})
+}
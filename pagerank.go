@@ -0,0 +1,139 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// PageRank parameters, as in the original paper
+const (
+	rankDamping    = 0.85
+	rankIterations = 100
+	rankEpsilon    = 1e-9
+)
+
+// PageStats is what the link graph tells of a page of the crawl, see
+// LinkGraph.Ranks.
+type PageStats struct {
+	URL string
+	// Rank is the PageRank of the page, over the links robots may follow
+	//   and the redirects, all of them summing to 1
+	Rank float64
+	// InLinks and OutLinks count the other pages linking to, and linked to
+	//   from, the page, by either kind of link
+	InLinks, OutLinks int
+	// Orphan reports whether no other page links or redirects to it, as
+	//   is the case of the pages known from a sitemap only
+	Orphan bool
+}
+
+// Ranks returns the stats of the pages of the crawl, the URLs that were
+// fetched, but not those only linked to, by decreasing rank.
+//
+// The rank a page passes on is split between the links it may follow, a
+// redirect passing it on whole. The rank of the pages leading nowhere, the
+// URLs linked to but not fetched among them, is spread over the whole
+// graph, so that every link out of the crawl leaks some.
+func (g *LinkGraph) Ranks() []PageStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	urls := g.sorted()
+	index := make(map[string]int, len(urls))
+	for i, u := range urls {
+		index[u] = i
+	}
+
+	stats := make([]PageStats, len(urls))
+	follow := make([][]int, len(urls)) // node => the nodes it passes its rank to
+	redirected := make([]bool, len(urls))
+	for i, u := range urls {
+		stats[i].URL = u
+		targets, kinds := g.edges(u)
+		for k, t := range targets {
+			j := index[t]
+			if j == i {
+				continue
+			}
+			switch kinds[k] {
+			case EdgeRedirect:
+				redirected[j] = true
+				follow[i] = append(follow[i], j)
+				continue
+			case EdgeLink:
+				follow[i] = append(follow[i], j)
+			}
+			stats[i].OutLinks++
+			stats[j].InLinks++
+		}
+	}
+
+	rank := pageRank(follow)
+	var pages []PageStats
+	for i, u := range urls {
+		if g.nodes[u].status == "" {
+			continue
+		}
+		stats[i].Rank = rank[i]
+		stats[i].Orphan = stats[i].InLinks == 0 && !redirected[i]
+		pages = append(pages, stats[i])
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Rank > pages[j].Rank })
+	return pages
+}
+
+// pageRank returns the PageRank of the nodes of the graph where node i
+// passes its rank on to the nodes of follow[i], by power iteration
+func pageRank(follow [][]int) []float64 {
+	n := float64(len(follow))
+	rank := make([]float64, len(follow))
+	for i := range rank {
+		rank[i] = 1 / n
+	}
+	next := make([]float64, len(follow))
+	for it := 0; it < rankIterations; it++ {
+		dangling := 0.0
+		for i, out := range follow {
+			if len(out) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-rankDamping)/n + rankDamping*dangling/n
+		for i := range next {
+			next[i] = base
+		}
+		for i, out := range follow {
+			for _, j := range out {
+				next[j] += rankDamping * rank[i] / float64(len(out))
+			}
+		}
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < rankEpsilon {
+			break
+		}
+	}
+	return rank
+}
+
+// WriteRanks writes the stats of the pages, as Ranks returns them, to w:
+// the rank, the in-links and the out-links of each page, orphans flagged:
+//
+//	0.2316	12	8	https://example.com/
+//	0.0098	0	3	https://example.com/old-promo	orphan
+func (g *LinkGraph) WriteRanks(w io.Writer) error {
+	for _, p := range g.Ranks() {
+		orphan := ""
+		if p.Orphan {
+			orphan = "\torphan"
+		}
+		if _, err := fmt.Fprintf(w, "%.4f\t%d\t%d\t%s%s\n", p.Rank, p.InLinks, p.OutLinks, p.URL, orphan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
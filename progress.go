@@ -0,0 +1,269 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultProgressErrors is how many of the latest errors a Progress keeps
+// when Errors is not set
+const DefaultProgressErrors = 5
+
+// DefaultRateWindow is how far back a Progress looks for the pages per
+// second when Window is not set
+const DefaultRateWindow = 10 * time.Second
+
+// Progress follows a crawl as it goes, for a live view of it: how many
+// pages were fetched and how fast, what is left in the frontier and for
+// which hosts, the latest errors, and when the crawl should be over.
+//
+//	p := &webcrawl.Progress{}
+//	c := &webcrawl.Crawler{Fetcher: f, MaxDepth: 3, Progress: p}
+//
+// Call Stats or WriteText as often as need be while the crawl runs. A
+// Progress is safe for concurrent use, its zero value is ready to use. It
+// follows one crawl at a time.
+type Progress struct {
+	// Errors is how many of the latest errors Stats has at most,
+	//   DefaultProgressErrors when zero
+	Errors int
+
+	// Window is how far back the pages per second are counted,
+	//   DefaultRateWindow when zero
+	Window time.Duration
+
+	mu       sync.Mutex
+	started  time.Time
+	budget   int // MaxPages of the crawl, 0 for none
+	fetched  int
+	failed   int
+	inFlight int
+	hosts    map[string]*HostQueue
+	done     []time.Time     // when the fetches of the window finished
+	errors   []ProgressError // the latest last
+}
+
+// ProgressStats is where a crawl stands, as Progress.Stats sees it.
+type ProgressStats struct {
+	Elapsed  time.Duration
+	Fetched  int // failed or not
+	Failed   int
+	InFlight int
+	Frontier int     // URLs waiting to be fetched, held for their host too
+	Rate     float64 // pages per second over the Window
+
+	// ETA is how long the crawl should take still, at Rate, to fetch the
+	//   frontier or what is left of MaxPages, whichever is less. The
+	//   frontier grows as pages are crawled, so it is a lower bound. Zero
+	//   while the rate is unknown
+	ETA time.Duration
+
+	// Hosts are the hosts with URLs waiting or fetches under way, the
+	//   longest queue first
+	Hosts []HostQueue
+
+	// Errors are the latest fetches that failed, the latest first
+	Errors []ProgressError
+}
+
+// HostQueue is what is left to do for a host.
+type HostQueue struct {
+	Host     string
+	Queued   int
+	InFlight int
+}
+
+// ProgressError is a fetch that failed.
+type ProgressError struct {
+	URL   string
+	Depth int
+	Err   error
+	At    time.Time
+}
+
+// begin starts following the crawl r, from where its frontier stands
+func (p *Progress) begin(r *run) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started = r.started
+	p.budget = r.MaxPages
+	p.fetched, p.failed, p.inFlight = 0, 0, 0
+	p.hosts = make(map[string]*HostQueue)
+	p.done, p.errors = nil, nil
+}
+
+// host returns the queue of host, creating it as need be
+func (p *Progress) host(host string) *HostQueue {
+	q, ok := p.hosts[host]
+	if !ok {
+		q = &HostQueue{Host: host}
+		p.hosts[host] = q
+	}
+	return q
+}
+
+// queue moves the queue of the host of rawURL by n
+func (p *Progress) queue(rawURL string, n int) {
+	p.mu.Lock()
+	p.host(hostname(rawURL)).Queued += n
+	p.mu.Unlock()
+}
+
+// startedFetch counts the fetch of it under way
+func (p *Progress) startedFetch(it FrontierItem) {
+	p.mu.Lock()
+	p.inFlight++
+	p.host(hostname(it.URL)).InFlight++
+	p.mu.Unlock()
+}
+
+// finishedFetch records the result of the fetch of it. One that is not
+// over, cut short or turned away by its host, only leaves the in-flight
+// ones
+func (p *Progress) finishedFetch(it FrontierItem, res CrawlResult, over bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight--
+	p.host(hostname(it.URL)).InFlight--
+	if !over {
+		return
+	}
+	now := time.Now()
+	p.fetched++
+	p.done = append(p.done, now)
+	if res.Err == nil {
+		return
+	}
+	p.failed++
+	p.errors = append(p.errors, ProgressError{URL: res.URL, Depth: res.Depth, Err: res.Err, At: now})
+	if n := p.maxErrors(); len(p.errors) > n {
+		p.errors = append(p.errors[:0], p.errors[len(p.errors)-n:]...)
+	}
+}
+
+func (p *Progress) maxErrors() int {
+	if p.Errors <= 0 {
+		return DefaultProgressErrors
+	}
+	return p.Errors
+}
+
+func (p *Progress) window() time.Duration {
+	if p.Window <= 0 {
+		return DefaultRateWindow
+	}
+	return p.Window
+}
+
+// Stats returns where the crawl stands.
+func (p *Progress) Stats() ProgressStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	s := ProgressStats{Fetched: p.fetched, Failed: p.failed, InFlight: p.inFlight}
+	if p.started.IsZero() {
+		return s
+	}
+	s.Elapsed = now.Sub(p.started)
+
+	// Forget the fetches out of the window, and count the rest over the
+	//   window or the crawl so far if shorter
+	window := p.window()
+	i := sort.Search(len(p.done), func(i int) bool { return now.Sub(p.done[i]) < window })
+	p.done = append(p.done[:0], p.done[i:]...)
+	if span := min(window, s.Elapsed); span > 0 {
+		s.Rate = float64(len(p.done)) / span.Seconds()
+	}
+
+	for _, q := range p.hosts {
+		if q.Queued == 0 && q.InFlight == 0 {
+			continue
+		}
+		s.Frontier += q.Queued
+		s.Hosts = append(s.Hosts, *q)
+	}
+	sort.Slice(s.Hosts, func(i, j int) bool {
+		a, b := s.Hosts[i], s.Hosts[j]
+		if a.Queued != b.Queued {
+			return a.Queued > b.Queued
+		}
+		return a.Host < b.Host
+	})
+
+	left := s.Frontier + s.InFlight
+	if p.budget > 0 {
+		left = min(left, max(p.budget-s.Fetched, 0))
+	}
+	if s.Rate > 0 {
+		s.ETA = time.Duration(float64(left) / s.Rate * float64(time.Second))
+	}
+
+	for i := len(p.errors) - 1; i >= 0; i-- {
+		s.Errors = append(s.Errors, p.errors[i])
+	}
+	return s
+}
+
+// WriteText writes where the crawl stands to w, the hosts with the longest
+// queues, at most hosts of them, and the latest errors:
+//
+//	elapsed 1m4s, eta 2m10s
+//	pages   312 fetched, 4 failed, 8 in flight, 5.2/s
+//	queued  688
+//	hosts   example.com 640 queued, 6 in flight
+//	        cdn.example.com 48 queued, 2 in flight
+//	errors  https://example.com/old: not found
+func (p *Progress) WriteText(w io.Writer, hosts int) error {
+	s := p.Stats()
+	eta := "unknown"
+	if s.Rate > 0 {
+		eta = s.ETA.Round(time.Second).String()
+	}
+	lines := []string{
+		fmt.Sprintf("elapsed %v, eta %s", s.Elapsed.Round(time.Second), eta),
+		fmt.Sprintf("pages   %d fetched, %d failed, %d in flight, %.1f/s", s.Fetched, s.Failed, s.InFlight, s.Rate),
+		fmt.Sprintf("queued  %d", s.Frontier),
+	}
+	label := "hosts   "
+	for i, q := range s.Hosts {
+		if i == hosts {
+			lines = append(lines, fmt.Sprintf("%s%d more", label, len(s.Hosts)-hosts))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s%s %d queued, %d in flight", label, q.Host, q.Queued, q.InFlight))
+		label = "        "
+	}
+	label = "errors  "
+	for _, e := range s.Errors {
+		lines = append(lines, fmt.Sprintf("%s%s: %v", label, e.URL, Classify(e.Err)))
+		label = "        "
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressFrontier counts the URLs of a frontier by host for a Progress
+type progressFrontier struct {
+	Frontier
+	p *Progress
+}
+
+func (f progressFrontier) Push(it FrontierItem) {
+	f.Frontier.Push(it)
+	f.p.queue(it.URL, 1)
+}
+
+func (f progressFrontier) Pop() (FrontierItem, bool) {
+	it, ok := f.Frontier.Pop()
+	if ok {
+		f.p.queue(it.URL, -1)
+	}
+	return it, ok
+}
@@ -0,0 +1,77 @@
+package webcrawl
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+)
+
+// disableHTTP2 has t speak HTTP/1.1 only, even to the servers that offer
+// HTTP/2
+func disableHTTP2(t *http.Transport) {
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	// A clone of a transport that spoke HTTP/2 still offers it
+	if c := t.TLSClientConfig; c != nil {
+		protos := make([]string, 0, len(c.NextProtos))
+		for _, p := range c.NextProtos {
+			if p != "h2" {
+				protos = append(protos, p)
+			}
+		}
+		c.NextProtos = protos
+	}
+}
+
+// protocolTransport picks the protocol of every request: HTTP/1.1 for the
+// hosts in http1Hosts, HTTP/3 through h3 for the others when it is set,
+// and tcp, HTTP/2 where the server offers it, otherwise. A host h3 fails
+// to fetch from is fetched from over tcp from then on, as a server that
+// doesn't speak HTTP/3 or a network that blocks UDP
+type protocolTransport struct {
+	tcp, http1, h3 http.RoundTripper
+	http1Hosts     map[string]bool
+
+	mu   sync.Mutex
+	noH3 map[string]bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *protocolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if t.http1Hosts[host] {
+		return t.http1.RoundTrip(req)
+	}
+	if t.h3 == nil || req.URL.Scheme != "https" || t.failedH3(host) {
+		return t.tcp.RoundTrip(req)
+	}
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		// Sent, or some of it, it can't go again
+		if req.GetBody == nil {
+			return resp, err
+		}
+		body, berr := req.GetBody()
+		if berr != nil {
+			return resp, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	t.mu.Lock()
+	if t.noH3 == nil {
+		t.noH3 = make(map[string]bool)
+	}
+	t.noH3[host] = true
+	t.mu.Unlock()
+	return t.tcp.RoundTrip(req)
+}
+
+func (t *protocolTransport) failedH3(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.noH3[host]
+}
@@ -0,0 +1,75 @@
+package webcrawl_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// proto writes the protocol of the request
+var proto = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprintf(w, "<p>%s</p>", req.Proto)
+})
+
+func TestHTTP3(t *testing.T) {
+	srv := httptest.NewUnstartedServer(proto)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	// The same port over UDP, with the same certificate
+	conn, err := net.ListenPacket("udp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Skip("no UDP:", err)
+	}
+	h3 := &http3.Server{Handler: proto, TLSConfig: http3.ConfigureTLSConfig(srv.TLS)}
+	go h3.Serve(conn)
+	defer h3.Close()
+
+	f, err := webcrawl.NewHTTPFetcherOptions(webcrawl.HTTPOptions{Timeout: 5 * time.Second, HTTP3: true, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := f.FetchResponse(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Protocol != "HTTP/3.0" || resp.Body != "<p>HTTP/3.0</p>" {
+		t.Errorf("fetched %s, %s, want HTTP/3.0", resp.Protocol, resp.Body)
+	}
+}
+
+func TestHTTP3Fallback(t *testing.T) {
+	srv := httptest.NewUnstartedServer(proto)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	f, err := webcrawl.NewHTTPFetcherOptions(webcrawl.HTTPOptions{Timeout: 5 * time.Second, ConnectTimeout: 200 * time.Millisecond,
+		HTTP3: true, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing on UDP, the first goes over TCP once QUIC gives up, the next
+	// straight away
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		resp, err := f.FetchResponse(context.Background(), srv.URL+"/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Protocol != "HTTP/2.0" {
+			t.Errorf("fetch %d over %s, want HTTP/2.0", i, resp.Protocol)
+		}
+		if i == 1 && time.Since(start) > 150*time.Millisecond {
+			t.Errorf("fetch %d took %s, want no HTTP/3 tried again", i, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ProxyProvider picks the proxy of each request, see ProxyPool.
+type ProxyProvider interface {
+	// Proxy returns the proxy req is to go through, nil for none
+	Proxy(req *http.Request) (*url.URL, error)
+
+	// Report tells how a request through proxy went, err being nil when
+	//   the proxy did its job
+	Report(proxy *url.URL, err error)
+}
+
+// ProxyTransport is an http.RoundTripper sending each request through the
+// proxy its ProxyProvider picks, and reporting back how it went. A transport
+// error or a 407 answer counts against the proxy, any other answer from
+// the server for it.
+type ProxyTransport struct {
+	t       *http.Transport
+	proxies ProxyProvider
+}
+
+// NewProxyTransport returns a ProxyTransport making the requests with a
+// copy of t, or of http.DefaultTransport when t is nil, the Proxy of
+// which is left out.
+func NewProxyTransport(t *http.Transport, proxies ProxyProvider) *ProxyTransport {
+	if t == nil {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, _ := req.Context().Value(proxyKey{}).(*url.URL)
+		return u, nil
+	}
+	return &ProxyTransport{t: t, proxies: proxies}
+}
+
+// proxyKey is the context key of the proxy picked for a request
+type proxyKey struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxy, err := t.proxies.Proxy(req)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(context.WithValue(req.Context(), proxyKey{}, proxy))
+	resp, err := t.t.RoundTrip(req)
+	if proxy == nil {
+		return resp, err
+	}
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// Called off, the proxy is not to blame
+	case err != nil:
+		t.proxies.Report(proxy, err)
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		t.proxies.Report(proxy, fmt.Errorf("proxy %s: %s", proxy.Redacted(), resp.Status))
+	default:
+		t.proxies.Report(proxy, nil)
+	}
+	return resp, err
+}
+
+// ProxyRotation is how a ProxyPool picks a proxy.
+type ProxyRotation int
+
+const (
+	RoundRobin    ProxyRotation = iota // each proxy in turn
+	RandomProxy                        // any proxy, at random
+	StickyPerHost                      // the same proxy for every request to a host, for as long as it works
+)
+
+// Defaults of ProxyPool.
+const (
+	DefaultProxyMaxFailures = 3
+	DefaultProxyQuarantine  = time.Minute
+)
+
+// ErrNoProxy is the error of the requests a ProxyPool has no proxy for,
+// every one of them being in quarantine.
+var ErrNoProxy = errors.New("webcrawl: every proxy is in quarantine")
+
+// ProxyPool is a ProxyProvider rotating through a list of proxies. A
+// proxy that fails MaxFailures times in a row is put in quarantine, and
+// gets no request, for the Quarantine period. Make one with NewProxyPool,
+// it is safe for concurrent use.
+type ProxyPool struct {
+	Rotation ProxyRotation
+
+	// MaxFailures is how many failures in a row put a proxy in
+	//   quarantine, DefaultProxyMaxFailures when zero. Quarantine is for
+	//   how long, DefaultProxyQuarantine when zero
+	MaxFailures int
+	Quarantine  time.Duration
+
+	mu      sync.Mutex
+	proxies []*pooledProxy
+	next    int            // the proxy RoundRobin picks next
+	hosts   map[string]int // host => index of its proxy, for StickyPerHost
+}
+
+type pooledProxy struct {
+	url      *url.URL
+	failures int       // in a row
+	until    time.Time // out of quarantine
+}
+
+// NewProxyPool returns a ProxyPool of the proxies, which are URLs as for
+// HTTPOptions.Proxy.
+func NewProxyPool(rotation ProxyRotation, proxies ...string) (*ProxyPool, error) {
+	if len(proxies) == 0 {
+		return nil, errors.New("webcrawl: a proxy pool needs proxies")
+	}
+	p := &ProxyPool{Rotation: rotation, hosts: make(map[string]int)}
+	for _, s := range proxies {
+		u, err := parseProxy(s)
+		if err != nil {
+			return nil, err
+		}
+		p.proxies = append(p.proxies, &pooledProxy{url: u})
+	}
+	return p, nil
+}
+
+// parseProxy parses the URL of a proxy, which must be http, https or
+// socks5
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("webcrawl: proxy %s: want an http, https or socks5 URL", s)
+	}
+	return u, nil
+}
+
+// Proxy implements ProxyProvider, it fails with ErrNoProxy when every
+// proxy is in quarantine.
+func (p *ProxyPool) Proxy(req *http.Request) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	ok := func(i int) bool { return !now.Before(p.proxies[i].until) }
+
+	host := req.URL.Host
+	if p.Rotation == StickyPerHost {
+		if i, found := p.hosts[host]; found && ok(i) {
+			return p.proxies[i].url, nil
+		}
+	}
+	var healthy []int
+	for i := range p.proxies {
+		if ok(i) {
+			healthy = append(healthy, i)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, ErrNoProxy
+	}
+	var i int
+	switch p.Rotation {
+	case RandomProxy:
+		i = healthy[rand.Intn(len(healthy))]
+	default:
+		// The next healthy one from where we are
+		i = healthy[0]
+		for _, h := range healthy {
+			if h >= p.next {
+				i = h
+				break
+			}
+		}
+		p.next = i + 1
+	}
+	if p.Rotation == StickyPerHost {
+		p.hosts[host] = i
+	}
+	return p.proxies[i].url, nil
+}
+
+// Report implements ProxyProvider.
+func (p *ProxyPool) Report(proxy *url.URL, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pp := range p.proxies {
+		if pp.url != proxy {
+			continue
+		}
+		if err == nil {
+			pp.failures = 0
+			return
+		}
+		pp.failures++
+		max := p.MaxFailures
+		if max <= 0 {
+			max = DefaultProxyMaxFailures
+		}
+		if pp.failures >= max {
+			d := p.Quarantine
+			if d <= 0 {
+				d = DefaultProxyQuarantine
+			}
+			pp.until, pp.failures = time.Now().Add(d), 0
+		}
+		return
+	}
+}
+
+// Quarantined returns the proxies in quarantine.
+func (p *ProxyPool) Quarantined() []*url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var q []*url.URL
+	for _, pp := range p.proxies {
+		if now.Before(pp.until) {
+			q = append(q, pp.url)
+		}
+	}
+	return q
+}
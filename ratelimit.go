@@ -0,0 +1,126 @@
+package webcrawl
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HostLimiter spaces out the requests made to each host, so that however
+// many workers the Crawler runs, no host sees more than its share. Each
+// host gets its own token bucket refilled at RequestsPerSecond, and on top
+// of that consecutive requests to a host start at least MinDelay apart.
+//
+// A HostLimiter is safe for concurrent use, its zero value does not limit
+// anything.
+type HostLimiter struct {
+	// RequestsPerSecond is the rate each host's bucket refills at, zero
+	//   or less means no rate limit
+	RequestsPerSecond float64
+
+	// Burst is how many requests a host may receive back to back before
+	//   the rate kicks in, less than one means one
+	Burst int
+
+	// MinDelay is the minimum time between the start of two requests to
+	//   the same host
+	MinDelay time.Duration
+
+	// Hosts have the rates of the hosts that get their own, by hostname,
+	//   in place of the ones above
+	Hosts map[string]HostRate
+
+	mu    sync.Mutex
+	hosts map[string]*bucket // hostname => its bucket
+}
+
+// HostRate is how a HostLimiter spaces out the requests to a host, see
+// HostLimiter.Hosts.
+type HostRate struct {
+	RequestsPerSecond float64
+	Burst             int
+	MinDelay          time.Duration
+}
+
+// bucket is the state the HostLimiter keeps per host
+type bucket struct {
+	tokens float64   // may go negative, that's the backlog of reservations
+	last   time.Time // when tokens was last brought up to date
+	next   time.Time // earliest start of the next request, for MinDelay
+}
+
+// NewHostLimiter returns a HostLimiter allowing rps requests per second,
+// in bursts of up to burst requests, at least minDelay apart, per host.
+func NewHostLimiter(rps float64, burst int, minDelay time.Duration) *HostLimiter {
+	return &HostLimiter{RequestsPerSecond: rps, Burst: burst, MinDelay: minDelay}
+}
+
+// Wait blocks until a request to host may be made, or ctx is done. The
+// turn of a Wait interrupted by ctx is lost, it is not handed to the next
+// caller.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	d := l.reserve(host, time.Now())
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitURL is Wait for the host of rawURL.
+func (l *HostLimiter) WaitURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return l.Wait(ctx, u.Hostname())
+}
+
+// reserve books the next request to host and returns how long after now
+// it may start
+func (l *HostLimiter) reserve(host string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rate := HostRate{l.RequestsPerSecond, l.Burst, l.MinDelay}
+	if hr, ok := l.Hosts[host]; ok {
+		rate = hr
+	}
+	burst := float64(rate.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if l.hosts == nil {
+		l.hosts = make(map[string]*bucket)
+	}
+	b, ok := l.hosts[host]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.hosts[host] = b
+	}
+
+	at := now
+	if rps := rate.RequestsPerSecond; rps > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * rps
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+		b.tokens--
+		if b.tokens < 0 {
+			at = now.Add(time.Duration(-b.tokens / rps * float64(time.Second)))
+		}
+	}
+	if at.Before(b.next) {
+		at = b.next
+	}
+	b.next = at.Add(rate.MinDelay)
+	return at.Sub(now)
+}
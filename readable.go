@@ -0,0 +1,208 @@
+package webcrawl
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The class and id names of boilerplate, and of content, as Readability
+// goes by them
+var (
+	boilerplateNames = regexp.MustCompile(`(?i)\b(nav|navbar|menu|footer|header|masthead|sidebar|aside|` +
+		`ad|ads|advert|banner|promo|sponsor|share|social|cookie|consent|comment|comments|related|` +
+		`breadcrumbs?|pagination|popup|modal|newsletter|subscribe)\b`)
+	contentNames = regexp.MustCompile(`(?i)\b(article|content|main|post|entry|story|body|text)\b`)
+)
+
+// ReadableText returns the main content of the HTML document doc as plain
+// text, the navigation, headers, footers, sidebars, ads and the like left
+// out, as reader modes do. The blocks of the text, such as paragraphs and
+// headings, are on lines of their own, with their runs of spaces
+// collapsed.
+//
+// The content is that of the <article> or <main> of the page if it has
+// one, else the element whose paragraphs have the most text that isn't
+// links.
+func ReadableText(doc *html.Node) string {
+	root := mainContent(doc)
+	if root == nil {
+		return ""
+	}
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if s := strings.Join(strings.Fields(line.String()), " "); s != "" {
+			lines = append(lines, s)
+		}
+		line.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			line.WriteString(n.Data)
+			return
+		case n.Type == html.ElementNode && n != root && boilerplate(n):
+			return
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			flush()
+			return
+		}
+		block := n.Type == html.ElementNode && blockElement(n.DataAtom)
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(root)
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// WordCount returns the number of words of text, as strings.Fields splits
+// them.
+func WordCount(text string) int {
+	return len(strings.Fields(text))
+}
+
+// mainContent returns the element of doc holding its main content, nil
+// when it has no <body>
+func mainContent(doc *html.Node) *html.Node {
+	var body, marked *html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Body:
+				body = n
+			case atom.Article, atom.Main:
+				if marked == nil || n.DataAtom == atom.Main && marked.DataAtom != atom.Main {
+					marked = n
+				}
+			}
+			if boilerplate(n) {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if marked != nil {
+		return marked
+	}
+	if body == nil {
+		return nil
+	}
+
+	// As Readability does: every paragraph scores for its parent, and
+	//   half as much for its grandparent
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node // the nodes scored, in document order
+	add := func(n *html.Node, s float64) {
+		if _, ok := scores[n]; !ok {
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+	var score func(n *html.Node)
+	score = func(n *html.Node) {
+		if n.Type != html.ElementNode || boilerplate(n) {
+			return
+		}
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Blockquote, atom.Td:
+			text := strings.Join(strings.Fields(nodeText(n)), " ")
+			if len(text) >= 25 {
+				s := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+				s *= 1 - linkDensity(n, len(text))
+				if p := n.Parent; p != nil {
+					add(p, s)
+					if g := p.Parent; g != nil {
+						add(g, s/2)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			score(c)
+		}
+	}
+	score(body)
+	best, top := body, 0.0
+	for _, n := range order {
+		s := scores[n]
+		if v, _ := attr(n, "class"); contentNames.MatchString(v) {
+			s *= 1.25
+		}
+		if s > top {
+			best, top = n, s
+		}
+	}
+	return best
+}
+
+// linkDensity returns how much of the text of n, length bytes long, is in
+// links
+func linkDensity(n *html.Node, length int) float64 {
+	if length == 0 {
+		return 0
+	}
+	links := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			links += len(strings.Join(strings.Fields(nodeText(n)), " "))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return min(float64(links)/float64(length), 1)
+}
+
+// boilerplate reports whether the element n is no part of the content of a
+// page, by its tag or by its class, id and role
+func boilerplate(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe,
+		atom.Nav, atom.Footer, atom.Header, atom.Aside, atom.Form, atom.Button, atom.Select:
+		return true
+	case atom.Body, atom.Article, atom.Main:
+		return false
+	}
+	if role, _ := attr(n, "role"); role == "navigation" || role == "banner" || role == "contentinfo" ||
+		role == "complementary" {
+		return true
+	}
+	if _, hidden := attr(n, "hidden"); hidden {
+		return true
+	}
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	names := class + " " + id
+	return boilerplateNames.MatchString(names) && !contentNames.MatchString(names)
+}
+
+// blockElement reports whether the elements of a start a line of text of
+// their own
+func blockElement(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.H1, atom.H2, atom.H3, atom.H4,
+		atom.H5, atom.H6, atom.Ul, atom.Ol, atom.Li, atom.Dl, atom.Dt, atom.Dd, atom.Table, atom.Tr,
+		atom.Blockquote, atom.Pre, atom.Figure, atom.Figcaption, atom.Hr:
+		return true
+	}
+	return false
+}
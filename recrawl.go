@@ -0,0 +1,288 @@
+package webcrawl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// ChangeKind says how a page changed between two crawls.
+type ChangeKind int
+
+const (
+	PageAdded    ChangeKind = iota + 1 // the page is new
+	PageRemoved                        // the page is gone, or can't be reached any more
+	PageModified                       // the content of the page is different
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case PageAdded:
+		return "added"
+	case PageRemoved:
+		return "removed"
+	case PageModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change is a page that changed between two crawls.
+type Change struct {
+	Kind ChangeKind
+	URL  string
+
+	// The content hashes before and after, see ContentHash, each empty
+	//   when the page was not there
+	OldHash, NewHash string
+
+	// The statuses and titles before and after, zero when the page was
+	//   not there, or for the titles not an HTML page
+	OldStatus, NewStatus int    `json:",omitempty"`
+	OldTitle, NewTitle   string `json:",omitempty"`
+}
+
+// PageState is what a Snapshot keeps of a page.
+type PageState struct {
+	Hash      string    // of the content, see ContentHash
+	Links     []string  `json:",omitempty"`
+	FetchedAt time.Time // when the content was last actually fetched
+
+	// Status is the HTTP status of the page, Title the <title> of an
+	//   HTML one
+	Status int    `json:",omitempty"`
+	Title  string `json:",omitempty"`
+}
+
+// pageState returns what a Snapshot keeps of res
+func pageState(res CrawlResult) PageState {
+	st := PageState{Links: res.Links, FetchedAt: res.FetchedAt, Status: statusCode(res)}
+	if res.Body != "" {
+		st.Hash = ContentHash(res.Body)
+		if base, doc, err := pageDoc(res); err == nil && htmlResult(res) {
+			st.Title = ExtractSEO(base, doc).Title
+		}
+	}
+	return st
+}
+
+// Snapshot is the state of a site as a crawl found it.
+type Snapshot struct {
+	Taken time.Time
+	Pages map[string]PageState // by URL
+
+	mu sync.Mutex
+}
+
+// Add records the page of res, failed or not, for a Snapshot of a crawl
+// to save and compare with DiffSnapshots later. The links leaving the
+// scope and the assets are left out. It is safe for concurrent use, but
+// not along with reading Pages.
+func (s *Snapshot) Add(res CrawlResult) {
+	if res.External || res.Asset {
+		return
+	}
+	st := pageState(res)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Pages == nil {
+		s.Pages = make(map[string]PageState)
+	}
+	s.Pages[res.URL] = st
+}
+
+// LoadSnapshot reads a Snapshot saved at path, a missing file is an empty
+// snapshot.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	s := &Snapshot{Pages: make(map[string]PageState)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the snapshot to path, as JSON, through a temporary file.
+func (s *Snapshot) Save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ContentHash returns the hash pages are compared by, the hex SHA-256 of
+// body.
+func ContentHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// Recrawler crawls a site again and again, and tells what changed from one
+// crawl to the next rather than everything it found.
+//
+// Each pass runs the Crawler from Seed and compares the content of every
+// page with the previous pass, reporting the pages added, removed and
+// modified. A page that fails with ErrNotFound is removed, one that fails
+// otherwise keeps its previous state. With a TTL, the pages fetched more
+// recently than their TTL are not fetched again, their previous state
+// and links stand in for them.
+type Recrawler struct {
+	// Crawler runs each pass. Its OnResult is still called, and its
+	//   Visited should be nil, or every pass but the first finds nothing
+	//   new to crawl
+	Crawler *Crawler
+	Seed    string
+
+	// Interval is the time between the start of two passes of Run
+	Interval time.Duration
+
+	// TTL returns how long the content of a page stays fresh, nil or a
+	//   zero TTL means every page is fetched on every pass
+	TTL func(url string) time.Duration
+
+	// OnChange is called with every change a pass finds, once it is over
+	OnChange func(Change)
+
+	// Snapshot is the state of the site as of the last pass, nil before
+	//   the first one, in which every page is added. Load one to pick up
+	//   from an earlier process
+	Snapshot *Snapshot
+}
+
+// Run crawls every Interval until ctx is done, then returns ctx.Err().
+// Passes in which some pages failed are no reason to stop.
+func (r *Recrawler) Run(ctx context.Context) error {
+	for {
+		start := time.Now()
+		if _, err := r.Pass(ctx); ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil && !errors.As(err, new(*ErrorReport)) {
+			return err
+		}
+		t := time.NewTimer(time.Until(start.Add(r.Interval)))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Pass crawls the site once and returns what changed since the previous
+// pass, sorted by URL, along with the error of the crawl. A crawl cut short
+// by ctx changes nothing.
+func (r *Recrawler) Pass(ctx context.Context) ([]Change, error) {
+	prev := r.Snapshot
+	if prev == nil {
+		prev = &Snapshot{}
+	}
+	now := time.Now()
+	next := &Snapshot{Taken: now, Pages: make(map[string]PageState)}
+	var mu sync.Mutex
+
+	// The Crawler is ours for the pass, with its Fetcher and OnResult
+	//   wrapped
+	c := r.Crawler
+	fetcher, onResult := c.Fetcher, c.OnResult
+	defer func() { c.Fetcher, c.OnResult = fetcher, onResult }()
+	fresh := &freshFetcher{Fetcher: fetcher, prev: prev, ttl: r.TTL, now: now}
+	c.Fetcher = fresh
+	c.OnResult = func(res CrawlResult) {
+		mu.Lock()
+		switch old, ok := prev.Pages[res.URL]; {
+		case res.Err != nil && errors.Is(res.Err, ErrNotFound):
+			// Left out of next, so it is removed
+		case res.Err != nil || res.Duplicate || res.External:
+			if ok {
+				next.Pages[res.URL] = old
+			}
+		case res.NotModified && ok:
+			st := old
+			st.Links = res.Links
+			if !fresh.skipped(res.URL) {
+				st.FetchedAt = res.FetchedAt
+			}
+			next.Pages[res.URL] = st
+		default:
+			next.Pages[res.URL] = pageState(res)
+		}
+		mu.Unlock()
+		if onResult != nil {
+			onResult(res)
+		}
+	}
+	err := c.Run(ctx, r.Seed)
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	changes := DiffSnapshots(prev, next)
+	r.Snapshot = next
+	if r.OnChange != nil {
+		for _, ch := range changes {
+			r.OnChange(ch)
+		}
+	}
+	return changes, err
+}
+
+// freshFetcher is the Fetcher of a pass, answering for the pages that are
+// still fresh without fetching them
+type freshFetcher struct {
+	Fetcher
+	prev *Snapshot
+	ttl  func(url string) time.Duration
+	now  time.Time
+
+	mu    sync.Mutex
+	fresh map[string]bool // the pages answered for
+}
+
+func (f *freshFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := f.FetchResponse(ctx, url)
+	return resp.Body, resp.Links, err
+}
+
+func (f *freshFetcher) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	if f.ttl != nil {
+		if p, ok := f.prev.Pages[url]; ok && f.now.Sub(p.FetchedAt) < f.ttl(url) {
+			f.mu.Lock()
+			if f.fresh == nil {
+				f.fresh = make(map[string]bool)
+			}
+			f.fresh[url] = true
+			f.mu.Unlock()
+			return &Response{URL: url, NotModified: true, Links: p.Links}, nil
+		}
+	}
+	return FetchResponse(ctx, f.Fetcher, url)
+}
+
+func (f *freshFetcher) Check(ctx context.Context, url string) error {
+	return Check(ctx, f.Fetcher, url)
+}
+
+// skipped reports whether url was answered for without being fetched
+func (f *freshFetcher) skipped(url string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fresh[url]
+}
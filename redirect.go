@@ -0,0 +1,175 @@
+package webcrawl
+
+import (
+	"sort"
+	"sync"
+)
+
+// RedirectGraph records which URLs redirect to which other URLs, so that
+// messy legacy redirect chains can be followed to their eventual
+// destination after a crawl.
+//
+// A URL has at most one outgoing redirect; adding a second redirect for the
+// same source replaces the first. A RedirectGraph is safe for concurrent use.
+type RedirectGraph struct {
+	mu    sync.RWMutex
+	edges map[string]redirectEdge // source URL => where it redirects to
+}
+
+// redirectEdge is a single hop of the graph
+type redirectEdge struct {
+	to   string
+	code int
+}
+
+// NewRedirectGraph returns an empty RedirectGraph.
+func NewRedirectGraph() *RedirectGraph {
+	return &RedirectGraph{edges: make(map[string]redirectEdge)}
+}
+
+// AddRedirect records that from redirects to to with the given HTTP status
+// code (301, 302, 307, 308, ...).
+func (g *RedirectGraph) AddRedirect(from, to string, code int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.edges == nil {
+		g.edges = make(map[string]redirectEdge)
+	}
+	g.edges[from] = redirectEdge{to, code}
+}
+
+// Redirect returns where from redirects to and with which status code.
+// ok is false when from has no recorded redirect.
+func (g *RedirectGraph) Redirect(from string) (to string, code int, ok bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	e, ok := g.edges[from]
+	return e.to, e.code, ok
+}
+
+// Chain follows the redirects from startURL and returns every URL visited,
+// starting with startURL itself and ending at the eventual destination.
+// If the chain runs into a loop, it stops right before a URL would be
+// visited a second time.
+func (g *RedirectGraph) Chain(startURL string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.chain(startURL)
+}
+
+// chain is Chain without the locking, the caller must hold g.mu
+func (g *RedirectGraph) chain(startURL string) []string {
+	chain := []string{startURL}
+	seen := map[string]bool{startURL: true}
+	for cur := startURL; ; {
+		e, ok := g.edges[cur]
+		if !ok || seen[e.to] {
+			return chain
+		}
+		seen[e.to] = true
+		chain = append(chain, e.to)
+		cur = e.to
+	}
+}
+
+// CircularRedirectReport returns every redirect loop in graph. Each loop is
+// reported once, rotated so that it starts with its lexically smallest URL,
+// and the loops are sorted by that first URL.
+func CircularRedirectReport(graph *RedirectGraph) [][]string {
+	graph.mu.RLock()
+	defer graph.mu.RUnlock()
+
+	// Since every URL has at most one outgoing edge, walking from each
+	// unvisited URL either ends at a destination, runs into a path that
+	// was already explored, or closes a loop on the current walk.
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var cycles [][]string
+	for _, start := range graph.sortedSources() {
+		if state[start] != unvisited {
+			continue
+		}
+		var path []string
+		cur := start
+		for {
+			if state[cur] == onPath {
+				// Found a loop, it is the tail of path starting at cur
+				for i, u := range path {
+					if u == cur {
+						cycles = append(cycles, rotateSmallestFirst(path[i:]))
+						break
+					}
+				}
+				break
+			}
+			if state[cur] == done {
+				break
+			}
+			state[cur] = onPath
+			path = append(path, cur)
+			e, ok := graph.edges[cur]
+			if !ok {
+				break
+			}
+			cur = e.to
+		}
+		for _, u := range path {
+			state[u] = done
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// LongChainReport returns the redirect chains in graph that take more than
+// minLength hops to reach their destination. Only chains starting at a URL
+// that nothing else redirects to are reported, so a long chain is not
+// repeated once for each of its suffixes. The result is sorted by number of
+// hops, longest first.
+func LongChainReport(graph *RedirectGraph, minLength int) [][]string {
+	graph.mu.RLock()
+	defer graph.mu.RUnlock()
+
+	targets := make(map[string]bool, len(graph.edges))
+	for _, e := range graph.edges {
+		targets[e.to] = true
+	}
+	var chains [][]string
+	for _, from := range graph.sortedSources() {
+		if targets[from] {
+			continue
+		}
+		if c := graph.chain(from); len(c)-1 > minLength {
+			chains = append(chains, c)
+		}
+	}
+	sort.SliceStable(chains, func(i, j int) bool { return len(chains[i]) > len(chains[j]) })
+	return chains
+}
+
+// sortedSources returns every URL with an outgoing redirect in a stable
+// order, the caller must hold g.mu
+func (g *RedirectGraph) sortedSources() []string {
+	sources := make([]string, 0, len(g.edges))
+	for from := range g.edges {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// rotateSmallestFirst returns a copy of cycle rotated so the lexically
+// smallest URL comes first
+func rotateSmallestFirst(cycle []string) []string {
+	min := 0
+	for i, u := range cycle {
+		if u < cycle[min] {
+			min = i
+		}
+	}
+	return append(append([]string(nil), cycle[min:]...), cycle[:min]...)
+}
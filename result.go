@@ -0,0 +1,142 @@
+package webcrawl
+
+import (
+	"net/http"
+	"time"
+)
+
+// CrawlResult is what the Crawler reports for every URL it fetched
+type CrawlResult struct {
+	URL   string   // the page that was fetched
+	Body  string   // body of the page, as returned by the Fetcher
+	Links []string // URLs found on the page
+	Depth int      // how many links away from the seed, the seed being 0
+	Err   error    // non-nil when the fetch failed, Body and Links are empty then
+
+	// StatusCode is the HTTP status of the page, zero when the Fetcher
+	//   doesn't tell
+	StatusCode int
+
+	// Redirects are the hops the fetch followed, the page is at the To
+	//   of the last one. Duplicate is set when that page was crawled
+	//   already, reached from another URL: Body and Links are empty then
+	Redirects []Redirect
+	Duplicate bool
+
+	// Header, ContentLength, RemoteAddr, TLSVersion, Protocol and
+	//   Timings tell of the response, see Response
+	Header        http.Header
+	ContentLength int64
+	RemoteAddr    string
+	TLSVersion    string
+	Protocol      string
+	Timings       Timings
+
+	// ContentHash is the hash of Body, see ContentHash, empty when there
+	//   is no body. With Crawler.DedupContent, a page with the content of
+	//   one crawled before is Duplicate too, DuplicateOf being the URL of
+	//   that one: Body is there, but Links are empty
+	ContentHash string
+	DuplicateOf string
+
+	// Canonical is the URL of the <link rel="canonical"> of the page,
+	//   absolute, with Crawler.FollowCanonical. When it is another page,
+	//   the page is Duplicate of it too, as for DedupContent
+	Canonical string
+
+	// NotModified is set when the page did not change since the last
+	//   crawl, see ConditionalCache: Body is empty then, Links are the
+	//   ones it had
+	NotModified bool
+
+	// NoIndex is set when the page asks not to be indexed, it is
+	//   reported all the same for whatever indexes the pages to leave it
+	//   out. NoFollowLinks are the links it asks robots not to follow,
+	//   which are not unless the Crawler ignores robots, see
+	//   Response.NoFollowLinks
+	NoIndex       bool
+	NoFollowLinks []string
+
+	// BodySize is how much of the body was downloaded, decompressed,
+	//   WireSize how much of it came over the wire, and Truncated is set
+	//   when it was cut short, see Response.BodySize
+	BodySize  int64
+	WireSize  int64
+	Truncated bool
+
+	// BodyCut is set when Crawler.Bodies left the body out of the result
+	//   or cut it short, see BodySampling: BodySize is the size of all
+	//   of it
+	BodyCut bool
+
+	// Charset is the one the body was in before it was transcoded to
+	//   UTF-8, see Response.Charset
+	Charset string
+
+	// Screenshot is the file a screenshot of the page was saved to, see
+	//   Response.Screenshot
+	Screenshot string
+
+	// Accessibility are the accessibility issues the Fetcher found on the
+	//   page, see Response.Accessibility and AccessibilityReport
+	Accessibility []AccessibilityIssue
+
+	// StructuredData is what the page embeds of JSON-LD, microdata and
+	//   OpenGraph, with Crawler.StructuredData. It is nil when there is
+	//   none
+	StructuredData *StructuredData
+
+	// Text is the main content of the page as plain text, WordCount the
+	//   number of its words, with Crawler.ReadableText
+	Text      string
+	WordCount int
+
+	// Fields are what Crawler.Scraper scraped off the page, by field
+	//   name, see ScrapeRule. It is nil when there are none
+	Fields map[string]any
+
+	// Matched are the names of the Crawler.ContentRules the page matched,
+	//   in the order of the rules. It is nil when there are none
+	Matched []string
+
+	// Assets are the URLs of the resources the page is made of, its
+	//   images, stylesheets, scripts and the like, with
+	//   Crawler.FetchAssets. Asset is set when the URL was fetched as one
+	//   of those, its Links are not followed then
+	Assets []string
+	Asset  bool
+
+	// Feeds are the URLs of the RSS and Atom feeds the page announces,
+	//   with Crawler.FindFeeds. Feed is set when the URL was fetched as a
+	//   feed, its Links are the URLs of its entries then
+	Feeds []string
+	Feed  bool
+
+	// Language is the language of the page as a BCP 47 tag, with
+	//   Crawler.DetectLanguage, see DetectLanguage. It is empty when
+	//   there is no telling
+	Language string
+
+	// Document is set when the URL is a PDF, a Word file, a spreadsheet
+	//   or the like, with Crawler.CheckDocuments or DocumentExtractor.
+	//   Its Text and Links are what the DocumentExtractor found in it
+	Document bool
+
+	// Soft404 is set when the page, answered with a 2xx status, is the
+	//   one its host answers for the URLs that don't exist, with
+	//   Crawler.Soft404. Its links are followed all the same
+	Soft404 bool
+
+	// Skipped tells why the page was not downloaded, see
+	//   Response.Skipped: Body and Links are empty then. For a body
+	//   shorter than Crawler.MinBodyBytes, it tells its length, Body being
+	//   kept but Links left empty
+	Skipped string
+
+	// External is set when the URL is a link leaving the scope that was
+	//   only checked, Body and Links are empty then too
+	External bool
+
+	FetchedAt time.Time     // when the fetch started
+	Duration  time.Duration // how long the fetch took
+}
@@ -0,0 +1,289 @@
+package webcrawl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy says how often, and how patiently, a failed fetch is tried
+// again. The delay before retry n (counting from 1) is BaseDelay doubled
+// n-1 times, capped at MaxDelay, then shortened by a random fraction of up
+// to Jitter of itself so that workers that failed together don't all come
+// back at the same instant.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, the first one included.
+	//   One or less means no retry
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between two tries, zero means no cap
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of the delay, between 0 and 1, that is
+	//   randomized
+	Jitter float64
+
+	// RetryOn classifies the errors worth a retry, when nil Retryable is
+	//   used
+	RetryOn func(err error) bool
+}
+
+// DefaultRetryPolicy tries each fetch up to three times, waiting half a
+// second and then a second between tries, minus up to half of that at
+// random.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.5,
+}
+
+// Delay returns how long to wait before retry n, counting from 1.
+func (p *RetryPolicy) Delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d <= math.MaxInt64/2; i++ {
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if j := p.Jitter; j > 0 && d > 0 {
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	return d
+}
+
+// Retryable reports whether err looks transient: a 429 or 5xx HTTP
+// status (except 501 Not Implemented, which won't change), a timeout, or a
+// connection that was refused, reset or cut short.
+func Retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests ||
+			se.StatusCode >= 500 && se.StatusCode != http.StatusNotImplemented
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryFetcher is a Fetcher that retries the fetches of another Fetcher
+// according to a RetryPolicy.
+type RetryFetcher struct {
+	Fetcher Fetcher
+	Policy  RetryPolicy
+}
+
+// WithRetry wraps f so that failed fetches are retried according to p.
+func WithRetry(f Fetcher, p RetryPolicy) *RetryFetcher {
+	return &RetryFetcher{Fetcher: f, Policy: p}
+}
+
+// Fetch implements Fetcher, returning the error of the last attempt when
+// all of them failed. It gives up as soon as ctx is done.
+func (f *RetryFetcher) Fetch(ctx context.Context, url string) (body string, urls []string, err error) {
+	err = f.retry(ctx, func() error {
+		body, urls, err = f.Fetcher.Fetch(ctx, url)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return body, urls, nil
+}
+
+// FetchResponse implements ResponseFetcher, retrying like Fetch.
+func (f *RetryFetcher) FetchResponse(ctx context.Context, url string) (resp *Response, err error) {
+	err = f.retry(ctx, func() error {
+		resp, err = FetchResponse(ctx, f.Fetcher, url)
+		return err
+	})
+	return resp, err
+}
+
+// Check implements Checker, retrying like Fetch.
+func (f *RetryFetcher) Check(ctx context.Context, url string) error {
+	return f.retry(ctx, func() error { return Check(ctx, f.Fetcher, url) })
+}
+
+// retry calls attempt until it succeeds or the policy says to give up.
+// It waits at least as long as a Retry-After asks, but gives up when that
+// is longer than MaxDelay, leaving it to the Crawler to back off the host
+func (f *RetryFetcher) retry(ctx context.Context, attempt func() error) error {
+	retryOn := f.Policy.RetryOn
+	if retryOn == nil {
+		retryOn = Retryable
+	}
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= f.Policy.MaxAttempts || ctx.Err() != nil || !retryOn(err) {
+			return err
+		}
+
+		delay := f.Policy.Delay(n)
+		if wait, ok := RetryAfter(err); ok && wait > delay {
+			if f.Policy.MaxDelay > 0 && wait > f.Policy.MaxDelay {
+				return err
+			}
+			delay = wait
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// URLState is where a URL stands in a crawl under way, see
+// Crawler.StateOf.
+type URLState int
+
+const (
+	URLUnknown  URLState = iota // not come across, or in an earlier run
+	URLQueued                   // waiting in the frontier, or for its host or its retry
+	URLInFlight                 // being fetched
+	URLDone                     // fetched, or dropped on the way
+	URLFailed                   // its last fetch failed
+)
+
+func (s URLState) String() string {
+	switch s {
+	case URLQueued:
+		return "queued"
+	case URLInFlight:
+		return "in flight"
+	case URLDone:
+		return "done"
+	case URLFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// urlState is what the dispatcher knows of a URL, for Crawler.Retry
+type urlState struct {
+	state     URLState
+	attempts  int       // fetches that failed
+	retryable bool      // the last failure, by the RetryOn of Retry
+	failed    time.Time // when it last failed
+}
+
+// delayed is an item to be fetched again once at has come
+type delayed struct {
+	it FrontierItem
+	at time.Time
+}
+
+// setState records that u is now in state. A compact run only keeps the
+// states of the URLs that failed and are not done
+func (r *run) setState(u string, state URLState) {
+	st := r.states[u]
+	if r.compact && (st.attempts == 0 || state == URLDone) {
+		delete(r.states, u)
+		return
+	}
+	st.state = state
+	r.states[u] = st
+}
+
+// failed records that the fetch of u failed of err, at now, and reports
+// whether it failed before
+func (r *run) failed(u string, err error, now time.Time) (again bool) {
+	st := r.states[u]
+	again = st.attempts > 0
+	st.state, st.failed = URLFailed, now
+	st.attempts++
+	if r.Retry != nil {
+		retryOn := r.Retry.RetryOn
+		if retryOn == nil {
+			retryOn = Retryable
+		}
+		st.retryable = retryOn(err)
+	}
+	r.states[u] = st
+	return again
+}
+
+// retrying reports whether u, which failed, is to be fetched again now
+// that it was found again: Retry deems its failure transient, and has
+// attempts left for it
+func (r *run) retrying(u string) bool {
+	if r.Retry == nil {
+		return false
+	}
+	st, ok := r.states[u]
+	return ok && st.state == URLFailed && st.retryable && st.attempts < r.Retry.MaxAttempts
+}
+
+// retry queues it again, once the delay of Retry since its last failure
+// is over
+func (r *run) retry(it FrontierItem, now time.Time) {
+	st := r.states[it.URL]
+	at := st.failed.Add(r.Retry.Delay(st.attempts))
+	r.setState(it.URL, URLQueued)
+	r.log.Debug("url queued again", "url", it.URL, "depth", it.Depth, "reason", "retry", "attempt", st.attempts+1)
+	if at.After(now) {
+		r.delayed = append(r.delayed, delayed{it, at})
+		return
+	}
+	r.frontier.Push(it)
+}
+
+// releaseRetries pushes the retries due into the frontier, all of them
+// once the crawl is called off
+func (r *run) releaseRetries(now time.Time, all bool) {
+	kept := r.delayed[:0]
+	for _, d := range r.delayed {
+		if all || !d.at.After(now) {
+			r.frontier.Push(d.it)
+		} else {
+			kept = append(kept, d)
+		}
+	}
+	clear(r.delayed[len(kept):])
+	r.delayed = kept
+}
+
+// StateOf returns where url stands in the crawls under way, URLUnknown
+// when none of them came across it. A crawl whose Visited is a
+// BloomVisitedSet only knows of the URLs that failed, see there.
+func (c *Crawler) StateOf(url string) URLState {
+	state := URLUnknown
+	c.control(func(r *run) error {
+		u, err := r.norm.Normalize(url)
+		if err != nil {
+			return nil
+		}
+		if r.Aliases != nil {
+			u = r.Aliases.Canonical(u)
+		}
+		if st, ok := r.states[u]; ok && state == URLUnknown {
+			state = st.state
+		}
+		return nil
+	})
+	return state
+}
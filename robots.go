@@ -0,0 +1,384 @@
+package webcrawl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent is the name the crawler goes by, both when looking for
+// its group in robots.txt files and when identifying itself to servers
+const DefaultUserAgent = "webcrawl"
+
+// The defaults of Robots.MaxAge and Robots.RetryUnreachable
+const (
+	DefaultRobotsMaxAge = 24 * time.Hour
+	DefaultRobotsRetry  = time.Minute
+)
+
+// Robots fetches, caches and evaluates the robots.txt file of each host,
+// following RFC 9309: a file that is not there, answered 4xx, allows
+// everything, but a host whose file can't be reached, answered 5xx or 429
+// Too Many Requests or not answering at all, is taken to disallow
+// everything until it can, the copy fetched before, if any, applying
+// meanwhile. A Robots is safe for concurrent use; every host's file is
+// fetched once at a time, however many workers ask for it.
+type Robots struct {
+	// Fetcher retrieves the robots.txt files
+	Fetcher Fetcher
+
+	// UserAgent selects the group of rules that applies, when empty
+	//   DefaultUserAgent is used. Only the product token matters, so
+	//   "mybot/1.2 (+https://example.com/bot)" is the same as "mybot"
+	UserAgent string
+
+	// MaxAge is how long a robots.txt is followed before it is fetched
+	//   again, DefaultRobotsMaxAge when zero. RetryUnreachable is how long
+	//   one that couldn't be reached is left before it is tried again,
+	//   DefaultRobotsRetry when zero
+	MaxAge           time.Duration
+	RetryUnreachable time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry // scheme://host => its robots.txt
+	next  map[string]time.Time    // scheme://host => when Crawl-delay allows the next fetch
+}
+
+// robotsEntry is the cached robots.txt of one host, rules and expires are
+// only valid once ready is closed
+type robotsEntry struct {
+	ready   chan struct{}
+	rules   *RobotsRules
+	expires time.Time // when the file is to be fetched again
+
+	// fetched are the rules of the last copy of the file fetched, or of
+	//   its absence, nil when it was never reached
+	fetched *RobotsRules
+}
+
+// stale reports whether e is filled and expired
+func (e *robotsEntry) stale(now time.Time) bool {
+	select {
+	case <-e.ready:
+		return e.rules != nil && now.After(e.expires)
+	default:
+		return false
+	}
+}
+
+// NewRobots returns a Robots fetching robots.txt files with f and
+// following the rules meant for userAgent.
+func NewRobots(f Fetcher, userAgent string) *Robots {
+	return &Robots{Fetcher: f, UserAgent: userAgent}
+}
+
+// Rules returns the rules that apply to rawURL's host, fetching its
+// robots.txt the first time the host is seen, and once MaxAge is over. A
+// robots.txt that is not there allows everything, one that can't be
+// reached disallows everything, see Robots.
+func (r *Robots) Rules(ctx context.Context, rawURL string) (*RobotsRules, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	key := u.Scheme + "://" + u.Host
+
+	r.mu.Lock()
+	if r.hosts == nil {
+		r.hosts = make(map[string]*robotsEntry)
+	}
+	e, ok := r.hosts[key]
+	switch {
+	case !ok:
+		e = &robotsEntry{ready: make(chan struct{})}
+		r.hosts[key] = e
+	case e.stale(time.Now()):
+		// Fetched again, the last copy standing in if it can't be
+		e, ok = &robotsEntry{ready: make(chan struct{}), fetched: e.fetched}, false
+		r.hosts[key] = e
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		// We are the first to ask for this host, everybody else waits
+		//   for us to fill the entry
+		body, _, err := r.Fetcher.Fetch(ctx, key+"/robots.txt")
+		if err != nil && ctx.Err() != nil {
+			// Don't cache the outcome of an aborted fetch, the next one
+			//   asking gets to try again, the last copy standing in for
+			//   the ones waiting if there is one
+			r.mu.Lock()
+			if e.fetched == nil {
+				delete(r.hosts, key)
+			}
+			e.rules, e.expires = e.fetched, time.Now()
+			r.mu.Unlock()
+			close(e.ready)
+			return nil, ctx.Err()
+		}
+		now := time.Now()
+		switch {
+		case err == nil:
+			e.rules = ParseRobots(body, r.userAgent())
+			e.fetched, e.expires = e.rules, now.Add(r.maxAge())
+		case robotsUnreachable(err):
+			e.rules = e.fetched
+			if e.rules == nil {
+				e.rules = ParseRobots("User-agent: *\nDisallow: /", r.userAgent())
+			}
+			e.expires = now.Add(r.retryUnreachable())
+		default:
+			e.rules = ParseRobots("", r.userAgent())
+			e.fetched, e.expires = e.rules, now.Add(r.maxAge())
+		}
+		close(e.ready)
+		return e.rules, nil
+	}
+
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if e.rules == nil {
+		// The fetch we waited for was aborted, have a go ourselves
+		return r.Rules(ctx, rawURL)
+	}
+	return e.rules, nil
+}
+
+// robotsUnreachable reports whether err, of the fetch of a robots.txt,
+// tells it can't be reached rather than that it is not there: a 5xx or 429
+// status, or a failure of the network. The other errors of the Fetchers,
+// a page not recorded or cached for instance, are taken for its absence
+func robotsUnreachable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrHostDown)
+}
+
+// Allowed reports whether robots.txt lets the crawler fetch rawURL.
+func (r *Robots) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	rules, err := r.Rules(ctx, rawURL)
+	if err != nil {
+		return false, err
+	}
+	u, _ := url.Parse(rawURL) // Rules already parsed it successfully
+	return rules.Allowed(u.RequestURI()), nil
+}
+
+// Wait blocks until the Crawl-delay of rawURL's host, if it has one, has
+// passed since the previous fetch from that host that went through Wait.
+func (r *Robots) Wait(ctx context.Context, rawURL string) error {
+	rules, err := r.Rules(ctx, rawURL)
+	if err != nil || rules.CrawlDelay <= 0 {
+		return err
+	}
+	u, _ := url.Parse(rawURL)
+	key := u.Scheme + "://" + u.Host
+
+	// Book the next slot for this host, then sleep until ours comes
+	r.mu.Lock()
+	if r.next == nil {
+		r.next = make(map[string]time.Time)
+	}
+	now := time.Now()
+	at := r.next[key]
+	if at.Before(now) {
+		at = now
+	}
+	r.next[key] = at.Add(rules.CrawlDelay)
+	r.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Robots) maxAge() time.Duration {
+	if r.MaxAge <= 0 {
+		return DefaultRobotsMaxAge
+	}
+	return r.MaxAge
+}
+
+func (r *Robots) retryUnreachable() time.Duration {
+	if r.RetryUnreachable <= 0 {
+		return DefaultRobotsRetry
+	}
+	return r.RetryUnreachable
+}
+
+func (r *Robots) userAgent() string {
+	if r.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return r.UserAgent
+}
+
+// RobotsRules is the part of a robots.txt file that applies to one user
+// agent.
+type RobotsRules struct {
+	rules []robotsRule
+
+	// CrawlDelay is how long to wait between two fetches from the host,
+	//   zero when robots.txt does not say
+	CrawlDelay time.Duration
+
+	// Sitemaps lists the Sitemap URLs the file announces, these apply to
+	//   every user agent
+	Sitemaps []string
+}
+
+// robotsRule is one Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string         // as written, its length decides which rule wins
+	re      *regexp.Regexp // pattern with * and $ turned into a regexp
+}
+
+// ParseRobots parses the content of a robots.txt file and keeps the rules
+// of the group that applies to userAgent, or of the * group when no group
+// names it. A file that cannot be made sense of allows everything.
+func ParseRobots(content, userAgent string) *RobotsRules {
+	agent := productToken(userAgent)
+
+	// Rules for our agent and for everybody are collected separately, the
+	//   latter only apply when the file has no group for us
+	var mine, star RobotsRules
+	foundMine := false
+	var sitemaps []string
+
+	// The group being read, a group is one or more user-agent lines
+	//   followed by rules
+	var forMe, forStar, inAgents bool
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				// A new group starts
+				forMe, forStar = false, false
+			}
+			inAgents = true
+			name := productToken(value)
+			if name == "*" {
+				forStar = true
+			} else if name == agent {
+				forMe, foundMine = true, true
+			}
+			continue
+		case "sitemap":
+			// Not part of any group
+			sitemaps = append(sitemaps, value)
+			continue
+		}
+		inAgents = false
+
+		var dst []*RobotsRules
+		if forMe {
+			dst = append(dst, &mine)
+		}
+		if forStar {
+			dst = append(dst, &star)
+		}
+		for _, g := range dst {
+			switch key {
+			case "allow", "disallow":
+				if value == "" {
+					// An empty Disallow disallows nothing
+					continue
+				}
+				g.rules = append(g.rules, robotsRule{
+					allow:   key == "allow",
+					pattern: value,
+					re:      robotsPattern(value),
+				})
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					g.CrawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	rules := &star
+	if foundMine {
+		rules = &mine
+	}
+	rules.Sitemaps = sitemaps
+	return rules
+}
+
+// Allowed reports whether the rules let the crawler fetch path, which
+// includes the query string if any. The most specific (longest) matching
+// rule wins, Allow winning a tie; a path no rule matches is allowed.
+func (r *RobotsRules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed, best := true, -1
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || n == best && rule.allow {
+			allowed, best = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// robotsPattern turns a robots.txt path pattern into an anchored regexp,
+// * matches any sequence of characters and a trailing $ the end of the path
+func robotsPattern(pattern string) *regexp.Regexp {
+	end := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i, part := range strings.Split(pattern, "*") {
+		if i > 0 {
+			sb.WriteString(".*")
+		}
+		sb.WriteString(regexp.QuoteMeta(part))
+	}
+	if end {
+		sb.WriteString("$")
+	}
+	return regexp.MustCompile(sb.String())
+}
+
+// productToken lowercases a user agent and keeps only its name, without
+// version or comments
+func productToken(userAgent string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	name, _, _ = strings.Cut(name, " ")
+	return strings.ToLower(name)
+}
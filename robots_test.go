@@ -0,0 +1,124 @@
+package webcrawl_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// robotsServer serves a robots.txt disallowing /private, answering with
+// the status in status instead when it is set
+func robotsServer(status *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/robots.txt" {
+			http.NotFound(w, req)
+			return
+		}
+		if code := int(status.Load()); code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+}
+
+// allowed reports what r makes of / and /private at base
+func allowed(t *testing.T, r *webcrawl.Robots, base string) (home, private bool) {
+	t.Helper()
+	ctx := context.Background()
+	home, err := r.Allowed(ctx, base+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err = r.Allowed(ctx, base+"/private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return home, private
+}
+
+func TestRobotsStatus(t *testing.T) {
+	tests := []struct {
+		status        int
+		home, private bool
+	}{
+		{0, true, false},
+		// Not there, everything is allowed
+		{http.StatusNotFound, true, true},
+		{http.StatusGone, true, true},
+		{http.StatusUnauthorized, true, true},
+		{http.StatusForbidden, true, true},
+		// Not reachable, nothing is
+		{http.StatusInternalServerError, false, false},
+		{http.StatusServiceUnavailable, false, false},
+		{http.StatusTooManyRequests, false, false},
+	}
+	for _, tt := range tests {
+		var status atomic.Int32
+		status.Store(int32(tt.status))
+		srv := robotsServer(&status)
+		r := webcrawl.NewRobots(webcrawl.NewHTTPFetcher(time.Second), "")
+		if home, private := allowed(t, r, srv.URL); home != tt.home || private != tt.private {
+			t.Errorf("robots.txt answered %d: / allowed %v, /private %v, want %v and %v", tt.status, home, private, tt.home, tt.private)
+		}
+		srv.Close()
+	}
+}
+
+func TestRobotsNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	base := srv.URL
+	srv.Close()
+	r := webcrawl.NewRobots(webcrawl.NewHTTPFetcher(time.Second), "")
+	if home, _ := allowed(t, r, base); home {
+		t.Error("a host not answering is allowed")
+	}
+}
+
+func TestRobotsRetryUnreachable(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	srv := robotsServer(&status)
+	defer srv.Close()
+	r := webcrawl.NewRobots(webcrawl.NewHTTPFetcher(time.Second), "")
+	r.RetryUnreachable = 20 * time.Millisecond
+	if home, _ := allowed(t, r, srv.URL); home {
+		t.Fatal("/ allowed with robots.txt answering 503")
+	}
+	status.Store(0)
+	if home, _ := allowed(t, r, srv.URL); home {
+		t.Error("robots.txt fetched again before RetryUnreachable")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if home, private := allowed(t, r, srv.URL); !home || private {
+		t.Errorf("once back: / allowed %v, /private %v, want true and false", home, private)
+	}
+}
+
+func TestRobotsStaleCopy(t *testing.T) {
+	var status atomic.Int32
+	srv := robotsServer(&status)
+	defer srv.Close()
+	r := webcrawl.NewRobots(webcrawl.NewHTTPFetcher(time.Second), "")
+	r.MaxAge, r.RetryUnreachable = 10*time.Millisecond, time.Millisecond
+	if home, private := allowed(t, r, srv.URL); !home || private {
+		t.Fatalf("/ allowed %v, /private %v, want true and false", home, private)
+	}
+	// Past MaxAge and unreachable, the copy fetched before applies
+	status.Store(http.StatusBadGateway)
+	time.Sleep(20 * time.Millisecond)
+	if home, private := allowed(t, r, srv.URL); !home || private {
+		t.Errorf("with the last copy: / allowed %v, /private %v, want true and false", home, private)
+	}
+	// Gone for good, everything is allowed
+	status.Store(http.StatusNotFound)
+	time.Sleep(5 * time.Millisecond)
+	if _, private := allowed(t, r, srv.URL); !private {
+		t.Error("/private disallowed once robots.txt is gone")
+	}
+}
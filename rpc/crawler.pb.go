@@ -0,0 +1,759 @@
+// The Crawler service package rpc serves over gRPC, for other services to
+// start crawls, follow them and take their results as they come.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: rpc/crawler.proto
+
+package rpc
+
+import (
+	streampb "github.com/jackyugit/webcrawl/stream/streampb"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStatusResponse_State int32
+
+const (
+	GetStatusResponse_STATE_UNSPECIFIED GetStatusResponse_State = 0
+	GetStatusResponse_WAITING           GetStatusResponse_State = 1 // for a StreamResults, see wait_for_stream
+	GetStatusResponse_RUNNING           GetStatusResponse_State = 2
+	GetStatusResponse_FINISHED          GetStatusResponse_State = 3 // every page fetched, error lists those that failed
+	GetStatusResponse_STOPPED           GetStatusResponse_State = 4 // by StopCrawl
+	GetStatusResponse_FAILED            GetStatusResponse_State = 5 // the crawl could not start, see error
+)
+
+// Enum value maps for GetStatusResponse_State.
+var (
+	GetStatusResponse_State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "WAITING",
+		2: "RUNNING",
+		3: "FINISHED",
+		4: "STOPPED",
+		5: "FAILED",
+	}
+	GetStatusResponse_State_value = map[string]int32{
+		"STATE_UNSPECIFIED": 0,
+		"WAITING":           1,
+		"RUNNING":           2,
+		"FINISHED":          3,
+		"STOPPED":           4,
+		"FAILED":            5,
+	}
+)
+
+func (x GetStatusResponse_State) Enum() *GetStatusResponse_State {
+	p := new(GetStatusResponse_State)
+	*p = x
+	return p
+}
+
+func (x GetStatusResponse_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GetStatusResponse_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_rpc_crawler_proto_enumTypes[0].Descriptor()
+}
+
+func (GetStatusResponse_State) Type() protoreflect.EnumType {
+	return &file_rpc_crawler_proto_enumTypes[0]
+}
+
+func (x GetStatusResponse_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GetStatusResponse_State.Descriptor instead.
+func (GetStatusResponse_State) EnumDescriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{5, 0}
+}
+
+type StartCrawlRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seed     string `protobuf:"bytes,1,opt,name=seed,proto3" json:"seed,omitempty"`
+	MaxDepth int32  `protobuf:"varint,2,opt,name=max_depth,json=maxDepth,proto3" json:"max_depth,omitempty"` // 0 for the server's default
+	MaxPages int32  `protobuf:"varint,3,opt,name=max_pages,json=maxPages,proto3" json:"max_pages,omitempty"` // 0 for no limit
+	Workers  int32  `protobuf:"varint,4,opt,name=workers,proto3" json:"workers,omitempty"`                   // 0 for the server's default
+	// Hold the crawl until a StreamResults of it is under way, so that no
+	// result is missed
+	WaitForStream bool `protobuf:"varint,5,opt,name=wait_for_stream,json=waitForStream,proto3" json:"wait_for_stream,omitempty"`
+}
+
+func (x *StartCrawlRequest) Reset() {
+	*x = StartCrawlRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StartCrawlRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartCrawlRequest) ProtoMessage() {}
+
+func (x *StartCrawlRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartCrawlRequest.ProtoReflect.Descriptor instead.
+func (*StartCrawlRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartCrawlRequest) GetSeed() string {
+	if x != nil {
+		return x.Seed
+	}
+	return ""
+}
+
+func (x *StartCrawlRequest) GetMaxDepth() int32 {
+	if x != nil {
+		return x.MaxDepth
+	}
+	return 0
+}
+
+func (x *StartCrawlRequest) GetMaxPages() int32 {
+	if x != nil {
+		return x.MaxPages
+	}
+	return 0
+}
+
+func (x *StartCrawlRequest) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+func (x *StartCrawlRequest) GetWaitForStream() bool {
+	if x != nil {
+		return x.WaitForStream
+	}
+	return false
+}
+
+type StartCrawlResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *StartCrawlResponse) Reset() {
+	*x = StartCrawlResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StartCrawlResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartCrawlResponse) ProtoMessage() {}
+
+func (x *StartCrawlResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartCrawlResponse.ProtoReflect.Descriptor instead.
+func (*StartCrawlResponse) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StartCrawlResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type StopCrawlRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *StopCrawlRequest) Reset() {
+	*x = StopCrawlRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StopCrawlRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopCrawlRequest) ProtoMessage() {}
+
+func (x *StopCrawlRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopCrawlRequest.ProtoReflect.Descriptor instead.
+func (*StopCrawlRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StopCrawlRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type StopCrawlResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StopCrawlResponse) Reset() {
+	*x = StopCrawlResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StopCrawlResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopCrawlResponse) ProtoMessage() {}
+
+func (x *StopCrawlResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopCrawlResponse.ProtoReflect.Descriptor instead.
+func (*StopCrawlResponse) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{3}
+}
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetStatusRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetStatusResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id             string                  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Seed           string                  `protobuf:"bytes,2,opt,name=seed,proto3" json:"seed,omitempty"`
+	State          GetStatusResponse_State `protobuf:"varint,3,opt,name=state,proto3,enum=webcrawl.GetStatusResponse_State" json:"state,omitempty"`
+	Error          string                  `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`    // why the crawl failed, or the URLs that did
+	Queued         int32                   `protobuf:"varint,5,opt,name=queued,proto3" json:"queued,omitempty"` // URLs waiting to be fetched
+	InFlight       int32                   `protobuf:"varint,6,opt,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`
+	Fetched        int32                   `protobuf:"varint,7,opt,name=fetched,proto3" json:"fetched,omitempty"` // failed or not
+	Failed         int32                   `protobuf:"varint,8,opt,name=failed,proto3" json:"failed,omitempty"`
+	Bytes          int64                   `protobuf:"varint,9,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	PagesPerSecond float64                 `protobuf:"fixed64,10,opt,name=pages_per_second,json=pagesPerSecond,proto3" json:"pages_per_second,omitempty"`
+	ElapsedMs      int64                   `protobuf:"varint,11,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	Paused         bool                    `protobuf:"varint,12,opt,name=paused,proto3" json:"paused,omitempty"`
+}
+
+func (x *GetStatusResponse) Reset() {
+	*x = GetStatusResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusResponse) ProtoMessage() {}
+
+func (x *GetStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetStatusResponse) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetStatusResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetSeed() string {
+	if x != nil {
+		return x.Seed
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetState() GetStatusResponse_State {
+	if x != nil {
+		return x.State
+	}
+	return GetStatusResponse_STATE_UNSPECIFIED
+}
+
+func (x *GetStatusResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetInFlight() int32 {
+	if x != nil {
+		return x.InFlight
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetFetched() int32 {
+	if x != nil {
+		return x.Fetched
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetPagesPerSecond() float64 {
+	if x != nil {
+		return x.PagesPerSecond
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+type StreamResultsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *StreamResultsRequest) Reset() {
+	*x = StreamResultsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_crawler_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamResultsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamResultsRequest) ProtoMessage() {}
+
+func (x *StreamResultsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_crawler_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamResultsRequest.ProtoReflect.Descriptor instead.
+func (*StreamResultsRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_crawler_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamResultsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_rpc_crawler_proto protoreflect.FileDescriptor
+
+var file_rpc_crawler_proto_rawDesc = []byte{
+	0x0a, 0x11, 0x72, 0x70, 0x63, 0x2f, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x65, 0x72, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x08, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x1a, 0x19, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x2f, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x5f, 0x72, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa3, 0x01, 0x0a, 0x11, 0x53, 0x74, 0x61,
+	0x72, 0x74, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x73, 0x65, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x65,
+	0x65, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x5f, 0x64, 0x65, 0x70, 0x74, 0x68, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x44, 0x65, 0x70, 0x74, 0x68, 0x12,
+	0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x61, 0x67, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x50, 0x61, 0x67, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x77,
+	0x6f, 0x72, 0x6b, 0x65, 0x72, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x77, 0x61, 0x69, 0x74, 0x5f, 0x66,
+	0x6f, 0x72, 0x5f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x0d, 0x77, 0x61, 0x69, 0x74, 0x46, 0x6f, 0x72, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x22, 0x24,
+	0x0a, 0x12, 0x53, 0x74, 0x61, 0x72, 0x74, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x02, 0x69, 0x64, 0x22, 0x22, 0x0a, 0x10, 0x53, 0x74, 0x6f, 0x70, 0x43, 0x72, 0x61, 0x77,
+	0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x13, 0x0a, 0x11, 0x53, 0x74, 0x6f, 0x70,
+	0x43, 0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x22, 0x0a,
+	0x10, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x22, 0xc5, 0x03, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x65, 0x65, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x65, 0x65, 0x64, 0x12, 0x37, 0x0a, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x77, 0x65, 0x62,
+	0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x71, 0x75,
+	0x65, 0x75, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x71, 0x75, 0x65, 0x75,
+	0x65, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x69, 0x6e, 0x5f, 0x66, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x69, 0x6e, 0x46, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x61, 0x69,
+	0x6c, 0x65, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65,
+	0x64, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x70, 0x61, 0x67, 0x65, 0x73,
+	0x5f, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x0e, 0x70, 0x61, 0x67, 0x65, 0x73, 0x50, 0x65, 0x72, 0x53, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64, 0x5f, 0x6d, 0x73, 0x18,
+	0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64, 0x4d, 0x73,
+	0x12, 0x16, 0x0a, 0x06, 0x70, 0x61, 0x75, 0x73, 0x65, 0x64, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x06, 0x70, 0x61, 0x75, 0x73, 0x65, 0x64, 0x22, 0x5f, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x12, 0x15, 0x0a, 0x11, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45,
+	0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x57, 0x41, 0x49, 0x54,
+	0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x55, 0x4e, 0x4e, 0x49, 0x4e, 0x47,
+	0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x46, 0x49, 0x4e, 0x49, 0x53, 0x48, 0x45, 0x44, 0x10, 0x03,
+	0x12, 0x0b, 0x0a, 0x07, 0x53, 0x54, 0x4f, 0x50, 0x50, 0x45, 0x44, 0x10, 0x04, 0x12, 0x0a, 0x0a,
+	0x06, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x05, 0x22, 0x26, 0x0a, 0x14, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x32, 0xa8, 0x02, 0x0a, 0x07, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x65, 0x72, 0x12, 0x47, 0x0a,
+	0x0a, 0x53, 0x74, 0x61, 0x72, 0x74, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x12, 0x1b, 0x2e, 0x77, 0x65,
+	0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e, 0x53, 0x74, 0x61, 0x72, 0x74, 0x43, 0x72, 0x61, 0x77,
+	0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72,
+	0x61, 0x77, 0x6c, 0x2e, 0x53, 0x74, 0x61, 0x72, 0x74, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x44, 0x0a, 0x09, 0x53, 0x74, 0x6f, 0x70, 0x43, 0x72,
+	0x61, 0x77, 0x6c, 0x12, 0x1a, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e, 0x53,
+	0x74, 0x6f, 0x70, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1b, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e, 0x53, 0x74, 0x6f, 0x70, 0x43,
+	0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x44, 0x0a, 0x09,
+	0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x2e, 0x77, 0x65, 0x62, 0x63,
+	0x72, 0x61, 0x77, 0x6c, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c,
+	0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x48, 0x0a, 0x0d, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x73, 0x12, 0x1e, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e, 0x43,
+	0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x30, 0x01, 0x42, 0x23, 0x5a, 0x21,
+	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x61, 0x63, 0x6b, 0x79,
+	0x75, 0x67, 0x69, 0x74, 0x2f, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2f, 0x72, 0x70,
+	0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_rpc_crawler_proto_rawDescOnce sync.Once
+	file_rpc_crawler_proto_rawDescData = file_rpc_crawler_proto_rawDesc
+)
+
+func file_rpc_crawler_proto_rawDescGZIP() []byte {
+	file_rpc_crawler_proto_rawDescOnce.Do(func() {
+		file_rpc_crawler_proto_rawDescData = protoimpl.X.CompressGZIP(file_rpc_crawler_proto_rawDescData)
+	})
+	return file_rpc_crawler_proto_rawDescData
+}
+
+var file_rpc_crawler_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_rpc_crawler_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_rpc_crawler_proto_goTypes = []any{
+	(GetStatusResponse_State)(0), // 0: webcrawl.GetStatusResponse.State
+	(*StartCrawlRequest)(nil),    // 1: webcrawl.StartCrawlRequest
+	(*StartCrawlResponse)(nil),   // 2: webcrawl.StartCrawlResponse
+	(*StopCrawlRequest)(nil),     // 3: webcrawl.StopCrawlRequest
+	(*StopCrawlResponse)(nil),    // 4: webcrawl.StopCrawlResponse
+	(*GetStatusRequest)(nil),     // 5: webcrawl.GetStatusRequest
+	(*GetStatusResponse)(nil),    // 6: webcrawl.GetStatusResponse
+	(*StreamResultsRequest)(nil), // 7: webcrawl.StreamResultsRequest
+	(*streampb.CrawlResult)(nil), // 8: webcrawl.CrawlResult
+}
+var file_rpc_crawler_proto_depIdxs = []int32{
+	0, // 0: webcrawl.GetStatusResponse.state:type_name -> webcrawl.GetStatusResponse.State
+	1, // 1: webcrawl.Crawler.StartCrawl:input_type -> webcrawl.StartCrawlRequest
+	3, // 2: webcrawl.Crawler.StopCrawl:input_type -> webcrawl.StopCrawlRequest
+	5, // 3: webcrawl.Crawler.GetStatus:input_type -> webcrawl.GetStatusRequest
+	7, // 4: webcrawl.Crawler.StreamResults:input_type -> webcrawl.StreamResultsRequest
+	2, // 5: webcrawl.Crawler.StartCrawl:output_type -> webcrawl.StartCrawlResponse
+	4, // 6: webcrawl.Crawler.StopCrawl:output_type -> webcrawl.StopCrawlResponse
+	6, // 7: webcrawl.Crawler.GetStatus:output_type -> webcrawl.GetStatusResponse
+	8, // 8: webcrawl.Crawler.StreamResults:output_type -> webcrawl.CrawlResult
+	5, // [5:9] is the sub-list for method output_type
+	1, // [1:5] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_rpc_crawler_proto_init() }
+func file_rpc_crawler_proto_init() {
+	if File_rpc_crawler_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_rpc_crawler_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*StartCrawlRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_crawler_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*StartCrawlResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_crawler_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*StopCrawlRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_crawler_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*StopCrawlResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_crawler_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*GetStatusRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_crawler_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*GetStatusResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_crawler_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*StreamResultsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_rpc_crawler_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rpc_crawler_proto_goTypes,
+		DependencyIndexes: file_rpc_crawler_proto_depIdxs,
+		EnumInfos:         file_rpc_crawler_proto_enumTypes,
+		MessageInfos:      file_rpc_crawler_proto_msgTypes,
+	}.Build()
+	File_rpc_crawler_proto = out.File
+	file_rpc_crawler_proto_rawDesc = nil
+	file_rpc_crawler_proto_goTypes = nil
+	file_rpc_crawler_proto_depIdxs = nil
+}
@@ -0,0 +1,253 @@
+// The Crawler service package rpc serves over gRPC, for other services to
+// start crawls, follow them and take their results as they come.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: rpc/crawler.proto
+
+package rpc
+
+import (
+	context "context"
+	streampb "github.com/jackyugit/webcrawl/stream/streampb"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Crawler_StartCrawl_FullMethodName    = "/webcrawl.Crawler/StartCrawl"
+	Crawler_StopCrawl_FullMethodName     = "/webcrawl.Crawler/StopCrawl"
+	Crawler_GetStatus_FullMethodName     = "/webcrawl.Crawler/GetStatus"
+	Crawler_StreamResults_FullMethodName = "/webcrawl.Crawler/StreamResults"
+)
+
+// CrawlerClient is the client API for Crawler service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CrawlerClient interface {
+	// StartCrawl starts crawling from a seed, and returns the id of the crawl
+	StartCrawl(ctx context.Context, in *StartCrawlRequest, opts ...grpc.CallOption) (*StartCrawlResponse, error)
+	// StopCrawl calls a crawl off, the fetches under way finish first
+	StopCrawl(ctx context.Context, in *StopCrawlRequest, opts ...grpc.CallOption) (*StopCrawlResponse, error)
+	// GetStatus tells where a crawl stands
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	// StreamResults sends the result of every fetch of a crawl from now on,
+	// and ends with the crawl
+	StreamResults(ctx context.Context, in *StreamResultsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[streampb.CrawlResult], error)
+}
+
+type crawlerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCrawlerClient(cc grpc.ClientConnInterface) CrawlerClient {
+	return &crawlerClient{cc}
+}
+
+func (c *crawlerClient) StartCrawl(ctx context.Context, in *StartCrawlRequest, opts ...grpc.CallOption) (*StartCrawlResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartCrawlResponse)
+	err := c.cc.Invoke(ctx, Crawler_StartCrawl_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crawlerClient) StopCrawl(ctx context.Context, in *StopCrawlRequest, opts ...grpc.CallOption) (*StopCrawlResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StopCrawlResponse)
+	err := c.cc.Invoke(ctx, Crawler_StopCrawl_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crawlerClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatusResponse)
+	err := c.cc.Invoke(ctx, Crawler_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crawlerClient) StreamResults(ctx context.Context, in *StreamResultsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[streampb.CrawlResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Crawler_ServiceDesc.Streams[0], Crawler_StreamResults_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamResultsRequest, streampb.CrawlResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Crawler_StreamResultsClient = grpc.ServerStreamingClient[streampb.CrawlResult]
+
+// CrawlerServer is the server API for Crawler service.
+// All implementations must embed UnimplementedCrawlerServer
+// for forward compatibility.
+type CrawlerServer interface {
+	// StartCrawl starts crawling from a seed, and returns the id of the crawl
+	StartCrawl(context.Context, *StartCrawlRequest) (*StartCrawlResponse, error)
+	// StopCrawl calls a crawl off, the fetches under way finish first
+	StopCrawl(context.Context, *StopCrawlRequest) (*StopCrawlResponse, error)
+	// GetStatus tells where a crawl stands
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	// StreamResults sends the result of every fetch of a crawl from now on,
+	// and ends with the crawl
+	StreamResults(*StreamResultsRequest, grpc.ServerStreamingServer[streampb.CrawlResult]) error
+	mustEmbedUnimplementedCrawlerServer()
+}
+
+// UnimplementedCrawlerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCrawlerServer struct{}
+
+func (UnimplementedCrawlerServer) StartCrawl(context.Context, *StartCrawlRequest) (*StartCrawlResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartCrawl not implemented")
+}
+func (UnimplementedCrawlerServer) StopCrawl(context.Context, *StopCrawlRequest) (*StopCrawlResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopCrawl not implemented")
+}
+func (UnimplementedCrawlerServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedCrawlerServer) StreamResults(*StreamResultsRequest, grpc.ServerStreamingServer[streampb.CrawlResult]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamResults not implemented")
+}
+func (UnimplementedCrawlerServer) mustEmbedUnimplementedCrawlerServer() {}
+func (UnimplementedCrawlerServer) testEmbeddedByValue()                 {}
+
+// UnsafeCrawlerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CrawlerServer will
+// result in compilation errors.
+type UnsafeCrawlerServer interface {
+	mustEmbedUnimplementedCrawlerServer()
+}
+
+func RegisterCrawlerServer(s grpc.ServiceRegistrar, srv CrawlerServer) {
+	// If the following call pancis, it indicates UnimplementedCrawlerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Crawler_ServiceDesc, srv)
+}
+
+func _Crawler_StartCrawl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartCrawlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrawlerServer).StartCrawl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Crawler_StartCrawl_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrawlerServer).StartCrawl(ctx, req.(*StartCrawlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Crawler_StopCrawl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopCrawlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrawlerServer).StopCrawl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Crawler_StopCrawl_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrawlerServer).StopCrawl(ctx, req.(*StopCrawlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Crawler_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrawlerServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Crawler_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrawlerServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Crawler_StreamResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamResultsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CrawlerServer).StreamResults(m, &grpc.GenericServerStream[StreamResultsRequest, streampb.CrawlResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Crawler_StreamResultsServer = grpc.ServerStreamingServer[streampb.CrawlResult]
+
+// Crawler_ServiceDesc is the grpc.ServiceDesc for Crawler service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Crawler_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "webcrawl.Crawler",
+	HandlerType: (*CrawlerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartCrawl",
+			Handler:    _Crawler_StartCrawl_Handler,
+		},
+		{
+			MethodName: "StopCrawl",
+			Handler:    _Crawler_StopCrawl_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Crawler_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamResults",
+			Handler:       _Crawler_StreamResults_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rpc/crawler.proto",
+}
@@ -0,0 +1,317 @@
+// Package rpc serves the Crawler service of crawler.proto over gRPC, for
+// other services to drive the crawler and take its results as they come,
+// rather than parse files or the output of the command:
+//
+//	s := &rpc.Server{Options: []webcrawl.Option{webcrawl.WithUserAgent("mybot")}}
+//	g := grpc.NewServer()
+//	rpc.RegisterCrawlerServer(g, s)
+//	lis, err := net.Listen("tcp", "localhost:9092")
+//	...
+//	log.Fatal(g.Serve(lis))
+//
+// StartCrawl starts a crawl and returns its id, for StopCrawl, GetStatus
+// and StreamResults, which sends the results as the CrawlResult message of
+// stream/crawl_result.proto. The crawls are the jobs of a
+// webcrawl.CrawlerManager, their ids those of the jobs. NewCrawlerClient
+// is a client of the service, the stubs being protoc's out of the .proto
+// files. Serve it with TLS credentials, or behind a proxy for TLS, it has
+// no authentication.
+package rpc
+
+//go:generate protoc -I .. --go_out=.. --go_opt=module=github.com/jackyugit/webcrawl --go-grpc_out=.. --go-grpc_opt=module=github.com/jackyugit/webcrawl rpc/crawler.proto
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/stream"
+)
+
+// DefaultStreamBuffer is how many results a StreamResults may fall behind
+// by, unless Server.StreamBuffer says otherwise.
+const DefaultStreamBuffer = 1024
+
+// Server is the Crawler service. Its zero value is ready to use.
+type Server struct {
+	UnimplementedCrawlerServer
+
+	// Manager runs the crawls, as jobs of its own, which it keeps until
+	//   their Remove. Nil means a CrawlerManager of the Server's own. The
+	//   jobs a shared Manager started otherwise have a status, but no
+	//   results to stream
+	Manager *webcrawl.CrawlerManager
+
+	// Options set up the Crawler of every crawl, after the Options of the
+	//   Manager and before those of the StartCrawlRequest.
+	//   webcrawl.WithOnResult is of no use, the results go to
+	//   StreamResults
+	Options []webcrawl.Option
+
+	// StreamBuffer is how many results a StreamResults may fall behind
+	//   the crawl by, sending them slower than they come, before it is
+	//   cut off with codes.ResourceExhausted: a slow client never holds
+	//   the crawl up. Zero means DefaultStreamBuffer
+	StreamBuffer int
+
+	once  sync.Once
+	m     *webcrawl.CrawlerManager
+	mu    sync.Mutex
+	feeds map[string]*feed // by job ID
+}
+
+// feed hands the results of a crawl over to its StreamResults
+type feed struct {
+	wait     bool          // the crawl holds until streamed
+	streamed chan struct{} // closed by the first StreamResults
+	once     sync.Once
+
+	mu   sync.Mutex
+	subs map[*subscriber]bool
+}
+
+// subscriber is a StreamResults under way
+type subscriber struct {
+	results chan webcrawl.CrawlResult
+	behind  chan struct{} // closed once it fell too far behind
+}
+
+// manager returns the CrawlerManager of the crawls
+func (s *Server) manager() *webcrawl.CrawlerManager {
+	s.once.Do(func() {
+		s.m = s.Manager
+		if s.m == nil {
+			s.m = &webcrawl.CrawlerManager{}
+		}
+	})
+	return s.m
+}
+
+// Handler returns the Server as an http.Handler speaking gRPC over HTTP/2
+// without TLS, for a server muxing it with others: see
+// grpc.Server.ServeHTTP, which checks a call is one before answering.
+func (s *Server) Handler() http.Handler {
+	g := grpc.NewServer()
+	RegisterCrawlerServer(g, s)
+	return h2c.NewHandler(g, &http2.Server{})
+}
+
+// StartCrawl starts a job of the Manager crawling from the seed.
+func (s *Server) StartCrawl(ctx context.Context, req *StartCrawlRequest) (*StartCrawlResponse, error) {
+	if req.Seed == "" {
+		return nil, status.Error(codes.InvalidArgument, "want a seed")
+	}
+	if req.MaxDepth < 0 || req.MaxPages < 0 || req.Workers < 0 {
+		return nil, status.Error(codes.InvalidArgument, "want a max_depth, max_pages and workers of 0 or more")
+	}
+	f := &feed{wait: req.WaitForStream, streamed: make(chan struct{}), subs: make(map[*subscriber]bool)}
+	opts := append([]webcrawl.Option(nil), s.Options...)
+	if req.MaxDepth > 0 {
+		opts = append(opts, webcrawl.WithDepth(int(req.MaxDepth)))
+	}
+	if req.MaxPages > 0 {
+		opts = append(opts, webcrawl.WithMaxPages(int(req.MaxPages)))
+	}
+	if req.Workers > 0 {
+		opts = append(opts, webcrawl.WithConcurrency(int(req.Workers)))
+	}
+	opts = append(opts, webcrawl.WithOnResult(f.add))
+	if f.wait {
+		opts = append(opts, webcrawl.WithMiddleware(webcrawl.Around(f.hold)))
+	}
+	job, err := s.manager().Start([]string{req.Seed}, opts...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.mu.Lock()
+	if s.feeds == nil {
+		s.feeds = make(map[string]*feed)
+	}
+	s.feeds[job.ID] = f
+	s.mu.Unlock()
+	return &StartCrawlResponse{Id: job.ID}, nil
+}
+
+// hold holds every fetch of a crawl, the first one and so its results,
+// until a StreamResults is under way
+func (f *feed) hold(ctx context.Context, call webcrawl.FetchCall) (*webcrawl.Response, error) {
+	select {
+	case <-f.streamed:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return call.Next(ctx)
+}
+
+// held reports whether the crawl is held until a StreamResults
+func (f *feed) held() bool {
+	if !f.wait {
+		return false
+	}
+	select {
+	case <-f.streamed:
+		return false
+	default:
+		return true
+	}
+}
+
+// add is the OnResult of the crawl, it hands res to every StreamResults
+// without ever waiting: those too far behind to take it are cut off
+func (f *feed) add(res webcrawl.CrawlResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		select {
+		case sub.results <- res:
+		default:
+			delete(f.subs, sub)
+			close(sub.behind)
+		}
+	}
+}
+
+// subscribe returns a subscriber to the results, buffering n of them
+func (f *feed) subscribe(n int) *subscriber {
+	sub := &subscriber{results: make(chan webcrawl.CrawlResult, n), behind: make(chan struct{})}
+	f.mu.Lock()
+	f.subs[sub] = true
+	f.mu.Unlock()
+	f.once.Do(func() { close(f.streamed) })
+	return sub
+}
+
+func (f *feed) unsubscribe(sub *subscriber) {
+	f.mu.Lock()
+	delete(f.subs, sub)
+	f.mu.Unlock()
+}
+
+// lookup returns the job of the crawl id, and its feed, nil for a job the
+// Server did not start
+func (s *Server) lookup(id string) (*webcrawl.Job, *feed, error) {
+	job, ok := s.manager().Job(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.feeds[id]
+	if !ok {
+		// Removed from the Manager meanwhile, maybe
+		delete(s.feeds, id)
+		return nil, nil, status.Errorf(codes.NotFound, "no crawl %q", id)
+	}
+	return job, f, nil
+}
+
+// StopCrawl cancels the job of the crawl.
+func (s *Server) StopCrawl(ctx context.Context, req *StopCrawlRequest) (*StopCrawlResponse, error) {
+	job, _, err := s.lookup(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	job.Cancel()
+	return &StopCrawlResponse{}, nil
+}
+
+// GetStatus tells where the job of the crawl stands, see Job.Status.
+func (s *Server) GetStatus(ctx context.Context, req *GetStatusRequest) (*GetStatusResponse, error) {
+	job, f, err := s.lookup(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	st := job.Status()
+	resp := &GetStatusResponse{
+		Id:      st.ID,
+		Error:   st.Error,
+		Fetched: int32(st.Fetched),
+		Failed:  int32(st.Failed),
+		Bytes:   st.Bytes,
+	}
+	if len(st.Seeds) > 0 {
+		resp.Seed = st.Seeds[0]
+	}
+	switch st.State {
+	case webcrawl.JobWaiting:
+		resp.State = GetStatusResponse_WAITING
+	case webcrawl.JobRunning:
+		resp.State = GetStatusResponse_RUNNING
+		if f != nil && f.held() {
+			resp.State = GetStatusResponse_WAITING
+		}
+	case webcrawl.JobFinished:
+		resp.State = GetStatusResponse_FINISHED
+	case webcrawl.JobStopped:
+		resp.State = GetStatusResponse_STOPPED
+	case webcrawl.JobFailed:
+		resp.State = GetStatusResponse_FAILED
+	}
+	if run := st.Run; run != nil {
+		resp.Queued = int32(run.Queued)
+		resp.InFlight = int32(run.InFlight)
+		resp.PagesPerSecond = run.Rate
+		resp.Paused = run.Paused
+	}
+	elapsed := st.Elapsed
+	if resp.State == GetStatusResponse_WAITING {
+		elapsed = time.Since(st.Created)
+	}
+	resp.ElapsedMs = elapsed.Milliseconds()
+	return resp, nil
+}
+
+// StreamResults sends the results of the crawl from now on, and ends with
+// it, or with codes.ResourceExhausted when the client takes them slower
+// than they come, by more than the StreamBuffer.
+func (s *Server) StreamResults(req *StreamResultsRequest, srv Crawler_StreamResultsServer) error {
+	job, f, err := s.lookup(req.Id)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return status.Errorf(codes.FailedPrecondition, "crawl %q was not started by StartCrawl, it has no results to stream", req.Id)
+	}
+	n := s.StreamBuffer
+	if n <= 0 {
+		n = DefaultStreamBuffer
+	}
+	sub := f.subscribe(n)
+	defer f.unsubscribe(sub)
+	for {
+		select {
+		case res := <-sub.results:
+			if err := srv.Send(stream.ProtoResult(res)); err != nil {
+				return err
+			}
+		case <-sub.behind:
+			return status.Errorf(codes.ResourceExhausted, "fell behind the crawl by more than %d results", n)
+		case <-job.Done():
+			// The results of the crawl are all in by now, unless it was
+			// cut off
+			for {
+				select {
+				case res := <-sub.results:
+					if err := srv.Send(stream.ProtoResult(res)); err != nil {
+						return err
+					}
+					continue
+				default:
+				}
+				select {
+				case <-sub.behind:
+					return status.Errorf(codes.ResourceExhausted, "fell behind the crawl by more than %d results", n)
+				default:
+					return nil
+				}
+			}
+		case <-srv.Context().Done():
+			return status.FromContextError(srv.Context().Err()).Err()
+		}
+	}
+}
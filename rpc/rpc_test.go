@@ -0,0 +1,240 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/rpc"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// serve serves s over gRPC on a local port and returns a client of it
+func serve(t *testing.T, s *rpc.Server) rpc.CrawlerClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := grpc.NewServer()
+	rpc.RegisterCrawlerServer(g, s)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	return dial(t, lis.Addr().String())
+}
+
+func dial(t *testing.T, addr string) rpc.CrawlerClient {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rpc.NewCrawlerClient(conn)
+}
+
+// newSite returns a site of n pages linked from its home page, served on a
+// local port, each of them with a body of size bytes
+func newSite(t *testing.T, n, size int) (*testsite.Site, string) {
+	t.Helper()
+	site := testsite.New("http://site.test")
+	var links []string
+	for i := range n {
+		p := fmt.Sprintf("/p/%d", i)
+		links = append(links, p)
+		site.Add(p, &testsite.Page{Body: strings.Repeat("x", size)})
+	}
+	site.Add("/", &testsite.Page{Links: links})
+	srv := httptest.NewServer(site.Handler())
+	t.Cleanup(srv.Close)
+	return site, srv.URL
+}
+
+func newServer() *rpc.Server {
+	return &rpc.Server{Options: []webcrawl.Option{
+		webcrawl.WithFetcher(webcrawl.NewHTTPFetcher(5 * time.Second)),
+		webcrawl.WithoutRobots(),
+	}}
+}
+
+// waitOver returns the status of the crawl id once it is over
+func waitOver(t *testing.T, client rpc.CrawlerClient, id string) *rpc.GetStatusResponse {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		st, err := client.GetStatus(context.Background(), &rpc.GetStatusRequest{Id: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch st.State {
+		case rpc.GetStatusResponse_FINISHED, rpc.GetStatusResponse_STOPPED, rpc.GetStatusResponse_FAILED:
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("crawl %s still %v", id, st.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamResults(t *testing.T) {
+	_, base := newSite(t, 5, 100)
+	client := serve(t, newServer())
+	ctx := context.Background()
+	started, err := client.StartCrawl(ctx, &rpc.StartCrawlRequest{Seed: base + "/", WaitForStream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := client.GetStatus(ctx, &rpc.GetStatusRequest{Id: started.Id})
+	if err != nil || st.State != rpc.GetStatusResponse_WAITING || st.Fetched != 0 {
+		t.Fatalf("before the stream: %v, %v, want WAITING with nothing fetched", st, err)
+	}
+	results, err := client.StreamResults(ctx, &rpc.StreamResultsRequest{Id: started.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for {
+		res, err := results.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != http.StatusOK {
+			t.Errorf("%s: status %d", res.Url, res.Status)
+		}
+		urls = append(urls, strings.TrimPrefix(res.Url, base))
+	}
+	sort.Strings(urls)
+	if got := strings.Join(urls, " "); got != "/ /p/0 /p/1 /p/2 /p/3 /p/4" {
+		t.Errorf("streamed %s", got)
+	}
+	st = waitOver(t, client, started.Id)
+	if st.State != rpc.GetStatusResponse_FINISHED || st.Fetched != 6 || st.Seed != base+"/" || st.Bytes < 500 {
+		t.Errorf("status %v, want FINISHED with 6 pages fetched", st)
+	}
+}
+
+func TestSlowStreamDoesNotHoldTheCrawl(t *testing.T) {
+	// Enough for the transport to stop taking what the stream sends
+	site, base := newSite(t, 200, 64<<10)
+	s := newServer()
+	s.StreamBuffer = 4
+	client := serve(t, s)
+	ctx := context.Background()
+	started, err := client.StartCrawl(ctx, &rpc.StartCrawlRequest{Seed: base + "/", WaitForStream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := client.StreamResults(ctx, &rpc.StreamResultsRequest{Id: started.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not reading a thing until the crawl is over
+	st := waitOver(t, client, started.Id)
+	if st.State != rpc.GetStatusResponse_FINISHED || st.Fetched != 201 {
+		t.Errorf("status %v, want FINISHED with 201 pages fetched", st)
+	}
+	if total, _ := site.TotalFetches(); total != 201 {
+		t.Errorf("%d fetches, want 201", total)
+	}
+	var n int
+	for {
+		_, err = results.Recv()
+		if err != nil {
+			break
+		}
+		n++
+	}
+	if status.Code(err) != codes.ResourceExhausted || n >= 201 {
+		t.Errorf("slow stream ended with %v after %d results, want ResourceExhausted before the end", err, n)
+	}
+}
+
+func TestStopCrawl(t *testing.T) {
+	_, base := newSite(t, 3, 10)
+	client := serve(t, newServer())
+	ctx := context.Background()
+	started, err := client.StartCrawl(ctx, &rpc.StartCrawlRequest{Seed: base + "/", WaitForStream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.StopCrawl(ctx, &rpc.StopCrawlRequest{Id: started.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if st := waitOver(t, client, started.Id); st.State != rpc.GetStatusResponse_STOPPED || st.Fetched != 0 {
+		t.Errorf("status %v, want STOPPED before fetching a thing", st)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	m := &webcrawl.CrawlerManager{}
+	client := serve(t, &rpc.Server{Manager: m})
+	ctx := context.Background()
+	for name, call := range map[string]func() error{
+		"no seed": func() error {
+			_, err := client.StartCrawl(ctx, &rpc.StartCrawlRequest{})
+			return err
+		},
+		"negative pages": func() error {
+			_, err := client.StartCrawl(ctx, &rpc.StartCrawlRequest{Seed: "http://site.test/", MaxPages: -1})
+			return err
+		},
+	} {
+		if code := status.Code(call()); code != codes.InvalidArgument {
+			t.Errorf("%s: %v, want InvalidArgument", name, code)
+		}
+	}
+	if _, err := client.GetStatus(ctx, &rpc.GetStatusRequest{Id: "42"}); status.Code(err) != codes.NotFound {
+		t.Errorf("status of no crawl: %v, want NotFound", err)
+	}
+	// A job of the Manager the Server didn't start
+	job, err := m.Start([]string{"http://site.test/"}, webcrawl.WithFetcher(testsite.New("http://site.test")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Wait()
+	if st, err := client.GetStatus(ctx, &rpc.GetStatusRequest{Id: job.ID}); err != nil || st.State != rpc.GetStatusResponse_FINISHED {
+		t.Errorf("status of a job of the Manager: %v, %v", st, err)
+	}
+	results, err := client.StreamResults(ctx, &rpc.StreamResultsRequest{Id: job.ID})
+	if err == nil {
+		_, err = results.Recv()
+	}
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("stream of a job of the Manager: %v, want FailedPrecondition", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(newServer().Handler())
+	defer srv.Close()
+	// Not a gRPC call, refused before anything is committed
+	resp, err := http.Post(srv.URL+"/webcrawl.Crawler/GetStatus", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Errorf("a call over HTTP/1.1 answered %s", resp.Status)
+	}
+	client := dial(t, strings.TrimPrefix(srv.URL, "http://"))
+	if _, err := client.GetStatus(context.Background(), &rpc.GetStatusRequest{Id: "1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("status over the Handler: %v, want NotFound", err)
+	}
+}
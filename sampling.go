@@ -0,0 +1,60 @@
+package webcrawl
+
+import "unicode/utf8"
+
+// BodySampling lightens the results a crawl hands over, for a crawl of
+// millions of pages out to map a site rather than keep its pages not to
+// need terabytes for their bodies, see Crawler.Bodies. The pages are
+// parsed, hashed and their text extracted from the whole of their body
+// all the same: only what the results carry to OnResult, Results and the
+// sinks after them is cut down, and BodyCut is set on those.
+type BodySampling struct {
+	// Every keeps the body of one result in Every of those with a body,
+	//   the first, then the Every+1th and so on, the others coming
+	//   without. Zero or 1 keeps them all
+	Every int
+
+	// MaxBytes cuts the bodies kept to their first MaxBytes bytes, short
+	//   of a UTF-8 character cut in two. Zero or less means no cap
+	MaxBytes int
+
+	// MetadataOnly leaves every body out, and the Text of the pages too:
+	//   the results are their URL, status, headers, links, sizes and the
+	//   like
+	MetadataOnly bool
+}
+
+// cut cuts down the body of res, the nth result with a body from 0 on
+func (s *BodySampling) cut(res *CrawlResult, n int64) {
+	if res.BodySize == 0 {
+		res.BodySize = int64(len(res.Body))
+	}
+	switch {
+	case s.MetadataOnly:
+		res.Body, res.Text = "", ""
+	case s.Every > 1 && n%int64(s.Every) != 0:
+		res.Body = ""
+	case s.MaxBytes > 0 && len(res.Body) > s.MaxBytes:
+		i := s.MaxBytes
+		for i > 0 && !utf8.RuneStart(res.Body[i]) {
+			i--
+		}
+		res.Body = res.Body[:i]
+	default:
+		return
+	}
+	res.BodyCut = true
+}
+
+// sample returns res as Bodies has it handed over
+func (r *run) sample(res CrawlResult) CrawlResult {
+	if r.Bodies == nil || res.Body == "" && res.Text == "" {
+		return res
+	}
+	var n int64
+	if res.Body != "" {
+		n = r.bodies.Add(1) - 1
+	}
+	r.Bodies.cut(&res, n)
+	return res
+}
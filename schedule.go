@@ -0,0 +1,156 @@
+package webcrawl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a time of the day the hosts of a Schedule may be crawled in,
+// such as 01:00 to 05:00 UTC. One whose To is before its From goes past
+// midnight, 22:00 to 06:00 for instance.
+type Window struct {
+	From, To time.Duration  // since midnight
+	Location *time.Location // of the times, UTC when nil
+}
+
+// ParseWindow parses a Window written "01:00-05:00", in UTC, or with a
+// location known to time.LoadLocation after it: "22:00-06:00
+// Europe/Paris".
+func ParseWindow(s string) (Window, error) {
+	span, zone, _ := strings.Cut(strings.TrimSpace(s), " ")
+	from, to, ok := strings.Cut(span, "-")
+	var w Window
+	var err1, err2 error
+	w.From, err1 = parseClock(from)
+	w.To, err2 = parseClock(to)
+	if !ok || err1 != nil || err2 != nil {
+		return Window{}, fmt.Errorf("webcrawl: window %q: want hh:mm-hh:mm", s)
+	}
+	if zone = strings.TrimSpace(zone); zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return Window{}, fmt.Errorf("webcrawl: window %q: %w", s, err)
+		}
+		w.Location = loc
+	}
+	return w, nil
+}
+
+// parseClock parses a time of the day, hh:mm, into the time since midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String returns w as ParseWindow reads it.
+func (w Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	s := clock(w.From) + "-" + clock(w.To)
+	if w.Location != nil && w.Location != time.UTC {
+		s += " " + w.Location.String()
+	}
+	return s
+}
+
+// next returns t when it is in w, else when w opens next
+func (w Window) next(t time.Time) time.Time {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	lt := t.In(loc)
+	length := w.To - w.From
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	var next time.Time
+	// The window of the day before may still be open, past midnight
+	for day := -1; day <= 1; day++ {
+		midnight := time.Date(lt.Year(), lt.Month(), lt.Day()+day, 0, 0, 0, 0, loc)
+		open := midnight.Add(w.From)
+		if !t.Before(open) && t.Before(open.Add(length)) {
+			return t
+		}
+		if open.After(t) && (next.IsZero() || open.Before(next)) {
+			next = open
+		}
+	}
+	return next
+}
+
+// Schedule says when the hosts may be crawled, see Crawler.Schedule: the
+// URLs of a host out of its windows are held back until the next one
+// opens, the fetches under way carrying on.
+type Schedule struct {
+	// Windows are those of the hosts without windows of their own, in
+	//   Hosts by hostname. A host without any may be crawled at any time
+	Windows []Window
+	Hosts   map[string][]Window
+}
+
+// Next returns when host may be crawled next from t on: t itself when it
+// is in one of the windows of the host.
+func (s *Schedule) Next(host string, t time.Time) time.Time {
+	windows, ok := s.Hosts[host]
+	if !ok {
+		windows = s.Windows
+	}
+	if len(windows) == 0 {
+		return t
+	}
+	var next time.Time
+	for _, w := range windows {
+		if n := w.next(t); next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// schedule pauses h, the state of the host name, until the Schedule lets
+// it be crawled, or until a pause of every host is over
+func (r *run) schedule(name string, h *hostState, now time.Time) {
+	until := r.pausedUntil
+	if r.Schedule != nil {
+		if open := r.Schedule.Next(name, now); open.After(until) {
+			until = open
+		}
+	}
+	if until.After(now) && until.After(h.until) {
+		if h.until.Before(now) {
+			r.log.Info("host paused", "host", name, "until", until)
+		}
+		h.until = until
+	}
+}
+
+// PauseHost holds off the fetches from host in the crawls under way until
+// until, the ones under way carrying on. It returns ErrNoCrawl when no
+// crawl is under way.
+func (c *Crawler) PauseHost(host string, until time.Time) error {
+	return c.control(func(r *run) error {
+		h := r.hostNamed(strings.ToLower(host))
+		if until.After(h.until) {
+			h.until = until
+		}
+		return nil
+	})
+}
+
+// PauseUntil holds off the fetches from every host in the crawls under way
+// until until, the ones under way carrying on. Unlike Pause, it needs no
+// Unpause. It returns ErrNoCrawl when no crawl is under way.
+func (c *Crawler) PauseUntil(until time.Time) error {
+	return c.control(func(r *run) error {
+		if until.After(r.pausedUntil) {
+			r.pausedUntil = until
+		}
+		return nil
+	})
+}
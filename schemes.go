@@ -0,0 +1,60 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fetcherFor returns the Fetcher of rawURL, which is normalized, or nil
+// when no Fetcher takes its scheme
+func (c *Crawler) fetcherFor(rawURL string) Fetcher {
+	if c.Fetchers == nil {
+		return c.Fetcher
+	}
+	scheme, _, _ := strings.Cut(rawURL, ":")
+	if f, ok := c.Fetchers[scheme]; ok {
+		return f
+	}
+	if isHTTP(rawURL) {
+		return c.Fetcher
+	}
+	return nil
+}
+
+// schemeFetcher is the Fetcher of a run, handing every URL to the Fetcher
+// of its scheme
+type schemeFetcher struct {
+	c *Crawler
+}
+
+func (f schemeFetcher) get(url string) (Fetcher, error) {
+	if ff := f.c.fetcherFor(url); ff != nil {
+		return ff, nil
+	}
+	return nil, fmt.Errorf("webcrawl: no Fetcher for the scheme of %s", url)
+}
+
+func (f schemeFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	ff, err := f.get(url)
+	if err != nil {
+		return "", nil, err
+	}
+	return ff.Fetch(ctx, url)
+}
+
+func (f schemeFetcher) FetchResponse(ctx context.Context, url string) (*Response, error) {
+	ff, err := f.get(url)
+	if err != nil {
+		return nil, err
+	}
+	return FetchResponse(ctx, ff, url)
+}
+
+func (f schemeFetcher) Check(ctx context.Context, url string) error {
+	ff, err := f.get(url)
+	if err != nil {
+		return err
+	}
+	return Check(ctx, ff, url)
+}
@@ -0,0 +1,127 @@
+package webcrawl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SubdomainPolicy says which hosts besides the seed's own are in scope.
+type SubdomainPolicy int
+
+const (
+	// SameHost keeps the crawl on the seed's host: www.example.com
+	//   does not reach blog.example.com
+	SameHost SubdomainPolicy = iota
+
+	// SameRegistrableDomain keeps the crawl on the domain the seed's host
+	//   belongs to, with all its subdomains: www.example.com reaches
+	//   blog.example.com and example.com, but not example.org
+	SameRegistrableDomain
+
+	// AnyHost does not restrict hosts at all
+	AnyHost
+)
+
+// ScopeRules decide which URLs belong to the crawl, URLs out of scope
+// never enter the frontier. A URL is in scope when its host is allowed and
+// its path matches one of Include, if there are any, and none of Exclude.
+type ScopeRules struct {
+	// Subdomains is the policy for hosts related to the seed's
+	Subdomains SubdomainPolicy
+
+	// AllowedHosts lists more hosts in scope whatever the policy, a
+	//   "*." prefix allows every subdomain of what follows it
+	AllowedHosts []string
+
+	// Include and Exclude match against the URL path, see Glob to write
+	//   them as globs. The seed itself is exempt from them
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+
+	// Languages, when set, keeps the crawl to the pages in these
+	//   languages, as BCP 47 tags: the links of the pages in another are
+	//   not followed, the seed's excepted, see LanguageInScope
+	Languages []string
+}
+
+// InScope reports whether u is in the scope of a crawl started at seed.
+func (s *ScopeRules) InScope(seed, u *url.URL) bool {
+	return s.HostInScope(seed, u) && s.PathInScope(u.EscapedPath())
+}
+
+// HostInScope reports whether the host of u is in the scope of a crawl
+// started at seed.
+func (s *ScopeRules) HostInScope(seed, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, h := range s.AllowedHosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+
+	seedHost := strings.ToLower(seed.Hostname())
+	switch s.Subdomains {
+	case AnyHost:
+		return true
+	case SameRegistrableDomain:
+		if host == seedHost {
+			return true
+		}
+		d1, err1 := publicsuffix.EffectiveTLDPlusOne(host)
+		d2, err2 := publicsuffix.EffectiveTLDPlusOne(seedHost)
+		return err1 == nil && err2 == nil && d1 == d2
+	}
+	return host == seedHost
+}
+
+// PathInScope reports whether a URL path passes Include and Exclude.
+func (s *ScopeRules) PathInScope(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	for _, re := range s.Exclude {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, re := range s.Include {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Glob compiles a path glob into an anchored regexp: * matches within a
+// path segment, ** across segments and ? a single character other than /.
+// "/blog/**" thus matches everything under /blog/, "/*.html" only the
+// HTML pages at the root.
+func Glob(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
@@ -0,0 +1,159 @@
+package webcrawl
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// ScrapeRule says how to scrape a field off the pages: by a CSS selector
+// or an XPath expression, from the text of the elements it matches or from
+// one of their attributes.
+type ScrapeRule struct {
+	Field string // the name of the field in CrawlResult.Fields
+
+	// CSS is a CSS selector, XPath an XPath 1.0 expression, one of them
+	//   is to be set. An XPath expression may select attributes, as in
+	//   //img/@src, or compute a number, a string or a boolean, as in
+	//   count(//article): the field is then that value
+	CSS   string
+	XPath string
+
+	// Attr takes the value of this attribute of the elements matched
+	//   rather than their text, trimmed. The URLs of href and src are
+	//   resolved
+	Attr string
+
+	// All makes the field the list of the values of every element
+	//   matched, a []string, rather than the value of the first one, a
+	//   string. The field is left out when nothing matches
+	All bool
+
+	// Path restricts the rule to the pages whose URL path matches it,
+	//   see Glob. When nil it applies to every page
+	Path *regexp.Regexp
+}
+
+// Scraper scrapes the fields of its rules off the pages, see
+// Crawler.Scraper. It can be shared by any number of goroutines.
+type Scraper struct {
+	rules []scrapeRule
+}
+
+type scrapeRule struct {
+	ScrapeRule
+	css   cascadia.Selector
+	xpath *xpath.Expr
+}
+
+// NewScraper returns a Scraper with rules, failing on a selector or an
+// expression that doesn't compile.
+func NewScraper(rules ...ScrapeRule) (*Scraper, error) {
+	s := &Scraper{}
+	for _, r := range rules {
+		c := scrapeRule{ScrapeRule: r}
+		var err error
+		switch {
+		case r.Field == "":
+			return nil, fmt.Errorf("webcrawl: scrape rule without a field")
+		case (r.CSS == "") == (r.XPath == ""):
+			return nil, fmt.Errorf("webcrawl: scrape rule %s: want either CSS or XPath", r.Field)
+		case r.CSS != "":
+			c.css, err = cascadia.Compile(r.CSS)
+		default:
+			c.xpath, err = xpath.Compile(r.XPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("webcrawl: scrape rule %s: %w", r.Field, err)
+		}
+		s.rules = append(s.rules, c)
+	}
+	return s, nil
+}
+
+// Scrape returns the fields of the HTML document doc, found at page, by
+// the rules that apply to it. It returns nil when no field was found.
+func (s *Scraper) Scrape(page *url.URL, doc *html.Node) map[string]any {
+	path := page.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if b := findBase(doc); b != "" {
+		if u, err := page.Parse(b); err == nil {
+			page = u
+		}
+	}
+	var fields map[string]any
+	for _, r := range s.rules {
+		if r.Path != nil && !r.Path.MatchString(path) {
+			continue
+		}
+		v, ok := r.scrape(page, doc)
+		if !ok {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]any)
+		}
+		fields[r.Field] = v
+	}
+	return fields
+}
+
+// scrape returns the value of the field of r in doc, ok is false when
+// nothing matched
+func (r *scrapeRule) scrape(base *url.URL, doc *html.Node) (v any, ok bool) {
+	var values []string
+	if r.css != nil {
+		for _, n := range r.css.MatchAll(doc) {
+			values = append(values, r.value(base, n))
+			if !r.All {
+				break
+			}
+		}
+	} else {
+		switch v := r.xpath.Evaluate(htmlquery.CreateXPathNavigator(doc)).(type) {
+		case *xpath.NodeIterator:
+			for v.MoveNext() {
+				nav := v.Current().(*htmlquery.NodeNavigator)
+				if nav.NodeType() == xpath.ElementNode {
+					values = append(values, r.value(base, nav.Current()))
+				} else {
+					// An attribute or a text node
+					values = append(values, strings.TrimSpace(nav.Value()))
+				}
+				if !r.All {
+					break
+				}
+			}
+		default:
+			// A number, a string or a boolean
+			return v, true
+		}
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+	if r.All {
+		return values, true
+	}
+	return values[0], true
+}
+
+// value returns the value of the element n matched by r
+func (r *scrapeRule) value(base *url.URL, n *html.Node) string {
+	if r.Attr == "" {
+		return strings.Join(strings.Fields(nodeText(n)), " ")
+	}
+	v, _ := attr(n, r.Attr)
+	if r.Attr == "href" || r.Attr == "src" {
+		return resolveRef(base, v)
+	}
+	return strings.TrimSpace(v)
+}
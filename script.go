@@ -0,0 +1,320 @@
+package webcrawl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Script steers a crawl from a script, for those who'd rather not write Go,
+// see ScriptHooks. It has three functions, any of which the script may
+// leave out:
+//
+//	should_follow(url)  whether to crawl a URL found, true when left out
+//	extract(page)       the fields to take off a page fetched, into CrawlResult.Fields
+//	transform(page)     the page changed, its links, text and fields, or false to drop its result
+//
+// OpenScript opens one by the extension of its file, with the engines
+// registered with RegisterScriptEngine: StarlarkScript, sandboxed, is built
+// in for ".star" files.
+type Script interface {
+	ShouldFollow(ctx context.Context, url string) (bool, error)
+	Extract(ctx context.Context, page *ScriptPage) (map[string]any, error)
+
+	// Transform changes page in place, and reports whether its result is
+	//   to be kept
+	Transform(ctx context.Context, page *ScriptPage) (bool, error)
+
+	Close() error
+}
+
+// ScriptPage is a page as a Script sees it, in JSON.
+type ScriptPage struct {
+	URL         string         `json:"url"`
+	Depth       int            `json:"depth"`
+	StatusCode  int            `json:"status,omitempty"`
+	ContentType string         `json:"content_type,omitempty"`
+	Body        string         `json:"body,omitempty"` // for extract only
+	Links       []string       `json:"links"`
+	Text        string         `json:"text,omitempty"`
+	Fields      map[string]any `json:"fields,omitempty"`
+}
+
+var (
+	scriptEnginesMu sync.Mutex
+	scriptEngines   = map[string]func(path string) (Script, error){
+		".star": func(path string) (Script, error) { return OpenStarlarkScript(path) },
+	}
+)
+
+// RegisterScriptEngine has OpenScript open the scripts of the extension
+// ext, such as ".star" or ".lua", with open.
+func RegisterScriptEngine(ext string, open func(path string) (Script, error)) {
+	scriptEnginesMu.Lock()
+	defer scriptEnginesMu.Unlock()
+	scriptEngines[strings.ToLower(ext)] = open
+}
+
+// OpenScript opens the script at path, with the engine registered for its
+// extension. It never runs an executable, see ProcessScript.
+func OpenScript(path string) (Script, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	scriptEnginesMu.Lock()
+	open := scriptEngines[ext]
+	scriptEnginesMu.Unlock()
+	if open == nil {
+		return nil, fmt.Errorf("webcrawl: %s: no script engine for %q files", path, ext)
+	}
+	return open(path)
+}
+
+// DefaultScriptTimeout is how long a call to a ProcessScript may take when
+// its Timeout is not set
+const DefaultScriptTimeout = 10 * time.Second
+
+// ErrScriptTimeout is the cause of the calls to a ProcessScript that took
+// longer than its Timeout.
+var ErrScriptTimeout = errors.New("webcrawl: script timed out")
+
+// ProcessScript is a Script running as a process of its own, written in any
+// language. It reads a call per line on its standard input, as JSON, and
+// answers each with a line on its standard output, in order:
+//
+//	{"call": "should_follow", "url": "https://example.com/a"}  {"result": true}
+//	{"call": "extract", "page": {"url": ..., "body": ...}}      {"result": {"title": "A"}}
+//	{"call": "transform", "page": {"url": ..., "links": ...}}   {"result": {"url": ..., "links": [...]}}
+//
+// A result of null does what a function left out does, an answer of
+// {"error": "..."} fails the call. The process gets no environment but
+// PATH, runs in the directory of the script, and its standard error is the
+// one of this process. A process that dies, or takes longer than Timeout
+// and is killed for it, fails the calls after. The calls are made one at a
+// time.
+//
+// It is no sandbox: the program runs with the rights of this process, to
+// do whatever they allow. OpenScript never starts one, nor does the
+// command, for a config file not to run a program of its choosing: only a
+// Go program that trusts the script does, with NewProcessScript.
+type ProcessScript struct {
+	// Timeout is how long a call may take, DefaultScriptTimeout when zero
+	Timeout time.Duration
+
+	cmd  *exec.Cmd
+	mu   sync.Mutex
+	in   io.WriteCloser
+	out  *bufio.Reader
+	dead error
+}
+
+// NewProcessScript starts command, the program and its arguments, as a
+// ProcessScript. Close it once the crawl is over.
+func NewProcessScript(command ...string) (*ProcessScript, error) {
+	if len(command) == 0 {
+		return nil, errors.New("webcrawl: no script command")
+	}
+	name := command[0]
+	if strings.ContainsRune(name, filepath.Separator) {
+		// Run from its own directory, a relative path is no longer one
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+	}
+	cmd := exec.Command(name, command[1:]...)
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	cmd.Dir = filepath.Dir(name)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("webcrawl: script: %w", err)
+	}
+	return &ProcessScript{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// scriptAnswer is a line a ProcessScript answers with
+type scriptAnswer struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// call makes the call of req and returns its result, nil for null
+func (s *ProcessScript) call(ctx context.Context, req map[string]any) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dead != nil {
+		return nil, s.dead
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+	type read struct {
+		line []byte
+		err  error
+	}
+	answered := make(chan read, 1)
+	go func() {
+		if _, err := s.in.Write(append(line, '\n')); err != nil {
+			answered <- read{nil, err}
+			return
+		}
+		b, err := s.out.ReadBytes('\n')
+		answered <- read{b, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var got read
+	select {
+	case got = <-answered:
+	case <-timer.C:
+		// Its answer could still come, in place of the next one's
+		s.cmd.Process.Kill()
+		s.dead = fmt.Errorf("%w after %v", ErrScriptTimeout, timeout)
+		return nil, s.dead
+	}
+	if got.err != nil {
+		s.dead = fmt.Errorf("webcrawl: script: %w", got.err)
+		return nil, s.dead
+	}
+	var a scriptAnswer
+	if err := json.Unmarshal(got.line, &a); err != nil {
+		return nil, fmt.Errorf("webcrawl: script: answer to %s: %w", req["call"], err)
+	}
+	if a.Error != "" {
+		return nil, fmt.Errorf("webcrawl: script: %s: %s", req["call"], a.Error)
+	}
+	if len(a.Result) == 0 || string(a.Result) == "null" {
+		return nil, nil
+	}
+	return a.Result, nil
+}
+
+// ShouldFollow implements Script.
+func (s *ProcessScript) ShouldFollow(ctx context.Context, url string) (bool, error) {
+	res, err := s.call(ctx, map[string]any{"call": "should_follow", "url": url})
+	if err != nil || res == nil {
+		return true, err
+	}
+	follow := true
+	if err := json.Unmarshal(res, &follow); err != nil {
+		return true, fmt.Errorf("webcrawl: script: should_follow: %w", err)
+	}
+	return follow, nil
+}
+
+// Extract implements Script.
+func (s *ProcessScript) Extract(ctx context.Context, page *ScriptPage) (map[string]any, error) {
+	res, err := s.call(ctx, map[string]any{"call": "extract", "page": page})
+	if err != nil || res == nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(res, &fields); err != nil {
+		return nil, fmt.Errorf("webcrawl: script: extract: %w", err)
+	}
+	return fields, nil
+}
+
+// Transform implements Script.
+func (s *ProcessScript) Transform(ctx context.Context, page *ScriptPage) (bool, error) {
+	res, err := s.call(ctx, map[string]any{"call": "transform", "page": page})
+	if err != nil || res == nil {
+		return true, err
+	}
+	if string(res) == "false" {
+		return false, nil
+	}
+	changed := *page
+	if err := json.Unmarshal(res, &changed); err != nil {
+		return true, fmt.Errorf("webcrawl: script: transform: %w", err)
+	}
+	*page = changed
+	return true, nil
+}
+
+// Close ends the script, closing its standard input for it to exit, and
+// killing it if it hasn't within a second.
+func (s *ProcessScript) Close() error {
+	s.in.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- s.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err != nil && s.dead == nil {
+			return fmt.Errorf("webcrawl: script: %w", err)
+		}
+		return nil
+	case <-time.After(time.Second):
+		s.cmd.Process.Kill()
+		<-exited
+		return nil
+	}
+}
+
+// ScriptHooks returns the Hooks running s: should_follow for every URL
+// about to be queued, the seed included, then extract and transform for
+// every page fetched. should_follow runs in the dispatcher of the crawl,
+// which waits on it. A call that fails is reported to onError, when it is
+// not nil, and does what a function left out does.
+func ScriptHooks(s Script, onError func(error)) *Hooks {
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	return &Hooks{
+		OnEnqueue: func(ctx context.Context, it FrontierItem) bool {
+			follow, err := s.ShouldFollow(ctx, it.URL)
+			report(err)
+			return follow
+		},
+		OnResult: func(ctx context.Context, res *CrawlResult) bool {
+			if res.Duplicate || res.Skipped != "" {
+				return true
+			}
+			page := &ScriptPage{URL: res.URL, Depth: res.Depth, StatusCode: res.StatusCode, Body: res.Body, Links: res.Links, Text: res.Text, Fields: res.Fields}
+			if res.Header != nil {
+				page.ContentType = res.Header.Get("Content-Type")
+			}
+			fields, err := s.Extract(ctx, page)
+			report(err)
+			if len(fields) > 0 {
+				if page.Fields == nil {
+					page.Fields = make(map[string]any, len(fields))
+				}
+				for k, v := range fields {
+					page.Fields[k] = v
+				}
+			}
+			page.Body = ""
+			keep, err := s.Transform(ctx, page)
+			report(err)
+			res.Links, res.Text, res.Fields = page.Links, page.Text, page.Fields
+			return keep
+		},
+	}
+}
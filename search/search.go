@@ -0,0 +1,184 @@
+// Package search indexes the pages of a crawl in a Bleve full-text index
+// on disk, their URL, title and text, for the crawled site to be searched
+// once the crawl is over.
+//
+// It is a package of its own so that the crawler doesn't depend on Bleve
+// unless it is needed:
+//
+//	idx, err := search.Open("site.bleve")
+//	...
+//	defer idx.Close()
+//	c := webcrawl.NewCrawler(webcrawl.WithOnResult(func(r webcrawl.CrawlResult) {
+//		if err := idx.Add(r); err != nil {
+//			log.Print(err)
+//		}
+//	}))
+//	...
+//	hits, err := idx.Search("shipping +returns", 10)
+package search
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"golang.org/x/net/html"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// BatchSize is how many pages an Index adds to Bleve at once.
+const BatchSize = 100
+
+// Index is a Bleve index of pages. Close it once done, for the last pages
+// added to be written. An Index is safe for concurrent use.
+type Index struct {
+	index bleve.Index
+
+	mu    sync.Mutex
+	batch *bleve.Batch
+}
+
+// page is the document a page is indexed as
+type page struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	Depth     int       `json:"depth"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Open opens the index at path, creating it when there is none.
+func Open(path string) (*Index, error) {
+	idx, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		idx, err = bleve.New(path, newMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Index{index: idx, batch: idx.NewBatch()}, nil
+}
+
+// newMapping returns the mapping of the pages: the URL as it is, the title
+// and the text analyzed in English
+func newMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = "en"
+	keyword := bleve.NewKeywordFieldMapping()
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("url", keyword)
+	doc.AddFieldMappingsAt("title", text)
+	doc.AddFieldMappingsAt("text", text)
+	doc.AddFieldMappingsAt("depth", bleve.NewNumericFieldMapping())
+	doc.AddFieldMappingsAt("fetched_at", bleve.NewDateTimeFieldMapping())
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultField = "text"
+	return m
+}
+
+// Add indexes the page of a fetch that went fine, with its title and the
+// text of its main content, see webcrawl.ReadableText. The other results
+// are ignored: errors, duplicates and the pages noindex asks not to be
+// indexed. A page indexed before is replaced.
+func (x *Index) Add(res webcrawl.CrawlResult) error {
+	if res.Err != nil || res.External || res.Duplicate || res.NoIndex || res.Body == "" {
+		return nil
+	}
+	p := page{URL: res.URL, Text: res.Text, Depth: res.Depth, FetchedAt: res.FetchedAt}
+	if len(res.Redirects) > 0 {
+		p.URL = res.Redirects[len(res.Redirects)-1].To
+	}
+	if ct := res.Header.Get("Content-Type"); ct == "" || strings.Contains(strings.ToLower(ct), "html") {
+		doc, err := html.Parse(strings.NewReader(res.Body))
+		if err != nil {
+			return nil
+		}
+		if base, err := url.Parse(p.URL); err == nil {
+			p.Title = webcrawl.ExtractSEO(base, doc).Title
+		}
+		if p.Text == "" {
+			p.Text = webcrawl.ReadableText(doc)
+		}
+	} else if strings.HasPrefix(strings.ToLower(ct), "text/") {
+		p.Text = res.Body
+	} else {
+		return nil
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.batch.Index(p.URL, p); err != nil {
+		return err
+	}
+	if x.batch.Size() < BatchSize {
+		return nil
+	}
+	return x.flush()
+}
+
+// flush adds the pages of the batch to the index. The caller holds x.mu
+func (x *Index) flush() error {
+	if x.batch.Size() == 0 {
+		return nil
+	}
+	err := x.index.Batch(x.batch)
+	x.batch.Reset()
+	return err
+}
+
+// Hit is a page found by Search.
+type Hit struct {
+	URL   string
+	Title string
+	Score float64
+
+	// Fragments are the bits of the text around the terms found, with
+	//   them in <mark>
+	Fragments []string
+}
+
+// Search returns the n pages that best match query, best first. The query
+// is in the query string syntax of Bleve: words, "phrases", +required and
+// -excluded terms, title:word for a word of the title and so on. The pages
+// added but not written yet, see BatchSize, are written first.
+func (x *Index) Search(query string, n int) ([]Hit, error) {
+	x.mu.Lock()
+	err := x.flush()
+	x.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewQueryStringQuery(query), n, 0, false)
+	req.Fields = []string{"title"}
+	req.Highlight = bleve.NewHighlightWithStyle("html")
+	req.Highlight.AddField("text")
+	res, err := x.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, 0, len(res.Hits))
+	for _, m := range res.Hits {
+		h := Hit{URL: m.ID, Score: m.Score, Fragments: m.Fragments["text"]}
+		h.Title, _ = m.Fields["title"].(string)
+		hits = append(hits, h)
+	}
+	return hits, nil
+}
+
+// Close writes the pages added last and closes the index.
+func (x *Index) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	err := x.flush()
+	if cerr := x.index.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package search
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// htmlPage returns the HTML of a page titled title, with text in its main content
+func htmlPage(title, text string) string {
+	return "<html><head><title>" + title + "</title></head><body><nav>Home Shop</nav><main><p>" +
+		text + "</p></main></body></html>"
+}
+
+// urls returns the URLs of hits, sorted
+func urls(hits []Hit) []string {
+	var us []string
+	for _, h := range hits {
+		us = append(us, h.URL)
+	}
+	sort.Strings(us)
+	return us
+}
+
+// openTest returns an Index of a new directory, with the pages of a small
+// shop added
+func openTest(t *testing.T) (*Index, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "site.bleve")
+	x, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	for _, res := range []webcrawl.CrawlResult{
+		{URL: "https://shop.test/shipping", StatusCode: 200, Header: html, Body: htmlPage("Shipping", "We ship parcels worldwide within three days.")},
+		{URL: "https://shop.test/returns", StatusCode: 200, Header: html, Body: htmlPage("Returns", "Returns of parcels are free for thirty days.")},
+		{URL: "https://shop.test/notes.txt", StatusCode: 200, Header: http.Header{"Content-Type": {"text/plain"}}, Body: "Plain notes on shipping."},
+		{URL: "https://shop.test/old", StatusCode: 200, Header: html, Body: htmlPage("Moved", "The new shipping page."),
+			Redirects: []webcrawl.Redirect{{From: "https://shop.test/old", To: "https://shop.test/new", StatusCode: 301}}},
+		// Not indexed
+		{URL: "https://shop.test/err", Err: errors.New("refused"), Body: htmlPage("Error", "shipping")},
+		{URL: "https://shop.test/copy", Duplicate: true, Body: htmlPage("Shipping", "shipping")},
+		{URL: "https://shop.test/private", NoIndex: true, Body: htmlPage("Private", "shipping")},
+		{URL: "https://other.test/", External: true, Body: htmlPage("Other", "shipping")},
+		{URL: "https://shop.test/logo.png", Header: http.Header{"Content-Type": {"image/png"}}, Body: "shipping"},
+	} {
+		if err := x.Add(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return x, path
+}
+
+func TestSearch(t *testing.T) {
+	x, _ := openTest(t)
+	defer x.Close()
+
+	hits, err := x.Search("shipping", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://shop.test/new", "https://shop.test/notes.txt", "https://shop.test/shipping"}
+	if got := urls(hits); !reflect.DeepEqual(got, want) {
+		t.Errorf("hits of shipping %q, want %q", got, want)
+	}
+
+	hits, err = x.Search("parcels -free", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].URL != "https://shop.test/shipping" {
+		t.Fatalf("hits of parcels -free %+v, want /shipping", hits)
+	}
+	h := hits[0]
+	if h.Title != "Shipping" || h.Score <= 0 {
+		t.Errorf("hit %+v, want the title Shipping and a score", h)
+	}
+	if len(h.Fragments) == 0 || !strings.Contains(h.Fragments[0], "<mark>parcels</mark>") {
+		t.Errorf("fragments %q, want parcels marked", h.Fragments)
+	}
+	// The text is the one of the main content, not of the navigation
+	if hits, err := x.Search("shop", 10); err != nil || len(hits) != 0 {
+		t.Errorf("hits of shop %+v, %v, want none", hits, err)
+	}
+	if hits, err := x.Search("title:returns", 10); err != nil || len(hits) != 1 || hits[0].URL != "https://shop.test/returns" {
+		t.Errorf("hits of title:returns %+v, %v, want /returns", hits, err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	x, path := openTest(t)
+	// Added again, a page is replaced
+	if err := x.Add(webcrawl.CrawlResult{URL: "https://shop.test/returns", StatusCode: 200, Body: htmlPage("Returns", "Returns are no longer taken.")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	if hits, err := x.Search("worldwide", 10); err != nil || len(hits) != 1 {
+		t.Errorf("hits of worldwide after Open %+v, %v, want the page written by Close", hits, err)
+	}
+	if hits, err := x.Search("free", 10); err != nil || len(hits) != 0 {
+		t.Errorf("hits of free %+v, %v, want none, the page replaced", hits, err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	x, err := Open(filepath.Join(t.TempDir(), "site.bleve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	for i := 0; i < BatchSize+1; i++ {
+		url := "https://shop.test/p" + strings.Repeat("x", i)
+		if err := x.Add(webcrawl.CrawlResult{URL: url, Header: http.Header{"Content-Type": {"text/plain"}}, Body: "a page"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := x.index.DocCount(); err != nil || n != BatchSize {
+		t.Errorf("%d pages written, %v, want a batch of %d", n, err, BatchSize)
+	}
+	if x.batch.Size() != 1 {
+		t.Errorf("%d pages in the batch, want 1", x.batch.Size())
+	}
+}
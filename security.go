@@ -0,0 +1,266 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The issues a SecurityReport flags
+const (
+	SecurityMixedContent     = "mixed content"           // an HTTPS page loading an HTTP resource
+	SecurityInsecureLink     = "insecure link"           // an HTTPS page linking to an HTTP URL
+	SecurityInsecureRedirect = "insecure redirect"       // an HTTPS URL redirecting to an HTTP one
+	SecurityMissingHSTS      = "missing HSTS"            // an HTTPS response without Strict-Transport-Security
+	SecurityInsecureCookie   = "cookie without Secure"   // a cookie that may be sent over HTTP
+	SecurityScriptCookie     = "cookie without HttpOnly" // a cookie the scripts of the page can read
+)
+
+// SecurityIssue is an issue a SecurityReport found on a page.
+type SecurityIssue struct {
+	URL  string // the page
+	Kind string // SecurityMixedContent and the like
+
+	// Detail is what the issue is about: the HTTP URL of a mixed content,
+	//   insecure link or redirect, the name of a cookie, empty for a
+	//   missing HSTS
+	Detail string
+}
+
+// HostSecurity sums up the issues of the pages of a host.
+type HostSecurity struct {
+	Host       string
+	Pages      int            // how many of its responses were recorded
+	HTTPSPages int            // how many of those were over HTTPS
+	Issues     map[string]int // issue kind => how many times it was found
+}
+
+// SecurityReport audits the security of the pages of a crawl: HTTPS pages
+// loading resources over HTTP, or linking to HTTP URLs, HTTPS URLs that
+// redirect to HTTP, HTTPS responses without HSTS, and cookies set without
+// Secure or HttpOnly, each once per host.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. A
+// SecurityReport is safe for concurrent use, its zero value is ready to
+// use.
+type SecurityReport struct {
+	mu      sync.Mutex
+	issues  []SecurityIssue
+	hosts   map[string]*HostSecurity
+	cookies map[string]bool // host, issue and name of the cookies flagged
+}
+
+// Add audits the response of a fetch, whether or not it failed, the
+// resources and links of the HTML pages being those their body has. The
+// assets of the pages, see Crawler.FetchAssets, are left out: the pages
+// tell of them.
+func (r *SecurityReport) Add(res CrawlResult) {
+	if res.External || res.Asset || res.Header == nil && res.Redirects == nil {
+		// Nothing to tell without a response
+		return
+	}
+	page := pageURL(res)
+	u, err := url.Parse(page)
+	if err != nil {
+		return
+	}
+	secure := u.Scheme == "https"
+	var issues []SecurityIssue
+	flag := func(kind, detail string) {
+		issues = append(issues, SecurityIssue{URL: page, Kind: kind, Detail: detail})
+	}
+	for _, hop := range res.Redirects {
+		if strings.HasPrefix(hop.From, "https://") && strings.HasPrefix(hop.To, "http://") {
+			issues = append(issues, SecurityIssue{URL: hop.From, Kind: SecurityInsecureRedirect, Detail: hop.To})
+		}
+	}
+	if secure && res.Header != nil && !hsts(res.Header) {
+		flag(SecurityMissingHSTS, "")
+	}
+	if secure && res.Err == nil && res.Body != "" && htmlResult(res) {
+		if base, doc, err := pageDoc(res); err == nil {
+			for _, a := range append(ExtractAssets(base, doc), frames(base, doc)...) {
+				if strings.HasPrefix(a, "http://") {
+					flag(SecurityMixedContent, a)
+				}
+			}
+		}
+		seen := make(map[string]bool)
+		for _, l := range append(res.Links[:len(res.Links):len(res.Links)], res.NoFollowLinks...) {
+			if strings.HasPrefix(l, "http://") && !seen[l] {
+				seen[l] = true
+				flag(SecurityInsecureLink, l)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A cookie is flagged on the first page of its host setting it
+	for _, c := range (&http.Response{Header: res.Header}).Cookies() {
+		for _, kind := range []string{SecurityInsecureCookie, SecurityScriptCookie} {
+			if kind == SecurityInsecureCookie && c.Secure || kind == SecurityScriptCookie && c.HttpOnly {
+				continue
+			}
+			k := u.Host + " " + kind + " " + c.Name
+			if r.cookies == nil {
+				r.cookies = make(map[string]bool)
+			}
+			if !r.cookies[k] {
+				r.cookies[k] = true
+				flag(kind, c.Name)
+			}
+		}
+	}
+	if res.Header != nil {
+		h := r.host(page)
+		h.Pages++
+		if secure {
+			h.HTTPSPages++
+		}
+	}
+	for _, i := range issues {
+		r.host(i.URL).Issues[i.Kind]++
+	}
+	r.issues = append(r.issues, issues...)
+}
+
+// host returns the summary of the host of rawURL, adding it if need be.
+// The caller holds r.mu
+func (r *SecurityReport) host(rawURL string) *HostSecurity {
+	if r.hosts == nil {
+		r.hosts = make(map[string]*HostSecurity)
+	}
+	name := issueHost(rawURL)
+	h := r.hosts[name]
+	if h == nil {
+		h = &HostSecurity{Host: name, Issues: make(map[string]int)}
+		r.hosts[name] = h
+	}
+	return h
+}
+
+// issueHost returns the host of rawURL, port included
+func issueHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// hsts reports whether header has HSTS in force, a max-age of 0 calling
+// it off
+func hsts(header http.Header) bool {
+	v := header.Get("Strict-Transport-Security")
+	for _, d := range strings.Split(v, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(name, "max-age") {
+			return strings.Trim(value, `" `) != "0"
+		}
+	}
+	return false
+}
+
+// frames returns the URLs of the frames and iframes of doc, found at base,
+// which its assets leave out
+func frames(base *url.URL, doc *html.Node) []string {
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+	var urls []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.Iframe || n.DataAtom == atom.Frame) {
+			if src, ok := attr(n, "src"); ok {
+				if u := assetURL(base, src); u != "" {
+					urls = append(urls, u)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return urls
+}
+
+// Issues returns the issues found so far, sorted by page, kind and detail.
+func (r *SecurityReport) Issues() []SecurityIssue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	issues := append([]SecurityIssue(nil), r.issues...)
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Detail < b.Detail
+	})
+	return issues
+}
+
+// Hosts returns the summary of every host recorded so far, sorted by
+// host.
+func (r *SecurityReport) Hosts() []HostSecurity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hosts := make([]HostSecurity, 0, len(r.hosts))
+	for _, h := range r.hosts {
+		c := *h
+		c.Issues = make(map[string]int, len(h.Issues))
+		for k, n := range h.Issues {
+			c.Issues[k] = n
+		}
+		hosts = append(hosts, c)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	return hosts
+}
+
+// WriteText writes the summary of each host, followed by its issues but
+// the missing HSTS, which the summary counts:
+//
+//	example.com	12 pages, 12 over HTTPS	missing HSTS: 12, mixed content: 1
+//		mixed content	https://example.com/	http://cdn.example.com/app.js
+func (r *SecurityReport) WriteText(w io.Writer) error {
+	byHost := make(map[string][]SecurityIssue)
+	for _, i := range r.Issues() {
+		if i.Kind != SecurityMissingHSTS {
+			byHost[issueHost(i.URL)] = append(byHost[issueHost(i.URL)], i)
+		}
+	}
+	for _, h := range r.Hosts() {
+		var counts []string
+		for k, n := range h.Issues {
+			counts = append(counts, fmt.Sprintf("%s: %d", k, n))
+		}
+		sort.Strings(counts)
+		summary := "no issues"
+		if len(counts) > 0 {
+			summary = strings.Join(counts, ", ")
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d pages, %d over HTTPS\t%s\n", h.Host, h.Pages, h.HTTPSPages, summary); err != nil {
+			return err
+		}
+		for _, i := range byHost[h.Host] {
+			if _, err := fmt.Fprintf(w, "\t%s\t%s\t%s\n", i.Kind, i.URL, i.Detail); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package webcrawl
+
+import (
+	"bufio"
+	"io"
+	neturl "net/url"
+	"strings"
+)
+
+// ReadSeeds reads the seeds of RunSeeds out of r, a URL per line: blank
+// lines and those starting with # are skipped, as is the space around the
+// URLs.
+func ReadSeeds(r io.Reader) ([]string, error) {
+	var seeds []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	return seeds, sc.Err()
+}
+
+// isSeed reports whether u is one of the seeds of the run, which the
+// Scope, the Filter and the guards let through
+func (r *run) isSeed(u string) bool {
+	return u == r.seed || r.seeds[u] != nil
+}
+
+// scopeSeed returns the URL of seed, an item's Seed, to hold it to the
+// Scope of: the first seed when empty
+func (r *run) scopeSeed(seed string) *neturl.URL {
+	if seed == "" || seed == r.seed {
+		return r.seedURL
+	}
+	if u := r.seeds[seed]; u != nil {
+		return u
+	}
+	// Resumed from a Journal, the seeds are only known by the items
+	u, err := neturl.Parse(seed)
+	if err != nil {
+		return r.seedURL
+	}
+	return u
+}
@@ -0,0 +1,211 @@
+package webcrawl
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// SEO is what a page tells search engines of itself, in its <head> and
+// headings.
+type SEO struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"` // <meta name="description">
+	H1          []string `json:"h1,omitempty"`
+	Canonical   string   `json:"canonical,omitempty"` // <link rel="canonical">, absolute
+
+	// Hreflang maps the languages of <link rel="alternate" hreflang> to
+	//   the URLs of the page in them, absolute
+	Hreflang map[string]string `json:"hreflang,omitempty"`
+
+	// OpenGraph maps the properties of the og: meta tags, "og:title" for
+	//   instance, to their content. A property given several times, such
+	//   as og:image, has the first content
+	OpenGraph map[string]string `json:"og,omitempty"`
+}
+
+// ExtractSEO finds the SEO tags of the HTML document doc, found at base.
+// The texts have their runs of spaces collapsed.
+func ExtractSEO(base *url.URL, doc *html.Node) SEO {
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+	resolve := func(ref string) string {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			return ""
+		}
+		u.Fragment, u.RawFragment = "", ""
+		return u.String()
+	}
+
+	var s SEO
+	title := false
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				// The first one, not those of inline <svg>
+				if !title && n.Namespace == "" {
+					title = true
+					s.Title = anchorText(n)
+				}
+			case atom.H1:
+				s.H1 = append(s.H1, anchorText(n))
+			case atom.Meta:
+				name, _ := attr(n, "name")
+				prop, _ := attr(n, "property")
+				content, _ := attr(n, "content")
+				switch {
+				case strings.EqualFold(name, "description") && s.Description == "":
+					s.Description = strings.Join(strings.Fields(content), " ")
+				case strings.HasPrefix(strings.ToLower(prop), "og:"):
+					if s.OpenGraph == nil {
+						s.OpenGraph = make(map[string]string)
+					}
+					if p := strings.ToLower(prop); s.OpenGraph[p] == "" {
+						s.OpenGraph[p] = strings.TrimSpace(content)
+					}
+				}
+			case atom.Link:
+				href, ok := attr(n, "href")
+				if !ok {
+					break
+				}
+				if hasRel(n, "canonical") && s.Canonical == "" {
+					s.Canonical = resolve(href)
+				}
+				if lang, ok := attr(n, "hreflang"); ok && hasRel(n, "alternate") {
+					if s.Hreflang == nil {
+						s.Hreflang = make(map[string]string)
+					}
+					s.Hreflang[strings.ToLower(strings.TrimSpace(lang))] = resolve(href)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return s
+}
+
+// The issues an SEOReport flags
+const (
+	SEOMissingTitle         = "missing title"
+	SEODuplicateTitle       = "duplicate title"
+	SEOMissingDescription   = "missing description"
+	SEODuplicateDescription = "duplicate description"
+	SEOMissingH1            = "missing h1"
+	SEOMultipleH1           = "multiple h1"
+	SEOOffSiteCanonical     = "canonical off-site"
+)
+
+// SEOPage is a page of an SEOReport, with its issues.
+type SEOPage struct {
+	URL string `json:"url"`
+	SEO
+	Issues []string `json:"issues,omitempty"` // SEOMissingTitle and the like, sorted
+}
+
+// SEOReport collects the SEO tags of the HTML pages of a crawl and flags
+// their issues: a title or description missing or the same as another
+// page's, no h1 or several, a canonical URL on another host.
+//
+// The pages noindex asks not to be indexed are left out, and so are the
+// duplicates, which is what their canonical URL is for.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance, and
+// write it with WriteJSON once the crawl is over. An SEOReport is safe for
+// concurrent use, its zero value is ready to use.
+type SEOReport struct {
+	mu    sync.Mutex
+	pages map[string]SEO // URL => its tags
+}
+
+// Add records the SEO tags of a page that was fetched fine, other results
+// are ignored.
+func (r *SEOReport) Add(res CrawlResult) {
+	if res.Err != nil || res.External || res.Body == "" || res.NoIndex || res.Duplicate {
+		return
+	}
+	if !htmlResult(res) {
+		return
+	}
+	base, doc, err := pageDoc(res)
+	if err != nil {
+		return
+	}
+	s := ExtractSEO(base, doc)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pages == nil {
+		r.pages = make(map[string]SEO)
+	}
+	r.pages[base.String()] = s
+}
+
+// Pages returns the pages recorded so far with their issues, sorted by
+// URL.
+func (r *SEOReport) Pages() []SEOPage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	titles := make(map[string]int)
+	descriptions := make(map[string]int)
+	for _, s := range r.pages {
+		titles[s.Title]++
+		descriptions[s.Description]++
+	}
+
+	pages := make([]SEOPage, 0, len(r.pages))
+	for u, s := range r.pages {
+		p := SEOPage{URL: u, SEO: s}
+		flag := func(issue string, when bool) {
+			if when {
+				p.Issues = append(p.Issues, issue)
+			}
+		}
+		flag(SEOMissingTitle, s.Title == "")
+		flag(SEODuplicateTitle, s.Title != "" && titles[s.Title] > 1)
+		flag(SEOMissingDescription, s.Description == "")
+		flag(SEODuplicateDescription, s.Description != "" && descriptions[s.Description] > 1)
+		flag(SEOMissingH1, len(s.H1) == 0)
+		flag(SEOMultipleH1, len(s.H1) > 1)
+		flag(SEOOffSiteCanonical, s.Canonical != "" && !sameHost(u, s.Canonical))
+		sort.Strings(p.Issues)
+		pages = append(pages, p)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+	return pages
+}
+
+// sameHost reports whether the URLs a and b are on the same host
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Hostname(), ub.Hostname())
+}
+
+// WriteJSON writes the pages, as Pages returns them, to w as a JSON array.
+func (r *SEOReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Pages())
+}
@@ -0,0 +1,374 @@
+package shard
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// Coordinator holds the frontier and the visited set of a crawl for its
+// workers, see the package doc. Its zero value is ready to use.
+type Coordinator struct {
+	// OnResult is called with the results the workers hand in, one at a
+	//   time, before the crawl is Over
+	OnResult func(webcrawl.CrawlResult)
+
+	// Lease is how long a worker that stopped syncing keeps the URLs it
+	//   claimed and its hosts, DefaultLease when zero
+	Lease time.Duration
+
+	mu      sync.Mutex
+	seen    map[string]bool
+	hosts   map[string]*hostQueue
+	workers map[string]*worker
+	free    []*hostQueue // the hosts with URLs queued and no worker
+	queued  int
+	claimed int
+	results int
+	started bool          // once a URL was queued
+	over    chan struct{} // closed once nothing is queued nor claimed
+
+	resultMu sync.Mutex // for OnResult
+}
+
+// hostQueue is a host and the URLs queued for it
+type hostQueue struct {
+	name    string
+	items   []webcrawl.FrontierItem
+	owner   *worker // nil for none
+	claimed int     // of its URLs, out to its owner
+	ready   bool    // in the ready list of its owner, or the free list
+}
+
+// worker is a worker that syncs with the coordinator
+type worker struct {
+	id       string
+	lastSync time.Time
+	claimed  map[string]webcrawl.FrontierItem
+	hosts    map[string]*hostQueue
+	ready    []*hostQueue // its hosts with URLs queued, taken in turn
+	results  int
+}
+
+// Status is where the crawl of a Coordinator stands.
+type Status struct {
+	Workers []WorkerStatus `json:"workers"`
+	Hosts   int            `json:"hosts"`
+	Queued  int            `json:"queued"`
+	Claimed int            `json:"claimed"`
+	Seen    int            `json:"seen"`
+	Results int            `json:"results"`
+	Over    bool           `json:"over"`
+}
+
+// WorkerStatus is where a worker stands.
+type WorkerStatus struct {
+	ID       string    `json:"id"`
+	Hosts    int       `json:"hosts"`
+	Claimed  int       `json:"claimed"`
+	Results  int       `json:"results"`
+	LastSync time.Time `json:"last_sync"`
+}
+
+func (co *Coordinator) init() {
+	if co.seen == nil {
+		co.seen = make(map[string]bool)
+		co.hosts = make(map[string]*hostQueue)
+		co.workers = make(map[string]*worker)
+		co.over = make(chan struct{})
+	}
+}
+
+func (co *Coordinator) lease() time.Duration {
+	if co.Lease > 0 {
+		return co.Lease
+	}
+	return DefaultLease
+}
+
+// Over returns a channel closed once the crawl is over: URLs were queued,
+// and none is left queued nor claimed.
+func (co *Coordinator) Over() <-chan struct{} {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.init()
+	return co.over
+}
+
+// Status returns where the crawl stands.
+func (co *Coordinator) Status() Status {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.init()
+	co.expire(time.Now())
+	st := Status{Workers: []WorkerStatus{}, Queued: co.queued, Claimed: co.claimed, Seen: len(co.seen),
+		Results: co.results, Over: co.isOver()}
+	for _, q := range co.hosts {
+		if len(q.items) > 0 {
+			st.Hosts++
+		}
+	}
+	for _, w := range co.workers {
+		st.Workers = append(st.Workers, WorkerStatus{ID: w.id, Hosts: len(w.hosts), Claimed: len(w.claimed),
+			Results: w.results, LastSync: w.lastSync})
+	}
+	return st
+}
+
+func (co *Coordinator) isOver() bool {
+	select {
+	case <-co.over:
+		return true
+	default:
+		return false
+	}
+}
+
+// Handler returns the protocol of the package doc as an http.Handler.
+// Mount it on a server of its own, it has no authentication.
+func (co *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sync", func(w http.ResponseWriter, req *http.Request) {
+		var sr syncRequest
+		if err := json.NewDecoder(req.Body).Decode(&sr); err != nil {
+			http.Error(w, "bad sync: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sr.Worker == "" {
+			http.Error(w, "bad sync: want the id of the worker", http.StatusBadRequest)
+			return
+		}
+		results := make([]webcrawl.CrawlResult, 0, len(sr.Results))
+		for _, raw := range sr.Results {
+			res, err := webcrawl.NewJSONLReader(bytes.NewReader(raw)).Next()
+			if err != nil {
+				http.Error(w, "bad sync: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			results = append(results, res)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(co.sync(&sr, results))
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(co.Status())
+	})
+	return mux
+}
+
+// sync does what a worker asks for. The results go to OnResult first, for
+// them to be in before the crawl is over
+func (co *Coordinator) sync(sr *syncRequest, results []webcrawl.CrawlResult) syncResponse {
+	if co.OnResult != nil && len(results) > 0 {
+		co.resultMu.Lock()
+		for _, res := range results {
+			co.OnResult(res)
+		}
+		co.resultMu.Unlock()
+	}
+
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.init()
+	now := time.Now()
+	co.expire(now)
+	w := co.workers[sr.Worker]
+	if w == nil {
+		w = &worker{id: sr.Worker, claimed: make(map[string]webcrawl.FrontierItem), hosts: make(map[string]*hostQueue)}
+		co.workers[w.id] = w
+	}
+	w.lastSync = now
+	w.results += len(results)
+	co.results += len(results)
+
+	// Pushed before done, for the links of a page to be queued by the time
+	//   it is no longer claimed
+	for _, it := range sr.Push {
+		if _, ok := w.claimed[it.URL]; ok {
+			// Handed back, to be fetched again
+			co.unclaim(w, it.URL)
+			co.queue(it, true)
+			continue
+		}
+		if co.seen[it.URL] {
+			continue
+		}
+		co.seen[it.URL] = true
+		co.queue(it, false)
+	}
+	for _, u := range sr.Done {
+		if _, ok := w.claimed[u]; ok {
+			co.unclaim(w, u)
+		}
+	}
+
+	resp := syncResponse{LeaseMS: co.lease().Milliseconds()}
+	if sr.Leave {
+		co.drop(w)
+	} else if !co.isOver() {
+		for len(resp.Items) < sr.Want {
+			q := co.pick(w)
+			if q == nil {
+				break
+			}
+			it := q.items[0]
+			q.items = q.items[1:]
+			co.queued--
+			q.claimed++
+			co.claimed++
+			w.claimed[it.URL] = it
+			co.ready(q)
+			resp.Items = append(resp.Items, it)
+		}
+	}
+	if co.started && co.queued == 0 && co.claimed == 0 && !co.isOver() {
+		close(co.over)
+	}
+	resp.Over = co.isOver()
+	return resp
+}
+
+// queue queues it for its host, first in line with front
+func (co *Coordinator) queue(it webcrawl.FrontierItem, front bool) {
+	host := hostname(it.URL)
+	q := co.hosts[host]
+	if q == nil {
+		q = &hostQueue{name: host}
+		co.hosts[host] = q
+	}
+	if front {
+		q.items = append([]webcrawl.FrontierItem{it}, q.items...)
+	} else {
+		q.items = append(q.items, it)
+	}
+	co.queued++
+	co.started = true
+	co.ready(q)
+}
+
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// ready puts q in the ready list of its owner, or the free list, once it
+// has URLs queued. A host of no worker which has URLs claimed was stolen
+// off the worker that claimed them, it is free once they are done
+func (co *Coordinator) ready(q *hostQueue) {
+	if q.ready || len(q.items) == 0 || q.owner == nil && q.claimed > 0 {
+		return
+	}
+	q.ready = true
+	if q.owner != nil {
+		q.owner.ready = append(q.owner.ready, q)
+	} else {
+		co.free = append(co.free, q)
+	}
+}
+
+// unclaim takes url off the URLs w claimed
+func (co *Coordinator) unclaim(w *worker, url string) {
+	delete(w.claimed, url)
+	if q := co.hosts[hostname(url)]; q != nil {
+		q.claimed--
+		co.ready(q)
+	}
+	co.claimed--
+}
+
+// pick returns the next host w is to claim a URL of: one of its own in
+// turn, else one of no worker, else one stolen from the worker with the
+// most of them queued. Nil when there is none, or the host stolen has
+// URLs out still
+func (co *Coordinator) pick(w *worker) *hostQueue {
+	for len(w.ready) > 0 {
+		q := w.ready[0]
+		w.ready = w.ready[1:]
+		q.ready = false
+		if len(q.items) > 0 {
+			return q
+		}
+	}
+	for len(co.free) > 0 {
+		q := co.free[0]
+		co.free = co.free[1:]
+		q.ready = false
+		if q.owner == nil && q.claimed == 0 && len(q.items) > 0 {
+			co.assign(q, w)
+			return q
+		}
+	}
+	// A worker with a single host to go on keeps it
+	var from *worker
+	for _, o := range co.workers {
+		if o != w && len(o.ready) > 1 && (from == nil || len(o.ready) > len(from.ready)) {
+			from = o
+		}
+	}
+	if from == nil {
+		return nil
+	}
+	steal := -1
+	for i, q := range from.ready {
+		if len(q.items) > 0 && (steal < 0 || q.claimed < from.ready[steal].claimed) {
+			steal = i
+		}
+	}
+	if steal < 0 {
+		return nil
+	}
+	q := from.ready[steal]
+	from.ready = append(from.ready[:steal], from.ready[steal+1:]...)
+	q.ready = false
+	delete(from.hosts, q.name)
+	q.owner = nil
+	if q.claimed > 0 {
+		// Not while the other fetches from it, that would be two at a
+		//   time: it is free once its URLs out are done
+		return nil
+	}
+	co.assign(q, w)
+	return q
+}
+
+func (co *Coordinator) assign(q *hostQueue, w *worker) {
+	q.owner = w
+	w.hosts[q.name] = q
+}
+
+// expire drops the workers that didn't sync for the lease
+func (co *Coordinator) expire(now time.Time) {
+	for _, w := range co.workers {
+		if now.Sub(w.lastSync) > co.lease() {
+			co.drop(w)
+		}
+	}
+}
+
+// drop hands the hosts of w out to the others, and queues the URLs it
+// claimed again
+func (co *Coordinator) drop(w *worker) {
+	for _, q := range w.hosts {
+		q.owner, q.ready = nil, false
+	}
+	w.ready = nil
+	for u, it := range w.claimed {
+		co.unclaim(w, u)
+		co.queue(it, true)
+	}
+	for _, q := range w.hosts {
+		co.ready(q)
+	}
+	delete(co.workers, w.id)
+}
@@ -0,0 +1,80 @@
+// Package shard spreads a crawl over worker processes, on as many machines
+// as need be, from a coordinator: it holds the frontier and the visited
+// set, hands the hosts out to the workers and takes their results in.
+//
+// The coordinator serves the protocol over HTTP, and gets the results of
+// the whole crawl:
+//
+//	co := &shard.Coordinator{OnResult: func(res webcrawl.CrawlResult) { ... }}
+//	go http.ListenAndServe(":9093", co.Handler())
+//	<-co.Over()
+//
+// and every worker runs a Crawler of its own, started with the same seed,
+// its frontier being the coordinator's:
+//
+//	w := shard.NewWorker("http://coordinator:9093", shard.WorkerOptions{})
+//	c := webcrawl.NewCrawler(webcrawl.WithFrontier(w), webcrawl.WithOnResult(w.Send))
+//	err := c.Run(ctx, "https://example.com/")
+//	w.Close()
+//
+// A host is crawled by one worker at a time, so that its politeness, its
+// rate limit and backoff, holds for the whole crawl. A worker with nothing
+// left to fetch is handed a host no worker has, or steals one from the
+// worker with the most of them queued, which is how a worker that joins
+// gets its share. A host only moves to another worker when none of its
+// URLs is out to the one it has. A worker that leaves, or stops syncing
+// for the Lease of the coordinator, has the URLs it claimed queued again
+// and its hosts handed out to the others: a URL may so be fetched twice,
+// never lost.
+//
+// The seed is queued once, the links the workers find are queued once
+// too, the coordinator keeping the URLs seen. Run returns on every worker
+// once nothing is queued nor claimed. The budgets of the Crawlers, such as
+// MaxPages, are per worker.
+//
+// The protocol is a POST of JSON to /sync, which a worker makes as it
+// finishes a page, and every third of the Lease to stay alive:
+//
+//	{"worker": "id", "push": [items], "done": [urls], "results": [results], "want": 16, "leave": false}
+//
+// pushing the items to queue, telling which URLs it claimed are done,
+// handing its results in, as JSONL objects of webcrawl.MarshalResult, and
+// claiming up to want URLs, which the answer has:
+//
+//	{"items": [items], "over": false, "lease_ms": 30000}
+//
+// GET /status answers the Status of the coordinator.
+package shard
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// DefaultLease is how long a worker that stopped syncing keeps the URLs it
+// claimed and its hosts, when Coordinator.Lease is not set.
+const DefaultLease = 30 * time.Second
+
+// DefaultBatch is how many URLs a worker claims at a time, when
+// WorkerOptions.Batch is not set.
+const DefaultBatch = 16
+
+// syncRequest is a /sync of a worker
+type syncRequest struct {
+	Worker  string                  `json:"worker"`
+	Push    []webcrawl.FrontierItem `json:"push,omitempty"`
+	Done    []string                `json:"done,omitempty"`
+	Results []json.RawMessage       `json:"results,omitempty"`
+	Want    int                     `json:"want,omitempty"`
+	Leave   bool                    `json:"leave,omitempty"`
+}
+
+// syncResponse is the answer to a /sync: the URLs claimed, and whether the
+// crawl is over
+type syncResponse struct {
+	Items   []webcrawl.FrontierItem `json:"items,omitempty"`
+	Over    bool                    `json:"over,omitempty"`
+	LeaseMS int64                   `json:"lease_ms"`
+}
@@ -0,0 +1,234 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// site is the pages of a few hosts, linking to one another. Every page is
+// as many links away from http://a.test/ whichever way it is reached, for
+// none to be fetched again by a shorter path
+var site = map[string][]string{
+	"http://a.test/":  {"http://a.test/1", "http://b.test/", "http://c.test/"},
+	"http://a.test/1": {"http://a.test/2", "http://a.test/"},
+	"http://a.test/2": nil,
+	"http://b.test/":  {"http://b.test/1", "http://a.test/1"},
+	"http://b.test/1": {"http://c.test/2"},
+	"http://c.test/":  {"http://c.test/1"},
+	"http://c.test/1": {"http://c.test/2"},
+	"http://c.test/2": {"http://a.test/"},
+}
+
+// fetches counts who fetched what
+type fetches struct {
+	mu sync.Mutex
+	by map[string][]string // URL: the workers that fetched it
+}
+
+// fetcher is a Fetcher of site for the worker id
+type fetcher struct {
+	id string
+	f  *fetches
+}
+
+func (f fetcher) Fetch(ctx context.Context, rawURL string) (string, []string, error) {
+	links, ok := site[rawURL]
+	if !ok {
+		return "", nil, errors.New("not found")
+	}
+	f.f.mu.Lock()
+	defer f.f.mu.Unlock()
+	f.f.by[rawURL] = append(f.f.by[rawURL], f.id)
+	return "<p>" + rawURL + "</p>", links, nil
+}
+
+func TestCrawl(t *testing.T) {
+	var mu sync.Mutex
+	results := make(map[string]int)
+	co := &Coordinator{OnResult: func(res webcrawl.CrawlResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[res.URL]++
+	}}
+	srv := httptest.NewServer(co.Handler())
+	defer srv.Close()
+
+	f := &fetches{by: make(map[string][]string)}
+	var wg sync.WaitGroup
+	for _, id := range []string{"w1", "w2"} {
+		w := NewWorker(srv.URL, WorkerOptions{ID: id, Batch: 2, Client: srv.Client()})
+		c := webcrawl.NewCrawler(webcrawl.WithFetcher(fetcher{id, f}), webcrawl.WithFrontier(w),
+			webcrawl.WithScope(&webcrawl.ScopeRules{Subdomains: webcrawl.AnyHost}), webcrawl.WithoutRobots(),
+			webcrawl.WithDepth(10), webcrawl.WithOnResult(w.Send))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := c.Run(ctx, "http://a.test/"); err != nil {
+				t.Errorf("Run of %s: %v", w.ID(), err)
+			}
+			if err := w.Close(); err != nil {
+				t.Errorf("Close of %s: %v", w.ID(), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-co.Over():
+	default:
+		t.Error("not Over once the workers are done")
+	}
+	for u := range site {
+		if by := f.by[u]; len(by) != 1 {
+			t.Errorf("%s fetched by %q, want once", u, by)
+		}
+		if results[u] != 1 {
+			t.Errorf("%d results of %s, want 1", results[u], u)
+		}
+	}
+	if len(f.by) != len(site) || len(results) != len(site) {
+		t.Errorf("fetched %v, results %v, want the %d pages of the site", f.by, results, len(site))
+	}
+	st := co.Status()
+	if !st.Over || st.Queued != 0 || st.Claimed != 0 || st.Seen != len(site) || st.Results != len(site) {
+		t.Errorf("Status %+v once over", st)
+	}
+	if len(st.Workers) != 0 {
+		t.Errorf("workers %+v after Close, want none", st.Workers)
+	}
+}
+
+// items returns the FrontierItems of urls, at depth 0
+func items(urls ...string) []webcrawl.FrontierItem {
+	var its []webcrawl.FrontierItem
+	for _, u := range urls {
+		its = append(its, webcrawl.FrontierItem{URL: u})
+	}
+	return its
+}
+
+// claimed returns the URLs of resp
+func claimed(resp syncResponse) string {
+	var us []string
+	for _, it := range resp.Items {
+		us = append(us, it.URL)
+	}
+	return strings.Join(us, " ")
+}
+
+func TestSteal(t *testing.T) {
+	co := &Coordinator{}
+	resp := co.sync(&syncRequest{Worker: "a", Push: items("http://x.test/1", "http://y.test/1"), Want: 2}, nil)
+	if got := claimed(resp); got != "http://x.test/1 http://y.test/1" {
+		t.Fatalf("a claimed %q, want a URL of both hosts", got)
+	}
+	// Two hosts of a with URLs queued, x having none out
+	co.sync(&syncRequest{Worker: "a", Push: items("http://x.test/2", "http://y.test/2"), Done: []string{"http://x.test/1"}}, nil)
+
+	resp = co.sync(&syncRequest{Worker: "b", Want: 1}, nil)
+	if got := claimed(resp); got != "http://x.test/2" {
+		t.Fatalf("b claimed %q, want x stolen off a", got)
+	}
+	// Left with y alone, a keeps it
+	if resp := co.sync(&syncRequest{Worker: "b", Want: 1}, nil); len(resp.Items) != 0 {
+		t.Errorf("b claimed %q, want nothing to steal", claimed(resp))
+	}
+	if resp := co.sync(&syncRequest{Worker: "a", Want: 2}, nil); claimed(resp) != "http://y.test/2" {
+		t.Errorf("a claimed %q, want the URL of y, and none of x", claimed(resp))
+	}
+
+	st := co.Status()
+	if len(st.Workers) != 2 || st.Claimed != 3 || st.Queued != 0 || st.Seen != 4 || st.Over {
+		t.Errorf("Status %+v", st)
+	}
+
+	co.sync(&syncRequest{Worker: "a", Done: []string{"http://y.test/1", "http://y.test/2"}}, nil)
+	resp = co.sync(&syncRequest{Worker: "b", Done: []string{"http://x.test/2"}}, nil)
+	if !resp.Over {
+		t.Error("not over once every URL is done")
+	}
+}
+
+func TestLease(t *testing.T) {
+	co := &Coordinator{Lease: 50 * time.Millisecond}
+	resp := co.sync(&syncRequest{Worker: "a", Push: items("http://x.test/1", "http://x.test/2"), Want: 1}, nil)
+	if got := claimed(resp); got != "http://x.test/1" || resp.LeaseMS != 50 {
+		t.Fatalf("a claimed %q, lease %dms", got, resp.LeaseMS)
+	}
+	// x is a's while its lease holds
+	if resp := co.sync(&syncRequest{Worker: "b", Want: 2}, nil); len(resp.Items) != 0 {
+		t.Errorf("b claimed %q while a is alive", claimed(resp))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	resp = co.sync(&syncRequest{Worker: "b", Want: 2}, nil)
+	if got := claimed(resp); got != "http://x.test/1 http://x.test/2" {
+		t.Errorf("b claimed %q once the lease of a ended, want its claim first", got)
+	}
+	if st := co.Status(); len(st.Workers) != 1 || st.Workers[0].ID != "b" || st.Workers[0].Hosts != 1 {
+		t.Errorf("workers %+v, want a gone and x b's", st.Workers)
+	}
+}
+
+func TestLeave(t *testing.T) {
+	co := &Coordinator{}
+	co.sync(&syncRequest{Worker: "a", Push: items("http://x.test/1"), Want: 1}, nil)
+	// Pushed again, a claimed seen URL is handed back
+	co.sync(&syncRequest{Worker: "a", Push: items("http://x.test/1")}, nil)
+	if st := co.Status(); st.Queued != 1 || st.Claimed != 0 {
+		t.Errorf("Status %+v after Push, want the URL queued again", st)
+	}
+	co.sync(&syncRequest{Worker: "a", Want: 1}, nil)
+	if resp := co.sync(&syncRequest{Worker: "a", Leave: true}, nil); resp.Over || len(resp.Items) != 0 {
+		t.Errorf("answer to leave %+v", resp)
+	}
+	if resp := co.sync(&syncRequest{Worker: "b", Want: 1}, nil); claimed(resp) != "http://x.test/1" {
+		t.Errorf("b claimed %q, want what a left", claimed(resp))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	co := &Coordinator{}
+	srv := httptest.NewServer(co.Handler())
+	defer srv.Close()
+	for _, body := range []string{"{", `{"push": []}`, `{"worker": "a", "results": [{"url": 1}]}`} {
+		resp, err := srv.Client().Post(srv.URL+"/sync", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("/sync of %s: %s, want 400", body, resp.Status)
+		}
+	}
+	resp, err := srv.Client().Get(srv.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("/status: %s, %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestWorkerErr(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	w := NewWorker(srv.URL, WorkerOptions{ID: "a", Client: srv.Client()})
+	if it, ok := w.Pop(); ok {
+		t.Errorf("Pop = %+v with no coordinator", it)
+	}
+	if err := w.Close(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Close = %v, want the 404 of /sync", err)
+	}
+}
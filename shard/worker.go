@@ -0,0 +1,254 @@
+package shard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// pollInterval is how often a worker with nothing to fetch asks again
+const pollInterval = 200 * time.Millisecond
+
+// WorkerOptions set up a Worker.
+type WorkerOptions struct {
+	// ID names the worker to the coordinator, it has to be unique to it.
+	//   The hostname and the process ID when empty
+	ID string
+
+	// Batch is how many URLs the worker claims at a time, DefaultBatch
+	//   when zero
+	Batch int
+
+	// Client makes the requests to the coordinator, http.DefaultClient
+	//   when nil
+	Client *http.Client
+}
+
+// Worker is a worker of a crawl, see the package doc. It is the
+// webcrawl.Frontier of its Crawler, a webcrawl.LeasingFrontier, and its
+// Send is the OnResult.
+//
+// The Frontier methods have no way of telling of a failure to reach the
+// coordinator: the first one is kept for Err, and the crawl of the worker
+// ends.
+type Worker struct {
+	url    string
+	id     string
+	batch  int
+	client *http.Client
+	quit   chan struct{}
+	closed chan struct{}
+	once   sync.Once
+
+	syncMu sync.Mutex // one /sync at a time
+
+	mu      sync.Mutex
+	err     error
+	items   []webcrawl.FrontierItem // claimed, not yet popped
+	claimed map[string]bool         // the URLs claimed, not yet done
+	push    []webcrawl.FrontierItem
+	done    []string
+	results []json.RawMessage
+	over    bool
+	lease   time.Duration
+}
+
+// NewWorker returns a worker of the coordinator at coordinatorURL, the
+// base URL of its Handler. It syncs to stay alive until Close.
+func NewWorker(coordinatorURL string, opts WorkerOptions) *Worker {
+	w := &Worker{url: strings.TrimSuffix(coordinatorURL, "/"), id: opts.ID, batch: opts.Batch, client: opts.Client,
+		quit: make(chan struct{}), closed: make(chan struct{}), claimed: make(map[string]bool), lease: DefaultLease}
+	if w.id == "" {
+		host, _ := os.Hostname()
+		w.id = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if w.batch <= 0 {
+		w.batch = DefaultBatch
+	}
+	if w.client == nil {
+		w.client = http.DefaultClient
+	}
+	go w.heartbeat()
+	return w
+}
+
+// ID returns the ID of the worker.
+func (w *Worker) ID() string { return w.id }
+
+// Err returns the first failure to sync with the coordinator.
+func (w *Worker) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// heartbeat syncs every third of the lease until Close, for the worker to
+// stay alive through pages that take long
+func (w *Worker) heartbeat() {
+	defer close(w.closed)
+	for {
+		w.mu.Lock()
+		every := w.lease / 3
+		w.mu.Unlock()
+		select {
+		case <-time.After(every):
+			w.sync(0, false)
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// sync pushes what is to be pushed, tells what is done, hands the results
+// in, and claims up to want URLs
+func (w *Worker) sync(want int, leave bool) {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	w.mu.Lock()
+	sr := syncRequest{Worker: w.id, Push: w.push, Done: w.done, Results: w.results, Want: want, Leave: leave}
+	w.push, w.done, w.results = nil, nil, nil
+	w.mu.Unlock()
+
+	resp, err := w.post(&sr)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		// For Close to try again
+		w.push, w.done = append(sr.Push, w.push...), append(sr.Done, w.done...)
+		w.results = append(sr.Results, w.results...)
+		return
+	}
+	for _, it := range resp.Items {
+		w.claimed[it.URL] = true
+	}
+	w.items = append(w.items, resp.Items...)
+	w.over = resp.Over
+	if resp.LeaseMS > 0 {
+		w.lease = time.Duration(resp.LeaseMS) * time.Millisecond
+	}
+}
+
+func (w *Worker) post(sr *syncRequest) (*syncResponse, error) {
+	b, err := json.Marshal(sr)
+	if err != nil {
+		return nil, err
+	}
+	res, err := w.client.Post(w.url+"/sync", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("shard: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("shard: %s/sync: %s", w.url, res.Status)
+	}
+	var resp syncResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("shard: %s/sync: %w", w.url, err)
+	}
+	return &resp, nil
+}
+
+// Push queues it with the coordinator, as of the next sync. An item the
+// worker claimed is handed back, for it to be fetched again later.
+func (w *Worker) Push(it webcrawl.FrontierItem) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.claimed, it.URL)
+	w.push = append(w.push, it)
+}
+
+// Pop returns the next URL claimed. When there is none and the Crawler has
+// nothing under way, it waits for the coordinator to have some, and only
+// returns false once the crawl is over.
+func (w *Worker) Pop() (webcrawl.FrontierItem, bool) {
+	for {
+		w.mu.Lock()
+		if len(w.items) > 0 {
+			it := w.items[0]
+			w.items = w.items[1:]
+			w.mu.Unlock()
+			return it, true
+		}
+		// The URLs under way here may well have links to queue still
+		busy := len(w.claimed) > 0 || w.err != nil || w.over
+		w.mu.Unlock()
+		if busy {
+			return webcrawl.FrontierItem{}, false
+		}
+		w.sync(w.batch, false)
+		w.mu.Lock()
+		got, over := len(w.items) > 0, w.over
+		w.mu.Unlock()
+		if got {
+			continue
+		}
+		if over {
+			return webcrawl.FrontierItem{}, false
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-w.quit:
+			return webcrawl.FrontierItem{}, false
+		}
+	}
+}
+
+// Done tells the coordinator the Crawler is done with the URL of it, its
+// links pushed, and claims more URLs if the worker runs short.
+func (w *Worker) Done(it webcrawl.FrontierItem) {
+	w.mu.Lock()
+	delete(w.claimed, it.URL)
+	w.done = append(w.done, it.URL)
+	want := w.batch - len(w.items)
+	if want < 0 || w.over {
+		want = 0
+	}
+	w.mu.Unlock()
+	w.sync(want, false)
+}
+
+// Len returns how many URLs the worker claimed and the Crawler didn't Pop
+// yet.
+func (w *Worker) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.items)
+}
+
+// Send hands res in to the coordinator, as of the next sync. It is meant
+// for Crawler.OnResult.
+func (w *Worker) Send(res webcrawl.CrawlResult) {
+	b, err := webcrawl.MarshalResult(res)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		return
+	}
+	w.results = append(w.results, b)
+}
+
+// Close leaves the crawl, handing the last results in: the URLs the
+// worker claimed and didn't fetch go back to the others. It returns Err,
+// or the failure to leave.
+func (w *Worker) Close() error {
+	w.once.Do(func() {
+		close(w.quit)
+		<-w.closed
+		w.sync(0, true)
+	})
+	return w.Err()
+}
@@ -0,0 +1,183 @@
+package webcrawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxSitemaps is how many sitemap files LoadSitemaps reads at most
+// when not told otherwise, sitemap indexes can nest deep
+const DefaultMaxSitemaps = 50
+
+// SitemapURL is a <url> entry of a sitemap.
+type SitemapURL struct {
+	Loc        string
+	LastMod    time.Time // zero when the sitemap doesn't say
+	ChangeFreq string
+	Priority   float64 // 0.5 when the sitemap doesn't say, as the protocol has it
+}
+
+// The XML of both sitemap flavours, namespaces are ignored so that sloppy
+// sitemaps parse too
+type xmlURLSet struct {
+	URLs []struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+	} `xml:"url"`
+}
+
+type xmlSitemapIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// ParseSitemap parses a sitemap (a <urlset>) or a sitemap index (a
+// <sitemapindex>), plain or gzipped. A sitemap yields its urls, an index
+// the locations of the sitemaps it lists.
+func ParseSitemap(data []byte) (urls []SitemapURL, sitemaps []string, err error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	root, err := xmlRoot(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch root {
+	case "urlset":
+		var set xmlURLSet
+		if err := xml.Unmarshal(data, &set); err != nil {
+			return nil, nil, err
+		}
+		for _, u := range set.URLs {
+			su := SitemapURL{
+				Loc:        strings.TrimSpace(u.Loc),
+				LastMod:    parseW3CDate(strings.TrimSpace(u.LastMod)),
+				ChangeFreq: strings.TrimSpace(u.ChangeFreq),
+				Priority:   0.5,
+			}
+			if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil && p >= 0 && p <= 1 {
+				su.Priority = p
+			}
+			if su.Loc != "" {
+				urls = append(urls, su)
+			}
+		}
+	case "sitemapindex":
+		var idx xmlSitemapIndex
+		if err := xml.Unmarshal(data, &idx); err != nil {
+			return nil, nil, err
+		}
+		for _, s := range idx.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				sitemaps = append(sitemaps, loc)
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("sitemap: unexpected root element <%s>", root)
+	}
+	return urls, sitemaps, nil
+}
+
+// xmlRoot returns the local name of the document's root element
+func xmlRoot(data []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", fmt.Errorf("sitemap: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+// parseW3CDate parses the few date formats sitemaps use
+func parseW3CDate(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// LoadSitemaps fetches the sitemaps at locs with f, following sitemap
+// indexes, and returns every url they list. At most max sitemap files are
+// fetched, or DefaultMaxSitemaps when max is zero or less. Sitemaps that
+// fail to load are skipped, their errors are returned together with
+// whatever the others yielded.
+func LoadSitemaps(ctx context.Context, f Fetcher, locs []string, max int) ([]SitemapURL, error) {
+	if max <= 0 {
+		max = DefaultMaxSitemaps
+	}
+	var urls []SitemapURL
+	var errs []error
+	queue := append([]string(nil), locs...)
+	seen := make(map[string]bool)
+	for loaded := 0; len(queue) > 0 && loaded < max; {
+		loc := queue[0]
+		queue = queue[1:]
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		loaded++
+
+		body, _, err := f.Fetch(ctx, loc)
+		if err != nil {
+			if ctx.Err() != nil {
+				return urls, ctx.Err()
+			}
+			errs = append(errs, err)
+			continue
+		}
+		u, more, err := ParseSitemap([]byte(body))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", loc, err))
+			continue
+		}
+		urls = append(urls, u...)
+		queue = append(queue, more...)
+	}
+	return urls, errors.Join(errs...)
+}
+
+// DiscoverSitemaps returns where the sitemaps of siteURL's host are: the
+// ones its robots.txt announces when robots is given and it announces any,
+// /sitemap.xml otherwise.
+func DiscoverSitemaps(ctx context.Context, robots *Robots, siteURL string) ([]string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if robots != nil {
+		rules, err := robots.Rules(ctx, siteURL)
+		if err != nil {
+			return nil, err
+		}
+		if len(rules.Sitemaps) > 0 {
+			return rules.Sitemaps, nil
+		}
+	}
+	return []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}, nil
+}
@@ -0,0 +1,257 @@
+package webcrawl
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The caps of the sitemap protocol on a sitemap file, beyond which the
+// URLs are split over several files and a sitemap index
+const (
+	SitemapMaxURLs  = 50000
+	SitemapMaxBytes = 50 << 20 // uncompressed
+)
+
+// The namespace of the sitemap protocol
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapBuilder builds the sitemap of a site out of a crawl of it: the
+// URLs of the pages that are there to be indexed, those answered 200 that
+// are not noindex and are their own canonical, or have none. Their
+// LastMod is the Last-Modified of their response.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance, and
+// write it with WriteFiles once the crawl is over. A SitemapBuilder is safe
+// for concurrent use, its zero value is ready to use.
+type SitemapBuilder struct {
+	// Normalizer must be the Crawler's, for the pages to be matched with
+	//   their canonicals. When nil a zero Normalizer is used
+	Normalizer *Normalizer
+
+	mu   sync.Mutex
+	urls map[string]SitemapURL // by normalized URL
+}
+
+// Add takes the page of res in the sitemap when it is to be indexed, other
+// results are ignored.
+func (b *SitemapBuilder) Add(res CrawlResult) {
+	if res.Err != nil || res.StatusCode != http.StatusOK || res.NoIndex || res.Duplicate || res.External ||
+		res.Asset || res.Feed || res.Skipped != "" || res.Soft404 || !htmlResult(res) && !res.Document {
+		return
+	}
+	norm := b.Normalizer
+	if norm == nil {
+		norm = &Normalizer{}
+	}
+	page, err := norm.Normalize(pageURL(res))
+	if err != nil {
+		return
+	}
+	canonical := res.Canonical
+	if canonical == "" && res.Body != "" && htmlResult(res) {
+		if base, doc, err := pageDoc(res); err == nil {
+			canonical = ExtractSEO(base, doc).Canonical
+		}
+	}
+	if canonical != "" {
+		if c, err := norm.Normalize(canonical); err != nil || c != page {
+			return
+		}
+	}
+	u := SitemapURL{Loc: page, Priority: 0.5}
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		u.LastMod = t.UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.urls == nil {
+		b.urls = make(map[string]SitemapURL)
+	}
+	b.urls[page] = u
+}
+
+// URLs returns the URLs of the sitemap so far, sorted.
+func (b *SitemapBuilder) URLs() []SitemapURL {
+	b.mu.Lock()
+	urls := make([]SitemapURL, 0, len(b.urls))
+	for _, u := range b.urls {
+		urls = append(urls, u)
+	}
+	b.mu.Unlock()
+	sort.Slice(urls, func(i, j int) bool { return urls[i].Loc < urls[j].Loc })
+	return urls
+}
+
+// WriteFiles writes the sitemap to dir: a sitemap.xml of every URL, or,
+// when they are more than one sitemap file can hold, sitemap-1.xml,
+// sitemap-2.xml and so on, with a sitemap.xml index of them, which lists
+// them under baseURL, where dir is to be served from. baseURL defaults to
+// the root of the host of the URLs. It returns the files written, the
+// sitemap.xml of a directory after the others.
+//
+// A sitemap may only list the URLs of the host it is served from, and of
+// its scheme: the URLs of several are written to a directory of dir for
+// each, named after them, https_example.com or http_example.com_8080, to
+// be served from the path of baseURL on that host.
+func (b *SitemapBuilder) WriteFiles(dir, baseURL string) ([]string, error) {
+	var origins []*url.URL
+	byOrigin := make(map[string][]SitemapURL) // scheme://host => its URLs
+	for _, u := range b.URLs() {
+		pu, err := url.Parse(u.Loc)
+		if err != nil {
+			continue
+		}
+		o := pu.Scheme + "://" + pu.Host
+		if _, ok := byOrigin[o]; !ok {
+			origins = append(origins, &url.URL{Scheme: pu.Scheme, Host: pu.Host, Path: "/"})
+		}
+		byOrigin[o] = append(byOrigin[o], u)
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: sitemap base URL: %w", err)
+	}
+	if len(origins) <= 1 {
+		var urls []SitemapURL
+		if len(origins) == 1 {
+			if baseURL == "" {
+				base = origins[0]
+			}
+			urls = byOrigin[origins[0].Scheme+"://"+origins[0].Host]
+		}
+		return writeSitemapFiles(dir, base, urls)
+	}
+	sort.Slice(origins, func(i, j int) bool { return origins[i].String() < origins[j].String() })
+	var files []string
+	for _, o := range origins {
+		at := *o
+		at.Path = base.Path
+		name := o.Scheme + "_" + strings.ReplaceAll(o.Host, ":", "_")
+		written, err := writeSitemapFiles(filepath.Join(dir, name), &at, byOrigin[o.Scheme+"://"+o.Host])
+		files = append(files, written...)
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// writeSitemapFiles writes the sitemap of urls to dir, served from base,
+// as WriteFiles does for the URLs of a host
+func writeSitemapFiles(dir string, base *url.URL, urls []SitemapURL) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	parts := splitSitemap(urls)
+	if len(parts) <= 1 {
+		path := filepath.Join(dir, "sitemap.xml")
+		return []string{path}, writeFile(path, func(w io.Writer) error { return WriteSitemap(w, urls) })
+	}
+	// The files are in base, not next to it
+	at := *base
+	if !strings.HasSuffix(at.Path, "/") {
+		at.Path += "/"
+		at.RawPath = ""
+	}
+	var files, locs []string
+	for i, part := range parts {
+		name := "sitemap-" + strconv.Itoa(i+1) + ".xml"
+		path := filepath.Join(dir, name)
+		if err := writeFile(path, func(w io.Writer) error { return WriteSitemap(w, part) }); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+		locs = append(locs, at.ResolveReference(&url.URL{Path: name}).String())
+	}
+	path := filepath.Join(dir, "sitemap.xml")
+	files = append(files, path)
+	return files, writeFile(path, func(w io.Writer) error { return WriteSitemapIndex(w, locs) })
+}
+
+// splitSitemap splits urls into the sitemap files they take, each under
+// SitemapMaxURLs and SitemapMaxBytes
+func splitSitemap(urls []SitemapURL) [][]SitemapURL {
+	header := len(xml.Header) + len(`<urlset xmlns="`+sitemapXMLNS+`">`) + len("\n</urlset>\n")
+	var parts [][]SitemapURL
+	start, size := 0, header
+	for i, u := range urls {
+		n := len(sitemapEntry(u))
+		if i > start && (i-start >= SitemapMaxURLs || size+n > SitemapMaxBytes) {
+			parts = append(parts, urls[start:i])
+			start, size = i, header
+		}
+		size += n
+	}
+	return append(parts, urls[start:])
+}
+
+// sitemapEntry returns the <url> element of u, as WriteSitemap writes it
+func sitemapEntry(u SitemapURL) string {
+	var sb strings.Builder
+	sb.WriteString("\n  <url><loc>")
+	xml.EscapeText(&sb, []byte(u.Loc))
+	sb.WriteString("</loc>")
+	if !u.LastMod.IsZero() {
+		sb.WriteString("<lastmod>" + u.LastMod.UTC().Format(time.RFC3339) + "</lastmod>")
+	}
+	if u.ChangeFreq != "" {
+		sb.WriteString("<changefreq>" + u.ChangeFreq + "</changefreq>")
+	}
+	if u.Priority != 0 && u.Priority != 0.5 {
+		sb.WriteString("<priority>" + strconv.FormatFloat(u.Priority, 'f', 1, 64) + "</priority>")
+	}
+	sb.WriteString("</url>")
+	return sb.String()
+}
+
+// WriteSitemap writes urls to w as a sitemap, a <urlset>, whatever their
+// number: see SitemapBuilder.WriteFiles to keep to the caps of the
+// protocol. The LastMod, ChangeFreq and Priority of a URL are left out
+// when zero, the Priority when 0.5 too.
+func WriteSitemap(w io.Writer, urls []SitemapURL) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header + `<urlset xmlns="` + sitemapXMLNS + `">`)
+	for _, u := range urls {
+		bw.WriteString(sitemapEntry(u))
+	}
+	bw.WriteString("\n</urlset>\n")
+	return bw.Flush()
+}
+
+// WriteSitemapIndex writes a sitemap index of the sitemaps at locs to w.
+func WriteSitemapIndex(w io.Writer, locs []string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header + `<sitemapindex xmlns="` + sitemapXMLNS + `">`)
+	for _, loc := range locs {
+		bw.WriteString("\n  <sitemap><loc>")
+		xml.EscapeText(bw, []byte(loc))
+		bw.WriteString("</loc></sitemap>")
+	}
+	bw.WriteString("\n</sitemapindex>\n")
+	return bw.Flush()
+}
+
+// writeFile writes a file at path with write
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,110 @@
+package webcrawl_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// page is the result of a page to be indexed at u
+func page(u string) webcrawl.CrawlResult {
+	return webcrawl.CrawlResult{URL: u, StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}}}
+}
+
+// sitemapLocs returns the <loc> of the sitemap or sitemap index at path
+func sitemapLocs(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return append(doc.URLs, doc.Sitemaps...)
+}
+
+func TestSitemapBuilderAdd(t *testing.T) {
+	var b webcrawl.SitemapBuilder
+	b.Add(page("https://example.com/"))
+	noindex := page("https://example.com/private")
+	noindex.NoIndex = true
+	b.Add(noindex)
+	missing := page("https://example.com/missing")
+	missing.StatusCode = http.StatusNotFound
+	b.Add(missing)
+	other := page("https://example.com/print")
+	other.Canonical = "https://example.com/"
+	b.Add(other)
+	own := page("https://example.com/about")
+	own.Canonical = "https://example.com/about"
+	b.Add(own)
+
+	var got []string
+	for _, u := range b.URLs() {
+		got = append(got, u.Loc)
+	}
+	if want := []string{"https://example.com/", "https://example.com/about"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs = %v, want %v", got, want)
+	}
+}
+
+func TestSitemapBuilderHosts(t *testing.T) {
+	var b webcrawl.SitemapBuilder
+	for _, u := range []string{"https://example.com/", "https://example.com/a", "https://blog.example.com/", "http://example.com:8080/x"} {
+		b.Add(page(u))
+	}
+	dir := t.TempDir()
+	files, err := b.WriteFiles(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"https_example.com":      {"https://example.com/", "https://example.com/a"},
+		"https_blog.example.com": {"https://blog.example.com/"},
+		"http_example.com_8080":  {"http://example.com:8080/x"},
+	}
+	if len(files) != len(want) {
+		t.Errorf("wrote %v, want a sitemap.xml for each of the %d hosts", files, len(want))
+	}
+	for sub, locs := range want {
+		if got := sitemapLocs(t, filepath.Join(dir, sub, "sitemap.xml")); !reflect.DeepEqual(got, locs) {
+			t.Errorf("sitemap of %s lists %v, want %v", sub, got, locs)
+		}
+	}
+}
+
+func TestSitemapIndexBase(t *testing.T) {
+	var b webcrawl.SitemapBuilder
+	for i := range webcrawl.SitemapMaxURLs + 1 {
+		b.Add(page("https://x.test/p/" + strconv.Itoa(i)))
+	}
+	for _, base := range []string{"https://x.test/sitemaps", "https://x.test/sitemaps/"} {
+		dir := t.TempDir()
+		files, err := b.WriteFiles(dir, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 3 {
+			t.Fatalf("wrote %v, want 2 sitemaps and their index", files)
+		}
+		got := sitemapLocs(t, filepath.Join(dir, "sitemap.xml"))
+		want := []string{"https://x.test/sitemaps/sitemap-1.xml", "https://x.test/sitemaps/sitemap-2.xml"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("index under %s lists %v, want %v", base, got, want)
+		}
+		if n := len(sitemapLocs(t, filepath.Join(dir, "sitemap-1.xml"))); n != webcrawl.SitemapMaxURLs {
+			t.Errorf("sitemap-1.xml lists %d URLs, want %d", n, webcrawl.SitemapMaxURLs)
+		}
+	}
+}
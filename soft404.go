@@ -0,0 +1,105 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// DefaultSoft404Distance is how many bits of SimHash apart a page and the
+// one a host answers for a URL that doesn't exist can be, for the page to
+// be a soft 404, when Soft404Detector.MaxDistance is not set
+const DefaultSoft404Distance = 3
+
+// Soft404Detector finds the soft 404s of a crawl: the pages a site answers
+// with a 2xx status when they don't exist. For each host, it fetches a
+// random path that can't exist, such as /webcrawl-5f1e0c9a2b7d4e36, and
+// fingerprints what comes back with SimHash, its URL left out: the pages
+// that are the same but for a few bits are soft 404s. A host that
+// redirects the probe, to its home page for instance, has the pages
+// redirecting to the same place flagged instead. A host answering the
+// probe with an error status has no soft 404s.
+//
+// It makes one more request per host, which robots.txt and the rate
+// limits don't hold back. A Soft404Detector is safe for concurrent use,
+// its zero value is ready to use.
+type Soft404Detector struct {
+	// MaxDistance is how many bits of SimHash apart a page and the probe
+	//   can be, DefaultSoft404Distance when zero
+	MaxDistance int
+
+	mu    sync.Mutex
+	hosts map[string]*soft404Probe // scheme://host => its probe
+}
+
+// soft404Probe is what a host answered for a URL that can't exist
+type soft404Probe struct {
+	once  sync.Once
+	page  bool   // it came back with a page
+	final string // where it redirected to, if anywhere
+	hash  uint64 // the SimHash of the page
+}
+
+// Soft404 reports whether res, which f fetched, is a soft 404, probing
+// the host of the page first if need be. Only the HTML pages fetched fine
+// can be, the home page of a host never is.
+func (d *Soft404Detector) Soft404(ctx context.Context, f Fetcher, res CrawlResult) bool {
+	if res.Err != nil || res.Body == "" || res.Duplicate || res.StatusCode/100 > 2 || !htmlResult(res) {
+		return false
+	}
+	u, err := url.Parse(pageURL(res))
+	if err != nil || u.Host == "" || u.Path == "" || u.Path == "/" {
+		return false
+	}
+	p := d.probe(ctx, f, u)
+	switch {
+	case !p.page:
+		return false
+	case p.final != "":
+		return len(res.Redirects) > 0 && pageURL(res) == p.final
+	}
+	max := d.MaxDistance
+	if max <= 0 {
+		max = DefaultSoft404Distance
+	}
+	return SimHashDistance(soft404Hash(res.Body, u), p.hash) <= max
+}
+
+// soft404Hash returns the SimHash of body, found at u, without the URL in
+// it: an error page often says which page it couldn't find
+func soft404Hash(body string, u *url.URL) uint64 {
+	return SimHash(strings.NewReplacer(u.String(), "", u.RequestURI(), "", u.Path, "").Replace(body))
+}
+
+// probe returns the probe of the host of u, fetching it with f the first
+// time
+func (d *Soft404Detector) probe(ctx context.Context, f Fetcher, u *url.URL) *soft404Probe {
+	key := u.Scheme + "://" + u.Host
+	d.mu.Lock()
+	if d.hosts == nil {
+		d.hosts = make(map[string]*soft404Probe)
+	}
+	p := d.hosts[key]
+	if p == nil {
+		p = &soft404Probe{}
+		d.hosts[key] = p
+	}
+	d.mu.Unlock()
+
+	p.once.Do(func() {
+		probe := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: fmt.Sprintf("/webcrawl-%016x", rand.Uint64())}
+		resp, err := FetchResponse(ctx, f, probe.String())
+		if err != nil || resp.Body == "" || resp.StatusCode/100 > 2 {
+			return
+		}
+		p.page = true
+		if n := len(resp.Redirects); n > 0 {
+			p.final = resp.Redirects[n-1].To
+		}
+		p.hash = soft404Hash(resp.Body, probe)
+	})
+	return p
+}
@@ -0,0 +1,175 @@
+package webcrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultInMemory is how many items a SpillFrontier keeps in memory when
+// NewSpillFrontier is given no limit.
+const DefaultInMemory = 100_000
+
+// SpillFrontier is a first-in first-out Frontier, as QueueFrontier, for
+// frontiers too big to hold in memory: it keeps the head of the queue in
+// memory and spills the tail to append-only segment files in a directory,
+// reading them back in as the head drains. At most its limit of items are
+// in memory at a time.
+//
+// The Frontier methods have no way of telling of a failure of the disk:
+// the first one is kept for Err, and the items that couldn't be spilled
+// are lost.
+type SpillFrontier struct {
+	dir   string
+	limit int
+	head  QueueFrontier
+
+	// The tail is in the segments, oldest first, the last of them being
+	//   written to when w is not nil
+	segments []spillSegment
+	f        *os.File
+	w        *bufio.Writer
+	enc      *json.Encoder
+	spilled  int // items in the segments
+	seq      int // of the last segment
+	err      error
+}
+
+// spillSegment is a file of the tail, a FrontierItem in JSON per line
+type spillSegment struct {
+	name string
+	n    int
+}
+
+// NewSpillFrontier returns an empty SpillFrontier keeping limit items in
+// memory, DefaultInMemory when not positive, and spilling the others to
+// dir, which it creates if need be. Close removes the segments left.
+func NewSpillFrontier(dir string, limit int) (*SpillFrontier, error) {
+	if limit <= 0 {
+		limit = DefaultInMemory
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &SpillFrontier{dir: dir, limit: limit}, nil
+}
+
+// Err returns the first failure to spill items to disk or read them back.
+func (s *SpillFrontier) Err() error {
+	return s.err
+}
+
+func (s *SpillFrontier) fail(err error) {
+	if s.err == nil {
+		s.err = fmt.Errorf("webcrawl: frontier: %w", err)
+	}
+}
+
+// Push implements Frontier.
+func (s *SpillFrontier) Push(it FrontierItem) {
+	// Once something is spilled, everything goes after it
+	if s.spilled == 0 && s.head.Len() < s.limit {
+		s.head.Push(it)
+		return
+	}
+	if s.w == nil || s.segments[len(s.segments)-1].n >= s.limit {
+		if err := s.startSegment(); err != nil {
+			s.fail(err)
+			return
+		}
+	}
+	if err := s.enc.Encode(it); err != nil {
+		s.fail(err)
+		return
+	}
+	s.segments[len(s.segments)-1].n++
+	s.spilled++
+}
+
+// startSegment closes the segment being written, if any, and starts the
+// next one
+func (s *SpillFrontier) startSegment() error {
+	if err := s.closeSegment(); err != nil {
+		return err
+	}
+	s.seq++
+	name := filepath.Join(s.dir, fmt.Sprintf("frontier-%06d.jsonl", s.seq))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	s.f, s.w = f, bufio.NewWriter(f)
+	s.enc = json.NewEncoder(s.w)
+	s.segments = append(s.segments, spillSegment{name: name})
+	return nil
+}
+
+// closeSegment closes the segment being written, if any
+func (s *SpillFrontier) closeSegment() error {
+	if s.w == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f, s.w, s.enc = nil, nil, nil
+	return err
+}
+
+// Pop implements Frontier.
+func (s *SpillFrontier) Pop() (FrontierItem, bool) {
+	if s.head.Len() == 0 && s.spilled > 0 {
+		if err := s.reload(); err != nil {
+			s.fail(err)
+		}
+	}
+	return s.head.Pop()
+}
+
+// reload reads the oldest segment back into the head, and removes it
+func (s *SpillFrontier) reload() error {
+	seg := s.segments[0]
+	s.segments = s.segments[1:]
+	s.spilled -= seg.n
+	if len(s.segments) == 0 {
+		// It is the one being written
+		if err := s.closeSegment(); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(seg.name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(seg.name)
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for i := 0; i < seg.n; i++ {
+		var it FrontierItem
+		if err := dec.Decode(&it); err != nil {
+			return err
+		}
+		s.head.Push(it)
+	}
+	return nil
+}
+
+// Len implements Frontier.
+func (s *SpillFrontier) Len() int {
+	return s.head.Len() + s.spilled
+}
+
+// Close removes the segments left on disk, the items in them are lost.
+func (s *SpillFrontier) Close() error {
+	err := s.closeSegment()
+	for _, seg := range s.segments {
+		if rerr := os.Remove(seg.name); err == nil {
+			err = rerr
+		}
+	}
+	s.segments, s.spilled = nil, 0
+	return err
+}
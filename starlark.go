@@ -0,0 +1,394 @@
+package webcrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// DefaultStarlarkSteps is how many steps a call to a StarlarkScript may
+// take when its MaxSteps is not set, the loading of the script included: a
+// few thousand per page parsed by hand is plenty.
+const DefaultStarlarkSteps = 1_000_000
+
+// starlarkOptions are the dialect of the scripts: while loops, the set
+// type and if and for at the top level, no recursion
+var starlarkOptions = &syntax.FileOptions{Set: true, While: true, TopLevelControl: true}
+
+// StarlarkScript is a Script in Starlark, the dialect of Python of Bazel,
+// run in this process in a sandbox: it has the built-ins of the language
+// and no more, no files, network, clock nor load, and a call is cut off
+// after MaxSteps steps or Timeout, whichever comes first. Its functions get
+// the page as a dict, of the keys of ScriptPage in JSON, and return plain
+// values, None, bools, numbers, strings, lists and dicts:
+//
+//	def should_follow(url):
+//	    return "/private/" not in url
+//
+//	def extract(page):
+//	    i = page["body"].find("<h1>")
+//	    return {"h1": page["body"][i+4:page["body"].find("</h1>")]} if i >= 0 else None
+//
+//	def transform(page):
+//	    page["links"] = [l for l in page["links"] if not l.endswith(".pdf")]
+//
+// transform may change the dict in place, return a dict of its own, or
+// False to drop the result. The globals of the script are frozen once it
+// is loaded, the calls sharing no state: they may run at once.
+type StarlarkScript struct {
+	// MaxSteps is how many steps a call may take, DefaultStarlarkSteps
+	//   when zero
+	MaxSteps uint64
+
+	// Timeout is how long a call may take, DefaultScriptTimeout when zero
+	Timeout time.Duration
+
+	name                             string
+	shouldFollow, extract, transform starlark.Callable
+}
+
+// OpenStarlarkScript loads the Starlark script at path, OpenScript's
+// engine for ".star" files.
+func OpenStarlarkScript(path string) (*StarlarkScript, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewStarlarkScript(path, src)
+}
+
+// NewStarlarkScript loads the Starlark script src, called name in its
+// errors. Its top level runs within DefaultStarlarkSteps and
+// DefaultScriptTimeout.
+func NewStarlarkScript(name string, src []byte) (*StarlarkScript, error) {
+	s := &StarlarkScript{name: name}
+	var globals starlark.StringDict
+	err := s.run(context.Background(), "load", func(thread *starlark.Thread) (err error) {
+		globals, err = starlark.ExecFileOptions(starlarkOptions, thread, name, src, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for fn, dst := range map[string]*starlark.Callable{"should_follow": &s.shouldFollow, "extract": &s.extract, "transform": &s.transform} {
+		v, ok := globals[fn]
+		if !ok {
+			continue
+		}
+		if *dst, ok = v.(starlark.Callable); !ok {
+			return nil, fmt.Errorf("webcrawl: script: %s: %s is a %s, not a function", name, fn, v.Type())
+		}
+	}
+	return s, nil
+}
+
+// run runs f on a thread of its own, cut off once it took more steps or
+// time than it may, or once ctx is done
+func (s *StarlarkScript) run(ctx context.Context, fn string, f func(*starlark.Thread) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	steps, timeout := s.MaxSteps, s.Timeout
+	if steps == 0 {
+		steps = DefaultStarlarkSteps
+	}
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+	thread := &starlark.Thread{
+		Name:  s.name + " " + fn,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Fprintf(os.Stderr, "%s: %s\n", s.name, msg) },
+	}
+	thread.SetMaxExecutionSteps(steps)
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		thread.Cancel("timed out")
+	})
+	defer timer.Stop()
+	defer context.AfterFunc(ctx, func() { thread.Cancel("canceled") })()
+
+	err := f(thread)
+	switch {
+	case err == nil:
+		return nil
+	case timedOut.Load():
+		return fmt.Errorf("%w: %s: %s after %v", ErrScriptTimeout, s.name, fn, timeout)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		// Where in the script it failed, past the built-in it called
+		for i := range evalErr.CallStack {
+			if pos := evalErr.CallStack.At(i).Pos; pos.Filename() != "<builtin>" {
+				return fmt.Errorf("webcrawl: script: %s: %s", pos, evalErr.Msg)
+			}
+		}
+	}
+	return fmt.Errorf("webcrawl: script: %w", err)
+}
+
+// call calls the function fn of the script, f, with args
+func (s *StarlarkScript) call(ctx context.Context, fn string, f starlark.Callable, args ...starlark.Value) (starlark.Value, error) {
+	var res starlark.Value
+	err := s.run(ctx, fn, func(thread *starlark.Thread) (err error) {
+		res, err = starlark.Call(thread, f, args, nil)
+		return err
+	})
+	return res, err
+}
+
+// ShouldFollow implements Script.
+func (s *StarlarkScript) ShouldFollow(ctx context.Context, url string) (bool, error) {
+	if s.shouldFollow == nil {
+		return true, nil
+	}
+	res, err := s.call(ctx, "should_follow", s.shouldFollow, starlark.String(url))
+	if err != nil || res == starlark.None {
+		return true, err
+	}
+	follow, ok := res.(starlark.Bool)
+	if !ok {
+		return true, fmt.Errorf("webcrawl: script: %s: should_follow returned a %s, want a bool", s.name, res.Type())
+	}
+	return bool(follow), nil
+}
+
+// Extract implements Script.
+func (s *StarlarkScript) Extract(ctx context.Context, page *ScriptPage) (map[string]any, error) {
+	if s.extract == nil {
+		return nil, nil
+	}
+	d, err := starlarkPage(page)
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: script: %s: extract: %w", s.name, err)
+	}
+	res, err := s.call(ctx, "extract", s.extract, d)
+	if err != nil || res == starlark.None {
+		return nil, err
+	}
+	v, err := fromStarlark(res)
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: script: %s: extract: %w", s.name, err)
+	}
+	fields, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("webcrawl: script: %s: extract returned a %s, want a dict", s.name, res.Type())
+	}
+	return fields, nil
+}
+
+// Transform implements Script.
+func (s *StarlarkScript) Transform(ctx context.Context, page *ScriptPage) (bool, error) {
+	if s.transform == nil {
+		return true, nil
+	}
+	d, err := starlarkPage(page)
+	if err != nil {
+		return true, fmt.Errorf("webcrawl: script: %s: transform: %w", s.name, err)
+	}
+	res, err := s.call(ctx, "transform", s.transform, d)
+	if err != nil {
+		return true, err
+	}
+	switch res := res.(type) {
+	case starlark.Bool:
+		if !res {
+			return false, nil
+		}
+	case *starlark.Dict:
+		d = res
+	default:
+		if res != starlark.None {
+			return true, fmt.Errorf("webcrawl: script: %s: transform returned a %s, want a dict, a bool or None", s.name, res.Type())
+		}
+	}
+	if err := pageFromStarlark(d, page); err != nil {
+		return true, fmt.Errorf("webcrawl: script: %s: transform: %w", s.name, err)
+	}
+	return true, nil
+}
+
+// Close implements Script, there is nothing to close.
+func (s *StarlarkScript) Close() error { return nil }
+
+// starlarkPage returns page as a dict, every key of it set
+func starlarkPage(page *ScriptPage) (*starlark.Dict, error) {
+	links := make([]starlark.Value, len(page.Links))
+	for i, l := range page.Links {
+		links[i] = starlark.String(l)
+	}
+	fields, err := toStarlark(page.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("fields: %w", err)
+	}
+	if fields == starlark.None {
+		fields = new(starlark.Dict)
+	}
+	d := starlark.NewDict(8)
+	d.SetKey(starlark.String("url"), starlark.String(page.URL))
+	d.SetKey(starlark.String("depth"), starlark.MakeInt(page.Depth))
+	d.SetKey(starlark.String("status"), starlark.MakeInt(page.StatusCode))
+	d.SetKey(starlark.String("content_type"), starlark.String(page.ContentType))
+	d.SetKey(starlark.String("body"), starlark.String(page.Body))
+	d.SetKey(starlark.String("links"), starlark.NewList(links))
+	d.SetKey(starlark.String("text"), starlark.String(page.Text))
+	d.SetKey(starlark.String("fields"), fields)
+	return d, nil
+}
+
+// pageFromStarlark sets the links, text and fields of page to those of d,
+// the keys d has
+func pageFromStarlark(d *starlark.Dict, page *ScriptPage) error {
+	changed := *page
+	if v, ok, _ := d.Get(starlark.String("links")); ok {
+		list, ok := v.(starlark.Indexable)
+		if _, isString := v.(starlark.String); !ok || isString {
+			return fmt.Errorf("links: a %s, want a list", v.Type())
+		}
+		changed.Links = make([]string, list.Len())
+		for i := range changed.Links {
+			l, ok := starlark.AsString(list.Index(i))
+			if !ok {
+				return fmt.Errorf("links: a %s, want strings", list.Index(i).Type())
+			}
+			changed.Links[i] = l
+		}
+	}
+	if v, ok, _ := d.Get(starlark.String("text")); ok {
+		s, ok := starlark.AsString(v)
+		if !ok {
+			return fmt.Errorf("text: a %s, want a string", v.Type())
+		}
+		changed.Text = s
+	}
+	if v, ok, _ := d.Get(starlark.String("fields")); ok {
+		fields, err := fromStarlark(v)
+		if err != nil {
+			return fmt.Errorf("fields: %w", err)
+		}
+		m, ok := fields.(map[string]any)
+		if fields != nil && !ok {
+			return fmt.Errorf("fields: a %s, want a dict", v.Type())
+		}
+		changed.Fields = m
+	}
+	*page = changed
+	return nil
+}
+
+// toStarlark returns v as a Starlark value, by way of JSON for the types
+// JSON has no plainer Go form of
+func toStarlark(v any) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case []string:
+		list := make([]starlark.Value, len(v))
+		for i, s := range v {
+			list[i] = starlark.String(s)
+		}
+		return starlark.NewList(list), nil
+	case []any:
+		list := make([]starlark.Value, len(v))
+		for i, e := range v {
+			sv, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = sv
+		}
+		return starlark.NewList(list), nil
+	case map[string]any:
+		d := starlark.NewDict(len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sv, err := toStarlark(v[k])
+			if err != nil {
+				return nil, err
+			}
+			d.SetKey(starlark.String(k), sv)
+		}
+		return d, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var plain any
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return nil, err
+	}
+	return toStarlark(plain)
+}
+
+// fromStarlark returns the Starlark value v as a Go one: nil, a bool, an
+// int64, a float64, a string, a []any or a map[string]any
+func fromStarlark(v starlark.Value) (any, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		if n, ok := v.Int64(); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("%s is too big an int", v)
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bytes:
+		return string(v), nil
+	case starlark.Indexable:
+		// Lists and tuples
+		list := make([]any, v.Len())
+		for i := range list {
+			e, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = e
+		}
+		return list, nil
+	case *starlark.Dict:
+		m := make(map[string]any, v.Len())
+		for _, kv := range v.Items() {
+			k, ok := starlark.AsString(kv[0])
+			if !ok {
+				return nil, fmt.Errorf("a dict key of %s, want strings", kv[0].Type())
+			}
+			e, err := fromStarlark(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			m[k] = e
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("a %s, want None, a bool, a number, a string, a list or a dict", v.Type())
+}
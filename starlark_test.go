@@ -0,0 +1,204 @@
+package webcrawl_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/testsite"
+)
+
+// steer is a script of every function, for the pages of testsite
+const steer = `
+def should_follow(url):
+    return "/private" not in url
+
+def extract(page):
+    body = page["body"]
+    i = body.find("<title>")
+    if i < 0:
+        return None
+    return {"title": body[i+len("<title>"):body.find("</title>")].lower(), "links": len(page["links"])}
+
+def transform(page):
+    if page["url"].endswith("/drop"):
+        return False
+    page["links"] = [l for l in page["links"] if not l.endswith(".pdf")]
+    page["fields"]["seen"] = True
+`
+
+func TestStarlarkScript(t *testing.T) {
+	s, err := webcrawl.NewStarlarkScript("steer.star", []byte(steer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for url, want := range map[string]bool{"https://site.test/a": true, "https://site.test/private/b": false} {
+		if got, err := s.ShouldFollow(ctx, url); err != nil || got != want {
+			t.Errorf("ShouldFollow(%s) = %v, %v, want %v", url, got, err, want)
+		}
+	}
+
+	page := &webcrawl.ScriptPage{URL: "https://site.test/a", Body: "<title>About Us</title>", Links: []string{"/b", "/c.pdf"}}
+	fields, err := s.Extract(ctx, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"title": "about us", "links": int64(2)}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Extract = %v, want %v", fields, want)
+	}
+	if fields, err := s.Extract(ctx, &webcrawl.ScriptPage{URL: "https://site.test/b"}); err != nil || fields != nil {
+		t.Errorf("Extract of a page without a title = %v, %v, want none", fields, err)
+	}
+
+	page.Fields = map[string]any{"title": "about us"}
+	keep, err := s.Transform(ctx, page)
+	if err != nil || !keep {
+		t.Fatalf("Transform = %v, %v, want it kept", keep, err)
+	}
+	if !reflect.DeepEqual(page.Links, []string{"/b"}) || page.Fields["seen"] != true || page.Fields["title"] != "about us" {
+		t.Errorf("Transform made links %q, fields %v", page.Links, page.Fields)
+	}
+	if keep, err := s.Transform(ctx, &webcrawl.ScriptPage{URL: "https://site.test/drop"}); err != nil || keep {
+		t.Errorf("Transform of /drop = %v, %v, want it dropped", keep, err)
+	}
+}
+
+func TestStarlarkScriptLeftOut(t *testing.T) {
+	s, err := webcrawl.NewStarlarkScript("empty.star", []byte("# Nothing to steer\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	page := &webcrawl.ScriptPage{URL: "https://site.test/", Links: []string{"/a"}}
+	follow, err := s.ShouldFollow(ctx, page.URL)
+	if err != nil || !follow {
+		t.Errorf("ShouldFollow = %v, %v, want true", follow, err)
+	}
+	if fields, err := s.Extract(ctx, page); err != nil || fields != nil {
+		t.Errorf("Extract = %v, %v, want none", fields, err)
+	}
+	if keep, err := s.Transform(ctx, page); err != nil || !keep || len(page.Links) != 1 {
+		t.Errorf("Transform = %v, %v, links %q, want the page as it was", keep, err, page.Links)
+	}
+}
+
+func TestStarlarkScriptSandbox(t *testing.T) {
+	for name, src := range map[string]string{
+		"load":      `load("other.star", "x")`,
+		"open":      `f = open("/etc/passwd")`,
+		"exec":      `exec("true")`,
+		"recursion": "def f(n):\n    return f(n)\nf(1)\n",
+		"loop":      "x = 0\nwhile True:\n    x += 1\n",
+		"syntax":    "def should_follow(url)\n",
+		"not a fun": "should_follow = True\n",
+	} {
+		if _, err := webcrawl.NewStarlarkScript(name+".star", []byte(src)); err == nil {
+			t.Errorf("%s: loaded", name)
+		} else if !strings.HasPrefix(err.Error(), "webcrawl: script") {
+			t.Errorf("%s: %v, want a webcrawl: script error", name, err)
+		}
+	}
+}
+
+func TestStarlarkScriptLimits(t *testing.T) {
+	const spin = "def should_follow(url):\n    while True:\n        pass\n"
+	s, err := webcrawl.NewStarlarkScript("spin.star", []byte(spin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxSteps = 10_000
+	follow, err := s.ShouldFollow(context.Background(), "https://site.test/")
+	if err == nil || !strings.Contains(err.Error(), "too many steps") || !follow {
+		t.Errorf("spinning past MaxSteps: %v, %v, want too many steps", follow, err)
+	}
+
+	s.MaxSteps, s.Timeout = 1<<62, 50*time.Millisecond
+	start := time.Now()
+	if _, err := s.ShouldFollow(context.Background(), "https://site.test/"); !errors.Is(err, webcrawl.ErrScriptTimeout) {
+		t.Errorf("spinning past Timeout: %v, want ErrScriptTimeout", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("cut off after %v, want about %v", took, s.Timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.Timeout = time.Minute
+	if _, err := s.ShouldFollow(ctx, "https://site.test/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("spinning past the context: %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestOpenScript(t *testing.T) {
+	dir := t.TempDir()
+	star := filepath.Join(dir, "steer.star")
+	if err := os.WriteFile(star, []byte(steer), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := webcrawl.OpenScript(star)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, ok := s.(*webcrawl.StarlarkScript); !ok {
+		t.Errorf("OpenScript of a .star file = %T, want a *StarlarkScript", s)
+	}
+
+	// An executable is not run, nor taken for a script
+	exe := filepath.Join(dir, "steer.sh")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\ntouch ran\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := webcrawl.OpenScript(exe); err == nil {
+		s.Close()
+		t.Error("OpenScript of an executable: no error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+		t.Error("OpenScript ran the executable")
+	}
+}
+
+func TestStarlarkScriptHooks(t *testing.T) {
+	site := testsite.New("http://site.test")
+	site.Add("/", &testsite.Page{Title: "Home", Links: []string{"/a", "/private/b", "/drop", "/c.pdf"}})
+	site.Add("/a", &testsite.Page{Title: "A"})
+	site.Add("/private/b", &testsite.Page{Title: "B"})
+	site.Add("/drop", &testsite.Page{Title: "Drop"})
+	site.Add("/c.pdf", &testsite.Page{Title: "C"})
+
+	s, err := webcrawl.NewStarlarkScript("steer.star", []byte(steer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var got []string
+	titles := make(map[string]any)
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithoutRobots(), webcrawl.WithDepth(webcrawl.UnlimitedDepth),
+		webcrawl.WithHooks(webcrawl.ScriptHooks(s, func(err error) { t.Error(err) })),
+		webcrawl.WithOnResult(func(res webcrawl.CrawlResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, strings.TrimPrefix(res.URL, "http://site.test"))
+			titles[res.URL] = res.Fields["title"]
+		}))
+	if err := c.Run(context.Background(), "http://site.test/"); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	// /private/b not followed, /drop dropped, /c.pdf taken off the links
+	if want := []string{"/", "/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results %q, want %q", got, want)
+	}
+	if titles["http://site.test/a"] != "a" {
+		t.Errorf("title field of /a %v, want a", titles["http://site.test/a"])
+	}
+}
@@ -0,0 +1,567 @@
+// Package store saves the results of crawls in a SQL database, SQLite or
+// PostgreSQL, for the crawl history to be queried in SQL and runs to be
+// compared.
+//
+// It works with any database/sql driver of them, which the program
+// imports, modernc.org/sqlite or github.com/jackc/pgx/v5/stdlib for
+// instance:
+//
+//	s, err := store.Open("sqlite", "crawl.db")
+//	...
+//	defer s.Close()
+//	run, err := s.StartRun(ctx, seed)
+//	...
+//	c := webcrawl.NewCrawler(webcrawl.WithOnResult(func(r webcrawl.CrawlResult) {
+//		if err := run.Add(r); err != nil {
+//			log.Print(err)
+//		}
+//	}))
+//	err = c.Run(ctx, seed)
+//	run.Finish()
+//
+// # Schema
+//
+// Open brings the schema up to date, running the migrations the database
+// hasn't had yet, which schema_migrations records:
+//
+//	runs       id, seed, started_at, finished_at
+//	pages      url, run_id, status, fetched_at, duration_ms, error,
+//	           cause, content_hash, depth, body_size, noindex,
+//	           first_run_id, first_seen_at, header, body
+//	fetches    run_id, url, status, fetched_at, duration_ms, error,
+//	           cause, content_hash, depth
+//	links      run_id, source, target, nofollow
+//
+// pages has the last fetch of every URL, whichever run it was in, its
+// headers as JSON and its body with it, and when the URL was first
+// fetched, see QueryByURL. The other tables have the rows of each run:
+// every fetch and every link found, see History. The times are UTC, in
+// RFC 3339 to the nanosecond with SQLite, and pages is indexed by
+// fetched_at, see QuerySinceTime. To see what changed between runs 1 and 2:
+//
+//	SELECT b.url, a.status, b.status FROM fetches a JOIN fetches b USING (url)
+//	WHERE a.run_id = 1 AND b.run_id = 2 AND a.content_hash IS DISTINCT FROM b.content_hash
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// Dialect is the SQL of a database.
+type Dialect int
+
+const (
+	SQLite   Dialect = iota // SQLite 3.35 or later
+	Postgres                // PostgreSQL 9.5 or later
+)
+
+// DriverDialect returns the dialect of the database/sql driver named
+// driver: sqlite, sqlite3, postgres, pgx or pq.
+func DriverDialect(driver string) (Dialect, error) {
+	switch driver {
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "pgx", "pq":
+		return Postgres, nil
+	}
+	return 0, fmt.Errorf("store: unknown driver %q, want sqlite or postgres", driver)
+}
+
+// Store is a database of crawls. It is safe for concurrent use.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+
+	// Writes one at a time, SQLite taking them so
+	mu       sync.Mutex
+	upsertID int64 // the run of UpsertResult, once started
+}
+
+// Open opens the database dsn with driver and migrates it, see
+// DriverDialect.
+func Open(driver, dsn string) (*Store, error) {
+	d, err := DriverDialect(driver)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := New(context.Background(), db, d)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New returns the Store of db, migrating it.
+func New(ctx context.Context, db *sql.DB, d Dialect) (*Store, error) {
+	s := &Store{db: db, dialect: d}
+	if err := s.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("store: migrating: %w", err)
+	}
+	return s, nil
+}
+
+// DB returns the database of s, for queries.
+func (s *Store) DB() *sql.DB { return s.db }
+
+// Close finishes the run of UpsertResult, if it started one, and closes
+// the database.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	id := s.upsertID
+	s.mu.Unlock()
+	var err error
+	if id != 0 {
+		err = (&Run{ID: id, store: s}).Finish()
+	}
+	if cerr := s.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// migrations are the changes of the schema, in order: the version of a
+// database is how many it had. {{id}} is the type of the keys, {{time}}
+// the one of times
+var migrations = []string{
+	`CREATE TABLE runs (
+		id {{id}},
+		seed TEXT NOT NULL,
+		started_at {{time}} NOT NULL,
+		finished_at {{time}}
+	);
+	CREATE TABLE pages (
+		url TEXT PRIMARY KEY,
+		run_id BIGINT NOT NULL REFERENCES runs (id),
+		status INTEGER,
+		fetched_at {{time}},
+		duration_ms DOUBLE PRECISION,
+		error TEXT,
+		cause TEXT,
+		content_hash TEXT,
+		depth INTEGER NOT NULL,
+		body_size BIGINT,
+		noindex BOOLEAN NOT NULL,
+		first_run_id BIGINT NOT NULL REFERENCES runs (id)
+	);
+	CREATE TABLE fetches (
+		run_id BIGINT NOT NULL REFERENCES runs (id),
+		url TEXT NOT NULL,
+		status INTEGER,
+		fetched_at {{time}},
+		duration_ms DOUBLE PRECISION,
+		error TEXT,
+		cause TEXT,
+		content_hash TEXT,
+		depth INTEGER NOT NULL,
+		PRIMARY KEY (run_id, url)
+	);
+	CREATE TABLE links (
+		run_id BIGINT NOT NULL REFERENCES runs (id),
+		source TEXT NOT NULL,
+		target TEXT NOT NULL,
+		nofollow BOOLEAN NOT NULL,
+		PRIMARY KEY (run_id, source, target)
+	);
+	CREATE INDEX links_target ON links (run_id, target)`,
+	// For QuerySinceTime, the SQLite times of version 1 padded to
+	//   nanoseconds for them to sort as text
+	`{{sqlite}} UPDATE pages SET fetched_at = {{pad fetched_at}} WHERE fetched_at IS NOT NULL;
+	{{sqlite}} UPDATE fetches SET fetched_at = {{pad fetched_at}} WHERE fetched_at IS NOT NULL;
+	CREATE INDEX pages_fetched_at ON pages (fetched_at)`,
+	`ALTER TABLE pages ADD COLUMN first_seen_at {{time}};
+	ALTER TABLE pages ADD COLUMN header TEXT;
+	ALTER TABLE pages ADD COLUMN body TEXT;
+	UPDATE pages SET first_seen_at = (SELECT MIN(f.fetched_at) FROM fetches f WHERE f.url = pages.url)`,
+}
+
+// sqliteTime is how SQLite stores times: RFC 3339 in UTC, to the
+// nanosecond always, for the text to sort as the times do
+const sqliteTime = "2006-01-02T15:04:05.000000000Z07:00"
+
+// padTime is the SQLite expression turning the RFC 3339 UTC time in a
+// column, {{pad column}}, into a sqliteTime: the fraction of a second,
+// if any, padded to 9 digits
+const padTime = `substr(col, 1, 19) || '.' || substr(CASE WHEN instr(col, '.') > 0 THEN substr(col, 21, length(col) - 21) ELSE '' END || '000000000', 1, 9) || 'Z'`
+
+// migrate runs the migrations db hasn't had
+func (s *Store) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	for v := version; v < len(migrations); v++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range strings.Split(migrations[v], ";") {
+			stmt, sqlite := strings.CutPrefix(strings.TrimSpace(stmt), "{{sqlite}}")
+			if sqlite && s.dialect != SQLite {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, s.ddl(stmt)); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("version %d: %w", v+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), v+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ddl returns the statement m of a migration in the dialect of s
+func (s *Store) ddl(m string) string {
+	id, ts := "INTEGER PRIMARY KEY", "TEXT"
+	if s.dialect == Postgres {
+		id, ts = "BIGSERIAL PRIMARY KEY", "TIMESTAMPTZ"
+	}
+	m = strings.NewReplacer("{{id}}", id, "{{time}}", ts).Replace(m)
+	for {
+		i := strings.Index(m, "{{pad ")
+		if i < 0 {
+			return m
+		}
+		col, rest, _ := strings.Cut(m[i+len("{{pad "):], "}}")
+		m = m[:i] + strings.ReplaceAll(padTime, "col", col) + rest
+	}
+}
+
+// rebind returns query with its ? placeholders in the dialect of s
+func (s *Store) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// timestamp returns t as the dialect of s stores it, NULL when zero
+func (s *Store) timestamp(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	if s.dialect == SQLite {
+		return t.UTC().Format(sqliteTime)
+	}
+	return t.UTC()
+}
+
+// Run is a crawl being saved to a Store.
+type Run struct {
+	ID    int64
+	store *Store
+}
+
+// StartRun records the start of a crawl from seed.
+func (s *Store) StartRun(ctx context.Context, seed string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var id int64
+	err := s.db.QueryRowContext(ctx, s.rebind(`INSERT INTO runs (seed, started_at) VALUES (?, ?) RETURNING id`),
+		seed, s.timestamp(time.Now())).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return &Run{ID: id, store: s}, nil
+}
+
+// Add saves the result of a fetch of the run: the fetch, the page and
+// its links. Results for the same URL replace one another, in the run
+// and in pages, where the time it was first seen is kept.
+func (r *Run) Add(res webcrawl.CrawlResult) error {
+	if err := r.store.add(context.Background(), r.ID, res); err != nil {
+		return fmt.Errorf("store: %s: %w", res.URL, err)
+	}
+	return nil
+}
+
+// UpsertResult saves res as Run.Add does, for the results that are not
+// of a Run: in a run of their own, of seed UpsertSeed, which s starts the
+// first time it is called and Close finishes. The page of its URL is
+// updated when it has one, the time it was first seen kept, see
+// QueryByURL; the results for one URL replace one another in the run as
+// in any other, so that an incremental crawl upserting its results with a
+// Store of its own leaves one row of each URL in History.
+func (s *Store) UpsertResult(res webcrawl.CrawlResult) error {
+	ctx := context.Background()
+	id, err := s.upsertRun(ctx)
+	if err == nil {
+		err = s.add(ctx, id, res)
+	}
+	if err != nil {
+		return fmt.Errorf("store: %s: %w", res.URL, err)
+	}
+	return nil
+}
+
+// UpsertSeed is the seed of the runs UpsertResult saves the results in.
+const UpsertSeed = "upsert:"
+
+// upsertRun returns the ID of the run of UpsertResult, starting it if
+// need be
+func (s *Store) upsertRun(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.upsertID == 0 {
+		err := s.db.QueryRowContext(ctx, s.rebind(`INSERT INTO runs (seed, started_at) VALUES (?, ?) RETURNING id`),
+			UpsertSeed, s.timestamp(time.Now())).Scan(&s.upsertID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return s.upsertID, nil
+}
+
+// add saves res in the run id
+func (s *Store) add(ctx context.Context, id int64, res webcrawl.CrawlResult) error {
+	var status, hash, errText, cause, duration, size, header, body any
+	if res.StatusCode != 0 {
+		status = res.StatusCode
+	}
+	if res.ContentHash != "" {
+		hash = res.ContentHash
+	}
+	if res.Err != nil {
+		errText, cause = res.Err.Error(), webcrawl.Classify(res.Err).Error()
+	}
+	if !res.FetchedAt.IsZero() {
+		duration = float64(res.Duration) / float64(time.Millisecond)
+	}
+	if res.BodySize > 0 || res.Body != "" {
+		size = max(res.BodySize, int64(len(res.Body)))
+	}
+	if res.Header != nil {
+		b, err := json.Marshal(res.Header)
+		if err != nil {
+			return err
+		}
+		header = string(b)
+	}
+	if res.Body != "" {
+		body = res.Body
+	}
+	fetched := s.timestamp(res.FetchedAt)
+	seen := fetched
+	if res.FetchedAt.IsZero() {
+		seen = s.timestamp(time.Now())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO fetches
+		(run_id, url, status, fetched_at, duration_ms, error, cause, content_hash, depth)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (run_id, url) DO UPDATE SET status = excluded.status, fetched_at = excluded.fetched_at,
+		duration_ms = excluded.duration_ms, error = excluded.error, cause = excluded.cause,
+		content_hash = excluded.content_hash, depth = excluded.depth`),
+		id, res.URL, status, fetched, duration, errText, cause, hash, res.Depth)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO pages
+		(url, run_id, status, fetched_at, duration_ms, error, cause, content_hash, depth, body_size, noindex, first_run_id,
+		first_seen_at, header, body)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET run_id = excluded.run_id, status = excluded.status,
+		fetched_at = excluded.fetched_at, duration_ms = excluded.duration_ms, error = excluded.error,
+		cause = excluded.cause, content_hash = excluded.content_hash, depth = excluded.depth,
+		body_size = excluded.body_size, noindex = excluded.noindex,
+		first_seen_at = COALESCE(pages.first_seen_at, excluded.first_seen_at),
+		header = excluded.header, body = excluded.body`),
+		res.URL, id, status, fetched, duration, errText, cause, hash, res.Depth, size, res.NoIndex, id, seen, header, body)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM links WHERE run_id = ? AND source = ?`), id, res.URL); err != nil {
+		return err
+	}
+	insert := s.rebind(`INSERT INTO links (run_id, source, target, nofollow) VALUES (?, ?, ?, ?)
+		ON CONFLICT (run_id, source, target) DO NOTHING`)
+	for _, links := range []struct {
+		urls     []string
+		nofollow bool
+	}{{res.Links, false}, {res.NoFollowLinks, true}} {
+		for _, u := range links.urls {
+			if _, err := tx.ExecContext(ctx, insert, id, res.URL, u, links.nofollow); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Finish records the end of the run.
+func (r *Run) Finish() error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(s.rebind(`UPDATE runs SET finished_at = ? WHERE id = ?`), s.timestamp(time.Now()), r.ID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = errors.New("no such run")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("store: finishing run %d: %w", r.ID, err)
+	}
+	return nil
+}
+
+// QuerySinceTime returns the pages fetched at t or after, by fetched_at,
+// as QueryByURL returns them. An incremental pipeline polls it with the
+// time it last processed up to, to get the newest pages alone.
+func (s *Store) QuerySinceTime(ctx context.Context, t time.Time) ([]webcrawl.CrawlResult, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+pageColumns+` FROM pages
+		WHERE fetched_at >= ? ORDER BY fetched_at, url`), s.timestamp(t))
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	results, err := scanPages(rows)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return results, nil
+}
+
+// QueryByURL returns the last result saved for url, whichever run it was
+// in, as far as pages tells of it: its status, fetch time and duration,
+// error, headers, body, content hash, depth, body size and noindex. It
+// fails with an error wrapping sql.ErrNoRows when there is none.
+func (s *Store) QueryByURL(ctx context.Context, url string) (webcrawl.CrawlResult, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+pageColumns+` FROM pages WHERE url = ?`), url)
+	if err != nil {
+		return webcrawl.CrawlResult{}, fmt.Errorf("store: %w", err)
+	}
+	results, err := scanPages(rows)
+	if err == nil && len(results) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return webcrawl.CrawlResult{}, fmt.Errorf("store: %s: %w", url, err)
+	}
+	return results[0], nil
+}
+
+// FirstSeen returns when url was first saved, the fetch time of its first
+// result. It fails with an error wrapping sql.ErrNoRows when there is
+// none.
+func (s *Store) FirstSeen(ctx context.Context, url string) (time.Time, error) {
+	var t timeValue
+	if err := s.db.QueryRowContext(ctx, s.rebind(`SELECT first_seen_at FROM pages WHERE url = ?`), url).Scan(&t); err != nil {
+		return time.Time{}, fmt.Errorf("store: %s: %w", url, err)
+	}
+	return t.Time, nil
+}
+
+// History returns the results saved for url in every run, oldest first,
+// as far as fetches tells of them: their status, fetch time and duration,
+// error, content hash and depth, for what changed over time. It is
+// empty when there are none.
+func (s *Store) History(ctx context.Context, url string) ([]webcrawl.CrawlResult, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT url, status, fetched_at, duration_ms, error, content_hash, depth,
+		NULL, false, NULL, NULL FROM fetches WHERE url = ? ORDER BY fetched_at, run_id`), url)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	results, err := scanPages(rows)
+	if err != nil {
+		return nil, fmt.Errorf("store: %s: %w", url, err)
+	}
+	return results, nil
+}
+
+// pageColumns are the columns of pages scanPages reads
+const pageColumns = `url, status, fetched_at, duration_ms, error, content_hash, depth, body_size, noindex, header, body`
+
+// scanPages reads the rows of pageColumns into results, and closes them
+func scanPages(rows *sql.Rows) ([]webcrawl.CrawlResult, error) {
+	defer rows.Close()
+	var results []webcrawl.CrawlResult
+	for rows.Next() {
+		var res webcrawl.CrawlResult
+		var status sql.NullInt64
+		var fetched timeValue
+		var duration sql.NullFloat64
+		var errText, hash, header, body sql.NullString
+		var size sql.NullInt64
+		if err := rows.Scan(&res.URL, &status, &fetched, &duration, &errText, &hash, &res.Depth, &size, &res.NoIndex, &header, &body); err != nil {
+			return nil, err
+		}
+		res.StatusCode, res.FetchedAt, res.ContentHash, res.BodySize = int(status.Int64), fetched.Time, hash.String, size.Int64
+		res.Duration = time.Duration(duration.Float64 * float64(time.Millisecond))
+		res.Body = body.String
+		if errText.Valid {
+			res.Err = errors.New(errText.String)
+		}
+		if header.Valid {
+			if err := json.Unmarshal([]byte(header.String), &res.Header); err != nil {
+				return nil, fmt.Errorf("headers of %s: %w", res.URL, err)
+			}
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// timeValue scans a time the way either dialect returns it, zero for NULL
+type timeValue struct{ time.Time }
+
+func (v *timeValue) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		v.Time = time.Time{}
+	case time.Time:
+		v.Time = src
+	case string:
+		return v.parse(src)
+	case []byte:
+		return v.parse(string(src))
+	default:
+		return fmt.Errorf("time of type %T", src)
+	}
+	return nil
+}
+
+func (v *timeValue) parse(s string) error {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	v.Time = t
+	return err
+}
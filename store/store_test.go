@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// openTest returns a Store of a new SQLite database
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open("sqlite", filepath.Join(t.TempDir(), "crawl.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// urls returns the URLs of results
+func urls(results []webcrawl.CrawlResult) []string {
+	var us []string
+	for _, r := range results {
+		us = append(us, r.URL)
+	}
+	return us
+}
+
+func TestQuerySinceTime(t *testing.T) {
+	s := openTest(t)
+	run, err := s.StartRun(context.Background(), "https://site.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, p := range []struct {
+		path string
+		at   time.Duration
+	}{
+		{"/c", 2 * time.Second},
+		{"/a", 0},
+		// A fraction of a second, which RFC3339Nano would sort before /a
+		{"/b", 500 * time.Millisecond},
+		{"/d", 3 * time.Second},
+	} {
+		if err := run.Add(webcrawl.CrawlResult{URL: "https://site.test" + p.path, StatusCode: 200, FetchedAt: t0.Add(p.at), Duration: 40 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := s.QuerySinceTime(context.Background(), t0.Add(500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(urls(results), " "), "https://site.test/b https://site.test/c https://site.test/d"; got != want {
+		t.Errorf("since t0+0.5s: %s, want %s", got, want)
+	}
+	if r := results[0]; !r.FetchedAt.Equal(t0.Add(500*time.Millisecond)) || r.StatusCode != 200 || r.Duration != 40*time.Millisecond {
+		t.Errorf("read back %+v", r)
+	}
+
+	var plan string
+	rows, err := s.DB().Query(`EXPLAIN QUERY PLAN SELECT url FROM pages WHERE fetched_at >= ?`, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		rows.Scan(&id, &parent, &unused, &detail)
+		plan += detail
+	}
+	rows.Close()
+	if !strings.Contains(plan, "pages_fetched_at") {
+		t.Errorf("query plan %q, want the fetched_at index", plan)
+	}
+}
+
+func TestMigratePadsTimes(t *testing.T) {
+	// A database of version 1, its times in RFC3339Nano
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Store{db: db, dialect: SQLite}
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER NOT NULL); INSERT INTO schema_migrations VALUES (1)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range strings.Split(old.ddl(migrations[0]), ";") {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Exec(`INSERT INTO runs (seed, started_at) VALUES ('https://site.test/', '2026-05-01T12:00:00Z')`)
+	for url, at := range map[string]string{"/a": "2026-05-01T12:00:00Z", "/b": "2026-05-01T12:00:00.5Z", "/c": "2026-05-01T12:00:00.123456789Z"} {
+		if _, err := db.Exec(`INSERT INTO pages (url, run_id, fetched_at, depth, noindex, first_run_id) VALUES (?, 1, ?, 0, false, 1)`, url, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	s, err := Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	results, err := s.QuerySinceTime(context.Background(), time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(urls(results), " "); got != "/a /c /b" {
+		t.Errorf("pages by time: %s, want /a /c /b", got)
+	}
+	if len(results) == 3 && results[1].FetchedAt.Nanosecond() != 123456789 {
+		t.Errorf("time of /c read back as %v", results[1].FetchedAt)
+	}
+}
+
+func TestUpsertResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.db")
+	ctx := context.Background()
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	const url = "https://site.test/"
+	// Two incremental crawls, each a Store of its own
+	for i, st := range []struct {
+		status int
+		body   string
+	}{{200, "<p>first</p>"}, {500, "<p>down</p>"}} {
+		s, err := Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := t0.Add(time.Duration(i) * time.Hour)
+		for _, body := range []string{"<p>early</p>", st.body} {
+			// Within a crawl, the last result of a URL counts
+			res := webcrawl.CrawlResult{URL: url, StatusCode: st.status, FetchedAt: at, Body: body,
+				Header: http.Header{"Content-Type": {"text/html"}, "X-Run": {strconv.Itoa(i)}}}
+			if err := s.UpsertResult(res); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	res, err := s.QueryByURL(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 500 || res.Body != "<p>down</p>" || res.Header.Get("X-Run") != "1" || !res.FetchedAt.Equal(t0.Add(time.Hour)) {
+		t.Errorf("QueryByURL = status %d, body %q, headers %v, fetched at %v, want the second crawl's", res.StatusCode, res.Body, res.Header, res.FetchedAt)
+	}
+	if first, err := s.FirstSeen(ctx, url); err != nil || !first.Equal(t0) {
+		t.Errorf("FirstSeen = %v, %v, want %v", first, err, t0)
+	}
+	history, err := s.History(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].StatusCode != 200 || history[1].StatusCode != 500 {
+		t.Errorf("History = %+v, want the 200 then the 500", history)
+	}
+	var runs int
+	s.DB().QueryRow(`SELECT COUNT(*) FROM runs WHERE seed = ? AND finished_at IS NOT NULL`, UpsertSeed).Scan(&runs)
+	if runs != 2 {
+		t.Errorf("%d upsert runs finished, want 2", runs)
+	}
+	if _, err := s.QueryByURL(ctx, "https://site.test/none"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("QueryByURL of a URL not saved: %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRunKeepsFirstSeen(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 2 {
+		run, err := s.StartRun(ctx, "https://site.test/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := run.Add(webcrawl.CrawlResult{URL: "https://site.test/", StatusCode: 200, FetchedAt: t0.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		run.Finish()
+	}
+	if first, err := s.FirstSeen(ctx, "https://site.test/"); err != nil || !first.Equal(t0) {
+		t.Errorf("FirstSeen = %v, %v, want %v", first, err, t0)
+	}
+}
@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/stream/streampb"
+)
+
+//go:generate protoc -I .. --go_out=.. --go_opt=module=github.com/jackyugit/webcrawl stream/crawl_result.proto
+
+// ProtoResult returns r as the CrawlResult message of crawl_result.proto.
+// Its strings not in UTF-8, which proto3 wants them in, have the bytes
+// that aren't replaced by U+FFFD.
+func ProtoResult(r webcrawl.CrawlResult) *streampb.CrawlResult {
+	m := &streampb.CrawlResult{
+		Url:           utf8(r.URL),
+		Depth:         int32(r.Depth),
+		Status:        int32(r.StatusCode),
+		ContentHash:   r.ContentHash,
+		DuplicateOf:   utf8(r.DuplicateOf),
+		Noindex:       r.NoIndex,
+		Links:         utf8s(r.Links),
+		NofollowLinks: utf8s(r.NoFollowLinks),
+		External:      r.External,
+		Duplicate:     r.Duplicate,
+	}
+	if r.Body != "" {
+		m.Body = []byte(r.Body)
+	}
+	if r.Err != nil {
+		m.Error = utf8(r.Err.Error())
+		m.Cause = webcrawl.Classify(r.Err).Error()
+	}
+	if !r.FetchedAt.IsZero() {
+		m.FetchedAtUnixNano = r.FetchedAt.UnixNano()
+		m.DurationMs = float64(r.Duration) / float64(time.Millisecond)
+	}
+	if len(r.Header) > 0 {
+		m.Headers = make(map[string]string, len(r.Header))
+		for name, values := range r.Header {
+			m.Headers[utf8(name)] = utf8(strings.Join(values, ", "))
+		}
+	}
+	return m
+}
+
+// MarshalProto returns r encoded as the CrawlResult message of
+// crawl_result.proto, the headers sorted for the same result to always
+// encode the same way.
+func MarshalProto(r webcrawl.CrawlResult) []byte {
+	// Of valid strings and numbers alone, it can't fail
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(ProtoResult(r))
+	return b
+}
+
+// utf8 returns s with the bytes not in UTF-8 replaced by U+FFFD
+func utf8(s string) string {
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+// utf8s returns the strings of ss through utf8, ss itself when they are
+// all valid
+func utf8s(ss []string) []string {
+	for i, s := range ss {
+		if v := utf8(s); v != s {
+			out := append([]string(nil), ss...)
+			for j := i; j < len(out); j++ {
+				out[j] = utf8(out[j])
+			}
+			return out
+		}
+	}
+	return ss
+}
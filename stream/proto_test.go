@@ -0,0 +1,39 @@
+package stream
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jackyugit/webcrawl"
+	"github.com/jackyugit/webcrawl/stream/streampb"
+)
+
+func TestMarshalProto(t *testing.T) {
+	at := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	res := webcrawl.CrawlResult{URL: "https://site.test/caf\xe9", Depth: 2, StatusCode: 500, Err: errors.New("boom"),
+		FetchedAt: at, Duration: 1500 * time.Microsecond, Body: "\xff\xfe",
+		Header: http.Header{"Content-Type": {"text/html"}, "Vary": {"Accept", "Cookie"}},
+		Links:  []string{"https://site.test/a", "https://site.test/\xe9"}}
+	b := MarshalProto(res)
+	var m streampb.CrawlResult
+	if err := proto.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Url != "https://site.test/caf�" || m.Depth != 2 || m.Status != 500 || m.Error != "boom" ||
+		m.FetchedAtUnixNano != at.UnixNano() || m.DurationMs != 1.5 || string(m.Body) != "\xff\xfe" {
+		t.Errorf("decoded %v", &m)
+	}
+	if m.Headers["Vary"] != "Accept, Cookie" || len(m.Links) != 2 || m.Links[1] != "https://site.test/�" {
+		t.Errorf("headers %v, links %q", m.Headers, m.Links)
+	}
+	if res.Links[1] != "https://site.test/\xe9" {
+		t.Errorf("links of the result changed to %q", res.Links)
+	}
+	if string(MarshalProto(res)) != string(b) {
+		t.Error("the same result encoded two ways")
+	}
+}
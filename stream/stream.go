@@ -0,0 +1,143 @@
+// Package stream publishes the results of a crawl, as they come, to Kafka
+// or NATS, for the crawler to feed streaming pipelines:
+//
+//	p := stream.NewKafka("localhost:9092")
+//	sink := &stream.Sink{Publisher: p, Topic: "crawl.results", Format: stream.JSON}
+//	defer sink.Close()
+//	c := webcrawl.NewCrawler(webcrawl.WithOnResult(func(r webcrawl.CrawlResult) {
+//		if err := sink.Add(ctx, r); err != nil {
+//			log.Print(err)
+//		}
+//	}))
+//
+// A message is a result in JSON, as webcrawl.JSONLWriter writes it, or in
+// Protobuf, as the CrawlResult message of crawl_result.proto, keyed by
+// the URL of the page. Kafka thus keeps the results of a page in order on
+// the same partition.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// Format is how a Sink serializes the results.
+type Format int
+
+const (
+	JSON     Format = iota // see webcrawl.MarshalResult
+	Protobuf               // see crawl_result.proto
+)
+
+// ParseFormat returns the Format named json or protobuf.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return JSON, nil
+	case "protobuf", "proto":
+		return Protobuf, nil
+	}
+	return 0, fmt.Errorf("stream: unknown format %q, want json or protobuf", name)
+}
+
+// Publisher sends messages to a broker.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, key, value []byte) error
+	Close() error
+}
+
+// Sink publishes crawl results to Topic. It is safe for concurrent use
+// when its Publisher is, as those of this package are.
+type Sink struct {
+	Publisher Publisher
+	Topic     string
+	Format    Format
+
+	// Fields are those of the results in JSON, all of them when empty,
+	//   see webcrawl.JSONLFields
+	Fields []string
+}
+
+// Add publishes res.
+func (s *Sink) Add(ctx context.Context, res webcrawl.CrawlResult) error {
+	var value []byte
+	switch s.Format {
+	case Protobuf:
+		value = MarshalProto(res)
+	default:
+		var err error
+		if value, err = webcrawl.MarshalResult(res, s.Fields...); err != nil {
+			return err
+		}
+	}
+	if err := s.Publisher.Publish(ctx, s.Topic, []byte(res.URL), value); err != nil {
+		return fmt.Errorf("stream: publishing %s: %w", res.URL, err)
+	}
+	return nil
+}
+
+// Close closes the Publisher, once the messages under way are sent.
+func (s *Sink) Close() error { return s.Publisher.Close() }
+
+// Kafka publishes to the topics of a Kafka cluster.
+type Kafka struct {
+	w *kafka.Writer
+}
+
+// NewKafka returns a Kafka publisher to the cluster of brokers, host:port
+// addresses. The topics are created as need be when the cluster allows
+// it.
+func NewKafka(brokers ...string) *Kafka {
+	return &Kafka{w: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+		RequiredAcks:           kafka.RequireOne,
+	}}
+}
+
+// Publish sends a message and waits for the broker to have it.
+func (k *Kafka) Publish(ctx context.Context, topic string, key, value []byte) error {
+	return k.w.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value})
+}
+
+// Close closes the connections to the brokers.
+func (k *Kafka) Close() error { return k.w.Close() }
+
+// NATS publishes to the subjects of a NATS server.
+type NATS struct {
+	conn *nats.Conn
+}
+
+// NewNATS connects to the NATS server at url, nats://localhost:4222 for
+// instance.
+func NewNATS(url string) (*NATS, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+	return &NATS{conn: conn}, nil
+}
+
+// Publish sends a message, with the key in its Key header, topic being the
+// subject. NATS having no acknowledgements but with JetStream, it returns
+// once the message is buffered.
+func (n *NATS) Publish(ctx context.Context, topic string, key, value []byte) error {
+	m := nats.NewMsg(topic)
+	m.Header.Set("Key", string(key))
+	m.Data = value
+	return n.conn.PublishMsg(m)
+}
+
+// Close sends the messages buffered and closes the connection.
+func (n *NATS) Close() error {
+	err := n.conn.Flush()
+	n.conn.Close()
+	return err
+}
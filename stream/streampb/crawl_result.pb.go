@@ -0,0 +1,303 @@
+// The message a stream.Sink publishes with the Protobuf format, one per
+// result of a crawl. The fields that don't apply to a result are left out.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: stream/crawl_result.proto
+
+package streampb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CrawlResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Url               string            `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Depth             int32             `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	Status            int32             `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"` // the HTTP status code, 0 when unknown
+	Error             string            `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`    // why the fetch failed
+	Cause             string            `protobuf:"bytes,5,opt,name=cause,proto3" json:"cause,omitempty"`    // what the error is classified under, such as "timed out"
+	FetchedAtUnixNano int64             `protobuf:"varint,6,opt,name=fetched_at_unix_nano,json=fetchedAtUnixNano,proto3" json:"fetched_at_unix_nano,omitempty"`
+	DurationMs        float64           `protobuf:"fixed64,7,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	Headers           map[string]string `protobuf:"bytes,8,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // values joined with ", "
+	ContentHash       string            `protobuf:"bytes,9,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	DuplicateOf       string            `protobuf:"bytes,10,opt,name=duplicate_of,json=duplicateOf,proto3" json:"duplicate_of,omitempty"`
+	Noindex           bool              `protobuf:"varint,11,opt,name=noindex,proto3" json:"noindex,omitempty"`
+	Links             []string          `protobuf:"bytes,12,rep,name=links,proto3" json:"links,omitempty"`
+	NofollowLinks     []string          `protobuf:"bytes,13,rep,name=nofollow_links,json=nofollowLinks,proto3" json:"nofollow_links,omitempty"`
+	Body              []byte            `protobuf:"bytes,14,opt,name=body,proto3" json:"body,omitempty"`
+	External          bool              `protobuf:"varint,15,opt,name=external,proto3" json:"external,omitempty"`
+	Duplicate         bool              `protobuf:"varint,16,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+}
+
+func (x *CrawlResult) Reset() {
+	*x = CrawlResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stream_crawl_result_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CrawlResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CrawlResult) ProtoMessage() {}
+
+func (x *CrawlResult) ProtoReflect() protoreflect.Message {
+	mi := &file_stream_crawl_result_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CrawlResult.ProtoReflect.Descriptor instead.
+func (*CrawlResult) Descriptor() ([]byte, []int) {
+	return file_stream_crawl_result_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CrawlResult) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *CrawlResult) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *CrawlResult) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *CrawlResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *CrawlResult) GetCause() string {
+	if x != nil {
+		return x.Cause
+	}
+	return ""
+}
+
+func (x *CrawlResult) GetFetchedAtUnixNano() int64 {
+	if x != nil {
+		return x.FetchedAtUnixNano
+	}
+	return 0
+}
+
+func (x *CrawlResult) GetDurationMs() float64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *CrawlResult) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *CrawlResult) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+func (x *CrawlResult) GetDuplicateOf() string {
+	if x != nil {
+		return x.DuplicateOf
+	}
+	return ""
+}
+
+func (x *CrawlResult) GetNoindex() bool {
+	if x != nil {
+		return x.Noindex
+	}
+	return false
+}
+
+func (x *CrawlResult) GetLinks() []string {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *CrawlResult) GetNofollowLinks() []string {
+	if x != nil {
+		return x.NofollowLinks
+	}
+	return nil
+}
+
+func (x *CrawlResult) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *CrawlResult) GetExternal() bool {
+	if x != nil {
+		return x.External
+	}
+	return false
+}
+
+func (x *CrawlResult) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+var File_stream_crawl_result_proto protoreflect.FileDescriptor
+
+var file_stream_crawl_result_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2f, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x5f, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08, 0x77, 0x65, 0x62,
+	0x63, 0x72, 0x61, 0x77, 0x6c, 0x22, 0xb0, 0x04, 0x0a, 0x0b, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6c, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x70, 0x74, 0x68,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x64, 0x65, 0x70, 0x74, 0x68, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x63,
+	0x61, 0x75, 0x73, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x61, 0x75, 0x73,
+	0x65, 0x12, 0x2f, 0x0a, 0x14, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x5f,
+	0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x11, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x41, 0x74, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61,
+	0x6e, 0x6f, 0x12, 0x1f, 0x0a, 0x0b, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6d,
+	0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x4d, 0x73, 0x12, 0x3c, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x08,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2e,
+	0x43, 0x72, 0x61, 0x77, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x48, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x73, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61, 0x73,
+	0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x48, 0x61, 0x73, 0x68, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74,
+	0x65, 0x5f, 0x6f, 0x66, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x75, 0x70, 0x6c,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x4f, 0x66, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x6f, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x6e, 0x6f, 0x69, 0x6e, 0x64, 0x65,
+	0x78, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x6e, 0x6f, 0x66, 0x6f, 0x6c,
+	0x6c, 0x6f, 0x77, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x0d, 0x6e, 0x6f, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f,
+	0x64, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x0f,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x65, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x12, 0x1c,
+	0x0a, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x10, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x1a, 0x3a, 0x0a, 0x0c,
+	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x2f, 0x5a, 0x2d, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x61, 0x63, 0x6b, 0x79, 0x75, 0x67, 0x69, 0x74,
+	0x2f, 0x77, 0x65, 0x62, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x2f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x2f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_stream_crawl_result_proto_rawDescOnce sync.Once
+	file_stream_crawl_result_proto_rawDescData = file_stream_crawl_result_proto_rawDesc
+)
+
+func file_stream_crawl_result_proto_rawDescGZIP() []byte {
+	file_stream_crawl_result_proto_rawDescOnce.Do(func() {
+		file_stream_crawl_result_proto_rawDescData = protoimpl.X.CompressGZIP(file_stream_crawl_result_proto_rawDescData)
+	})
+	return file_stream_crawl_result_proto_rawDescData
+}
+
+var file_stream_crawl_result_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_stream_crawl_result_proto_goTypes = []any{
+	(*CrawlResult)(nil), // 0: webcrawl.CrawlResult
+	nil,                 // 1: webcrawl.CrawlResult.HeadersEntry
+}
+var file_stream_crawl_result_proto_depIdxs = []int32{
+	1, // 0: webcrawl.CrawlResult.headers:type_name -> webcrawl.CrawlResult.HeadersEntry
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_stream_crawl_result_proto_init() }
+func file_stream_crawl_result_proto_init() {
+	if File_stream_crawl_result_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_stream_crawl_result_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*CrawlResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_stream_crawl_result_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_stream_crawl_result_proto_goTypes,
+		DependencyIndexes: file_stream_crawl_result_proto_depIdxs,
+		MessageInfos:      file_stream_crawl_result_proto_msgTypes,
+	}.Build()
+	File_stream_crawl_result_proto = out.File
+	file_stream_crawl_result_proto_rawDesc = nil
+	file_stream_crawl_result_proto_goTypes = nil
+	file_stream_crawl_result_proto_depIdxs = nil
+}
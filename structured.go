@@ -0,0 +1,202 @@
+package webcrawl
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// StructuredData is the machine-readable description a page embeds of
+// what it is about: a product, an article, a recipe and so on.
+type StructuredData struct {
+	// JSONLD are the objects of the <script type="application/ld+json">
+	//   of the page, decoded as encoding/json does into an any. A script
+	//   holding an array adds each of its elements, one that is not valid
+	//   JSON is skipped
+	JSONLD []any `json:"json_ld,omitempty"`
+
+	// Microdata are the top-level items of the page, those with an
+	//   itemscope that are not the itemprop of another
+	Microdata []*MicrodataItem `json:"microdata,omitempty"`
+
+	// OpenGraph maps the properties of the og: meta tags to their
+	//   contents, in the order of the page: "og:image" may be there
+	//   several times
+	OpenGraph map[string][]string `json:"opengraph,omitempty"`
+}
+
+// MicrodataItem is an item of microdata, an element with an itemscope.
+type MicrodataItem struct {
+	Type []string `json:"type,omitempty"` // its itemtype URLs
+	ID   string   `json:"id,omitempty"`   // its itemid
+
+	// Properties maps the itemprop names to their values, in the order of
+	//   the page: a string, or a *MicrodataItem when the element of the
+	//   property has an itemscope of its own. The values of URL properties,
+	//   such as the href of an <a>, are resolved
+	Properties map[string][]any `json:"properties"`
+}
+
+// ExtractStructuredData finds the JSON-LD, microdata and OpenGraph of the
+// HTML document doc, found at base. It returns nil when there is none.
+func ExtractStructuredData(base *url.URL, doc *html.Node) *StructuredData {
+	if b := findBase(doc); b != "" {
+		if u, err := base.Parse(b); err == nil {
+			base = u
+		}
+	}
+	var d StructuredData
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			_, scope := attr(n, "itemscope")
+			_, prop := attr(n, "itemprop")
+			switch {
+			case n.DataAtom == atom.Script:
+				if typ, _ := attr(n, "type"); strings.EqualFold(strings.TrimSpace(typ), "application/ld+json") {
+					d.JSONLD = append(d.JSONLD, decodeJSONLD(nodeText(n))...)
+				}
+				return
+			case n.DataAtom == atom.Meta:
+				p, _ := attr(n, "property")
+				if p = strings.ToLower(strings.TrimSpace(p)); strings.HasPrefix(p, "og:") {
+					if d.OpenGraph == nil {
+						d.OpenGraph = make(map[string][]string)
+					}
+					content, _ := attr(n, "content")
+					d.OpenGraph[p] = append(d.OpenGraph[p], strings.TrimSpace(content))
+				}
+			case scope && !prop:
+				d.Microdata = append(d.Microdata, microdataItem(base, n))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if d.JSONLD == nil && d.Microdata == nil && d.OpenGraph == nil {
+		return nil
+	}
+	return &d
+}
+
+// decodeJSONLD returns the objects of the JSON-LD script text
+func decodeJSONLD(text string) []any {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil
+	}
+	if list, ok := v.([]any); ok {
+		return list
+	}
+	return []any{v}
+}
+
+// microdataItem returns the item of the element n, which has an itemscope
+func microdataItem(base *url.URL, n *html.Node) *MicrodataItem {
+	item := &MicrodataItem{Properties: make(map[string][]any)}
+	typ, _ := attr(n, "itemtype")
+	item.Type = strings.Fields(typ)
+	if id, ok := attr(n, "itemid"); ok {
+		item.ID = resolveRef(base, id)
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			_, scope := attr(c, "itemscope")
+			if names, ok := attr(c, "itemprop"); ok {
+				var v any
+				if scope {
+					v = microdataItem(base, c)
+				} else {
+					v = microdataValue(base, c)
+				}
+				for _, name := range strings.Fields(names) {
+					item.Properties[name] = append(item.Properties[name], v)
+				}
+			}
+			// The properties of a nested item are its own
+			if !scope {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return item
+}
+
+// microdataValue returns the value of the property element n, as the
+// microdata spec has it
+func microdataValue(base *url.URL, n *html.Node) string {
+	var name string
+	switch n.DataAtom {
+	case atom.Meta:
+		name = "content"
+	case atom.Audio, atom.Embed, atom.Iframe, atom.Img, atom.Source, atom.Track, atom.Video:
+		v, _ := attr(n, "src")
+		return resolveRef(base, v)
+	case atom.A, atom.Area, atom.Link:
+		v, _ := attr(n, "href")
+		return resolveRef(base, v)
+	case atom.Object:
+		v, _ := attr(n, "data")
+		return resolveRef(base, v)
+	case atom.Data, atom.Meter:
+		name = "value"
+	case atom.Time:
+		name = "datetime"
+	}
+	if v, ok := attr(n, name); name != "" && ok {
+		return strings.TrimSpace(v)
+	}
+	return nodeText(n)
+}
+
+// resolveRef returns ref resolved against base, or as it is when it isn't
+// a URL
+func resolveRef(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if u, err := base.Parse(ref); err == nil {
+		return u.String()
+	}
+	return ref
+}
+
+// nodeText returns the text inside n, trimmed
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(sb.String())
+}
+
+// pageDoc parses the HTML page of res, returning it with the URL it is at
+func pageDoc(res CrawlResult) (*url.URL, *html.Node, error) {
+	base, err := url.Parse(pageURL(res))
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := html.Parse(strings.NewReader(res.Body))
+	return base, doc, err
+}
+
+// htmlResult reports whether the body of res is HTML, as far as its
+// media type tells
+func htmlResult(res CrawlResult) bool {
+	return isHTML(mediaType(res))
+}
@@ -0,0 +1,210 @@
+package webcrawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSummaryTop is how many pages a SummaryReport lists as the
+// largest, slowest and deepest when Top is not set
+const DefaultSummaryTop = 10
+
+// SummaryPage is a page a Summary singles out.
+type SummaryPage struct {
+	URL      string        `json:"url"`
+	Depth    int           `json:"depth"`
+	Size     int64         `json:"size"`     // bytes downloaded
+	Duration time.Duration `json:"duration"` // in nanoseconds in JSON
+}
+
+// Summary is the bird's-eye view of a crawl a SummaryReport takes.
+type Summary struct {
+	Fetched int `json:"fetched"` // every result, pages, assets and checks alike
+
+	// Statuses counts the results by HTTP status, Errors the failed
+	//   ones by cause, the classes of Classify as Metrics names them:
+	//   not_found, timed_out and the like
+	Statuses map[int]int    `json:"statuses"`
+	Errors   map[string]int `json:"errors"`
+
+	// Bytes is how much came over the wire, bodies only
+	Bytes int64 `json:"bytes"`
+
+	// P50, P95 and P99 are the percentiles of the fetch durations, of
+	//   the fetches that reached a server. In nanoseconds in JSON
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+
+	// Largest, Slowest and Deepest are the pages with the biggest
+	//   bodies, the longest fetches and the most links from the seed,
+	//   those first
+	Largest []SummaryPage `json:"largest"`
+	Slowest []SummaryPage `json:"slowest"`
+	Deepest []SummaryPage `json:"deepest"`
+}
+
+// SummaryReport sums up a crawl: how many URLs it fetched, with what
+// statuses and errors, how fast and how much, and which pages stand out.
+//
+// Feed it every result with Add, from Crawler.OnResult for instance. A
+// SummaryReport is safe for concurrent use, its zero value is ready to use.
+type SummaryReport struct {
+	// Top is how many pages Largest, Slowest and Deepest have at most,
+	//   DefaultSummaryTop when zero
+	Top int
+
+	mu        sync.Mutex
+	s         Summary
+	durations []time.Duration
+}
+
+// Add counts a result in.
+func (r *SummaryReport) Add(res CrawlResult) {
+	p := SummaryPage{URL: res.URL, Depth: res.Depth, Size: res.WireSize, Duration: res.Duration}
+	if p.Size == 0 {
+		p.Size = res.BodySize
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.s.Statuses == nil {
+		r.s.Statuses = make(map[int]int)
+		r.s.Errors = make(map[string]int)
+	}
+	r.s.Fetched++
+	if status := statusCode(res); status != 0 {
+		r.s.Statuses[status]++
+	}
+	if res.Err != nil {
+		r.s.Errors[errorClasses[Classify(res.Err)]]++
+	}
+	r.s.Bytes += p.Size
+	if !res.FetchedAt.IsZero() {
+		r.durations = append(r.durations, res.Duration)
+	}
+	if res.Err != nil {
+		return
+	}
+	if p.Size > 0 {
+		r.s.Largest = r.top(r.s.Largest, p, func(a, b SummaryPage) bool { return a.Size > b.Size })
+	}
+	r.s.Slowest = r.top(r.s.Slowest, p, func(a, b SummaryPage) bool { return a.Duration > b.Duration })
+	r.s.Deepest = r.top(r.s.Deepest, p, func(a, b SummaryPage) bool { return a.Depth > b.Depth })
+}
+
+// top inserts p into pages, sorted by first, keeping the Top first ones
+func (r *SummaryReport) top(pages []SummaryPage, p SummaryPage, first func(a, b SummaryPage) bool) []SummaryPage {
+	n := r.Top
+	if n <= 0 {
+		n = DefaultSummaryTop
+	}
+	i := sort.Search(len(pages), func(i int) bool { return first(p, pages[i]) })
+	if i >= n {
+		return pages
+	}
+	pages = append(pages, SummaryPage{})
+	copy(pages[i+1:], pages[i:])
+	pages[i] = p
+	if len(pages) > n {
+		pages = pages[:n]
+	}
+	return pages
+}
+
+// Summary returns the summary of the results added so far.
+func (r *SummaryReport) Summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.s
+	s.Statuses, s.Errors = make(map[int]int, len(r.s.Statuses)), make(map[string]int, len(r.s.Errors))
+	for k, v := range r.s.Statuses {
+		s.Statuses[k] = v
+	}
+	for k, v := range r.s.Errors {
+		s.Errors[k] = v
+	}
+	s.Largest = append([]SummaryPage(nil), r.s.Largest...)
+	s.Slowest = append([]SummaryPage(nil), r.s.Slowest...)
+	s.Deepest = append([]SummaryPage(nil), r.s.Deepest...)
+
+	sort.Slice(r.durations, func(i, j int) bool { return r.durations[i] < r.durations[j] })
+	s.P50, s.P95, s.P99 = percentile(r.durations, 50), percentile(r.durations, 95), percentile(r.durations, 99)
+	return s
+}
+
+// percentile returns the p-th percentile of the sorted durations d, by
+// nearest rank
+func percentile(d []time.Duration, p float64) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(d)))) - 1
+	return d[max(i, 0)]
+}
+
+// WriteText writes the summary to w:
+//
+//	fetched	120
+//	status 200	112
+//	status 404	6
+//	error not_found	6
+//	bytes	5242880
+//	latency p50	85ms
+//	...
+//	largest	https://example.com/big	1048576
+//	slowest	https://example.com/slow	2.1s
+//	deepest	https://example.com/a/b/c	7
+func (r *SummaryReport) WriteText(w io.Writer) error {
+	s := r.Summary()
+	var lines []string
+	lines = append(lines, fmt.Sprintf("fetched\t%d", s.Fetched))
+	statuses := make([]int, 0, len(s.Statuses))
+	for code := range s.Statuses {
+		statuses = append(statuses, code)
+	}
+	sort.Ints(statuses)
+	for _, code := range statuses {
+		lines = append(lines, fmt.Sprintf("status %d\t%d", code, s.Statuses[code]))
+	}
+	classes := make([]string, 0, len(s.Errors))
+	for class := range s.Errors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		lines = append(lines, fmt.Sprintf("error %s\t%d", class, s.Errors[class]))
+	}
+	lines = append(lines, fmt.Sprintf("bytes\t%d", s.Bytes),
+		fmt.Sprintf("latency p50\t%v", s.P50.Round(time.Millisecond)),
+		fmt.Sprintf("latency p95\t%v", s.P95.Round(time.Millisecond)),
+		fmt.Sprintf("latency p99\t%v", s.P99.Round(time.Millisecond)))
+	for _, p := range s.Largest {
+		lines = append(lines, fmt.Sprintf("largest\t%s\t%d", p.URL, p.Size))
+	}
+	for _, p := range s.Slowest {
+		lines = append(lines, fmt.Sprintf("slowest\t%s\t%v", p.URL, p.Duration.Round(time.Millisecond)))
+	}
+	for _, p := range s.Deepest {
+		lines = append(lines, fmt.Sprintf("deepest\t%s\t%d", p.URL, p.Depth))
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the summary, as Summary returns it, to w as a JSON
+// object.
+func (r *SummaryReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Summary())
+}
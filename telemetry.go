@@ -0,0 +1,102 @@
+package webcrawl
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// noTracer is the tracer of a Crawler with no Tracer
+var noTracer = noop.NewTracerProvider().Tracer("")
+
+// span starts the span name at the time at, now when zero, and returns ctx
+// carrying it
+func (r *run) span(ctx context.Context, name string, at time.Time, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := r.Tracer
+	if tracer == nil {
+		tracer = noTracer
+	}
+	opts := []trace.SpanStartOption{trace.WithAttributes(attrs...)}
+	if !at.IsZero() {
+		opts = append(opts, trace.WithTimestamp(at))
+	}
+	if name == "crawl.fetch" {
+		opts = append(opts, trace.WithSpanKind(trace.SpanKindClient))
+	}
+	return tracer.Start(ctx, name, opts...)
+}
+
+// endSpan ends s, failed of err if it is not nil
+func endSpan(s trace.Span, err error) {
+	if err != nil {
+		s.RecordError(err)
+		s.SetStatus(codes.Error, err.Error())
+	}
+	s.End()
+}
+
+// OTLPTracer is a Tracer of the OpenTelemetry SDK exporting its spans to an
+// OpenTelemetry collector, or any backend taking OTLP over HTTP, such as
+// Jaeger. The spans ended are sent in batches every few seconds, Close
+// sends the last ones.
+type OTLPTracer struct {
+	trace.Tracer
+	provider *sdktrace.TracerProvider
+}
+
+// otlpCloseTimeout is how long Close of an OTLPTracer has to send the last
+// spans
+const otlpCloseTimeout = 10 * time.Second
+
+// NewOTLPTracer returns an OTLPTracer sending the spans to endpoint, such
+// as http://localhost:4318/v1/traces, /v1/traces being added to a URL with
+// no path, as those of the service named service, webcrawl when empty.
+func NewOTLPTracer(endpoint, service string) (*OTLPTracer, error) {
+	if service == "" {
+		service = "webcrawl"
+	}
+	u, err := normalizeEndpoint(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: otlp: %w", err)
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u))
+	if err != nil {
+		return nil, fmt.Errorf("webcrawl: otlp: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))))
+	return &OTLPTracer{Tracer: provider.Tracer("github.com/jackyugit/webcrawl"), provider: provider}, nil
+}
+
+func normalizeEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%s: want an http:// or https:// URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// Close sends the spans left, and returns the failure to.
+func (t *OTLPTracer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpCloseTimeout)
+	defer cancel()
+	if err := t.provider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("webcrawl: otlp: %w", err)
+	}
+	return nil
+}
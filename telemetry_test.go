@@ -0,0 +1,150 @@
+package webcrawl_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestTracer(t *testing.T) {
+	var mu sync.Mutex
+	traceparents := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		traceparents[req.URL.Path] = req.Header.Get("Traceparent")
+		mu.Unlock()
+		switch req.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="/missing">Missing</a></body></html>`)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	exp := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	defer provider.Shutdown(context.Background())
+	var results int
+	c := webcrawl.NewCrawler(webcrawl.WithTracer(provider.Tracer("test")), webcrawl.WithDepth(2),
+		webcrawl.WithOnResult(func(webcrawl.CrawlResult) { results++ }))
+	if err := c.Run(context.Background(), srv.URL+"/"); !errors.Is(err, webcrawl.ErrNotFound) {
+		t.Fatalf("Run: %v, want /missing not found", err)
+	}
+	if results != 2 {
+		t.Fatalf("%d results, want / and /missing", results)
+	}
+
+	spans := exp.GetSpans()
+	pages := make(map[trace.SpanID]tracetest.SpanStub)
+	for _, s := range spans {
+		if s.Name == "crawl.page" {
+			pages[s.SpanContext.SpanID()] = s
+		}
+	}
+	if len(pages) != 2 {
+		t.Fatalf("%d crawl.page spans, want one per page", len(pages))
+	}
+	stages := make(map[string]int)
+	fetches := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		if s.Name == "crawl.page" {
+			continue
+		}
+		page, ok := pages[s.Parent.SpanID()]
+		if !ok || s.SpanContext.TraceID() != page.SpanContext.TraceID() {
+			t.Errorf("%s: not a child of a crawl.page span", s.Name)
+			continue
+		}
+		stages[s.Name]++
+		if s.Name == "crawl.fetch" {
+			for _, a := range page.Attributes {
+				if a.Key == "url.full" {
+					fetches[a.Value.AsString()] = s
+				}
+			}
+		}
+	}
+	// /missing is not parsed
+	want := map[string]int{"crawl.dequeue": 2, "crawl.robots": 2, "crawl.fetch": 2, "crawl.parse": 1, "crawl.sink": 2}
+	if fmt.Sprint(stages) != fmt.Sprint(want) {
+		t.Errorf("spans %v, want %v", stages, want)
+	}
+	var failed int
+	for _, s := range spans {
+		if s.Status.Code.String() == "Error" {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("%d spans failed, want the fetch and the page of /missing", failed)
+	}
+	fetch := fetches[srv.URL+"/"]
+	if fetch.SpanKind != trace.SpanKindClient {
+		t.Errorf("crawl.fetch is a %s span, want a client", fetch.SpanKind)
+	}
+
+	sc := fetch.SpanContext
+	if want := fmt.Sprintf("00-%s-%s-01", sc.TraceID(), sc.SpanID()); traceparents["/"] != want {
+		t.Errorf("traceparent of / %q, want the one of its crawl.fetch %q", traceparents["/"], want)
+	}
+	if sc := fetches[srv.URL+"/missing"].SpanContext; sc.TraceID() == fetch.SpanContext.TraceID() {
+		t.Error("/ and /missing in the same trace, want a trace per page")
+	}
+}
+
+func TestTracerUnset(t *testing.T) {
+	var traceparent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparent = append(traceparent, req.Header.Values("Traceparent")...)
+		fmt.Fprint(w, "<p>Hi</p>")
+	}))
+	defer srv.Close()
+	c := webcrawl.NewCrawler(webcrawl.WithoutRobots())
+	if err := c.Run(context.Background(), srv.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+	if len(traceparent) != 0 {
+		t.Errorf("traceparent %q sent without a Tracer", traceparent)
+	}
+}
+
+func TestOTLPTracer(t *testing.T) {
+	var mu sync.Mutex
+	var posts []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts = append(posts, req.Method+" "+req.URL.Path+" "+req.Header.Get("Content-Type"))
+	}))
+	defer collector.Close()
+
+	tracer, err := webcrawl.NewOTLPTracer(collector.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tracer.Start(context.Background(), "crawl.page")
+	span.End()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0] != "POST /v1/traces application/x-protobuf" {
+		t.Errorf("collector got %q, want a POST of the span to /v1/traces", posts)
+	}
+
+	if _, err := webcrawl.NewOTLPTracer("localhost:4318", ""); err == nil {
+		t.Error("NewOTLPTracer of a URL with no scheme: no error")
+	}
+}
@@ -0,0 +1,205 @@
+package testsite
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// SelfTestBudget is how long SelfTest may take
+const SelfTestBudget = 2 * time.Second
+
+// selfTestTolerance is how far the ranks may be off those of the fixture
+const selfTestTolerance = 1e-4
+
+// selfTestFixture is the reference site of SelfTest: its pages with their
+// title and links, and their PageRank, worked out by power iteration
+// apart from LinkGraph.Ranks
+//
+//go:embed selftest.json
+var selfTestFixture []byte
+
+// fixture is selfTestFixture decoded
+type fixture struct {
+	Pages []struct {
+		Path  string   `json:"path"`
+		Title string   `json:"title"`
+		Links []string `json:"links"`
+	} `json:"pages"`
+	Ranks map[string]float64 `json:"ranks"`
+}
+
+// SelfTestCheck is a check of a SelfTestReport.
+type SelfTestCheck struct {
+	Name   string
+	OK     bool
+	Detail string // what was found, or what went wrong
+}
+
+// SelfTestReport is how SelfTest went. It is the error SelfTest returns
+// when a check failed.
+type SelfTestReport struct {
+	Checks []SelfTestCheck
+	Took   time.Duration
+}
+
+// Failed reports whether a check failed.
+func (r *SelfTestReport) Failed() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the report, a line per check and the verdict:
+//
+//	PASS  pages     20 pages crawled
+//	FAIL  titles    /about titled "", want "About us"
+//	...
+//	FAIL  in 41ms
+func (r *SelfTestReport) String() string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		verdict := "PASS"
+		if !c.OK {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s  %-9s %s\n", verdict, c.Name, c.Detail)
+	}
+	verdict := "PASS"
+	if r.Failed() {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s  in %v\n", verdict, r.Took.Round(time.Millisecond))
+	return b.String()
+}
+
+// Error implements error, as the report.
+func (r *SelfTestReport) Error() string {
+	return "webcrawl: self-test failed:\n" + r.String()
+}
+
+// SelfTest crawls a reference site of 20 pages, embedded, over HTTP on a
+// local server with an HTTPFetcher, and checks that the crawl found its
+// pages, their links and titles, no broken link, and the PageRank of the
+// pages worked out beforehand, within SelfTestBudget. It returns a
+// *SelfTestReport when a check fails, see RunSelfTest for the report of
+// a test that passed.
+func SelfTest(ctx context.Context) error {
+	if r := RunSelfTest(ctx); r.Failed() {
+		return r
+	}
+	return nil
+}
+
+// RunSelfTest is SelfTest returning its report, whether it passed or not.
+func RunSelfTest(ctx context.Context) *SelfTestReport {
+	start := time.Now()
+	r := &SelfTestReport{}
+	check := func(name string, ok bool, format string, args ...any) {
+		r.Checks = append(r.Checks, SelfTestCheck{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+	}
+	var fx fixture
+	if err := json.Unmarshal(selfTestFixture, &fx); err != nil {
+		check("fixture", false, "%v", err)
+		r.Took = time.Since(start)
+		return r
+	}
+
+	site := New("http://selftest.invalid")
+	var edges int
+	for _, p := range fx.Pages {
+		site.Add(p.Path, &Page{Title: p.Title, Links: p.Links})
+		edges += len(p.Links)
+	}
+	srv := httptest.NewServer(site.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, SelfTestBudget)
+	defer cancel()
+	var mu sync.Mutex
+	var pages int
+	graph := &webcrawl.LinkGraph{}
+	broken := &webcrawl.BrokenLinkReport{}
+	seo := &webcrawl.SEOReport{}
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(webcrawl.NewHTTPFetcher(time.Second)),
+		webcrawl.WithoutRobots(), webcrawl.WithDepth(webcrawl.UnlimitedDepth),
+		webcrawl.WithOnResult(func(res webcrawl.CrawlResult) {
+			graph.Add(res)
+			broken.Add(res)
+			seo.Add(res)
+			if res.Err == nil {
+				mu.Lock()
+				pages++
+				mu.Unlock()
+			}
+		}))
+	err := c.Run(ctx, srv.URL+"/")
+	check("crawl", err == nil, "%s", errText(err))
+
+	check("pages", pages == len(fx.Pages), "%d pages crawled, want %d", pages, len(fx.Pages))
+
+	var found int
+	for _, u := range graph.Nodes() {
+		found += len(graph.OutEdges(u))
+	}
+	check("edges", found == edges, "%d links found, want %d", found, edges)
+
+	var brokenLinks []string
+	for _, b := range broken.Broken() {
+		brokenLinks = append(brokenLinks, strings.TrimPrefix(b.URL, srv.URL)+" ("+b.Reason()+")")
+	}
+	check("broken", len(brokenLinks) == 0, "%s", strings.Join(append(brokenLinks, fmt.Sprintf("%d broken links", len(brokenLinks))), ", "))
+
+	titles := make(map[string]string)
+	for _, p := range seo.Pages() {
+		titles[strings.TrimPrefix(p.URL, srv.URL)] = p.Title
+	}
+	var wrong []string
+	for _, p := range fx.Pages {
+		if got := titles[p.Path]; got != p.Title {
+			wrong = append(wrong, fmt.Sprintf("%s titled %q, want %q", p.Path, got, p.Title))
+		}
+	}
+	check("titles", len(wrong) == 0, "%s", strings.Join(append(wrong, fmt.Sprintf("%d of %d right", len(fx.Pages)-len(wrong), len(fx.Pages))), ", "))
+
+	ranks := make(map[string]float64)
+	for _, s := range graph.Ranks() {
+		ranks[strings.TrimPrefix(s.URL, srv.URL)] = s.Rank
+	}
+	var off []string
+	worst := 0.0
+	for path, want := range fx.Ranks {
+		got, ok := ranks[path]
+		d := math.Abs(got - want)
+		worst = max(worst, d)
+		if !ok || d > selfTestTolerance {
+			off = append(off, fmt.Sprintf("%s ranked %.6f, want %.6f", path, got, want))
+		}
+	}
+	sort.Strings(off)
+	check("pagerank", len(off) == 0 && len(ranks) == len(fx.Ranks), "%s", strings.Join(append(off, fmt.Sprintf("%d ranks, %.1e off at most", len(ranks), worst)), ", "))
+
+	r.Took = time.Since(start)
+	check("time", r.Took < SelfTestBudget, "%v, want under %v", r.Took.Round(time.Millisecond), SelfTestBudget)
+	return r
+}
+
+// errText returns what err says, "ok" for nil
+func errText(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
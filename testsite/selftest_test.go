@@ -0,0 +1,33 @@
+package testsite
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r := RunSelfTest(context.Background())
+	for _, name := range []string{"crawl", "pages", "edges", "broken", "titles", "pagerank", "time"} {
+		if !strings.Contains(r.String(), "PASS  "+name) {
+			t.Errorf("report without a passed %s check:\n%s", name, r)
+		}
+	}
+}
+
+func TestSelfTestFails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := SelfTest(ctx)
+	var r *SelfTestReport
+	if !errors.As(err, &r) {
+		t.Fatalf("self-test of a cancelled context: %v, want a *SelfTestReport", err)
+	}
+	if !strings.Contains(err.Error(), "FAIL  pages") {
+		t.Errorf("report of a cancelled crawl:\n%s", err)
+	}
+}
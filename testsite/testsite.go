@@ -0,0 +1,419 @@
+// Package testsite builds synthetic web sites in memory, for the tests and
+// benchmarks of crawl logic: a Site is a Fetcher, the Crawler crawls it
+// without touching the network, and an http.Handler for a real server.
+// Pages are added by hand, or Generate makes a site of a given shape:
+//
+//	site := testsite.Generate("http://site.test", testsite.Options{
+//		Pages: 1000, Branching: 5, CrossLinks: 2, DeadLinks: 0.05,
+//		SlowPages: 0.01, SlowDelay: 100 * time.Millisecond, Redirects: 0.05,
+//	})
+//	c := webcrawl.NewCrawler(webcrawl.WithFetcher(site), webcrawl.WithDepth(4))
+//	err := c.Crawl(site.URL("/"))
+//
+// The site then tells what the crawl should have fetched, Reachable, and
+// what it did, Fetches.
+package testsite
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// maxRedirects is how many redirects a fetch follows, as the HTTPFetcher
+const maxRedirects = webcrawl.DefaultMaxRedirects
+
+// Page is a page of a Site.
+type Page struct {
+	// Links are the links of the page, paths of the site or absolute URLs
+	Links []string
+
+	// Status is the HTTP status it answers with, zero for 200. With a
+	//   3xx one, it redirects to Location
+	Status   int
+	Location string
+
+	// Delay is how long it takes to answer
+	Delay time.Duration
+
+	// Body is served as it is when set, of ContentType, text/html when
+	//   empty. Otherwise the body is HTML with the links in it, of the
+	//   <title> Title, its path when empty
+	Body        string
+	ContentType string
+	Title       string
+}
+
+// Site is a web site in memory, its pages keyed by path. It is safe for
+// concurrent use, the pages are not to be changed once it is crawled.
+type Site struct {
+	// Base is the scheme and host of the URLs of the site, such as
+	//   http://site.test
+	Base string
+
+	mu      sync.Mutex
+	pages   map[string]*Page
+	fetches map[string]int
+}
+
+// New returns a Site with no page at base.
+func New(base string) *Site {
+	return &Site{Base: strings.TrimSuffix(base, "/"), pages: make(map[string]*Page), fetches: make(map[string]int)}
+}
+
+// Add adds the page p at path, in place of any there, and returns it.
+func (s *Site) Add(path string, p *Page) *Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[path] = p
+	return p
+}
+
+// Page returns the page at path, nil when there is none.
+func (s *Site) Page(path string) *Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pages[path]
+}
+
+// Paths returns the paths of the pages, sorted.
+func (s *Site) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := make([]string, 0, len(s.pages))
+	for p := range s.pages {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// URL returns the URL of path on the site.
+func (s *Site) URL(path string) string {
+	return s.Base + path
+}
+
+// resolve returns the absolute URL of the link l of the site
+func (s *Site) resolve(l string) string {
+	if strings.HasPrefix(l, "/") {
+		return s.Base + l
+	}
+	return l
+}
+
+// path returns the path of rawURL on the site, false when it is not of it
+func (s *Site) path(rawURL string) (string, bool) {
+	rest, ok := strings.CutPrefix(rawURL, s.Base)
+	if !ok || rest != "" && !strings.HasPrefix(rest, "/") {
+		return "", false
+	}
+	if rest == "" {
+		rest = "/"
+	}
+	return rest, true
+}
+
+// answer is what the page at path answers, its body included
+func (s *Site) answer(path string) (p Page, body string, found bool) {
+	s.mu.Lock()
+	s.fetches[path]++
+	pg := s.pages[path]
+	s.mu.Unlock()
+	if pg == nil {
+		return Page{Status: http.StatusNotFound}, "<html><body>not found</body></html>", false
+	}
+	p = *pg
+	if p.Status == 0 {
+		p.Status = http.StatusOK
+	}
+	if p.ContentType == "" {
+		p.ContentType = "text/html; charset=utf-8"
+	}
+	body = p.Body
+	if body == "" {
+		var b strings.Builder
+		title := p.Title
+		if title == "" {
+			title = path
+		}
+		fmt.Fprintf(&b, "<html><head><title>%s</title></head><body>\n", html.EscapeString(title))
+		for _, l := range p.Links {
+			fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(l), html.EscapeString(l))
+		}
+		b.WriteString("</body></html>\n")
+		body = b.String()
+	}
+	return p, body, true
+}
+
+// wait waits for d, or ctx to be done
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Fetch implements webcrawl.Fetcher.
+func (s *Site) Fetch(ctx context.Context, url string) (string, []string, error) {
+	resp, err := s.FetchResponse(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Body, resp.Links, nil
+}
+
+// FetchResponse implements webcrawl.ResponseFetcher, following redirects
+// as the HTTPFetcher does. Any status but 2xx is a webcrawl.StatusError,
+// as are the pages missing, 404 Not Found.
+func (s *Site) FetchResponse(ctx context.Context, rawURL string) (*webcrawl.Response, error) {
+	out := &webcrawl.Response{URL: rawURL, ContentLength: -1}
+	for hops := 0; ; hops++ {
+		path, ok := s.path(out.URL)
+		if !ok {
+			return out, fmt.Errorf("fetch %s: not on %s", out.URL, s.Base)
+		}
+		p, body, _ := s.answer(path)
+		if err := wait(ctx, p.Delay); err != nil {
+			return out, fmt.Errorf("fetch %s: %w", out.URL, err)
+		}
+		out.StatusCode = p.Status
+		out.Header = http.Header{"Content-Type": {p.ContentType}}
+		if p.Status >= 300 && p.Status < 400 && p.Location != "" {
+			if hops >= maxRedirects {
+				return out, &webcrawl.RedirectError{URL: rawURL, Redirects: out.Redirects, Err: webcrawl.ErrTooManyRedirects}
+			}
+			to := s.resolve(p.Location)
+			out.Redirects = append(out.Redirects, webcrawl.Redirect{From: out.URL, To: to, StatusCode: p.Status})
+			out.URL = to
+			continue
+		}
+		if p.Status < 200 || p.Status > 299 {
+			return out, &webcrawl.StatusError{URL: out.URL, StatusCode: p.Status, Status: fmt.Sprintf("%d %s", p.Status, http.StatusText(p.Status))}
+		}
+		out.Body = body
+		out.BodySize = int64(len(body))
+		out.ContentLength = int64(len(body))
+		if strings.HasPrefix(p.ContentType, "text/html") {
+			for _, l := range p.Links {
+				out.Links = append(out.Links, s.resolve(l))
+			}
+		}
+		return out, nil
+	}
+}
+
+// Handler returns the site as an http.Handler, whatever the host of the
+// requests, its links to the site being paths.
+func (s *Site) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, body, _ := s.answer(req.URL.Path)
+		if wait(req.Context(), p.Delay) != nil {
+			return
+		}
+		if p.Status >= 300 && p.Status < 400 && p.Location != "" {
+			loc := p.Location
+			if rest, ok := strings.CutPrefix(loc, s.Base); ok {
+				// To this server rather than Base
+				loc = rest
+			}
+			http.Redirect(w, req, loc, p.Status)
+			return
+		}
+		w.Header().Set("Content-Type", p.ContentType)
+		w.WriteHeader(p.Status)
+		w.Write([]byte(body))
+	})
+}
+
+// Fetches returns how many times the URL was fetched, over FetchResponse
+// or Handler, redirects followed counting for their targets too.
+func (s *Site) Fetches(rawURL string) int {
+	path, ok := s.path(rawURL)
+	if !ok {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches[path]
+}
+
+// TotalFetches returns how many fetches the site answered, and Twice the
+// URLs fetched more than once, sorted.
+func (s *Site) TotalFetches() (total int, twice []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, n := range s.fetches {
+		total += n
+		if n > 1 {
+			twice = append(twice, s.Base+path)
+		}
+	}
+	sort.Strings(twice)
+	return total, twice
+}
+
+// Reset forgets the fetches, for the site to be crawled again.
+func (s *Site) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches = make(map[string]int)
+}
+
+// Reachable returns the URLs of the site a crawl from / with a MaxDepth of
+// maxDepth fetches, with the shortest depth of each: the pages, the dead
+// links and the redirects, the targets of which are at the same depth.
+// The links leaving the site are left out, a negative maxDepth is no
+// limit.
+func (s *Site) Reachable(maxDepth int) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	depths := map[string]int{"/": 0}
+	queue := []string{"/"}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		d := depths[path]
+		p := s.pages[path]
+		if p == nil {
+			continue
+		}
+		next, depth := p.Links, d+1
+		if p.Status >= 300 && p.Status < 400 && p.Location != "" {
+			next, depth = []string{p.Location}, d
+		} else if p.Status != 0 && (p.Status < 200 || p.Status > 299) {
+			continue
+		}
+		if maxDepth >= 0 && depth >= maxDepth {
+			continue
+		}
+		for _, l := range next {
+			lp, ok := s.path(s.resolve(l))
+			if !ok {
+				continue
+			}
+			if u, err := url.Parse(lp); err == nil {
+				lp = u.Path
+			}
+			if old, seen := depths[lp]; seen && old <= depth {
+				continue
+			}
+			depths[lp] = depth
+			queue = append(queue, lp)
+		}
+	}
+	urls := make(map[string]int, len(depths))
+	for path, d := range depths {
+		urls[s.Base+path] = d
+	}
+	return urls
+}
+
+// DefaultBranching is the Branching of Options when zero.
+const DefaultBranching = 4
+
+// Options are the shape of the site Generate makes. The shares are between
+// 0 and 1, of the pages.
+type Options struct {
+	// Pages is how many pages the site has, the home page at / included,
+	//   the others being at /p/1, /p/2 and so on
+	Pages int
+
+	// Branching is how many new pages a page links to, the site being a
+	//   tree of them breadth first, DefaultBranching when zero
+	Branching int
+
+	// BackLinks has every page link back to its parent and to the home
+	//   page, CrossLinks to that many pages at random more, which makes
+	//   for cycles and for pages reached by several paths
+	BackLinks  bool
+	CrossLinks int
+
+	// DeadLinks is the share of the pages with a link to a page that
+	//   doesn't exist, at /dead/n
+	DeadLinks float64
+
+	// SlowPages is the share of the pages answering after SlowDelay
+	SlowPages float64
+	SlowDelay time.Duration
+
+	// Redirects is the share of the pages linked to through a redirect,
+	//   from /r/n
+	Redirects float64
+
+	// Errors is the share of the pages answering 500 Internal Server
+	//   Error
+	Errors float64
+
+	// Seed seeds the random choices: the same Options make the same site
+	Seed uint64
+}
+
+// Generate returns a site of the shape of o at base.
+func Generate(base string, o Options) *Site {
+	s := New(base)
+	b := o.Branching
+	if b <= 0 {
+		b = DefaultBranching
+	}
+	rng := rand.New(rand.NewPCG(o.Seed, 0x9e3779b97f4a7c15))
+	chance := func(p float64) bool { return p > 0 && rng.Float64() < p }
+	pagePath := func(i int) string {
+		if i == 0 {
+			return "/"
+		}
+		return fmt.Sprintf("/p/%d", i)
+	}
+	n := max(1, o.Pages)
+	// Linked through a redirect, decided up front for every link to a
+	//   page to go through it
+	link := make([]string, n)
+	for i := range n {
+		link[i] = pagePath(i)
+		if i > 0 && chance(o.Redirects) {
+			link[i] = fmt.Sprintf("/r/%d", i)
+			s.pages[link[i]] = &Page{Status: http.StatusMovedPermanently, Location: pagePath(i)}
+		}
+	}
+	dead := 0
+	for i := range n {
+		p := &Page{}
+		for c := i*b + 1; c <= i*b+b && c < n; c++ {
+			p.Links = append(p.Links, link[c])
+		}
+		if o.BackLinks && i > 0 {
+			p.Links = append(p.Links, link[(i-1)/b], link[0])
+		}
+		for range o.CrossLinks {
+			p.Links = append(p.Links, link[rng.IntN(n)])
+		}
+		if chance(o.DeadLinks) {
+			dead++
+			p.Links = append(p.Links, fmt.Sprintf("/dead/%d", dead))
+		}
+		if i > 0 && chance(o.SlowPages) {
+			p.Delay = o.SlowDelay
+		}
+		if i > 0 && chance(o.Errors) {
+			p.Status = http.StatusInternalServerError
+		}
+		s.pages[pagePath(i)] = p
+	}
+	return s
+}
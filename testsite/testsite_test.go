@@ -0,0 +1,146 @@
+package testsite
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	o := Options{Pages: 200, BackLinks: true, CrossLinks: 3, DeadLinks: 0.1, Redirects: 0.1, Errors: 0.05, Seed: 42}
+	a, b := Generate("http://site.test", o), Generate("http://site.test", o)
+	if !reflect.DeepEqual(a.Paths(), b.Paths()) {
+		t.Fatal("the same Options made sites of other paths")
+	}
+	for _, p := range a.Paths() {
+		if !reflect.DeepEqual(a.Page(p), b.Page(p)) {
+			t.Fatalf("the same Options made two pages at %s: %+v and %+v", p, a.Page(p), b.Page(p))
+		}
+	}
+	o.Seed++
+	c := Generate("http://site.test", o)
+	same := true
+	for _, p := range a.Paths() {
+		if !reflect.DeepEqual(a.Page(p), c.Page(p)) {
+			same = false
+			break
+		}
+	}
+	if same {
+		t.Error("another Seed made the same site")
+	}
+}
+
+func TestGenerateShape(t *testing.T) {
+	s := Generate("http://site.test", Options{Pages: 21, Branching: 4})
+	if n := len(s.Paths()); n != 21 {
+		t.Fatalf("%d pages, want 21", n)
+	}
+	if got, want := s.Page("/").Links, []string{"/p/1", "/p/2", "/p/3", "/p/4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("links of / = %v, want %v", got, want)
+	}
+	if got := s.Page("/p/5").Links; len(got) != 0 {
+		t.Errorf("links of /p/5, a leaf, = %v, want none", got)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	s := New("http://site.test")
+	s.Add("/", &Page{Links: []string{"/a", "/b", "http://other.test/"}})
+	s.Add("/a", &Page{Links: []string{"/c", "/missing"}})
+	s.Add("/b", &Page{Links: []string{"/r"}})
+	s.Add("/r", &Page{Status: http.StatusFound, Location: "/c"})
+	s.Add("/c", &Page{Links: []string{"/d"}})
+	s.Add("/d", &Page{})
+	s.Add("/err", &Page{Status: http.StatusInternalServerError, Links: []string{"/d"}})
+
+	want := map[string]int{
+		"http://site.test/":        0,
+		"http://site.test/a":       1,
+		"http://site.test/b":       1,
+		"http://site.test/c":       2,
+		"http://site.test/missing": 2,
+		"http://site.test/r":       2,
+		"http://site.test/d":       3,
+	}
+	if got := s.Reachable(-1); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reachable(-1) = %v, want %v", got, want)
+	}
+	// Up to 3 levels, the seed being the first, /d is too deep
+	delete(want, "http://site.test/d")
+	if got := s.Reachable(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reachable(3) = %v, want %v", got, want)
+	}
+	if got := s.Reachable(1); !reflect.DeepEqual(got, map[string]int{"http://site.test/": 0}) {
+		t.Errorf("Reachable(1) = %v, want the home page alone", got)
+	}
+}
+
+func TestFetches(t *testing.T) {
+	s := New("http://site.test")
+	s.Add("/", &Page{Links: []string{"/a"}})
+	s.Add("/r", &Page{Status: http.StatusMovedPermanently, Location: "/a"})
+	s.Add("/a", &Page{})
+	ctx := context.Background()
+
+	resp, err := s.FetchResponse(ctx, s.URL("/r"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.URL != s.URL("/a") || len(resp.Redirects) != 1 {
+		t.Errorf("fetch of /r ended at %s after %d redirects, want /a after 1", resp.URL, len(resp.Redirects))
+	}
+	_, links, err := s.Fetch(ctx, s.URL("/"))
+	if err != nil || !reflect.DeepEqual(links, []string{s.URL("/a")}) {
+		t.Errorf("Fetch(/) = %v, %v, want the absolute link to /a", links, err)
+	}
+	var se *webcrawl.StatusError
+	if _, err := s.FetchResponse(ctx, s.URL("/missing")); !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("fetch of a missing page: %v, want a 404 StatusError", err)
+	}
+
+	total, twice := s.TotalFetches()
+	if total != 4 {
+		t.Errorf("TotalFetches = %d, want 4", total)
+	}
+	if !reflect.DeepEqual(twice, []string(nil)) {
+		t.Errorf("fetched twice: %v, want none", twice)
+	}
+	s.Fetch(ctx, s.URL("/a"))
+	if _, twice := s.TotalFetches(); !reflect.DeepEqual(twice, []string{s.URL("/a")}) {
+		t.Errorf("fetched twice: %v, want /a", twice)
+	}
+	if n := s.Fetches(s.URL("/a")); n != 2 {
+		t.Errorf("Fetches(/a) = %d, want 2", n)
+	}
+	s.Reset()
+	if total, _ := s.TotalFetches(); total != 0 {
+		t.Errorf("TotalFetches after Reset = %d", total)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := Generate("http://site.test", Options{Pages: 30, Redirects: 0.3, Seed: 1})
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	c := webcrawl.NewCrawler(webcrawl.WithFetcher(webcrawl.NewHTTPFetcher(0)), webcrawl.WithoutRobots(), webcrawl.WithDepth(webcrawl.UnlimitedDepth))
+	var pages int
+	c.OnResult = func(res webcrawl.CrawlResult) {
+		if res.Err == nil {
+			pages++
+		}
+	}
+	c.Sequential = true
+	if err := c.Crawl(srv.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	if pages != 30 {
+		t.Errorf("crawled %d pages over HTTP, want 30", pages)
+	}
+}
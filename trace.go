@@ -0,0 +1,106 @@
+package webcrawl
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings break down how long a fetch over HTTP took. DNS, Connect and
+// TLS are zero when the request went over a connection opened before.
+// They are those of the last request when redirects were followed, Total
+// covering them all.
+type Timings struct {
+	DNS     time.Duration // resolving the host
+	Connect time.Duration // opening the TCP connection
+	TLS     time.Duration // the TLS handshake
+	TTFB    time.Duration // from the request sent to the first byte of the response
+	Total   time.Duration // the whole fetch, the body included
+}
+
+// tracer records the Timings of a fetch and the address of the server,
+// its hooks are called from several goroutines of net/http
+type tracer struct {
+	start time.Time
+
+	mu                             sync.Mutex
+	dns, connect, handshake, wrote time.Time
+	timings                        Timings
+	remote                         string
+}
+
+// traced returns ctx with a tracer attached to the requests made with it
+func traced(ctx context.Context) (context.Context, *tracer) {
+	t := &tracer{start: time.Now()}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// A new request, after a redirect
+			t.timings = Timings{}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.dns) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.since(&t.dns, &t.timings.DNS)
+		},
+		ConnectStart: func(string, string) { t.set(&t.connect) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.since(&t.connect, &t.timings.Connect)
+			}
+		},
+		TLSHandshakeStart: func() { t.set(&t.handshake) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.since(&t.handshake, &t.timings.TLS)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.remote = info.Conn.RemoteAddr().String()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wrote) },
+		GotFirstResponseByte: func() { t.since(&t.wrote, &t.timings.TTFB) },
+	}), t
+}
+
+// set records the time an event started at in *at
+func (t *tracer) set(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+// since records the time since *start, if it is set, in *d
+func (t *tracer) since(start *time.Time, d *time.Duration) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !start.IsZero() {
+		*d = now.Sub(*start)
+	}
+}
+
+// record sets the Timings and the remote address of out, the fetch being
+// over
+func (t *tracer) record(out *Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out.Timings = t.timings
+	out.Timings.Total = time.Since(t.start)
+	out.RemoteAddr = t.remote
+}
+
+// responseMeta sets what out tells of the response resp, but its body
+func responseMeta(out *Response, resp *http.Response) {
+	out.URL = resp.Request.URL.String()
+	out.StatusCode = resp.StatusCode
+	out.Header = resp.Header
+	out.ContentLength = resp.ContentLength
+	out.Protocol = resp.Proto
+	if resp.TLS != nil {
+		out.TLSVersion = tls.VersionName(resp.TLS.Version)
+	}
+}
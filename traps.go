@@ -0,0 +1,152 @@
+package webcrawl
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The rules of TrapRules, as a Trap tells which one caught its URLs
+const (
+	TrapPathDepth       = "path depth"       // too many segments in the path
+	TrapRepeatedSegment = "repeated segment" // a segment repeated too many times in the path
+	TrapQueryVariants   = "query variants"   // too many queries for the same path
+	TrapDirectoryURLs   = "directory urls"   // too many URLs in the same directory
+)
+
+// TrapRules keep a crawl out of the URL spaces that never end: calendars
+// with a link to the next month, faceted navigation combining filters ad
+// infinitum, session IDs in the path, relative links piling up segments.
+// The URLs caught are dropped, as the too deep ones are, and the patterns
+// they fit are reported by Traps. A zero field turns its rule off.
+//
+// The Crawler hands every new URL to Trapped, the seed excepted. TrapRules
+// are safe for concurrent use.
+type TrapRules struct {
+	// MaxPathDepth is how many segments a path can have, /a/b/c having 3
+	MaxPathDepth int
+
+	// MaxRepeatedSegments is how many times a segment can be in a path,
+	//   /a/b/a/b/a having a 3 times
+	MaxRepeatedSegments int
+
+	// MaxQueryVariants is how many URLs with a query a path can have, as
+	//   /search?color=red&size=m and /search?size=m for /search
+	MaxQueryVariants int
+
+	// MaxDirectoryURLs is how many URLs a directory can have, its
+	//   subdirectories apart: /events/1 and /events/2?p=1 for /events/
+	MaxDirectoryURLs int
+
+	mu      sync.Mutex
+	queries map[string]int   // host and path => URLs with a query let through
+	dirs    map[string]int   // host and directory => URLs let through
+	traps   map[string]*Trap // rule and pattern => its trap
+}
+
+// Trap is a pattern of URLs TrapRules caught.
+type Trap struct {
+	Rule string // TrapPathDepth and the like
+
+	// Pattern is the host followed by a glob of the paths caught, as
+	//   example.com/**/calendar/** for TrapRepeatedSegment,
+	//   example.com/search?* for TrapQueryVariants
+	Pattern string
+
+	URLs    int    // how many were dropped
+	Example string // the first of them
+}
+
+// Trapped reports which rule catches u, "" when none does, counting u
+// against the limits of the query variants and directory URLs when it
+// goes through. It is to be called once per URL.
+func (t *TrapRules) Trapped(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if segments[0] == "" {
+		segments = nil
+	}
+	dir := p[:strings.LastIndex(p, "/")+1]
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.MaxPathDepth > 0 && len(segments) > t.MaxPathDepth {
+		return t.caught(u, TrapPathDepth, u.Host+"/"+strings.Join(segments[:t.MaxPathDepth], "/")+"/**")
+	}
+	if t.MaxRepeatedSegments > 0 {
+		counts := make(map[string]int, len(segments))
+		for _, s := range segments {
+			if counts[s]++; counts[s] > t.MaxRepeatedSegments {
+				return t.caught(u, TrapRepeatedSegment, u.Host+"/**/"+s+"/**")
+			}
+		}
+	}
+	if t.queries == nil {
+		t.queries = make(map[string]int)
+		t.dirs = make(map[string]int)
+	}
+	if t.MaxQueryVariants > 0 && u.RawQuery != "" && t.queries[u.Host+p] >= t.MaxQueryVariants {
+		return t.caught(u, TrapQueryVariants, u.Host+p+"?*")
+	}
+	if t.MaxDirectoryURLs > 0 && t.dirs[u.Host+dir] >= t.MaxDirectoryURLs {
+		return t.caught(u, TrapDirectoryURLs, u.Host+dir+"*")
+	}
+	if u.RawQuery != "" {
+		t.queries[u.Host+p]++
+	}
+	t.dirs[u.Host+dir]++
+	return ""
+}
+
+// caught records that rule caught u, which fits pattern, and returns rule.
+// The caller holds t.mu
+func (t *TrapRules) caught(u *url.URL, rule, pattern string) string {
+	if t.traps == nil {
+		t.traps = make(map[string]*Trap)
+	}
+	k := rule + " " + pattern
+	trap := t.traps[k]
+	if trap == nil {
+		trap = &Trap{Rule: rule, Pattern: pattern, Example: u.String()}
+		t.traps[k] = trap
+	}
+	trap.URLs++
+	return rule
+}
+
+// Traps returns the patterns caught so far, those with the most URLs
+// first.
+func (t *TrapRules) Traps() []Trap {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	traps := make([]Trap, 0, len(t.traps))
+	for _, trap := range t.traps {
+		traps = append(traps, *trap)
+	}
+	sort.Slice(traps, func(i, j int) bool {
+		if traps[i].URLs != traps[j].URLs {
+			return traps[i].URLs > traps[j].URLs
+		}
+		return traps[i].Pattern < traps[j].Pattern
+	})
+	return traps
+}
+
+// WriteText writes the patterns caught to w, one per line with the number
+// of URLs dropped, the rule and the first URL:
+//
+//	412	query variants	example.com/search?*	https://example.com/search?color=red&page=9
+func (t *TrapRules) WriteText(w io.Writer) error {
+	for _, trap := range t.Traps() {
+		if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", trap.URLs, trap.Rule, trap.Pattern, trap.Example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
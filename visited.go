@@ -0,0 +1,164 @@
+package webcrawl
+
+import (
+	"math"
+	"sync"
+)
+
+// VisitedSet remembers which URLs the crawler has already come across, so
+// that each is crawled once. Implementations backed by Redis, Bolt, a bloom
+// filter and the like can be plugged into Crawler.Visited.
+//
+// The Crawler only calls it from its dispatcher goroutine, but an
+// implementation shared between crawlers must be safe for concurrent use.
+// The ShardedVisitedSet a Crawler starts with when Visited is nil is the
+// exception: its workers mark the links they find in it themselves, see
+// ShardedVisitedSet.
+type VisitedSet interface {
+	// Seen reports whether url was marked as seen
+	Seen(url string) bool
+	// MarkSeen records url as seen
+	MarkSeen(url string)
+}
+
+// MemoryVisitedSet is a VisitedSet kept in a map. It is safe for
+// concurrent use.
+type MemoryVisitedSet struct {
+	mu   sync.RWMutex
+	seen map[string]bool
+}
+
+// NewMemoryVisitedSet returns an empty MemoryVisitedSet.
+func NewMemoryVisitedSet() *MemoryVisitedSet {
+	return &MemoryVisitedSet{seen: make(map[string]bool)}
+}
+
+// Seen implements VisitedSet.
+func (s *MemoryVisitedSet) Seen(url string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seen[url]
+}
+
+// MarkSeen implements VisitedSet.
+func (s *MemoryVisitedSet) MarkSeen(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[url] = true
+}
+
+// Len returns how many URLs were marked as seen.
+func (s *MemoryVisitedSet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.seen)
+}
+
+// visitedShards is how many shards a ShardedVisitedSet has, a power of 2
+const visitedShards = 64
+
+// noDepth is the depth a ShardedVisitedSet keeps for the URLs not crawled
+// by depth, or marked by MarkSeen
+const noDepth = math.MaxInt32
+
+// ShardedVisitedSet is a VisitedSet split into shards by a hash of the
+// URLs, each with a lock of its own, for many goroutines to mark URLs at
+// once with little contention. It is the one a Crawler starts with when
+// Visited is nil, and then its workers screen the links they find, the
+// normalizing, scope, Filter and visited set included, rather than leave
+// it all to the single dispatcher goroutine, which was the bottleneck of a
+// crawl with many workers, see the benchmarks in the package doc.
+//
+// A ShardedVisitedSet is safe for concurrent use, its zero value is ready
+// to use.
+type ShardedVisitedSet struct {
+	shards [visitedShards]visitedShard
+}
+
+// visitedShard is a shard of a ShardedVisitedSet, padded to a cache line
+// of its own lest the locks of two shards contend all the same
+type visitedShard struct {
+	mu   sync.Mutex
+	seen map[string]int32 // URL => the least depth it was found at, noDepth when none
+	_    [48]byte
+}
+
+// NewShardedVisitedSet returns an empty ShardedVisitedSet.
+func NewShardedVisitedSet() *ShardedVisitedSet {
+	return &ShardedVisitedSet{}
+}
+
+// shard returns the shard of url, by its FNV-1a hash
+func (s *ShardedVisitedSet) shard(url string) *visitedShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(url); i++ {
+		h ^= uint32(url[i])
+		h *= 16777619
+	}
+	return &s.shards[h&(visitedShards-1)]
+}
+
+// Seen implements VisitedSet.
+func (s *ShardedVisitedSet) Seen(url string) bool {
+	sh := s.shard(url)
+	sh.mu.Lock()
+	_, ok := sh.seen[url]
+	sh.mu.Unlock()
+	return ok
+}
+
+// MarkSeen implements VisitedSet.
+func (s *ShardedVisitedSet) MarkSeen(url string) {
+	s.Add(url)
+}
+
+// Add marks url as seen, and reports whether it was not already: of the
+// goroutines adding the same URL at once, only one is told it is new.
+func (s *ShardedVisitedSet) Add(url string) bool {
+	return s.claim(url, noDepth)
+}
+
+// claim is Add, found at depth, or noDepth when the URL is not crawled by
+// depth: the least depth of a URL is kept, see least
+func (s *ShardedVisitedSet) claim(url string, depth int) bool {
+	sh := s.shard(url)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	d, ok := sh.seen[url]
+	if !ok {
+		if sh.seen == nil {
+			sh.seen = make(map[string]int32)
+		}
+		sh.seen[url] = int32(min(depth, noDepth))
+		return true
+	}
+	if depth < int(d) {
+		sh.seen[url] = int32(depth)
+	}
+	return false
+}
+
+// least returns the least depth url was claimed at, false when it only was
+// at noDepth
+func (s *ShardedVisitedSet) least(url string) (int, bool) {
+	sh := s.shard(url)
+	sh.mu.Lock()
+	d, ok := sh.seen[url]
+	sh.mu.Unlock()
+	return int(d), ok && d != noDepth
+}
+
+// Len returns how many URLs were marked as seen.
+func (s *ShardedVisitedSet) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.seen)
+		sh.mu.Unlock()
+	}
+	return n
+}
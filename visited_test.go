@@ -0,0 +1,52 @@
+package webcrawl
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestShardedVisitedSetClaim(t *testing.T) {
+	const urls, goroutines = 500, 16
+	s := NewShardedVisitedSet()
+	var mu sync.Mutex
+	claimed := make(map[string]int)
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range urls {
+				u := "http://site.test/" + strconv.Itoa(i)
+				// Every goroutine finds every URL, at depths of its own
+				if s.claim(u, (g*7+i)%goroutines) {
+					mu.Lock()
+					claimed[u]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := s.Len(); n != urls {
+		t.Fatalf("Len = %d, want %d", n, urls)
+	}
+	for i := range urls {
+		u := "http://site.test/" + strconv.Itoa(i)
+		if n := claimed[u]; n != 1 {
+			t.Errorf("%s claimed %d times, want 1", u, n)
+		}
+		if d, ok := s.least(u); !ok || d != 0 {
+			t.Errorf("least(%s) = %d, %v, want 0, true", u, d, ok)
+		}
+	}
+	if s.Add("http://site.test/0") {
+		t.Error("Add of a URL claimed reports it new")
+	}
+	if !s.Add("http://site.test/new") || !s.Seen("http://site.test/new") {
+		t.Error("Add of a new URL doesn't mark it")
+	}
+	if _, ok := s.least("http://site.test/new"); ok {
+		t.Error("least of a URL added without a depth is ok")
+	}
+}
@@ -0,0 +1,239 @@
+package webcrawl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WARCRecord is a record of a WARC file.
+type WARCRecord struct {
+	Type        string // warcinfo, request, response...
+	TargetURI   string // empty for a warcinfo
+	Date        time.Time
+	ContentType string
+	Block       []byte
+
+	// Header holds the other named fields, such as WARC-Concurrent-To or
+	//   WARC-Payload-Digest. WARC-Record-ID is made up when missing
+	Header map[string]string
+}
+
+// WARCWriter writes WARC 1.1 files, such as the ones WARCTransport records
+// a crawl into, for wayback tools to replay. Every record carries a
+// WARC-Block-Digest. A WARCWriter is safe for concurrent use.
+type WARCWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	compress bool
+	info     bool // whether the warcinfo record was written
+}
+
+// NewWARCWriter returns a WARCWriter writing to w. With compress, each
+// record is a gzip member of its own, as .warc.gz files have it, so that
+// tools can seek to any record.
+func NewWARCWriter(w io.Writer, compress bool) *WARCWriter {
+	return &WARCWriter{w: w, compress: compress}
+}
+
+// WriteRecord writes rec out, preceded by a warcinfo record naming this
+// package when it is the first record of the file.
+func (w *WARCWriter) WriteRecord(rec *WARCRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.info {
+		w.info = true
+		info := &WARCRecord{
+			Type:        "warcinfo",
+			Date:        time.Now(),
+			ContentType: "application/warc-fields",
+			Block:       []byte("software: webcrawl\r\nformat: WARC File Format 1.1\r\n"),
+		}
+		if err := w.write(info); err != nil {
+			return err
+		}
+	}
+	return w.write(rec)
+}
+
+// write writes a single record, the caller holds w.mu
+func (w *WARCWriter) write(rec *WARCRecord) error {
+	var buf bytes.Buffer
+	buf.WriteString("WARC/1.1\r\n")
+	fields := map[string]string{
+		"WARC-Type":         rec.Type,
+		"WARC-Date":         rec.Date.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		"WARC-Block-Digest": warcDigest(rec.Block),
+		"Content-Length":    fmt.Sprint(len(rec.Block)),
+	}
+	if rec.TargetURI != "" {
+		fields["WARC-Target-URI"] = rec.TargetURI
+	}
+	if rec.ContentType != "" {
+		fields["Content-Type"] = rec.ContentType
+	}
+	for k, v := range rec.Header {
+		fields[k] = v
+	}
+	if fields["WARC-Record-ID"] == "" {
+		fields["WARC-Record-ID"] = NewWARCRecordID()
+	}
+	// WARC-Type first, as readers expect, the rest sorted to be stable
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "WARC-Type" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range append([]string{"WARC-Type"}, names...) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, fields[k])
+	}
+	buf.WriteString("\r\n")
+	buf.Write(rec.Block)
+	buf.WriteString("\r\n\r\n")
+
+	if !w.compress {
+		_, err := w.w.Write(buf.Bytes())
+		return err
+	}
+	zw := gzip.NewWriter(w.w)
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// NewWARCRecordID returns a fresh WARC-Record-ID, a random UUID URN.
+func NewWARCRecordID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// warcDigest is the SHA-1 digest of b, written the way WARC tools expect
+func warcDigest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// WARCTransport is an http.RoundTripper recording every exchange it makes
+// into a WARC file, as a request record and a response record holding the
+// headers and body. Set it as the Transport of the http.Client of an
+// HTTPFetcher to archive a crawl:
+//
+//	f := webcrawl.NewHTTPFetcher(0)
+//	f.Client.Transport = &webcrawl.WARCTransport{WARC: webcrawl.NewWARCWriter(file, true)}
+//
+// The response body is read in full before RoundTrip returns. When the
+// underlying transport decompressed it, the record holds it decompressed,
+// disable compression on that transport to record the bytes as sent.
+type WARCTransport struct {
+	// Transport makes the requests, nil means http.DefaultTransport
+	Transport http.RoundTripper
+
+	WARC *WARCWriter
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *WARCTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := t.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	date := time.Now()
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	reqID, respID := NewWARCRecordID(), NewWARCRecordID()
+	uri := req.URL.String()
+	err = t.WARC.WriteRecord(&WARCRecord{
+		Type:        "response",
+		TargetURI:   uri,
+		Date:        date,
+		ContentType: "application/http; msgtype=response",
+		Block:       rawResponse(resp, body),
+		Header: map[string]string{
+			"WARC-Record-ID":      respID,
+			"WARC-Concurrent-To":  reqID,
+			"WARC-Payload-Digest": warcDigest(body),
+		},
+	})
+	if err == nil {
+		err = t.WARC.WriteRecord(&WARCRecord{
+			Type:        "request",
+			TargetURI:   uri,
+			Date:        date,
+			ContentType: "application/http; msgtype=request",
+			Block:       rawRequest(req, reqBody),
+			Header: map[string]string{
+				"WARC-Record-ID":     reqID,
+				"WARC-Concurrent-To": respID,
+			},
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("warc: %w", err)
+	}
+	return resp, nil
+}
+
+// rawRequest is req as it went on the wire, give or take the headers the
+// transport adds
+func rawRequest(req *http.Request, body []byte) []byte {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	fmt.Fprintf(bw, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(bw, "Host: %s\r\n", host)
+	req.Header.Write(bw)
+	bw.WriteString("\r\n")
+	bw.Write(body)
+	bw.Flush()
+	return buf.Bytes()
+}
+
+// rawResponse is resp as it came off the wire, with its body unchunked
+func rawResponse(resp *http.Response, body []byte) []byte {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	fmt.Fprintf(bw, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	resp.Header.Write(bw)
+	bw.WriteString("\r\n")
+	bw.Write(body)
+	bw.Flush()
+	return buf.Bytes()
+}
@@ -0,0 +1,275 @@
+package webcrawl
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The events a Webhook posts, in the event field of its payloads and its
+// X-Webcrawl-Event header
+const (
+	EventStarted   = "crawl.started"    // the crawl is starting, from seed
+	EventFinished  = "crawl.finished"   // the crawl is over, with its counts and error if any
+	EventErrorRate = "crawl.error_rate" // the share of failed fetches went over ErrorRate
+	EventResults   = "crawl.results"    // a batch of BatchSize results
+)
+
+// Webhook posts the events of a crawl to URL, as JSON objects:
+//
+//	{"event":"crawl.finished","seed":"https://example.com/","time":"...","pages":120,"errors":3,"duration_ms":5230.1}
+//
+// A post answered with a 429 or a 5xx status, or that fails to get an
+// answer, is tried again as Retry says. Call Start before the crawl, Add
+// with every result, from Crawler.OnResult for instance, and Finish once
+// it's over. A Webhook is safe for concurrent use.
+type Webhook struct {
+	URL string
+
+	// Secret, when set, signs the posts: their X-Webcrawl-Signature
+	//   header is sha256= followed by the HMAC-SHA256 of the body with
+	//   Secret as the key, in hex
+	Secret string
+
+	// Events are the ones posted, all but EventResults when empty
+	Events []string
+
+	// ErrorRate is the share of failed fetches, between 0 and 1, that
+	//   posts EventErrorRate, once, after MinResults results at least. Zero
+	//   never posts it
+	ErrorRate  float64
+	MinResults int
+
+	// BatchSize is the number of results of an EventResults post, with
+	//   the fields url, depth, status, error and cause of the jsonl
+	//   format. Finish posts the results left over first. 100 when zero
+	BatchSize int
+
+	// Retry says how the failed posts are tried again, DefaultRetryPolicy
+	//   when nil
+	Retry *RetryPolicy
+
+	// Client posts the events, http.DefaultClient when nil
+	Client *http.Client
+
+	// Timeout bounds each attempt of a post. Zero means DefaultTimeout, a
+	//   negative value disables the timeout
+	Timeout time.Duration
+
+	mu            sync.Mutex
+	seed          string
+	started       time.Time
+	pages, failed int
+	alerted       bool
+	batch         []json.RawMessage
+	inFlight      sync.WaitGroup // the posts made in the background under way
+	bgCtx         context.Context
+	cancel        context.CancelFunc // cancels bgCtx, the context of the posts in the background
+	firstErr      error
+}
+
+// webhookPayload is the body of a post
+type webhookPayload struct {
+	Event      string            `json:"event"`
+	Seed       string            `json:"seed"`
+	Time       time.Time         `json:"time"`
+	Pages      int               `json:"pages,omitempty"`
+	Errors     int               `json:"errors,omitempty"`
+	ErrorRate  float64           `json:"error_rate,omitempty"`
+	DurationMS float64           `json:"duration_ms,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Results    []json.RawMessage `json:"results,omitempty"`
+}
+
+// wants reports whether the events of w include event
+func (w *Webhook) wants(event string) bool {
+	if len(w.Events) == 0 {
+		return event != EventResults
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Start posts EventStarted, for a crawl from seed.
+func (w *Webhook) Start(ctx context.Context, seed string) error {
+	w.mu.Lock()
+	w.seed, w.started = seed, time.Now()
+	w.mu.Unlock()
+	if !w.wants(EventStarted) {
+		return nil
+	}
+	return w.post(ctx, webhookPayload{Event: EventStarted, Seed: seed, Time: time.Now()})
+}
+
+// Add counts res, posting EventErrorRate when the share of failed fetches
+// goes over ErrorRate, and EventResults when a batch is full. The posts
+// are made in the background, Finish returning the error of the first one
+// that failed.
+func (w *Webhook) Add(res CrawlResult) {
+	var line []byte
+	if w.wants(EventResults) {
+		line, _ = marshalResult(&res, []string{"url", "depth", "status", "error", "cause"})
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pages++
+	if res.Err != nil {
+		w.failed++
+	}
+	p := webhookPayload{Seed: w.seed, Pages: w.pages, Errors: w.failed}
+	rate := float64(w.failed) / float64(w.pages)
+	if w.ErrorRate > 0 && !w.alerted && w.pages >= w.MinResults && rate > w.ErrorRate && w.wants(EventErrorRate) {
+		w.alerted = true
+		p.Event, p.ErrorRate, p.Time = EventErrorRate, rate, time.Now()
+		w.background(p)
+	}
+	if line != nil {
+		w.batch = append(w.batch, line)
+		if len(w.batch) >= w.batchSize() {
+			w.background(webhookPayload{Event: EventResults, Seed: w.seed, Time: time.Now(), Results: w.batch})
+			w.batch = nil
+		}
+	}
+}
+
+func (w *Webhook) batchSize() int {
+	if w.BatchSize <= 0 {
+		return 100
+	}
+	return w.BatchSize
+}
+
+// background posts p in a goroutine of its own, recording its error if
+// it is the first. The caller holds w.mu
+func (w *Webhook) background(p webhookPayload) {
+	if w.bgCtx == nil {
+		w.bgCtx, w.cancel = context.WithCancel(context.Background())
+	}
+	ctx := w.bgCtx
+	w.inFlight.Add(1)
+	go func() {
+		defer w.inFlight.Done()
+		if err := w.post(ctx, p); err != nil {
+			w.mu.Lock()
+			if w.firstErr == nil {
+				w.firstErr = err
+			}
+			w.mu.Unlock()
+		}
+	}()
+}
+
+// Finish posts the results left over and EventFinished, crawlErr being
+// what the crawl returned. It waits for the posts made in the background,
+// canceling those left once ctx is done, and returns the first error among
+// theirs and its own.
+func (w *Webhook) Finish(ctx context.Context, crawlErr error) error {
+	w.mu.Lock()
+	if len(w.batch) > 0 {
+		w.background(webhookPayload{Event: EventResults, Seed: w.seed, Time: time.Now(), Results: w.batch})
+		w.batch = nil
+	}
+	p := webhookPayload{Event: EventFinished, Seed: w.seed, Time: time.Now(), Pages: w.pages, Errors: w.failed,
+		DurationMS: ms(time.Since(w.started))}
+	w.mu.Unlock()
+	if crawlErr != nil {
+		p.Error = crawlErr.Error()
+	}
+	done := make(chan struct{})
+	go func() {
+		w.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		w.mu.Lock()
+		if w.cancel != nil {
+			w.cancel()
+		}
+		w.mu.Unlock()
+		<-done
+	}
+
+	w.mu.Lock()
+	err := w.firstErr
+	w.mu.Unlock()
+	if w.wants(EventFinished) {
+		if perr := w.post(ctx, p); err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// post posts p, trying again as Retry says
+func (w *Webhook) post(ctx context.Context, p webhookPayload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	policy := w.Retry
+	if policy == nil {
+		policy = &DefaultRetryPolicy
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for attempt := 1; ; attempt++ {
+		err = w.send(ctx, client, p.Event, body)
+		if err == nil || attempt >= policy.MaxAttempts || !Retryable(err) {
+			return err
+		}
+		wait, _ := RetryAfter(err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(max(policy.Delay(attempt), wait)):
+		}
+	}
+}
+
+// send makes one post of body for event, bounded by Timeout
+func (w *Webhook) send(ctx context.Context, client *http.Client, event string, body []byte) error {
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	req.Header.Set("X-Webcrawl-Event", event)
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Webcrawl-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError(w.URL, resp)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package webcrawl_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackyugit/webcrawl"
+)
+
+// hook serves the posts of a Webhook: a batch of results with the URL
+// /bad is refused, one with /hang is never answered
+func hook(posted chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var p struct {
+			Results []struct{ URL string } `json:"results"`
+		}
+		json.NewDecoder(req.Body).Decode(&p)
+		ev := req.Header.Get("X-Webcrawl-Event")
+		if len(p.Results) > 0 {
+			ev += " " + p.Results[0].URL
+		}
+		posted <- ev
+		switch {
+		case strings.HasSuffix(ev, "/bad"):
+			http.Error(w, "bad", http.StatusBadRequest)
+		case strings.HasSuffix(ev, "/hang"):
+			<-req.Context().Done()
+		}
+	}))
+}
+
+func TestWebhookFinish(t *testing.T) {
+	posted := make(chan string, 10)
+	srv := hook(posted)
+	defer srv.Close()
+	w := &webcrawl.Webhook{URL: srv.URL, BatchSize: 1, Timeout: 50 * time.Millisecond,
+		Events: []string{webcrawl.EventResults, webcrawl.EventFinished}, Retry: &webcrawl.RetryPolicy{MaxAttempts: 1}}
+
+	w.Add(webcrawl.CrawlResult{URL: "https://site.test/bad"})
+	<-posted
+	w.Add(webcrawl.CrawlResult{URL: "https://site.test/hang"})
+	start := time.Now()
+	err := w.Finish(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Finish = %v, want the 400 of the first post that failed", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Finish took %s, want the post left hanging cut off by Timeout", d)
+	}
+	if got := <-posted + ", " + <-posted; got != "crawl.results https://site.test/hang, crawl.finished" {
+		t.Errorf("posted %s", got)
+	}
+}
+
+func TestWebhookFinishCanceled(t *testing.T) {
+	posted := make(chan string, 10)
+	srv := hook(posted)
+	defer srv.Close()
+	w := &webcrawl.Webhook{URL: srv.URL, BatchSize: 1, Timeout: -1, Events: []string{webcrawl.EventResults},
+		Retry: &webcrawl.RetryPolicy{MaxAttempts: 1}}
+
+	w.Add(webcrawl.CrawlResult{URL: "https://site.test/hang"})
+	<-posted
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Finish(ctx, nil); err == nil {
+		t.Error("Finish with a post left hanging: no error")
+	}
+}